@@ -31,7 +31,7 @@ func (s *stubBackend) Execute(_ context.Context, spec backend.WorkloadSpec) (bac
 
 	if spec.LogWriter != nil {
 		for _, line := range s.logLines {
-			spec.LogWriter(line)
+			spec.LogWriter("", line)
 		}
 	}
 
@@ -3,6 +3,8 @@
 // the appropriate runtime, and streams results back over vsock.
 //
 // Build with: CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -o vulcan-guest ./cmd/vulcan-guest
+// Set the version reported in the host handshake with
+// -ldflags "-X github.com/seantiz/vulcan/internal/guest.Version=<version>".
 package main
 
 import (
@@ -24,9 +26,10 @@ func main() {
 	}
 	defer l.Close()
 
-	log.Printf("vulcan-guest listening on vsock port %d", port)
+	log.Printf("vulcan-guest %s listening on vsock port %d", guest.Version, port)
 
 	agent := guest.New(l, fc.GuestWorkDir)
+	agent.SetMaxExecs(guest.BootMaxExecs())
 	if err := agent.Serve(); err != nil {
 		log.Fatalf("serve: %v", err)
 	}
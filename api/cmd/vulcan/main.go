@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -9,8 +10,10 @@ import (
 	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
 	"github.com/seantiz/vulcan/internal/config"
 	"github.com/seantiz/vulcan/internal/engine"
+	"github.com/seantiz/vulcan/internal/images"
 	"github.com/seantiz/vulcan/internal/model"
 	"github.com/seantiz/vulcan/internal/store"
+	"github.com/seantiz/vulcan/internal/volumes"
 )
 
 func main() {
@@ -28,7 +31,14 @@ func main() {
 	}
 	defer db.Close()
 
+	// Sessions' sandboxes do not survive a restart.
+	if err := db.CloseUnfinishedSessions(context.Background(), model.SessionClosedByRestart); err != nil {
+		log.Fatalf("failed to close sessions: %v", err)
+	}
+
 	reg := backend.NewRegistry()
+	var catalog *images.Catalog
+	var volumeMgr *volumes.Manager
 
 	// Register Firecracker backend if configured.
 	fcCfg := fc.LoadConfig()
@@ -41,11 +51,41 @@ func main() {
 		} else {
 			reg.Register(model.IsolationMicroVM, fcBackend)
 			logger.Info("firecracker backend registered")
+			fcBackend.StartSweeper()
+
+			inspect := func(ctx context.Context, path string) (string, error) {
+				agent, err := fc.InspectRootfs(ctx, fc.DefaultDebugFSBin, path)
+				return agent.Version, err
+			}
+			imgCfg := images.LoadConfig()
+			catalog, err = images.NewCatalog(imgCfg, db, inspect, logger)
+			if err != nil {
+				logger.Warn("image catalog unavailable", "error", err)
+			} else if imgCfg.AgentBin != "" {
+				catalog.SetBuilder(func(ctx context.Context, dir, output string, sizeMB int) error {
+					return fc.BuildRootfs(ctx, fc.DefaultMkfsBin, imgCfg.AgentBin, dir, output, sizeMB)
+				})
+			}
+
+			format := func(ctx context.Context, path string) error {
+				return fc.FormatVolume(ctx, fc.DefaultMkfsBin, path)
+			}
+			volumeMgr, err = volumes.NewManager(context.Background(), volumes.LoadConfig(), db, format, logger)
+			if err != nil {
+				logger.Warn("volumes unavailable", "error", err)
+			}
 		}
 	}
 
 	eng := engine.NewEngine(db, reg, logger)
+	eng.SetScratchBudget(cfg.ScratchBudgetMB)
 	srv := api.NewServer(cfg.ListenAddr, db, reg, eng, logger)
+	if catalog != nil {
+		srv.SetImageCatalog(catalog)
+	}
+	if volumeMgr != nil {
+		srv.SetVolumeManager(volumeMgr)
+	}
 
 	if err := srv.Run(); err != nil {
 		log.Fatalf("server error: %v", err)
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/seantiz/vulcan/internal/backend"
+)
+
+// orphansResponse lists the resources leaked by each backend that can sweep.
+type orphansResponse struct {
+	Reports []backend.SweepReport `json:"reports"`
+}
+
+// handleListOrphans reports resources leaked by sandboxes that are no longer
+// running, without removing them.
+func (s *Server) handleListOrphans(w http.ResponseWriter, r *http.Request) {
+	resp := orphansResponse{Reports: []backend.SweepReport{}}
+	for name, sw := range s.registry.Sweepers() {
+		report, err := sw.Sweep(r.Context(), true)
+		if err != nil {
+			s.logger.Error("failed to sweep for orphans", "backend", name, "error", err)
+			s.writeError(w, http.StatusInternalServerError, "failed to sweep for orphans")
+			return
+		}
+		if report.Resources == nil {
+			report.Resources = []backend.LeakedResource{}
+		}
+		resp.Reports = append(resp.Reports, report)
+	}
+	slices.SortFunc(resp.Reports, func(a, b backend.SweepReport) int {
+		return strings.Compare(a.Backend, b.Backend)
+	})
+	s.writeJSON(w, http.StatusOK, resp)
+}
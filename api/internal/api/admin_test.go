@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// sweepingStubBackend is a stubBackend that reports a leaked resource.
+type sweepingStubBackend struct {
+	stubBackend
+	err    error
+	dryRun *bool
+}
+
+func (s *sweepingStubBackend) Sweep(_ context.Context, dryRun bool) (backend.SweepReport, error) {
+	s.dryRun = &dryRun
+	if s.err != nil {
+		return backend.SweepReport{}, s.err
+	}
+	return backend.SweepReport{
+		Backend: "test-microvm",
+		DryRun:  dryRun,
+		Resources: []backend.LeakedResource{
+			{Kind: "netns", Name: "vulcan-01J0000000000000000000000", WorkloadID: "01J0000000000000000000000"},
+		},
+	}, nil
+}
+
+func TestListOrphans(t *testing.T) {
+	srv := newTestServer(t)
+	sweeper := &sweepingStubBackend{}
+	srv.registry.Register(model.IsolationIsolate, &stubBackend{})
+	srv.registry.Register(model.IsolationMicroVM, sweeper)
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/admin/orphans")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if sweeper.dryRun == nil || !*sweeper.dryRun {
+		t.Error("orphans endpoint did not request a dry run")
+	}
+
+	var body orphansResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Reports) != 1 {
+		t.Fatalf("reports = %d, want 1 (only sweeping backends)", len(body.Reports))
+	}
+	report := body.Reports[0]
+	if report.Backend != "test-microvm" || !report.DryRun || len(report.Resources) != 1 {
+		t.Errorf("report = %+v, want one leaked resource from a dry run", report)
+	}
+	if report.Resources[0].Removed {
+		t.Error("dry-run resource reported as removed")
+	}
+}
+
+func TestListOrphansNoSweepers(t *testing.T) {
+	srv := newTestServer(t)
+	srv.registry.Register(model.IsolationIsolate, &stubBackend{})
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/admin/orphans")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if string(body["reports"]) != "[]" {
+		t.Errorf("reports = %s, want []", body["reports"])
+	}
+}
+
+func TestListOrphansSweepError(t *testing.T) {
+	srv := newTestServer(t)
+	srv.registry.Register(model.IsolationMicroVM, &sweepingStubBackend{err: errors.New("boom")})
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/admin/orphans")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", resp.StatusCode)
+	}
+}
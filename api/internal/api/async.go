@@ -3,9 +3,11 @@ package api
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/seantiz/vulcan/internal/engine"
 	"github.com/seantiz/vulcan/internal/model"
 )
 
@@ -24,6 +26,10 @@ func (s *Server) handleAsyncWorkload(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadRequest, "runtime is required")
 		return
 	}
+	if invalidImageRef(req.Runtime) {
+		s.writeError(w, http.StatusBadRequest, "invalid image reference; use <name>@<version>")
+		return
+	}
 
 	now := time.Now().UTC()
 	wl := &model.Workload{
@@ -47,8 +53,30 @@ func (s *Server) handleAsyncWorkload(w http.ResponseWriter, r *http.Request) {
 	if err := s.parseCodeFields(&req, wl, w); err != nil {
 		return // error already written
 	}
+	if err := s.parseNetwork(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseRateLimits(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseDisk(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseGrace(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseVolumes(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseService(&req, wl, w); err != nil {
+		return // error already written
+	}
 
 	if err := s.engine.Submit(r.Context(), wl); err != nil {
+		if errors.Is(err, engine.ErrScratchBudget) {
+			s.writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
 		s.logger.Error("submit async workload", "error", err)
 		s.writeError(w, http.StatusInternalServerError, "failed to submit workload")
 		return
@@ -83,3 +111,101 @@ func (s *Server) parseCodeFields(req *createWorkloadRequest, wl *model.Workload,
 
 	return nil
 }
+
+// parseNetwork validates the request's network policy, exposed port and
+// network group and sets them on the workload. Returns an error if validation fails (error
+// already written to w).
+func (s *Server) parseNetwork(req *createWorkloadRequest, wl *model.Workload, w http.ResponseWriter) error {
+	if req.Network != nil {
+		if err := req.Network.Validate(); err != nil {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return errValidation
+		}
+		wl.Network = req.Network
+	}
+
+	if req.ExposePort != nil {
+		if port := *req.ExposePort; port < 1 || port > 65535 {
+			s.writeError(w, http.StatusBadRequest, "expose_port must be between 1 and 65535")
+			return errValidation
+		}
+		if req.Network.EffectiveMode() == model.NetworkNone {
+			s.writeError(w, http.StatusBadRequest, "expose_port requires a network mode other than none")
+			return errValidation
+		}
+		wl.ExposePort = req.ExposePort
+	}
+
+	if err := model.ValidateGroup(req.Group, req.Name); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return errValidation
+	}
+	if req.Group != "" && req.Network.EffectiveMode() == model.NetworkNone {
+		s.writeError(w, http.StatusBadRequest, "group requires a network mode other than none")
+		return errValidation
+	}
+	wl.Group = req.Group
+	wl.Name = req.Name
+	return nil
+}
+
+// parseRateLimits validates the request's disk and network rate limits and
+// sets them on the workload. Returns an error if validation fails (error
+// already written to w).
+func (s *Server) parseRateLimits(req *createWorkloadRequest, wl *model.Workload, w http.ResponseWriter) error {
+	if req.Resources == nil || req.Resources.RateLimits.IsZero() {
+		return nil
+	}
+	limits := req.Resources.RateLimits
+	if err := limits.Validate(); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return errValidation
+	}
+	if limits.HasNetwork() && req.Network.EffectiveMode() == model.NetworkNone {
+		s.writeError(w, http.StatusBadRequest, "network rate limits require a network mode other than none")
+		return errValidation
+	}
+	wl.RateLimits = &limits
+	return nil
+}
+
+// parseDisk validates the request's scratch disk size and sets it on the
+// workload. Returns an error if validation fails (error already written to w).
+func (s *Server) parseDisk(req *createWorkloadRequest, wl *model.Workload, w http.ResponseWriter) error {
+	if req.Resources == nil || req.Resources.DiskMB == nil || *req.Resources.DiskMB == 0 {
+		return nil
+	}
+	if err := model.ValidateDiskMB(*req.Resources.DiskMB); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return errValidation
+	}
+	wl.DiskLimit = req.Resources.DiskMB
+	return nil
+}
+
+// parseGrace validates the request's grace period and sets it on the
+// workload. Returns an error if validation fails (error already written to w).
+func (s *Server) parseGrace(req *createWorkloadRequest, wl *model.Workload, w http.ResponseWriter) error {
+	if req.Resources == nil || req.Resources.GraceS == nil {
+		return nil
+	}
+	if err := model.ValidateGraceS(*req.Resources.GraceS); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return errValidation
+	}
+	wl.GraceS = req.Resources.GraceS
+	return nil
+}
+
+// parseVolumes validates the request's volume mounts and sets them on the
+// workload. Whether the volumes exist and can be locked is checked when the
+// workload starts. Returns an error if validation fails (error already
+// written to w).
+func (s *Server) parseVolumes(req *createWorkloadRequest, wl *model.Workload, w http.ResponseWriter) error {
+	if err := model.ValidateVolumeMounts(req.Volumes); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return errValidation
+	}
+	wl.Volumes = req.Volumes
+	return nil
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/seantiz/vulcan/internal/images"
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// Multipart part names for image uploads.
+const (
+	imageMetadataPart = "metadata"
+	imageFilePart     = "image"
+)
+
+// registerImageRequest is the JSON body for POST /v1/images, or the
+// metadata part of a multipart upload.
+type registerImageRequest struct {
+	Name       string   `json:"name"`
+	Version    string   `json:"version"`
+	Command    []string `json:"command"`
+	Entrypoint string   `json:"entrypoint"`
+
+	// Path is an image file in the catalog's import directory on the host
+	// to copy into the catalog. Only used with a JSON body; uploads carry
+	// the file instead.
+	Path string `json:"path"`
+}
+
+func (r *registerImageRequest) image() model.Image {
+	return model.Image{Name: r.Name, Version: r.Version, Command: r.Command, Entrypoint: r.Entrypoint}
+}
+
+// buildImageRequest is the JSON body for POST /v1/images/build.
+type buildImageRequest struct {
+	registerImageRequest
+
+	// Source is an OCI image layout directory, or an OCI layout or
+	// docker-save tar archive, in the catalog's import directory on the host.
+	Source string `json:"source"`
+	Tag    string `json:"tag"`
+	SizeMB int    `json:"size_mb"`
+}
+
+// listImagesResponse wraps the image list response.
+type listImagesResponse struct {
+	Images []*model.Image `json:"images"`
+}
+
+// invalidImageRef reports whether runtime names an image version, as
+// "<name>@<version>", but is malformed.
+func invalidImageRef(runtime string) bool {
+	if !strings.Contains(runtime, model.ImageRefSeparator) {
+		return false
+	}
+	_, _, ok := model.ParseImageRef(runtime)
+	return !ok
+}
+
+// SetImageCatalog enables image registration, deletion and garbage
+// collection through the catalog c. Without a catalog those endpoints
+// return 503, while images already in the store can still be listed.
+func (s *Server) SetImageCatalog(c *images.Catalog) {
+	s.images = c
+}
+
+func (s *Server) handleListImages(w http.ResponseWriter, r *http.Request) {
+	list, err := s.store.ListImages(r.Context(), r.URL.Query().Get("name"))
+	if err != nil {
+		s.logger.Error("list images", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to list images")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, listImagesResponse{Images: list})
+}
+
+func (s *Server) handleGetImage(w http.ResponseWriter, r *http.Request) {
+	img, err := s.store.GetImage(r.Context(), chi.URLParam(r, "name"), chi.URLParam(r, "version"))
+	if err != nil {
+		if errors.Is(err, store.ErrImageNotFound) {
+			s.writeError(w, http.StatusNotFound, "image not found")
+			return
+		}
+		s.logger.Error("get image", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve image")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, img)
+}
+
+// handleRegisterImage registers an image from a file on the host (JSON body)
+// or from an upload (multipart body with a metadata part followed by an image
+// part).
+func (s *Server) handleRegisterImage(w http.ResponseWriter, r *http.Request) {
+	if s.images == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "image catalog is not configured")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var img *model.Image
+	var err error
+	if mediaType == "multipart/form-data" {
+		img, err = s.registerUploadedImage(w, r)
+	} else {
+		var req registerImageRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if req.Path == "" {
+			s.writeError(w, http.StatusBadRequest, "path is required; upload the image as multipart/form-data instead")
+			return
+		}
+		img, err = s.images.RegisterFile(r.Context(), req.image(), req.Path)
+	}
+
+	switch {
+	case errors.Is(err, errValidation):
+		// Response already written.
+	case errors.Is(err, images.ErrImportDisabled):
+		s.writeError(w, http.StatusForbidden, "registering images by path is disabled; upload the image as multipart/form-data instead")
+	case errors.Is(err, images.ErrInvalidImage):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, store.ErrImageExists):
+		s.writeError(w, http.StatusConflict, "image version already exists")
+	case err != nil:
+		s.logger.Error("register image", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to register image")
+	default:
+		s.writeJSON(w, http.StatusCreated, img)
+	}
+}
+
+// registerUploadedImage streams a multipart image upload into the catalog.
+func (s *Server) registerUploadedImage(w http.ResponseWriter, r *http.Request) (*model.Image, error) {
+	// Images can take longer to upload than the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Warn("failed to clear write deadline for image upload", "error", err)
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid multipart body")
+		return nil, errValidation
+	}
+
+	part, err := mr.NextPart()
+	if err != nil || part.FormName() != imageMetadataPart {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("first part must be %q", imageMetadataPart))
+		return nil, errValidation
+	}
+	var req registerImageRequest
+	if err := json.NewDecoder(part).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid metadata JSON")
+		return nil, errValidation
+	}
+
+	part, err = mr.NextPart()
+	if err != nil || part.FormName() != imageFilePart {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("second part must be %q", imageFilePart))
+		return nil, errValidation
+	}
+	return s.images.Register(r.Context(), req.image(), part)
+}
+
+// handleBuildImage builds an image from a container image on the host and
+// registers it.
+func (s *Server) handleBuildImage(w http.ResponseWriter, r *http.Request) {
+	if s.images == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "image catalog is not configured")
+		return
+	}
+
+	var req buildImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if req.Source == "" {
+		s.writeError(w, http.StatusBadRequest, "source is required")
+		return
+	}
+
+	// Unpacking and building large images can outlast the write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Warn("failed to clear write deadline for image build", "error", err)
+	}
+
+	img, err := s.images.Build(r.Context(), images.BuildRequest{
+		Image:  req.image(),
+		Source: req.Source,
+		Tag:    req.Tag,
+		SizeMB: req.SizeMB,
+	})
+	switch {
+	case errors.Is(err, images.ErrBuildsUnavailable):
+		s.writeError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, images.ErrImportDisabled):
+		s.writeError(w, http.StatusForbidden, "building images is disabled without an import directory")
+	case errors.Is(err, images.ErrInvalidImage):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, store.ErrImageExists):
+		s.writeError(w, http.StatusConflict, "image version already exists")
+	case err != nil:
+		s.logger.Error("build image", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to build image")
+	default:
+		s.writeJSON(w, http.StatusCreated, img)
+	}
+}
+
+func (s *Server) handleDeleteImage(w http.ResponseWriter, r *http.Request) {
+	if s.images == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "image catalog is not configured")
+		return
+	}
+
+	if err := s.images.Delete(r.Context(), chi.URLParam(r, "name"), chi.URLParam(r, "version")); err != nil {
+		if errors.Is(err, store.ErrImageNotFound) {
+			s.writeError(w, http.StatusNotFound, "image not found")
+			return
+		}
+		s.logger.Error("delete image", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to delete image")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleImageGC garbage-collects unused image versions. With ?dry_run=true
+// it only reports what would be removed.
+func (s *Server) handleImageGC(w http.ResponseWriter, r *http.Request) {
+	if s.images == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "image catalog is not configured")
+		return
+	}
+
+	report, err := s.images.GC(r.Context(), r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		s.logger.Error("image gc", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to garbage-collect images")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/images"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// newImageTestServer returns a test server with an image catalog whose
+// inspector accepts any file except one containing "no agent".
+func newImageTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newImageTestServerWithImports(t, "")
+}
+
+// newImageTestServerWithImports is newImageTestServer with images read by
+// path from importDir.
+func newImageTestServerWithImports(t *testing.T, importDir string) *httptest.Server {
+	t.Helper()
+	srv := newTestServer(t)
+	inspect := func(_ context.Context, path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if string(data) == "no agent" {
+			return "", errors.New("guest agent not found in image")
+		}
+		return "v1.5.0", nil
+	}
+	cfg := images.Config{Dir: t.TempDir(), KeepVersions: 1, ImportDir: importDir}
+	catalog, err := images.NewCatalog(cfg, srv.store, inspect, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	srv.SetImageCatalog(catalog)
+
+	ts := httptest.NewServer(srv.Router())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// uploadImage posts a multipart image upload and returns the response.
+func uploadImage(t *testing.T, url, metadata, contents string) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("metadata", metadata)
+	fw, err := mw.CreateFormFile("image", "rootfs.ext4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(contents))
+	mw.Close()
+
+	resp, err := http.Post(url+"/v1/images", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("POST /v1/images: %v", err)
+	}
+	return resp
+}
+
+func TestUploadImage(t *testing.T) {
+	ts := newImageTestServer(t)
+
+	resp := uploadImage(t, ts.URL, `{"name":"python","version":"3.12","command":["python3"],"entrypoint":"main.py"}`, "rootfs")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	var img model.Image
+	if err := json.NewDecoder(resp.Body).Decode(&img); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if img.Ref() != "python@3.12" || img.SizeBytes != 6 || img.AgentVersion != "v1.5.0" || img.Digest == "" {
+		t.Errorf("image = %+v, want python@3.12 with digest and agent version", img)
+	}
+
+	get, err := http.Get(ts.URL + "/v1/images/python/3.12")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer get.Body.Close()
+	if get.StatusCode != http.StatusOK {
+		t.Errorf("GET status = %d, want 200", get.StatusCode)
+	}
+
+	dup := uploadImage(t, ts.URL, `{"name":"python","version":"3.12","command":["python3"],"entrypoint":"main.py"}`, "rootfs")
+	dup.Body.Close()
+	if dup.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate status = %d, want 409", dup.StatusCode)
+	}
+}
+
+func TestUploadImageInvalid(t *testing.T) {
+	ts := newImageTestServer(t)
+
+	tests := []struct {
+		name, metadata, contents string
+	}{
+		{"no agent", `{"name":"python","version":"3.12","command":["python3"],"entrypoint":"main.py"}`, "no agent"},
+		{"no command", `{"name":"python","version":"3.12","entrypoint":"main.py"}`, "rootfs"},
+		{"bad metadata", `{"name":`, "rootfs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := uploadImage(t, ts.URL, tt.metadata, tt.contents)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRegisterImageFromPath(t *testing.T) {
+	importDir := t.TempDir()
+	ts := newImageTestServerWithImports(t, importDir)
+	path := filepath.Join(importDir, "ml.ext4")
+	if err := os.WriteFile(path, []byte("ml rootfs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"name":"ml","version":"1.0","command":["python3","-u"],"entrypoint":"train.py","path":"` + path + `"}`
+	resp, err := http.Post(ts.URL+"/v1/images", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/v1/images", "application/json", bytes.NewBufferString(`{"name":"ml","version":"1.1","command":["python3"],"entrypoint":"main.py"}`))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status without path = %d, want 400", resp.StatusCode)
+	}
+
+	outside := filepath.Join(t.TempDir(), "ml.ext4")
+	if err := os.WriteFile(outside, []byte("ml rootfs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	body = `{"name":"ml","version":"1.2","command":["python3"],"entrypoint":"main.py","path":"` + outside + `"}`
+	resp, err = http.Post(ts.URL+"/v1/images", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status for a path outside the import dir = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestRegisterImageFromPathDisabled(t *testing.T) {
+	ts := newImageTestServer(t)
+	path := filepath.Join(t.TempDir(), "ml.ext4")
+	if err := os.WriteFile(path, []byte("ml rootfs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"name":"ml","version":"1.0","command":["python3"],"entrypoint":"main.py","path":"` + path + `"}`
+	resp, err := http.Post(ts.URL+"/v1/images", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status without an import dir = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestListAndDeleteImages(t *testing.T) {
+	ts := newImageTestServer(t)
+	for _, version := range []string{"3.11", "3.12"} {
+		resp := uploadImage(t, ts.URL, `{"name":"python","version":"`+version+`","command":["python3"],"entrypoint":"main.py"}`, "rootfs "+version)
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/images?name=python")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var list listImagesResponse
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Images) != 2 || list.Images[0].Version != "3.12" {
+		t.Fatalf("images = %+v, want 3.12 then 3.11", list.Images)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/images/python/3.11", nil)
+	del, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	del.Body.Close()
+	if del.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", del.StatusCode)
+	}
+
+	del, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	del.Body.Close()
+	if del.StatusCode != http.StatusNotFound {
+		t.Errorf("second DELETE status = %d, want 404", del.StatusCode)
+	}
+}
+
+func TestImageGCDryRun(t *testing.T) {
+	ts := newImageTestServer(t)
+
+	resp, err := http.Post(ts.URL+"/v1/images/gc?dry_run=true", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var report images.GCReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !report.DryRun || len(report.Images) != 0 {
+		t.Errorf("report = %+v, want an empty dry run", report)
+	}
+}
+
+func TestImageCatalogNotConfigured(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp := uploadImage(t, ts.URL, `{"name":"python","version":"3.12","command":["python3"],"entrypoint":"main.py"}`, "rootfs")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("upload status = %d, want 503", resp.StatusCode)
+	}
+
+	list, err := http.Get(ts.URL + "/v1/images")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	list.Body.Close()
+	if list.StatusCode != http.StatusOK {
+		t.Errorf("list status = %d, want 200", list.StatusCode)
+	}
+}
+
+func TestBuildImage(t *testing.T) {
+	srv := newTestServer(t)
+	inspect := func(context.Context, string) (string, error) { return "v1.5.0", nil }
+	importDir := t.TempDir()
+	catalog, err := images.NewCatalog(images.Config{Dir: t.TempDir(), ImportDir: importDir}, srv.store, inspect, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	srv.SetImageCatalog(catalog)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	post := func(body string) int {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/v1/images/build", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post(`{"name":"ml","version":"1.0","entrypoint":"main.py","source":"/srv/ml.tar"}`); got != http.StatusServiceUnavailable {
+		t.Errorf("status without a builder = %d, want 503", got)
+	}
+
+	catalog.SetBuilder(func(_ context.Context, _, output string, _ int) error {
+		return os.WriteFile(output, []byte("rootfs"), 0o644)
+	})
+	if got := post(`{"name":"ml","version":"1.0","entrypoint":"main.py"}`); got != http.StatusBadRequest {
+		t.Errorf("status without source = %d, want 400", got)
+	}
+	if got := post(`{"name":"ml","version":"1.0","entrypoint":"main.py","source":"` + importDir + `"}`); got != http.StatusBadRequest {
+		t.Errorf("status for a directory that is not an image = %d, want 400", got)
+	}
+	if got := post(`{"name":"ml","version":"1.0","entrypoint":"main.py","source":"` + t.TempDir() + `"}`); got != http.StatusBadRequest {
+		t.Errorf("status for a source outside the import dir = %d, want 400", got)
+	}
+}
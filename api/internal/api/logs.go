@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -59,7 +60,7 @@ func (s *Server) handleStreamLogs(w http.ResponseWriter, r *http.Request) {
 
 	for {
 		select {
-		case line, ok := <-ch:
+		case ev, ok := <-ch:
 			if !ok {
 				// Workload finished; send explicit done event before closing.
 				_ = writeSSEEvent(w, "done", "stream complete")
@@ -68,7 +69,7 @@ func (s *Server) handleStreamLogs(w http.ResponseWriter, r *http.Request) {
 				}
 				return
 			}
-			if err := writeSSEData(w, line); err != nil {
+			if err := writeSSELine(w, ev.Stream, ev.Line); err != nil {
 				return // Write failed (e.g. client gone).
 			}
 			if canFlush {
@@ -83,6 +84,7 @@ func (s *Server) handleStreamLogs(w http.ResponseWriter, r *http.Request) {
 // logHistoryLine is a single log line in the history response.
 type logHistoryLine struct {
 	Seq       int    `json:"seq"`
+	Stream    string `json:"stream,omitempty"`
 	Line      string `json:"line"`
 	CreatedAt string `json:"created_at"`
 }
@@ -90,6 +92,7 @@ type logHistoryLine struct {
 // logHistoryResponse is the JSON response for GET /v1/workloads/:id/logs/history.
 type logHistoryResponse struct {
 	WorkloadID string           `json:"workload_id"`
+	Stream     string           `json:"stream,omitempty"`
 	Lines      []logHistoryLine `json:"lines"`
 }
 
@@ -108,7 +111,26 @@ func (s *Server) handleGetLogHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logLines, err := s.store.GetLogLines(r.Context(), id)
+	// The workload's own log, all its streams interleaved, is the default;
+	// ?stream=stdout, stderr or system keeps the lines of one stream.
+	// ?stream=console returns the sandbox's console output and ?stream=build
+	// the output of compiling its code instead.
+	stream := r.URL.Query().Get("stream")
+	var logLines []model.LogLine
+	switch {
+	case stream == "":
+		logLines, err = s.store.GetLogLines(r.Context(), id)
+	case slices.Contains(model.WorkloadLogStreams, stream):
+		logLines, err = s.store.GetLogLines(r.Context(), id)
+		logLines = slices.DeleteFunc(logLines, func(l model.LogLine) bool { return l.Stream != stream })
+	case stream == model.LogStreamConsole:
+		logLines, err = s.store.GetConsoleLines(r.Context(), id)
+	case stream == model.LogStreamBuild:
+		logLines, err = s.store.GetBuildLogLines(r.Context(), id)
+	default:
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown log stream %q", stream))
+		return
+	}
 	if err != nil {
 		s.logger.Error("get log lines", "error", err)
 		s.writeError(w, http.StatusInternalServerError, "failed to get log lines")
@@ -120,16 +142,29 @@ func (s *Server) handleGetLogHistory(w http.ResponseWriter, r *http.Request) {
 		lines[i] = logHistoryLine{
 			Seq:       l.Seq,
 			Line:      l.Line,
+			Stream:    l.Stream,
 			CreatedAt: l.CreatedAt.Format(time.RFC3339),
 		}
 	}
 
 	s.writeJSON(w, http.StatusOK, logHistoryResponse{
 		WorkloadID: id,
+		Stream:     stream,
 		Lines:      lines,
 	})
 }
 
+// writeSSELine writes a log line as an SSE event named after its stream,
+// or as an unnamed data event if it has none.
+func writeSSELine(w http.ResponseWriter, stream, line string) error {
+	if stream != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", stream); err != nil {
+			return err
+		}
+	}
+	return writeSSEData(w, line)
+}
+
 // writeSSEData writes a log line as an SSE data event. Multi-line strings are
 // split so that each segment gets its own "data:" prefix, per the SSE spec.
 func writeSSEData(w http.ResponseWriter, line string) error {
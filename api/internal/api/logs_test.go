@@ -138,8 +138,8 @@ func TestStreamLogsReceivesEvents(t *testing.T) {
 
 	// Publish some log lines and close the stream.
 	broker := srv.engine.Broker()
-	broker.Publish(wl.ID, "hello world")
-	broker.Publish(wl.ID, "goodbye")
+	broker.Publish(wl.ID, "", "hello world")
+	broker.Publish(wl.ID, "", "goodbye")
 	broker.Close(wl.ID)
 
 	// Parse SSE events from the response body.
@@ -175,7 +175,7 @@ func TestGetLogHistoryHappyPath(t *testing.T) {
 
 	// Insert some log lines.
 	for i := 0; i < 3; i++ {
-		if err := srv.store.InsertLogLine(context.Background(), wl.ID, i, fmt.Sprintf("line %d", i)); err != nil {
+		if err := srv.store.InsertLogLine(context.Background(), wl.ID, i, "", fmt.Sprintf("line %d", i)); err != nil {
 			t.Fatalf("InsertLogLine[%d]: %v", i, err)
 		}
 	}
@@ -258,6 +258,103 @@ func TestGetLogHistoryEmpty(t *testing.T) {
 	}
 }
 
+func TestGetLogHistoryConsole(t *testing.T) {
+	srv := newTestServer(t)
+
+	wl := &model.Workload{
+		ID:        model.NewID(),
+		Status:    model.StatusPending,
+		Isolation: model.IsolationMicroVM,
+		Runtime:   model.RuntimePython,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := srv.store.CreateWorkload(context.Background(), wl); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	if err := srv.store.InsertLogLine(context.Background(), wl.ID, 0, "", "workload output"); err != nil {
+		t.Fatalf("InsertLogLine: %v", err)
+	}
+	if err := srv.store.InsertConsoleLines(context.Background(), wl.ID, []string{"Kernel panic"}); err != nil {
+		t.Fatalf("InsertConsoleLines: %v", err)
+	}
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/workloads/" + wl.ID + "/logs/history?stream=console")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var body logHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Stream != model.LogStreamConsole {
+		t.Errorf("stream = %q, want %q", body.Stream, model.LogStreamConsole)
+	}
+	if len(body.Lines) != 1 || body.Lines[0].Line != "Kernel panic" {
+		t.Errorf("lines = %+v, want the console line only", body.Lines)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/workloads/" + wl.ID + "/logs/history?stream=bogus")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown stream status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestGetLogHistoryBuild(t *testing.T) {
+	srv := newTestServer(t)
+
+	wl := &model.Workload{
+		ID:        model.NewID(),
+		Status:    model.StatusPending,
+		Isolation: model.IsolationMicroVM,
+		Runtime:   model.RuntimeGo,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := srv.store.CreateWorkload(context.Background(), wl); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	if err := srv.store.InsertLogLine(context.Background(), wl.ID, 0, "", "workload output"); err != nil {
+		t.Fatalf("InsertLogLine: %v", err)
+	}
+	if err := srv.store.InsertBuildLogLine(context.Background(), wl.ID, 0, "./main.go:1:1: expected 'package'"); err != nil {
+		t.Fatalf("InsertBuildLogLine: %v", err)
+	}
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/workloads/" + wl.ID + "/logs/history?stream=build")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var body logHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Stream != model.LogStreamBuild {
+		t.Errorf("stream = %q, want %q", body.Stream, model.LogStreamBuild)
+	}
+	if len(body.Lines) != 1 || body.Lines[0].Line != "./main.go:1:1: expected 'package'" {
+		t.Errorf("lines = %+v, want the build line only", body.Lines)
+	}
+}
+
 func TestGetLogHistoryNotFound(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -308,7 +405,7 @@ func TestStreamLogsMultiLineData(t *testing.T) {
 
 	// Publish a multi-line log entry (e.g. a stack trace).
 	broker := srv.engine.Broker()
-	broker.Publish(wl.ID, "error: something failed\n  at main.go:42\n  at handler.go:10")
+	broker.Publish(wl.ID, "", "error: something failed\n  at main.go:42\n  at handler.go:10")
 	broker.Close(wl.ID)
 
 	// Parse SSE events using the shared parser.
@@ -326,3 +423,95 @@ func TestStreamLogsMultiLineData(t *testing.T) {
 		t.Errorf("event[1] = %+v, want done event with data %q", events[1], "stream complete")
 	}
 }
+
+func TestStreamLogsNamesStreams(t *testing.T) {
+	srv := newTestServer(t)
+
+	wl := &model.Workload{
+		ID:        model.NewID(),
+		Status:    model.StatusPending,
+		Isolation: "isolate",
+		Runtime:   "node",
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := srv.store.CreateWorkload(context.Background(), wl); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+"/v1/workloads/"+wl.ID+"/logs", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	broker := srv.engine.Broker()
+	broker.Publish(wl.ID, model.LogStreamStdout, "out")
+	broker.Publish(wl.ID, model.LogStreamStderr, "err")
+	broker.Publish(wl.ID, model.LogStreamSystem, "installing")
+	broker.Close(wl.ID)
+
+	events := parseSSEEvents(bufio.NewScanner(resp.Body))
+	want := []sseEvent{
+		{Type: model.LogStreamStdout, Data: "out"},
+		{Type: model.LogStreamStderr, Data: "err"},
+		{Type: model.LogStreamSystem, Data: "installing"},
+		{Type: "done", Data: "stream complete"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %v", len(events), len(want), events)
+	}
+	for i, ev := range events {
+		if ev != want[i] {
+			t.Errorf("event[%d] = %+v, want %+v", i, ev, want[i])
+		}
+	}
+}
+
+func TestGetLogHistoryStreamFilter(t *testing.T) {
+	srv := newTestServer(t)
+
+	wl := &model.Workload{
+		ID:        model.NewID(),
+		Status:    model.StatusPending,
+		Isolation: model.IsolationMicroVM,
+		Runtime:   model.RuntimePython,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := srv.store.CreateWorkload(context.Background(), wl); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	for seq, stream := range []string{model.LogStreamStdout, model.LogStreamStderr, model.LogStreamStdout} {
+		if err := srv.store.InsertLogLine(context.Background(), wl.ID, seq, stream, fmt.Sprintf("line %d", seq)); err != nil {
+			t.Fatalf("InsertLogLine[%d]: %v", seq, err)
+		}
+	}
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	var all logHistoryResponse
+	if status := doJSON(t, http.MethodGet, ts.URL+"/v1/workloads/"+wl.ID+"/logs/history", "", &all); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if len(all.Lines) != 3 || all.Lines[1].Stream != model.LogStreamStderr {
+		t.Errorf("lines = %+v, want all three interleaved with their streams", all.Lines)
+	}
+
+	var stdout logHistoryResponse
+	if status := doJSON(t, http.MethodGet, ts.URL+"/v1/workloads/"+wl.ID+"/logs/history?stream=stdout", "", &stdout); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if stdout.Stream != model.LogStreamStdout || len(stdout.Lines) != 2 || stdout.Lines[1].Seq != 2 {
+		t.Errorf("stdout history = %+v, want lines 0 and 2", stdout)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// handleProxyWorkload forwards requests under /v1/workloads/{id}/proxy/ to the
+// port a running workload exposes, with the prefix stripped from the path.
+func (s *Server) handleProxyWorkload(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	wl, err := s.store.GetWorkload(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "workload not found")
+			return
+		}
+		s.logger.Error("get workload", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve workload")
+		return
+	}
+	if wl.ExposePort == nil {
+		s.writeError(w, http.StatusNotFound, "workload does not expose a port")
+		return
+	}
+	if wl.Status != model.StatusRunning {
+		s.writeError(w, http.StatusConflict, "workload is not running")
+		return
+	}
+	if wl.EndpointURL == "" {
+		s.writeError(w, http.StatusServiceUnavailable, "workload endpoint is not ready")
+		return
+	}
+
+	target, err := url.Parse(wl.EndpointURL)
+	if err != nil {
+		s.logger.Error("parse workload endpoint", "workload_id", id, "endpoint", wl.EndpointURL, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "invalid workload endpoint")
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = "/" + chi.URLParam(r, "*")
+			pr.Out.URL.RawPath = ""
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Forwarded-Prefix", "/v1/workloads/"+id+"/proxy")
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			s.logger.Warn("proxy to workload", "workload_id", id, "error", err)
+			s.writeError(w, http.StatusBadGateway, "workload endpoint unreachable")
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
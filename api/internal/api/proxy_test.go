@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// createExposedWorkload stores a workload exposing port 8080 and moves it to
+// the given status.
+func createExposedWorkload(t *testing.T, srv *Server, status string) *model.Workload {
+	t.Helper()
+	port := 8080
+	wl := &model.Workload{
+		ID:         model.NewID(),
+		Status:     model.StatusPending,
+		Isolation:  model.IsolationMicroVM,
+		Runtime:    model.RuntimePython,
+		ExposePort: &port,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := srv.store.CreateWorkload(context.Background(), wl); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	for _, next := range []string{model.StatusRunning, model.StatusCompleted} {
+		if wl.Status == status {
+			break
+		}
+		if err := srv.store.UpdateWorkloadStatus(context.Background(), wl.ID, next); err != nil {
+			t.Fatalf("%s→%s: %v", wl.Status, next, err)
+		}
+		wl.Status = next
+	}
+	return wl
+}
+
+func TestProxyWorkload(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Prefix", r.Header.Get("X-Forwarded-Prefix"))
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI())
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t)
+	wl := createExposedWorkload(t, srv, model.StatusRunning)
+	if err := srv.store.SetWorkloadEndpoint(context.Background(), wl.ID, upstream.URL); err != nil {
+		t.Fatalf("SetWorkloadEndpoint: %v", err)
+	}
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/workloads/" + wl.ID + "/proxy/api/items?limit=2")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	if got, want := string(body), "GET /api/items?limit=2"; got != want {
+		t.Errorf("upstream saw %q, want %q", got, want)
+	}
+	if got, want := resp.Header.Get("X-Upstream-Prefix"), "/v1/workloads/"+wl.ID+"/proxy"; got != want {
+		t.Errorf("X-Forwarded-Prefix = %q, want %q", got, want)
+	}
+}
+
+func TestProxyWorkloadErrors(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	plain := &model.Workload{
+		ID:        model.NewID(),
+		Status:    model.StatusPending,
+		Isolation: model.IsolationMicroVM,
+		Runtime:   model.RuntimePython,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := srv.store.CreateWorkload(context.Background(), plain); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	starting := createExposedWorkload(t, srv, model.StatusRunning)
+	finished := createExposedWorkload(t, srv, model.StatusCompleted)
+
+	unreachable := createExposedWorkload(t, srv, model.StatusRunning)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	if err := srv.store.SetWorkloadEndpoint(context.Background(), unreachable.ID, closed.URL); err != nil {
+		t.Fatalf("SetWorkloadEndpoint: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"unknown workload", "nonexistent", http.StatusNotFound},
+		{"no exposed port", plain.ID, http.StatusNotFound},
+		{"endpoint not ready", starting.ID, http.StatusServiceUnavailable},
+		{"not running", finished.ID, http.StatusConflict},
+		{"endpoint unreachable", unreachable.ID, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/v1/workloads/" + tt.id + "/proxy/")
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -16,7 +16,9 @@ import (
 
 	"github.com/seantiz/vulcan/internal/backend"
 	"github.com/seantiz/vulcan/internal/engine"
+	"github.com/seantiz/vulcan/internal/images"
 	"github.com/seantiz/vulcan/internal/store"
+	"github.com/seantiz/vulcan/internal/volumes"
 )
 
 const (
@@ -31,6 +33,8 @@ type Server struct {
 	store    store.Store
 	registry *backend.Registry
 	engine   *engine.Engine
+	images   *images.Catalog  // nil if image registration is unavailable
+	volumes  *volumes.Manager // nil if volumes are unavailable
 	logger   *slog.Logger
 	addr     string
 }
@@ -52,7 +56,7 @@ func NewServer(addr string, s store.Store, reg *backend.Registry, eng *engine.En
 	srv.router.Use(metricsMiddleware)
 	srv.router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-Id"},
 		ExposedHeaders:   []string{"X-Request-Id"},
 		AllowCredentials: false,
@@ -71,6 +75,34 @@ func (s *Server) routes() {
 
 	s.router.Get("/v1/backends", s.handleListBackends)
 	s.router.Get("/v1/stats", s.handleGetStats)
+	s.router.Get("/v1/admin/orphans", s.handleListOrphans)
+
+	s.router.Route("/v1/images", func(r chi.Router) {
+		r.Post("/", s.handleRegisterImage)
+		r.Get("/", s.handleListImages)
+		r.Post("/gc", s.handleImageGC)
+		r.Post("/build", s.handleBuildImage)
+		r.Get("/{name}/{version}", s.handleGetImage)
+		r.Delete("/{name}/{version}", s.handleDeleteImage)
+	})
+
+	s.router.Route("/v1/volumes", func(r chi.Router) {
+		r.Post("/", s.handleCreateVolume)
+		r.Get("/", s.handleListVolumes)
+		r.Get("/{name}", s.handleGetVolume)
+		r.Delete("/{name}", s.handleDeleteVolume)
+		r.Post("/{name}/snapshot", s.handleSnapshotVolume)
+		r.Post("/{name}/clone", s.handleCloneVolume)
+	})
+
+	s.router.Route("/v1/sessions", func(r chi.Router) {
+		r.Post("/", s.handleCreateSession)
+		r.Get("/", s.handleListSessions)
+		r.Get("/{id}", s.handleGetSession)
+		r.Post("/{id}/exec", s.handleExecSession)
+		r.Get("/{id}/execs", s.handleListSessionExecs)
+		r.Delete("/{id}", s.handleDeleteSession)
+	})
 
 	s.router.Route("/v1/workloads", func(r chi.Router) {
 		r.Post("/", s.handleCreateWorkload)
@@ -79,6 +111,11 @@ func (s *Server) routes() {
 		r.Get("/{id}", s.handleGetWorkload)
 		r.Get("/{id}/logs", s.handleStreamLogs)
 		r.Get("/{id}/logs/history", s.handleGetLogHistory)
+		r.Post("/{id}/signal", s.handleSignalWorkload)
+		r.Post("/{id}/stop", s.handleStopService)
+		r.Get("/{id}/health", s.handleListHealthEvents)
+		r.Patch("/{id}", s.handleUpdateService)
+		r.HandleFunc("/{id}/proxy/*", s.handleProxyWorkload)
 		r.Delete("/{id}", s.handleDeleteWorkload)
 	})
 }
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/engine"
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// updateServiceRequest is the JSON body for PATCH /v1/workloads/{id}.
+type updateServiceRequest struct {
+	Code        string             `json:"code"`
+	CodeArchive string             `json:"code_archive"`
+	Service     *model.ServiceSpec `json:"service"`
+}
+
+// parseService validates the request's workload kind and service spec and
+// sets them on the workload. Services run until stopped, so they take no
+// timeout. Returns an error if validation fails (error already written to w).
+func (s *Server) parseService(req *createWorkloadRequest, wl *model.Workload, w http.ResponseWriter) error {
+	switch req.Kind {
+	case "", model.KindJob:
+		if req.Service != nil {
+			s.writeError(w, http.StatusBadRequest, "service requires kind service")
+			return errValidation
+		}
+		wl.Kind = model.KindJob
+		return nil
+	case model.KindService:
+	default:
+		s.writeError(w, http.StatusBadRequest, "invalid kind: must be job or service")
+		return errValidation
+	}
+
+	if req.Resources != nil && req.Resources.TimeoutS != nil {
+		s.writeError(w, http.StatusBadRequest, "timeout_s is not valid for services")
+		return errValidation
+	}
+	svc := model.ServiceSpec{}
+	if req.Service != nil {
+		svc = *req.Service
+	}
+	if err := svc.Validate(); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return errValidation
+	}
+	svc = svc.WithDefaults()
+	wl.Kind = model.KindService
+	wl.Service = &svc
+	return nil
+}
+
+func (s *Server) handleStopService(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if _, err := s.store.GetWorkload(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "workload not found")
+			return
+		}
+		s.logger.Error("get workload", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve workload")
+		return
+	}
+
+	if err := s.engine.Stop(id); err != nil {
+		s.writeServiceError(w, id, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusAccepted, map[string]string{"id": id, "status": "stopping"})
+}
+
+func (s *Server) handleUpdateService(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req updateServiceRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	// The code fields are validated as they are on creation.
+	var code model.Workload
+	codeReq := createWorkloadRequest{Code: req.Code, CodeArchive: req.CodeArchive}
+	if err := s.parseCodeFields(&codeReq, &code, w); err != nil {
+		return // error already written
+	}
+	u := engine.ServiceUpdate{Code: code.Code, CodeArchive: code.CodeArchive}
+	if req.Service != nil {
+		if err := req.Service.Validate(); err != nil {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		svc := req.Service.WithDefaults()
+		u.Service = &svc
+	}
+	if u.Code == "" && u.CodeArchive == nil && u.Service == nil {
+		s.writeError(w, http.StatusBadRequest, "code, code_archive or service is required")
+		return
+	}
+
+	if _, err := s.store.GetWorkload(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "workload not found")
+			return
+		}
+		s.logger.Error("get workload", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve workload")
+		return
+	}
+
+	if err := s.engine.Update(r.Context(), id, u); err != nil {
+		s.writeServiceError(w, id, err)
+		return
+	}
+
+	wl, err := s.store.GetWorkload(r.Context(), id)
+	if err != nil {
+		s.logger.Error("get updated workload", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve workload")
+		return
+	}
+
+	s.writeJSON(w, http.StatusAccepted, wl)
+}
+
+func (s *Server) handleListHealthEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if _, err := s.store.GetWorkload(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "workload not found")
+			return
+		}
+		s.logger.Error("get workload", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve workload")
+		return
+	}
+
+	events, err := s.store.ListHealthEvents(r.Context(), id)
+	if err != nil {
+		s.logger.Error("list health events", "workload_id", id, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to list health events")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{"events": events})
+}
+
+// writeServiceError writes the response for an error from stopping or
+// updating a service.
+func (s *Server) writeServiceError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, backend.ErrNotRunning):
+		s.writeError(w, http.StatusConflict, "workload is not running")
+	case errors.Is(err, engine.ErrNotService):
+		s.writeError(w, http.StatusConflict, "workload is not a service")
+	default:
+		s.logger.Error("manage service", "workload_id", id, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to manage service")
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// serviceStubBackend runs workloads until they are cancelled, reporting
+// services healthy once started.
+type serviceStubBackend struct {
+	stubBackend
+	started chan string
+}
+
+func (sb *serviceStubBackend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	if spec.OnHealth != nil {
+		spec.OnHealth(backend.HealthReport{Status: model.HealthHealthy})
+	}
+	sb.started <- spec.Code
+	<-ctx.Done()
+	return backend.WorkloadResult{ExitCode: 0}, nil
+}
+
+func (sb *serviceStubBackend) Capabilities() backend.BackendCapabilities {
+	caps := sb.stubBackend.Capabilities()
+	caps.Services = true
+	return caps
+}
+
+// doJSON sends body to url with method and decodes the response into v if
+// it is not nil, returning the status code.
+func doJSON(t *testing.T, method, url, body string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestCreateServiceValidation(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"unknown kind", `{"runtime":"python","kind":"daemon"}`, "invalid kind"},
+		{"service on job", `{"runtime":"python","service":{}}`, "service requires kind service"},
+		{"timeout", `{"runtime":"python","kind":"service","resources":{"timeout_s":10}}`, "timeout_s"},
+		{"bad policy", `{"runtime":"python","kind":"service","service":{"restart":{"policy":"sometimes"}}}`, "invalid restart policy"},
+		{"bad check", `{"runtime":"python","kind":"service","service":{"health_check":{"type":"http"}}}`, "port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]string
+			status := doJSON(t, http.MethodPost, ts.URL+"/v1/workloads", tt.body, &body)
+			if status != http.StatusBadRequest || !strings.Contains(body["error"], tt.want) {
+				t.Errorf("status %d error %q, want 400 mentioning %q", status, body["error"], tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateServiceAppliesDefaults(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	var wl model.Workload
+	status := doJSON(t, http.MethodPost, ts.URL+"/v1/workloads", `{"runtime":"python","kind":"service"}`, &wl)
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", status)
+	}
+	if wl.Kind != model.KindService || wl.Service == nil || wl.Service.Restart.Policy != model.RestartAlways {
+		t.Errorf("kind %q service %+v, want a service restarted always", wl.Kind, wl.Service)
+	}
+
+	var job model.Workload
+	status = doJSON(t, http.MethodPost, ts.URL+"/v1/workloads", `{"runtime":"python"}`, &job)
+	if status != http.StatusCreated || job.Kind != model.KindJob || job.Service != nil {
+		t.Errorf("status %d kind %q service %+v, want a job", status, job.Kind, job.Service)
+	}
+}
+
+func TestServiceLifecycle(t *testing.T) {
+	srv := newTestServer(t)
+	b := &serviceStubBackend{started: make(chan string, 1)}
+	srv.registry.Register(model.IsolationIsolate, b)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	var wl model.Workload
+	status := doJSON(t, http.MethodPost, ts.URL+"/v1/workloads/async",
+		`{"runtime":"node","isolation":"isolate","kind":"service","code":"v1"}`, &wl)
+	if status != http.StatusAccepted {
+		t.Fatalf("submit status = %d, want 202", status)
+	}
+	<-b.started
+
+	var updated model.Workload
+	status = doJSON(t, http.MethodPatch, ts.URL+"/v1/workloads/"+wl.ID,
+		`{"code":"v2","service":{"restart":{"policy":"on-failure","max_restarts":5}}}`, &updated)
+	if status != http.StatusAccepted {
+		t.Fatalf("update status = %d, want 202", status)
+	}
+	if updated.Service == nil || updated.Service.Restart.MaxRestarts != 5 {
+		t.Errorf("updated service = %+v, want the new restart policy", updated.Service)
+	}
+	if code := <-b.started; code != "v2" {
+		t.Errorf("redeployed code = %q, want v2", code)
+	}
+
+	var health struct {
+		Events []model.HealthEvent `json:"events"`
+	}
+	status = doJSON(t, http.MethodGet, ts.URL+"/v1/workloads/"+wl.ID+"/health", "", &health)
+	if status != http.StatusOK || len(health.Events) != 3 {
+		t.Errorf("health status %d events %+v, want healthy, starting, healthy", status, health.Events)
+	}
+
+	if status := doJSON(t, http.MethodPost, ts.URL+"/v1/workloads/"+wl.ID+"/stop", "", nil); status != http.StatusAccepted {
+		t.Fatalf("stop status = %d, want 202", status)
+	}
+	srv.engine.Wait()
+
+	var got model.Workload
+	doJSON(t, http.MethodGet, ts.URL+"/v1/workloads/"+wl.ID, "", &got)
+	if got.Status != model.StatusCompleted {
+		t.Errorf("status after stop = %q, want completed", got.Status)
+	}
+	if status := doJSON(t, http.MethodPost, ts.URL+"/v1/workloads/"+wl.ID+"/stop", "", nil); status != http.StatusConflict {
+		t.Errorf("second stop status = %d, want 409", status)
+	}
+}
+
+func TestStopAndUpdateRejectJobs(t *testing.T) {
+	srv := newTestServer(t)
+	b := &serviceStubBackend{started: make(chan string, 1)}
+	srv.registry.Register(model.IsolationIsolate, b)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	var wl model.Workload
+	doJSON(t, http.MethodPost, ts.URL+"/v1/workloads/async", `{"runtime":"node","isolation":"isolate"}`, &wl)
+	// Jobs get no health reporter; wait until the job is executing.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var body map[string]string
+		status := doJSON(t, http.MethodPost, ts.URL+"/v1/workloads/"+wl.ID+"/stop", "", &body)
+		if status == http.StatusConflict && body["error"] == "workload is not a service" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var body map[string]string
+	status := doJSON(t, http.MethodPatch, ts.URL+"/v1/workloads/"+wl.ID, `{"code":"x"}`, &body)
+	if status != http.StatusConflict || body["error"] != "workload is not a service" {
+		t.Errorf("update status %d error %q, want 409 not a service", status, body["error"])
+	}
+	status = doJSON(t, http.MethodPatch, ts.URL+"/v1/workloads/"+wl.ID, `{}`, &body)
+	if status != http.StatusBadRequest {
+		t.Errorf("empty update status = %d, want 400", status)
+	}
+	status = doJSON(t, http.MethodGet, ts.URL+"/v1/workloads/missing/health", "", &body)
+	if status != http.StatusNotFound {
+		t.Errorf("health of missing workload status = %d, want 404", status)
+	}
+
+	srv.engine.Cancel(wl.ID)
+	srv.engine.Wait()
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/seantiz/vulcan/internal/engine"
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// createSessionRequest is the JSON body for POST /v1/sessions.
+type createSessionRequest struct {
+	Runtime     string               `json:"runtime"`
+	Isolation   string               `json:"isolation"`
+	Resources   *resourcesReq        `json:"resources"`
+	Network     *model.NetworkPolicy `json:"network"`
+	Interpreter bool                 `json:"interpreter"`
+
+	IdleTimeoutS int `json:"idle_timeout_s"`
+	MaxLifetimeS int `json:"max_lifetime_s"`
+}
+
+// execSessionRequest is the JSON body for POST /v1/sessions/{id}/exec.
+type execSessionRequest struct {
+	Code        string `json:"code"`
+	CodeArchive string `json:"code_archive"`
+	TimeoutS    *int   `json:"timeout_s"`
+	GraceS      *int   `json:"grace_s"`
+}
+
+// listSessionsResponse wraps the session list response.
+type listSessionsResponse struct {
+	Sessions []*model.Session `json:"sessions"`
+}
+
+// listSessionExecsResponse wraps the list of a session's executions.
+type listSessionExecsResponse struct {
+	Workloads []*model.Workload `json:"workloads"`
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req createSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if req.Runtime == "" {
+		s.writeError(w, http.StatusBadRequest, "runtime is required")
+		return
+	}
+	if invalidImageRef(req.Runtime) {
+		s.writeError(w, http.StatusBadRequest, "invalid image reference; use <name>@<version>")
+		return
+	}
+	if req.Interpreter && !slices.Contains(model.InterpreterRuntimes, req.Runtime) {
+		s.writeError(w, http.StatusBadRequest, "interpreter is only supported for runtimes "+strings.Join(model.InterpreterRuntimes, ", "))
+		return
+	}
+	if req.Network != nil {
+		if err := req.Network.Validate(); err != nil {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	sess := model.Session{
+		ID:           model.NewID(),
+		Status:       model.SessionStarting,
+		Isolation:    req.Isolation,
+		Runtime:      req.Runtime,
+		Interpreter:  req.Interpreter,
+		Network:      req.Network,
+		IdleTimeoutS: req.IdleTimeoutS,
+		MaxLifetimeS: req.MaxLifetimeS,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := sess.ValidateLifetimes(); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sess = sess.WithDefaults()
+	if res := req.Resources; res != nil {
+		if res.DiskMB != nil || res.TimeoutS != nil || res.GraceS != nil || !res.RateLimits.IsZero() {
+			s.writeError(w, http.StatusBadRequest, "sessions only take cpus and mem_mb resources; set timeout_s and grace_s per execution")
+			return
+		}
+		sess.CPULimit = res.CPUs
+		sess.MemLimit = res.MemMB
+	}
+
+	if err := s.engine.StartSession(r.Context(), &sess); err != nil {
+		s.logger.Error("create session", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to create session")
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, sess)
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	list, err := s.store.ListSessions(r.Context())
+	if err != nil {
+		s.logger.Error("list sessions", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, listSessionsResponse{Sessions: list})
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.getSession(w, r)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, http.StatusOK, sess)
+}
+
+// getSession looks up the session in the URL, writing the error response
+// if it cannot.
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) (*model.Session, bool) {
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, store.ErrSessionNotFound) {
+			s.writeError(w, http.StatusNotFound, "session not found")
+			return nil, false
+		}
+		s.logger.Error("get session", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve session")
+		return nil, false
+	}
+	return sess, true
+}
+
+// handleExecSession runs code in a session and responds with the finished
+// execution once it is done.
+func (s *Server) handleExecSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.getSession(w, r)
+	if !ok {
+		return
+	}
+	if sess.IsFinished() {
+		s.writeError(w, http.StatusConflict, "session is closed")
+		return
+	}
+
+	var req execSessionRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if req.TimeoutS != nil && *req.TimeoutS <= 0 {
+		s.writeError(w, http.StatusBadRequest, "timeout_s must be positive")
+		return
+	}
+	if req.GraceS != nil {
+		if err := model.ValidateGraceS(*req.GraceS); err != nil {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	wl := &model.Workload{
+		ID:        model.NewID(),
+		TimeoutS:  req.TimeoutS,
+		GraceS:    req.GraceS,
+		CreatedAt: time.Now().UTC(),
+	}
+	code := createWorkloadRequest{Code: req.Code, CodeArchive: req.CodeArchive}
+	if err := s.parseCodeFields(&code, wl, w); err != nil {
+		return // error already written
+	}
+
+	// Executions can outlast the write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Warn("failed to clear write deadline for session exec", "error", err)
+	}
+
+	if err := s.engine.Exec(r.Context(), sess.ID, wl); err != nil {
+		if errors.Is(err, engine.ErrSessionClosed) {
+			s.writeError(w, http.StatusConflict, "session is closed")
+			return
+		}
+		s.logger.Error("exec session", "session_id", sess.ID, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to run execution")
+		return
+	}
+
+	done, err := s.store.GetWorkload(r.Context(), wl.ID)
+	if err != nil {
+		s.logger.Error("get session exec", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve workload")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, done)
+}
+
+func (s *Server) handleListSessionExecs(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.getSession(w, r)
+	if !ok {
+		return
+	}
+	execs, err := s.store.ListSessionExecs(r.Context(), sess.ID)
+	if err != nil {
+		s.logger.Error("list session execs", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to list workloads")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, listSessionExecsResponse{Workloads: execs})
+}
+
+// handleDeleteSession closes a session. The sandbox stops in the
+// background; the session is closed once it has.
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.getSession(w, r)
+	if !ok {
+		return
+	}
+	if err := s.engine.CloseSession(sess.ID); err != nil {
+		s.writeError(w, http.StatusConflict, "session is closed")
+		return
+	}
+	s.writeJSON(w, http.StatusAccepted, map[string]string{"id": sess.ID, "status": "closing"})
+}
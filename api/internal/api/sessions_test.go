@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// sessionStubBackend runs sessions whose executions answer with their code.
+type sessionStubBackend struct {
+	stubBackend
+}
+
+func (sb *sessionStubBackend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	spec.Session.OnReady()
+	for {
+		select {
+		case <-ctx.Done():
+			return backend.WorkloadResult{}, nil
+		case x := <-spec.Session.Execs:
+			x.Result <- backend.ExecResult{Result: backend.WorkloadResult{Output: []byte(x.Code)}}
+		}
+	}
+}
+
+func (sb *sessionStubBackend) Capabilities() backend.BackendCapabilities {
+	caps := sb.stubBackend.Capabilities()
+	caps.Sessions = true
+	return caps
+}
+
+func TestCreateSessionValidation(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no runtime", `{}`, "runtime is required"},
+		{"interpreter", `{"runtime":"go","interpreter":true}`, "interpreter is only supported"},
+		{"idle over lifetime", `{"runtime":"python","idle_timeout_s":600,"max_lifetime_s":60}`, "idle_timeout_s must not exceed"},
+		{"lifetime cap", `{"runtime":"python","max_lifetime_s":100000}`, "at most"},
+		{"timeout", `{"runtime":"python","resources":{"timeout_s":10}}`, "per execution"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]string
+			status := doJSON(t, http.MethodPost, ts.URL+"/v1/sessions", tt.body, &body)
+			if status != http.StatusBadRequest || !strings.Contains(body["error"], tt.want) {
+				t.Errorf("status %d error %q, want 400 mentioning %q", status, body["error"], tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	srv := newTestServer(t)
+	srv.registry.Register(model.IsolationIsolate, &sessionStubBackend{})
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	var sess model.Session
+	status := doJSON(t, http.MethodPost, ts.URL+"/v1/sessions", `{"runtime":"node","isolation":"isolate","idle_timeout_s":60}`, &sess)
+	if status != http.StatusCreated {
+		t.Fatalf("create status = %d, want 201", status)
+	}
+	if sess.IdleTimeoutS != 60 || sess.MaxLifetimeS != model.DefaultSessionMaxLifetimeS {
+		t.Errorf("lifetimes idle %d max %d, want 60 and the default", sess.IdleTimeoutS, sess.MaxLifetimeS)
+	}
+
+	var wl model.Workload
+	status = doJSON(t, http.MethodPost, ts.URL+"/v1/sessions/"+sess.ID+"/exec", `{"code":"1 + 1"}`, &wl)
+	if status != http.StatusOK {
+		t.Fatalf("exec status = %d, want 200", status)
+	}
+	if wl.Status != model.StatusCompleted || string(wl.Output) != "1 + 1" || wl.SessionID != sess.ID {
+		t.Errorf("exec = %q %q session %q, want completed in the session", wl.Status, wl.Output, wl.SessionID)
+	}
+
+	var execs listSessionExecsResponse
+	if status := doJSON(t, http.MethodGet, ts.URL+"/v1/sessions/"+sess.ID+"/execs", "", &execs); status != http.StatusOK || len(execs.Workloads) != 1 {
+		t.Errorf("execs status %d len %d, want the one execution", status, len(execs.Workloads))
+	}
+
+	if status := doJSON(t, http.MethodDelete, ts.URL+"/v1/sessions/"+sess.ID, "", nil); status != http.StatusAccepted {
+		t.Fatalf("delete status = %d, want 202", status)
+	}
+	srv.engine.Wait()
+
+	var got model.Session
+	doJSON(t, http.MethodGet, ts.URL+"/v1/sessions/"+sess.ID, "", &got)
+	if got.Status != model.SessionClosed || got.CloseReason != model.SessionClosedByClient || got.Execs != 1 {
+		t.Errorf("session = %q %q execs %d, want closed by the client after one execution", got.Status, got.CloseReason, got.Execs)
+	}
+	if status := doJSON(t, http.MethodPost, ts.URL+"/v1/sessions/"+sess.ID+"/exec", `{"code":"x"}`, nil); status != http.StatusConflict {
+		t.Errorf("exec after close status = %d, want 409", status)
+	}
+	if status := doJSON(t, http.MethodDelete, ts.URL+"/v1/sessions/"+sess.ID, "", nil); status != http.StatusConflict {
+		t.Errorf("second delete status = %d, want 409", status)
+	}
+}
+
+func TestSessionNotFound(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/sessions/missing"},
+		{http.MethodPost, "/v1/sessions/missing/exec"},
+		{http.MethodGet, "/v1/sessions/missing/execs"},
+		{http.MethodDelete, "/v1/sessions/missing"},
+	} {
+		if status := doJSON(t, tc.method, ts.URL+tc.path, `{}`, nil); status != http.StatusNotFound {
+			t.Errorf("%s %s status = %d, want 404", tc.method, tc.path, status)
+		}
+	}
+
+	var list listSessionsResponse
+	if status := doJSON(t, http.MethodGet, ts.URL+"/v1/sessions", "", &list); status != http.StatusOK || list.Sessions == nil {
+		t.Errorf("list status %d sessions %v, want an empty list", status, list.Sessions)
+	}
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"net/http"
+
+	"github.com/seantiz/vulcan/internal/store"
 )
 
 // statsResponse is the JSON response for GET /v1/stats.
@@ -10,6 +12,9 @@ type statsResponse struct {
 	ByStatus      map[string]int `json:"by_status"`
 	ByIsolation   map[string]int `json:"by_isolation"`
 	AvgDurationMS float64        `json:"avg_duration_ms"`
+
+	Usage          store.UsageStats            `json:"usage"`
+	UsageByRuntime map[string]store.UsageStats `json:"usage_by_runtime"`
 }
 
 func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
@@ -21,9 +26,11 @@ func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s.writeJSON(w, http.StatusOK, statsResponse{
-		Total:         stats.Total,
-		ByStatus:      stats.CountByStatus,
-		ByIsolation:   stats.CountByIsolation,
-		AvgDurationMS: stats.AvgDurationMS,
+		Total:          stats.Total,
+		ByStatus:       stats.CountByStatus,
+		ByIsolation:    stats.CountByIsolation,
+		AvgDurationMS:  stats.AvgDurationMS,
+		Usage:          stats.Usage,
+		UsageByRuntime: stats.UsageByRuntime,
 	})
 }
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+	"github.com/seantiz/vulcan/internal/volumes"
+)
+
+// createVolumeRequest is the JSON body for POST /v1/volumes.
+type createVolumeRequest struct {
+	Name   string `json:"name"`
+	SizeMB int    `json:"size_mb"`
+}
+
+// copyVolumeRequest is the JSON body for POST /v1/volumes/{name}/snapshot
+// and POST /v1/volumes/{name}/clone.
+type copyVolumeRequest struct {
+	Name string `json:"name"`
+}
+
+// listVolumesResponse wraps the volume list response.
+type listVolumesResponse struct {
+	Volumes []*model.Volume `json:"volumes"`
+}
+
+// SetVolumeManager enables creating, copying and deleting volumes through m.
+// Without a manager those endpoints return 503, while volumes already in the
+// store can still be listed.
+func (s *Server) SetVolumeManager(m *volumes.Manager) {
+	s.volumes = m
+}
+
+func (s *Server) handleListVolumes(w http.ResponseWriter, r *http.Request) {
+	list, err := s.store.ListVolumes(r.Context())
+	if err != nil {
+		s.logger.Error("list volumes", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to list volumes")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, listVolumesResponse{Volumes: list})
+}
+
+func (s *Server) handleGetVolume(w http.ResponseWriter, r *http.Request) {
+	v, err := s.store.GetVolume(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		if errors.Is(err, store.ErrVolumeNotFound) {
+			s.writeError(w, http.StatusNotFound, "volume not found")
+			return
+		}
+		s.logger.Error("get volume", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve volume")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) handleCreateVolume(w http.ResponseWriter, r *http.Request) {
+	if s.volumes == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "volumes are not configured")
+		return
+	}
+
+	var req createVolumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	v, err := s.volumes.Create(r.Context(), req.Name, req.SizeMB)
+	s.writeVolumeResult(w, "create volume", v, err)
+}
+
+// handleSnapshotVolume takes a read-only snapshot of a volume.
+func (s *Server) handleSnapshotVolume(w http.ResponseWriter, r *http.Request) {
+	s.copyVolume(w, r, (*volumes.Manager).Snapshot, "snapshot volume")
+}
+
+// handleCloneVolume copies a volume or snapshot into a new writable volume.
+func (s *Server) handleCloneVolume(w http.ResponseWriter, r *http.Request) {
+	s.copyVolume(w, r, (*volumes.Manager).Clone, "clone volume")
+}
+
+// copyVolume decodes a copy request for the volume in the URL and performs
+// it with op.
+func (s *Server) copyVolume(w http.ResponseWriter, r *http.Request, op func(m *volumes.Manager, ctx context.Context, source, name string) (*model.Volume, error), action string) {
+	if s.volumes == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "volumes are not configured")
+		return
+	}
+
+	var req copyVolumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	// Copying large volumes can outlast the write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Warn("failed to clear write deadline for volume copy", "error", err)
+	}
+
+	v, err := op(s.volumes, r.Context(), chi.URLParam(r, "name"), req.Name)
+	s.writeVolumeResult(w, action, v, err)
+}
+
+// writeVolumeResult writes the volume created by action, or its error.
+func (s *Server) writeVolumeResult(w http.ResponseWriter, action string, v *model.Volume, err error) {
+	switch {
+	case errors.Is(err, volumes.ErrInvalidVolume):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, store.ErrVolumeNotFound):
+		s.writeError(w, http.StatusNotFound, "volume not found")
+	case errors.Is(err, store.ErrVolumeExists):
+		s.writeError(w, http.StatusConflict, "volume already exists")
+	case errors.Is(err, store.ErrVolumeLocked):
+		s.writeError(w, http.StatusConflict, "volume is attached read-write")
+	case err != nil:
+		s.logger.Error(action, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to "+action)
+	default:
+		s.writeJSON(w, http.StatusCreated, v)
+	}
+}
+
+func (s *Server) handleDeleteVolume(w http.ResponseWriter, r *http.Request) {
+	if s.volumes == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "volumes are not configured")
+		return
+	}
+
+	if err := s.volumes.Delete(r.Context(), chi.URLParam(r, "name")); err != nil {
+		switch {
+		case errors.Is(err, store.ErrVolumeNotFound):
+			s.writeError(w, http.StatusNotFound, "volume not found")
+		case errors.Is(err, store.ErrVolumeInUse):
+			s.writeError(w, http.StatusConflict, "volume is attached")
+		default:
+			s.logger.Error("delete volume", "error", err)
+			s.writeError(w, http.StatusInternalServerError, "failed to delete volume")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/volumes"
+)
+
+// newVolumeTestServer returns a test server with a volume manager whose
+// formatter leaves volume files blank, and the server itself.
+func newVolumeTestServer(t *testing.T) (*httptest.Server, *Server) {
+	t.Helper()
+	srv := newTestServer(t)
+	format := func(context.Context, string) error { return nil }
+	cfg := volumes.Config{Dir: t.TempDir(), MaxSizeMB: 64}
+	m, err := volumes.NewManager(context.Background(), cfg, srv.store, format, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	srv.SetVolumeManager(m)
+
+	ts := httptest.NewServer(srv.Router())
+	t.Cleanup(ts.Close)
+	return ts, srv
+}
+
+// postVolume posts body to path and decodes a volume from a 201 response.
+func postVolume(t *testing.T, url, body string) (*model.Volume, int) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, resp.StatusCode
+	}
+	var v model.Volume
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return &v, resp.StatusCode
+}
+
+func TestCreateAndGetVolume(t *testing.T) {
+	ts, _ := newVolumeTestServer(t)
+
+	v, status := postVolume(t, ts.URL+"/v1/volumes", `{"name":"data","size_mb":16}`)
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", status)
+	}
+	if v.Name != "data" || v.SizeMB != 16 || v.Snapshot {
+		t.Errorf("volume = %+v", v)
+	}
+	if info, err := os.Stat(v.Path); err != nil || info.Size() != 16<<20 {
+		t.Errorf("volume file = %v, %v, want 16 MiB", info, err)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/volumes/data")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var got model.Volume
+	json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || got.Name != "data" || got.Attachments == nil {
+		t.Errorf("GET = %d %+v", resp.StatusCode, got)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/volumes/missing")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET missing status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestCreateVolumeErrors(t *testing.T) {
+	ts, _ := newVolumeTestServer(t)
+	if _, status := postVolume(t, ts.URL+"/v1/volumes", `{"name":"data","size_mb":16}`); status != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", status)
+	}
+
+	for body, want := range map[string]int{
+		`{"name":"data","size_mb":16}`:  http.StatusConflict,
+		`{"name":"Data","size_mb":16}`:  http.StatusBadRequest,
+		`{"name":"big","size_mb":4096}`: http.StatusBadRequest,
+		`not json`:                      http.StatusBadRequest,
+	} {
+		if _, status := postVolume(t, ts.URL+"/v1/volumes", body); status != want {
+			t.Errorf("POST %s: status = %d, want %d", body, status, want)
+		}
+	}
+}
+
+func TestSnapshotCloneAndDeleteVolume(t *testing.T) {
+	ts, srv := newVolumeTestServer(t)
+	if _, status := postVolume(t, ts.URL+"/v1/volumes", `{"name":"data","size_mb":16}`); status != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", status)
+	}
+
+	snap, status := postVolume(t, ts.URL+"/v1/volumes/data/snapshot", `{"name":"data-1"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("snapshot status = %d, want 201", status)
+	}
+	if !snap.Snapshot || snap.Source != "data" {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	clone, status := postVolume(t, ts.URL+"/v1/volumes/data-1/clone", `{"name":"data-2"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("clone status = %d, want 201", status)
+	}
+	if clone.Snapshot || clone.Source != "data-1" {
+		t.Errorf("clone = %+v", clone)
+	}
+	if _, status := postVolume(t, ts.URL+"/v1/volumes/missing/clone", `{"name":"x"}`); status != http.StatusNotFound {
+		t.Errorf("clone of a missing volume status = %d, want 404", status)
+	}
+
+	// A volume attached read-write can be neither copied nor deleted.
+	if _, err := srv.store.AttachVolumes(context.Background(), "wl-1", []model.VolumeMount{{Name: "data", MountPath: "/data"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, status := postVolume(t, ts.URL+"/v1/volumes/data/snapshot", `{"name":"data-3"}`); status != http.StatusConflict {
+		t.Errorf("snapshot of an attached volume status = %d, want 409", status)
+	}
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/volumes/data", nil)
+	del, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	del.Body.Close()
+	if del.StatusCode != http.StatusConflict {
+		t.Errorf("DELETE of an attached volume status = %d, want 409", del.StatusCode)
+	}
+
+	srv.store.DetachVolumes(context.Background(), "wl-1")
+	del, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	del.Body.Close()
+	if del.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", del.StatusCode)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/volumes")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var list listVolumesResponse
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Volumes) != 2 || list.Volumes[0].Name != "data-1" || list.Volumes[1].Name != "data-2" {
+		t.Errorf("volumes = %+v, want data-1 and data-2", list.Volumes)
+	}
+}
+
+func TestVolumesNotConfigured(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	if _, status := postVolume(t, ts.URL+"/v1/volumes", `{"name":"data"}`); status != http.StatusServiceUnavailable {
+		t.Errorf("create status = %d, want 503", status)
+	}
+	if _, status := postVolume(t, ts.URL+"/v1/volumes/data/snapshot", `{"name":"snap"}`); status != http.StatusServiceUnavailable {
+		t.Errorf("snapshot status = %d, want 503", status)
+	}
+
+	list, err := http.Get(ts.URL + "/v1/volumes")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	list.Body.Close()
+	if list.StatusCode != http.StatusOK {
+		t.Errorf("list status = %d, want 200", list.StatusCode)
+	}
+}
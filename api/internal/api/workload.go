@@ -3,12 +3,15 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/seantiz/vulcan/internal/backend"
 	"github.com/seantiz/vulcan/internal/model"
 	"github.com/seantiz/vulcan/internal/store"
 )
@@ -25,18 +28,32 @@ const (
 
 // createWorkloadRequest is the JSON body for POST /v1/workloads.
 type createWorkloadRequest struct {
+	Kind        string          `json:"kind"`
 	Runtime     string          `json:"runtime"`
 	Isolation   string          `json:"isolation"`
 	Code        string          `json:"code"`
 	CodeArchive string          `json:"code_archive"`
 	Input       json.RawMessage `json:"input"`
 	Resources   *resourcesReq   `json:"resources"`
+
+	Network    *model.NetworkPolicy `json:"network"`
+	ExposePort *int                 `json:"expose_port"`
+	Group      string               `json:"group"`
+	Name       string               `json:"name"`
+
+	Volumes []model.VolumeMount `json:"volumes"`
+
+	Service *model.ServiceSpec `json:"service"`
 }
 
 type resourcesReq struct {
 	CPUs     *int `json:"cpus"`
 	MemMB    *int `json:"mem_mb"`
+	DiskMB   *int `json:"disk_mb"`
 	TimeoutS *int `json:"timeout_s"`
+	GraceS   *int `json:"grace_s"`
+
+	model.RateLimits
 }
 
 // listWorkloadsResponse wraps the paginated list response.
@@ -59,6 +76,10 @@ func (s *Server) handleCreateWorkload(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadRequest, "runtime is required")
 		return
 	}
+	if invalidImageRef(req.Runtime) {
+		s.writeError(w, http.StatusBadRequest, "invalid image reference; use <name>@<version>")
+		return
+	}
 
 	now := time.Now().UTC()
 	wl := &model.Workload{
@@ -80,6 +101,24 @@ func (s *Server) handleCreateWorkload(w http.ResponseWriter, r *http.Request) {
 	if err := s.parseCodeFields(&req, wl, w); err != nil {
 		return // error already written
 	}
+	if err := s.parseNetwork(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseRateLimits(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseDisk(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseGrace(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseVolumes(&req, wl, w); err != nil {
+		return // error already written
+	}
+	if err := s.parseService(&req, wl, w); err != nil {
+		return // error already written
+	}
 
 	if err := s.store.CreateWorkload(r.Context(), wl); err != nil {
 		s.logger.Error("create workload", "error", err)
@@ -118,7 +157,14 @@ func (s *Server) handleListWorkloads(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	workloads, total, err := s.store.ListWorkloads(r.Context(), limit, offset)
+	// ?exit_reason= keeps the workloads that ended for one reason.
+	filter := store.WorkloadFilter{ExitReason: r.URL.Query().Get("exit_reason")}
+	if filter.ExitReason != "" && !model.ValidExitReason(filter.ExitReason) {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown exit reason %q", filter.ExitReason))
+		return
+	}
+
+	workloads, total, err := s.store.ListWorkloads(r.Context(), filter, limit, offset)
 	if err != nil {
 		s.logger.Error("list workloads", "error", err)
 		s.writeError(w, http.StatusInternalServerError, "failed to list workloads")
@@ -154,6 +200,9 @@ func (s *Server) handleDeleteWorkload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Stop the execution if it is in flight; a pending workload never starts.
+	s.engine.Cancel(id)
+
 	wl, err := s.store.GetWorkload(r.Context(), id)
 	if err != nil {
 		s.logger.Error("get killed workload", "error", err)
@@ -164,6 +213,50 @@ func (s *Server) handleDeleteWorkload(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, wl)
 }
 
+// signalWorkloadRequest is the JSON body for POST /v1/workloads/{id}/signal.
+type signalWorkloadRequest struct {
+	Signal string `json:"signal"`
+}
+
+func (s *Server) handleSignalWorkload(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req signalWorkloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if !model.ValidSignal(req.Signal) {
+		s.writeError(w, http.StatusBadRequest, "invalid signal: must be one of "+strings.Join(model.Signals, ", "))
+		return
+	}
+
+	if _, err := s.store.GetWorkload(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "workload not found")
+			return
+		}
+		s.logger.Error("get workload", "error", err)
+		s.writeError(w, http.StatusInternalServerError, "failed to retrieve workload")
+		return
+	}
+
+	if err := s.engine.Signal(r.Context(), id, req.Signal); err != nil {
+		switch {
+		case errors.Is(err, backend.ErrNotRunning):
+			s.writeError(w, http.StatusConflict, "workload is not running")
+		case errors.Is(err, backend.ErrUnsupported):
+			s.writeError(w, http.StatusNotImplemented, "workload sandbox does not support signals")
+		default:
+			s.logger.Error("signal workload", "workload_id", id, "error", err)
+			s.writeError(w, http.StatusInternalServerError, "failed to signal workload")
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusAccepted, map[string]string{"id": id, "signal": req.Signal})
+}
+
 // writeJSON writes a JSON response with the given status code.
 func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"context"
 
@@ -216,6 +218,64 @@ func TestListWorkloadsDefaultLimit(t *testing.T) {
 	}
 }
 
+func TestListWorkloadsByExitReason(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	for _, reason := range []string{model.ExitReasonExited, model.ExitReasonOOMKilled, model.ExitReasonExited} {
+		wl := &model.Workload{
+			ID:        model.NewID(),
+			Status:    model.StatusPending,
+			Isolation: model.IsolationMicroVM,
+			Runtime:   model.RuntimePython,
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := srv.store.CreateWorkload(ctx, wl); err != nil {
+			t.Fatalf("CreateWorkload: %v", err)
+		}
+		wl.Status = model.StatusFailed
+		wl.ExitReason = reason
+		if err := srv.store.UpdateWorkload(ctx, wl); err != nil {
+			t.Fatalf("UpdateWorkload: %v", err)
+		}
+	}
+
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/workloads?exit_reason=oom_killed")
+	if err != nil {
+		t.Fatalf("GET /v1/workloads: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var listResp listWorkloadsResponse
+	json.NewDecoder(resp.Body).Decode(&listResp)
+
+	if listResp.Total != 1 || len(listResp.Workloads) != 1 {
+		t.Fatalf("got %d workloads of %d, want 1 of 1", len(listResp.Workloads), listResp.Total)
+	}
+	if got := listResp.Workloads[0].ExitReason; got != model.ExitReasonOOMKilled {
+		t.Errorf("exit_reason = %q, want %q", got, model.ExitReasonOOMKilled)
+	}
+}
+
+func TestListWorkloadsUnknownExitReason(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/workloads?exit_reason=exploded")
+	if err != nil {
+		t.Fatalf("GET /v1/workloads: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
 func TestDeleteWorkloadExisting(t *testing.T) {
 	srv := newTestServer(t)
 	ts := httptest.NewServer(srv.Router())
@@ -268,6 +328,60 @@ func TestDeleteWorkloadNotFound(t *testing.T) {
 	}
 }
 
+func TestSignalWorkloadInvalidSignal(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/workloads/any/signal", "application/json", bytes.NewBufferString(`{"signal":"SIGSEGV"}`))
+	if err != nil {
+		t.Fatalf("POST signal: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestSignalWorkloadNotFound(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/workloads/nonexistent/signal", "application/json", bytes.NewBufferString(`{"signal":"SIGUSR1"}`))
+	if err != nil {
+		t.Fatalf("POST signal: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestSignalWorkloadNotRunning(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	// No backend is registered, so the workload never runs.
+	createResp, _ := http.Post(ts.URL+"/v1/workloads", "application/json", bytes.NewBufferString(`{"runtime":"python"}`))
+	var created model.Workload
+	json.NewDecoder(createResp.Body).Decode(&created)
+	createResp.Body.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/workloads/"+created.ID+"/signal", "application/json", bytes.NewBufferString(`{"signal":"SIGUSR1"}`))
+	if err != nil {
+		t.Fatalf("POST signal: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("status = %d, want 409", resp.StatusCode)
+	}
+}
+
 // stubBackend is a minimal Backend for endpoint tests.
 type stubBackend struct{}
 
@@ -496,3 +610,340 @@ func TestAsyncWorkloadCodeAndArchiveMutuallyExclusive(t *testing.T) {
 		t.Errorf("error = %q, want mutual exclusivity message", errResp["error"])
 	}
 }
+
+func TestCreateWorkloadWithNetworkPolicy(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	body := `{"runtime":"python","code":"print(1)","network":{"mode":"egress","allow":[{"cidr":"203.0.113.0/24","protocol":"tcp","ports":[443]}]}}`
+	resp, err := http.Post(ts.URL+"/v1/workloads", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST /v1/workloads: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+
+	var wl model.Workload
+	if err := json.NewDecoder(resp.Body).Decode(&wl); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if wl.Network == nil || wl.Network.Mode != model.NetworkEgress {
+		t.Fatalf("Network = %+v, want egress policy", wl.Network)
+	}
+	if len(wl.Network.Allow) != 1 || wl.Network.Allow[0].CIDR != "203.0.113.0/24" {
+		t.Errorf("Allow = %+v, want one rule for 203.0.113.0/24", wl.Network.Allow)
+	}
+}
+
+func TestCreateWorkloadInvalidNetworkPolicy(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	for _, path := range []string{"/v1/workloads", "/v1/workloads/async"} {
+		body := `{"runtime":"python","code":"print(1)","network":{"mode":"egress","allow":[{"cidr":"not-a-cidr"}]}}`
+		resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestCreateWorkloadInvalidExposePort(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"runtime":"python","code":"print(1)","expose_port":0}`,
+		`{"runtime":"python","code":"print(1)","expose_port":70000}`,
+		`{"runtime":"python","code":"print(1)","expose_port":8080,"network":{"mode":"none"}}`,
+	} {
+		for _, path := range []string{"/v1/workloads", "/v1/workloads/async"} {
+			resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatalf("POST %s: %v", path, err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST %s %s status = %d, want 400", path, body, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestCreateWorkloadGroup(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	body := `{"runtime":"python","code":"print(1)","group":"shop","name":"api"}`
+	resp, err := http.Post(ts.URL+"/v1/workloads", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+
+	var wl model.Workload
+	if err := json.NewDecoder(resp.Body).Decode(&wl); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if wl.Group != "shop" || wl.Name != "api" {
+		t.Errorf("group, name = %q, %q; want shop, api", wl.Group, wl.Name)
+	}
+}
+
+func TestCreateWorkloadInvalidGroup(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"runtime":"python","code":"print(1)","name":"api"}`,
+		`{"runtime":"python","code":"print(1)","group":"Shop"}`,
+		`{"runtime":"python","code":"print(1)","group":"shop","name":"api.v2"}`,
+		`{"runtime":"python","code":"print(1)","group":"shop","network":{"mode":"none"}}`,
+	} {
+		for _, path := range []string{"/v1/workloads", "/v1/workloads/async"} {
+			resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatalf("POST %s: %v", path, err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST %s %s status = %d, want 400", path, body, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestCreateWorkloadInvalidImageRef(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	for _, runtime := range []string{"python@", "Python@3.12", "python@3.12@1"} {
+		body := `{"runtime":"` + runtime + `","code":"print(1)"}`
+		for _, path := range []string{"/v1/workloads", "/v1/workloads/async"} {
+			resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatalf("POST %s: %v", path, err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST %s runtime %q status = %d, want 400", path, runtime, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestCreateWorkloadRateLimits(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	body := `{"runtime":"python","code":"print(1)","resources":{"cpus":1,"disk_bytes_per_s":10485760,"net_packets_per_s":1000}}`
+	resp, err := http.Post(ts.URL+"/v1/workloads", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+
+	var wl model.Workload
+	if err := json.NewDecoder(resp.Body).Decode(&wl); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := model.RateLimits{DiskBytesPerS: 10 << 20, NetPacketsPerS: 1000}
+	if wl.RateLimits == nil || *wl.RateLimits != want {
+		t.Errorf("rate_limits = %+v, want %+v", wl.RateLimits, want)
+	}
+}
+
+func TestCreateWorkloadInvalidRateLimits(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"runtime":"python","code":"print(1)","resources":{"disk_iops":-1}}`,
+		`{"runtime":"python","code":"print(1)","resources":{"net_bytes_per_s":1024},"network":{"mode":"none"}}`,
+	} {
+		for _, path := range []string{"/v1/workloads", "/v1/workloads/async"} {
+			resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatalf("POST %s: %v", path, err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST %s %s status = %d, want 400", path, body, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestCreateWorkloadVolumes(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	body := `{"runtime":"python","code":"print(1)","volumes":[{"name":"data","mount_path":"/data"},{"name":"models","mount_path":"/models","read_only":true}]}`
+	resp, err := http.Post(ts.URL+"/v1/workloads", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+
+	var wl model.Workload
+	if err := json.NewDecoder(resp.Body).Decode(&wl); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []model.VolumeMount{{Name: "data", MountPath: "/data"}, {Name: "models", MountPath: "/models", ReadOnly: true}}
+	if len(wl.Volumes) != 2 || wl.Volumes[0] != want[0] || wl.Volumes[1] != want[1] {
+		t.Errorf("volumes = %+v, want %+v", wl.Volumes, want)
+	}
+}
+
+func TestCreateWorkloadInvalidVolumes(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"runtime":"python","code":"print(1)","volumes":[{"name":"Data","mount_path":"/data"}]}`,
+		`{"runtime":"python","code":"print(1)","volumes":[{"name":"data","mount_path":"data"}]}`,
+		`{"runtime":"python","code":"print(1)","volumes":[{"name":"data","mount_path":"/proc/data"}]}`,
+		`{"runtime":"python","code":"print(1)","volumes":[{"name":"a","mount_path":"/data"},{"name":"b","mount_path":"/data"}]}`,
+	} {
+		for _, path := range []string{"/v1/workloads", "/v1/workloads/async"} {
+			resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatalf("POST %s: %v", path, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST %s %s: status = %d, want 400", path, body, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestCreateWorkloadDisk(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	body := `{"runtime":"python","code":"print(1)","resources":{"disk_mb":256}}`
+	resp, err := http.Post(ts.URL+"/v1/workloads", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	var wl model.Workload
+	if err := json.NewDecoder(resp.Body).Decode(&wl); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if wl.DiskLimit == nil || *wl.DiskLimit != 256 {
+		t.Errorf("disk_limit = %v, want 256", wl.DiskLimit)
+	}
+
+	for _, mb := range []int{-1, model.MinDiskMB - 1} {
+		body := fmt.Sprintf(`{"runtime":"python","code":"print(1)","resources":{"disk_mb":%d}}`, mb)
+		for _, path := range []string{"/v1/workloads", "/v1/workloads/async"} {
+			resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatalf("POST %s: %v", path, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST %s disk_mb=%d: status = %d, want 400", path, mb, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestCreateWorkloadGrace(t *testing.T) {
+	srv := newTestServer(t)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	body := `{"runtime":"python","code":"print(1)","resources":{"timeout_s":10,"grace_s":20}}`
+	resp, err := http.Post(ts.URL+"/v1/workloads", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	var wl model.Workload
+	if err := json.NewDecoder(resp.Body).Decode(&wl); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if wl.GraceS == nil || *wl.GraceS != 20 {
+		t.Errorf("grace_s = %v, want 20", wl.GraceS)
+	}
+
+	for _, s := range []int{0, -1, model.MaxGraceS + 1} {
+		body := fmt.Sprintf(`{"runtime":"python","code":"print(1)","resources":{"grace_s":%d}}`, s)
+		for _, path := range []string{"/v1/workloads", "/v1/workloads/async"} {
+			resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatalf("POST %s: %v", path, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST %s grace_s=%d: status = %d, want 400", path, s, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestAsyncWorkloadOverScratchBudget(t *testing.T) {
+	srv := newTestServer(t)
+	srv.engine.SetScratchBudget(128)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	body := `{"runtime":"python","code":"print(1)","resources":{"disk_mb":256}}`
+	resp, err := http.Post(ts.URL+"/v1/workloads/async", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&e)
+	if !strings.Contains(e.Error, "scratch disk budget exceeded") {
+		t.Errorf("error = %q, want the budget named", e.Error)
+	}
+	srv.engine.Wait()
+}
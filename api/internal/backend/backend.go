@@ -1,6 +1,34 @@
 package backend
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// Errors returned by optional backend operations.
+var (
+	// ErrNotRunning is returned when an operation targets a workload that the
+	// backend is not currently executing.
+	ErrNotRunning = errors.New("workload is not running")
+
+	// ErrUnsupported is returned when a backend, or the sandbox a workload runs
+	// in, cannot perform an optional operation.
+	ErrUnsupported = errors.New("operation not supported")
+)
+
+// Errors wrapped by Execute when a workload's sandbox failed under it.
+var (
+	// ErrVMCrashed is wrapped when the VM running a workload stopped before
+	// the workload finished.
+	ErrVMCrashed = errors.New("VM crashed")
+
+	// ErrAgentUnreachable is wrapped when the VM running a workload kept
+	// running but its guest agent could not be reached or stopped answering.
+	ErrAgentUnreachable = errors.New("guest agent unreachable")
+)
 
 // Backend is the interface that all isolation backends must implement.
 // Each backend (Firecracker microVM, V8 isolate, gVisor) provides its own
@@ -17,6 +45,45 @@ type Backend interface {
 	Cleanup(ctx context.Context, workloadID string) error
 }
 
+// Signaler is implemented by backends that can deliver signals to a running
+// workload's processes.
+type Signaler interface {
+	// Signal delivers the named signal (e.g. "SIGUSR1") to the workload.
+	// Returns ErrNotRunning if the workload is not executing and
+	// ErrUnsupported if its sandbox cannot receive signals.
+	Signal(ctx context.Context, workloadID, signal string) error
+}
+
+// Sweeper is implemented by backends that can find and remove host
+// resources leaked by sandboxes that are no longer running.
+type Sweeper interface {
+	// Sweep finds leaked resources and, unless dryRun is set, removes them.
+	Sweep(ctx context.Context, dryRun bool) (SweepReport, error)
+}
+
+// SweepReport lists the leaked resources found by a sweep.
+type SweepReport struct {
+	Backend   string           `json:"backend"`
+	DryRun    bool             `json:"dry_run"`
+	Resources []LeakedResource `json:"resources"`
+
+	// Errors lists sources of resources the sweep could not inspect.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// LeakedResource is a host resource left behind by a sandbox that is no
+// longer running.
+type LeakedResource struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	WorkloadID string `json:"workload_id,omitempty"`
+
+	// Removed reports whether the sweep removed the resource; Error is set
+	// if removing it failed. Both are empty in a dry run.
+	Removed bool   `json:"removed,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
 // WorkloadSpec describes a workload to be executed by a backend.
 type WorkloadSpec struct {
 	ID         string `json:"id"`
@@ -28,13 +95,145 @@ type WorkloadSpec struct {
 	MemLimitMB int    `json:"mem_limit_mb"`
 	TimeoutS   int    `json:"timeout_s"`
 
+	// GraceS is how long the workload's processes have between SIGTERM and
+	// SIGKILL once it times out or is cancelled, during which its logs keep
+	// streaming; zero leaves it to the backend.
+	GraceS int `json:"grace_s,omitempty"`
+
+	// DiskMB is the size of the scratch disk holding the sandbox's /tmp and
+	// working directory; zero leaves them on the root filesystem.
+	DiskMB int `json:"disk_mb,omitempty"`
+
 	// CodeArchive is a tar.gz archive containing the workload code.
 	// When set, it takes precedence over the Code field.
 	CodeArchive []byte `json:"code_archive,omitempty"`
 
+	// Network is the workload's network policy; nil means model.NetworkFull.
+	Network *model.NetworkPolicy `json:"network,omitempty"`
+
+	// ExposePort is a port inside the sandbox to make reachable from the
+	// host, or zero for none.
+	ExposePort int `json:"expose_port,omitempty"`
+
+	// OnEndpoint is an optional callback that backends invoke with the URL
+	// of the exposed port once it is reachable from the host.
+	OnEndpoint func(url string) `json:"-"`
+
+	// Group is the private network the workload shares with the other
+	// workloads of the same group, and Name is its host name there. Both are
+	// empty for workloads outside any group.
+	Group string `json:"group,omitempty"`
+	Name  string `json:"name,omitempty"`
+
+	// RateLimits caps the sandbox's disk and network throughput; nil means
+	// unlimited.
+	RateLimits *model.RateLimits `json:"rate_limits,omitempty"`
+
+	// Image is the catalog image the workload runs in, in place of the
+	// backend's built-in image for Runtime; nil for built-in runtimes.
+	Image *model.Image `json:"image,omitempty"`
+
+	// Volumes are the persistent volumes to attach to the sandbox, already
+	// locked for the workload.
+	Volumes []AttachedVolume `json:"volumes,omitempty"`
+
+	// Service runs the workload as a service: its process is restarted per
+	// the restart policy and health-checked until ctx is cancelled, and
+	// TimeoutS is ignored. Nil runs the workload to completion.
+	Service *model.ServiceSpec `json:"service,omitempty"`
+
+	// OnHealth is an optional callback that backends invoke when a
+	// service's health changes or its process is restarted.
+	OnHealth func(HealthReport) `json:"-"`
+
+	// Session keeps the sandbox alive to run the executions received on
+	// Session.Execs, in place of running Code, until ctx is cancelled or the
+	// channel is closed. TimeoutS is ignored. Nil runs the workload itself.
+	Session *SessionSpec `json:"-"`
+
 	// LogWriter is an optional callback that backends invoke to emit log lines
 	// during execution. Each call delivers one line to connected SSE subscribers.
-	LogWriter func(line string) `json:"-"`
+	// stream is one of model.WorkloadLogStreams, or empty if the backend
+	// cannot tell which stream the line came from.
+	LogWriter func(stream, line string) `json:"-"`
+
+	// BuildLogWriter is an optional callback that backends invoke with each
+	// line of output from compiling the workload's code, for backends that
+	// build code before running it.
+	BuildLogWriter func(line string) `json:"-"`
+}
+
+// SessionSpec describes a session: a sandbox that runs many executions,
+// one at a time, each seeing the files left by the ones before it.
+type SessionSpec struct {
+	// Interpreter runs the executions' code in one long-lived interpreter
+	// process, so that they also share its variables and imports.
+	Interpreter bool
+
+	// Concurrency, if above 1, runs up to that many executions at once,
+	// each in a work directory of its own, instead of one after another in
+	// a shared one. Backends use it to pack workloads into one sandbox.
+	Concurrency int
+
+	// Execs delivers the executions to run. Backends answer each on its
+	// Result channel.
+	Execs <-chan ExecRequest
+
+	// OnReady is an optional callback that backends invoke once the sandbox
+	// is ready to run executions.
+	OnReady func()
+}
+
+// ExecRequest is one execution in a session.
+type ExecRequest struct {
+	// ID identifies the execution, as the workload it is stored as, to
+	// Signaler.Signal and in logs.
+	ID string
+
+	// Ctx bounds the execution; cancelling it stops the execution but not
+	// the session.
+	Ctx context.Context
+
+	Code        string
+	CodeArchive []byte
+	TimeoutS    int
+	GraceS      int // as WorkloadSpec.GraceS
+
+	// LogWriter is an optional callback that backends invoke with each line
+	// the execution logs, as WorkloadSpec.LogWriter.
+	LogWriter func(stream, line string)
+
+	// Result receives the outcome of the execution, exactly once.
+	Result chan<- ExecResult
+}
+
+// ExecResult is the outcome of an ExecRequest. Err is set when the
+// execution could not be run at all.
+type ExecResult struct {
+	Result WorkloadResult
+	Err    error
+}
+
+// AttachedVolume is a persistent volume attached to a workload's sandbox.
+type AttachedVolume struct {
+	Name string `json:"name"`
+
+	// Path is the volume's ext4 filesystem image on the host.
+	Path string `json:"path"`
+
+	// MountPath is where the volume is mounted inside the sandbox.
+	MountPath string `json:"mount_path"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+// HealthReport describes a service's health as reported by its backend.
+type HealthReport struct {
+	// Status is one of the model.Health* constants.
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+
+	// Restarts is how many times the service's process has been restarted.
+	Restarts int `json:"restarts"`
 }
 
 // WorkloadResult holds the output produced by a backend after executing a workload.
@@ -44,6 +243,26 @@ type WorkloadResult struct {
 	Error      string   `json:"error"`
 	DurationMS int      `json:"duration_ms"`
 	LogLines   []string `json:"log_lines"`
+
+	// Stdout and Stderr are the workload's output on each stream, for
+	// backends that keep them apart; Output holds both, stdout first.
+	Stdout []byte `json:"stdout,omitempty"`
+	Stderr []byte `json:"stderr,omitempty"`
+
+	// ExitReason classifies how the workload's process ended, one of
+	// model.ExitReasons, and ExitSignal names the signal that killed it.
+	// Empty for backends that cannot tell, whose workloads are taken to
+	// have exited.
+	ExitReason string `json:"exit_reason,omitempty"`
+	ExitSignal string `json:"exit_signal,omitempty"`
+
+	// Usage is the resources consumed by the workload, for backends that can
+	// measure it. Nil when unknown.
+	Usage *model.ResourceUsage `json:"usage,omitempty"`
+
+	// ConsoleLines is the sandbox's console output, returned alongside an
+	// error when the sandbox failed to boot or its agent was unreachable.
+	ConsoleLines []string `json:"console_lines,omitempty"`
 }
 
 // BackendCapabilities describes what a backend supports.
@@ -52,4 +271,59 @@ type BackendCapabilities struct {
 	SupportedRuntimes   []string `json:"supported_runtimes"`
 	SupportedIsolations []string `json:"supported_isolations"`
 	MaxConcurrency      int      `json:"max_concurrency"`
+
+	// NetworkModes lists the network policy modes the backend enforces.
+	// Workloads requesting any other mode are rejected.
+	NetworkModes []string `json:"network_modes,omitempty"`
+
+	// Ingress reports whether the backend can expose a port inside the
+	// sandbox to the host.
+	Ingress bool `json:"ingress,omitempty"`
+
+	// NetworkGroups reports whether the backend can place workloads on
+	// private per-group networks.
+	NetworkGroups bool `json:"network_groups,omitempty"`
+
+	// RateLimits reports whether the backend can enforce disk and network
+	// rate limits.
+	RateLimits bool `json:"rate_limits,omitempty"`
+
+	// Images reports whether the backend can run catalog rootfs images.
+	Images bool `json:"images,omitempty"`
+
+	// Volumes reports whether the backend can attach persistent volumes.
+	Volumes bool `json:"volumes,omitempty"`
+
+	// ScratchDisks reports whether the backend can give workloads a scratch
+	// disk of a fixed size.
+	ScratchDisks bool `json:"scratch_disks,omitempty"`
+
+	// Services reports whether the backend can run service workloads.
+	Services bool `json:"services,omitempty"`
+
+	// Sessions reports whether the backend can keep a sandbox alive across
+	// executions.
+	Sessions bool `json:"sessions,omitempty"`
+
+	// Agents lists the guest agents observed in the backend's runtime images,
+	// for backends that run an agent inside each sandbox.
+	Agents []AgentInfo `json:"agents,omitempty"`
+}
+
+// AgentInfo describes the guest agent found in one of a backend's runtime
+// images, as reported by the agent itself during the connection handshake.
+type AgentInfo struct {
+	Image           string    `json:"image"`
+	AgentVersion    string    `json:"agent_version"`
+	ProtocolVersion int       `json:"protocol_version"`
+	Runtimes        []string  `json:"runtimes,omitempty"`
+	Features        []string  `json:"features,omitempty"`
+	Kernel          string    `json:"kernel,omitempty"`
+	ObservedAt      time.Time `json:"observed_at"`
+
+	// Toolchains maps runtimes that compile code to their toolchain version.
+	Toolchains map[string]string `json:"toolchains,omitempty"`
+
+	// MaxExecs is how many requests the agent executes at once.
+	MaxExecs int `json:"max_execs,omitempty"`
 }
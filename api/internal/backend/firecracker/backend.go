@@ -1,13 +1,18 @@
 package firecracker
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net/netip"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -41,6 +46,10 @@ const (
 
 	// gracefulShutdownTimeout is the time allowed for graceful VM shutdown.
 	gracefulShutdownTimeout = 3 * time.Second
+
+	// inlineInputLimit is the largest input sent inline in the GuestRequest;
+	// larger inputs are streamed to the guest as chunk frames.
+	inlineInputLimit = 1 << 20
 )
 
 // vmState tracks the state of an active microVM.
@@ -50,6 +59,18 @@ type vmState struct {
 	netConfig *NetworkConfig
 	socketDir string // temp directory for socket files and rootfs copy
 	started   bool   // true after machine.Start succeeds (guards activeVMs gauge)
+
+	// guest and agent are set once the guest agent is connected; guarded by Backend.mu.
+	guest *GuestConn
+	agent GuestHello
+
+	// ingress forwards a host port to the workload's exposed port, if any.
+	ingress *ingressProxy
+
+	// console captures the serial console; metrics reads the VMM's metrics
+	// FIFO once the VM has started.
+	console *consoleBuffer
+	metrics *vmmMetricsReader
 }
 
 // Backend implements the backend.Backend interface using Firecracker microVMs.
@@ -60,10 +81,26 @@ type Backend struct {
 
 	mu       sync.Mutex
 	activeVMs map[string]*vmState // workloadID → vmState
+	inflight  map[string]bool     // workloads inside Execute, including setup and teardown
+
+	sweepMu     sync.Mutex // serializes sweeps
+	sweepEnv    sweepEnv
+	stopSweeper func()
 
 	cidMu    sync.Mutex
 	cidNext  uint32
 	cidInUse map[uint32]bool
+
+	agentsMu sync.Mutex
+	agents   map[string]backend.AgentInfo // rootfs image → last observed agent
+
+	runtimes []string // runtimes the rootfs images provide, from DiscoverRuntimes
+
+	deps   *depsCache  // nil when the dependency install phase is disabled
+	builds *buildCache // nil when compiled Go programs are not cached
+	packs  *packer     // nil when every workload gets its own VM
+
+	execs map[string]execConn // execution ID → connection, for executions of sessions and packed VMs; guarded by mu
 }
 
 // NewBackend creates a new Firecracker backend.
@@ -72,14 +109,37 @@ func NewBackend(cfg Config, logger *slog.Logger) (*Backend, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create network manager: %w", err)
 	}
+	deps, err := newDepsCache(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create dependency cache: %w", err)
+	}
+	builds, err := newBuildCache(cfg.GoBuildCacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("create build cache: %w", err)
+	}
+	runtimes, err := DiscoverRuntimes(context.Background(), DefaultDebugFSBin, cfg.RootfsDir, logger)
+	if err != nil {
+		return nil, fmt.Errorf("discover runtimes: %w", err)
+	}
+	for _, rt := range runtimes {
+		initRuntimeMetrics(rt)
+	}
+	logger.Info("discovered runtimes", "rootfs_dir", cfg.RootfsDir, "runtimes", runtimes)
 
 	return &Backend{
 		cfg:       cfg,
 		netMgr:    netMgr,
 		logger:    logger,
 		activeVMs: make(map[string]*vmState),
+		inflight:  make(map[string]bool),
+		sweepEnv:  defaultSweepEnv(),
 		cidNext:   cfg.CIDBase,
 		cidInUse:  make(map[uint32]bool),
+		agents:    make(map[string]backend.AgentInfo),
+		deps:      deps,
+		builds:    builds,
+		packs:     newPacker(cfg.PackExecs),
+		runtimes:  runtimes,
 	}, nil
 }
 
@@ -89,30 +149,71 @@ func (b *Backend) Verify() error {
 }
 
 // Execute runs a workload inside a Firecracker microVM.
-func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (result backend.WorkloadResult, err error) {
+	if key, ok := b.packKey(spec); ok {
+		return b.executePacked(ctx, key, spec)
+	}
 	start := time.Now()
 
+	// Registered first so it runs last: the sweeper treats the workload's
+	// resources as live until every other cleanup has finished.
+	b.mu.Lock()
+	b.inflight[spec.ID] = true
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.inflight, spec.ID)
+		b.mu.Unlock()
+	}()
+
 	// 1. Select rootfs image.
-	rootfsPath, err := RootfsPath(b.cfg.RootfsDir, spec.Runtime)
-	if err != nil {
+	rootfsPath := ""
+	if spec.Image != nil {
+		rootfsPath = spec.Image.Path
+	} else if !slices.Contains(b.runtimes, spec.Runtime) {
+		return backend.WorkloadResult{}, fmt.Errorf("select rootfs: unsupported runtime %q: must be one of %v", spec.Runtime, b.runtimes)
+	} else if rootfsPath, err = RootfsPath(b.cfg.RootfsDir, spec.Runtime); err != nil {
 		return backend.WorkloadResult{}, fmt.Errorf("select rootfs: %w", err)
 	}
 
+	if spec.ExposePort != 0 && spec.Network.EffectiveMode() == model.NetworkNone {
+		return backend.WorkloadResult{}, fmt.Errorf("expose port %d: workload has no network", spec.ExposePort)
+	}
+	if spec.Group != "" && spec.Network.EffectiveMode() == model.NetworkNone {
+		return backend.WorkloadResult{}, fmt.Errorf("network group %s: workload has no network", spec.Group)
+	}
+	if spec.RateLimits != nil {
+		if err := spec.RateLimits.Validate(); err != nil {
+			return backend.WorkloadResult{}, err
+		}
+	}
+	if spec.RateLimits.HasNetwork() && spec.Network.EffectiveMode() == model.NetworkNone {
+		return backend.WorkloadResult{}, errors.New("network rate limits: workload has no network")
+	}
+
 	// 2. Allocate CID.
 	cid, err := b.allocateCID()
 	if err != nil {
 		return backend.WorkloadResult{}, fmt.Errorf("allocate CID: %w", err)
 	}
 
-	// 3. Set up CNI networking.
-	netCfg, err := b.netMgr.Setup(ctx, spec.ID)
-	if err != nil {
-		b.releaseCID(cid)
-		return backend.WorkloadResult{}, fmt.Errorf("network setup: %w", err)
+	// 3. Set up CNI networking, unless the workload gets no network at all.
+	var netCfg *NetworkConfig
+	if spec.Network.EffectiveMode() != model.NetworkNone {
+		netCfg, err = b.netMgr.Setup(ctx, spec.ID, NetworkRequest{
+			Policy:      spec.Network,
+			IngressPort: spec.ExposePort,
+			Group:       spec.Group,
+			Name:        spec.Name,
+		})
+		if err != nil {
+			b.releaseCID(cid)
+			return backend.WorkloadResult{}, fmt.Errorf("network setup: %w", err)
+		}
 	}
 
 	// 4. Create temporary directory for socket and rootfs copy.
-	socketDir, err := os.MkdirTemp("", "vulcan-vm-"+spec.ID+"-")
+	socketDir, err := os.MkdirTemp("", vmDirPrefix+spec.ID+"-")
 	if err != nil {
 		b.releaseCID(cid)
 		b.cleanupResources(ctx, spec.ID, "")
@@ -127,6 +228,28 @@ func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backe
 		return backend.WorkloadResult{}, fmt.Errorf("copy rootfs: %w", err)
 	}
 
+	// Attach the dependency layer for the code's manifests, installing it
+	// on a cache miss.
+	deps, err := b.prepareDeps(ctx, spec, rootfsPath)
+	if err != nil {
+		b.releaseCID(cid)
+		b.cleanupResources(ctx, spec.ID, socketDir)
+		return backend.WorkloadResult{}, fmt.Errorf("prepare dependencies: %w", err)
+	}
+	if deps != nil {
+		defer b.deps.discard(deps) // runs after the VM has stopped
+	}
+
+	// Create the scratch disk holding /tmp and the working directory.
+	var scratchPath string
+	if spec.DiskMB > 0 {
+		if scratchPath, err = createScratch(ctx, DefaultMkfsBin, socketDir, spec.DiskMB); err != nil {
+			b.releaseCID(cid)
+			b.cleanupResources(ctx, spec.ID, socketDir)
+			return backend.WorkloadResult{}, fmt.Errorf("create scratch disk: %w", err)
+		}
+	}
+
 	// 6. Configure VM.
 	socketPath := filepath.Join(socketDir, spec.ID+vmSocketSuffix)
 	vsockPath := filepath.Join(socketDir, spec.ID+vsockSocketSuffix)
@@ -140,6 +263,20 @@ func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backe
 		memMB = int64(spec.MemLimitMB)
 	}
 
+	// The disk rate limits cover the rootfs, the dependency layer and
+	// package mirror, the volumes and the scratch disk together.
+	limitedDrives := 1 + len(spec.Volumes)
+	if scratchPath != "" {
+		limitedDrives++
+	}
+	if deps != nil {
+		limitedDrives++
+		if deps.install {
+			limitedDrives++
+		}
+	}
+	diskLimiter := driveRateLimiter(spec.RateLimits, limitedDrives)
+
 	fcCfg := fcsdk.Config{
 		SocketPath:      socketPath,
 		KernelImagePath: b.cfg.KernelPath,
@@ -150,14 +287,7 @@ func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backe
 				PathOnHost:   fcsdk.String(vmRootfs),
 				IsRootDevice: fcsdk.Bool(true),
 				IsReadOnly:   fcsdk.Bool(false),
-			},
-		},
-		NetworkInterfaces: fcsdk.NetworkInterfaces{
-			{
-				StaticConfiguration: &fcsdk.StaticNetworkConfiguration{
-					MacAddress:  netCfg.MACAddress,
-					HostDevName: netCfg.TAPDevice,
-				},
+				RateLimiter:  diskLimiter,
 			},
 		},
 		VsockDevices: []fcsdk.VsockDevice{
@@ -172,21 +302,79 @@ func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backe
 			MemSizeMib: fcsdk.Int64(memMB),
 			Smt:        fcsdk.Bool(false),
 		},
-		NetNS: netCfg.NamespacePath,
-		VMID:  spec.ID,
+		MetricsFifo: filepath.Join(socketDir, metricsFifoName),
+		VMID:        spec.ID,
+	}
+	if deps != nil {
+		fcCfg.Drives = append(fcCfg.Drives, models.Drive{
+			DriveID:      fcsdk.String(depsDriveID),
+			PathOnHost:   fcsdk.String(deps.path),
+			IsRootDevice: fcsdk.Bool(false),
+			IsReadOnly:   fcsdk.Bool(!deps.install),
+			RateLimiter:  diskLimiter,
+		})
+		if deps.install {
+			fcCfg.Drives = append(fcCfg.Drives, models.Drive{
+				DriveID:      fcsdk.String(mirrorDriveID),
+				PathOnHost:   fcsdk.String(deps.mirror),
+				IsRootDevice: fcsdk.Bool(false),
+				IsReadOnly:   fcsdk.Bool(true),
+				RateLimiter:  diskLimiter,
+			})
+		}
+	}
+	var volumeMounts []VolumeMount
+	fcCfg.Drives, volumeMounts = attachVolumes(fcCfg.Drives, spec.Volumes, diskLimiter)
+	var scratchDevice string
+	if scratchPath != "" {
+		fcCfg.Drives, scratchDevice = attachScratch(fcCfg.Drives, scratchPath, diskLimiter)
+	}
+	if netCfg != nil {
+		ipCfg, err := netCfg.IPConfiguration()
+		if err != nil {
+			b.releaseCID(cid)
+			b.cleanupResources(ctx, spec.ID, socketDir)
+			return backend.WorkloadResult{}, fmt.Errorf("guest IP configuration: %w", err)
+		}
+		fcCfg.NetworkInterfaces = fcsdk.NetworkInterfaces{
+			{
+				StaticConfiguration: &fcsdk.StaticNetworkConfiguration{
+					MacAddress:      netCfg.MACAddress,
+					HostDevName:     netCfg.TAPDevice,
+					IPConfiguration: ipCfg,
+				},
+				InRateLimiter:  netRateLimiter(spec.RateLimits),
+				OutRateLimiter: netRateLimiter(spec.RateLimits),
+			},
+		}
+		fcCfg.NetNS = netCfg.NamespacePath
+		fcCfg.KernelArgs += netCfg.KernelArgs()
+	}
+	if spec.Session != nil && spec.Session.Concurrency > 1 {
+		fcCfg.KernelArgs += fmt.Sprintf(" %s=%d", BootParamMaxExecs, spec.Session.Concurrency)
 	}
 
 	// Create a logrus logger that discards output (we use slog).
 	fcLogger := logrus.New()
 	fcLogger.SetOutput(io.Discard)
 
-	// Use the configured Firecracker binary path.
+	// The VMM must outlive cancellation of ctx so that a cancelled workload
+	// can be terminated cleanly by the guest agent and still report its
+	// result; stopAndCleanup stops the VM on every exit path.
+	vmCtx, vmCancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer vmCancel()
+
+	// Use the configured Firecracker binary path. The guest's serial console
+	// arrives on the VMM's stdout.
+	console := &consoleBuffer{}
 	fcCmd := fcsdk.VMCommandBuilder{}.
 		WithBin(b.cfg.FirecrackerBin).
 		WithSocketPath(socketPath).
-		Build(ctx)
+		WithStdout(console).
+		WithStderr(console).
+		Build(vmCtx)
 
-	machine, err := fcsdk.NewMachine(ctx, fcCfg,
+	machine, err := fcsdk.NewMachine(vmCtx, fcCfg,
 		fcsdk.WithLogger(logrus.NewEntry(fcLogger)),
 		fcsdk.WithProcessRunner(fcCmd),
 	)
@@ -202,25 +390,38 @@ func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backe
 		cid:       cid,
 		netConfig: netCfg,
 		socketDir: socketDir,
+		console:   console,
 	}
 	b.mu.Lock()
 	b.activeVMs[spec.ID] = state
 	b.mu.Unlock()
 
-	// Ensure cleanup on all exit paths.
+	// Ensure cleanup on all exit paths. If the VM failed to boot or its
+	// agent could not be reached, the serial console shows why; it is
+	// complete once the VMM has exited.
+	var connected bool
 	defer func() {
 		b.stopAndCleanup(ctx, spec.ID, state)
+		if err != nil && !connected {
+			result.ConsoleLines = console.Lines()
+		}
 	}()
 
 	// 7. Start VM.
 	bootStart := time.Now()
-	if err := machine.Start(ctx); err != nil {
+	if err := machine.Start(vmCtx); err != nil {
 		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
 		return backend.WorkloadResult{}, fmt.Errorf("start VM: %w", err)
 	}
 	state.started = true
 	activeVMs.Inc()
 
+	metrics, err := startVMMMetrics(fcCfg.MetricsFifo, socketPath, spec.ID, b.logger)
+	if err != nil {
+		b.logger.Warn("VMM metrics unavailable", "workload_id", spec.ID, "error", err)
+	}
+	state.metrics = metrics
+
 	b.logger.Info("VM started",
 		"workload_id", spec.ID,
 		"runtime", spec.Runtime,
@@ -229,14 +430,89 @@ func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backe
 		"mem_mb", memMB,
 	)
 
-	// 8. Connect to guest agent via vsock.
-	gc, err := DialGuest(ctx, vsockPath, b.cfg.VsockPort)
+	// 8. Connect to guest agent via vsock and check its capabilities.
+	gc, agent, err := b.connectGuest(ctx, spec.Runtime, vsockPath)
 	vmBootDuration.Observe(time.Since(bootStart).Seconds())
 	if err != nil {
 		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+		if ctx.Err() == nil {
+			err = guestLost(machine.Wait, err)
+		}
 		return backend.WorkloadResult{}, fmt.Errorf("connect to guest: %w", err)
 	}
 	defer gc.Close()
+	connected = true
+
+	b.mu.Lock()
+	state.guest = gc
+	state.agent = agent
+	b.mu.Unlock()
+
+	// Cancelling ctx or its deadline passing stops the workload, which is
+	// given its grace period to exit and report its result.
+	grace := gracePeriod(spec.GraceS)
+	if err := gc.SetDeadline(graceDeadline(ctx, grace)); err != nil {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+		return backend.WorkloadResult{}, fmt.Errorf("set guest deadline: %w", err)
+	}
+	stopCancelWatch := context.AfterFunc(ctx, func() {
+		b.stopGuest(spec.ID, gc, agent, grace)
+	})
+	defer stopCancelWatch()
+
+	if spec.ExposePort != 0 {
+		ingress, err := b.startIngress(spec, netCfg)
+		if err != nil {
+			workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+			return backend.WorkloadResult{}, fmt.Errorf("expose port %d: %w", spec.ExposePort, err)
+		}
+		b.mu.Lock()
+		state.ingress = ingress
+		b.mu.Unlock()
+		if spec.OnEndpoint != nil {
+			spec.OnEndpoint(ingress.URL())
+		}
+	}
+
+	if spec.Image != nil && !agent.HasFeature(FeatureCommand) {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+		return backend.WorkloadResult{}, fmt.Errorf("guest agent %s in image %s cannot run image commands",
+			agent.AgentVersion, spec.Image.Ref())
+	}
+	if spec.Image == nil && agent.ProtocolVersion > LegacyProtocolVersion && !agent.HasRuntime(spec.Runtime) {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+		return backend.WorkloadResult{}, fmt.Errorf("guest agent %s in %s rootfs does not support runtime %q (advertises %v)",
+			agent.AgentVersion, spec.Runtime, spec.Runtime, agent.Runtimes)
+	}
+
+	if len(spec.Volumes) > 0 && !agent.HasFeature(FeatureVolumes) {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+		return backend.WorkloadResult{}, fmt.Errorf("guest agent %s cannot mount volumes", agent.AgentVersion)
+	}
+	if scratchDevice != "" && !agent.HasFeature(FeatureScratch) {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+		return backend.WorkloadResult{}, fmt.Errorf("guest agent %s cannot mount scratch disks", agent.AgentVersion)
+	}
+	if spec.Service != nil && !agent.HasFeature(FeatureService) {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+		return backend.WorkloadResult{}, fmt.Errorf("guest agent %s cannot run services", agent.AgentVersion)
+	}
+	if spec.Session != nil && !agent.HasFeature(FeatureSession) {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+		return backend.WorkloadResult{}, fmt.Errorf("guest agent %s cannot run sessions", agent.AgentVersion)
+	}
+
+	// Sessions run their executions in place of the workload, each
+	// cancelled on its own.
+	if spec.Session != nil {
+		stopCancelWatch()
+		result, err := b.runSession(ctx, spec, state, vsockPath, gc, agent)
+		// Packed VMs count their executions as the workloads.
+		if spec.Session.Concurrency <= 1 {
+			workloadsTotal.WithLabelValues(spec.Runtime, statusCompleted).Inc()
+		}
+		return result, err
+	}
 
 	// 9. Send workload and stream results.
 	req := GuestRequest{
@@ -245,21 +521,98 @@ func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backe
 		CodeArchive: spec.CodeArchive,
 		Input:       spec.Input,
 		TimeoutS:    spec.TimeoutS,
+		GraceMS:     int(grace.Milliseconds()),
+		Volumes:     volumeMounts,
+	}
+	req.ScratchDevice = scratchDevice
+	req.Service = serviceRequest(spec.Service)
+	if spec.Image != nil {
+		req.Command = spec.Image.Command
+		req.Entrypoint = spec.Image.Entrypoint
+	}
+	if agent.HasFeature(FeatureControl) {
+		req.HeartbeatMS = int(HeartbeatInterval.Milliseconds())
+	}
+	var onDepsInstalled func()
+	if deps != nil {
+		if agent.HasFeature(FeatureDeps) {
+			req.Deps = &DepsRequest{Device: depsGuestDevice, Install: deps.install}
+			if deps.install {
+				req.Deps.MirrorDevice = mirrorGuestDevice
+				// The layer is cached only while nothing but the installer
+				// has written to it; the workload's code could plant files
+				// in it later.
+				if agent.HasFeature(FeatureDepsCommit) {
+					req.Deps.Commit = true
+					onDepsInstalled = func() { b.commitDeps(spec.ID, deps) }
+				} else {
+					b.logger.Warn("guest agent cannot hand over dependency layers before running code, not caching them",
+						"workload_id", spec.ID, "agent_version", agent.AgentVersion)
+				}
+			}
+		} else {
+			b.logger.Warn("guest agent cannot attach dependency layers, running without them",
+				"workload_id", spec.ID, "agent_version", agent.AgentVersion)
+		}
+	}
+
+	var stdout, stderr outputBuffer
+	sio := StreamIO{LogWriter: spec.LogWriter, BuildLogWriter: spec.BuildLogWriter, OnDepsInstalled: onDepsInstalled}
+	if spec.Service != nil {
+		sio.OnHealth = healthReporter(spec)
+	}
+	if agent.HasFeature(FeatureChunkedIO) {
+		sio.Stdout = &stdout
+		sio.Stderr = &stderr
+		if len(spec.Input) > inlineInputLimit {
+			sio.Input = bytes.NewReader(spec.Input)
+		}
+	}
+
+	// Go code is compiled once per code digest and toolchain; later runs
+	// are sent the cached program instead of compiling it again.
+	var build *goBuild
+	if toolchain := agent.Toolchains[model.RuntimeGo]; b.builds != nil && spec.Image == nil &&
+		spec.Runtime == model.RuntimeGo && toolchain != "" && agent.HasFeature(FeatureGoBuild) {
+		build, err = b.builds.prepare(buildKey(codeDigest(spec), toolchain))
+		if err != nil {
+			b.logger.Warn("build cache unavailable, compiling without it", "workload_id", spec.ID, "error", err)
+		}
+	}
+	if build != nil {
+		req.Build = &BuildRequest{Prebuilt: build.prebuilt}
+		if build.prebuilt {
+			sio.Program = build.file
+		} else {
+			sio.BuiltProgram = build
+		}
 	}
 
 	vsockStart := time.Now()
-	resp, err := gc.RunWorkload(req, spec.LogWriter)
+	resp, err := gc.RunWorkloadStream(req, sio)
 	vsockWorkloadDuration.Observe(time.Since(vsockStart).Seconds())
+	if build != nil {
+		if ferr := b.builds.finish(build, err == nil && resp.Built); ferr != nil {
+			b.logger.Warn("failed to cache compiled program", "workload_id", spec.ID, "error", ferr)
+		} else if !build.prebuilt && err == nil && resp.Built {
+			b.logger.Info("compiled program cached", "workload_id", spec.ID, "key", build.key, "size_bytes", build.size)
+		}
+	}
 	if err != nil {
 		if ctx.Err() != nil {
 			workloadsTotal.WithLabelValues(spec.Runtime, statusKilled).Inc()
 		} else {
 			workloadsTotal.WithLabelValues(spec.Runtime, statusFailed).Inc()
+			err = guestLost(machine.Wait, err)
 		}
 		return backend.WorkloadResult{}, fmt.Errorf("run workload: %w", err)
 	}
 
-	workloadsTotal.WithLabelValues(spec.Runtime, statusCompleted).Inc()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusKilled).Inc()
+	} else {
+		workloadsTotal.WithLabelValues(spec.Runtime, statusCompleted).Inc()
+	}
 
 	duration := time.Since(start)
 
@@ -269,22 +622,211 @@ func (b *Backend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backe
 		"duration_ms", duration.Milliseconds(),
 	)
 
-	return backend.WorkloadResult{
+	observeUsage(spec.Runtime, resp.Usage)
+
+	return withOutput(backend.WorkloadResult{
 		ExitCode:   resp.ExitCode,
-		Output:     []byte(resp.Output),
 		Error:      resp.Error,
 		DurationMS: int(duration.Milliseconds()),
 		LogLines:   resp.LogLines,
-	}, nil
+		Usage:      toModelUsage(resp.Usage),
+		ExitReason: resp.ExitReason,
+		ExitSignal: resp.ExitSignal,
+	}, resp, &stdout, &stderr), nil
+}
+
+// prepareDeps returns the dependency layer to attach for a workload whose
+// code archive has dependency manifests for its runtime, or nil if it has
+// none or the dependency install phase is disabled.
+func (b *Backend) prepareDeps(ctx context.Context, spec backend.WorkloadSpec, rootfsPath string) (*depsLayer, error) {
+	if b.deps == nil {
+		return nil, nil
+	}
+	manifests, err := findDepsManifests(spec.Runtime, spec.CodeArchive)
+	if err != nil || manifests == nil {
+		return nil, err
+	}
+	id, err := rootfsID(rootfsPath, spec.Image)
+	if err != nil {
+		return nil, fmt.Errorf("identify rootfs: %w", err)
+	}
+	return b.deps.prepare(ctx, depsKey(spec.Runtime, id, manifests))
+}
+
+// commitDeps caches the dependency layer the guest has just installed for
+// workload id, before it runs any of the workload's code.
+func (b *Backend) commitDeps(id string, deps *depsLayer) {
+	if err := b.deps.commit(deps); err != nil {
+		b.logger.Warn("failed to cache dependency layer", "workload_id", id, "error", err)
+		return
+	}
+	b.logger.Info("dependency layer cached", "workload_id", id, "key", deps.key)
 }
 
-// Capabilities reports what this backend supports.
+// toModelUsage converts resource usage reported by the guest agent.
+func toModelUsage(u *ResourceUsage) *model.ResourceUsage {
+	if u == nil {
+		return nil
+	}
+	return &model.ResourceUsage{
+		CPUUserMS:     u.CPUUserMS,
+		CPUSysMS:      u.CPUSysMS,
+		PeakRSSKB:     u.PeakRSSKB,
+		IOReadBytes:   u.IOReadBytes,
+		IOWriteBytes:  u.IOWriteBytes,
+		PeakMemoryKB:  u.PeakMemoryKB,
+		DiskUsedBytes: u.DiskUsedBytes,
+	}
+}
+
+// Signal delivers the named signal to the process group of a running
+// workload via its guest agent. Agents without the control feature cannot
+// receive signals.
+func (b *Backend) Signal(_ context.Context, workloadID, signal string) error {
+	b.mu.Lock()
+	state, ok := b.activeVMs[workloadID]
+	var gc *GuestConn
+	var agent GuestHello
+	if ok {
+		gc, agent = state.guest, state.agent
+	} else if x, ok := b.execs[workloadID]; ok {
+		gc, agent = x.gc, x.agent
+	}
+	b.mu.Unlock()
+
+	if gc == nil {
+		return backend.ErrNotRunning
+	}
+	if !agent.HasFeature(FeatureControl) {
+		return fmt.Errorf("guest agent %s: %w", agent.AgentVersion, backend.ErrUnsupported)
+	}
+	return gc.Signal(signal)
+}
+
+// stopGuest stops a cancelled or timed-out workload. Agents with the
+// control feature terminate the workload's process group (SIGTERM, then
+// SIGKILL after grace) and still report a result, so the connection
+// deadline is moved to leave room for it. Older agents cannot be told to
+// stop, so the connection is closed and the VM is torn down with the
+// workload in it.
+func (b *Backend) stopGuest(workloadID string, gc *GuestConn, agent GuestHello, grace time.Duration) {
+	if !agent.HasFeature(FeatureControl) {
+		gc.Close()
+		return
+	}
+
+	b.logger.Info("stopping workload", "workload_id", workloadID, "grace", grace)
+	if err := gc.SetDeadline(time.Now().Add(grace + cancelResultMargin)); err != nil {
+		b.logger.Warn("extend guest deadline", "workload_id", workloadID, "error", err)
+	}
+	if err := gc.Cancel(grace); err != nil {
+		b.logger.Warn("send cancel to guest", "workload_id", workloadID, "error", err)
+		gc.Close()
+	}
+}
+
+// startIngress starts forwarding a host port to the workload's exposed port
+// on the guest's bridge address.
+func (b *Backend) startIngress(spec backend.WorkloadSpec, netCfg *NetworkConfig) (*ingressProxy, error) {
+	prefix, err := netip.ParsePrefix(netCfg.GuestIP)
+	if err != nil {
+		return nil, fmt.Errorf("parse guest IP %q: %w", netCfg.GuestIP, err)
+	}
+	ingress, err := startIngress(b.cfg.IngressHost, prefix.Addr(), spec.ExposePort,
+		b.logger.With("workload_id", spec.ID))
+	if err != nil {
+		return nil, err
+	}
+	b.logger.Info("workload port exposed", "workload_id", spec.ID, "port", spec.ExposePort, "url", ingress.URL())
+	return ingress, nil
+}
+
+// Capabilities reports what this backend supports, including the guest agent
+// versions observed in each rootfs image so far.
 func (b *Backend) Capabilities() backend.BackendCapabilities {
+	b.agentsMu.Lock()
+	agents := make([]backend.AgentInfo, 0, len(b.agents))
+	for _, info := range b.agents {
+		agents = append(agents, info)
+	}
+	b.agentsMu.Unlock()
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].Image < agents[j].Image
+	})
+
 	return backend.BackendCapabilities{
 		Name:                BackendName,
-		SupportedRuntimes:   SupportedRuntimes,
+		SupportedRuntimes:   b.SupportedRuntimes(),
 		SupportedIsolations: []string{model.IsolationMicroVM},
 		MaxConcurrency:      b.cfg.MaxConcurrentVMs,
+		NetworkModes:        model.NetworkModes,
+		Ingress:             true,
+		NetworkGroups:       b.netMgr.GroupsEnabled(),
+		RateLimits:          true,
+		Images:              true,
+		Volumes:             true,
+		ScratchDisks:        true,
+		Services:            true,
+		Sessions:            true,
+		Agents:              agents,
+	}
+}
+
+// SupportedRuntimes returns the runtimes provided by the rootfs images in the
+// configured rootfs directory, as declared by their runtime manifests. The
+// images are scanned once, by NewBackend; images added later are not seen
+// until the backend is created again.
+func (b *Backend) SupportedRuntimes() []string {
+	return slices.Clone(b.runtimes)
+}
+
+// connectGuest dials the guest agent and performs the handshake. Legacy
+// agents that predate the handshake are redialled and driven without
+// optional features; incompatible agents are rejected. The agent's
+// capabilities are recorded against the rootfs image for Capabilities.
+func (b *Backend) connectGuest(ctx context.Context, image, vsockPath string) (*GuestConn, GuestHello, error) {
+	gc, err := DialGuest(ctx, vsockPath, b.cfg.VsockPort)
+	if err != nil {
+		return nil, GuestHello{}, err
+	}
+
+	info, err := gc.Handshake()
+	switch {
+	case errors.Is(err, ErrLegacyAgent):
+		gc.Close()
+		b.logger.Warn("guest agent predates handshake, running without optional features", "image", image)
+		gc, err = DialGuest(ctx, vsockPath, b.cfg.VsockPort)
+		if err != nil {
+			return nil, GuestHello{}, err
+		}
+		info = LegacyAgent
+	case errors.Is(err, ErrIncompatibleAgent):
+		gc.Close()
+		b.recordAgent(image, info)
+		return nil, GuestHello{}, err
+	case err != nil:
+		gc.Close()
+		return nil, GuestHello{}, fmt.Errorf("handshake: %w", err)
+	}
+
+	b.recordAgent(image, info)
+	return gc, info, nil
+}
+
+// recordAgent stores the agent capabilities observed for a rootfs image.
+func (b *Backend) recordAgent(image string, info GuestHello) {
+	b.agentsMu.Lock()
+	defer b.agentsMu.Unlock()
+	b.agents[image] = backend.AgentInfo{
+		Image:           image,
+		AgentVersion:    info.AgentVersion,
+		ProtocolVersion: info.ProtocolVersion,
+		Runtimes:        info.Runtimes,
+		Features:        info.Features,
+		Kernel:          info.Kernel,
+		Toolchains:      info.Toolchains,
+		MaxExecs:        info.MaxExecs,
+		ObservedAt:      time.Now().UTC(),
 	}
 }
 
@@ -305,6 +847,10 @@ func (b *Backend) Cleanup(ctx context.Context, workloadID string) error {
 
 // Shutdown gracefully stops all active VMs and cleans up.
 func (b *Backend) Shutdown(ctx context.Context) {
+	if b.stopSweeper != nil {
+		b.stopSweeper()
+	}
+
 	b.mu.Lock()
 	ids := make([]string, 0, len(b.activeVMs))
 	for id := range b.activeVMs {
@@ -336,6 +882,12 @@ func (b *Backend) stopAndCleanup(_ context.Context, workloadID string, state *vm
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracefulShutdownTimeout)
 	defer cancel()
 
+	if state.metrics != nil {
+		if err := state.metrics.Flush(shutdownCtx); err != nil {
+			b.logger.Debug("flush VMM metrics", "workload_id", workloadID, "error", err)
+		}
+	}
+
 	if err := state.machine.Shutdown(shutdownCtx); err != nil {
 		b.logger.Debug("graceful shutdown failed, forcing stop", "workload_id", workloadID, "error", err)
 		if stopErr := state.machine.StopVMM(); stopErr != nil {
@@ -353,6 +905,14 @@ func (b *Backend) stopAndCleanup(_ context.Context, workloadID string, state *vm
 	if state.started {
 		activeVMs.Dec()
 	}
+	if state.metrics != nil {
+		state.metrics.Close(metricsDrainTimeout)
+	}
+
+	// Drop forwarded connections before the guest's address is released.
+	if state.ingress != nil {
+		state.ingress.Close()
+	}
 
 	// Release CID.
 	b.releaseCID(state.cid)
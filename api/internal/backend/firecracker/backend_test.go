@@ -1,13 +1,18 @@
 package firecracker
 
 import (
+	"bufio"
 	"context"
+	"io"
+	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/seantiz/vulcan/internal/backend"
 	"github.com/seantiz/vulcan/internal/model"
@@ -20,6 +25,7 @@ func TestCapabilities(t *testing.T) {
 			DefaultMemMB:     DefaultMemMB,
 			MaxConcurrentVMs: MaxConcurrentVMs,
 		},
+		runtimes: []string{"node", "python", "ruby"},
 	}
 
 	caps := b.Capabilities()
@@ -28,14 +34,8 @@ func TestCapabilities(t *testing.T) {
 		t.Errorf("Name = %q, want %q", caps.Name, BackendName)
 	}
 
-	if len(caps.SupportedRuntimes) != len(SupportedRuntimes) {
-		t.Errorf("SupportedRuntimes length = %d, want %d",
-			len(caps.SupportedRuntimes), len(SupportedRuntimes))
-	}
-	for i, rt := range caps.SupportedRuntimes {
-		if rt != SupportedRuntimes[i] {
-			t.Errorf("SupportedRuntimes[%d] = %q, want %q", i, rt, SupportedRuntimes[i])
-		}
+	if !slices.Equal(caps.SupportedRuntimes, b.runtimes) {
+		t.Errorf("SupportedRuntimes = %v, want the discovered %v", caps.SupportedRuntimes, b.runtimes)
 	}
 
 	if len(caps.SupportedIsolations) != 1 || caps.SupportedIsolations[0] != model.IsolationMicroVM {
@@ -45,6 +45,22 @@ func TestCapabilities(t *testing.T) {
 	if caps.MaxConcurrency != MaxConcurrentVMs {
 		t.Errorf("MaxConcurrency = %d, want %d", caps.MaxConcurrency, MaxConcurrentVMs)
 	}
+
+	if !caps.RateLimits {
+		t.Error("RateLimits = false, want true")
+	}
+	if !caps.Volumes {
+		t.Error("Volumes = false, want true")
+	}
+	if !caps.ScratchDisks {
+		t.Error("ScratchDisks = false, want true")
+	}
+	if !caps.Services {
+		t.Error("Services = false, want true")
+	}
+	if !caps.Sessions {
+		t.Error("Sessions = false, want true")
+	}
 }
 
 func TestCapabilitiesCustomConcurrency(t *testing.T) {
@@ -242,3 +258,96 @@ func TestDefaultBootArgs(t *testing.T) {
 func containsArg(args, arg string) bool {
 	return slices.Contains(strings.Fields(args), arg)
 }
+
+func TestConnectGuestLegacyAgentRedials(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "vsock.sock")
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	// acceptConnect accepts one connection and completes the CONNECT handshake.
+	acceptConnect := func() (net.Conn, *bufio.Reader) {
+		conn, err := l.Accept()
+		if err != nil {
+			return nil, nil
+		}
+		r := bufio.NewReader(conn)
+		if _, err := r.ReadString('\n'); err != nil {
+			conn.Close()
+			return nil, nil
+		}
+		conn.Write([]byte("OK 1024\n"))
+		return conn, r
+	}
+
+	// Mock legacy guest: the first connection receives the hello and replies
+	// with an error result; the second stays open for the workload.
+	second := make(chan net.Conn, 1)
+	go func() {
+		conn, r := acceptConnect()
+		if conn == nil {
+			return
+		}
+		var req GuestRequest
+		ReadMessage(r, &req)
+		WriteMessage(conn, &GuestMessage{Type: MsgTypeResult, Response: &GuestResponse{ExitCode: 1}})
+		conn.Close()
+
+		conn, _ = acceptConnect()
+		second <- conn
+	}()
+
+	b := &Backend{
+		cfg:    Config{VsockPort: DefaultVsockPort},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		agents: make(map[string]backend.AgentInfo),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	gc, info, err := b.connectGuest(ctx, "python", sockPath)
+	if err != nil {
+		t.Fatalf("connectGuest: %v", err)
+	}
+	defer gc.Close()
+	if conn := <-second; conn != nil {
+		defer conn.Close()
+	} else {
+		t.Fatal("expected a second connection after legacy handshake")
+	}
+
+	if info.ProtocolVersion != LegacyProtocolVersion {
+		t.Errorf("ProtocolVersion = %d, want %d", info.ProtocolVersion, LegacyProtocolVersion)
+	}
+	if info.HasFeature(FeatureChunkedIO) {
+		t.Error("legacy agent should not advertise chunked I/O")
+	}
+
+	caps := b.Capabilities()
+	if len(caps.Agents) != 1 {
+		t.Fatalf("Agents = %d, want 1", len(caps.Agents))
+	}
+	if caps.Agents[0].Image != "python" || caps.Agents[0].AgentVersion != LegacyAgent.AgentVersion {
+		t.Errorf("Agents[0] = %+v, want python image with legacy agent", caps.Agents[0])
+	}
+}
+
+func TestCapabilitiesReportsAgentsSorted(t *testing.T) {
+	b := &Backend{agents: make(map[string]backend.AgentInfo)}
+	b.recordAgent("python", GuestHello{AgentVersion: "v2", ProtocolVersion: ProtocolVersion})
+	b.recordAgent("go", GuestHello{AgentVersion: "v1", ProtocolVersion: ProtocolVersion})
+
+	caps := b.Capabilities()
+	if len(caps.Agents) != 2 {
+		t.Fatalf("Agents = %d, want 2", len(caps.Agents))
+	}
+	if caps.Agents[0].Image != "go" || caps.Agents[1].Image != "python" {
+		t.Errorf("Agents order = [%s %s], want [go python]", caps.Agents[0].Image, caps.Agents[1].Image)
+	}
+	if caps.Agents[1].AgentVersion != "v2" || caps.Agents[1].ObservedAt.IsZero() {
+		t.Errorf("Agents[1] = %+v, want version v2 with observation time", caps.Agents[1])
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Environment variable names for Firecracker configuration.
@@ -16,8 +17,28 @@ const (
 	envVsockPort       = "VULCAN_FC_VSOCK_PORT"
 	envMaxConcurrent   = "VULCAN_FC_MAX_CONCURRENT_VMS"
 	envJailer          = "VULCAN_FC_JAILER"
+	envNFTBin          = "VULCAN_FC_NFT_BIN"
+	envBridge          = "VULCAN_FC_BRIDGE"
+	envSubnets         = "VULCAN_FC_SUBNETS"
+	envGateway         = "VULCAN_FC_GATEWAY"
+	envMTU             = "VULCAN_FC_MTU"
+	envDNS             = "VULCAN_FC_DNS"
+	envIngressHost     = "VULCAN_FC_INGRESS_HOST"
+	envGroupSubnets    = "VULCAN_FC_GROUP_SUBNETS"
+	envSweepInterval   = "VULCAN_FC_SWEEP_INTERVAL"
+	envDepsMirrorDir   = "VULCAN_FC_DEPS_MIRROR_DIR"
+	envDepsCacheDir    = "VULCAN_FC_DEPS_CACHE_DIR"
+	envDepsDiskMB      = "VULCAN_FC_DEPS_DISK_MB"
+	envGoBuildCacheDir = "VULCAN_FC_GO_BUILD_CACHE_DIR"
+	envPackExecs       = "VULCAN_FC_PACK_EXECS"
 )
 
+// DefaultNFTBin is the nftables CLI used to enforce network policies.
+const DefaultNFTBin = "nft"
+
+// DefaultIngressHost is the address exposed workload ports listen on.
+const DefaultIngressHost = "127.0.0.1"
+
 // Config holds configuration for the Firecracker microVM backend.
 type Config struct {
 	// KernelPath is the path to the Firecracker-compatible kernel image.
@@ -35,6 +56,36 @@ type Config struct {
 	// CNIBinDir is the path to CNI plugin binaries.
 	CNIBinDir string
 
+	// NFTBin is the nftables CLI used to enforce per-VM network policies.
+	NFTBin string
+
+	// BridgeName is the Linux bridge microVM interfaces attach to.
+	BridgeName string
+
+	// Subnets are the CIDR pools VM addresses are allocated from. At least
+	// one must be IPv4; adding an IPv6 pool makes VMs dual-stack.
+	Subnets []string
+
+	// Gateway is the bridge address on the first IPv4 subnet. Empty means
+	// the subnet's first host address, which is also used for other pools.
+	Gateway string
+
+	// MTU is the bridge and guest interface MTU. Zero keeps the default of 1500.
+	MTU int
+
+	// Nameservers are the DNS servers handed to guests (at most two).
+	Nameservers []string
+
+	// GroupSubnets is the IPv4 range each network group's private subnet is
+	// carved from. Empty disables network groups; set the environment
+	// variable to "none" to do so.
+	GroupSubnets string
+
+	// IngressHost is the host address exposed workload ports listen on.
+	// An unspecified address (0.0.0.0 or ::) listens on every interface and
+	// advertises the machine's host name in endpoint URLs.
+	IngressHost string
+
 	// VsockPort is the guest agent vsock port.
 	VsockPort uint32
 
@@ -52,6 +103,31 @@ type Config struct {
 
 	// MaxConcurrentVMs is the maximum number of concurrent microVMs.
 	MaxConcurrentVMs int
+
+	// SweepInterval is how often resources leaked by VMs that are no longer
+	// running are removed. Zero disables sweeping.
+	SweepInterval time.Duration
+
+	// DepsMirrorDir is the local package mirror dependencies are installed
+	// from, with pip wheels under pip/ and an npm cache under npm/. Empty
+	// disables the dependency install phase.
+	DepsMirrorDir string
+
+	// DepsCacheDir is where installed dependency layers are cached, keyed by
+	// the digest of the manifests they were installed from.
+	DepsCacheDir string
+
+	// DepsDiskMB is the size of the blank drive dependencies are installed into.
+	DepsDiskMB int
+
+	// GoBuildCacheDir is where Go programs compiled by workloads are cached,
+	// keyed by code digest and toolchain version. Empty disables the cache,
+	// and Go code is compiled at every run.
+	GoBuildCacheDir string
+
+	// PackExecs is how many small workloads may share one microVM, whose
+	// guest agent runs them at once. Below 2, every workload gets its own VM.
+	PackExecs int
 }
 
 // LoadConfig reads Firecracker configuration from environment variables,
@@ -63,6 +139,16 @@ func LoadConfig() Config {
 		DefaultVCPUs:     DefaultVCPUs,
 		DefaultMemMB:     DefaultMemMB,
 		MaxConcurrentVMs: MaxConcurrentVMs,
+		NFTBin:           DefaultNFTBin,
+		BridgeName:       DefaultBridgeName,
+		Subnets:          []string{DefaultSubnet},
+		Nameservers:      append([]string(nil), DefaultNameservers...),
+		IngressHost:      DefaultIngressHost,
+		GroupSubnets:     DefaultGroupSubnets,
+		SweepInterval:    DefaultSweepInterval,
+		DepsCacheDir:     DefaultDepsCacheDir,
+		DepsDiskMB:       DefaultDepsDiskMB,
+		GoBuildCacheDir:  DefaultGoBuildCacheDir,
 	}
 
 	if v := os.Getenv(envKernelPath); v != "" {
@@ -80,6 +166,55 @@ func LoadConfig() Config {
 	if v := os.Getenv(envCNIBinDir); v != "" {
 		cfg.CNIBinDir = v
 	}
+	if v := os.Getenv(envNFTBin); v != "" {
+		cfg.NFTBin = v
+	}
+	if v := os.Getenv(envBridge); v != "" {
+		cfg.BridgeName = v
+	}
+	if v := os.Getenv(envSubnets); v != "" {
+		cfg.Subnets = splitList(v)
+	}
+	if v := os.Getenv(envGateway); v != "" {
+		cfg.Gateway = v
+	}
+	if v := os.Getenv(envMTU); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MTU = n
+		}
+	}
+	if v := os.Getenv(envDNS); v != "" {
+		cfg.Nameservers = splitList(v)
+	}
+	if v := os.Getenv(envGroupSubnets); v == "none" {
+		cfg.GroupSubnets = ""
+	} else if v != "" {
+		cfg.GroupSubnets = v
+	}
+	if v := os.Getenv(envSweepInterval); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.SweepInterval = d
+		}
+	}
+	if v := os.Getenv(envIngressHost); v != "" {
+		cfg.IngressHost = v
+	}
+	cfg.DepsMirrorDir = os.Getenv(envDepsMirrorDir)
+	if v := os.Getenv(envDepsCacheDir); v != "" {
+		cfg.DepsCacheDir = v
+	}
+	if v := os.Getenv(envDepsDiskMB); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.DepsDiskMB = n
+		}
+	}
+	switch v := os.Getenv(envGoBuildCacheDir); v {
+	case "":
+	case "none":
+		cfg.GoBuildCacheDir = ""
+	default:
+		cfg.GoBuildCacheDir = v
+	}
 	if v := os.Getenv(envVsockPort); v != "" {
 		if port, err := strconv.ParseUint(v, 10, 32); err == nil {
 			cfg.VsockPort = uint32(port)
@@ -90,9 +225,25 @@ func LoadConfig() Config {
 			cfg.MaxConcurrentVMs = n
 		}
 	}
+	if v := os.Getenv(envPackExecs); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.PackExecs = n
+		}
+	}
 	if v := os.Getenv(envJailer); v != "" {
 		cfg.JailerEnabled = strings.EqualFold(v, "true") || v == "1"
 	}
 
 	return cfg
 }
+
+// splitList splits a comma-separated environment value, dropping empty items.
+func splitList(v string) []string {
+	var items []string
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,14 +1,17 @@
 package firecracker
 
 import (
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestLoadConfigDefaults(t *testing.T) {
 	// Clear all FC env vars to ensure defaults.
 	for _, env := range []string{
 		envKernelPath, envRootfsDir, envBin,
-		envCNIConfigDir, envCNIBinDir, envVsockPort, envJailer,
+		envCNIConfigDir, envCNIBinDir, envVsockPort, envJailer, envNFTBin,
+		envBridge, envSubnets, envGateway, envMTU, envDNS, envIngressHost, envGroupSubnets,
 	} {
 		t.Setenv(env, "")
 	}
@@ -30,9 +33,30 @@ func TestLoadConfigDefaults(t *testing.T) {
 	if cfg.JailerEnabled {
 		t.Error("JailerEnabled should be false by default")
 	}
+	if cfg.NFTBin != DefaultNFTBin {
+		t.Errorf("NFTBin = %q, want %q", cfg.NFTBin, DefaultNFTBin)
+	}
+	if cfg.GroupSubnets != DefaultGroupSubnets {
+		t.Errorf("GroupSubnets = %q, want %q", cfg.GroupSubnets, DefaultGroupSubnets)
+	}
+	if cfg.IngressHost != DefaultIngressHost {
+		t.Errorf("IngressHost = %q, want %q", cfg.IngressHost, DefaultIngressHost)
+	}
 	if cfg.KernelPath != "" {
 		t.Errorf("KernelPath = %q, want empty", cfg.KernelPath)
 	}
+	if cfg.BridgeName != DefaultBridgeName {
+		t.Errorf("BridgeName = %q, want %q", cfg.BridgeName, DefaultBridgeName)
+	}
+	if len(cfg.Subnets) != 1 || cfg.Subnets[0] != DefaultSubnet {
+		t.Errorf("Subnets = %v, want [%s]", cfg.Subnets, DefaultSubnet)
+	}
+	if cfg.Gateway != "" || cfg.MTU != 0 {
+		t.Errorf("Gateway = %q, MTU = %d, want unset", cfg.Gateway, cfg.MTU)
+	}
+	if !slices.Equal(cfg.Nameservers, DefaultNameservers) {
+		t.Errorf("Nameservers = %v, want %v", cfg.Nameservers, DefaultNameservers)
+	}
 }
 
 func TestLoadConfigEnvOverrides(t *testing.T) {
@@ -69,6 +93,116 @@ func TestLoadConfigEnvOverrides(t *testing.T) {
 	}
 }
 
+func TestLoadConfigNetworkOverrides(t *testing.T) {
+	t.Setenv(envBridge, "vulcanbr0")
+	t.Setenv(envSubnets, "172.20.0.0/16, fd00:168::/64,")
+	t.Setenv(envGateway, "172.20.0.254")
+	t.Setenv(envMTU, "1400")
+	t.Setenv(envDNS, "192.0.2.53")
+
+	cfg := LoadConfig()
+
+	if cfg.BridgeName != "vulcanbr0" {
+		t.Errorf("BridgeName = %q, want vulcanbr0", cfg.BridgeName)
+	}
+	if !slices.Equal(cfg.Subnets, []string{"172.20.0.0/16", "fd00:168::/64"}) {
+		t.Errorf("Subnets = %v, want [172.20.0.0/16 fd00:168::/64]", cfg.Subnets)
+	}
+	if cfg.Gateway != "172.20.0.254" {
+		t.Errorf("Gateway = %q, want 172.20.0.254", cfg.Gateway)
+	}
+	if cfg.MTU != 1400 {
+		t.Errorf("MTU = %d, want 1400", cfg.MTU)
+	}
+	if !slices.Equal(cfg.Nameservers, []string{"192.0.2.53"}) {
+		t.Errorf("Nameservers = %v, want [192.0.2.53]", cfg.Nameservers)
+	}
+	if _, err := cfg.validateNetwork(); err != nil {
+		t.Errorf("validateNetwork: %v", err)
+	}
+}
+
+func TestLoadConfigGroupSubnets(t *testing.T) {
+	t.Setenv(envGroupSubnets, "172.30.0.0/16")
+	if cfg := LoadConfig(); cfg.GroupSubnets != "172.30.0.0/16" {
+		t.Errorf("GroupSubnets = %q, want 172.30.0.0/16", cfg.GroupSubnets)
+	}
+
+	t.Setenv(envGroupSubnets, "none")
+	if cfg := LoadConfig(); cfg.GroupSubnets != "" {
+		t.Errorf("GroupSubnets = %q, want disabled", cfg.GroupSubnets)
+	}
+}
+
+func TestLoadConfigSweepInterval(t *testing.T) {
+	if cfg := LoadConfig(); cfg.SweepInterval != DefaultSweepInterval {
+		t.Errorf("SweepInterval = %v, want default %v", cfg.SweepInterval, DefaultSweepInterval)
+	}
+
+	t.Setenv(envSweepInterval, "30s")
+	if cfg := LoadConfig(); cfg.SweepInterval != 30*time.Second {
+		t.Errorf("SweepInterval = %v, want 30s", cfg.SweepInterval)
+	}
+
+	t.Setenv(envSweepInterval, "0")
+	if cfg := LoadConfig(); cfg.SweepInterval != 0 {
+		t.Errorf("SweepInterval = %v, want disabled", cfg.SweepInterval)
+	}
+
+	t.Setenv(envSweepInterval, "often")
+	if cfg := LoadConfig(); cfg.SweepInterval != DefaultSweepInterval {
+		t.Errorf("SweepInterval = %v, want default for invalid value", cfg.SweepInterval)
+	}
+}
+
+func TestLoadConfigDeps(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.DepsMirrorDir != "" || cfg.DepsCacheDir != DefaultDepsCacheDir || cfg.DepsDiskMB != DefaultDepsDiskMB {
+		t.Errorf("deps config = %q, %q, %d, want disabled with defaults", cfg.DepsMirrorDir, cfg.DepsCacheDir, cfg.DepsDiskMB)
+	}
+
+	t.Setenv(envDepsMirrorDir, "/srv/mirror")
+	t.Setenv(envDepsCacheDir, "/var/cache/vulcan/deps")
+	t.Setenv(envDepsDiskMB, "2048")
+	cfg = LoadConfig()
+	if cfg.DepsMirrorDir != "/srv/mirror" || cfg.DepsCacheDir != "/var/cache/vulcan/deps" || cfg.DepsDiskMB != 2048 {
+		t.Errorf("deps config = %q, %q, %d, want the environment's", cfg.DepsMirrorDir, cfg.DepsCacheDir, cfg.DepsDiskMB)
+	}
+
+	t.Setenv(envDepsDiskMB, "-1")
+	if cfg := LoadConfig(); cfg.DepsDiskMB != DefaultDepsDiskMB {
+		t.Errorf("DepsDiskMB = %d, want default for invalid value", cfg.DepsDiskMB)
+	}
+}
+
+func TestLoadConfigGoBuildCache(t *testing.T) {
+	if cfg := LoadConfig(); cfg.GoBuildCacheDir != DefaultGoBuildCacheDir {
+		t.Errorf("GoBuildCacheDir = %q, want default", cfg.GoBuildCacheDir)
+	}
+	t.Setenv(envGoBuildCacheDir, "/var/cache/vulcan/go")
+	if cfg := LoadConfig(); cfg.GoBuildCacheDir != "/var/cache/vulcan/go" {
+		t.Errorf("GoBuildCacheDir = %q, want the environment's", cfg.GoBuildCacheDir)
+	}
+	t.Setenv(envGoBuildCacheDir, "none")
+	if cfg := LoadConfig(); cfg.GoBuildCacheDir != "" {
+		t.Errorf("GoBuildCacheDir = %q, want disabled", cfg.GoBuildCacheDir)
+	}
+}
+
+func TestLoadConfigPackExecs(t *testing.T) {
+	if cfg := LoadConfig(); cfg.PackExecs != 0 {
+		t.Errorf("PackExecs = %d, want packing off by default", cfg.PackExecs)
+	}
+	t.Setenv(envPackExecs, "4")
+	if cfg := LoadConfig(); cfg.PackExecs != 4 {
+		t.Errorf("PackExecs = %d, want 4", cfg.PackExecs)
+	}
+	t.Setenv(envPackExecs, "-2")
+	if cfg := LoadConfig(); cfg.PackExecs != 0 {
+		t.Errorf("PackExecs = %d, want packing off for an invalid value", cfg.PackExecs)
+	}
+}
+
 func TestLoadConfigJailerVariants(t *testing.T) {
 	tests := []struct {
 		value string
@@ -0,0 +1,81 @@
+package firecracker
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// consoleMaxLines is the number of serial console lines kept per VM;
+	// older lines are dropped.
+	consoleMaxLines = 500
+
+	// consoleMaxLineLen truncates console lines longer than this many bytes.
+	consoleMaxLineLen = 4096
+)
+
+// consoleBuffer captures a VM's serial console, which Firecracker writes to
+// its stdout, together with the VMM's own stderr. It keeps the last
+// consoleMaxLines lines so that boot failures can be diagnosed.
+type consoleBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	partial []byte
+	dropped int
+}
+
+// Write splits p into lines. It never fails.
+func (c *consoleBuffer) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			c.partial = append(c.partial, p...)
+			if len(c.partial) > consoleMaxLineLen {
+				c.add(c.partial)
+				c.partial = c.partial[:0]
+			}
+			break
+		}
+		c.partial = append(c.partial, p[:i]...)
+		c.add(c.partial)
+		c.partial = c.partial[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// add appends a complete line. The caller must hold c.mu.
+func (c *consoleBuffer) add(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) > consoleMaxLineLen {
+		line = line[:consoleMaxLineLen]
+	}
+	if len(c.lines) == consoleMaxLines {
+		c.lines = c.lines[1:]
+		c.dropped++
+	}
+	c.lines = append(c.lines, string(line))
+}
+
+// Lines returns the captured lines, including an unterminated last line. A
+// leading note records how many earlier lines were dropped.
+func (c *consoleBuffer) Lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines := make([]string, 0, len(c.lines)+2)
+	if c.dropped > 0 {
+		lines = append(lines, fmt.Sprintf("[%d earlier console lines dropped]", c.dropped))
+	}
+	lines = append(lines, c.lines...)
+	if tail := strings.TrimRight(string(c.partial), "\r"); tail != "" {
+		lines = append(lines, tail)
+	}
+	return lines
+}
@@ -0,0 +1,53 @@
+package firecracker
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConsoleBufferLines(t *testing.T) {
+	var c consoleBuffer
+	fmt.Fprint(&c, "[    0.000000] Linux version 5.10\r\n[    0.1")
+	fmt.Fprint(&c, "00000] Command line: console=ttyS0\n")
+	fmt.Fprint(&c, "Kernel panic - not syncing")
+
+	want := []string{
+		"[    0.000000] Linux version 5.10",
+		"[    0.100000] Command line: console=ttyS0",
+		"Kernel panic - not syncing",
+	}
+	if got := c.Lines(); !slices.Equal(got, want) {
+		t.Errorf("Lines = %q, want %q", got, want)
+	}
+}
+
+func TestConsoleBufferDropsOldLines(t *testing.T) {
+	var c consoleBuffer
+	for i := range consoleMaxLines + 3 {
+		fmt.Fprintf(&c, "line %d\n", i)
+	}
+
+	lines := c.Lines()
+	if len(lines) != consoleMaxLines+1 {
+		t.Fatalf("len(Lines) = %d, want %d", len(lines), consoleMaxLines+1)
+	}
+	if lines[0] != "[3 earlier console lines dropped]" {
+		t.Errorf("Lines[0] = %q, want a dropped-lines note", lines[0])
+	}
+	if lines[1] != "line 3" || lines[len(lines)-1] != fmt.Sprintf("line %d", consoleMaxLines+2) {
+		t.Errorf("Lines = %q ... %q, want line 3 ... line %d", lines[1], lines[len(lines)-1], consoleMaxLines+2)
+	}
+}
+
+func TestConsoleBufferTruncatesLongLines(t *testing.T) {
+	var c consoleBuffer
+	c.Write([]byte(strings.Repeat("x", 3*consoleMaxLineLen)))
+
+	for i, line := range c.Lines() {
+		if len(line) > consoleMaxLineLen {
+			t.Errorf("Lines[%d] has %d bytes, want at most %d", i, len(line), consoleMaxLineLen)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package firecracker
 import (
 	"fmt"
 	"path/filepath"
-	"slices"
+	"time"
 )
 
 // Default vsock settings.
@@ -21,9 +21,6 @@ const (
 	DefaultMemMB = 512
 )
 
-// SupportedRuntimes lists the runtimes available in pre-built rootfs images.
-var SupportedRuntimes = []string{"go", "node", "python"}
-
 // RootfsFilename is the format string for rootfs image filenames (e.g. "go.ext4").
 const RootfsFilename = "%s.ext4"
 
@@ -36,17 +33,34 @@ const (
 	GuestAgentPath = "/usr/local/bin/vulcan-guest"
 )
 
+// Control channel defaults.
+const (
+	// HeartbeatInterval is how often guest agents that support the control
+	// feature report the workload's process state.
+	HeartbeatInterval = time.Second
+
+	// HeartbeatMisses is the number of consecutive heartbeat intervals
+	// without any message after which the guest is considered hung.
+	HeartbeatMisses = 5
+
+	// DefaultGracePeriod is the time a timed-out or cancelled workload's
+	// process group has between SIGTERM and SIGKILL, unless the workload
+	// asks for another.
+	DefaultGracePeriod = 5 * time.Second
+
+	// cancelResultMargin is the extra time allowed after the grace period
+	// for the guest to deliver the stopped workload's result.
+	cancelResultMargin = 2 * time.Second
+)
+
 // MaxConcurrentVMs is the default maximum number of concurrent microVMs.
 const MaxConcurrentVMs = 10
 
 // RootfsPath returns the full path to the rootfs image for a given runtime.
+// Whether the image exists and provides the runtime is up to DiscoverRuntimes.
 func RootfsPath(rootfsDir, runtime string) (string, error) {
-	if !isSupportedRuntime(runtime) {
-		return "", fmt.Errorf("unsupported runtime %q: must be one of %v", runtime, SupportedRuntimes)
+	if !validRuntimeName(runtime) {
+		return "", fmt.Errorf("invalid runtime name %q", runtime)
 	}
 	return filepath.Join(rootfsDir, fmt.Sprintf(RootfsFilename, runtime)), nil
 }
-
-func isSupportedRuntime(runtime string) bool {
-	return slices.Contains(SupportedRuntimes, runtime)
-}
@@ -13,6 +13,7 @@ func TestRootfsPathSupported(t *testing.T) {
 		{"go", "/images/go.ext4"},
 		{"node", "/images/node.ext4"},
 		{"python", "/images/python.ext4"},
+		{"ruby", "/images/ruby.ext4"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.runtime, func(t *testing.T) {
@@ -27,12 +28,14 @@ func TestRootfsPathSupported(t *testing.T) {
 	}
 }
 
-func TestRootfsPathUnsupported(t *testing.T) {
-	_, err := RootfsPath("/images", "ruby")
-	if err == nil {
-		t.Fatal("expected error for unsupported runtime")
-	}
-	if !strings.Contains(err.Error(), "unsupported runtime") {
-		t.Errorf("error = %q, want it to contain 'unsupported runtime'", err.Error())
+func TestRootfsPathInvalid(t *testing.T) {
+	for _, runtime := range []string{"", "../etc/passwd", "python@3.12", "Ruby"} {
+		_, err := RootfsPath("/images", runtime)
+		if err == nil {
+			t.Fatalf("RootfsPath(%q): expected error for invalid runtime name", runtime)
+		}
+		if !strings.Contains(err.Error(), "invalid runtime name") {
+			t.Errorf("RootfsPath(%q) error = %q, want it to contain 'invalid runtime name'", runtime, err.Error())
+		}
 	}
 }
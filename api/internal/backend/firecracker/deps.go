@@ -0,0 +1,338 @@
+package firecracker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// Dependency layer defaults.
+const (
+	DefaultDepsCacheDir = "deps-cache"
+	DefaultDepsDiskMB   = 1024
+)
+
+// Drives attached for the dependency phase. They follow the rootfs, which is
+// /dev/vda in the guest, in this order.
+const (
+	depsDriveID       = "deps"
+	mirrorDriveID     = "mirror"
+	depsGuestDevice   = "/dev/vdb"
+	mirrorGuestDevice = "/dev/vdc"
+)
+
+// Files in the dependency cache directory: cached layers are named
+// "<key>.ext4", layers being installed "<installPrefix><key>-*.ext4", and
+// images of the package mirror "<mirrorPrefix><fingerprint>.ext4".
+const (
+	depsLayerExt      = ".ext4"
+	depsInstallPrefix = ".install-"
+	depsMirrorPrefix  = "mirror-"
+)
+
+// Metric label values for dependency cache lookups.
+const (
+	depsCacheHit  = "hit"
+	depsCacheMiss = "miss"
+)
+
+// mirrorFreeBytes is the slack added to package mirror images.
+const mirrorFreeBytes = 16 << 20
+
+// depsManifests lists the dependency manifests recognised at the root of a
+// workload's code archive, by runtime. The first is required; the others,
+// such as lock files, are part of the cache key when present.
+var depsManifests = map[string][]string{
+	model.RuntimePython: {"requirements.txt"},
+	model.RuntimeNode:   {"package.json", "package-lock.json"},
+}
+
+// findDepsManifests returns the dependency manifests for runtime at the root
+// of a tar.gz code archive, keyed by name, or nil if it has none.
+func findDepsManifests(runtime string, archive []byte) (map[string][]byte, error) {
+	names := depsManifests[runtime]
+	if len(names) == 0 || len(archive) == 0 {
+		return nil, nil
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer gz.Close()
+
+	manifests := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if hdr.Typeflag != tar.TypeReg || !slices.Contains(names, name) {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, MaxMessageSize))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		manifests[name] = data
+	}
+	if _, ok := manifests[names[0]]; !ok {
+		return nil, nil
+	}
+	return manifests, nil
+}
+
+// depsKey returns the cache key of the dependencies installed from manifests
+// for runtime in the rootfs identified by rootfsID. Layers built in another
+// rootfs may hold native extensions for a different interpreter, so the
+// rootfs is part of the key.
+func depsKey(runtime, rootfsID string, manifests map[string][]byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", runtime, rootfsID)
+	names := make([]string, 0, len(manifests))
+	for name := range manifests {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(manifests[name]))
+		h.Write(manifests[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// rootfsID identifies the contents of a rootfs image: a catalog image by its
+// digest, and a runtime image by its size and modification time, which
+// change whenever it is rebuilt.
+func rootfsID(rootfsPath string, img *model.Image) (string, error) {
+	if img != nil {
+		return img.Digest, nil
+	}
+	info, err := os.Stat(rootfsPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d:%d", filepath.Base(rootfsPath), info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// depsCache stores dependency layers, ext4 images holding the dependencies
+// installed from a set of manifests, and the image of the package mirror
+// they are installed from.
+type depsCache struct {
+	dir       string
+	mirrorDir string
+	mkfsBin   string
+	diskMB    int
+
+	mu sync.Mutex // serializes mirror image builds
+}
+
+// newDepsCache creates the dependency cache described by cfg, or returns nil
+// if no package mirror is configured. Layers left half-installed by an
+// earlier process are removed.
+func newDepsCache(cfg Config) (*depsCache, error) {
+	if cfg.DepsMirrorDir == "" {
+		return nil, nil
+	}
+	if info, err := os.Stat(cfg.DepsMirrorDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("package mirror %s is not a directory", cfg.DepsMirrorDir)
+	}
+	if err := os.MkdirAll(cfg.DepsCacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create deps cache dir: %w", err)
+	}
+	dir, err := filepath.Abs(cfg.DepsCacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve deps cache dir: %w", err)
+	}
+	stale, _ := filepath.Glob(filepath.Join(dir, depsInstallPrefix+"*"))
+	for _, p := range stale {
+		os.Remove(p)
+	}
+	return &depsCache{dir: dir, mirrorDir: cfg.DepsMirrorDir, mkfsBin: DefaultMkfsBin, diskMB: cfg.DepsDiskMB}, nil
+}
+
+// depsLayer is the dependency layer attached to a VM.
+type depsLayer struct {
+	key  string
+	path string
+
+	// install indicates that path is a blank filesystem to install the
+	// dependencies into from the package mirror image at mirror.
+	install bool
+	mirror  string
+}
+
+// prepare returns the cached layer for key or, on a miss, a blank layer to
+// install into.
+func (c *depsCache) prepare(ctx context.Context, key string) (*depsLayer, error) {
+	cached := filepath.Join(c.dir, key+depsLayerExt)
+	if _, err := os.Stat(cached); err == nil {
+		depsCacheTotal.WithLabelValues(depsCacheHit).Inc()
+		return &depsLayer{key: key, path: cached}, nil
+	}
+	depsCacheTotal.WithLabelValues(depsCacheMiss).Inc()
+
+	mirror, err := c.mirrorImage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("package mirror image: %w", err)
+	}
+	f, err := os.CreateTemp(c.dir, depsInstallPrefix+key+"-*"+depsLayerExt)
+	if err != nil {
+		return nil, fmt.Errorf("create deps layer: %w", err)
+	}
+	layer := &depsLayer{key: key, path: f.Name(), install: true, mirror: mirror}
+	err = f.Truncate(int64(c.diskMB) << 20)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = runMkfs(ctx, c.mkfsBin, layer.path, "")
+	}
+	if err != nil {
+		os.Remove(layer.path)
+		return nil, fmt.Errorf("create deps layer: %w", err)
+	}
+	return layer, nil
+}
+
+// commit copies a layer the guest installed into the cache. The layer stays
+// attached to the VM, writable, while its workload runs, so the cache gets a
+// copy taken before then rather than the file itself. Concurrent installs of
+// the same key are harmless: the last one replaces the others.
+func (c *depsCache) commit(layer *depsLayer) error {
+	f, err := os.CreateTemp(c.dir, depsInstallPrefix+layer.key+"-*"+depsLayerExt)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+	if err := copyRootfs(layer.path, tmp); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0o444); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(c.dir, layer.key+depsLayerExt))
+}
+
+// discard removes a layer being installed that will not be cached. Cached
+// layers are left alone.
+func (c *depsCache) discard(layer *depsLayer) {
+	if layer.install {
+		os.Remove(layer.path)
+	}
+}
+
+// mirrorImage returns an ext4 image of the package mirror, building it when
+// the mirror has changed since the last build. Older images are removed;
+// VMs still using them keep them open.
+func (c *depsCache) mirrorImage(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fingerprint, err := treeFingerprint(c.mirrorDir)
+	if err != nil {
+		return "", err
+	}
+	image := filepath.Join(c.dir, depsMirrorPrefix+fingerprint+depsLayerExt)
+	if _, err := os.Stat(image); err == nil {
+		return image, nil
+	}
+
+	used, _, err := treeUsage(c.mirrorDir)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(c.dir, depsInstallPrefix+depsMirrorPrefix+"*"+depsLayerExt)
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+	err = f.Truncate(used + used/4 + mirrorFreeBytes)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := runMkfs(ctx, c.mkfsBin, tmp, c.mirrorDir); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, image); err != nil {
+		return "", err
+	}
+
+	old, _ := filepath.Glob(filepath.Join(c.dir, depsMirrorPrefix+"*"+depsLayerExt))
+	for _, p := range old {
+		if p != image {
+			os.Remove(p)
+		}
+	}
+	return image, nil
+}
+
+// runMkfs creates an ext4 filesystem filling the file at image, populated
+// from the tree at dir unless dir is empty.
+func runMkfs(ctx context.Context, mkfsBin, image, dir string) error {
+	args := []string{"-q", "-F", "-t", "ext4"}
+	if dir != "" {
+		args = append(args, "-d", dir)
+	}
+	out, err := exec.CommandContext(ctx, mkfsBin, append(args, image)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", mkfsBin, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// treeFingerprint hashes the names, sizes, modes and modification times of
+// the files in the tree at dir.
+func treeFingerprint(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%o\x00%s\x00", rel, info.Size(), info.Mode(), strconv.FormatInt(info.ModTime().UnixNano(), 10))
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("package mirror %s: %w", dir, err)
+		}
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32], nil
+}
@@ -0,0 +1,234 @@
+package firecracker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// tarGz builds a tar.gz archive holding files.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestFindDepsManifests(t *testing.T) {
+	archive := tarGz(t, map[string]string{
+		"./package.json":       `{"name":"app"}`,
+		"package-lock.json":    `{"lockfileVersion":3}`,
+		"lib/requirements.txt": "nested",
+		"index.js":             "",
+	})
+
+	got, err := findDepsManifests(model.RuntimeNode, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || string(got["package.json"]) != `{"name":"app"}` || got["package-lock.json"] == nil {
+		t.Errorf("node manifests = %q", got)
+	}
+
+	// The requirements file must be at the root.
+	if got, err := findDepsManifests(model.RuntimePython, archive); err != nil || got != nil {
+		t.Errorf("python manifests = %q, %v, want none", got, err)
+	}
+	if got, err := findDepsManifests(model.RuntimeGo, archive); err != nil || got != nil {
+		t.Errorf("go manifests = %q, %v, want none", got, err)
+	}
+	if got, err := findDepsManifests(model.RuntimeNode, nil); err != nil || got != nil {
+		t.Errorf("manifests without archive = %q, %v, want none", got, err)
+	}
+
+	// A lock file alone does not start a dependency phase.
+	lockOnly := tarGz(t, map[string]string{"package-lock.json": "{}"})
+	if got, err := findDepsManifests(model.RuntimeNode, lockOnly); err != nil || got != nil {
+		t.Errorf("lock-only manifests = %q, %v, want none", got, err)
+	}
+
+	if _, err := findDepsManifests(model.RuntimeNode, []byte("not gzip")); err == nil {
+		t.Error("expected error for a corrupt archive")
+	}
+}
+
+func TestDepsKey(t *testing.T) {
+	manifests := map[string][]byte{"package.json": []byte("{}"), "package-lock.json": []byte("{}")}
+	key := depsKey(model.RuntimeNode, "digest", manifests)
+	if len(key) != 64 {
+		t.Fatalf("key = %q, want a hex sha256", key)
+	}
+
+	changes := map[string]string{
+		"runtime": depsKey(model.RuntimePython, "digest", manifests),
+		"rootfs":  depsKey(model.RuntimeNode, "other", manifests),
+		"lock":    depsKey(model.RuntimeNode, "digest", map[string][]byte{"package.json": []byte("{}")}),
+		"content": depsKey(model.RuntimeNode, "digest", map[string][]byte{"package.json": []byte("{}"), "package-lock.json": []byte("[]")}),
+	}
+	for name, other := range changes {
+		if other == key {
+			t.Errorf("key unchanged by a different %s", name)
+		}
+	}
+	if again := depsKey(model.RuntimeNode, "digest", manifests); again != key {
+		t.Errorf("key = %q then %q, want stable", key, again)
+	}
+}
+
+func TestRootfsID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "python.ext4")
+	os.WriteFile(path, []byte("image"), 0o644)
+
+	if id, err := rootfsID(path, &model.Image{Digest: "abc"}); err != nil || id != "abc" {
+		t.Errorf("catalog image id = %q, %v, want its digest", id, err)
+	}
+	id, err := rootfsID(path, nil)
+	if err != nil || !strings.HasPrefix(id, "python.ext4:5:") {
+		t.Fatalf("runtime image id = %q, %v", id, err)
+	}
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	if rebuilt, _ := rootfsID(path, nil); rebuilt == id {
+		t.Error("id unchanged after the image was rebuilt")
+	}
+	if _, err := rootfsID(filepath.Join(t.TempDir(), "missing.ext4"), nil); err == nil {
+		t.Error("expected error for a missing image")
+	}
+}
+
+func TestNewDepsCache(t *testing.T) {
+	if c, err := newDepsCache(Config{}); c != nil || err != nil {
+		t.Errorf("newDepsCache without mirror = %v, %v, want disabled", c, err)
+	}
+	if _, err := newDepsCache(Config{DepsMirrorDir: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("expected error for a missing mirror")
+	}
+
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	os.MkdirAll(cacheDir, 0o755)
+	stale := filepath.Join(cacheDir, depsInstallPrefix+"abc-1"+depsLayerExt)
+	cached := filepath.Join(cacheDir, "abc"+depsLayerExt)
+	os.WriteFile(stale, nil, 0o644)
+	os.WriteFile(cached, nil, 0o444)
+
+	c, err := newDepsCache(Config{DepsMirrorDir: dir, DepsCacheDir: cacheDir, DepsDiskMB: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.dir != cacheDir || c.diskMB != 8 {
+		t.Errorf("cache = %+v", c)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("half-installed layer not removed")
+	}
+	if _, err := os.Stat(cached); err != nil {
+		t.Errorf("cached layer removed: %v", err)
+	}
+}
+
+func TestDepsCacheInstallAndHit(t *testing.T) {
+	requireE2fsprogs(t)
+	dir := t.TempDir()
+	mirror := filepath.Join(dir, "mirror")
+	os.MkdirAll(filepath.Join(mirror, "pip"), 0o755)
+	os.WriteFile(filepath.Join(mirror, "pip", "greeting-1.0-py3-none-any.whl"), []byte("wheel"), 0o644)
+
+	c, err := newDepsCache(Config{DepsMirrorDir: mirror, DepsCacheDir: filepath.Join(dir, "cache"), DepsDiskMB: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	layer, err := c.prepare(ctx, "k1")
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if !layer.install || layer.mirror == "" {
+		t.Fatalf("layer = %+v, want a blank layer to install into", layer)
+	}
+	if info, err := os.Stat(layer.path); err != nil || info.Size() != 8<<20 {
+		t.Errorf("layer file = %v, %v, want 8 MiB", info, err)
+	}
+	if out := debugfsLs(t, layer.mirror, "/pip"); !strings.Contains(out, "greeting-1.0-py3-none-any.whl") {
+		t.Errorf("mirror image /pip = %q, want the wheel", out)
+	}
+
+	// An unchanged mirror reuses its image.
+	other, err := c.prepare(ctx, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.mirror != layer.mirror {
+		t.Errorf("mirror image rebuilt: %s then %s", layer.mirror, other.mirror)
+	}
+	c.discard(other)
+	if _, err := os.Stat(other.path); !os.IsNotExist(err) {
+		t.Error("discarded layer not removed")
+	}
+
+	if err := c.commit(layer); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	hit, err := c.prepare(ctx, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hit.install || hit.path != filepath.Join(c.dir, "k1"+depsLayerExt) {
+		t.Errorf("layer = %+v, want the cached one", hit)
+	}
+	// The cache holds a copy: writes the VM makes to its layer after the
+	// commit do not reach it.
+	if err := os.WriteFile(layer.path, []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(hit.path); err != nil || info.Size() != 8<<20 || info.Mode().Perm() != 0o444 {
+		t.Errorf("cached layer = %v, %v, want an 8 MiB read-only copy", info, err)
+	}
+	c.discard(layer)
+	c.discard(hit)
+	if _, err := os.Stat(hit.path); err != nil {
+		t.Errorf("discard removed a cached layer: %v", err)
+	}
+
+	// A changed mirror gets a new image and the old one is removed.
+	os.WriteFile(filepath.Join(mirror, "pip", "greeting-2.0-py3-none-any.whl"), []byte("wheel"), 0o644)
+	next, err := c.prepare(ctx, "k3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.mirror == layer.mirror {
+		t.Error("mirror image not rebuilt after the mirror changed")
+	}
+	if _, err := os.Stat(layer.mirror); !os.IsNotExist(err) {
+		t.Error("old mirror image not removed")
+	}
+}
+
+// debugfsLs lists a directory in an ext4 image.
+func debugfsLs(t *testing.T, image, dir string) string {
+	t.Helper()
+	out, err := exec.Command(DefaultDebugFSBin, "-R", "ls "+dir, image).CombinedOutput()
+	if err != nil {
+		t.Fatalf("debugfs: %v: %s", err, out)
+	}
+	return string(out)
+}
@@ -0,0 +1,266 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+const (
+	// dnsPort is the port group DNS servers listen on.
+	dnsPort = 53
+
+	// dnsRecordTTL is the TTL of group records. It is short because records
+	// come and go with their workloads.
+	dnsRecordTTL = 5
+
+	// dnsForwardTimeout bounds how long a forwarded query waits for an
+	// upstream answer.
+	dnsForwardTimeout = 2 * time.Second
+
+	// maxDNSMessage is the largest UDP DNS message served.
+	maxDNSMessage = 4096
+)
+
+// groupDNS answers queries from a network group's VMs. Names under the
+// group's zone, <name>.<group>.vulcan, resolve to the members' addresses;
+// names in other groups' zones do not exist, and everything else is forwarded
+// to the upstream nameservers the querying member's network policy lets it
+// reach. Queries the server would have to forward for anyone else are
+// refused, so the server is no way around a member's egress allowlist.
+type groupDNS struct {
+	conn     net.PacketConn
+	zone     string // fully qualified, e.g. "shop.vulcan."
+	upstream []string
+	logger   *slog.Logger
+
+	mu      sync.RWMutex
+	records map[string]netip.Addr // fully qualified name → address
+	clients map[string]dnsClient  // vmID → member that may recurse
+
+	wg sync.WaitGroup
+}
+
+// startGroupDNS serves group on addr over UDP, forwarding other queries to
+// the upstream nameservers, given as addresses with optional ports.
+func startGroupDNS(addr, group string, upstream []string, logger *slog.Logger) (*groupDNS, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	servers := make([]string, len(upstream))
+	for i, ns := range upstream {
+		if _, _, err := net.SplitHostPort(ns); err != nil {
+			ns = net.JoinHostPort(ns, strconv.Itoa(dnsPort))
+		}
+		servers[i] = ns
+	}
+	d := &groupDNS{
+		conn:     conn,
+		zone:     group + "." + model.GroupDomain + ".",
+		upstream: servers,
+		logger:   logger,
+		records:  make(map[string]netip.Addr),
+		clients:  make(map[string]dnsClient),
+	}
+	d.wg.Go(d.serve)
+	return d, nil
+}
+
+// Add registers name as resolving to addr.
+func (d *groupDNS) Add(name string, addr netip.Addr) {
+	d.mu.Lock()
+	d.records[name+"."+d.zone] = addr
+	d.mu.Unlock()
+}
+
+// Remove unregisters name.
+func (d *groupDNS) Remove(name string) {
+	d.mu.Lock()
+	delete(d.records, name+"."+d.zone)
+	d.mu.Unlock()
+}
+
+// dnsClient is a member whose queries for names outside the group domain
+// are forwarded.
+type dnsClient struct {
+	addr     netip.Addr
+	upstream []string // the nameservers its policy lets it reach
+}
+
+// AddClient lets the member vmID at addr have names outside the group domain
+// resolved through the upstream nameservers that allowed reports it may
+// reach over UDP.
+func (d *groupDNS) AddClient(vmID string, addr netip.Addr, allowed func(netip.AddrPort) bool) {
+	var upstream []string
+	for _, ns := range d.upstream {
+		if ap, err := netip.ParseAddrPort(ns); err == nil && allowed(ap) {
+			upstream = append(upstream, ns)
+		}
+	}
+	d.mu.Lock()
+	d.clients[vmID] = dnsClient{addr: addr.Unmap(), upstream: upstream}
+	d.mu.Unlock()
+}
+
+// RemoveClient stops forwarding queries for the member vmID.
+func (d *groupDNS) RemoveClient(vmID string) {
+	d.mu.Lock()
+	delete(d.clients, vmID)
+	d.mu.Unlock()
+}
+
+// clientUpstream returns the nameservers queries from addr may be forwarded
+// to, and false if addr is not a client.
+func (d *groupDNS) clientUpstream(addr netip.Addr) ([]string, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, c := range d.clients {
+		if c.addr == addr {
+			return c.upstream, true
+		}
+	}
+	return nil, false
+}
+
+// Close stops serving and waits for in-flight queries.
+func (d *groupDNS) Close() {
+	d.conn.Close()
+	d.wg.Wait()
+}
+
+func (d *groupDNS) serve() {
+	buf := make([]byte, maxDNSMessage)
+	for {
+		n, from, err := d.conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				d.logger.Warn("group DNS read failed", "zone", d.zone, "error", err)
+			}
+			return
+		}
+		query := append([]byte(nil), buf[:n]...)
+		d.wg.Go(func() {
+			resp := d.handle(query, from)
+			if resp == nil {
+				return
+			}
+			if _, err := d.conn.WriteTo(resp, from); err != nil {
+				d.logger.Debug("group DNS write failed", "zone", d.zone, "error", err)
+			}
+		})
+	}
+}
+
+// handle returns the response to a query sent from from, or nil to drop it.
+func (d *groupDNS) handle(query []byte, from net.Addr) []byte {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil || hdr.Response {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	name := strings.ToLower(q.Name.String())
+	switch {
+	case name == d.zone || strings.HasSuffix(name, "."+d.zone):
+		return d.answer(hdr, q, name)
+	case name == model.GroupDomain+"." || strings.HasSuffix(name, "."+model.GroupDomain+"."):
+		// Another group's zone: its members do not exist from here.
+		return reply(hdr, q, dnsmessage.RCodeNameError, nil)
+	default:
+		var addr netip.Addr
+		if ua, ok := from.(*net.UDPAddr); ok {
+			addr = ua.AddrPort().Addr().Unmap()
+		}
+		upstream, ok := d.clientUpstream(addr)
+		if !ok || (len(upstream) == 0 && len(d.upstream) > 0) {
+			return reply(hdr, q, dnsmessage.RCodeRefused, nil)
+		}
+		return d.forward(hdr, q, query, upstream)
+	}
+}
+
+// answer resolves a name in the group's own zone.
+func (d *groupDNS) answer(hdr dnsmessage.Header, q dnsmessage.Question, name string) []byte {
+	d.mu.RLock()
+	addr, ok := d.records[name]
+	d.mu.RUnlock()
+
+	if !ok && name != d.zone {
+		return reply(hdr, q, dnsmessage.RCodeNameError, nil)
+	}
+	if !ok || (q.Type != dnsmessage.TypeA && q.Type != dnsmessage.TypeALL) {
+		return reply(hdr, q, dnsmessage.RCodeSuccess, nil)
+	}
+	return reply(hdr, q, dnsmessage.RCodeSuccess, &dnsmessage.AResource{A: addr.As4()})
+}
+
+// forward relays a query to the first of upstream that answers.
+func (d *groupDNS) forward(hdr dnsmessage.Header, q dnsmessage.Question, query []byte, upstream []string) []byte {
+	buf := make([]byte, maxDNSMessage)
+	for _, ns := range upstream {
+		conn, err := net.Dial("udp", ns)
+		if err != nil {
+			continue
+		}
+		conn.SetDeadline(time.Now().Add(dnsForwardTimeout))
+		_, err = conn.Write(query)
+		var n int
+		if err == nil {
+			n, err = conn.Read(buf)
+		}
+		conn.Close()
+		if err == nil {
+			return buf[:n]
+		}
+		d.logger.Debug("group DNS forward failed", "nameserver", ns, "error", err)
+	}
+	return reply(hdr, q, dnsmessage.RCodeServerFailure, nil)
+}
+
+// reply builds a response to q with the given code and an optional A record.
+func reply(hdr dnsmessage.Header, q dnsmessage.Question, rcode dnsmessage.RCode, a *dnsmessage.AResource) []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 hdr.ID,
+		Response:           true,
+		Authoritative:      rcode != dnsmessage.RCodeServerFailure && rcode != dnsmessage.RCodeRefused,
+		RecursionDesired:   hdr.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              rcode,
+	})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil
+	}
+	if err := b.Question(q); err != nil {
+		return nil
+	}
+	if a != nil {
+		if err := b.StartAnswers(); err != nil {
+			return nil
+		}
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: dnsRecordTTL}
+		if err := b.AResource(rh, *a); err != nil {
+			return nil
+		}
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return msg
+}
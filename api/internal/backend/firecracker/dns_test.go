@@ -0,0 +1,210 @@
+package firecracker
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// queryDNS sends an A query for name to addr and returns the response.
+func queryDNS(t *testing.T, addr, name string) dnsmessage.Message {
+	t.Helper()
+	q := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 42, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := q.Pack()
+	if err != nil {
+		t.Fatalf("pack query: %v", err)
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("dial %s: %v", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(packed); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	buf := make([]byte, maxDNSMessage)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf[:n]); err != nil {
+		t.Fatalf("unpack response: %v", err)
+	}
+	if resp.Header.ID != 42 || !resp.Header.Response {
+		t.Fatalf("header = %+v, want a response to query 42", resp.Header)
+	}
+	return resp
+}
+
+// answerA returns the address of the response's single A record.
+func answerA(t *testing.T, resp dnsmessage.Message) netip.Addr {
+	t.Helper()
+	if len(resp.Answers) != 1 {
+		t.Fatalf("answers = %d, want 1 (rcode %v)", len(resp.Answers), resp.Header.RCode)
+	}
+	a, ok := resp.Answers[0].Body.(*dnsmessage.AResource)
+	if !ok {
+		t.Fatalf("answer = %T, want A record", resp.Answers[0].Body)
+	}
+	return netip.AddrFrom4(a.A)
+}
+
+// loopback is the address test queries come from.
+var loopback = netip.MustParseAddr("127.0.0.1")
+
+func allowAll(netip.AddrPort) bool { return true }
+
+func startTestDNS(t *testing.T, group string, upstream ...string) *groupDNS {
+	t.Helper()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	d, err := startGroupDNS("127.0.0.1:0", group, upstream, logger)
+	if err != nil {
+		t.Fatalf("startGroupDNS: %v", err)
+	}
+	t.Cleanup(d.Close)
+	return d
+}
+
+func TestGroupDNSResolvesMembers(t *testing.T) {
+	d := startTestDNS(t, "shop")
+	addr := d.conn.LocalAddr().String()
+	d.Add("db", netip.MustParseAddr("10.169.0.2"))
+
+	if got := answerA(t, queryDNS(t, addr, "db.shop.vulcan.")); got != netip.MustParseAddr("10.169.0.2") {
+		t.Errorf("db.shop.vulcan = %s, want 10.169.0.2", got)
+	}
+	// Names are case-insensitive.
+	if got := answerA(t, queryDNS(t, addr, "DB.Shop.Vulcan.")); got != netip.MustParseAddr("10.169.0.2") {
+		t.Errorf("DB.Shop.Vulcan = %s, want 10.169.0.2", got)
+	}
+
+	resp := queryDNS(t, addr, "cache.shop.vulcan.")
+	if resp.Header.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("unknown member rcode = %v, want NXDOMAIN", resp.Header.RCode)
+	}
+
+	d.Remove("db")
+	resp = queryDNS(t, addr, "db.shop.vulcan.")
+	if resp.Header.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("removed member rcode = %v, want NXDOMAIN", resp.Header.RCode)
+	}
+}
+
+func TestGroupDNSHidesOtherGroups(t *testing.T) {
+	shop := startTestDNS(t, "shop")
+	blog := startTestDNS(t, "blog")
+	blog.Add("db", netip.MustParseAddr("10.169.1.2"))
+
+	resp := queryDNS(t, shop.conn.LocalAddr().String(), "db.blog.vulcan.")
+	if resp.Header.RCode != dnsmessage.RCodeNameError || len(resp.Answers) != 0 {
+		t.Errorf("other group's member = %v with %d answers, want NXDOMAIN", resp.Header.RCode, len(resp.Answers))
+	}
+}
+
+func TestGroupDNSKeepsGroupDomainLocal(t *testing.T) {
+	// Even an upstream that would answer must not see names under .vulcan.
+	upstream := startTestDNS(t, "blog")
+	upstream.Add("www", netip.MustParseAddr("10.169.1.2"))
+
+	d := startTestDNS(t, "shop", upstream.conn.LocalAddr().String())
+	resp := queryDNS(t, d.conn.LocalAddr().String(), "www.blog.vulcan.")
+	if resp.Header.RCode != dnsmessage.RCodeNameError || len(resp.Answers) != 0 {
+		t.Errorf("rcode = %v with %d answers, want NXDOMAIN", resp.Header.RCode, len(resp.Answers))
+	}
+}
+
+// startTestUpstream starts a bare UDP responder that stands in for an
+// upstream resolver, answering every query with 203.0.113.80.
+func startTestUpstream(t *testing.T) net.PacketConn {
+	t.Helper()
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { upstream.Close() })
+	go func() {
+		buf := make([]byte, maxDNSMessage)
+		for {
+			n, from, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			hdr, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+			upstream.WriteTo(reply(hdr, q, dnsmessage.RCodeSuccess, &dnsmessage.AResource{A: [4]byte{203, 0, 113, 80}}), from)
+		}
+	}()
+	return upstream
+}
+
+func TestGroupDNSForwardsOutsideDomain(t *testing.T) {
+	upstream := startTestUpstream(t)
+	d := startTestDNS(t, "shop", upstream.LocalAddr().String())
+	d.AddClient("vm1", loopback, allowAll)
+	if got := answerA(t, queryDNS(t, d.conn.LocalAddr().String(), "example.com.")); got != netip.MustParseAddr("203.0.113.80") {
+		t.Errorf("example.com = %s, want the upstream's 203.0.113.80", got)
+	}
+}
+
+func TestGroupDNSRefusesOutsidePolicy(t *testing.T) {
+	upstream := startTestUpstream(t)
+	d := startTestDNS(t, "shop", upstream.LocalAddr().String())
+	addr := d.conn.LocalAddr().String()
+	d.Add("db", netip.MustParseAddr("10.169.0.2"))
+
+	// Only members are served beyond the group domain.
+	if resp := queryDNS(t, addr, "example.com."); resp.Header.RCode != dnsmessage.RCodeRefused {
+		t.Errorf("query from a non-member rcode = %v, want REFUSED", resp.Header.RCode)
+	}
+
+	// A member whose policy does not reach the upstream resolver still
+	// resolves the group's names, but nothing else.
+	d.AddClient("vm1", loopback, func(netip.AddrPort) bool { return false })
+	if resp := queryDNS(t, addr, "example.com."); resp.Header.RCode != dnsmessage.RCodeRefused || len(resp.Answers) != 0 {
+		t.Errorf("query outside the policy = %v with %d answers, want REFUSED", resp.Header.RCode, len(resp.Answers))
+	}
+	if got := answerA(t, queryDNS(t, addr, "db.shop.vulcan.")); got != netip.MustParseAddr("10.169.0.2") {
+		t.Errorf("db.shop.vulcan = %s, want 10.169.0.2", got)
+	}
+
+	d.AddClient("vm1", loopback, allowAll)
+	if got := answerA(t, queryDNS(t, addr, "example.com.")); got != netip.MustParseAddr("203.0.113.80") {
+		t.Errorf("example.com = %s, want the upstream's answer once the policy allows it", got)
+	}
+	d.RemoveClient("vm1")
+	if resp := queryDNS(t, addr, "example.com."); resp.Header.RCode != dnsmessage.RCodeRefused {
+		t.Errorf("query after the member left rcode = %v, want REFUSED", resp.Header.RCode)
+	}
+}
+
+func TestGroupDNSUpstreamUnavailable(t *testing.T) {
+	d := startTestDNS(t, "shop")
+	d.AddClient("vm1", loopback, allowAll)
+	resp := queryDNS(t, d.conn.LocalAddr().String(), "example.com.")
+	if resp.Header.RCode != dnsmessage.RCodeServerFailure {
+		t.Errorf("rcode = %v, want SERVFAIL without upstreams", resp.Header.RCode)
+	}
+}
@@ -0,0 +1,29 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/backend"
+)
+
+// vmExitGrace is how long the loss of a guest agent waits for the VMM to
+// exit before it is put down to the agent rather than the VM. The VMM
+// exits shortly after the guest kernel panics or the guest reboots, which
+// the connection to the agent may notice first.
+const vmExitGrace = 2 * time.Second
+
+// guestLost wraps err, the failure to reach or keep hold of a VM's guest
+// agent, with backend.ErrVMCrashed if the VM stops within vmExitGrace and
+// backend.ErrAgentUnreachable if it keeps running. wait is the VM's
+// Machine.Wait.
+func guestLost(wait func(context.Context) error, err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), vmExitGrace)
+	defer cancel()
+	if werr := wait(ctx); errors.Is(werr, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", backend.ErrAgentUnreachable, err)
+	}
+	return fmt.Errorf("%w: %w", backend.ErrVMCrashed, err)
+}
@@ -0,0 +1,33 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/backend"
+)
+
+func TestGuestLost(t *testing.T) {
+	connErr := errors.New("read: connection reset by peer")
+	tests := []struct {
+		name string
+		wait func(context.Context) error
+		want error
+	}{
+		{"vmm exited", func(context.Context) error { return nil }, backend.ErrVMCrashed},
+		{"vmm exited with error", func(context.Context) error { return errors.New("signal: killed") }, backend.ErrVMCrashed},
+		{"vmm still running", func(context.Context) error { return context.DeadlineExceeded }, backend.ErrAgentUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guestLost(tt.wait, connErr)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("guestLost() = %v, want it to wrap %v", err, tt.want)
+			}
+			if !errors.Is(err, connErr) {
+				t.Errorf("guestLost() = %v, want it to wrap %v", err, connErr)
+			}
+		})
+	}
+}
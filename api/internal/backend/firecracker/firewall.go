@@ -0,0 +1,158 @@
+package firecracker
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// nftTable is the nftables table that holds a VM's network policy. It lives
+// in the VM's network namespace and is removed along with it.
+const nftTable = "vulcan"
+
+// attachment describes where a VM is attached, for rendering its ruleset.
+type attachment struct {
+	// pools are the address pools of the bridge the VM is attached to.
+	pools []AddressPool
+
+	// mesh lets VMs on the bridge reach each other, as in a network group.
+	mesh bool
+
+	// isolated are other VM networks the VM must not reach through the host.
+	isolated []netip.Prefix
+
+	// ingressPort is a guest TCP port the host forwards connections to, or 0.
+	ingressPort int
+}
+
+// policyRuleset renders the nftables ruleset enforcing policy for a VM with
+// the given attachment.
+//
+// tc-redirect-tap moves the VM's frames between its TAP device and eth0 with
+// tc, bypassing the namespace's IP netfilter hooks, so the rules attach to
+// the netdev egress hook of eth0, which every frame leaving the VM passes.
+// Unless the bridge is a mesh, traffic to other VMs on the bridge is always
+// dropped, so with every VM carrying these rules, sibling VMs cannot reach
+// each other. Traffic to the isolated networks is dropped before any allow
+// rule, so no policy can open a path into another group. A non-zero
+// ingressPort lets the VM answer the host's ingress connections to that TCP
+// port even when the policy would otherwise drop traffic to the gateway.
+func policyRuleset(policy *model.NetworkPolicy, att attachment) (string, error) {
+	mode := policy.EffectiveMode()
+	defaultVerdict := "accept"
+	if mode == model.NetworkEgress {
+		defaultVerdict = "drop"
+	} else if mode != model.NetworkFull {
+		return "", fmt.Errorf("network mode %q has no ruleset", mode)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "table netdev %s {\n", nftTable)
+	b.WriteString("\tchain egress {\n")
+	fmt.Fprintf(&b, "\t\ttype filter hook egress device %q priority 0; policy %s;\n", CNIIfName, defaultVerdict)
+	b.WriteString("\t\tmeta protocol arp accept\n")
+	for _, p := range att.pools {
+		family := prefixFamily(p.Subnet)
+		if family == "ip6" && mode == model.NetworkEgress {
+			// IPv6 resolves neighbours with ICMPv6 rather than ARP.
+			b.WriteString("\t\ticmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-solicit } accept\n")
+		}
+		if att.mesh {
+			fmt.Fprintf(&b, "\t\t%s daddr %s accept\n", family, p.Subnet)
+			continue
+		}
+		fmt.Fprintf(&b, "\t\t%s daddr %s %s daddr != %s drop\n", family, p.Subnet, family, p.Gateway)
+		if mode == model.NetworkEgress && att.ingressPort != 0 {
+			fmt.Fprintf(&b, "\t\t%s daddr %s tcp sport %d accept\n", family, p.Gateway, att.ingressPort)
+		}
+	}
+	for _, prefix := range att.isolated {
+		fmt.Fprintf(&b, "\t\t%s daddr %s drop\n", prefixFamily(prefix), prefix)
+	}
+
+	if mode == model.NetworkEgress {
+		for _, rule := range policy.Allow {
+			line, err := egressRule(rule)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString("\t\t" + line + "\n")
+		}
+	}
+
+	b.WriteString("\t}\n}\n")
+	return b.String(), nil
+}
+
+// egressRule renders a single allowlist entry as an nftables accept rule.
+func egressRule(rule model.EgressRule) (string, error) {
+	prefix, err := netip.ParsePrefix(rule.CIDR)
+	if err != nil {
+		return "", fmt.Errorf("parse cidr %q: %w", rule.CIDR, err)
+	}
+
+	parts := []string{prefixFamily(prefix), "daddr", prefix.Masked().String()}
+
+	switch {
+	case rule.Protocol != "":
+		parts = append(parts, "meta l4proto", rule.Protocol)
+	case len(rule.Ports) > 0:
+		parts = append(parts, "meta l4proto { tcp, udp }")
+	}
+
+	if len(rule.Ports) > 0 {
+		ports := make([]string, len(rule.Ports))
+		for i, p := range rule.Ports {
+			ports[i] = strconv.Itoa(p)
+		}
+		parts = append(parts, "th dport {", strings.Join(ports, ", "), "}")
+	}
+
+	return strings.Join(append(parts, "accept"), " "), nil
+}
+
+// prefixFamily returns the nftables address family matching prefix.
+func prefixFamily(prefix netip.Prefix) string {
+	if prefix.Addr().Is6() {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// egressProbeRuleset uses the netdev egress hook as policyRuleset does. It
+// is only checked, never loaded.
+const egressProbeRuleset = `table netdev vulcan_probe {
+	chain egress {
+		type filter hook egress device "lo" priority 0; policy accept;
+	}
+}
+`
+
+// checkEgressHook reports whether the kernel and the nft binary at nftBin
+// support the netdev egress hook, which needs Linux 5.16 and nftables 1.0.1
+// or later. nft --check validates the ruleset with the kernel and then
+// discards it.
+func checkEgressHook(nftBin string) error {
+	cmd := exec.Command(nftBin, "--check", "-f", "-")
+	cmd.Stdin = strings.NewReader(egressProbeRuleset)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nftables netdev egress hook (required for network policies; needs Linux 5.16+ and nft 1.0.1+): %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// applyRuleset loads an nftables ruleset into the named network namespace
+// using the nft binary at nftBin.
+func applyRuleset(nftBin, nsName, ruleset string) error {
+	cmd := exec.Command("ip", "netns", "exec", nsName, nftBin, "-f", "-")
+	cmd.Stdin = bytes.NewBufferString(ruleset)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nft -f in %s: %s: %w", nsName, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package firecracker
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// testPools parses subnets into address pools with default gateways.
+func testPools(t *testing.T, subnets ...string) []AddressPool {
+	t.Helper()
+	pools, err := Config{Subnets: subnets}.AddressPools()
+	if err != nil {
+		t.Fatalf("AddressPools: %v", err)
+	}
+	return pools
+}
+
+func TestPolicyRulesetFull(t *testing.T) {
+	for _, policy := range []*model.NetworkPolicy{nil, {Mode: model.NetworkFull}} {
+		rs, err := policyRuleset(policy, attachment{pools: testPools(t, DefaultSubnet)})
+		if err != nil {
+			t.Fatalf("policyRuleset(%v): %v", policy, err)
+		}
+		for _, want := range []string{
+			`type filter hook egress device "eth0" priority 0; policy accept;`,
+			"ip daddr 10.168.0.0/24 ip daddr != 10.168.0.1 drop",
+		} {
+			if !strings.Contains(rs, want) {
+				t.Errorf("ruleset missing %q:\n%s", want, rs)
+			}
+		}
+	}
+}
+
+func TestPolicyRulesetEgress(t *testing.T) {
+	policy := &model.NetworkPolicy{
+		Mode: model.NetworkEgress,
+		Allow: []model.EgressRule{
+			{CIDR: "203.0.113.7/24", Protocol: model.ProtocolTCP, Ports: []int{80, 443}},
+			{CIDR: "198.51.100.53/32", Ports: []int{53}},
+			{CIDR: "2001:db8::/32"},
+		},
+	}
+
+	rs, err := policyRuleset(policy, attachment{pools: testPools(t, DefaultSubnet)})
+	if err != nil {
+		t.Fatalf("policyRuleset: %v", err)
+	}
+
+	want := []string{
+		"policy drop;",
+		"meta protocol arp accept",
+		"ip daddr 10.168.0.0/24 ip daddr != 10.168.0.1 drop",
+		"ip daddr 203.0.113.0/24 meta l4proto tcp th dport { 80, 443 } accept",
+		"ip daddr 198.51.100.53/32 meta l4proto { tcp, udp } th dport { 53 } accept",
+		"ip6 daddr 2001:db8::/32 accept",
+	}
+	for _, w := range want {
+		if !strings.Contains(rs, w) {
+			t.Errorf("ruleset missing %q:\n%s", w, rs)
+		}
+	}
+
+	// Sibling isolation must precede the allowlist so it cannot be overridden.
+	if strings.Index(rs, "!= 10.168.0.1 drop") > strings.Index(rs, "203.0.113.0/24") {
+		t.Errorf("sibling drop rule comes after allow rules:\n%s", rs)
+	}
+}
+
+func TestPolicyRulesetNone(t *testing.T) {
+	if _, err := policyRuleset(&model.NetworkPolicy{Mode: model.NetworkNone}, attachment{pools: testPools(t, DefaultSubnet)}); err == nil {
+		t.Error("expected error: mode none has no NIC to filter")
+	}
+}
+
+func TestPolicyRulesetMultiplePools(t *testing.T) {
+	policy := &model.NetworkPolicy{Mode: model.NetworkEgress}
+	rs, err := policyRuleset(policy, attachment{pools: testPools(t, "10.168.0.0/24", "10.169.0.0/24", "fd00:168::/64")})
+	if err != nil {
+		t.Fatalf("policyRuleset: %v", err)
+	}
+	for _, want := range []string{
+		"ip daddr 10.168.0.0/24 ip daddr != 10.168.0.1 drop",
+		"ip daddr 10.169.0.0/24 ip daddr != 10.169.0.1 drop",
+		"ip6 daddr fd00:168::/64 ip6 daddr != fd00:168::1 drop",
+		"icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-solicit } accept",
+	} {
+		if !strings.Contains(rs, want) {
+			t.Errorf("ruleset missing %q:\n%s", want, rs)
+		}
+	}
+}
+
+func TestPolicyRulesetIngressPort(t *testing.T) {
+	pools := testPools(t, DefaultSubnet, "fd00:168::/64")
+
+	rs, err := policyRuleset(&model.NetworkPolicy{Mode: model.NetworkEgress}, attachment{pools: pools, ingressPort: 8080})
+	if err != nil {
+		t.Fatalf("policyRuleset: %v", err)
+	}
+	for _, want := range []string{
+		"ip daddr 10.168.0.1 tcp sport 8080 accept",
+		"ip6 daddr fd00:168::1 tcp sport 8080 accept",
+	} {
+		if !strings.Contains(rs, want) {
+			t.Errorf("ruleset missing %q:\n%s", want, rs)
+		}
+	}
+
+	// Full mode accepts traffic to the gateway already.
+	rs, err = policyRuleset(nil, attachment{pools: pools, ingressPort: 8080})
+	if err != nil {
+		t.Fatalf("policyRuleset: %v", err)
+	}
+	if strings.Contains(rs, "sport") {
+		t.Errorf("full-mode ruleset has an ingress rule:\n%s", rs)
+	}
+}
+
+func TestPolicyRulesetGroupMesh(t *testing.T) {
+	att := attachment{
+		pools:    []AddressPool{{Subnet: netip.MustParsePrefix("10.169.3.0/24"), Gateway: netip.MustParseAddr("10.169.3.1")}},
+		mesh:     true,
+		isolated: []netip.Prefix{netip.MustParsePrefix("10.169.0.0/16"), netip.MustParsePrefix(DefaultSubnet)},
+	}
+	policy := &model.NetworkPolicy{
+		Mode:  model.NetworkEgress,
+		Allow: []model.EgressRule{{CIDR: "10.0.0.0/8"}},
+	}
+
+	rs, err := policyRuleset(policy, att)
+	if err != nil {
+		t.Fatalf("policyRuleset: %v", err)
+	}
+	want := []string{
+		"ip daddr 10.169.3.0/24 accept",
+		"ip daddr 10.169.0.0/16 drop",
+		"ip daddr 10.168.0.0/24 drop",
+		"ip daddr 10.0.0.0/8 accept",
+	}
+	last := -1
+	for _, w := range want {
+		i := strings.Index(rs, w)
+		if i < 0 {
+			t.Fatalf("ruleset missing %q:\n%s", w, rs)
+		}
+		// Peers are accepted before other groups are dropped, and other
+		// groups are dropped before any allow rule can open them.
+		if i < last {
+			t.Errorf("rule %q out of order:\n%s", w, rs)
+		}
+		last = i
+	}
+	if strings.Contains(rs, "daddr != ") {
+		t.Errorf("mesh ruleset drops peers:\n%s", rs)
+	}
+}
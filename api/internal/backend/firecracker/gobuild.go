@@ -0,0 +1,136 @@
+package firecracker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/seantiz/vulcan/internal/backend"
+)
+
+// DefaultGoBuildCacheDir is where compiled Go programs are cached by default.
+const DefaultGoBuildCacheDir = "go-build-cache"
+
+// Files in the build cache directory: programs are named by their key, and
+// programs being received from a guest "<buildTempPrefix>*".
+const buildTempPrefix = ".build-"
+
+// maxProgramSize bounds a compiled program received from a guest.
+const maxProgramSize = 512 << 20
+
+// Metric label values for build cache lookups.
+const (
+	buildCacheHit  = "hit"
+	buildCacheMiss = "miss"
+)
+
+// errProgramTooLarge is returned when a guest sends a program larger than
+// maxProgramSize.
+var errProgramTooLarge = fmt.Errorf("compiled program exceeds %d bytes", maxProgramSize)
+
+// codeDigest returns the hex SHA-256 digest of a workload's code.
+func codeDigest(spec backend.WorkloadSpec) string {
+	h := sha256.New()
+	if len(spec.CodeArchive) > 0 {
+		h.Write([]byte("archive\x00"))
+		h.Write(spec.CodeArchive)
+	} else {
+		h.Write([]byte("code\x00"))
+		h.Write([]byte(spec.Code))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// buildKey returns the cache key of the program compiled from the code with
+// the given digest by toolchain. Guests share the host's architecture.
+func buildKey(digest, toolchain string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", digest, toolchain, runtime.GOARCH)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// buildCache stores the programs guests compiled from workload code, so that
+// later runs of the same code skip the compiler.
+type buildCache struct {
+	dir string
+}
+
+// newBuildCache creates the build cache in dir, or returns nil if dir is
+// empty. Programs left half-received by an earlier process are removed.
+func newBuildCache(dir string) (*buildCache, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create build cache dir: %w", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve build cache dir: %w", err)
+	}
+	stale, _ := filepath.Glob(filepath.Join(abs, buildTempPrefix+"*"))
+	for _, p := range stale {
+		os.Remove(p)
+	}
+	return &buildCache{dir: abs}, nil
+}
+
+// goBuild is the program exchanged with the guest during one run: either the
+// cached program sent to it, or the file receiving the one it compiles.
+type goBuild struct {
+	key      string
+	prebuilt bool
+	file     *os.File
+	size     int64
+}
+
+// prepare opens the cached program for key or, on a miss, a temporary file
+// to receive the program the guest compiles.
+func (c *buildCache) prepare(key string) (*goBuild, error) {
+	f, err := os.Open(filepath.Join(c.dir, key))
+	if err == nil {
+		goBuildCacheTotal.WithLabelValues(buildCacheHit).Inc()
+		return &goBuild{key: key, prebuilt: true, file: f}, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("open cached program: %w", err)
+	}
+	goBuildCacheTotal.WithLabelValues(buildCacheMiss).Inc()
+
+	f, err = os.CreateTemp(c.dir, buildTempPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("create program file: %w", err)
+	}
+	return &goBuild{key: key, file: f}, nil
+}
+
+// Write appends to the program being received, up to maxProgramSize.
+func (g *goBuild) Write(p []byte) (int, error) {
+	if g.size+int64(len(p)) > maxProgramSize {
+		return 0, errProgramTooLarge
+	}
+	n, err := g.file.Write(p)
+	g.size += int64(n)
+	return n, err
+}
+
+// finish releases a run's program. A program the guest compiled is moved
+// into the cache if built is set and discarded otherwise. Concurrent builds
+// of the same key are harmless: the last one replaces the others.
+func (c *buildCache) finish(g *goBuild, built bool) error {
+	if g.prebuilt {
+		return g.file.Close()
+	}
+	err := g.file.Close()
+	if err == nil && built {
+		if err = os.Chmod(g.file.Name(), 0o444); err == nil {
+			err = os.Rename(g.file.Name(), filepath.Join(c.dir, g.key))
+		}
+	}
+	os.Remove(g.file.Name()) // no-op once renamed
+	return err
+}
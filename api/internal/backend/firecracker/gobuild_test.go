@@ -0,0 +1,136 @@
+package firecracker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/backend"
+)
+
+func TestCodeDigest(t *testing.T) {
+	code := codeDigest(backend.WorkloadSpec{Code: "package main"})
+	if len(code) != 64 {
+		t.Fatalf("digest = %q, want a hex sha256", code)
+	}
+	if again := codeDigest(backend.WorkloadSpec{ID: "other", Code: "package main"}); again != code {
+		t.Error("digest depends on more than the code")
+	}
+	if archive := codeDigest(backend.WorkloadSpec{Code: "ignored", CodeArchive: []byte("package main")}); archive == code {
+		t.Error("inline code and an archive with the same bytes share a digest")
+	}
+}
+
+func TestBuildKey(t *testing.T) {
+	key := buildKey("digest", "go1.22.5")
+	if key == buildKey("digest", "go1.23.0") {
+		t.Error("key unchanged by a different toolchain")
+	}
+	if key == buildKey("other", "go1.22.5") {
+		t.Error("key unchanged by different code")
+	}
+	if key != buildKey("digest", "go1.22.5") {
+		t.Error("key not stable")
+	}
+}
+
+func TestNewBuildCache(t *testing.T) {
+	if c, err := newBuildCache(""); c != nil || err != nil {
+		t.Errorf("newBuildCache(\"\") = %v, %v, want disabled", c, err)
+	}
+
+	dir := t.TempDir()
+	stale := filepath.Join(dir, buildTempPrefix+"1")
+	os.WriteFile(stale, []byte("partial"), 0o644)
+	cached := filepath.Join(dir, "abc")
+	os.WriteFile(cached, []byte("program"), 0o444)
+
+	if _, err := newBuildCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("half-received program not removed")
+	}
+	if _, err := os.Stat(cached); err != nil {
+		t.Errorf("cached program removed: %v", err)
+	}
+}
+
+func TestBuildCacheMissThenHit(t *testing.T) {
+	c, err := newBuildCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	miss, err := c.prepare("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if miss.prebuilt {
+		t.Fatal("prepare on an empty cache returned a prebuilt program")
+	}
+	if _, err := io.Copy(miss, bytes.NewReader([]byte("\x7fELF program"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.finish(miss, true); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	hit, err := c.prepare("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hit.prebuilt {
+		t.Fatal("prepare after a build did not return the cached program")
+	}
+	data, _ := io.ReadAll(hit.file)
+	if string(data) != "\x7fELF program" {
+		t.Errorf("cached program = %q", data)
+	}
+	if err := c.finish(hit, false); err != nil {
+		t.Errorf("finish: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(c.dir, "k")); err != nil {
+		t.Errorf("finishing a prebuilt run removed the program: %v", err)
+	}
+}
+
+func TestBuildCacheDiscardsFailedBuild(t *testing.T) {
+	c, err := newBuildCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := c.prepare("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Write([]byte("partial"))
+	if err := c.finish(g, false); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(c.dir)
+	if len(entries) != 0 {
+		t.Errorf("cache dir holds %d files after a failed build, want none", len(entries))
+	}
+}
+
+func TestGoBuildWriteLimit(t *testing.T) {
+	c, err := newBuildCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := c.prepare("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.finish(g, false)
+
+	g.size = maxProgramSize - 1
+	if _, err := g.Write([]byte("xx")); !errors.Is(err, errProgramTooLarge) {
+		t.Errorf("Write past the limit = %v, want errProgramTooLarge", err)
+	}
+}
@@ -0,0 +1,27 @@
+package firecracker
+
+import (
+	"context"
+	"time"
+)
+
+// gracePeriod returns the time a workload that asked for graceS seconds has
+// between SIGTERM and SIGKILL once it times out or is cancelled.
+func gracePeriod(graceS int) time.Duration {
+	if graceS <= 0 {
+		return DefaultGracePeriod
+	}
+	return time.Duration(graceS) * time.Second
+}
+
+// graceDeadline returns the deadline of the connection to the guest running
+// a workload under ctx: far enough past ctx's deadline for the guest to stop
+// the workload within grace and still deliver its result. It is the zero
+// time, meaning none, if ctx has no deadline.
+func graceDeadline(ctx context.Context, grace time.Duration) time.Time {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return time.Time{}
+	}
+	return deadline.Add(grace + cancelResultMargin)
+}
@@ -0,0 +1,30 @@
+package firecracker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGracePeriod(t *testing.T) {
+	if got := gracePeriod(0); got != DefaultGracePeriod {
+		t.Errorf("gracePeriod(0) = %s, want %s", got, DefaultGracePeriod)
+	}
+	if got := gracePeriod(12); got != 12*time.Second {
+		t.Errorf("gracePeriod(12) = %s, want 12s", got)
+	}
+}
+
+func TestGraceDeadline(t *testing.T) {
+	if got := graceDeadline(context.Background(), time.Second); !got.IsZero() {
+		t.Errorf("graceDeadline without a deadline = %v, want none", got)
+	}
+
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	want := deadline.Add(3*time.Second + cancelResultMargin)
+	if got := graceDeadline(ctx, 3*time.Second); !got.Equal(want) {
+		t.Errorf("graceDeadline = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,247 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/vishvananda/netlink"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// groupNetwork is the private bridge, subnet and DNS zone shared by the VMs
+// of one network group. VMs on it can reach each other; VMs on any other
+// bridge cannot reach them.
+type groupNetwork struct {
+	name     string
+	index    int
+	pool     AddressPool
+	network  bridgeNetwork
+	confList *libcni.NetworkConfigList
+	members  map[string]string // vmID → workload name, "" if unnamed
+	dns      *groupDNS
+}
+
+// groupNetworkName returns the CNI network name of a group.
+func groupNetworkName(group string) string {
+	return CNINetworkName + "-" + group
+}
+
+// GroupsEnabled reports whether VMs can be placed in network groups. It is
+// false for a nil manager.
+func (nm *NetworkManager) GroupsEnabled() bool {
+	return nm != nil && nm.groupRange.IsValid()
+}
+
+// joinGroup adds vmID to group, allocating the group's subnet and bridge on
+// first use. name must be unique among the group's current members.
+func (nm *NetworkManager) joinGroup(group, vmID, name string) (*groupNetwork, error) {
+	if !nm.groupRange.IsValid() {
+		return nil, errors.New("network groups are disabled")
+	}
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	g, ok := nm.groups[group]
+	if !ok {
+		var err error
+		if g, err = nm.newGroupNetwork(group); err != nil {
+			return nil, err
+		}
+		nm.groups[group] = g
+		nm.groupIndexes[g.index] = true
+	}
+	if name != "" {
+		for _, member := range g.members {
+			if member == name {
+				return nil, fmt.Errorf("name %q is already in use in group %s", name, group)
+			}
+		}
+	}
+	g.members[vmID] = name
+	nm.vmGroups[vmID] = group
+	return g, nil
+}
+
+// newGroupNetwork allocates the lowest free group subnet and builds the
+// group's CNI network. The caller must hold nm.mu.
+func (nm *NetworkManager) newGroupNetwork(group string) (*groupNetwork, error) {
+	index := 0
+	for nm.groupIndexes[index] {
+		index++
+	}
+	pool, ok := groupPool(nm.groupRange, index)
+	if !ok {
+		return nil, fmt.Errorf("no free subnet in group range %s", nm.groupRange)
+	}
+
+	// Members resolve each other through the group's DNS server on the
+	// gateway; it forwards other names to the first upstream nameserver,
+	// which is also listed directly as a fallback.
+	nameservers := []string{pool.Gateway.String()}
+	if len(nm.nameservers) > 0 {
+		nameservers = append(nameservers, nm.nameservers[0])
+	}
+	network := bridgeNetwork{
+		name:        groupNetworkName(group),
+		bridge:      GroupBridgePrefix + strconv.Itoa(index),
+		pools:       []AddressPool{pool},
+		mtu:         nm.mtu,
+		nameservers: nameservers,
+		search:      []string{group + "." + model.GroupDomain},
+	}
+	data, err := generateConfList(network)
+	if err != nil {
+		return nil, fmt.Errorf("generate CNI conflist for group %s: %w", group, err)
+	}
+	confList, err := libcni.ConfListFromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse CNI conflist for group %s: %w", group, err)
+	}
+	if nm.cniConfigDir != "" {
+		if err := nm.writeConfList(network.name, data); err != nil {
+			nm.logger.Warn("failed to write group conflist", "group", group, "error", err)
+		}
+	}
+
+	nm.logger.Info("network group created", "group", group, "subnet", pool.Subnet, "bridge", network.bridge)
+	return &groupNetwork{
+		name:     group,
+		index:    index,
+		pool:     pool,
+		network:  network,
+		confList: confList,
+		members:  make(map[string]string),
+	}, nil
+}
+
+// groupAttachment returns the ruleset attachment of a VM in group g: it may
+// reach the group's subnet, but neither other groups nor the default bridge.
+func (nm *NetworkManager) groupAttachment(g *groupNetwork, ingressPort int) attachment {
+	isolated := []netip.Prefix{nm.groupRange}
+	for _, p := range nm.pools {
+		isolated = append(isolated, p.Subnet)
+	}
+	return attachment{
+		pools:       []AddressPool{g.pool},
+		mesh:        true,
+		isolated:    isolated,
+		ingressPort: ingressPort,
+	}
+}
+
+// registerMember starts the group's DNS server if needed, registers name as
+// resolving to the VM's address and lets the VM resolve other names through
+// the upstream nameservers its policy allows. The server listens on the
+// gateway, which the bridge holds once the first member is attached.
+func (nm *NetworkManager) registerMember(g *groupNetwork, vmID, name string, policy *model.NetworkPolicy, netCfg *NetworkConfig) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if g.dns == nil {
+		addr := net.JoinHostPort(g.pool.Gateway.String(), strconv.Itoa(dnsPort))
+		dns, err := startGroupDNS(addr, g.name, nm.nameservers, nm.logger.With("group", g.name))
+		if err != nil {
+			return fmt.Errorf("start group DNS: %w", err)
+		}
+		g.dns = dns
+	}
+	prefix, err := netip.ParsePrefix(netCfg.GuestIP)
+	if err != nil {
+		return fmt.Errorf("parse guest IP %q: %w", netCfg.GuestIP, err)
+	}
+	g.dns.AddClient(vmID, prefix.Addr(), func(ns netip.AddrPort) bool {
+		return policy.Allows(ns.Addr(), model.ProtocolUDP, int(ns.Port()))
+	})
+	if name != "" {
+		g.dns.Add(name, prefix.Addr())
+	}
+	return nil
+}
+
+// leaveGroup removes vmID from its group, if any, and releases the group's
+// network once its last member has left.
+func (nm *NetworkManager) leaveGroup(vmID string) {
+	nm.mu.Lock()
+	group, ok := nm.vmGroups[vmID]
+	if !ok {
+		nm.mu.Unlock()
+		return
+	}
+	delete(nm.vmGroups, vmID)
+	g := nm.groups[group]
+	name := g.members[vmID]
+	delete(g.members, vmID)
+	if g.dns != nil {
+		g.dns.RemoveClient(vmID)
+		if name != "" {
+			g.dns.Remove(name)
+		}
+	}
+	empty := len(g.members) == 0
+	if empty {
+		// The subnet index stays reserved until the bridge is gone, so a
+		// new group cannot reuse the bridge name in the meantime. The
+		// conflist is named after the group instead, so it goes while
+		// nm.mu is held, before a new network of that name can be written.
+		delete(nm.groups, group)
+		nm.removeGroupConfList(g)
+	}
+	nm.mu.Unlock()
+
+	if empty {
+		nm.releaseGroup(g)
+	}
+}
+
+// removeGroupConfList deletes the group's conflist from the config
+// directory. The caller must hold nm.mu.
+func (nm *NetworkManager) removeGroupConfList(g *groupNetwork) {
+	if nm.cniConfigDir == "" {
+		return
+	}
+	confPath := filepath.Join(nm.cniConfigDir, g.network.name+".conflist")
+	if err := os.Remove(confPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		nm.logger.Warn("failed to remove group conflist", "group", g.name, "error", err)
+	}
+}
+
+// releaseGroup stops the group's DNS server, deletes its bridge and frees
+// its subnet.
+func (nm *NetworkManager) releaseGroup(g *groupNetwork) {
+	if g.dns != nil {
+		g.dns.Close()
+	}
+	if err := deleteLink(g.network.bridge); err != nil {
+		nm.logger.Warn("failed to delete group bridge", "group", g.name, "bridge", g.network.bridge, "error", err)
+	}
+
+	nm.mu.Lock()
+	delete(nm.groupIndexes, g.index)
+	nm.mu.Unlock()
+
+	nm.logger.Info("network group released", "group", g.name, "subnet", g.pool.Subnet)
+}
+
+// deleteLink deletes the named network interface. It returns nil if the
+// interface does not exist.
+func deleteLink(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+		return fmt.Errorf("find link %s: %w", name, err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("delete link %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package firecracker
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestGroupManager returns a NetworkManager with network groups enabled
+// that does not touch the host's network.
+func newTestGroupManager(t *testing.T) *NetworkManager {
+	t.Helper()
+	cfg := testNetworkConfig()
+	cfg.GroupSubnets = DefaultGroupSubnets
+	nm, err := NewNetworkManager(cfg, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewNetworkManager: %v", err)
+	}
+	return nm
+}
+
+func TestGroupConfListFollowsMembership(t *testing.T) {
+	nm := newTestGroupManager(t)
+	nm.cniConfigDir = t.TempDir()
+	confPath := filepath.Join(nm.cniConfigDir, groupNetworkName("shop")+".conflist")
+
+	if _, err := nm.joinGroup("shop", "vm1", ""); err != nil {
+		t.Fatalf("joinGroup: %v", err)
+	}
+	nm.leaveGroup("vm1")
+	if _, err := os.Stat(confPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("conflist after the group was released: %v, want it removed", err)
+	}
+
+	// A group rejoined after release keeps the conflist its join wrote.
+	if _, err := nm.joinGroup("shop", "vm2", ""); err != nil {
+		t.Fatalf("joinGroup: %v", err)
+	}
+	if _, err := os.Stat(confPath); err != nil {
+		t.Errorf("conflist of the rejoined group: %v", err)
+	}
+}
+
+func TestJoinGroupAllocatesSubnets(t *testing.T) {
+	nm := newTestGroupManager(t)
+
+	shop, err := nm.joinGroup("shop", "vm1", "api")
+	if err != nil {
+		t.Fatalf("joinGroup: %v", err)
+	}
+	again, err := nm.joinGroup("shop", "vm2", "db")
+	if err != nil {
+		t.Fatalf("joinGroup: %v", err)
+	}
+	if again != shop {
+		t.Error("second member got a different network")
+	}
+	blog, err := nm.joinGroup("blog", "vm3", "api")
+	if err != nil {
+		t.Fatalf("joinGroup: %v", err)
+	}
+
+	if shop.pool.Subnet.String() != "10.169.0.0/24" || shop.network.bridge != "vgrp0" {
+		t.Errorf("shop = %s on %s, want 10.169.0.0/24 on vgrp0", shop.pool.Subnet, shop.network.bridge)
+	}
+	if blog.pool.Subnet.String() != "10.169.1.0/24" || blog.network.bridge != "vgrp1" {
+		t.Errorf("blog = %s on %s, want 10.169.1.0/24 on vgrp1", blog.pool.Subnet, blog.network.bridge)
+	}
+	if blog.confList.Name != "vulcan-fcnet-blog" {
+		t.Errorf("blog CNI network = %q, want vulcan-fcnet-blog", blog.confList.Name)
+	}
+
+	// Releasing a group frees its subnet for the next new group.
+	nm.leaveGroup("vm1")
+	nm.leaveGroup("vm2")
+	if _, ok := nm.groups["shop"]; ok {
+		t.Error("shop still allocated after its last member left")
+	}
+	docs, err := nm.joinGroup("docs", "vm4", "")
+	if err != nil {
+		t.Fatalf("joinGroup: %v", err)
+	}
+	if docs.index != 0 {
+		t.Errorf("docs index = %d, want the released 0", docs.index)
+	}
+}
+
+func TestJoinGroupNameInUse(t *testing.T) {
+	nm := newTestGroupManager(t)
+
+	if _, err := nm.joinGroup("shop", "vm1", "api"); err != nil {
+		t.Fatalf("joinGroup: %v", err)
+	}
+	_, err := nm.joinGroup("shop", "vm2", "api")
+	if err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Fatalf("joinGroup with a taken name = %v, want already in use", err)
+	}
+	// Unnamed members never conflict.
+	for _, vm := range []string{"vm3", "vm4"} {
+		if _, err := nm.joinGroup("shop", vm, ""); err != nil {
+			t.Errorf("joinGroup(%s, unnamed): %v", vm, err)
+		}
+	}
+
+	nm.leaveGroup("vm1")
+	if _, err := nm.joinGroup("shop", "vm2", "api"); err != nil {
+		t.Errorf("joinGroup after the name was released: %v", err)
+	}
+}
+
+func TestJoinGroupDisabled(t *testing.T) {
+	nm, err := NewNetworkManager(testNetworkConfig(), slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewNetworkManager: %v", err)
+	}
+	if nm.GroupsEnabled() {
+		t.Error("GroupsEnabled without group subnets")
+	}
+	if _, err := nm.joinGroup("shop", "vm1", ""); err == nil {
+		t.Error("joinGroup succeeded with network groups disabled")
+	}
+}
+
+func TestGroupAttachment(t *testing.T) {
+	nm := newTestGroupManager(t)
+	g, err := nm.joinGroup("shop", "vm1", "api")
+	if err != nil {
+		t.Fatalf("joinGroup: %v", err)
+	}
+
+	att := nm.groupAttachment(g, 0)
+	if !att.mesh || len(att.pools) != 1 || att.pools[0] != g.pool {
+		t.Errorf("attachment = %+v, want a mesh on the group pool", att)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix(DefaultGroupSubnets), netip.MustParsePrefix(DefaultSubnet)}
+	if len(att.isolated) != len(want) || att.isolated[0] != want[0] || att.isolated[1] != want[1] {
+		t.Errorf("isolated = %v, want %v", att.isolated, want)
+	}
+}
@@ -0,0 +1,154 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ingressDialTimeout bounds how long a forwarded connection waits for the
+// guest to accept it.
+const ingressDialTimeout = 5 * time.Second
+
+// ingressProxy forwards TCP connections accepted on a host port to a port
+// inside a microVM. The host reaches the guest's address over the bridge,
+// so connections enter the VM's network namespace like any other traffic.
+type ingressProxy struct {
+	listener net.Listener
+	target   string
+	url      string
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// startIngress listens on an ephemeral port of host and forwards connections
+// to guestIP:port.
+func startIngress(host string, guestIP netip.Addr, port int, logger *slog.Logger) (*ingressProxy, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", host, err)
+	}
+
+	p := &ingressProxy{
+		listener: l,
+		target:   net.JoinHostPort(guestIP.String(), strconv.Itoa(port)),
+		url:      "http://" + net.JoinHostPort(advertisedHost(host), strconv.Itoa(l.Addr().(*net.TCPAddr).Port)),
+		logger:   logger,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	p.wg.Go(p.serve)
+	return p, nil
+}
+
+// advertisedHost returns the host name to publish in endpoint URLs: the
+// listen address itself, or the machine's host name when listening on all
+// addresses.
+func advertisedHost(host string) string {
+	if addr, err := netip.ParseAddr(host); err == nil && !addr.IsUnspecified() {
+		return host
+	}
+	if host != "" && net.ParseIP(host) == nil {
+		return host
+	}
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "localhost"
+}
+
+// URL returns the endpoint URL for the exposed port.
+func (p *ingressProxy) URL() string {
+	return p.url
+}
+
+func (p *ingressProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				p.logger.Warn("ingress accept failed", "target", p.target, "error", err)
+			}
+			return
+		}
+		if !p.track(conn) {
+			conn.Close()
+			return
+		}
+		p.wg.Go(func() { p.forward(conn) })
+	}
+}
+
+// forward copies data between a client connection and the guest until
+// either side closes.
+func (p *ingressProxy) forward(client net.Conn) {
+	defer p.untrack(client)
+
+	upstream, err := net.DialTimeout("tcp", p.target, ingressDialTimeout)
+	if err != nil {
+		p.logger.Debug("ingress dial failed", "target", p.target, "error", err)
+		return
+	}
+	if !p.track(upstream) {
+		upstream.Close()
+		return
+	}
+	defer p.untrack(upstream)
+
+	var wg sync.WaitGroup
+	wg.Go(func() { pipe(upstream, client) })
+	pipe(client, upstream)
+	wg.Wait()
+}
+
+// pipe copies src to dst, then half-closes dst so the peer sees EOF.
+func pipe(dst, src net.Conn) {
+	io.Copy(dst, src)
+	if tcp, ok := dst.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	} else {
+		dst.Close()
+	}
+}
+
+// track registers an open connection; it reports false once the proxy is closed.
+func (p *ingressProxy) track(c net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.conns[c] = struct{}{}
+	return true
+}
+
+func (p *ingressProxy) untrack(c net.Conn) {
+	p.mu.Lock()
+	delete(p.conns, c)
+	p.mu.Unlock()
+	c.Close()
+}
+
+// Close stops accepting connections, drops open ones and waits for the
+// forwarding goroutines to exit.
+func (p *ingressProxy) Close() {
+	p.mu.Lock()
+	p.closed = true
+	for c := range p.conns {
+		c.Close()
+	}
+	p.mu.Unlock()
+
+	p.listener.Close()
+	p.wg.Wait()
+}
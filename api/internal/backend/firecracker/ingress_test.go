@@ -0,0 +1,138 @@
+package firecracker
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoListener starts a TCP server on loopback that echoes each line back
+// prefixed with "echo: ".
+func echoListener(t *testing.T) *net.TCPAddr {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					io.WriteString(conn, "echo: "+line)
+				}
+			}()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr)
+}
+
+func TestIngressForwards(t *testing.T) {
+	upstream := echoListener(t)
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	p, err := startIngress("127.0.0.1", netip.MustParseAddr("127.0.0.1"), upstream.Port, logger)
+	if err != nil {
+		t.Fatalf("startIngress: %v", err)
+	}
+	defer p.Close()
+
+	if !strings.HasPrefix(p.URL(), "http://127.0.0.1:") {
+		t.Fatalf("URL = %q, want http://127.0.0.1:<port>", p.URL())
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(p.URL(), "http://"))
+	if err != nil {
+		t.Fatalf("dial ingress: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	for _, msg := range []string{"hello\n", "again\n"} {
+		io.WriteString(conn, msg)
+		got, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if got != "echo: "+msg {
+			t.Errorf("got %q, want %q", got, "echo: "+msg)
+		}
+	}
+}
+
+func TestIngressCloseDropsConnections(t *testing.T) {
+	upstream := echoListener(t)
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	p, err := startIngress("127.0.0.1", netip.MustParseAddr("127.0.0.1"), upstream.Port, logger)
+	if err != nil {
+		t.Fatalf("startIngress: %v", err)
+	}
+	addr := strings.TrimPrefix(p.URL(), "http://")
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial ingress: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "ping\n")
+	if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return with an open connection")
+	}
+
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("read after Close succeeded, want the connection dropped")
+	}
+	if c, err := net.Dial("tcp", addr); err == nil {
+		c.Close()
+		t.Error("dial after Close succeeded, want the listener closed")
+	}
+}
+
+func TestAdvertisedHost(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1":       "127.0.0.1",
+		"10.0.0.5":        "10.0.0.5",
+		"::1":             "::1",
+		"vulcan.internal": "vulcan.internal",
+	}
+	for host, want := range tests {
+		if got := advertisedHost(host); got != want {
+			t.Errorf("advertisedHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+
+	for _, host := range []string{"", "0.0.0.0", "::"} {
+		if got := advertisedHost(host); got == "" || got == host {
+			t.Errorf("advertisedHost(%q) = %q, want the machine's host name", host, got)
+		}
+	}
+}
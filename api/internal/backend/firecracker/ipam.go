@@ -0,0 +1,213 @@
+package firecracker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// MTU bounds for the bridge and guest interfaces.
+const (
+	minMTU     = 576
+	minMTUIPv6 = 1280
+	maxMTU     = 9000
+)
+
+// maxBridgeNameLen is the longest Linux interface name (IFNAMSIZ - 1).
+const maxBridgeNameLen = 15
+
+// AddressPool is a subnet microVMs are allocated addresses from, together
+// with the gateway address the bridge holds on it.
+type AddressPool struct {
+	Subnet  netip.Prefix
+	Gateway netip.Addr
+}
+
+// AddressPools parses the configured subnets into address pools. The first
+// IPv4 pool uses cfg.Gateway when set; every other pool's gateway is its
+// first host address.
+func (c Config) AddressPools() ([]AddressPool, error) {
+	if len(c.Subnets) == 0 {
+		return nil, errors.New("no subnets configured")
+	}
+
+	var pools []AddressPool
+	haveIPv4 := false
+	for _, s := range c.Subnets {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("parse subnet %q: %w", s, err)
+		}
+		if prefix != prefix.Masked() {
+			return nil, fmt.Errorf("subnet %s has host bits set, use %s", prefix, prefix.Masked())
+		}
+		if prefix.Addr().IsLinkLocalUnicast() || prefix.Addr().IsLoopback() || prefix.Addr().IsMulticast() {
+			return nil, fmt.Errorf("subnet %s is not usable for VM addresses", prefix)
+		}
+		if prefix.Addr().BitLen()-prefix.Bits() < 2 {
+			return nil, fmt.Errorf("subnet %s is too small", prefix)
+		}
+		for _, p := range pools {
+			if p.Subnet.Overlaps(prefix) {
+				return nil, fmt.Errorf("subnets %s and %s overlap", p.Subnet, prefix)
+			}
+		}
+
+		pool := AddressPool{Subnet: prefix, Gateway: prefix.Addr().Next()}
+		if prefix.Addr().Is4() && !haveIPv4 {
+			haveIPv4 = true
+			if c.Gateway != "" {
+				gw, err := netip.ParseAddr(c.Gateway)
+				if err != nil {
+					return nil, fmt.Errorf("parse gateway %q: %w", c.Gateway, err)
+				}
+				if !prefix.Contains(gw) || gw == prefix.Addr() {
+					return nil, fmt.Errorf("gateway %s is not a host address in subnet %s", gw, prefix)
+				}
+				pool.Gateway = gw
+			}
+		}
+		pools = append(pools, pool)
+	}
+
+	// Guests are configured through the kernel's ip= parameter, which is
+	// IPv4 only; IPv6 pools add a second address alongside it.
+	if !haveIPv4 {
+		return nil, errors.New("at least one IPv4 subnet is required")
+	}
+	return pools, nil
+}
+
+// validateNetwork checks the bridge, MTU and DNS settings and returns the
+// parsed address pools.
+func (c Config) validateNetwork() ([]AddressPool, error) {
+	if c.BridgeName == "" || len(c.BridgeName) > maxBridgeNameLen {
+		return nil, fmt.Errorf("bridge name %q must be 1-%d characters", c.BridgeName, maxBridgeNameLen)
+	}
+
+	pools, err := c.AddressPools()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.MTU != 0 {
+		lowest := minMTU
+		for _, p := range pools {
+			if p.Subnet.Addr().Is6() {
+				lowest = minMTUIPv6
+			}
+		}
+		if c.MTU < lowest || c.MTU > maxMTU {
+			return nil, fmt.Errorf("MTU %d out of range %d-%d", c.MTU, lowest, maxMTU)
+		}
+	}
+
+	if len(c.Nameservers) > 2 {
+		return nil, fmt.Errorf("at most 2 nameservers are supported, got %d", len(c.Nameservers))
+	}
+	for _, ns := range c.Nameservers {
+		if _, err := netip.ParseAddr(ns); err != nil {
+			return nil, fmt.Errorf("parse nameserver %q: %w", ns, err)
+		}
+	}
+	return pools, nil
+}
+
+// GroupRange parses the range network groups' subnets are carved from. Each
+// group gets a /GroupPrefixLen subnet of it; an empty GroupSubnets disables
+// network groups and returns the zero prefix. The range must be IPv4 and must
+// not overlap pools, the default bridge's address pools.
+func (c Config) GroupRange(pools []AddressPool) (netip.Prefix, error) {
+	if c.GroupSubnets == "" {
+		return netip.Prefix{}, nil
+	}
+	prefix, err := netip.ParsePrefix(c.GroupSubnets)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("parse group subnets %q: %w", c.GroupSubnets, err)
+	}
+	if prefix != prefix.Masked() {
+		return netip.Prefix{}, fmt.Errorf("group subnets %s has host bits set, use %s", prefix, prefix.Masked())
+	}
+	if !prefix.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("group subnets %s must be IPv4", prefix)
+	}
+	if prefix.Bits() > GroupPrefixLen {
+		return netip.Prefix{}, fmt.Errorf("group subnets %s is smaller than one /%d group subnet", prefix, GroupPrefixLen)
+	}
+	for _, p := range pools {
+		if p.Subnet.Overlaps(prefix) {
+			return netip.Prefix{}, fmt.Errorf("group subnets %s overlap subnet %s", prefix, p.Subnet)
+		}
+	}
+	return prefix, nil
+}
+
+// groupPool returns the address pool of the index-th group subnet in r, or
+// false if r has no such subnet.
+func groupPool(r netip.Prefix, index int) (AddressPool, bool) {
+	if index < 0 || index >= 1<<(GroupPrefixLen-r.Bits()) {
+		return AddressPool{}, false
+	}
+	addr := r.Addr().As4()
+	n := binary.BigEndian.Uint32(addr[:]) + uint32(index)<<(32-GroupPrefixLen)
+	binary.BigEndian.PutUint32(addr[:], n)
+	subnet := netip.PrefixFrom(netip.AddrFrom4(addr), GroupPrefixLen)
+	return AddressPool{Subnet: subnet, Gateway: subnet.Addr().Next()}, true
+}
+
+// hostRoute is a destination route in the host's main routing table.
+type hostRoute struct {
+	dst netip.Prefix
+	dev string
+}
+
+// hostRoutes lists the host's non-default routes.
+func hostRoutes() ([]hostRoute, error) {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, fmt.Errorf("list host routes: %w", err)
+	}
+
+	devs := make(map[int]string)
+	var out []hostRoute
+	for _, r := range routes {
+		if r.Dst == nil {
+			continue
+		}
+		ones, _ := r.Dst.Mask.Size()
+		addr, ok := netip.AddrFromSlice(r.Dst.IP)
+		if !ok || ones == 0 {
+			continue
+		}
+		dev, ok := devs[r.LinkIndex]
+		if !ok {
+			if link, err := netlink.LinkByIndex(r.LinkIndex); err == nil {
+				dev = link.Attrs().Name
+			}
+			devs[r.LinkIndex] = dev
+		}
+		out = append(out, hostRoute{dst: netip.PrefixFrom(addr.Unmap(), ones), dev: dev})
+	}
+	return out, nil
+}
+
+// checkRouteOverlap returns an error if a pool overlaps a host route, which
+// would make the route ambiguous between VMs and the host's network. Routes
+// via the bridge itself or a group bridge are the pools' own and are ignored.
+func checkRouteOverlap(pools []AddressPool, routes []hostRoute, bridge string) error {
+	for _, p := range pools {
+		for _, r := range routes {
+			if r.dev == bridge || strings.HasPrefix(r.dev, GroupBridgePrefix) {
+				continue
+			}
+			if p.Subnet.Overlaps(r.dst) {
+				return fmt.Errorf("subnet %s overlaps host route %s dev %s", p.Subnet, r.dst, r.dev)
+			}
+		}
+	}
+	return nil
+}
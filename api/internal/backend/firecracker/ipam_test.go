@@ -0,0 +1,159 @@
+package firecracker
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestAddressPoolsDefault(t *testing.T) {
+	pools, err := Config{Subnets: []string{DefaultSubnet}}.AddressPools()
+	if err != nil {
+		t.Fatalf("AddressPools: %v", err)
+	}
+	if len(pools) != 1 {
+		t.Fatalf("pools = %d, want 1", len(pools))
+	}
+	if pools[0].Gateway.String() != DefaultGateway {
+		t.Errorf("gateway = %s, want %s", pools[0].Gateway, DefaultGateway)
+	}
+}
+
+func TestAddressPoolsGatewayOverride(t *testing.T) {
+	cfg := Config{Subnets: []string{"fd00:168::/64", "10.200.0.0/16", "10.201.0.0/16"}, Gateway: "10.200.255.254"}
+	pools, err := cfg.AddressPools()
+	if err != nil {
+		t.Fatalf("AddressPools: %v", err)
+	}
+	want := []string{"fd00:168::1", "10.200.255.254", "10.201.0.1"}
+	for i, p := range pools {
+		if p.Gateway.String() != want[i] {
+			t.Errorf("pool %s gateway = %s, want %s", p.Subnet, p.Gateway, want[i])
+		}
+	}
+}
+
+func TestAddressPoolsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"none", Config{}, "no subnets"},
+		{"unparseable", Config{Subnets: []string{"10.168.0.0"}}, "parse subnet"},
+		{"host bits", Config{Subnets: []string{"10.168.0.5/24"}}, "host bits"},
+		{"too small", Config{Subnets: []string{"10.168.0.0/31"}}, "too small"},
+		{"link local", Config{Subnets: []string{"169.254.0.0/16"}}, "not usable"},
+		{"overlap", Config{Subnets: []string{"10.168.0.0/16", "10.168.4.0/24"}}, "overlap"},
+		{"ipv6 only", Config{Subnets: []string{"fd00:168::/64"}}, "IPv4 subnet is required"},
+		{"gateway outside", Config{Subnets: []string{DefaultSubnet}, Gateway: "10.169.0.1"}, "not a host address"},
+		{"gateway network address", Config{Subnets: []string{DefaultSubnet}, Gateway: "10.168.0.0"}, "not a host address"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.cfg.AddressPools()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("AddressPools() error = %v, want containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateNetwork(t *testing.T) {
+	valid := testNetworkConfig()
+	if _, err := valid.validateNetwork(); err != nil {
+		t.Fatalf("validateNetwork(defaults): %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+	}{
+		{"empty bridge", func(c *Config) { c.BridgeName = "" }},
+		{"long bridge", func(c *Config) { c.BridgeName = "vulcan-bridge-too-long" }},
+		{"mtu too small", func(c *Config) { c.MTU = 500 }},
+		{"mtu too large", func(c *Config) { c.MTU = 9500 }},
+		{"mtu below ipv6 minimum", func(c *Config) {
+			c.Subnets = []string{DefaultSubnet, "fd00:168::/64"}
+			c.MTU = 1000
+		}},
+		{"three nameservers", func(c *Config) { c.Nameservers = []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} }},
+		{"bad nameserver", func(c *Config) { c.Nameservers = []string{"dns.example"} }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testNetworkConfig()
+			tc.mutate(&cfg)
+			if _, err := cfg.validateNetwork(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestCheckRouteOverlap(t *testing.T) {
+	pools := []AddressPool{{
+		Subnet:  netip.MustParsePrefix("10.168.0.0/24"),
+		Gateway: netip.MustParseAddr("10.168.0.1"),
+	}}
+
+	tests := []struct {
+		name    string
+		routes  []hostRoute
+		wantErr bool
+	}{
+		{"disjoint", []hostRoute{{dst: netip.MustParsePrefix("192.168.1.0/24"), dev: "eth0"}}, false},
+		{"own bridge", []hostRoute{{dst: netip.MustParsePrefix("10.168.0.0/24"), dev: DefaultBridgeName}}, false},
+		{"lan covers pool", []hostRoute{{dst: netip.MustParsePrefix("10.0.0.0/8"), dev: "eth0"}}, true},
+		{"route inside pool", []hostRoute{{dst: netip.MustParsePrefix("10.168.0.128/25"), dev: "wg0"}}, true},
+		{"group bridge", []hostRoute{{dst: netip.MustParsePrefix("10.168.0.0/24"), dev: GroupBridgePrefix + "3"}}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkRouteOverlap(pools, tc.routes, DefaultBridgeName)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkRouteOverlap() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestGroupRange(t *testing.T) {
+	pools := testPools(t, DefaultSubnet)
+
+	r, err := Config{GroupSubnets: DefaultGroupSubnets}.GroupRange(pools)
+	if err != nil || r.String() != DefaultGroupSubnets {
+		t.Fatalf("GroupRange(default) = %s, %v; want %s", r, err, DefaultGroupSubnets)
+	}
+	if r, err := (Config{}).GroupRange(pools); err != nil || r.IsValid() {
+		t.Errorf("GroupRange(disabled) = %s, %v; want zero prefix", r, err)
+	}
+
+	for name, subnets := range map[string]string{
+		"unparseable":    "10.169.0.0",
+		"host bits":      "10.169.0.1/16",
+		"ipv6":           "fd00:169::/48",
+		"too small":      "10.169.0.0/25",
+		"overlaps pools": "10.168.0.0/16",
+	} {
+		if _, err := (Config{GroupSubnets: subnets}).GroupRange(pools); err == nil {
+			t.Errorf("GroupRange(%s: %s) = nil error, want error", name, subnets)
+		}
+	}
+}
+
+func TestGroupPool(t *testing.T) {
+	r := netip.MustParsePrefix("10.169.0.0/22")
+	for index, want := range []string{"10.169.0.0/24", "10.169.1.0/24", "10.169.2.0/24", "10.169.3.0/24"} {
+		p, ok := groupPool(r, index)
+		if !ok || p.Subnet.String() != want {
+			t.Errorf("groupPool(%d) = %s, %v; want %s", index, p.Subnet, ok, want)
+		}
+		if p.Gateway != p.Subnet.Addr().Next() {
+			t.Errorf("groupPool(%d) gateway = %s, want first host", index, p.Gateway)
+		}
+	}
+	if _, ok := groupPool(r, 4); ok {
+		t.Error("groupPool beyond the range succeeded")
+	}
+}
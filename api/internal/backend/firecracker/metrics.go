@@ -9,6 +9,14 @@ const (
 	statusKilled    = "killed"
 )
 
+// Metric label values for resource usage.
+const (
+	cpuModeUser      = "user"
+	cpuModeSystem    = "system"
+	ioDirectionRead  = "read"
+	ioDirectionWrite = "write"
+)
+
 var (
 	vmBootDuration = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
@@ -41,6 +49,51 @@ var (
 		},
 	)
 
+	workloadCPUSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vulcan_firecracker_workload_cpu_seconds",
+			Help:    "CPU time consumed by a workload's process tree, in seconds.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		},
+		[]string{"runtime", "mode"},
+	)
+
+	workloadPeakRSSBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vulcan_firecracker_workload_peak_rss_bytes",
+			Help:    "Peak resident set size of a workload's largest process, in bytes.",
+			Buckets: prometheus.ExponentialBuckets(4<<20, 2, 9),
+		},
+		[]string{"runtime"},
+	)
+
+	workloadPeakMemoryBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vulcan_firecracker_workload_peak_memory_bytes",
+			Help:    "Peak memory of all of a workload's processes together, page cache included, in bytes.",
+			Buckets: prometheus.ExponentialBuckets(4<<20, 2, 9),
+		},
+		[]string{"runtime"},
+	)
+
+	workloadIOBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vulcan_firecracker_workload_io_bytes",
+			Help:    "Block I/O performed by a workload's process tree, in bytes.",
+			Buckets: prometheus.ExponentialBuckets(64<<10, 4, 8),
+		},
+		[]string{"runtime", "direction"},
+	)
+
+	workloadScratchUsedBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vulcan_firecracker_workload_scratch_used_bytes",
+			Help:    "Space a workload took on its scratch disk, in bytes.",
+			Buckets: prometheus.ExponentialBuckets(1<<20, 4, 8),
+		},
+		[]string{"runtime"},
+	)
+
 	workloadsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "vulcan_firecracker_workloads_total",
@@ -48,6 +101,54 @@ var (
 		},
 		[]string{"runtime", "status"},
 	)
+
+	serviceRestartsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_service_restarts_total",
+			Help: "Total number of times the guest agent restarted a service's process.",
+		},
+		[]string{"runtime"},
+	)
+
+	sessionExecsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_session_execs_total",
+			Help: "Total number of executions run in session VMs.",
+		},
+		[]string{"runtime", "status"},
+	)
+
+	leakedResourcesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_leaked_resources_total",
+			Help: "Total number of resources leaked by finished VMs that the sweeper tried to remove.",
+		},
+		[]string{"kind"},
+	)
+
+	sweepFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_sweep_failures_total",
+			Help: "Total number of leaked resources the sweeper failed to remove.",
+		},
+		[]string{"kind"},
+	)
+
+	depsCacheTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_deps_cache_total",
+			Help: "Total number of dependency layer lookups, by whether a cached layer was found.",
+		},
+		[]string{"result"},
+	)
+
+	goBuildCacheTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_go_build_cache_total",
+			Help: "Total number of compiled Go program lookups, by whether a cached program was found.",
+		},
+		[]string{"result"},
+	)
 )
 
 func init() {
@@ -56,12 +157,67 @@ func init() {
 	prometheus.MustRegister(vsockWorkloadDuration)
 	prometheus.MustRegister(vmCleanupDuration)
 	prometheus.MustRegister(workloadsTotal)
+	prometheus.MustRegister(workloadCPUSeconds)
+	prometheus.MustRegister(workloadPeakRSSBytes)
+	prometheus.MustRegister(workloadIOBytes)
+	prometheus.MustRegister(workloadPeakMemoryBytes)
+	prometheus.MustRegister(workloadScratchUsedBytes)
+	prometheus.MustRegister(serviceRestartsTotal)
+	prometheus.MustRegister(sessionExecsTotal)
+	prometheus.MustRegister(leakedResourcesTotal)
+	prometheus.MustRegister(sweepFailuresTotal)
+	prometheus.MustRegister(depsCacheTotal)
+	prometheus.MustRegister(goBuildCacheTotal)
 
 	// Pre-initialize counter label combinations so they appear in /metrics
 	// with value 0 from startup, rather than only after first observation.
-	for _, rt := range SupportedRuntimes {
-		workloadsTotal.WithLabelValues(rt, statusCompleted)
-		workloadsTotal.WithLabelValues(rt, statusFailed)
-		workloadsTotal.WithLabelValues(rt, statusKilled)
+	// Runtimes beyond the built-in ones are added once discovered.
+	for _, rt := range DefaultRuntimes.Names() {
+		initRuntimeMetrics(rt)
+	}
+	for _, kind := range leakKinds {
+		leakedResourcesTotal.WithLabelValues(kind)
+		sweepFailuresTotal.WithLabelValues(kind)
+	}
+	depsCacheTotal.WithLabelValues(depsCacheHit)
+	depsCacheTotal.WithLabelValues(depsCacheMiss)
+	goBuildCacheTotal.WithLabelValues(buildCacheHit)
+	goBuildCacheTotal.WithLabelValues(buildCacheMiss)
+}
+
+// initRuntimeMetrics pre-initializes the label combinations of a runtime's
+// metrics.
+func initRuntimeMetrics(rt string) {
+	workloadsTotal.WithLabelValues(rt, statusCompleted)
+	workloadsTotal.WithLabelValues(rt, statusFailed)
+	workloadsTotal.WithLabelValues(rt, statusKilled)
+	workloadCPUSeconds.WithLabelValues(rt, cpuModeUser)
+	workloadCPUSeconds.WithLabelValues(rt, cpuModeSystem)
+	workloadPeakRSSBytes.WithLabelValues(rt)
+	workloadPeakMemoryBytes.WithLabelValues(rt)
+	workloadScratchUsedBytes.WithLabelValues(rt)
+	serviceRestartsTotal.WithLabelValues(rt)
+	sessionExecsTotal.WithLabelValues(rt, statusCompleted)
+	sessionExecsTotal.WithLabelValues(rt, statusFailed)
+	sessionExecsTotal.WithLabelValues(rt, statusKilled)
+	workloadIOBytes.WithLabelValues(rt, ioDirectionRead)
+	workloadIOBytes.WithLabelValues(rt, ioDirectionWrite)
+}
+
+// observeUsage records a workload's resource usage in the per-runtime histograms.
+func observeUsage(runtime string, u *ResourceUsage) {
+	if u == nil {
+		return
+	}
+	workloadCPUSeconds.WithLabelValues(runtime, cpuModeUser).Observe(float64(u.CPUUserMS) / 1000)
+	workloadCPUSeconds.WithLabelValues(runtime, cpuModeSystem).Observe(float64(u.CPUSysMS) / 1000)
+	workloadPeakRSSBytes.WithLabelValues(runtime).Observe(float64(u.PeakRSSKB) * 1024)
+	workloadIOBytes.WithLabelValues(runtime, ioDirectionRead).Observe(float64(u.IOReadBytes))
+	workloadIOBytes.WithLabelValues(runtime, ioDirectionWrite).Observe(float64(u.IOWriteBytes))
+	if u.PeakMemoryKB > 0 {
+		workloadPeakMemoryBytes.WithLabelValues(runtime).Observe(float64(u.PeakMemoryKB) * 1024)
+	}
+	if u.DiskUsedBytes > 0 {
+		workloadScratchUsedBytes.WithLabelValues(runtime).Observe(float64(u.DiskUsedBytes))
 	}
 }
@@ -6,16 +6,21 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"net/netip"
 	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/containernetworking/cni/libcni"
 	"github.com/containernetworking/cni/pkg/types"
 	types100 "github.com/containernetworking/cni/pkg/types/100"
+	fcsdk "github.com/firecracker-microvm/firecracker-go-sdk"
+
+	"github.com/seantiz/vulcan/internal/model"
 )
 
 // Networking defaults for the Firecracker CNI bridge.
@@ -23,10 +28,10 @@ const (
 	// DefaultBridgeName is the Linux bridge device for microVM networking.
 	DefaultBridgeName = "fcbr0"
 
-	// DefaultSubnet is the CIDR subnet for microVM IP allocation.
+	// DefaultSubnet is the default CIDR subnet for microVM IP allocation.
 	DefaultSubnet = "10.168.0.0/24"
 
-	// DefaultGateway is the gateway IP address on the bridge.
+	// DefaultGateway is the gateway IP address on the bridge for DefaultSubnet.
 	DefaultGateway = "10.168.0.1"
 
 	// CNINetworkName is the CNI network name used in conflist.
@@ -38,6 +43,10 @@ const (
 	// CNIIfName is the interface name inside the network namespace.
 	CNIIfName = "eth0"
 
+	// GuestIfName is the name of the microVM's network interface as seen by
+	// the guest kernel.
+	GuestIfName = "eth0"
+
 	// CNICacheDir is the directory for CNI result caching.
 	CNICacheDir = "/var/lib/cni/cache"
 
@@ -46,8 +55,39 @@ const (
 
 	// NetNSPrefix is the prefix for per-VM namespace names.
 	NetNSPrefix = "vulcan-"
+
+	// DefaultGroupSubnets is the default range network group subnets are
+	// allocated from.
+	DefaultGroupSubnets = "10.169.0.0/16"
+
+	// GroupPrefixLen is the prefix length of each network group's subnet.
+	GroupPrefixLen = 24
+
+	// GroupBridgePrefix prefixes the bridge device of each network group,
+	// followed by the group subnet's index in the group range.
+	GroupBridgePrefix = "vgrp"
+)
+
+// Kernel command line parameters carrying the guest network settings that
+// the kernel's ip= parameter cannot express.
+const (
+	// BootParamMTU is the guest interface MTU.
+	BootParamMTU = "vulcan.mtu"
+
+	// BootParamIP6 is the guest's IPv6 address in CIDR notation.
+	BootParamIP6 = "vulcan.ip6"
+
+	// BootParamGateway6 is the guest's IPv6 default gateway.
+	BootParamGateway6 = "vulcan.gw6"
+
+	// BootParamSearch is the guest's comma-separated DNS search domains.
+	BootParamSearch = "vulcan.search"
 )
 
+// DefaultNameservers are the default DNS servers handed to guests. The
+// kernel's ip= parameter carries at most two.
+var DefaultNameservers = []string{"1.1.1.1", "8.8.8.8"}
+
 // Required CNI plugins for Firecracker networking.
 var requiredCNIPlugins = []string{"bridge", "host-local", "tc-redirect-tap"}
 
@@ -62,38 +102,136 @@ type NetworkConfig struct {
 	// GatewayIP is the gateway address for the guest.
 	GatewayIP string
 
+	// GuestIP6 is the guest's IPv6 address (CIDR notation), empty unless an
+	// IPv6 pool is configured.
+	GuestIP6 string
+
+	// GatewayIP6 is the IPv6 gateway address for the guest.
+	GatewayIP6 string
+
+	// MTU is the guest interface MTU, or zero for the default.
+	MTU int
+
 	// MACAddress is the MAC address of the guest interface.
 	MACAddress string
 
+	// Nameservers are the DNS servers the guest should use.
+	Nameservers []string
+
+	// SearchDomains are the DNS search domains the guest should use.
+	SearchDomains []string
+
 	// NamespacePath is the full path to the network namespace.
 	NamespacePath string
 }
 
+// IPConfiguration returns the guest's static IP settings. The Firecracker SDK
+// renders them as the kernel's ip= boot parameter, which the guest agent reads
+// to configure its interface, default route and resolv.conf.
+func (c *NetworkConfig) IPConfiguration() (*fcsdk.IPConfiguration, error) {
+	ip, ipNet, err := net.ParseCIDR(c.GuestIP)
+	if err != nil {
+		return nil, fmt.Errorf("parse guest IP %q: %w", c.GuestIP, err)
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("guest IP %s is not IPv4", c.GuestIP)
+	}
+	gateway := net.ParseIP(c.GatewayIP)
+	if gateway == nil {
+		return nil, fmt.Errorf("parse gateway IP %q", c.GatewayIP)
+	}
+
+	return &fcsdk.IPConfiguration{
+		IPAddr:      net.IPNet{IP: ip, Mask: ipNet.Mask},
+		Gateway:     gateway,
+		Nameservers: c.Nameservers[:min(len(c.Nameservers), 2)],
+		IfName:      GuestIfName,
+	}, nil
+}
+
+// KernelArgs returns the boot parameters for the settings the ip= parameter
+// cannot carry, each preceded by a space, or "" if there are none.
+func (c *NetworkConfig) KernelArgs() string {
+	var b strings.Builder
+	if c.MTU > 0 {
+		fmt.Fprintf(&b, " %s=%d", BootParamMTU, c.MTU)
+	}
+	if c.GuestIP6 != "" {
+		fmt.Fprintf(&b, " %s=%s", BootParamIP6, c.GuestIP6)
+		if c.GatewayIP6 != "" {
+			fmt.Fprintf(&b, " %s=%s", BootParamGateway6, c.GatewayIP6)
+		}
+	}
+	if len(c.SearchDomains) > 0 {
+		fmt.Fprintf(&b, " %s=%s", BootParamSearch, strings.Join(c.SearchDomains, ","))
+	}
+	return b.String()
+}
+
 // ipForwardPath is the sysctl path to enable IPv4 forwarding.
 const ipForwardPath = "/proc/sys/net/ipv4/ip_forward"
 
+// ip6ForwardPath is the sysctl path to enable IPv6 forwarding on all
+// interfaces, which dual-stack pools need to route guest traffic.
+const ip6ForwardPath = "/proc/sys/net/ipv6/conf/all/forwarding"
+
 // NetworkManager handles CNI-based networking for Firecracker microVMs.
 type NetworkManager struct {
 	cniBinDir     string
 	cniConfigDir  string
+	nftBin        string
+	bridgeName    string
+	pools         []AddressPool
+	mtu           int
 	cniConfig     *libcni.CNIConfig
 	confList      *libcni.NetworkConfigList
 	confListBytes []byte // cached conflist JSON for WriteConfList
+	nameservers   []string
+	groupRange    netip.Prefix // zero when network groups are disabled
 	logger        *slog.Logger
 
-	mu         sync.Mutex
-	namespaces map[string]string // vmID → namespace path
+	mu           sync.Mutex
+	namespaces   map[string]string        // vmID → namespace path
+	groups       map[string]*groupNetwork // group name → network
+	groupIndexes map[int]bool             // group subnet indexes in use or being released
+	vmGroups     map[string]string        // vmID → group name, for grouped VMs
+}
+
+// NetworkRequest describes the network a microVM is attached to.
+type NetworkRequest struct {
+	// Policy is the VM's network policy; nil means full access.
+	Policy *model.NetworkPolicy
+
+	// IngressPort is a guest TCP port the host forwards connections to, or 0.
+	IngressPort int
+
+	// Group attaches the VM to the network group's private bridge instead of
+	// the default one, and Name registers it in the group's DNS zone.
+	Group string
+	Name  string
 }
 
 // NewNetworkManager creates a NetworkManager with the given CNI configuration.
+// It returns an error if the bridge, address pool, MTU or DNS settings are
+// invalid.
 func NewNetworkManager(cfg Config, logger *slog.Logger) (*NetworkManager, error) {
+	pools, err := cfg.validateNetwork()
+	if err != nil {
+		return nil, fmt.Errorf("network config: %w", err)
+	}
+
 	cniConfig := libcni.NewCNIConfigWithCacheDir(
 		[]string{cfg.CNIBinDir},
 		CNICacheDir,
 		nil,
 	)
 
-	confBytes, err := generateConfList()
+	groupRange, err := cfg.GroupRange(pools)
+	if err != nil {
+		return nil, fmt.Errorf("network config: %w", err)
+	}
+
+	confBytes, err := generateConfList(defaultNetwork(cfg, pools))
 	if err != nil {
 		return nil, fmt.Errorf("generate CNI conflist: %w", err)
 	}
@@ -106,22 +244,61 @@ func NewNetworkManager(cfg Config, logger *slog.Logger) (*NetworkManager, error)
 	return &NetworkManager{
 		cniBinDir:     cfg.CNIBinDir,
 		cniConfigDir:  cfg.CNIConfigDir,
+		nftBin:        cfg.NFTBin,
+		bridgeName:    cfg.BridgeName,
+		pools:         pools,
+		mtu:           cfg.MTU,
 		cniConfig:     cniConfig,
 		confList:      confList,
 		confListBytes: confBytes,
+		nameservers:   cfg.Nameservers,
+		groupRange:    groupRange,
 		logger:        logger,
 		namespaces:    make(map[string]string),
+		groups:        make(map[string]*groupNetwork),
+		groupIndexes:  make(map[int]bool),
+		vmGroups:      make(map[string]string),
 	}, nil
 }
 
-// Setup creates a network namespace and configures networking for a microVM.
+// Setup creates a network namespace and configures networking for a microVM,
+// enforcing the request's policy with nftables rules inside the namespace.
+// Workloads with mode none need no networking and must not be passed to
+// Setup. VMs in a network group attach to the group's private bridge and are
+// registered in its DNS zone.
 // Returns the network configuration including the TAP device name and guest IP.
-func (nm *NetworkManager) Setup(ctx context.Context, vmID string) (*NetworkConfig, error) {
+func (nm *NetworkManager) Setup(ctx context.Context, vmID string, req NetworkRequest) (*NetworkConfig, error) {
+	confList := nm.confList
+	att := attachment{
+		pools:       nm.pools,
+		ingressPort: req.IngressPort,
+	}
+	if nm.groupRange.IsValid() {
+		att.isolated = []netip.Prefix{nm.groupRange}
+	}
+
+	var group *groupNetwork
+	if req.Group != "" {
+		var err error
+		if group, err = nm.joinGroup(req.Group, vmID, req.Name); err != nil {
+			return nil, err
+		}
+		confList = group.confList
+		att = nm.groupAttachment(group, req.IngressPort)
+	}
+
+	ruleset, err := policyRuleset(req.Policy, att)
+	if err != nil {
+		nm.leaveGroup(vmID)
+		return nil, fmt.Errorf("network policy for %s: %w", vmID, err)
+	}
+
 	nsName := NetNSPrefix + vmID
 	nsPath := filepath.Join(NetNSRunDir, nsName)
 
 	// Create the network namespace.
 	if err := createNetNS(nsName); err != nil {
+		nm.leaveGroup(vmID)
 		return nil, fmt.Errorf("create netns %s: %w", nsName, err)
 	}
 
@@ -136,7 +313,7 @@ func (nm *NetworkManager) Setup(ctx context.Context, vmID string) (*NetworkConfi
 		IfName:      CNIIfName,
 	}
 
-	result, err := nm.cniConfig.AddNetworkList(ctx, nm.confList, rtConf)
+	result, err := nm.cniConfig.AddNetworkList(ctx, confList, rtConf)
 	if err != nil {
 		// Attempt cleanup on failure.
 		cleanupErr := deleteNetNS(nsName)
@@ -147,35 +324,57 @@ func (nm *NetworkManager) Setup(ctx context.Context, vmID string) (*NetworkConfi
 		nm.mu.Lock()
 		delete(nm.namespaces, vmID)
 		nm.mu.Unlock()
+		nm.leaveGroup(vmID)
 		return nil, fmt.Errorf("CNI ADD for %s: %w", vmID, err)
 	}
 
 	// Parse the CNI result.
 	netCfg, err := parseResult(result, nsPath)
 	if err != nil {
-		// Attempt full teardown on parse failure.
-		if delErr := nm.cniConfig.DelNetworkList(ctx, nm.confList, rtConf); delErr != nil {
-			nm.logger.Debug("cleanup CNI DEL after parse failure", "vmID", vmID, "error", delErr)
-		}
-		if nsErr := deleteNetNS(nsName); nsErr != nil {
-			nm.logger.Debug("cleanup netns after parse failure", "vmID", vmID, "error", nsErr)
-		}
-		nm.mu.Lock()
-		delete(nm.namespaces, vmID)
-		nm.mu.Unlock()
+		nm.abortSetup(ctx, vmID, confList, rtConf, "parse")
 		return nil, fmt.Errorf("parse CNI result for %s: %w", vmID, err)
 	}
+	netCfg.MTU = nm.mtu
+
+	// Enforce the network policy before the VM can send any traffic.
+	if err := applyRuleset(nm.nftBin, nsName, ruleset); err != nil {
+		nm.abortSetup(ctx, vmID, confList, rtConf, "policy")
+		return nil, fmt.Errorf("apply network policy for %s: %w", vmID, err)
+	}
+
+	if group != nil {
+		if err := nm.registerMember(group, vmID, req.Name, req.Policy, netCfg); err != nil {
+			nm.abortSetup(ctx, vmID, confList, rtConf, "group DNS")
+			return nil, fmt.Errorf("register %s in group %s: %w", vmID, req.Group, err)
+		}
+	}
 
 	nm.logger.Info("network setup complete",
 		"vmID", vmID,
 		"tap", netCfg.TAPDevice,
 		"guest_ip", netCfg.GuestIP,
 		"namespace", nsPath,
+		"network_mode", req.Policy.EffectiveMode(),
+		"group", req.Group,
 	)
 
 	return netCfg, nil
 }
 
+// abortSetup undoes a CNI ADD and the namespace after a later setup step failed.
+func (nm *NetworkManager) abortSetup(ctx context.Context, vmID string, confList *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf, step string) {
+	if delErr := nm.cniConfig.DelNetworkList(ctx, confList, rtConf); delErr != nil {
+		nm.logger.Debug("cleanup CNI DEL after "+step+" failure", "vmID", vmID, "error", delErr)
+	}
+	if nsErr := deleteNetNS(NetNSPrefix + vmID); nsErr != nil {
+		nm.logger.Debug("cleanup netns after "+step+" failure", "vmID", vmID, "error", nsErr)
+	}
+	nm.mu.Lock()
+	delete(nm.namespaces, vmID)
+	nm.mu.Unlock()
+	nm.leaveGroup(vmID)
+}
+
 // Teardown removes networking and the network namespace for a microVM.
 // Safe to call multiple times — subsequent calls are no-ops.
 func (nm *NetworkManager) Teardown(ctx context.Context, vmID string) error {
@@ -186,7 +385,12 @@ func (nm *NetworkManager) Teardown(ctx context.Context, vmID string) error {
 		return nil // Already torn down or never set up.
 	}
 	delete(nm.namespaces, vmID)
+	confList := nm.confList
+	if g := nm.groups[nm.vmGroups[vmID]]; g != nil {
+		confList = g.confList
+	}
 	nm.mu.Unlock()
+	defer nm.leaveGroup(vmID)
 
 	nsName := NetNSPrefix + vmID
 
@@ -198,7 +402,7 @@ func (nm *NetworkManager) Teardown(ctx context.Context, vmID string) error {
 	}
 
 	var firstErr error
-	if err := nm.cniConfig.DelNetworkList(ctx, nm.confList, rtConf); err != nil {
+	if err := nm.cniConfig.DelNetworkList(ctx, confList, rtConf); err != nil {
 		firstErr = fmt.Errorf("CNI DEL for %s: %w", vmID, err)
 		nm.logger.Warn("CNI DEL failed", "vmID", vmID, "error", err)
 	}
@@ -234,7 +438,10 @@ func (nm *NetworkManager) TeardownAll(ctx context.Context) {
 	}
 }
 
-// Verify checks that all required CNI plugins exist in the bin directory.
+// Verify checks that all required CNI plugins exist in the bin directory,
+// that nftables is available and supports the netdev egress hook to enforce
+// network policies, and that no address pool overlaps a host route. It also
+// enables IP forwarding for each address family the pools use.
 func (nm *NetworkManager) Verify() error {
 	var missing []string
 	for _, plugin := range requiredCNIPlugins {
@@ -252,6 +459,28 @@ func (nm *NetworkManager) Verify() error {
 	if len(missing) > 0 {
 		return fmt.Errorf("missing CNI plugins in %s: %s", nm.cniBinDir, strings.Join(missing, ", "))
 	}
+	if _, err := exec.LookPath(nm.nftBin); err != nil {
+		return fmt.Errorf("nftables CLI %q (required for network policies): %w", nm.nftBin, err)
+	}
+	if err := checkEgressHook(nm.nftBin); err != nil {
+		return err
+	}
+	if len(nm.pools) > 0 {
+		routes, err := hostRoutes()
+		if err != nil {
+			return err
+		}
+		pools := nm.pools
+		if nm.groupRange.IsValid() {
+			pools = append(slices.Clip(pools), AddressPool{Subnet: nm.groupRange})
+		}
+		if err := checkRouteOverlap(pools, routes, nm.bridgeName); err != nil {
+			return err
+		}
+		if err := EnsureIPForwarding(nm.pools); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -261,8 +490,13 @@ func (nm *NetworkManager) WriteConfList() error {
 		return fmt.Errorf("create CNI config dir: %w", err)
 	}
 
-	confPath := filepath.Join(nm.cniConfigDir, CNINetworkName+".conflist")
-	if err := os.WriteFile(confPath, nm.confListBytes, 0o644); err != nil {
+	return nm.writeConfList(CNINetworkName, nm.confListBytes)
+}
+
+// writeConfList writes the named network's conflist to the config directory.
+func (nm *NetworkManager) writeConfList(name string, data []byte) error {
+	confPath := filepath.Join(nm.cniConfigDir, name+".conflist")
+	if err := os.WriteFile(confPath, data, 0o644); err != nil {
 		return fmt.Errorf("write conflist: %w", err)
 	}
 
@@ -277,23 +511,71 @@ type confListJSON struct {
 	Plugins    []map[string]any `json:"plugins"`
 }
 
+// bridgeNetwork describes a CNI bridge network microVMs attach to.
+type bridgeNetwork struct {
+	name        string // CNI network name
+	bridge      string
+	pools       []AddressPool
+	mtu         int
+	nameservers []string
+	search      []string
+}
+
+// defaultNetwork returns the bridge network VMs outside any group attach to.
+func defaultNetwork(cfg Config, pools []AddressPool) bridgeNetwork {
+	return bridgeNetwork{
+		name:        CNINetworkName,
+		bridge:      cfg.BridgeName,
+		pools:       pools,
+		mtu:         cfg.MTU,
+		nameservers: cfg.Nameservers,
+	}
+}
+
 // generateConfList returns the CNI conflist JSON for bridge + tc-redirect-tap.
-func generateConfList() ([]byte, error) {
+// IPv4 pools form one host-local range set and IPv6 pools another, so each VM
+// gets an address from the first pool with free addresses in each family.
+func generateConfList(n bridgeNetwork) ([]byte, error) {
+	var v4, v6 []map[string]any
+	for _, p := range n.pools {
+		r := map[string]any{"subnet": p.Subnet.String(), "gateway": p.Gateway.String()}
+		if p.Subnet.Addr().Is4() {
+			v4 = append(v4, r)
+		} else {
+			v6 = append(v6, r)
+		}
+	}
+	ranges := [][]map[string]any{v4}
+	if len(v6) > 0 {
+		ranges = append(ranges, v6)
+	}
+
+	dns := map[string]any{
+		"nameservers": n.nameservers,
+	}
+	if len(n.search) > 0 {
+		dns["search"] = n.search
+	}
+	bridge := map[string]any{
+		"type":      "bridge",
+		"bridge":    n.bridge,
+		"isGateway": true,
+		"ipMasq":    true,
+		"ipam": map[string]any{
+			"type":   "host-local",
+			"ranges": ranges,
+		},
+		"dns": dns,
+	}
+	if n.mtu > 0 {
+		bridge["mtu"] = n.mtu
+	}
+
 	confList := confListJSON{
 		CNIVersion: CNIVersion,
-		Name:       CNINetworkName,
+		Name:       n.name,
 		Plugins: []map[string]any{
-			{
-				"type":      "bridge",
-				"bridge":    DefaultBridgeName,
-				"isGateway": true,
-				"ipMasq":    true,
-				"ipam": map[string]any{
-					"type":    "host-local",
-					"subnet":  DefaultSubnet,
-					"gateway": DefaultGateway,
-				},
-			},
+			bridge,
 			{
 				"type": "tc-redirect-tap",
 			},
@@ -345,18 +627,29 @@ func parseResult(result types.Result, nsPath string) (*NetworkConfig, error) {
 		return nil, fmt.Errorf("no TAP device in CNI result (no interface with sandbox set)")
 	}
 
-	// Extract the first assigned IP.
-	if len(res.IPs) > 0 {
-		netCfg.GuestIP = res.IPs[0].Address.String()
-		if res.IPs[0].Gateway != nil {
-			netCfg.GatewayIP = res.IPs[0].Gateway.String()
+	// Extract the first assigned address of each family.
+	for _, ip := range res.IPs {
+		var gateway string
+		if ip.Gateway != nil {
+			gateway = ip.Gateway.String()
+		}
+		switch {
+		case ip.Address.IP.To4() != nil && netCfg.GuestIP == "":
+			netCfg.GuestIP = ip.Address.String()
+			netCfg.GatewayIP = gateway
+		case ip.Address.IP.To4() == nil && netCfg.GuestIP6 == "":
+			netCfg.GuestIP6 = ip.Address.String()
+			netCfg.GatewayIP6 = gateway
 		}
 	}
 
 	if netCfg.GuestIP == "" {
-		return nil, fmt.Errorf("no IP address in CNI result")
+		return nil, fmt.Errorf("no IPv4 address in CNI result")
 	}
 
+	netCfg.Nameservers = res.DNS.Nameservers
+	netCfg.SearchDomains = res.DNS.Search
+
 	return netCfg, nil
 }
 
@@ -390,19 +683,39 @@ func deleteNetNS(name string) error {
 	return nil
 }
 
-// EnsureIPForwarding enables IPv4 forwarding on the host.
+// EnsureIPForwarding enables IP forwarding on the host for the address
+// families of pools: IPv4 always, and IPv6 when a pool is IPv6.
 // This is required for outbound NAT from the bridge subnet.
+func EnsureIPForwarding(pools []AddressPool) error {
+	for _, path := range forwardingPaths(pools) {
+		if err := enableForwarding(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forwardingPaths returns the forwarding sysctls that pools need.
+func forwardingPaths(pools []AddressPool) []string {
+	paths := []string{ipForwardPath}
+	if slices.ContainsFunc(pools, func(p AddressPool) bool { return p.Subnet.Addr().Is6() }) {
+		paths = append(paths, ip6ForwardPath)
+	}
+	return paths
+}
+
+// enableForwarding sets the forwarding sysctl at path.
 // Idempotent: reads the current value and only writes if not already enabled.
-func EnsureIPForwarding() error {
-	data, err := os.ReadFile(ipForwardPath)
+func enableForwarding(path string) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("read ip_forward: %w", err)
+		return fmt.Errorf("read %s: %w", path, err)
 	}
 	if strings.TrimSpace(string(data)) == "1" {
 		return nil // Already enabled.
 	}
-	if err := os.WriteFile(ipForwardPath, []byte("1"), 0o644); err != nil {
-		return fmt.Errorf("enable ip_forward: %w", err)
+	if err := os.WriteFile(path, []byte("1"), 0o644); err != nil {
+		return fmt.Errorf("enable forwarding in %s: %w", path, err)
 	}
 	return nil
 }
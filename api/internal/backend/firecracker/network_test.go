@@ -5,16 +5,46 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/containernetworking/cni/pkg/types"
 	types100 "github.com/containernetworking/cni/pkg/types/100"
 )
 
+// testNetworkConfig returns a Config with the default network settings.
+func testNetworkConfig() Config {
+	return Config{
+		BridgeName:  DefaultBridgeName,
+		Subnets:     []string{DefaultSubnet},
+		Nameservers: DefaultNameservers,
+	}
+}
+
+// testConfList generates the conflist for cfg.
+func testConfList(t *testing.T, cfg Config) []byte {
+	t.Helper()
+	pools, err := cfg.validateNetwork()
+	if err != nil {
+		t.Fatalf("validateNetwork: %v", err)
+	}
+	data, err := generateConfList(defaultNetwork(cfg, pools))
+	if err != nil {
+		t.Fatalf("generateConfList: %v", err)
+	}
+	return data
+}
+
 func TestGenerateConfList(t *testing.T) {
-	data, err := generateConfList()
+	pools, err := testNetworkConfig().validateNetwork()
+	if err != nil {
+		t.Fatalf("validateNetwork: %v", err)
+	}
+	data, err := generateConfList(defaultNetwork(testNetworkConfig(), pools))
 	if err != nil {
 		t.Fatalf("generateConfList: %v", err)
 	}
@@ -57,11 +87,31 @@ func TestGenerateConfList(t *testing.T) {
 	if ipam["type"] != "host-local" {
 		t.Errorf("ipam.type = %q, want %q", ipam["type"], "host-local")
 	}
-	if ipam["subnet"] != DefaultSubnet {
-		t.Errorf("ipam.subnet = %q, want %q", ipam["subnet"], DefaultSubnet)
+	ranges, ok := ipam["ranges"].([]any)
+	if !ok || len(ranges) != 1 {
+		t.Fatalf("ipam.ranges = %v, want one range set", ipam["ranges"])
+	}
+	set, _ := ranges[0].([]any)
+	if len(set) != 1 {
+		t.Fatalf("ipam.ranges[0] = %v, want one range", ranges[0])
+	}
+	r, _ := set[0].(map[string]any)
+	if r["subnet"] != DefaultSubnet {
+		t.Errorf("range subnet = %q, want %q", r["subnet"], DefaultSubnet)
+	}
+	if r["gateway"] != DefaultGateway {
+		t.Errorf("range gateway = %q, want %q", r["gateway"], DefaultGateway)
+	}
+	if _, ok := bridge["mtu"]; ok {
+		t.Error("plugin[0].mtu should be omitted by default")
+	}
+
+	dns, ok := bridge["dns"].(map[string]any)
+	if !ok {
+		t.Fatal("plugin[0].dns is not a map")
 	}
-	if ipam["gateway"] != DefaultGateway {
-		t.Errorf("ipam.gateway = %q, want %q", ipam["gateway"], DefaultGateway)
+	if ns, _ := dns["nameservers"].([]any); len(ns) != len(DefaultNameservers) {
+		t.Errorf("dns.nameservers = %v, want %v", dns["nameservers"], DefaultNameservers)
 	}
 
 	// Second plugin: tc-redirect-tap.
@@ -71,12 +121,51 @@ func TestGenerateConfList(t *testing.T) {
 	}
 }
 
-func TestGenerateConfListIsValidCNI(t *testing.T) {
-	data, err := generateConfList()
-	if err != nil {
-		t.Fatalf("generateConfList: %v", err)
+func TestGenerateConfListPoolsAndDualStack(t *testing.T) {
+	cfg := testNetworkConfig()
+	cfg.BridgeName = "vulcanbr1"
+	cfg.Subnets = []string{"172.20.0.0/22", "fd00:168::/64", "172.20.4.0/22"}
+	cfg.Gateway = "172.20.0.254"
+	cfg.MTU = 1400
+
+	var parsed struct {
+		Plugins []struct {
+			Bridge string `json:"bridge"`
+			MTU    int    `json:"mtu"`
+			IPAM   struct {
+				Ranges [][]struct {
+					Subnet  string `json:"subnet"`
+					Gateway string `json:"gateway"`
+				} `json:"ranges"`
+			} `json:"ipam"`
+		} `json:"plugins"`
+	}
+	if err := json.Unmarshal(testConfList(t, cfg), &parsed); err != nil {
+		t.Fatalf("unmarshal conflist: %v", err)
 	}
 
+	bridge := parsed.Plugins[0]
+	if bridge.Bridge != "vulcanbr1" {
+		t.Errorf("bridge = %q, want vulcanbr1", bridge.Bridge)
+	}
+	if bridge.MTU != 1400 {
+		t.Errorf("mtu = %d, want 1400", bridge.MTU)
+	}
+	if len(bridge.IPAM.Ranges) != 2 {
+		t.Fatalf("range sets = %d, want 2 (IPv4 and IPv6)", len(bridge.IPAM.Ranges))
+	}
+	v4, v6 := bridge.IPAM.Ranges[0], bridge.IPAM.Ranges[1]
+	if len(v4) != 2 || v4[0].Gateway != "172.20.0.254" || v4[1].Gateway != "172.20.4.1" {
+		t.Errorf("IPv4 ranges = %+v, want two pools with gateways 172.20.0.254 and 172.20.4.1", v4)
+	}
+	if len(v6) != 1 || v6[0].Subnet != "fd00:168::/64" || v6[0].Gateway != "fd00:168::1" {
+		t.Errorf("IPv6 ranges = %+v, want fd00:168::/64 via fd00:168::1", v6)
+	}
+}
+
+func TestGenerateConfListIsValidCNI(t *testing.T) {
+	data := testConfList(t, testNetworkConfig())
+
 	// Verify the JSON has the required top-level fields for a CNI conflist.
 	var raw map[string]any
 	if err := json.Unmarshal(data, &raw); err != nil {
@@ -121,12 +210,54 @@ func TestVerifyPluginsPresent(t *testing.T) {
 		}
 	}
 
-	nm := &NetworkManager{cniBinDir: tmpDir}
+	nft := filepath.Join(tmpDir, "nft")
+	if err := os.WriteFile(nft, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("create fake nft: %v", err)
+	}
+
+	nm := &NetworkManager{cniBinDir: tmpDir, nftBin: nft}
 	if err := nm.Verify(); err != nil {
 		t.Errorf("Verify with all plugins present: %v", err)
 	}
 }
 
+func TestVerifyNFTMissing(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range requiredCNIPlugins {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("fake"), 0o755); err != nil {
+			t.Fatalf("create fake plugin %s: %v", name, err)
+		}
+	}
+
+	nm := &NetworkManager{cniBinDir: tmpDir, nftBin: filepath.Join(tmpDir, "missing-nft")}
+	err := nm.Verify()
+	if err == nil || !strings.Contains(err.Error(), "nftables") {
+		t.Errorf("Verify error = %v, want missing nftables", err)
+	}
+}
+
+func TestVerifyEgressHookUnsupported(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range requiredCNIPlugins {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("fake"), 0o755); err != nil {
+			t.Fatalf("create fake plugin %s: %v", name, err)
+		}
+	}
+
+	// nft before 1.0.1 cannot parse the egress hook.
+	nft := filepath.Join(tmpDir, "nft")
+	script := "#!/bin/sh\necho 'Error: unknown chain hook' >&2\nexit 1\n"
+	if err := os.WriteFile(nft, []byte(script), 0o755); err != nil {
+		t.Fatalf("create fake nft: %v", err)
+	}
+
+	nm := &NetworkManager{cniBinDir: tmpDir, nftBin: nft}
+	err := nm.Verify()
+	if err == nil || !strings.Contains(err.Error(), "egress hook") || !strings.Contains(err.Error(), "unknown chain hook") {
+		t.Errorf("Verify error = %v, want unsupported egress hook", err)
+	}
+}
+
 func TestVerifyPluginsMissing(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -170,7 +301,7 @@ func TestWriteConfList(t *testing.T) {
 	tmpDir := t.TempDir()
 	configDir := filepath.Join(tmpDir, "cni-conf")
 
-	confBytes, _ := generateConfList()
+	confBytes := testConfList(t, testNetworkConfig())
 	nm := &NetworkManager{
 		cniConfigDir:  configDir,
 		confListBytes: confBytes,
@@ -202,7 +333,7 @@ func TestWriteConfListIdempotent(t *testing.T) {
 	tmpDir := t.TempDir()
 	configDir := filepath.Join(tmpDir, "cni-conf")
 
-	confBytes, _ := generateConfList()
+	confBytes := testConfList(t, testNetworkConfig())
 	nm := &NetworkManager{
 		cniConfigDir:  configDir,
 		confListBytes: confBytes,
@@ -339,6 +470,139 @@ func TestParseResultValid(t *testing.T) {
 	}
 }
 
+func TestParseResultDualStack(t *testing.T) {
+	result := &types100.Result{
+		CNIVersion: "1.0.0",
+		Interfaces: []*types100.Interface{
+			{Name: "tap0", Mac: "02:ab:cd:ef:01:23", Sandbox: "/var/run/netns/vulcan-test"},
+		},
+		IPs: []*types100.IPConfig{
+			{Address: mustParseCIDR("fd00:168::5/64"), Gateway: net.ParseIP("fd00:168::1")},
+			{Address: mustParseCIDR("10.168.0.5/24"), Gateway: net.ParseIP("10.168.0.1")},
+		},
+	}
+
+	cfg, err := parseResult(result, "/var/run/netns/vulcan-test")
+	if err != nil {
+		t.Fatalf("parseResult: %v", err)
+	}
+	if cfg.GuestIP != "10.168.0.5/24" || cfg.GatewayIP != "10.168.0.1" {
+		t.Errorf("IPv4 = %s via %s, want 10.168.0.5/24 via 10.168.0.1", cfg.GuestIP, cfg.GatewayIP)
+	}
+	if cfg.GuestIP6 != "fd00:168::5/64" || cfg.GatewayIP6 != "fd00:168::1" {
+		t.Errorf("IPv6 = %s via %s, want fd00:168::5/64 via fd00:168::1", cfg.GuestIP6, cfg.GatewayIP6)
+	}
+}
+
+func TestNetworkConfigKernelArgs(t *testing.T) {
+	cfg := &NetworkConfig{MTU: 1400, GuestIP6: "fd00:168::5/64", GatewayIP6: "fd00:168::1"}
+	want := " vulcan.mtu=1400 vulcan.ip6=fd00:168::5/64 vulcan.gw6=fd00:168::1"
+	if got := cfg.KernelArgs(); got != want {
+		t.Errorf("KernelArgs() = %q, want %q", got, want)
+	}
+	if got := (&NetworkConfig{GuestIP: "10.168.0.5/24"}).KernelArgs(); got != "" {
+		t.Errorf("KernelArgs() = %q, want empty for IPv4-only default MTU", got)
+	}
+	group := &NetworkConfig{GuestIP: "10.169.0.5/24", SearchDomains: []string{"shop.vulcan"}}
+	if got, want := group.KernelArgs(), " vulcan.search=shop.vulcan"; got != want {
+		t.Errorf("KernelArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateConfListGroup(t *testing.T) {
+	pool := AddressPool{Subnet: netip.MustParsePrefix("10.169.2.0/24"), Gateway: netip.MustParseAddr("10.169.2.1")}
+	data, err := generateConfList(bridgeNetwork{
+		name:        groupNetworkName("shop"),
+		bridge:      GroupBridgePrefix + "2",
+		pools:       []AddressPool{pool},
+		nameservers: []string{"10.169.2.1", "1.1.1.1"},
+		search:      []string{"shop.vulcan"},
+	})
+	if err != nil {
+		t.Fatalf("generateConfList: %v", err)
+	}
+
+	var parsed confListJSON
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("unmarshal conflist: %v", err)
+	}
+	if parsed.Name != "vulcan-fcnet-shop" {
+		t.Errorf("name = %q, want vulcan-fcnet-shop", parsed.Name)
+	}
+	bridge := parsed.Plugins[0]
+	if bridge["bridge"] != "vgrp2" {
+		t.Errorf("bridge = %v, want vgrp2", bridge["bridge"])
+	}
+	dns := bridge["dns"].(map[string]any)
+	if search := dns["search"].([]any); len(search) != 1 || search[0] != "shop.vulcan" {
+		t.Errorf("dns.search = %v, want [shop.vulcan]", dns["search"])
+	}
+	if ns := dns["nameservers"].([]any); len(ns) != 2 || ns[0] != "10.169.2.1" {
+		t.Errorf("dns.nameservers = %v, want the group gateway first", dns["nameservers"])
+	}
+}
+
+func TestParseResultNameservers(t *testing.T) {
+	result := &types100.Result{
+		CNIVersion: "1.0.0",
+		Interfaces: []*types100.Interface{
+			{Name: "tap0", Mac: "02:ab:cd:ef:01:23", Sandbox: "/var/run/netns/vulcan-test"},
+		},
+		IPs: []*types100.IPConfig{
+			{Address: mustParseCIDR("10.168.0.2/24"), Gateway: net.ParseIP("10.168.0.1")},
+		},
+		DNS: types.DNS{Nameservers: []string{"192.0.2.53"}, Search: []string{"shop.vulcan"}},
+	}
+
+	cfg, err := parseResult(result, "/var/run/netns/vulcan-test")
+	if err != nil {
+		t.Fatalf("parseResult: %v", err)
+	}
+	if len(cfg.Nameservers) != 1 || cfg.Nameservers[0] != "192.0.2.53" {
+		t.Errorf("Nameservers = %v, want [192.0.2.53]", cfg.Nameservers)
+	}
+	if len(cfg.SearchDomains) != 1 || cfg.SearchDomains[0] != "shop.vulcan" {
+		t.Errorf("SearchDomains = %v, want [shop.vulcan]", cfg.SearchDomains)
+	}
+}
+
+func TestNetworkConfigIPConfiguration(t *testing.T) {
+	cfg := &NetworkConfig{
+		GuestIP:     "10.168.0.7/24",
+		GatewayIP:   "10.168.0.1",
+		Nameservers: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+	}
+
+	ipCfg, err := cfg.IPConfiguration()
+	if err != nil {
+		t.Fatalf("IPConfiguration: %v", err)
+	}
+	if got := ipCfg.IPAddr.String(); got != "10.168.0.7/24" {
+		t.Errorf("IPAddr = %s, want 10.168.0.7/24", got)
+	}
+	if !ipCfg.Gateway.Equal(net.ParseIP("10.168.0.1")) {
+		t.Errorf("Gateway = %s, want 10.168.0.1", ipCfg.Gateway)
+	}
+	if len(ipCfg.Nameservers) != 2 {
+		t.Errorf("Nameservers = %v, want the first two", ipCfg.Nameservers)
+	}
+	if ipCfg.IfName != GuestIfName {
+		t.Errorf("IfName = %q, want %q", ipCfg.IfName, GuestIfName)
+	}
+}
+
+func TestNetworkConfigIPConfigurationInvalid(t *testing.T) {
+	for _, cfg := range []NetworkConfig{
+		{GuestIP: "10.168.0.7", GatewayIP: "10.168.0.1"},
+		{GuestIP: "fd00::7/64", GatewayIP: "fd00::1"},
+		{GuestIP: "10.168.0.7/24", GatewayIP: "gateway"},
+	} {
+		if _, err := cfg.IPConfiguration(); err == nil {
+			t.Errorf("IPConfiguration(%+v): expected error", cfg)
+		}
+	}
+}
+
 func TestParseResultNoIPs(t *testing.T) {
 	result := &types100.Result{
 		CNIVersion: "1.0.0",
@@ -352,8 +616,8 @@ func TestParseResultNoIPs(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for result with no IPs")
 	}
-	if !strings.Contains(err.Error(), "no IP address") {
-		t.Errorf("error = %q, want to contain 'no IP address'", err.Error())
+	if !strings.Contains(err.Error(), "no IPv4 address") {
+		t.Errorf("error = %q, want to contain 'no IPv4 address'", err.Error())
 	}
 }
 
@@ -414,6 +678,35 @@ func TestEnsureIPForwardingPath(t *testing.T) {
 	}
 }
 
+func TestForwardingPaths(t *testing.T) {
+	v4 := AddressPool{Subnet: netip.MustParsePrefix("10.168.0.0/24")}
+	v6 := AddressPool{Subnet: netip.MustParsePrefix("fd00:168::/64")}
+
+	if got := forwardingPaths([]AddressPool{v4}); !slices.Equal(got, []string{ipForwardPath}) {
+		t.Errorf("IPv4 pools: paths = %v, want only %s", got, ipForwardPath)
+	}
+	if got := forwardingPaths([]AddressPool{v4, v6}); !slices.Equal(got, []string{ipForwardPath, ip6ForwardPath}) {
+		t.Errorf("dual-stack pools: paths = %v, want %s and %s", got, ipForwardPath, ip6ForwardPath)
+	}
+}
+
+func TestEnableForwarding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forwarding")
+	if err := os.WriteFile(path, []byte("0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := enableForwarding(path); err != nil {
+		t.Fatalf("enableForwarding: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "1" {
+		t.Errorf("sysctl = %q, want 1", data)
+	}
+
+	if err := enableForwarding(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("enableForwarding on a missing sysctl succeeded, want an error")
+	}
+}
+
 func testLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(io.Discard, nil))
 }
@@ -0,0 +1,50 @@
+package firecracker
+
+import (
+	"fmt"
+	"sync"
+)
+
+// outputMaxBytes is how much of each of a workload's output streams the
+// host keeps; the rest is streamed from the guest and dropped.
+const outputMaxBytes = 8 << 20
+
+// outputBuffer collects one output stream streamed by the guest, keeping
+// its first outputMaxBytes bytes so that a workload cannot exhaust the
+// host's memory by writing without end.
+type outputBuffer struct {
+	mu      sync.Mutex
+	buf     []byte
+	dropped int64
+}
+
+// Write keeps as much of p as fits. It never fails, so that the stream
+// keeps flowing to the end.
+func (o *outputBuffer) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := min(len(p), outputMaxBytes-len(o.buf))
+	o.buf = append(o.buf, p[:n]...)
+	o.dropped += int64(len(p) - n)
+	return len(p), nil
+}
+
+// Len returns the number of bytes written, including those dropped.
+func (o *outputBuffer) Len() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return int64(len(o.buf)) + o.dropped
+}
+
+// Bytes returns the bytes kept, followed by a note of how many were
+// dropped, if any.
+func (o *outputBuffer) Bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.dropped == 0 {
+		return o.buf
+	}
+	return fmt.Appendf(o.buf[:len(o.buf):len(o.buf)], "\n[%d bytes of output dropped]\n", o.dropped)
+}
@@ -0,0 +1,36 @@
+package firecracker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOutputBufferKeepsFirstBytes(t *testing.T) {
+	var o outputBuffer
+	chunk := bytes.Repeat([]byte("x"), 1<<20)
+	for range outputMaxBytes>>20 + 2 {
+		if n, err := o.Write(chunk); n != len(chunk) || err != nil {
+			t.Fatalf("Write = %d, %v; want the whole chunk accepted", n, err)
+		}
+	}
+
+	if got, want := o.Len(), int64(outputMaxBytes+2<<20); got != want {
+		t.Errorf("Len = %d, want %d", got, want)
+	}
+	got := o.Bytes()
+	if !bytes.Equal(got[:outputMaxBytes], bytes.Repeat([]byte("x"), outputMaxBytes)) {
+		t.Error("Bytes does not start with the first outputMaxBytes written")
+	}
+	if tail := string(got[outputMaxBytes:]); !strings.Contains(tail, "[2097152 bytes of output dropped]") {
+		t.Errorf("Bytes ends with %q, want a note of the dropped bytes", tail)
+	}
+}
+
+func TestOutputBufferUnderLimit(t *testing.T) {
+	var o outputBuffer
+	o.Write([]byte("hello\n"))
+	if got := string(o.Bytes()); got != "hello\n" {
+		t.Errorf("Bytes = %q, want %q", got, "hello\n")
+	}
+}
@@ -0,0 +1,204 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// BootParamMaxExecs is the kernel command line parameter setting how many
+// requests the guest agent executes at once.
+const BootParamMaxExecs = "vulcan.max_execs"
+
+// packedVMPrefix starts the IDs of VMs shared by packed workloads.
+const packedVMPrefix = "pack-"
+
+// packer places small workloads that need the same kind of VM into shared
+// VMs, each running up to size of them at once. A shared VM is booted for
+// the first workload that finds none with room, and stopped once its last
+// workload has finished.
+type packer struct {
+	size int
+
+	mu  sync.Mutex
+	vms map[string][]*packedVM // pack key → running VMs
+}
+
+// packedVM is a VM shared by packed workloads. It runs as a session with
+// Concurrency set, whose executions are the workloads.
+type packedVM struct {
+	id     string
+	key    string
+	execs  chan backend.ExecRequest
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// done is closed once the VM has stopped; err says why, if it stopped
+	// while workloads were still assigned to it.
+	done chan struct{}
+	err  error
+
+	// users counts the workloads assigned to the VM; guarded by packer.mu.
+	users int
+}
+
+// newPacker returns a packer sharing each VM among up to size workloads, or
+// nil if size leaves every workload its own VM.
+func newPacker(size int) *packer {
+	if size < 2 {
+		return nil
+	}
+	return &packer{size: size, vms: make(map[string][]*packedVM)}
+}
+
+// acquire assigns a workload to a VM with the given pack key and room for
+// it, calling boot in a goroutine to run a new one if there is none.
+func (p *packer) acquire(key, workloadID string, boot func(vm *packedVM)) *packedVM {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, vm := range p.vms[key] {
+		if vm.users < p.size {
+			vm.users++
+			return vm
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	vm := &packedVM{
+		id:     packedVMPrefix + workloadID,
+		key:    key,
+		execs:  make(chan backend.ExecRequest),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		users:  1,
+	}
+	p.vms[key] = append(p.vms[key], vm)
+	go boot(vm)
+	return vm
+}
+
+// release unassigns a workload from vm, stopping the VM if it was the last.
+func (p *packer) release(vm *packedVM) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if vm.users--; vm.users > 0 {
+		return
+	}
+	p.vms[vm.key] = slices.DeleteFunc(p.vms[vm.key], func(v *packedVM) bool { return v == vm })
+	if len(p.vms[vm.key]) == 0 {
+		delete(p.vms, vm.key)
+	}
+	vm.cancel()
+}
+
+// packKey returns the key identifying the VMs a workload can share, and
+// false if it needs a VM of its own: services and sessions, workloads with
+// ports, groups, rate limits, volumes, scratch disks, input or dependency
+// layers, and compiled code, which is built with the build cache.
+func (b *Backend) packKey(spec backend.WorkloadSpec) (string, bool) {
+	if b.packs == nil || spec.Service != nil || spec.Session != nil || spec.ExposePort != 0 ||
+		spec.Group != "" || spec.RateLimits != nil || len(spec.Volumes) > 0 || spec.DiskMB > 0 ||
+		len(spec.Input) > 0 || spec.Runtime == model.RuntimeGo {
+		return "", false
+	}
+	if b.deps != nil {
+		if manifests, err := findDepsManifests(spec.Runtime, spec.CodeArchive); err != nil || manifests != nil {
+			return "", false
+		}
+	}
+
+	image := ""
+	if spec.Image != nil {
+		image = spec.Image.Ref()
+	}
+	network, err := json.Marshal(spec.Network)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s|%s|%s|%d|%d|%s", spec.Runtime, spec.Isolation, image, spec.CPULimit, spec.MemLimitMB, network), true
+}
+
+// executePacked runs a workload in a VM shared with other workloads of the
+// same pack key.
+func (b *Backend) executePacked(ctx context.Context, key string, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	vm := b.packs.acquire(key, spec.ID, func(vm *packedVM) { b.runPackedVM(vm, spec) })
+	defer b.packs.release(vm)
+	b.logger.Info("workload packed", "workload_id", spec.ID, "vm_id", vm.id)
+
+	results := make(chan backend.ExecResult, 1)
+	x := backend.ExecRequest{
+		ID:          spec.ID,
+		Ctx:         ctx,
+		Code:        spec.Code,
+		CodeArchive: spec.CodeArchive,
+		TimeoutS:    spec.TimeoutS,
+		GraceS:      spec.GraceS,
+		LogWriter:   spec.LogWriter,
+		Result:      results,
+	}
+	select {
+	case vm.execs <- x:
+	case <-vm.done:
+		err := vm.err
+		if err == nil {
+			err = errors.New("VM stopped")
+		}
+		return backend.WorkloadResult{}, fmt.Errorf("packed VM %s: %w", vm.id, err)
+	case <-ctx.Done():
+		return backend.WorkloadResult{}, fmt.Errorf("wait for packed VM %s: %w", vm.id, ctx.Err())
+	}
+	res := <-results
+	return res.Result, res.Err
+}
+
+// runPackedVM boots vm with the settings of spec, the workload it was
+// booted for, and runs the workloads assigned to it until it is released.
+func (b *Backend) runPackedVM(vm *packedVM, spec backend.WorkloadSpec) {
+	defer close(vm.done)
+	_, vm.err = b.Execute(vm.ctx, backend.WorkloadSpec{
+		ID:         vm.id,
+		Runtime:    spec.Runtime,
+		Isolation:  spec.Isolation,
+		CPULimit:   spec.CPULimit,
+		MemLimitMB: spec.MemLimitMB,
+		Network:    spec.Network,
+		Image:      spec.Image,
+		Session:    &backend.SessionSpec{Execs: vm.execs, Concurrency: b.packs.size},
+	})
+	if vm.err != nil {
+		b.logger.Warn("packed VM failed", "vm_id", vm.id, "error", vm.err)
+	}
+}
+
+// execConn is the guest connection an execution of a session or packed VM
+// runs on, for Signal.
+type execConn struct {
+	gc    *GuestConn
+	agent GuestHello
+}
+
+// trackExec records the connection execution id runs on until the
+// returned function is called.
+func (b *Backend) trackExec(id string, gc *GuestConn, agent GuestHello) func() {
+	if id == "" {
+		return func() {}
+	}
+	b.mu.Lock()
+	if b.execs == nil {
+		b.execs = make(map[string]execConn)
+	}
+	b.execs[id] = execConn{gc: gc, agent: agent}
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.execs, id)
+		b.mu.Unlock()
+	}
+}
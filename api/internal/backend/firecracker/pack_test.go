@@ -0,0 +1,116 @@
+package firecracker
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+func TestPackKey(t *testing.T) {
+	b := &Backend{packs: newPacker(4)}
+	base := backend.WorkloadSpec{ID: "w1", Runtime: model.RuntimePython, Code: "print(1)", TimeoutS: 5}
+
+	key, ok := b.packKey(base)
+	if !ok {
+		t.Fatal("small python workload is not packable")
+	}
+	other := base
+	other.ID, other.Code, other.TimeoutS = "w2", "print(2)", 30
+	if k, _ := b.packKey(other); k != key {
+		t.Errorf("workloads differing only in code and timeout get keys %q and %q", key, k)
+	}
+	other.MemLimitMB = 512
+	if k, _ := b.packKey(other); k == key {
+		t.Error("workloads with different memory limits share a key")
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*backend.WorkloadSpec)
+	}{
+		{"session", func(s *backend.WorkloadSpec) { s.Session = &backend.SessionSpec{} }},
+		{"service", func(s *backend.WorkloadSpec) { s.Service = &model.ServiceSpec{} }},
+		{"port", func(s *backend.WorkloadSpec) { s.ExposePort = 8080 }},
+		{"group", func(s *backend.WorkloadSpec) { s.Group = "g" }},
+		{"scratch disk", func(s *backend.WorkloadSpec) { s.DiskMB = 64 }},
+		{"input", func(s *backend.WorkloadSpec) { s.Input = []byte("x") }},
+		{"go", func(s *backend.WorkloadSpec) { s.Runtime = model.RuntimeGo }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := base
+			tt.modify(&spec)
+			if _, ok := b.packKey(spec); ok {
+				t.Error("workload is packable")
+			}
+		})
+	}
+
+	if _, ok := (&Backend{}).packKey(base); ok {
+		t.Error("workload is packable with packing disabled")
+	}
+}
+
+func TestPackerAcquireRelease(t *testing.T) {
+	if newPacker(1) != nil {
+		t.Error("newPacker(1) packs workloads")
+	}
+
+	p := newPacker(2)
+	var mu sync.Mutex
+	booted := 0
+	boot := func(*packedVM) {
+		mu.Lock()
+		booted++
+		mu.Unlock()
+	}
+
+	a := p.acquire("k", "w1", boot)
+	b := p.acquire("k", "w2", boot)
+	c := p.acquire("k", "w3", boot)
+	d := p.acquire("other", "w4", boot)
+	if a != b || a == c || a == d {
+		t.Fatalf("VMs %s %s %s %s, want the first two shared and the others apart", a.id, b.id, c.id, d.id)
+	}
+	if a.id != packedVMPrefix+"w1" {
+		t.Errorf("VM ID = %q, want it named after its first workload", a.id)
+	}
+
+	p.release(a)
+	if a.ctx.Err() != nil {
+		t.Error("VM stopped while a workload still uses it")
+	}
+	if e := p.acquire("k", "w5", boot); e != a {
+		t.Errorf("workload went to %s, want the VM with room, %s", e.id, a.id)
+	}
+	p.release(a)
+	p.release(a)
+	if a.ctx.Err() == nil {
+		t.Error("VM still running after its last workload")
+	}
+	if e := p.acquire("k", "w6", boot); e == a || e != c {
+		t.Errorf("workload went to %s, want the remaining VM %s", e.id, c.id)
+	}
+}
+
+func TestExecLimit(t *testing.T) {
+	multiplex := GuestHello{Features: []string{FeatureMultiplex}, MaxExecs: 3}
+	tests := []struct {
+		concurrency int
+		agent       GuestHello
+		want        int
+	}{
+		{0, multiplex, 1},
+		{8, GuestHello{}, 1},
+		{2, multiplex, 2},
+		{8, multiplex, 3},
+		{8, GuestHello{Features: []string{FeatureMultiplex}}, 1},
+	}
+	for _, tt := range tests {
+		if got := execLimit(&backend.SessionSpec{Concurrency: tt.concurrency}, tt.agent); got != tt.want {
+			t.Errorf("execLimit(%d, %+v) = %d, want %d", tt.concurrency, tt.agent, got, tt.want)
+		}
+	}
+}
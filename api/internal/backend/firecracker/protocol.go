@@ -5,11 +5,77 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
 )
 
 // MaxMessageSize is the maximum allowed vsock message payload (16 MiB).
 const MaxMessageSize = 16 << 20
 
+// Protocol versions for the host↔guest handshake. The version is bumped for
+// incompatible changes; additive capabilities are advertised as features.
+const (
+	// ProtocolVersion is the protocol version spoken by this build.
+	ProtocolVersion = 2
+
+	// MinProtocolVersion is the oldest guest protocol version the host accepts.
+	MinProtocolVersion = LegacyProtocolVersion
+
+	// LegacyProtocolVersion is assigned to guest agents that predate the
+	// handshake. They are driven in a degraded mode without optional features.
+	LegacyProtocolVersion = 1
+)
+
+// Guest agent features advertised in the handshake.
+const (
+	// FeatureChunkedIO indicates support for chunk frames on stdin and output.
+	FeatureChunkedIO = "chunked_io"
+
+	// FeatureControl indicates support for cancel and signal messages from
+	// the host and heartbeats from the guest.
+	FeatureControl = "control"
+
+	// FeatureCommand indicates support for GuestRequest.Command, which catalog
+	// images use to declare how workload code is run.
+	FeatureCommand = "command"
+
+	// FeatureDeps indicates support for GuestRequest.Deps: mounting a
+	// dependency layer and installing dependencies into a blank one.
+	FeatureDeps = "deps"
+
+	// FeatureDepsCommit indicates support for DepsRequest.Commit: handing a
+	// freshly installed dependency layer to the host before any workload
+	// code runs.
+	FeatureDepsCommit = "deps_commit"
+
+	// FeatureGoBuild indicates support for GuestRequest.Build: compiling Go
+	// code into a program that is returned to the host, and running a
+	// program the host sends instead of compiling the code again.
+	FeatureGoBuild = "go_build"
+
+	// FeatureVolumes indicates support for GuestRequest.Volumes: mounting
+	// persistent volumes before the workload runs.
+	FeatureVolumes = "volumes"
+
+	// FeatureScratch indicates support for GuestRequest.ScratchDevice:
+	// placing /tmp and the working directory on a scratch disk.
+	FeatureScratch = "scratch"
+
+	// FeatureService indicates support for GuestRequest.Service: keeping the
+	// workload's process running, checking its health and sending health
+	// messages.
+	FeatureService = "service"
+
+	// FeatureSession indicates support for GuestRequest.Session: running
+	// requests over the files left by earlier ones, optionally in a
+	// persistent interpreter.
+	FeatureSession = "session"
+
+	// FeatureMultiplex indicates that requests on separate connections run
+	// at once, each in its own work directory and process group, up to
+	// GuestHello.MaxExecs of them.
+	FeatureMultiplex = "multiplex"
+)
+
 // GuestRequest is the JSON payload sent from host to guest over vsock.
 type GuestRequest struct {
 	Runtime     string            `json:"runtime"`
@@ -19,6 +85,55 @@ type GuestRequest struct {
 	Env         map[string]string `json:"env,omitempty"`
 	Entrypoint  string            `json:"entrypoint,omitempty"`
 	TimeoutS    int               `json:"timeout_s"`
+
+	// GraceMS is the time the workload's process group has between SIGTERM
+	// and SIGKILL once TimeoutS has passed. Zero gives it the agent's
+	// default; agents that predate it kill the workload at once.
+	GraceMS int `json:"grace_ms,omitempty"`
+
+	// Command replaces the runtime's command with a program and leading
+	// arguments; the entrypoint's path is appended. Requires FeatureCommand.
+	Command []string `json:"command,omitempty"`
+
+	// Deps attaches a dependency layer for the manifests in the code archive.
+	// Requires FeatureDeps.
+	Deps *DepsRequest `json:"deps,omitempty"`
+
+	// Build runs the code as a compiled program rather than through the
+	// runtime's command. Requires FeatureGoBuild.
+	Build *BuildRequest `json:"build,omitempty"`
+
+	// Volumes lists persistent volumes to mount before the workload runs and
+	// unmount once it has exited. Requires FeatureVolumes.
+	Volumes []VolumeMount `json:"volumes,omitempty"`
+
+	// ScratchDevice is a blank ext4 block device to hold /tmp and the
+	// working directory, so that what the workload writes there is bounded
+	// by its size. Requires FeatureScratch.
+	ScratchDevice string `json:"scratch_device,omitempty"`
+
+	// Service runs the workload as a service, restarted when it exits until
+	// the host cancels it, rather than to completion; TimeoutS is ignored.
+	// Requires FeatureService.
+	Service *ServiceRequest `json:"service,omitempty"`
+
+	// Session runs the request as one execution in a session: the working
+	// directory keeps the files of earlier executions. Requires
+	// FeatureSession.
+	Session *SessionRequest `json:"session,omitempty"`
+
+	// StreamInput indicates that Input is omitted from the request and instead
+	// follows it as StreamInput chunk frames terminated by a chunk end marker.
+	StreamInput bool `json:"stream_input,omitempty"`
+
+	// StreamOutput asks the guest to deliver stdout and stderr as chunk frames
+	// before the result message rather than inline in GuestResponse.Output.
+	// Guests that predate chunked framing ignore it and reply inline.
+	StreamOutput bool `json:"stream_output,omitempty"`
+
+	// HeartbeatMS asks the guest to send a heartbeat message at this interval
+	// while the request is being handled. Zero disables heartbeats.
+	HeartbeatMS int `json:"heartbeat_ms,omitempty"`
 }
 
 // GuestResponse is the JSON payload sent from guest to host over vsock.
@@ -27,21 +142,307 @@ type GuestResponse struct {
 	Output   string   `json:"output"`
 	Error    string   `json:"error,omitempty"`
 	LogLines []string `json:"log_lines,omitempty"`
+
+	// Usage is the resources consumed by the workload's process tree. Absent
+	// from agents that predate usage reporting and when the process never ran.
+	Usage *ResourceUsage `json:"usage,omitempty"`
+
+	// ExitReason classifies how the workload's process ended, one of the
+	// model.ExitReason* constants, and ExitSignal names the signal that
+	// killed it. Absent from agents that predate exit classification and
+	// when the process never ran.
+	ExitReason string `json:"exit_reason,omitempty"`
+	ExitSignal string `json:"exit_signal,omitempty"`
+
+	// DepsInstalled reports that dependencies were installed into the blank
+	// layer of DepsRequest.Install, which was then unmounted cleanly.
+	DepsInstalled bool `json:"deps_installed,omitempty"`
+
+	// Built reports that the code of BuildRequest was compiled and the
+	// program streamed back as StreamProgram chunk frames before the result.
+	Built bool `json:"built,omitempty"`
+}
+
+// DepsRequest describes the dependency layer attached to the guest as a
+// block device.
+type DepsRequest struct {
+	// Device is the block device holding the layer.
+	Device string `json:"device"`
+
+	// Install indicates that Device is a blank filesystem to install the
+	// dependencies into, offline, from the package mirror on MirrorDevice.
+	Install      bool   `json:"install,omitempty"`
+	MirrorDevice string `json:"mirror_device,omitempty"`
+
+	// Commit asks the guest, once it has installed into Device and unmounted
+	// it, to send MsgTypeDepsInstalled and wait for MsgTypeDepsCommitted
+	// before it runs anything from the workload. The host caches the layer
+	// in between, while it holds only what the installer wrote.
+	Commit bool `json:"commit,omitempty"`
+}
+
+// VolumeMount describes a persistent volume attached to the guest as a
+// block device.
+type VolumeMount struct {
+	Device    string `json:"device"`
+	MountPath string `json:"mount_path"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+// BuildRequest describes how to obtain the program a workload runs.
+type BuildRequest struct {
+	// Prebuilt indicates that the program, compiled by an earlier run from
+	// the same code and toolchain, follows the request as StreamProgram chunk
+	// frames. Otherwise the guest compiles the code, sending the compiler's
+	// output as build log messages, and streams the program back.
+	Prebuilt bool `json:"prebuilt,omitempty"`
+}
+
+// ServiceRequest describes how the guest keeps a service's process running.
+type ServiceRequest struct {
+	// Restart is "always", "on-failure" or "never". MaxRestarts bounds the
+	// number of restarts; zero means no limit.
+	Restart     string `json:"restart"`
+	MaxRestarts int    `json:"max_restarts,omitempty"`
+
+	// BackoffMS is the delay before a restart, doubled for each consecutive
+	// restart up to MaxBackoffMS. A process that stays up for longer than
+	// MaxBackoffMS resets the delay.
+	BackoffMS    int `json:"backoff_ms"`
+	MaxBackoffMS int `json:"max_backoff_ms"`
+
+	// HealthCheck probes the process while it runs; nil reports the service
+	// healthy for as long as the process is running.
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+}
+
+// SessionRequest describes an execution in a session.
+type SessionRequest struct {
+	// Interpreter runs the code in the guest's persistent interpreter for
+	// the runtime, started by the first such request, so that it sees the
+	// variables and imports of earlier executions. Only python and node
+	// have one.
+	Interpreter bool `json:"interpreter,omitempty"`
+}
+
+// HealthCheck is a probe of a service run by the guest: an HTTP GET of
+// HTTPPath on HTTPPort of the loopback interface when HTTPPort is set, and
+// Command otherwise.
+type HealthCheck struct {
+	HTTPPort int      `json:"http_port,omitempty"`
+	HTTPPath string   `json:"http_path,omitempty"`
+	Command  []string `json:"command,omitempty"`
+
+	// Retries is the number of consecutive failed probes after which the
+	// service is unhealthy.
+	IntervalMS int `json:"interval_ms"`
+	TimeoutMS  int `json:"timeout_ms"`
+	Retries    int `json:"retries"`
+
+	// StartPeriodMS delays the first probe after each start of the process.
+	StartPeriodMS int `json:"start_period_ms,omitempty"`
+}
+
+// ResourceUsage describes the resources consumed by a workload's process
+// tree, as measured by the guest agent.
+type ResourceUsage struct {
+	CPUUserMS    int64 `json:"cpu_user_ms"`
+	CPUSysMS     int64 `json:"cpu_sys_ms"`
+	PeakRSSKB    int64 `json:"peak_rss_kb"`
+	IOReadBytes  int64 `json:"io_read_bytes"`
+	IOWriteBytes int64 `json:"io_write_bytes"`
+
+	// PeakMemoryKB is the peak memory of the workload's cgroup, page cache
+	// included; zero where the guest cannot measure it.
+	PeakMemoryKB int64 `json:"peak_memory_kb,omitempty"`
+
+	// DiskUsedBytes is the space the workload took on its scratch disk.
+	DiskUsedBytes int64 `json:"disk_used_bytes,omitempty"`
 }
 
 // Guest→host message types for vsock streaming.
 const (
 	MsgTypeLog    = "log"
 	MsgTypeResult = "result"
+
+	// MsgTypeBuildLog carries a line of compiler output, kept apart from the
+	// workload's own log lines.
+	MsgTypeBuildLog = "build_log"
 )
 
+// Control message types. Cancel and signal are sent host→guest at any time
+// after the request; heartbeats are sent guest→host while it is handled.
+const (
+	MsgTypeCancel    = "cancel"
+	MsgTypeSignal    = "signal"
+	MsgTypeHeartbeat = "heartbeat"
+)
+
+// MsgTypeHealth is sent guest→host when a service's health changes or its
+// process is restarted.
+const MsgTypeHealth = "health"
+
+// Dependency commit message types. MsgTypeDepsInstalled is sent guest→host
+// when the layer of a DepsRequest with Commit is installed, and
+// MsgTypeDepsCommitted host→guest once the host is done with the layer,
+// whether or not it could cache it.
+const (
+	MsgTypeDepsInstalled = "deps_installed"
+	MsgTypeDepsCommitted = "deps_committed"
+)
+
+// Service health states reported in health messages.
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// HealthReport describes a service's health.
+type HealthReport struct {
+	// Status is one of the Health* constants.
+	Status string `json:"status"`
+
+	// Detail explains an unhealthy status, e.g. the failed probe.
+	Detail string `json:"detail,omitempty"`
+
+	// Restarts is how many times the service's process has been restarted.
+	Restarts int `json:"restarts"`
+}
+
+// Workload process states reported in heartbeats.
+const (
+	ProcessPreparing   = "preparing"
+	ProcessRunning     = "running"
+	ProcessTerminating = "terminating"
+	ProcessExited      = "exited"
+)
+
+// Heartbeat reports the state of the workload process inside the guest.
+type Heartbeat struct {
+	// State is one of the Process* constants.
+	State string `json:"state"`
+
+	// PID is the workload process ID, once started.
+	PID int `json:"pid,omitempty"`
+
+	// ProcState is the kernel scheduler state of the process from
+	// /proc/<pid>/stat (e.g. "R", "S", "D", "Z").
+	ProcState string `json:"proc_state,omitempty"`
+
+	// ElapsedMS is the time since the process started.
+	ElapsedMS int64 `json:"elapsed_ms,omitempty"`
+}
+
+// MsgTypeHello is the handshake message type, sent by the host as the first
+// frame on a connection and answered by the guest with its capabilities.
+const MsgTypeHello = "hello"
+
+// HostHello is the host's half of the handshake.
+type HostHello struct {
+	ProtocolVersion int `json:"protocol_version"`
+}
+
+// GuestHello is the guest's half of the handshake, describing the agent
+// baked into the rootfs.
+type GuestHello struct {
+	AgentVersion    string   `json:"agent_version"`
+	ProtocolVersion int      `json:"protocol_version"`
+	Runtimes        []string `json:"runtimes,omitempty"`
+	Features        []string `json:"features,omitempty"`
+	Kernel          string   `json:"kernel,omitempty"`
+
+	// Toolchains maps runtimes that compile code to their toolchain version,
+	// e.g. "go" to "go1.22.5".
+	Toolchains map[string]string `json:"toolchains,omitempty"`
+
+	// MaxExecs is how many requests the agent executes at once; further
+	// requests wait for one to finish.
+	MaxExecs int `json:"max_execs,omitempty"`
+}
+
+// HasFeature reports whether the guest advertised the given feature.
+func (h GuestHello) HasFeature(feature string) bool {
+	return slices.Contains(h.Features, feature)
+}
+
+// HasRuntime reports whether the guest advertised the given runtime.
+func (h GuestHello) HasRuntime(runtime string) bool {
+	return slices.Contains(h.Runtimes, runtime)
+}
+
+// Chunk frame message types. These are used in both directions to carry
+// payloads that are too large to hold in a single frame.
+const (
+	// MsgTypeChunk carries up to ChunkSize bytes of a stream.
+	MsgTypeChunk = "chunk"
+
+	// MsgTypeChunkEnd marks the end of a stream; no further chunks follow.
+	MsgTypeChunkEnd = "chunk_end"
+
+	// MsgTypeChunkAck returns Count flow-control credits to the sender of a stream.
+	MsgTypeChunkAck = "chunk_ack"
+)
+
+// Stream identifiers carried by chunk frames.
+const (
+	// StreamInput is the workload's stdin, sent host→guest.
+	StreamInput = "input"
+
+	// StreamStdout is the workload's final stdout output, sent guest→host.
+	StreamStdout = "stdout"
+
+	// StreamStderr is the workload's final stderr output, sent guest→host.
+	StreamStderr = "stderr"
+
+	// StreamProgram is a compiled program: sent host→guest when the request
+	// is prebuilt, and guest→host after the guest compiled it.
+	StreamProgram = "program"
+)
+
+// StreamSystem tags log lines the agent reports about running a workload,
+// such as the output of installing its dependencies, rather than lines the
+// workload wrote. The workload's own lines are tagged StreamStdout or
+// StreamStderr.
+const StreamSystem = "system"
+
 // GuestMessage is the envelope for all guest→host messages over vsock.
-// During execution, the guest sends log lines with Type="log".
+// During execution, the guest sends log lines with Type="log", tagged with
+// the stream they were written to.
+// Streamed output is sent as chunk frames, and acknowledgements for streamed
+// input as chunk_ack frames.
 // After execution completes, the guest sends one final message with Type="result".
 type GuestMessage struct {
-	Type     string         `json:"type"`
-	Line     string         `json:"line,omitempty"`
-	Response *GuestResponse `json:"response,omitempty"`
+	Type      string         `json:"type"`
+	Line      string         `json:"line,omitempty"`
+	Response  *GuestResponse `json:"response,omitempty"`
+	Hello     *GuestHello    `json:"hello,omitempty"`
+	Heartbeat *Heartbeat     `json:"heartbeat,omitempty"`
+	Health    *HealthReport  `json:"health,omitempty"`
+
+	// Stream, Data and Count are set on chunk frames. Stream is also set on
+	// log lines; agents that predate it leave it empty.
+	Stream string `json:"stream,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+	Count  int    `json:"count,omitempty"`
+}
+
+// HostMessage is the envelope for host→guest messages other than the
+// GuestRequest itself: the handshake hello that precedes it, and streamed
+// input chunks, acknowledgements for streamed output and control messages
+// that follow it.
+type HostMessage struct {
+	Type   string     `json:"type"`
+	Hello  *HostHello `json:"hello,omitempty"`
+	Stream string     `json:"stream,omitempty"`
+	Data   []byte     `json:"data,omitempty"`
+	Count  int        `json:"count,omitempty"`
+
+	// Signal is the signal name (e.g. "SIGUSR1") for signal messages.
+	Signal string `json:"signal,omitempty"`
+
+	// GraceMS is the time between SIGTERM and SIGKILL for cancel messages.
+	GraceMS int `json:"grace_ms,omitempty"`
 }
 
 // WriteMessage writes a length-prefixed JSON message to w.
@@ -66,23 +467,34 @@ func WriteMessage(w io.Writer, v any) error {
 
 // ReadMessage reads a length-prefixed JSON message from r and decodes it into v.
 func ReadMessage(r io.Reader, v any) error {
+	data, err := ReadFrame(r)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unmarshal message: %w", err)
+	}
+
+	return nil
+}
+
+// ReadFrame reads a length-prefixed frame from r and returns its raw JSON
+// payload, for callers that must inspect a message before decoding it.
+func ReadFrame(r io.Reader) ([]byte, error) {
 	var length uint32
 	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
-		return fmt.Errorf("read length prefix: %w", err)
+		return nil, fmt.Errorf("read length prefix: %w", err)
 	}
 
 	if length > MaxMessageSize {
-		return fmt.Errorf("message size %d exceeds maximum %d", length, MaxMessageSize)
+		return nil, fmt.Errorf("message size %d exceeds maximum %d", length, MaxMessageSize)
 	}
 
 	data := make([]byte, length)
 	if _, err := io.ReadFull(r, data); err != nil {
-		return fmt.Errorf("read payload: %w", err)
+		return nil, fmt.Errorf("read payload: %w", err)
 	}
 
-	if err := json.Unmarshal(data, v); err != nil {
-		return fmt.Errorf("unmarshal message: %w", err)
-	}
-
-	return nil
+	return data, nil
 }
@@ -0,0 +1,66 @@
+package firecracker
+
+import (
+	fcsdk "github.com/firecracker-microvm/firecracker-go-sdk"
+	models "github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// rateLimiterRefillMS is the refill interval of the token buckets backing a
+// rate limit. Each bucket holds one second's worth of tokens, so a limit of
+// N per second allows bursts of at most N.
+const rateLimiterRefillMS = 1000
+
+// driveRateLimiter returns the rate limiter of each of the drives the disk
+// limits cover, or nil when the disk is unlimited. Firecracker limits each
+// drive on its own, so the limits are split evenly across the drives, and
+// together they never exceed them.
+func driveRateLimiter(limits *model.RateLimits, drives int) *models.RateLimiter {
+	if limits == nil {
+		return nil
+	}
+	return rateLimiter(driveShare(limits.DiskBytesPerS, drives), driveShare(limits.DiskIOPS, drives))
+}
+
+// driveShare returns one of drives equal shares of limit, at least 1 so that a
+// small limit is not turned into no limit.
+func driveShare(limit int64, drives int) int64 {
+	if limit <= 0 || drives <= 1 {
+		return limit
+	}
+	return max(limit/int64(drives), 1)
+}
+
+// netRateLimiter returns the rate limiter applied to each direction of the
+// network interface, or nil when the network is unlimited.
+func netRateLimiter(limits *model.RateLimits) *models.RateLimiter {
+	if limits == nil {
+		return nil
+	}
+	return rateLimiter(limits.NetBytesPerS, limits.NetPacketsPerS)
+}
+
+// rateLimiter returns a Firecracker rate limiter allowing bytesPerS bytes and
+// opsPerS operations per second, where zero means unlimited. It returns nil
+// when both are zero.
+func rateLimiter(bytesPerS, opsPerS int64) *models.RateLimiter {
+	if bytesPerS <= 0 && opsPerS <= 0 {
+		return nil
+	}
+	limiter := &models.RateLimiter{}
+	if bytesPerS > 0 {
+		limiter.Bandwidth = tokenBucket(bytesPerS)
+	}
+	if opsPerS > 0 {
+		limiter.Ops = tokenBucket(opsPerS)
+	}
+	return limiter
+}
+
+func tokenBucket(perSecond int64) *models.TokenBucket {
+	return &models.TokenBucket{
+		Size:       fcsdk.Int64(perSecond),
+		RefillTime: fcsdk.Int64(rateLimiterRefillMS),
+	}
+}
@@ -0,0 +1,81 @@
+package firecracker
+
+import (
+	"testing"
+
+	models "github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// bucketRate returns the per-second rate of a token bucket, 0 if nil.
+func bucketRate(t *testing.T, b *models.TokenBucket) int64 {
+	t.Helper()
+	if b == nil {
+		return 0
+	}
+	if *b.RefillTime != rateLimiterRefillMS {
+		t.Errorf("RefillTime = %d, want %d", *b.RefillTime, rateLimiterRefillMS)
+	}
+	return *b.Size * 1000 / *b.RefillTime
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	if l := driveRateLimiter(nil, 1); l != nil {
+		t.Errorf("driveRateLimiter(nil, 1) = %+v, want nil", l)
+	}
+	netOnly := &model.RateLimits{NetBytesPerS: 1 << 20}
+	if l := driveRateLimiter(netOnly, 1); l != nil {
+		t.Errorf("driveRateLimiter with only network limits = %+v, want nil", l)
+	}
+	diskOnly := &model.RateLimits{DiskIOPS: 100}
+	if l := netRateLimiter(diskOnly); l != nil {
+		t.Errorf("netRateLimiter with only disk limits = %+v, want nil", l)
+	}
+}
+
+func TestRateLimiterBuckets(t *testing.T) {
+	limits := &model.RateLimits{
+		DiskBytesPerS:  10 << 20,
+		DiskIOPS:       500,
+		NetBytesPerS:   1 << 20,
+		NetPacketsPerS: 2000,
+	}
+
+	disk := driveRateLimiter(limits, 1)
+	if got := bucketRate(t, disk.Bandwidth); got != 10<<20 {
+		t.Errorf("disk bandwidth = %d/s, want %d", got, 10<<20)
+	}
+	if got := bucketRate(t, disk.Ops); got != 500 {
+		t.Errorf("disk ops = %d/s, want 500", got)
+	}
+
+	net := netRateLimiter(limits)
+	if got := bucketRate(t, net.Bandwidth); got != 1<<20 {
+		t.Errorf("net bandwidth = %d/s, want %d", got, 1<<20)
+	}
+	if got := bucketRate(t, net.Ops); got != 2000 {
+		t.Errorf("net packets = %d/s, want 2000", got)
+	}
+
+	bwOnly := rateLimiter(4096, 0)
+	if bwOnly.Ops != nil {
+		t.Errorf("Ops = %+v, want nil without an ops limit", bwOnly.Ops)
+	}
+	if err := bwOnly.Validate(nil); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestDriveRateLimiterSplit(t *testing.T) {
+	limits := &model.RateLimits{DiskBytesPerS: 12 << 20, DiskIOPS: 2}
+
+	// Three drives share the limits; none may use more than its third.
+	disk := driveRateLimiter(limits, 3)
+	if got := bucketRate(t, disk.Bandwidth); got != 4<<20 {
+		t.Errorf("disk bandwidth per drive = %d/s, want %d", got, 4<<20)
+	}
+	if got := bucketRate(t, disk.Ops); got != 1 {
+		t.Errorf("disk ops per drive = %d/s, want at least 1", got)
+	}
+}
@@ -0,0 +1,217 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultDebugFSBin is the e2fsprogs tool used to read files from rootfs
+// images without mounting them.
+const DefaultDebugFSBin = "debugfs"
+
+// DefaultMkfsBin is the e2fsprogs tool used to build rootfs images.
+const DefaultMkfsBin = "mkfs.ext4"
+
+// Sizing of built rootfs images: file data rounded up to whole blocks, plus
+// 25% filesystem overhead and free space for files workloads write.
+const (
+	rootfsBlockSize     = 4096
+	rootfsFreeBytes     = 256 << 20
+	rootfsBytesPerInode = 16384 // mkfs.ext4's default ratio
+)
+
+// Guest agent build identifiers, read from its Go build information.
+const (
+	guestAgentPackage = "github.com/seantiz/vulcan/cmd/vulcan-guest"
+	guestVersionVar   = "github.com/seantiz/vulcan/internal/guest.Version"
+
+	// guestDefaultVersion is the agent version when none was set at build time.
+	guestDefaultVersion = "dev"
+)
+
+// RootfsAgent describes the guest agent found in a rootfs image.
+type RootfsAgent struct {
+	Version string
+}
+
+// InspectRootfs checks that the ext4 image at path contains the guest agent
+// at GuestAgentPath and returns its version. The agent is copied out with
+// debugfs and identified from its Go build information, so the image is
+// neither mounted nor is anything in it run.
+func InspectRootfs(ctx context.Context, debugfsBin, path string) (RootfsAgent, error) {
+	tmpDir, err := os.MkdirTemp("", "vulcan-inspect-")
+	if err != nil {
+		return RootfsAgent{}, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	agentPath := filepath.Join(tmpDir, "vulcan-guest")
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, debugfsBin, "-R", fmt.Sprintf("dump %s %q", GuestAgentPath, agentPath), path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return RootfsAgent{}, fmt.Errorf("read image with %s: %w: %s", debugfsBin, err, strings.TrimSpace(stderr.String()))
+	}
+	// debugfs reports unreadable images and missing files on stderr but
+	// still exits zero, leaving no output file behind.
+	if info, err := os.Stat(agentPath); err != nil || info.Size() == 0 {
+		if msg := debugfsError(stderr.String()); msg != "" {
+			return RootfsAgent{}, fmt.Errorf("guest agent %s not found in image: %s", GuestAgentPath, msg)
+		}
+		return RootfsAgent{}, fmt.Errorf("guest agent %s not found in image", GuestAgentPath)
+	}
+
+	bi, err := buildinfo.ReadFile(agentPath)
+	if err != nil {
+		return RootfsAgent{}, fmt.Errorf("%s is not a Go binary: %w", GuestAgentPath, err)
+	}
+	if bi.Path != guestAgentPackage {
+		return RootfsAgent{}, fmt.Errorf("%s is %s, not the guest agent", GuestAgentPath, bi.Path)
+	}
+	return RootfsAgent{Version: agentVersion(bi)}, nil
+}
+
+// agentVersion returns the guest agent version set with -ldflags -X at build
+// time, or guestDefaultVersion if none was set.
+func agentVersion(bi *buildinfo.BuildInfo) string {
+	for _, s := range bi.Settings {
+		if s.Key != "-ldflags" {
+			continue
+		}
+		fields := strings.Fields(s.Value)
+		for i, f := range fields {
+			def, ok := strings.CutPrefix(f, "-X=")
+			if !ok && f == "-X" && i+1 < len(fields) {
+				def, ok = fields[i+1], true
+			}
+			if version, found := strings.CutPrefix(def, guestVersionVar+"="); ok && found {
+				return strings.Trim(version, `"'`)
+			}
+		}
+	}
+	return guestDefaultVersion
+}
+
+// debugfsError returns the first error debugfs wrote to stderr, skipping
+// its version banner.
+func debugfsError(stderr string) string {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "debugfs 1.") {
+			return line
+		}
+	}
+	return ""
+}
+
+// BuildRootfs builds the ext4 image output from the root filesystem tree in
+// dir, installing the guest agent binary agentBin at GuestAgentPath. Nothing
+// is mounted: the image is populated with mkfs.ext4 -d. sizeMB sets the image
+// size; 0 sizes it to fit the tree with room for workload files.
+func BuildRootfs(ctx context.Context, mkfsBin, agentBin, dir, output string, sizeMB int) error {
+	if err := installAgent(agentBin, dir); err != nil {
+		return fmt.Errorf("install guest agent: %w", err)
+	}
+
+	used, files, err := treeUsage(dir)
+	if err != nil {
+		return fmt.Errorf("measure rootfs: %w", err)
+	}
+	size := used + used/4 + rootfsFreeBytes
+	if sizeMB > 0 {
+		if int64(sizeMB)<<20 < used {
+			return fmt.Errorf("size %d MB is smaller than the %d MB of files", sizeMB, used>>20)
+		}
+		size = int64(sizeMB) << 20
+	}
+	size = (size + 1<<20 - 1) &^ (1<<20 - 1)
+	inodes := max(size/rootfsBytesPerInode, files+files/2)
+
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("create image: %w", err)
+	}
+	err = f.Truncate(size)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("create image: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, mkfsBin, "-q", "-F", "-t", "ext4",
+		"-N", strconv.FormatInt(inodes, 10), "-d", dir, output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", mkfsBin, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// installAgent copies the guest agent binary into the rootfs tree at dir,
+// along with the directories the agent expects.
+func installAgent(agentBin, dir string) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	src, err := os.Open(agentBin)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst := strings.TrimPrefix(GuestAgentPath, "/")
+	if err := root.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := root.RemoveAll(dst); err != nil {
+		return err
+	}
+	f, err := root.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o755)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := root.Chmod(dst, 0o755); err != nil {
+		return err
+	}
+	return root.MkdirAll("work", 0o755)
+}
+
+// treeUsage returns the bytes of blocks used by files in the tree at dir
+// and the number of entries in it.
+func treeUsage(dir string) (used, files int64, err error) {
+	err = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		files++
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		used += (info.Size() + rootfsBlockSize - 1) / rootfsBlockSize * rootfsBlockSize
+		if info.IsDir() || info.Size() == 0 {
+			used += rootfsBlockSize
+		}
+		return nil
+	})
+	return used, files, err
+}
@@ -0,0 +1,182 @@
+package firecracker
+
+import (
+	"context"
+	"debug/buildinfo"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// requireE2fsprogs skips the test if e2fsprogs is not installed.
+func requireE2fsprogs(t *testing.T) {
+	t.Helper()
+	for _, bin := range []string{DefaultMkfsBin, DefaultDebugFSBin} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not available", bin)
+		}
+	}
+}
+
+// buildTestRootfs creates an ext4 image holding files, keyed by path in
+// the image. It skips the test if e2fsprogs is not installed.
+func buildTestRootfs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	requireE2fsprogs(t)
+
+	root := filepath.Join(t.TempDir(), "root")
+	for name, src := range files {
+		dst := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dst, data, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	image := filepath.Join(t.TempDir(), "rootfs.ext4")
+	if out, err := exec.Command("mkfs.ext4", "-q", "-d", root, image, "64M").CombinedOutput(); err != nil {
+		t.Fatalf("mkfs.ext4: %v: %s", err, out)
+	}
+	return image
+}
+
+// buildTestAgent compiles the guest agent with the given version.
+func buildTestAgent(t *testing.T, version string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the guest agent")
+	}
+	out := filepath.Join(t.TempDir(), "vulcan-guest")
+	cmd := exec.Command("go", "build", "-ldflags", "-X "+guestVersionVar+"="+version, "-o", out, guestAgentPackage)
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build guest agent: %v: %s", err, msg)
+	}
+	return out
+}
+
+func TestInspectRootfs(t *testing.T) {
+	agent := buildTestAgent(t, "v1.7.0")
+	image := buildTestRootfs(t, map[string]string{GuestAgentPath: agent})
+
+	got, err := InspectRootfs(context.Background(), DefaultDebugFSBin, image)
+	if err != nil {
+		t.Fatalf("InspectRootfs: %v", err)
+	}
+	if got.Version != "v1.7.0" {
+		t.Errorf("Version = %q, want v1.7.0", got.Version)
+	}
+}
+
+func TestInspectRootfsRejectsImages(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "agent.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	notExt4 := filepath.Join(t.TempDir(), "rootfs.ext4")
+	if err := os.WriteFile(notExt4, []byte("not a filesystem"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		image string
+		want  string
+	}{
+		{"no agent", buildTestRootfs(t, map[string]string{"/usr/local/bin/other": script}), "not found in image"},
+		{"not a Go binary", buildTestRootfs(t, map[string]string{GuestAgentPath: script}), "not a Go binary"},
+		{"other Go program", buildTestRootfs(t, map[string]string{GuestAgentPath: self}), "not the guest agent"},
+		{"not ext4", notExt4, "not found in image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := InspectRootfs(context.Background(), DefaultDebugFSBin, tt.image)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("InspectRootfs = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgentVersion(t *testing.T) {
+	tests := []struct {
+		ldflags string
+		want    string
+	}{
+		{"-X " + guestVersionVar + "=v1.2.3", "v1.2.3"},
+		{"-s -w -X=" + guestVersionVar + "=v2.0.0", "v2.0.0"},
+		{"-X main.other=x -X " + guestVersionVar + "=abc123", "abc123"},
+		{"-X main.other=x", guestDefaultVersion},
+		{"", guestDefaultVersion},
+	}
+	for _, tt := range tests {
+		bi := &buildinfo.BuildInfo{Settings: []debug.BuildSetting{{Key: "-ldflags", Value: tt.ldflags}}}
+		if got := agentVersion(bi); got != tt.want {
+			t.Errorf("agentVersion(%q) = %q, want %q", tt.ldflags, got, tt.want)
+		}
+	}
+}
+
+func TestBuildRootfs(t *testing.T) {
+	requireE2fsprogs(t)
+	agent := buildTestAgent(t, "v1.8.0")
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "usr", "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "usr", "bin", "python3"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	image := filepath.Join(t.TempDir(), "rootfs.ext4")
+	if err := BuildRootfs(context.Background(), DefaultMkfsBin, agent, dir, image, 0); err != nil {
+		t.Fatalf("BuildRootfs: %v", err)
+	}
+
+	got, err := InspectRootfs(context.Background(), DefaultDebugFSBin, image)
+	if err != nil {
+		t.Fatalf("InspectRootfs: %v", err)
+	}
+	if got.Version != "v1.8.0" {
+		t.Errorf("Version = %q, want v1.8.0", got.Version)
+	}
+	info, err := os.Stat(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() < rootfsFreeBytes {
+		t.Errorf("image size = %d, want at least %d of free space", info.Size(), rootfsFreeBytes)
+	}
+	out, err := exec.Command(DefaultDebugFSBin, "-R", "stat /usr/bin/python3", image).CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "Type: regular") {
+		t.Errorf("debugfs stat /usr/bin/python3: %v: %s", err, out)
+	}
+}
+
+func TestBuildRootfsTooSmall(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "big"), make([]byte, 2<<20), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	agent := filepath.Join(t.TempDir(), "vulcan-guest")
+	if err := os.WriteFile(agent, []byte("agent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := BuildRootfs(context.Background(), DefaultMkfsBin, agent, dir, filepath.Join(t.TempDir(), "rootfs.ext4"), 1)
+	if err == nil || !strings.Contains(err.Error(), "smaller than") {
+		t.Errorf("BuildRootfs = %v, want a size error", err)
+	}
+}
@@ -0,0 +1,226 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RuntimeManifestPath is the file in a rootfs image that declares the
+// runtimes its guest agent runs.
+const RuntimeManifestPath = "/etc/vulcan/runtimes.json"
+
+// EntrypointArg is replaced by the entrypoint's path in a runtime's Args.
+const EntrypointArg = "{entrypoint}"
+
+// ErrNoManifest is returned by ReadRootfsManifest for images without a
+// runtime manifest, built before runtimes were declared in the image.
+var ErrNoManifest = errors.New("rootfs has no runtime manifest")
+
+// runtimeNamePattern matches runtime names, which also name the rootfs
+// image file and label metrics.
+var runtimeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// RuntimeManifest is the contents of RuntimeManifestPath.
+type RuntimeManifest struct {
+	Runtimes []RuntimeDef `json:"runtimes"`
+}
+
+// RuntimeDef describes how the guest agent runs code in one runtime.
+type RuntimeDef struct {
+	Name string `json:"name"`
+
+	// Bin is the program run, looked up on PATH, and Args its arguments.
+	// EntrypointArg in Args is replaced by the entrypoint's path; without it
+	// the path is appended.
+	Bin  string   `json:"bin"`
+	Args []string `json:"args,omitempty"`
+
+	// Entrypoint is the file run when a request names none. It defaults to
+	// "main" with Extension, the extension of the runtime's source files.
+	Entrypoint string `json:"entrypoint,omitempty"`
+	Extension  string `json:"extension,omitempty"`
+
+	// Env is added to the environment of every workload in the runtime;
+	// requests' own variables take precedence.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// DefaultRuntimes are the runtimes of rootfs images without a manifest, run
+// as they were before runtimes were declared in the image.
+var DefaultRuntimes = RuntimeManifest{Runtimes: []RuntimeDef{
+	{Name: "go", Bin: "go", Args: []string{"run", EntrypointArg}, Extension: ".go"},
+	{Name: "node", Bin: "node", Entrypoint: "index.js", Extension: ".js"},
+	{Name: "python", Bin: "python3", Extension: ".py"},
+}}
+
+// ParseRuntimeManifest decodes and validates a runtime manifest.
+func ParseRuntimeManifest(data []byte) (RuntimeManifest, error) {
+	var m RuntimeManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return RuntimeManifest{}, fmt.Errorf("decode runtime manifest: %w", err)
+	}
+	if len(m.Runtimes) == 0 {
+		return RuntimeManifest{}, errors.New("runtime manifest declares no runtimes")
+	}
+	seen := make(map[string]bool, len(m.Runtimes))
+	for _, d := range m.Runtimes {
+		if err := d.Validate(); err != nil {
+			return RuntimeManifest{}, err
+		}
+		if seen[d.Name] {
+			return RuntimeManifest{}, fmt.Errorf("runtime %q is declared twice", d.Name)
+		}
+		seen[d.Name] = true
+	}
+	return m, nil
+}
+
+// Validate checks that the runtime can be run.
+func (d RuntimeDef) Validate() error {
+	if !runtimeNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("invalid runtime name %q", d.Name)
+	}
+	if d.Bin == "" {
+		return fmt.Errorf("runtime %q has no bin", d.Name)
+	}
+	if d.Extension != "" && (!strings.HasPrefix(d.Extension, ".") || strings.ContainsRune(d.Extension, '/')) {
+		return fmt.Errorf("runtime %q has invalid extension %q", d.Name, d.Extension)
+	}
+	if d.DefaultEntrypoint() == "" {
+		return fmt.Errorf("runtime %q has neither an entrypoint nor an extension", d.Name)
+	}
+	return nil
+}
+
+// DefaultEntrypoint returns the file run when a request names none, or ""
+// if the runtime has none.
+func (d RuntimeDef) DefaultEntrypoint() string {
+	if d.Entrypoint != "" {
+		return d.Entrypoint
+	}
+	if d.Extension != "" {
+		return "main" + d.Extension
+	}
+	return ""
+}
+
+// Command returns the arguments that follow Bin to run entrypoint.
+func (d RuntimeDef) Command(entrypoint string) []string {
+	if !slices.ContainsFunc(d.Args, func(arg string) bool { return strings.Contains(arg, EntrypointArg) }) {
+		return append(slices.Clone(d.Args), entrypoint)
+	}
+	args := make([]string, len(d.Args))
+	for i, arg := range d.Args {
+		args[i] = strings.ReplaceAll(arg, EntrypointArg, entrypoint)
+	}
+	return args
+}
+
+// Lookup returns the runtime named name.
+func (m RuntimeManifest) Lookup(name string) (RuntimeDef, bool) {
+	i := slices.IndexFunc(m.Runtimes, func(d RuntimeDef) bool { return d.Name == name })
+	if i < 0 {
+		return RuntimeDef{}, false
+	}
+	return m.Runtimes[i], true
+}
+
+// Names returns the names of the manifest's runtimes, sorted.
+func (m RuntimeManifest) Names() []string {
+	names := make([]string, 0, len(m.Runtimes))
+	for _, d := range m.Runtimes {
+		names = append(names, d.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ReadRootfsManifest reads the runtime manifest of the ext4 image at path
+// with debugfs, without mounting the image. Returns ErrNoManifest if the
+// image has none.
+func ReadRootfsManifest(ctx context.Context, debugfsBin, path string) (RuntimeManifest, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, debugfsBin, "-R", "cat "+RuntimeManifestPath, path)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return RuntimeManifest{}, fmt.Errorf("read image with %s: %w: %s", debugfsBin, err, strings.TrimSpace(stderr.String()))
+	}
+	// debugfs reports unreadable images and missing files on stderr but
+	// still exits zero.
+	if stdout.Len() == 0 {
+		msg := debugfsError(stderr.String())
+		if msg == "" || strings.Contains(msg, "File not found") {
+			return RuntimeManifest{}, ErrNoManifest
+		}
+		return RuntimeManifest{}, fmt.Errorf("read %s: %s", RuntimeManifestPath, msg)
+	}
+	m, err := ParseRuntimeManifest(stdout.Bytes())
+	if err != nil {
+		return RuntimeManifest{}, fmt.Errorf("%s: %w", RuntimeManifestPath, err)
+	}
+	return m, nil
+}
+
+// DiscoverRuntimes returns the runtimes the rootfs images in rootfsDir
+// provide, sorted: the image <name>.ext4 provides the runtime <name> if its
+// manifest declares it. Images without a manifest, and all images if
+// debugfsBin is not installed, are taken to provide it if it is one of
+// DefaultRuntimes; images that cannot be read or whose manifest is invalid
+// provide nothing.
+func DiscoverRuntimes(ctx context.Context, debugfsBin, rootfsDir string, logger *slog.Logger) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(rootfsDir, fmt.Sprintf(RootfsFilename, "*")))
+	if err != nil {
+		return nil, fmt.Errorf("list rootfs images: %w", err)
+	}
+
+	var runtimes []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if !validRuntimeName(name) {
+			continue
+		}
+		m, err := ReadRootfsManifest(ctx, debugfsBin, path)
+		var execErr *exec.Error
+		switch {
+		case errors.Is(err, ErrNoManifest):
+			m = DefaultRuntimes
+		case errors.As(err, &execErr):
+			logger.Warn("cannot read runtime manifest, assuming built-in runtimes", "image", path, "error", err)
+			m = DefaultRuntimes
+		case err != nil:
+			logger.Warn("skipping rootfs image", "image", path, "error", err)
+			continue
+		}
+		if _, ok := m.Lookup(name); !ok {
+			logger.Warn("rootfs image does not declare its runtime", "image", path, "runtime", name, "declares", m.Names())
+			continue
+		}
+		runtimes = append(runtimes, name)
+	}
+	slices.Sort(runtimes)
+	return runtimes, nil
+}
+
+// validRuntimeName reports whether name can name a runtime and its rootfs.
+func validRuntimeName(name string) bool {
+	return runtimeNamePattern.MatchString(name)
+}
+
+// LoadRuntimeManifest reads and validates the runtime manifest at path.
+func LoadRuntimeManifest(path string) (RuntimeManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RuntimeManifest{}, err
+	}
+	return ParseRuntimeManifest(data)
+}
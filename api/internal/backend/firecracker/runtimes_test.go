@@ -0,0 +1,151 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const rubyManifest = `{"runtimes": [
+	{"name": "ruby", "bin": "ruby", "extension": ".rb", "env": {"GEM_HOME": "/work/.gems"}},
+	{"name": "sh", "bin": "/bin/sh", "args": ["-e", "{entrypoint}"], "entrypoint": "run.sh"}
+]}`
+
+func TestParseRuntimeManifest(t *testing.T) {
+	m, err := ParseRuntimeManifest([]byte(rubyManifest))
+	if err != nil {
+		t.Fatalf("ParseRuntimeManifest: %v", err)
+	}
+	if got := m.Names(); !slices.Equal(got, []string{"ruby", "sh"}) {
+		t.Errorf("Names = %v, want [ruby sh]", got)
+	}
+	ruby, ok := m.Lookup("ruby")
+	if !ok {
+		t.Fatal("Lookup(ruby) not found")
+	}
+	if ruby.DefaultEntrypoint() != "main.rb" || ruby.Env["GEM_HOME"] != "/work/.gems" {
+		t.Errorf("ruby = %+v, want entrypoint main.rb and its env", ruby)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{"malformed", `{"runtimes": [`, "decode runtime manifest"},
+		{"empty", `{"runtimes": []}`, "declares no runtimes"},
+		{"bad name", `{"runtimes": [{"name": "../ruby", "bin": "ruby", "extension": ".rb"}]}`, "invalid runtime name"},
+		{"no bin", `{"runtimes": [{"name": "ruby", "extension": ".rb"}]}`, "has no bin"},
+		{"bad extension", `{"runtimes": [{"name": "ruby", "bin": "ruby", "extension": "rb"}]}`, "invalid extension"},
+		{"no entrypoint", `{"runtimes": [{"name": "ruby", "bin": "ruby"}]}`, "neither an entrypoint nor an extension"},
+		{"duplicate", `{"runtimes": [{"name": "sh", "bin": "sh", "entrypoint": "a"}, {"name": "sh", "bin": "sh", "entrypoint": "b"}]}`, "declared twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRuntimeManifest([]byte(tt.manifest))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseRuntimeManifest = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRuntimesAreValid(t *testing.T) {
+	for _, d := range DefaultRuntimes.Runtimes {
+		if err := d.Validate(); err != nil {
+			t.Errorf("Validate(%s): %v", d.Name, err)
+		}
+	}
+	want := map[string]string{"go": "main.go", "node": "index.js", "python": "main.py"}
+	for name, entrypoint := range want {
+		d, ok := DefaultRuntimes.Lookup(name)
+		if !ok || d.DefaultEntrypoint() != entrypoint {
+			t.Errorf("DefaultRuntimes %s = %+v, want entrypoint %s", name, d, entrypoint)
+		}
+	}
+}
+
+func TestRuntimeDefCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"/work/main.py"}},
+		{[]string{"-u"}, []string{"-u", "/work/main.py"}},
+		{[]string{"run", EntrypointArg}, []string{"run", "/work/main.py"}},
+		{[]string{"--file=" + EntrypointArg, "--"}, []string{"--file=/work/main.py", "--"}},
+	}
+	for _, tt := range tests {
+		d := RuntimeDef{Name: "x", Bin: "x", Args: tt.args}
+		if got := d.Command("/work/main.py"); !slices.Equal(got, tt.want) {
+			t.Errorf("Command with args %q = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverRuntimes(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "runtimes.json")
+	if err := os.WriteFile(manifest, []byte(rubyManifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"runtimes": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(other, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	images := map[string]map[string]string{
+		"ruby":   {RuntimeManifestPath: manifest}, // declares ruby
+		"sh":     {RuntimeManifestPath: manifest}, // declares sh alongside ruby
+		"bun":    {RuntimeManifestPath: manifest}, // does not declare bun
+		"perl":   {RuntimeManifestPath: invalid},  // invalid manifest
+		"python": {"/usr/bin/python3": other},     // predates manifests
+		"lua":    {"/usr/bin/lua": other},         // predates manifests, not built in
+	}
+	for name, files := range images {
+		image := buildTestRootfs(t, files)
+		if err := os.Rename(image, filepath.Join(dir, name+".ext4")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	got, err := DiscoverRuntimes(context.Background(), DefaultDebugFSBin, dir, logger)
+	if err != nil {
+		t.Fatalf("DiscoverRuntimes: %v", err)
+	}
+	if want := []string{"python", "ruby", "sh"}; !slices.Equal(got, want) {
+		t.Errorf("DiscoverRuntimes = %v, want %v", got, want)
+	}
+
+	// Without debugfs, images are taken to provide the built-in runtimes.
+	got, err = DiscoverRuntimes(context.Background(), "vulcan-no-such-debugfs", dir, logger)
+	if err != nil {
+		t.Fatalf("DiscoverRuntimes without debugfs: %v", err)
+	}
+	if want := []string{"python"}; !slices.Equal(got, want) {
+		t.Errorf("DiscoverRuntimes without debugfs = %v, want %v", got, want)
+	}
+}
+
+func TestReadRootfsManifestMissing(t *testing.T) {
+	other := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(other, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	image := buildTestRootfs(t, map[string]string{"/etc/hostname": other})
+
+	if _, err := ReadRootfsManifest(context.Background(), DefaultDebugFSBin, image); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("ReadRootfsManifest = %v, want ErrNoManifest", err)
+	}
+}
@@ -0,0 +1,53 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	fcsdk "github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+)
+
+// scratchDriveID identifies the scratch disk among the VM's drives.
+const scratchDriveID = "scratch"
+
+// scratchImageName is the scratch disk's file in the VM's temp dir.
+const scratchImageName = "scratch.ext4"
+
+// createScratch creates a blank ext4 filesystem of sizeMB in dir, for use as
+// a VM's scratch disk, and returns its path. The file is sparse, so it takes
+// space on the host only as the workload writes to it, and the filesystem's
+// size bounds how much it can write.
+func createScratch(ctx context.Context, mkfsBin, dir string, sizeMB int) (string, error) {
+	path := filepath.Join(dir, scratchImageName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	err = f.Truncate(int64(sizeMB) << 20)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("size scratch disk: %w", err)
+	}
+	if err := runMkfs(ctx, mkfsBin, path, ""); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// attachScratch appends the scratch disk at path to drives and returns the
+// resulting list, with the device the guest sees it as.
+func attachScratch(drives []models.Drive, path string, limits *models.RateLimiter) ([]models.Drive, string) {
+	device := guestBlockDevice(len(drives))
+	return append(drives, models.Drive{
+		DriveID:      fcsdk.String(scratchDriveID),
+		PathOnHost:   fcsdk.String(path),
+		IsRootDevice: fcsdk.Bool(false),
+		IsReadOnly:   fcsdk.Bool(false),
+		RateLimiter:  limits,
+	}), device
+}
@@ -0,0 +1,70 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	fcsdk "github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+)
+
+func TestCreateScratch(t *testing.T) {
+	if _, err := exec.LookPath(DefaultMkfsBin); err != nil {
+		t.Skip("mkfs.ext4 not available")
+	}
+	dir := t.TempDir()
+
+	path, err := createScratch(context.Background(), DefaultMkfsBin, dir, 64)
+	if err != nil {
+		t.Fatalf("createScratch: %v", err)
+	}
+	if path != filepath.Join(dir, scratchImageName) {
+		t.Errorf("path = %q, want it in the VM dir", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 64<<20 {
+		t.Errorf("size = %d, want %d", info.Size(), 64<<20)
+	}
+	if st := info.Sys().(*syscall.Stat_t); st.Blocks*512 >= info.Size() {
+		t.Errorf("scratch disk takes %d bytes on the host, want a sparse file", st.Blocks*512)
+	}
+	out, err := exec.Command("dumpe2fs", "-h", path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("dumpe2fs: %v: %s", err, out)
+	}
+	if !strings.Contains(string(out), "Block count:") {
+		t.Errorf("dumpe2fs output lacks a superblock:\n%s", out)
+	}
+
+	if _, err := createScratch(context.Background(), DefaultMkfsBin, dir, 64); err == nil {
+		t.Error("createScratch replaced an existing scratch disk")
+	}
+}
+
+func TestAttachScratch(t *testing.T) {
+	drives := []models.Drive{
+		{DriveID: fcsdk.String(rootfsDriveID)},
+		{DriveID: fcsdk.String(volumeDriveIDPrefix + "0")},
+	}
+	limits := &models.RateLimiter{}
+
+	drives, device := attachScratch(drives, "/tmp/vm/scratch.ext4", limits)
+	if device != "/dev/vdc" {
+		t.Errorf("device = %q, want /dev/vdc", device)
+	}
+	if len(drives) != 3 {
+		t.Fatalf("got %d drives, want 3", len(drives))
+	}
+	d := drives[2]
+	if *d.DriveID != scratchDriveID || *d.PathOnHost != "/tmp/vm/scratch.ext4" || *d.IsReadOnly || *d.IsRootDevice || d.RateLimiter != limits {
+		t.Errorf("scratch drive = %+v", d)
+	}
+}
@@ -0,0 +1,51 @@
+package firecracker
+
+import (
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// serviceRequest translates a service spec into the guest's form, or
+// returns nil for workloads that run to completion.
+func serviceRequest(svc *model.ServiceSpec) *ServiceRequest {
+	if svc == nil {
+		return nil
+	}
+	spec := svc.WithDefaults()
+	req := &ServiceRequest{
+		Restart:      spec.Restart.Policy,
+		MaxRestarts:  spec.Restart.MaxRestarts,
+		BackoffMS:    spec.Restart.BackoffMS,
+		MaxBackoffMS: spec.Restart.MaxBackoffMS,
+	}
+	if hc := spec.HealthCheck; hc != nil {
+		req.HealthCheck = &HealthCheck{
+			IntervalMS:    hc.IntervalS * 1000,
+			TimeoutMS:     hc.TimeoutS * 1000,
+			Retries:       hc.Retries,
+			StartPeriodMS: hc.StartPeriodS * 1000,
+		}
+		if hc.Type == model.HealthCheckHTTP {
+			req.HealthCheck.HTTPPort = hc.Port
+			req.HealthCheck.HTTPPath = hc.Path
+		} else {
+			req.HealthCheck.Command = hc.Command
+		}
+	}
+	return req
+}
+
+// healthReporter returns the handler for a service's health messages, which
+// counts restarts and passes each report on to the spec's OnHealth.
+func healthReporter(spec backend.WorkloadSpec) func(HealthReport) {
+	restarts := 0
+	return func(r HealthReport) {
+		if r.Restarts > restarts {
+			serviceRestartsTotal.WithLabelValues(spec.Runtime).Add(float64(r.Restarts - restarts))
+			restarts = r.Restarts
+		}
+		if spec.OnHealth != nil {
+			spec.OnHealth(backend.HealthReport{Status: r.Status, Detail: r.Detail, Restarts: r.Restarts})
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package firecracker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+func TestServiceRequest(t *testing.T) {
+	if req := serviceRequest(nil); req != nil {
+		t.Errorf("serviceRequest(nil) = %+v, want nil", req)
+	}
+
+	req := serviceRequest(&model.ServiceSpec{
+		Restart:     model.RestartPolicy{Policy: model.RestartOnFailure, MaxRestarts: 4},
+		HealthCheck: &model.HealthCheck{Type: model.HealthCheckHTTP, Port: 8080, StartPeriodS: 2},
+	})
+	want := &ServiceRequest{
+		Restart:      model.RestartOnFailure,
+		MaxRestarts:  4,
+		BackoffMS:    model.DefaultRestartBackoffMS,
+		MaxBackoffMS: model.DefaultRestartMaxBackoffMS,
+		HealthCheck: &HealthCheck{
+			HTTPPort:      8080,
+			HTTPPath:      "/",
+			IntervalMS:    model.DefaultHealthIntervalS * 1000,
+			TimeoutMS:     model.DefaultHealthTimeoutS * 1000,
+			Retries:       model.DefaultHealthRetries,
+			StartPeriodMS: 2000,
+		},
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("serviceRequest = %+v, want %+v", req, want)
+	}
+
+	req = serviceRequest(&model.ServiceSpec{
+		HealthCheck: &model.HealthCheck{Type: model.HealthCheckCommand, Command: []string{"pg_isready", "-q"}},
+	})
+	if req.Restart != model.RestartAlways || req.HealthCheck.HTTPPort != 0 ||
+		!reflect.DeepEqual(req.HealthCheck.Command, []string{"pg_isready", "-q"}) {
+		t.Errorf("command check request = %+v, health check %+v", req, req.HealthCheck)
+	}
+}
+
+func TestHealthReporter(t *testing.T) {
+	var got []backend.HealthReport
+	spec := backend.WorkloadSpec{
+		Runtime:  model.RuntimePython,
+		OnHealth: func(r backend.HealthReport) { got = append(got, r) },
+	}
+	restarts := serviceRestartsTotal.WithLabelValues(model.RuntimePython)
+	before := counterValue(t, restarts)
+
+	report := healthReporter(spec)
+	report(HealthReport{Status: HealthStarting})
+	report(HealthReport{Status: HealthUnhealthy, Detail: "exited with code 1"})
+	report(HealthReport{Status: HealthStarting, Restarts: 1})
+	report(HealthReport{Status: HealthHealthy, Restarts: 1})
+	report(HealthReport{Status: HealthStarting, Restarts: 3})
+
+	want := []backend.HealthReport{
+		{Status: model.HealthStarting},
+		{Status: model.HealthUnhealthy, Detail: "exited with code 1"},
+		{Status: model.HealthStarting, Restarts: 1},
+		{Status: model.HealthHealthy, Restarts: 1},
+		{Status: model.HealthStarting, Restarts: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reports = %+v, want %+v", got, want)
+	}
+	if delta := counterValue(t, restarts) - before; delta != 3 {
+		t.Errorf("restart counter rose by %v, want 3", delta)
+	}
+}
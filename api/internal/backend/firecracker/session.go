@@ -0,0 +1,192 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/backend"
+)
+
+// runSession keeps a booted VM running the executions of spec's session
+// until ctx is cancelled or the session's execution channel is closed. The
+// first execution is sent on gc, the connection the handshake was done on;
+// each later one dials the agent afresh, as the agent serves one request
+// per connection. The agent leaves the work directory as each execution
+// left it, unless the session runs executions concurrently: then up to
+// Concurrency of them, or as many as the agent takes at once, run side by
+// side, each in a work directory of its own.
+func (b *Backend) runSession(ctx context.Context, spec backend.WorkloadSpec, state *vmState, vsockPath string, gc *GuestConn, agent GuestHello) (backend.WorkloadResult, error) {
+	start := time.Now()
+	if spec.Session.OnReady != nil {
+		spec.Session.OnReady()
+	}
+	b.logger.Info("session ready", "workload_id", spec.ID, "runtime", spec.Runtime)
+
+	slots := make(chan struct{}, execLimit(spec.Session, agent))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	execs := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return b.endSession(spec, execs, start), nil
+		case x, ok := <-spec.Session.Execs:
+			if !ok {
+				return b.endSession(spec, execs, start), nil
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				x.Result <- backend.ExecResult{Err: fmt.Errorf("session ended: %w", ctx.Err())}
+				return b.endSession(spec, execs, start), nil
+			}
+			first := gc
+			gc = nil
+			execs++
+			wg.Go(func() {
+				defer func() { <-slots }()
+				result, err := b.runExec(ctx, spec, state, vsockPath, first, agent, x)
+				x.Result <- backend.ExecResult{Result: result, Err: err}
+			})
+		}
+	}
+}
+
+// execLimit returns how many executions of session run at once on a VM
+// whose agent is agent.
+func execLimit(session *backend.SessionSpec, agent GuestHello) int {
+	if session.Concurrency <= 1 || !agent.HasFeature(FeatureMultiplex) {
+		return 1
+	}
+	return min(session.Concurrency, max(agent.MaxExecs, 1))
+}
+
+// endSession logs the end of a session and returns its result.
+func (b *Backend) endSession(spec backend.WorkloadSpec, execs int, start time.Time) backend.WorkloadResult {
+	duration := time.Since(start)
+	b.logger.Info("session ended", "workload_id", spec.ID, "execs", execs, "duration_ms", duration.Milliseconds())
+	return backend.WorkloadResult{DurationMS: int(duration.Milliseconds())}
+}
+
+// runExec runs one execution of a session, on gc if it is not nil. The
+// execution is cancelled when either its own context or the session's is.
+func (b *Backend) runExec(ctx context.Context, spec backend.WorkloadSpec, state *vmState, vsockPath string, gc *GuestConn, agent GuestHello, x backend.ExecRequest) (result backend.WorkloadResult, err error) {
+	start := time.Now()
+	execCtx, cancel := context.WithCancel(x.Ctx)
+	defer cancel()
+	stopSessionWatch := context.AfterFunc(ctx, cancel)
+	defer stopSessionWatch()
+
+	concurrent := spec.Session.Concurrency > 1
+	defer func() {
+		status := statusCompleted
+		switch {
+		case errors.Is(execCtx.Err(), context.Canceled):
+			status = statusKilled
+		case err != nil:
+			status = statusFailed
+		}
+		// The executions of packed VMs are workloads of their own.
+		if concurrent {
+			workloadsTotal.WithLabelValues(spec.Runtime, status).Inc()
+		} else {
+			sessionExecsTotal.WithLabelValues(spec.Runtime, status).Inc()
+		}
+	}()
+
+	if gc == nil {
+		if gc, _, err = b.connectGuest(execCtx, spec.Runtime, vsockPath); err != nil {
+			if execCtx.Err() == nil {
+				err = guestLost(state.machine.Wait, err)
+			}
+			return backend.WorkloadResult{}, fmt.Errorf("connect to guest: %w", err)
+		}
+	}
+	grace := gracePeriod(x.GraceS)
+	if err := gc.SetDeadline(graceDeadline(execCtx, grace)); err != nil {
+		gc.Close()
+		return backend.WorkloadResult{}, fmt.Errorf("set deadline: %w", err)
+	}
+	defer gc.Close()
+
+	// Concurrent executions share the VM, so only their own IDs reach them.
+	if !concurrent {
+		b.mu.Lock()
+		state.guest = gc
+		b.mu.Unlock()
+	}
+	defer b.trackExec(x.ID, gc, agent)()
+
+	execID := x.ID
+	if execID == "" {
+		execID = spec.ID
+	}
+	stopCancelWatch := context.AfterFunc(execCtx, func() {
+		b.stopGuest(execID, gc, agent, grace)
+	})
+	defer stopCancelWatch()
+
+	req := GuestRequest{
+		Runtime:     spec.Runtime,
+		Code:        x.Code,
+		CodeArchive: x.CodeArchive,
+		TimeoutS:    x.TimeoutS,
+		GraceMS:     int(grace.Milliseconds()),
+	}
+	if !concurrent {
+		req.Session = &SessionRequest{Interpreter: spec.Session.Interpreter}
+	}
+	if spec.Image != nil {
+		req.Command = spec.Image.Command
+		req.Entrypoint = spec.Image.Entrypoint
+	}
+	if agent.HasFeature(FeatureControl) {
+		req.HeartbeatMS = int(HeartbeatInterval.Milliseconds())
+	}
+
+	var stdout, stderr outputBuffer
+	sio := StreamIO{LogWriter: x.LogWriter}
+	if agent.HasFeature(FeatureChunkedIO) {
+		sio.Stdout = &stdout
+		sio.Stderr = &stderr
+	}
+
+	resp, err := gc.RunWorkloadStream(req, sio)
+	if err != nil {
+		if execCtx.Err() == nil {
+			err = guestLost(state.machine.Wait, err)
+		}
+		return backend.WorkloadResult{}, fmt.Errorf("run workload: %w", err)
+	}
+
+	observeUsage(spec.Runtime, resp.Usage)
+
+	return withOutput(backend.WorkloadResult{
+		ExitCode:   resp.ExitCode,
+		Error:      resp.Error,
+		DurationMS: int(time.Since(start).Milliseconds()),
+		LogLines:   resp.LogLines,
+		Usage:      toModelUsage(resp.Usage),
+		ExitReason: resp.ExitReason,
+		ExitSignal: resp.ExitSignal,
+	}, resp, &stdout, &stderr), nil
+}
+
+// withOutput sets the output of result from stdout and stderr, as streamed
+// by the guest, or from resp for guests without chunked output support,
+// which reply inline with both streams in one.
+func withOutput(result backend.WorkloadResult, resp GuestResponse, stdout, stderr *outputBuffer) backend.WorkloadResult {
+	if stdout.Len() == 0 && stderr.Len() == 0 {
+		result.Output = []byte(resp.Output)
+		return result
+	}
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+	result.Output = append(slices.Clip(result.Stdout), result.Stderr...)
+	return result
+}
@@ -0,0 +1,214 @@
+package firecracker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// serveSessionGuest answers every connection to l as an agent with the
+// session feature would, completing the handshake if the host starts one,
+// and replying to each request with its code as output. It sends the
+// requests it receives on reqs. Each reply waits for a value on release,
+// if it is not nil.
+func serveSessionGuest(l net.Listener, reqs chan<- GuestRequest, release <-chan struct{}) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			conn.Write([]byte("OK 1024\n"))
+
+			frame, err := ReadFrame(r)
+			if err != nil {
+				return
+			}
+			var envelope struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal(frame, &envelope)
+			if envelope.Type == MsgTypeHello {
+				hello := GuestHello{AgentVersion: "test", ProtocolVersion: ProtocolVersion, Features: []string{FeatureSession, FeatureMultiplex}, MaxExecs: 4}
+				WriteMessage(conn, &GuestMessage{Type: MsgTypeHello, Hello: &hello})
+				if frame, err = ReadFrame(r); err != nil {
+					return
+				}
+			}
+			var req GuestRequest
+			if err := json.Unmarshal(frame, &req); err != nil {
+				return
+			}
+			reqs <- req
+			if release != nil {
+				<-release
+			}
+			WriteMessage(conn, &GuestMessage{Type: MsgTypeResult, Response: &GuestResponse{Output: req.Code}})
+		}()
+	}
+}
+
+func TestRunSession(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "vsock.sock")
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	reqs := make(chan GuestRequest, 2)
+	go serveSessionGuest(l, reqs, nil)
+
+	b := &Backend{
+		cfg:       Config{VsockPort: DefaultVsockPort},
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		agents:    make(map[string]backend.AgentInfo),
+		activeVMs: make(map[string]*vmState),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	gc, agent, err := b.connectGuest(ctx, model.RuntimePython, sockPath)
+	if err != nil {
+		t.Fatalf("connectGuest: %v", err)
+	}
+
+	execs := make(chan backend.ExecRequest)
+	ready := make(chan struct{})
+	spec := backend.WorkloadSpec{
+		ID:      "session-1",
+		Runtime: model.RuntimePython,
+		Session: &backend.SessionSpec{Interpreter: true, Execs: execs, OnReady: func() { close(ready) }},
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.runSession(ctx, spec, &vmState{}, sockPath, gc, agent)
+		done <- err
+	}()
+	<-ready
+
+	// The first execution reuses the handshake's connection; the second
+	// dials the agent again.
+	for _, code := range []string{"x = 1", "print(x)"} {
+		results := make(chan backend.ExecResult, 1)
+		execs <- backend.ExecRequest{Ctx: ctx, Code: code, TimeoutS: 5, Result: results}
+		res := <-results
+		if res.Err != nil {
+			t.Fatalf("exec %q: %v", code, res.Err)
+		}
+		if string(res.Result.Output) != code {
+			t.Errorf("exec output = %q, want %q", res.Result.Output, code)
+		}
+		req := <-reqs
+		if req.Session == nil || !req.Session.Interpreter || req.TimeoutS != 5 {
+			t.Errorf("request session %+v timeout %d, want an interpreted session execution", req.Session, req.TimeoutS)
+		}
+	}
+
+	close(execs)
+	if err := <-done; err != nil {
+		t.Errorf("runSession: %v", err)
+	}
+}
+
+func TestRunSessionConcurrently(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "vsock.sock")
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	reqs := make(chan GuestRequest, 3)
+	release := make(chan struct{})
+	go serveSessionGuest(l, reqs, release)
+
+	b := &Backend{
+		cfg:       Config{VsockPort: DefaultVsockPort},
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		agents:    make(map[string]backend.AgentInfo),
+		activeVMs: make(map[string]*vmState),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	gc, agent, err := b.connectGuest(ctx, model.RuntimePython, sockPath)
+	if err != nil {
+		t.Fatalf("connectGuest: %v", err)
+	}
+
+	execs := make(chan backend.ExecRequest)
+	spec := backend.WorkloadSpec{
+		ID:      "pack-1",
+		Runtime: model.RuntimePython,
+		Session: &backend.SessionSpec{Execs: execs, Concurrency: 2},
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.runSession(ctx, spec, &vmState{}, sockPath, gc, agent)
+		done <- err
+	}()
+
+	// Two executions reach the agent before either has finished; the
+	// third waits for a slot.
+	results := make(chan backend.ExecResult, 3)
+	for _, id := range []string{"w1", "w2", "w3"} {
+		go func() { execs <- backend.ExecRequest{ID: id, Ctx: ctx, Code: id, TimeoutS: 5, Result: results} }()
+	}
+	for range 2 {
+		if req := <-reqs; req.Session != nil {
+			t.Errorf("concurrent execution sent as a session request: %+v", req.Session)
+		}
+	}
+	select {
+	case req := <-reqs:
+		t.Fatalf("execution %q started beyond the session's concurrency", req.Code)
+	case <-time.After(100 * time.Millisecond):
+	}
+	b.mu.Lock()
+	tracked := len(b.execs)
+	b.mu.Unlock()
+	if tracked != 2 {
+		t.Errorf("%d executions tracked for signals, want 2", tracked)
+	}
+
+	for range 3 {
+		release <- struct{}{}
+	}
+	for range 3 {
+		if res := <-results; res.Err != nil {
+			t.Errorf("exec: %v", res.Err)
+		}
+	}
+
+	close(execs)
+	if err := <-done; err != nil {
+		t.Errorf("runSession: %v", err)
+	}
+}
+
+func TestWithOutput(t *testing.T) {
+	var stdout, stderr outputBuffer
+	stdout.Write([]byte("out\n"))
+	stderr.Write([]byte("err\n"))
+	res := withOutput(backend.WorkloadResult{ExitCode: 1}, GuestResponse{}, &stdout, &stderr)
+	if string(res.Output) != "out\nerr\n" || string(res.Stdout) != "out\n" || string(res.Stderr) != "err\n" || res.ExitCode != 1 {
+		t.Errorf("streamed result = output %q stdout %q stderr %q exit %d", res.Output, res.Stdout, res.Stderr, res.ExitCode)
+	}
+
+	// Guests without chunked output reply with both streams in one.
+	res = withOutput(backend.WorkloadResult{}, GuestResponse{Output: "both"}, &outputBuffer{}, &outputBuffer{})
+	if string(res.Output) != "both" || res.Stdout != nil || res.Stderr != nil {
+		t.Errorf("inline result = output %q stdout %q stderr %q, want only the combined output", res.Output, res.Stdout, res.Stderr)
+	}
+}
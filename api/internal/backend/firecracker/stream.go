@@ -0,0 +1,198 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Chunked streaming defaults.
+const (
+	// ChunkSize is the maximum payload carried by a single chunk frame.
+	ChunkSize = 32 << 10
+
+	// ChunkWindow is the number of chunk frames a sender may have in flight
+	// before it must wait for the receiver to acknowledge them.
+	ChunkWindow = 8
+)
+
+// ErrStreamAborted is returned by chunk readers and writers when the
+// connection carrying the stream goes away before the stream completes.
+var ErrStreamAborted = errors.New("stream aborted")
+
+// ChunkWriter splits writes into chunk frames with credit-based flow control.
+// At most ChunkWindow chunks are unacknowledged at any time; Write blocks
+// until the receiver returns credits via Ack.
+type ChunkWriter struct {
+	send    func(msgType string, data []byte) error
+	credits chan struct{}
+	done    <-chan struct{}
+	closed  bool
+}
+
+// NewChunkWriter creates a ChunkWriter that emits frames through send.
+// Pending writes fail with ErrStreamAborted once done is closed.
+func NewChunkWriter(send func(msgType string, data []byte) error, done <-chan struct{}) *ChunkWriter {
+	return &ChunkWriter{
+		send:    send,
+		credits: make(chan struct{}, ChunkWindow),
+		done:    done,
+	}
+}
+
+// Write sends p as one or more chunk frames.
+func (w *ChunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), ChunkSize)
+
+		select {
+		case w.credits <- struct{}{}:
+		case <-w.done:
+			return written, ErrStreamAborted
+		}
+
+		if err := w.send(MsgTypeChunk, p[:n]); err != nil {
+			return written, fmt.Errorf("send chunk: %w", err)
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close sends the end-of-stream marker. Subsequent calls are no-ops.
+func (w *ChunkWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.send(MsgTypeChunkEnd, nil); err != nil {
+		return fmt.Errorf("send chunk end: %w", err)
+	}
+	return nil
+}
+
+// Ack returns n credits to the writer. Credits beyond those outstanding are ignored.
+func (w *ChunkWriter) Ack(n int) {
+	for range n {
+		select {
+		case <-w.credits:
+		default:
+			return
+		}
+	}
+}
+
+// ChunkReader reassembles chunk frames delivered by a connection's read loop
+// into an io.Reader, acknowledging each chunk as it is consumed.
+type ChunkReader struct {
+	chunks chan []byte
+	ack    func(n int) error
+	done   <-chan struct{}
+	buf    []byte
+	eof    bool
+
+	mu     sync.Mutex
+	ended  bool
+	err    error         // set by Fail
+	failed chan struct{} // closed by Fail
+}
+
+// NewChunkReader creates a ChunkReader that acknowledges consumed chunks via
+// ack. Pending reads fail with ErrStreamAborted once done is closed.
+func NewChunkReader(ack func(n int) error, done <-chan struct{}) *ChunkReader {
+	return &ChunkReader{
+		chunks: make(chan []byte, ChunkWindow),
+		ack:    ack,
+		done:   done,
+		failed: make(chan struct{}),
+	}
+}
+
+// Fail abandons the stream after a protocol violation: reads return err,
+// even of chunks already buffered, so the consumer never sees a stream
+// with chunks missing, and later chunks are dropped. Only the first call
+// has an effect.
+func (r *ChunkReader) Fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		r.err = err
+		close(r.failed)
+	}
+}
+
+// Err returns the error the stream failed with, or nil.
+func (r *ChunkReader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Push delivers a received chunk. It never blocks: a sender that exceeds the
+// flow-control window is a protocol violation and is reported as an error.
+func (r *ChunkReader) Push(data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	if r.ended {
+		return fmt.Errorf("chunk received after end of stream")
+	}
+	select {
+	case r.chunks <- data:
+		return nil
+	default:
+		return fmt.Errorf("chunk window of %d exceeded", ChunkWindow)
+	}
+}
+
+// End marks the stream complete. Reads return io.EOF once buffered chunks
+// are drained. Subsequent calls are no-ops.
+func (r *ChunkReader) End() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.ended {
+		r.ended = true
+		close(r.chunks)
+	}
+}
+
+// Read implements io.Reader.
+func (r *ChunkReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.failed:
+		return 0, r.Err()
+	default:
+	}
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		select {
+		case data, ok := <-r.chunks:
+			if !ok {
+				r.eof = true
+				continue
+			}
+			r.buf = data
+			if err := r.ack(1); err != nil {
+				return 0, fmt.Errorf("ack chunk: %w", err)
+			}
+		case <-r.done:
+			return 0, ErrStreamAborted
+		case <-r.failed:
+			return 0, r.Err()
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
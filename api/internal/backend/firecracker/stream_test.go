@@ -0,0 +1,196 @@
+package firecracker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chunkPipe wires a ChunkWriter directly to a ChunkReader, delivering frames
+// and acknowledgements synchronously as a connection's read loops would.
+func chunkPipe(t *testing.T) (*ChunkWriter, *ChunkReader, chan struct{}) {
+	t.Helper()
+	done := make(chan struct{})
+
+	var w *ChunkWriter
+	r := NewChunkReader(func(n int) error {
+		w.Ack(n)
+		return nil
+	}, done)
+	w = NewChunkWriter(func(msgType string, data []byte) error {
+		switch msgType {
+		case MsgTypeChunk:
+			return r.Push(bytes.Clone(data))
+		case MsgTypeChunkEnd:
+			r.End()
+		}
+		return nil
+	}, done)
+	return w, r, done
+}
+
+func TestChunkWriterReaderRoundTrip(t *testing.T) {
+	w, r, _ := chunkPipe(t)
+
+	// Larger than the whole flow-control window, so the writer must wait for acks.
+	payload := bytes.Repeat([]byte("0123456789abcdef"), (ChunkWindow+3)*ChunkSize/16+7)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if _, err := w.Write(payload); err != nil {
+			errCh <- err
+			return
+		}
+		errCh <- w.Close()
+	}()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("writer: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("read %d bytes, want %d identical bytes", len(got), len(payload))
+	}
+}
+
+func TestChunkWriterSplitsFrames(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	w := NewChunkWriter(func(msgType string, data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if msgType == MsgTypeChunk {
+			sizes = append(sizes, len(data))
+		}
+		return nil
+	}, make(chan struct{}))
+
+	if _, err := w.Write(make([]byte, 2*ChunkSize+1)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	want := []int{ChunkSize, ChunkSize, 1}
+	if len(sizes) != len(want) {
+		t.Fatalf("sent %d chunks, want %d", len(sizes), len(want))
+	}
+	for i := range want {
+		if sizes[i] != want[i] {
+			t.Errorf("chunk %d size = %d, want %d", i, sizes[i], want[i])
+		}
+	}
+}
+
+func TestChunkWriterBlocksWithoutCredits(t *testing.T) {
+	done := make(chan struct{})
+	w := NewChunkWriter(func(string, []byte) error { return nil }, done)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := w.Write(make([]byte, (ChunkWindow+1)*ChunkSize))
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("Write returned early with %v; want it to block on flow control", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(done)
+	if err := <-errCh; !errors.Is(err, ErrStreamAborted) {
+		t.Errorf("Write error = %v, want ErrStreamAborted", err)
+	}
+}
+
+func TestChunkReaderWindowExceeded(t *testing.T) {
+	r := NewChunkReader(func(int) error { return nil }, make(chan struct{}))
+
+	for i := range ChunkWindow {
+		if err := r.Push([]byte{byte(i)}); err != nil {
+			t.Fatalf("Push %d: %v", i, err)
+		}
+	}
+	if err := r.Push([]byte("overflow")); err == nil {
+		t.Fatal("expected error when exceeding chunk window")
+	}
+}
+
+func TestChunkReaderPushAfterEnd(t *testing.T) {
+	r := NewChunkReader(func(int) error { return nil }, make(chan struct{}))
+	r.End()
+	r.End() // idempotent
+
+	if err := r.Push([]byte("late")); err == nil {
+		t.Fatal("expected error for chunk after end")
+	}
+	if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read error = %v, want io.EOF", err)
+	}
+}
+
+func TestChunkReaderAborted(t *testing.T) {
+	done := make(chan struct{})
+	r := NewChunkReader(func(int) error { return nil }, done)
+	close(done)
+
+	_, err := io.ReadAll(r)
+	if !errors.Is(err, ErrStreamAborted) {
+		t.Errorf("ReadAll error = %v, want ErrStreamAborted", err)
+	}
+}
+
+func TestChunkReaderFail(t *testing.T) {
+	r := NewChunkReader(func(int) error { return nil }, make(chan struct{}))
+	if err := r.Push([]byte("buffered")); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	violation := errors.New("chunk window exceeded")
+	r.Fail(violation)
+	r.Fail(errors.New("later")) // only the first error is kept
+
+	if _, err := io.ReadAll(r); !errors.Is(err, violation) {
+		t.Errorf("ReadAll error = %v, want the violation", err)
+	}
+	if err := r.Push([]byte("late")); !errors.Is(err, violation) {
+		t.Errorf("Push after Fail = %v, want the violation", err)
+	}
+}
+
+func TestChunkWriterCloseOnce(t *testing.T) {
+	var ends int
+	w := NewChunkWriter(func(msgType string, _ []byte) error {
+		if msgType == MsgTypeChunkEnd {
+			ends++
+		}
+		return nil
+	}, make(chan struct{}))
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if ends != 1 {
+		t.Errorf("sent %d end markers, want 1", ends)
+	}
+}
+
+func TestChunkWriterSendError(t *testing.T) {
+	w := NewChunkWriter(func(string, []byte) error {
+		return errors.New("broken pipe")
+	}, make(chan struct{}))
+
+	_, err := w.Write([]byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "broken pipe") {
+		t.Errorf("Write error = %v, want to contain 'broken pipe'", err)
+	}
+}
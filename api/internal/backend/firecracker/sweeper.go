@@ -0,0 +1,433 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/vishvananda/netlink"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// DefaultSweepInterval is how often the backend looks for resources leaked
+// by VMs that are no longer running.
+const DefaultSweepInterval = 5 * time.Minute
+
+// vmDirPrefix prefixes the temporary directory of each VM, which is followed
+// by the workload ID and a random suffix.
+const vmDirPrefix = "vulcan-vm-"
+
+// Kinds of leaked resources.
+const (
+	leakProcess  = "process"
+	leakCNICache = "cni_cache"
+	leakNetNS    = "netns"
+	leakTAP      = "tap"
+	leakBridge   = "bridge"
+	leakTempDir  = "temp_dir"
+)
+
+// leakKinds lists the kinds of leaked resources in the order they are removed:
+// processes first, so that nothing holds the rest, and addresses are released
+// through CNI before their namespaces disappear.
+var leakKinds = []string{leakProcess, leakCNICache, leakNetNS, leakTAP, leakBridge, leakTempDir}
+
+// sweepEnv locates the host resources the sweeper inspects and removes them.
+// Tests point it at fakes.
+type sweepEnv struct {
+	netnsDir    string
+	tempDir     string
+	procDir     string
+	deleteNetNS func(name string) error
+	kill        func(pid int) error
+	links       func() ([]netlink.Link, error)
+	deleteLink  func(name string) error
+}
+
+func defaultSweepEnv() sweepEnv {
+	return sweepEnv{
+		netnsDir:    NetNSRunDir,
+		tempDir:     os.TempDir(),
+		procDir:     "/proc",
+		deleteNetNS: deleteNetNS,
+		kill:        killProcess,
+		links:       netlink.LinkList,
+		deleteLink:  deleteLink,
+	}
+}
+
+// killProcess kills pid. It returns nil if the process has already exited.
+func killProcess(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
+
+// orphan is a leaked resource and the function that removes it.
+type orphan struct {
+	backend.LeakedResource
+	remove func(ctx context.Context) error
+}
+
+// StartSweeper removes resources leaked by earlier runs, then keeps sweeping
+// every Config.SweepInterval until Shutdown. A zero interval disables it.
+func (b *Backend) StartSweeper() {
+	if b.cfg.SweepInterval <= 0 {
+		return
+	}
+	b.sweep(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	b.stopSweeper = func() {
+		cancel()
+		<-done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(b.cfg.SweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.sweep(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// sweep runs a sweep and logs its outcome.
+func (b *Backend) sweep(ctx context.Context) {
+	report, err := b.Sweep(ctx, false)
+	if err != nil {
+		b.logger.Warn("sweep interrupted", "error", err)
+	}
+	if len(report.Resources) > 0 {
+		b.logger.Warn("removed leaked VM resources", "count", len(report.Resources))
+	}
+}
+
+// Sweep finds processes, CNI cache entries, network namespaces, network
+// devices and temporary directories left behind by VMs that are no longer
+// running and, unless dryRun is set, removes them. Failures to inspect or
+// remove a resource are reported rather than returned; the error is set only
+// if ctx ends the sweep early.
+func (b *Backend) Sweep(ctx context.Context, dryRun bool) (backend.SweepReport, error) {
+	b.sweepMu.Lock()
+	defer b.sweepMu.Unlock()
+
+	orphans, errs := b.findOrphans()
+	report := backend.SweepReport{
+		Backend:   BackendName,
+		DryRun:    dryRun,
+		Resources: make([]backend.LeakedResource, 0, len(orphans)),
+	}
+	for _, err := range errs {
+		report.Errors = append(report.Errors, err.Error())
+		b.logger.Warn("sweep could not inspect resources", "error", err)
+	}
+
+	for _, o := range orphans {
+		if !dryRun {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			leakedResourcesTotal.WithLabelValues(o.Kind).Inc()
+			if err := o.remove(ctx); err != nil {
+				o.Error = err.Error()
+				sweepFailuresTotal.WithLabelValues(o.Kind).Inc()
+				b.logger.Warn("failed to remove leaked resource",
+					"kind", o.Kind, "name", o.Name, "workload_id", o.WorkloadID, "error", err)
+			} else {
+				o.Removed = true
+				b.logger.Info("removed leaked resource", "kind", o.Kind, "name", o.Name, "workload_id", o.WorkloadID)
+			}
+		}
+		report.Resources = append(report.Resources, o.LeakedResource)
+	}
+	return report, nil
+}
+
+// findOrphans lists the backend's resources on the host and returns those
+// that belong to no live VM, in removal order.
+func (b *Backend) findOrphans() ([]orphan, []error) {
+	finders := map[string]func() ([]orphan, error){
+		leakProcess:  b.findProcesses,
+		leakCNICache: b.findCNICache,
+		leakNetNS:    b.findNetNS,
+		leakTempDir:  b.findTempDirs,
+	}
+
+	var candidates []orphan
+	var errs []error
+	for _, kind := range leakKinds {
+		find, ok := finders[kind]
+		if !ok {
+			continue
+		}
+		found, err := find()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		candidates = append(candidates, found...)
+	}
+
+	// The live set is read after listing, so that a VM started meanwhile is
+	// never mistaken for an orphan.
+	live := b.liveVMs()
+	orphans := make([]orphan, 0, len(candidates))
+	for _, c := range candidates {
+		if !live[c.WorkloadID] {
+			orphans = append(orphans, c)
+		}
+	}
+
+	links, err := b.findLinks()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	orphans = append(orphans, links...)
+
+	// Keep the removal order across kinds.
+	rank := make(map[string]int, len(leakKinds))
+	for i, kind := range leakKinds {
+		rank[kind] = i
+	}
+	slices.SortStableFunc(orphans, func(a, b orphan) int { return rank[a.Kind] - rank[b.Kind] })
+	return orphans, errs
+}
+
+// liveVMs returns the IDs of the workloads the backend is executing,
+// including those still being set up or torn down.
+func (b *Backend) liveVMs() map[string]bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	live := make(map[string]bool, len(b.inflight)+len(b.activeVMs))
+	for id := range b.inflight {
+		live[id] = true
+	}
+	for id := range b.activeVMs {
+		live[id] = true
+	}
+	return live
+}
+
+// findProcesses lists Firecracker processes started for a VM.
+func (b *Backend) findProcesses() ([]orphan, error) {
+	entries, err := os.ReadDir(b.sweepEnv.procDir)
+	if err != nil {
+		return nil, fmt.Errorf("list processes: %w", err)
+	}
+
+	var found []orphan
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(b.sweepEnv.procDir, e.Name(), "cmdline"))
+		if err != nil {
+			continue // exited meanwhile
+		}
+		id, ok := firecrackerWorkload(cmdline)
+		if !ok {
+			continue
+		}
+		found = append(found, orphan{
+			LeakedResource: backend.LeakedResource{Kind: leakProcess, Name: e.Name(), WorkloadID: id},
+			remove:         func(context.Context) error { return b.sweepEnv.kill(pid) },
+		})
+	}
+	return found, nil
+}
+
+// firecrackerWorkload returns the workload ID of a Firecracker process
+// started for a VM, given its NUL-separated command line.
+func firecrackerWorkload(cmdline []byte) (string, bool) {
+	args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	for i, arg := range args {
+		if arg == "--api-sock" && i+1 < len(args) {
+			return vmDirWorkload(filepath.Base(filepath.Dir(args[i+1])))
+		}
+	}
+	return "", false
+}
+
+// vmDirWorkload returns the workload ID of a VM temporary directory name.
+func vmDirWorkload(name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, vmDirPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, "-")
+	if !ok || !model.IsID(id) {
+		return "", false
+	}
+	return id, true
+}
+
+// findCNICache lists CNI attachments to the backend's networks. Removing one
+// runs CNI DEL with the cached configuration, which also releases its address.
+func (b *Backend) findCNICache() ([]orphan, error) {
+	if b.netMgr == nil {
+		return nil, nil
+	}
+	attachments, err := b.netMgr.cniConfig.GetCachedAttachments("")
+	if err != nil {
+		return nil, fmt.Errorf("list CNI cache: %w", err)
+	}
+
+	var found []orphan
+	for _, att := range attachments {
+		if att.Network != CNINetworkName && !strings.HasPrefix(att.Network, CNINetworkName+"-") {
+			continue
+		}
+		found = append(found, orphan{
+			LeakedResource: backend.LeakedResource{
+				Kind:       leakCNICache,
+				Name:       att.Network + "/" + att.ContainerID,
+				WorkloadID: att.ContainerID,
+			},
+			remove: func(ctx context.Context) error { return b.netMgr.releaseAttachment(ctx, att) },
+		})
+	}
+	return found, nil
+}
+
+// releaseAttachment runs CNI DEL for a cached attachment.
+func (nm *NetworkManager) releaseAttachment(ctx context.Context, att *libcni.NetworkAttachment) error {
+	confList, err := libcni.ConfListFromBytes(att.Config)
+	if err != nil {
+		return fmt.Errorf("parse cached conflist: %w", err)
+	}
+	rtConf := &libcni.RuntimeConf{
+		ContainerID: att.ContainerID,
+		NetNS:       att.NetNS,
+		IfName:      att.IfName,
+	}
+	if err := nm.cniConfig.DelNetworkList(ctx, confList, rtConf); err != nil {
+		return fmt.Errorf("CNI DEL: %w", err)
+	}
+	return nil
+}
+
+// findNetNS lists the VMs' network namespaces.
+func (b *Backend) findNetNS() ([]orphan, error) {
+	entries, err := os.ReadDir(b.sweepEnv.netnsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list network namespaces: %w", err)
+	}
+
+	var found []orphan
+	for _, e := range entries {
+		id, ok := strings.CutPrefix(e.Name(), NetNSPrefix)
+		if !ok || !model.IsID(id) {
+			continue
+		}
+		name := e.Name()
+		found = append(found, orphan{
+			LeakedResource: backend.LeakedResource{Kind: leakNetNS, Name: name, WorkloadID: id},
+			remove:         func(context.Context) error { return b.sweepEnv.deleteNetNS(name) },
+		})
+	}
+	return found, nil
+}
+
+// findTempDirs lists the VMs' temporary directories.
+func (b *Backend) findTempDirs() ([]orphan, error) {
+	entries, err := os.ReadDir(b.sweepEnv.tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("list temp dirs: %w", err)
+	}
+
+	var found []orphan
+	for _, e := range entries {
+		id, ok := vmDirWorkload(e.Name())
+		if !ok || !e.IsDir() {
+			continue
+		}
+		path := filepath.Join(b.sweepEnv.tempDir, e.Name())
+		found = append(found, orphan{
+			LeakedResource: backend.LeakedResource{Kind: leakTempDir, Name: path, WorkloadID: id},
+			remove:         func(context.Context) error { return os.RemoveAll(path) },
+		})
+	}
+	return found, nil
+}
+
+// findLinks lists leaked devices in the host namespace: group bridges whose
+// group no longer exists, and TAP devices attached to any of the backend's
+// bridges, since a VM's TAP device lives in its own namespace.
+func (b *Backend) findLinks() ([]orphan, error) {
+	if b.netMgr == nil {
+		return nil, nil
+	}
+	links, err := b.sweepEnv.links()
+	if err != nil {
+		return nil, fmt.Errorf("list network devices: %w", err)
+	}
+
+	// Read after listing, like the live VM set: a group's index is reserved
+	// before its bridge is created and freed after it is deleted.
+	nm := b.netMgr
+	nm.mu.Lock()
+	liveBridges := make(map[string]bool, len(nm.groupIndexes))
+	for index := range nm.groupIndexes {
+		liveBridges[GroupBridgePrefix+strconv.Itoa(index)] = true
+	}
+	nm.mu.Unlock()
+
+	bridges := make(map[int]bool)
+	for _, link := range links {
+		attrs := link.Attrs()
+		if attrs.Name == nm.bridgeName || strings.HasPrefix(attrs.Name, GroupBridgePrefix) {
+			bridges[attrs.Index] = true
+		}
+	}
+
+	var found []orphan
+	for _, link := range links {
+		attrs := link.Attrs()
+		name := attrs.Name
+		switch {
+		case link.Type() == "tuntap" && bridges[attrs.MasterIndex]:
+			found = append(found, orphan{
+				LeakedResource: backend.LeakedResource{Kind: leakTAP, Name: name},
+				remove:         func(context.Context) error { return b.sweepEnv.deleteLink(name) },
+			})
+		case link.Type() == "bridge" && isGroupBridge(name) && !liveBridges[name]:
+			found = append(found, orphan{
+				LeakedResource: backend.LeakedResource{Kind: leakBridge, Name: name},
+				remove:         func(context.Context) error { return b.sweepEnv.deleteLink(name) },
+			})
+		}
+	}
+	return found, nil
+}
+
+// isGroupBridge reports whether name is a group bridge name, vgrp<index>.
+func isGroupBridge(name string) bool {
+	index, ok := strings.CutPrefix(name, GroupBridgePrefix)
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(index)
+	return err == nil
+}
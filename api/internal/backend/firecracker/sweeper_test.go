@@ -0,0 +1,332 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/vishvananda/netlink"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// sweepFixture is a backend whose sweeper sees fake host resources.
+type sweepFixture struct {
+	b        *Backend
+	procDir  string
+	netnsDir string
+	tempDir  string
+	cacheDir string
+	links    []netlink.Link
+
+	killed    []int
+	deletedNS []string
+	deleted   []string
+}
+
+func newSweepFixture(t *testing.T) *sweepFixture {
+	t.Helper()
+	root := t.TempDir()
+	f := &sweepFixture{
+		procDir:  filepath.Join(root, "proc"),
+		netnsDir: filepath.Join(root, "netns"),
+		tempDir:  filepath.Join(root, "tmp"),
+		cacheDir: filepath.Join(root, "cni-cache"),
+	}
+	for _, dir := range []string{f.procDir, f.netnsDir, f.tempDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f.b = &Backend{
+		logger:    testLogger(),
+		activeVMs: make(map[string]*vmState),
+		inflight:  make(map[string]bool),
+		netMgr: &NetworkManager{
+			bridgeName:   DefaultBridgeName,
+			cniConfig:    libcni.NewCNIConfigWithCacheDir(nil, f.cacheDir, nil),
+			groupIndexes: make(map[int]bool),
+			logger:       testLogger(),
+		},
+		sweepEnv: sweepEnv{
+			netnsDir: f.netnsDir,
+			tempDir:  f.tempDir,
+			procDir:  f.procDir,
+			deleteNetNS: func(name string) error {
+				f.deletedNS = append(f.deletedNS, name)
+				return os.Remove(filepath.Join(f.netnsDir, name))
+			},
+			kill: func(pid int) error {
+				f.killed = append(f.killed, pid)
+				return nil
+			},
+			links: func() ([]netlink.Link, error) { return f.links, nil },
+			deleteLink: func(name string) error {
+				f.deleted = append(f.deleted, name)
+				return nil
+			},
+		},
+	}
+	return f
+}
+
+// addProcess fakes a process whose command line is args.
+func (f *sweepFixture) addProcess(t *testing.T, pid int, args ...string) {
+	t.Helper()
+	dir := filepath.Join(f.procDir, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var cmdline []byte
+	for _, arg := range args {
+		cmdline = append(append(cmdline, arg...), 0)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cmdline"), cmdline, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addVM fakes the netns, temp dir and Firecracker process of a VM.
+func (f *sweepFixture) addVM(t *testing.T, id string, pid int) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(f.netnsDir, NetNSPrefix+id), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vmDir := filepath.Join(f.tempDir, vmDirPrefix+id+"-123")
+	if err := os.MkdirAll(vmDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f.addProcess(t, pid, "/usr/bin/firecracker", "--api-sock", filepath.Join(vmDir, "firecracker.sock"))
+}
+
+// addCNICache fakes a CNI cache entry for id on network.
+func (f *sweepFixture) addCNICache(t *testing.T, network, id string) {
+	t.Helper()
+	dir := filepath.Join(f.cacheDir, "results")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := json.Marshal(map[string]any{
+		"kind":        "cniCacheV1",
+		"containerId": id,
+		"config":      []byte(`{"cniVersion":"1.0.0","name":"` + network + `","plugins":[]}`),
+		"ifName":      "eth0",
+		"networkName": network,
+		"netns":       filepath.Join(f.netnsDir, NetNSPrefix+id),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, network+"-"+id+"-eth0"), entry, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resourceNames returns "kind:workloadID" (or "kind:name" for devices) for
+// each resource in the report.
+func resourceNames(report backend.SweepReport) []string {
+	var names []string
+	for _, r := range report.Resources {
+		key := r.WorkloadID
+		if key == "" {
+			key = r.Name
+		}
+		names = append(names, r.Kind+":"+key)
+	}
+	return names
+}
+
+func TestSweepFindsOnlyDeadVMs(t *testing.T) {
+	f := newSweepFixture(t)
+	dead, live, starting := model.NewID(), model.NewID(), model.NewID()
+	f.addVM(t, dead, 100)
+	f.addVM(t, live, 101)
+	f.addVM(t, starting, 102)
+	f.addCNICache(t, CNINetworkName, dead)
+	f.addCNICache(t, CNINetworkName, live)
+	f.b.activeVMs[live] = &vmState{}
+	f.b.inflight[starting] = true
+
+	// Resources that are not the backend's.
+	f.addProcess(t, 200, "/usr/bin/firecracker", "--api-sock", "/srv/other/firecracker.sock")
+	f.addProcess(t, 201, "sleep", "infinity")
+	f.addCNICache(t, "other-net", dead)
+	os.WriteFile(filepath.Join(f.netnsDir, "cni-1234"), nil, 0o644)
+	os.MkdirAll(filepath.Join(f.tempDir, "vulcan-vm-notanid-1"), 0o755)
+
+	report, err := f.b.Sweep(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	want := []string{"process:" + dead, "cni_cache:" + dead, "netns:" + dead, "temp_dir:" + dead}
+	if got := resourceNames(report); !slices.Equal(got, want) {
+		t.Errorf("resources = %v, want %v", got, want)
+	}
+	if !report.DryRun || report.Backend != BackendName || len(report.Errors) != 0 {
+		t.Errorf("report = %+v, want a clean dry run of %s", report, BackendName)
+	}
+
+	// A dry run removes nothing.
+	for _, r := range report.Resources {
+		if r.Removed {
+			t.Errorf("%s %s removed in a dry run", r.Kind, r.Name)
+		}
+	}
+	if len(f.killed) != 0 || len(f.deletedNS) != 0 {
+		t.Errorf("dry run killed %v and deleted %v", f.killed, f.deletedNS)
+	}
+	if _, err := os.Stat(filepath.Join(f.tempDir, vmDirPrefix+dead+"-123")); err != nil {
+		t.Errorf("dry run removed the temp dir: %v", err)
+	}
+}
+
+func TestSweepRemovesLeakedResources(t *testing.T) {
+	f := newSweepFixture(t)
+	dead, live := model.NewID(), model.NewID()
+	f.addVM(t, dead, 100)
+	f.addVM(t, live, 101)
+	f.b.activeVMs[live] = &vmState{}
+
+	report, err := f.b.Sweep(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	for _, r := range report.Resources {
+		if !r.Removed || r.Error != "" {
+			t.Errorf("%s %s: removed=%v error=%q, want removed", r.Kind, r.Name, r.Removed, r.Error)
+		}
+	}
+
+	if !slices.Equal(f.killed, []int{100}) {
+		t.Errorf("killed = %v, want [100]", f.killed)
+	}
+	if !slices.Equal(f.deletedNS, []string{NetNSPrefix + dead}) {
+		t.Errorf("deleted namespaces = %v, want [%s]", f.deletedNS, NetNSPrefix+dead)
+	}
+	if _, err := os.Stat(filepath.Join(f.tempDir, vmDirPrefix+dead+"-123")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("leaked temp dir still present: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(f.tempDir, vmDirPrefix+live+"-123")); err != nil {
+		t.Errorf("live VM's temp dir removed: %v", err)
+	}
+
+	// Nothing is left for the next sweep.
+	f.killed = nil
+	os.RemoveAll(filepath.Join(f.procDir, "100"))
+	report, err = f.b.Sweep(context.Background(), false)
+	if err != nil {
+		t.Fatalf("second Sweep: %v", err)
+	}
+	if len(report.Resources) != 0 {
+		t.Errorf("second sweep found %v, want nothing", resourceNames(report))
+	}
+}
+
+func TestSweepReportsRemovalFailures(t *testing.T) {
+	f := newSweepFixture(t)
+	dead := model.NewID()
+	f.addVM(t, dead, 100)
+	f.b.sweepEnv.kill = func(int) error { return errors.New("operation not permitted") }
+
+	report, err := f.b.Sweep(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	for _, r := range report.Resources {
+		failed := r.Kind == leakProcess
+		if failed && (r.Removed || r.Error != "operation not permitted") {
+			t.Errorf("process: removed=%v error=%q, want the kill error", r.Removed, r.Error)
+		}
+		if !failed && !r.Removed {
+			t.Errorf("%s not removed after an unrelated failure", r.Kind)
+		}
+	}
+}
+
+func TestSweepLinks(t *testing.T) {
+	f := newSweepFixture(t)
+	f.b.netMgr.groupIndexes[0] = true
+	f.links = []netlink.Link{
+		&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: DefaultBridgeName, Index: 10}},
+		&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: GroupBridgePrefix + "0", Index: 11}},
+		&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: GroupBridgePrefix + "1", Index: 12}},
+		&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "docker0", Index: 13}},
+		&netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{Name: "tap0", Index: 20, MasterIndex: 10}},
+		&netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{Name: "tap1", Index: 21, MasterIndex: 12}},
+		&netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{Name: "tap2", Index: 22, MasterIndex: 13}},
+		&netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{Name: "tap3", Index: 23}},
+	}
+
+	report, err := f.b.Sweep(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	want := []string{"tap:tap0", "tap:tap1", "bridge:" + GroupBridgePrefix + "1"}
+	if got := resourceNames(report); !slices.Equal(got, want) {
+		t.Errorf("resources = %v, want %v", got, want)
+	}
+	if want := []string{"tap0", "tap1", GroupBridgePrefix + "1"}; !slices.Equal(f.deleted, want) {
+		t.Errorf("deleted = %v, want %v", f.deleted, want)
+	}
+}
+
+func TestSweepReportsUnreadableSources(t *testing.T) {
+	f := newSweepFixture(t)
+	dead := model.NewID()
+	f.addVM(t, dead, 100)
+	f.b.sweepEnv.procDir = filepath.Join(t.TempDir(), "missing")
+
+	report, err := f.b.Sweep(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if len(report.Errors) != 1 {
+		t.Errorf("errors = %v, want one for the process list", report.Errors)
+	}
+	want := []string{"netns:" + dead, "temp_dir:" + dead}
+	if got := resourceNames(report); !slices.Equal(got, want) {
+		t.Errorf("resources = %v, want %v", got, want)
+	}
+}
+
+func TestSweepStopsWhenCancelled(t *testing.T) {
+	f := newSweepFixture(t)
+	f.addVM(t, model.NewID(), 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := f.b.Sweep(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sweep = %v, want context.Canceled", err)
+	}
+	if len(f.killed) != 0 {
+		t.Errorf("cancelled sweep killed %v", f.killed)
+	}
+}
+
+func TestFirecrackerWorkload(t *testing.T) {
+	id := model.NewID()
+	tests := []struct {
+		cmdline string
+		want    string
+	}{
+		{"firecracker\x00--api-sock\x00/tmp/" + vmDirPrefix + id + "-42/firecracker.sock\x00", id},
+		{"firecracker\x00--api-sock\x00/tmp/" + vmDirPrefix + "x-42/firecracker.sock\x00", ""},
+		{"firecracker\x00--api-sock\x00/run/fc.sock\x00", ""},
+		{"firecracker\x00--api-sock\x00", ""},
+		{"bash\x00-c\x00" + vmDirPrefix + id + "-1\x00", ""},
+	}
+	for _, tt := range tests {
+		got, ok := firecrackerWorkload([]byte(tt.cmdline))
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("firecrackerWorkload(%q) = %q, %v, want %q", tt.cmdline, got, ok, tt.want)
+		}
+	}
+}
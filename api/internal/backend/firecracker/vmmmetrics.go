@@ -0,0 +1,240 @@
+package firecracker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	fcsdk "github.com/firecracker-microvm/firecracker-go-sdk"
+	models "github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// metricsFifoName is the name of the VMM metrics FIFO in a VM's socket
+	// directory.
+	metricsFifoName = "metrics.fifo"
+
+	// vmMetricsRetention is how long per-VM series are kept after the VM
+	// exits, so that a final scrape sees their last values.
+	vmMetricsRetention = 5 * time.Minute
+
+	// metricsDrainTimeout bounds the wait for the last metrics flush to be
+	// read once the VMM has exited.
+	metricsDrainTimeout = time.Second
+
+	// maxMetricsLine bounds a single metrics flush.
+	maxMetricsLine = 1 << 20
+)
+
+// Metric label values for VMM metrics.
+const (
+	exitIOIn       = "io_in"
+	exitIOOut      = "io_out"
+	exitMMIORead   = "mmio_read"
+	exitMMIOWrite  = "mmio_write"
+	netDirectionRx = "rx"
+	netDirectionTx = "tx"
+	deviceBlock    = "block"
+	deviceNet      = "net"
+)
+
+var (
+	vmVCPUExits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_vm_vcpu_exits_total",
+			Help: "vCPU exits handled by the VMM, by exit reason.",
+		},
+		[]string{"workload_id", "reason"},
+	)
+
+	vmBlockBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_vm_block_bytes_total",
+			Help: "Bytes transferred by the VM's block devices, by direction.",
+		},
+		[]string{"workload_id", "direction"},
+	)
+
+	vmNetBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_vm_net_bytes_total",
+			Help: "Bytes transferred by the VM's network interface, by direction.",
+		},
+		[]string{"workload_id", "direction"},
+	)
+
+	vmVsockErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_vm_vsock_errors_total",
+			Help: "Errors reported by the VM's vsock device.",
+		},
+		[]string{"workload_id"},
+	)
+
+	vmRateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vulcan_firecracker_vm_rate_limited_total",
+			Help: "Times a VM device was throttled by its rate limiter, by device.",
+		},
+		[]string{"workload_id", "device"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(vmVCPUExits)
+	prometheus.MustRegister(vmBlockBytes)
+	prometheus.MustRegister(vmNetBytes)
+	prometheus.MustRegister(vmVsockErrors)
+	prometheus.MustRegister(vmRateLimited)
+}
+
+// vmmMetrics is the part of a Firecracker metrics flush that is exported.
+// Counters in a flush are deltas since the previous flush.
+type vmmMetrics struct {
+	VCPU struct {
+		ExitIOIn      float64 `json:"exit_io_in"`
+		ExitIOOut     float64 `json:"exit_io_out"`
+		ExitMMIORead  float64 `json:"exit_mmio_read"`
+		ExitMMIOWrite float64 `json:"exit_mmio_write"`
+	} `json:"vcpu"`
+	Block struct {
+		ReadBytes  float64 `json:"read_bytes"`
+		WriteBytes float64 `json:"write_bytes"`
+		Throttled  float64 `json:"rate_limiter_throttled_events"`
+	} `json:"block"`
+	Net struct {
+		RxBytes     float64 `json:"rx_bytes_count"`
+		TxBytes     float64 `json:"tx_bytes_count"`
+		RxThrottled float64 `json:"rx_rate_limiter_throttled"`
+		TxThrottled float64 `json:"tx_rate_limiter_throttled"`
+	} `json:"net"`
+	// Vsock holds every vsock counter; those ending in _fails are errors.
+	Vsock map[string]json.RawMessage `json:"vsock"`
+}
+
+// vsockErrors sums the vsock failure counters of a flush.
+func (m *vmmMetrics) vsockErrors() float64 {
+	var total float64
+	for name, raw := range m.Vsock {
+		if !strings.HasSuffix(name, "_fails") {
+			continue
+		}
+		if v, err := strconv.ParseFloat(string(raw), 64); err == nil {
+			total += v
+		}
+	}
+	return total
+}
+
+// record adds a flush to the workload's series.
+func (m *vmmMetrics) record(workloadID string) {
+	vmVCPUExits.WithLabelValues(workloadID, exitIOIn).Add(m.VCPU.ExitIOIn)
+	vmVCPUExits.WithLabelValues(workloadID, exitIOOut).Add(m.VCPU.ExitIOOut)
+	vmVCPUExits.WithLabelValues(workloadID, exitMMIORead).Add(m.VCPU.ExitMMIORead)
+	vmVCPUExits.WithLabelValues(workloadID, exitMMIOWrite).Add(m.VCPU.ExitMMIOWrite)
+	vmBlockBytes.WithLabelValues(workloadID, ioDirectionRead).Add(m.Block.ReadBytes)
+	vmBlockBytes.WithLabelValues(workloadID, ioDirectionWrite).Add(m.Block.WriteBytes)
+	vmNetBytes.WithLabelValues(workloadID, netDirectionRx).Add(m.Net.RxBytes)
+	vmNetBytes.WithLabelValues(workloadID, netDirectionTx).Add(m.Net.TxBytes)
+	vmVsockErrors.WithLabelValues(workloadID).Add(m.vsockErrors())
+	vmRateLimited.WithLabelValues(workloadID, deviceBlock).Add(m.Block.Throttled)
+	vmRateLimited.WithLabelValues(workloadID, deviceNet).Add(m.Net.RxThrottled + m.Net.TxThrottled)
+}
+
+// deleteVMMetrics removes a workload's per-VM series.
+func deleteVMMetrics(workloadID string) {
+	labels := prometheus.Labels{"workload_id": workloadID}
+	vmVCPUExits.DeletePartialMatch(labels)
+	vmBlockBytes.DeletePartialMatch(labels)
+	vmNetBytes.DeletePartialMatch(labels)
+	vmVsockErrors.DeletePartialMatch(labels)
+	vmRateLimited.DeletePartialMatch(labels)
+}
+
+// vmmMetricsReader translates the flushes Firecracker writes to a VM's
+// metrics FIFO into the workload's Prometheus series.
+type vmmMetricsReader struct {
+	fifo       *os.File
+	socketPath string
+	workloadID string
+	logger     *slog.Logger
+	done       chan struct{}
+}
+
+// startVMMMetrics starts reading the metrics FIFO at path. Firecracker must
+// already have opened it for writing, which it does while the machine starts;
+// reading ends when the VMM exits.
+func startVMMMetrics(path, socketPath, workloadID string, logger *slog.Logger) (*vmmMetricsReader, error) {
+	// Non-blocking, so that the read end is polled and can be closed.
+	fifo, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	r := &vmmMetricsReader{
+		fifo:       fifo,
+		socketPath: socketPath,
+		workloadID: workloadID,
+		logger:     logger,
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		r.read(fifo)
+	}()
+	return r, nil
+}
+
+// read records each flush read from rd until it is exhausted.
+func (r *vmmMetricsReader) read(rd io.Reader) {
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64<<10), maxMetricsLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var m vmmMetrics
+		if err := json.Unmarshal(line, &m); err != nil {
+			r.logger.Debug("skipping malformed VMM metrics", "workload_id", r.workloadID, "error", err)
+			continue
+		}
+		m.record(r.workloadID)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		r.logger.Warn("read VMM metrics", "workload_id", r.workloadID, "error", err)
+	}
+}
+
+// Flush asks the VMM to write its current metrics. Firecracker otherwise
+// flushes only once a minute, which most workloads do not outlive.
+func (r *vmmMetricsReader) Flush(ctx context.Context) error {
+	fcLogger := logrus.New()
+	fcLogger.SetOutput(io.Discard)
+	client := fcsdk.NewClient(r.socketPath, logrus.NewEntry(fcLogger), false)
+	_, err := client.CreateSyncAction(ctx, &models.InstanceActionInfo{
+		ActionType: fcsdk.String(models.InstanceActionInfoActionTypeFlushMetrics),
+	})
+	return err
+}
+
+// Close waits up to timeout for the VMM's last flush to be read, stops
+// reading and schedules the removal of the workload's series.
+func (r *vmmMetricsReader) Close(timeout time.Duration) {
+	select {
+	case <-r.done:
+	case <-time.After(timeout):
+	}
+	r.fifo.Close()
+	<-r.done
+	time.AfterFunc(vmMetricsRetention, func() { deleteVMMetrics(r.workloadID) })
+}
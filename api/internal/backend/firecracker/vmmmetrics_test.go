@@ -0,0 +1,91 @@
+package firecracker
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sampleFlush is an abridged Firecracker metrics flush.
+const sampleFlush = `{"utc_timestamp_ms":1700000000000,` +
+	`"vcpu":{"exit_io_in":3,"exit_io_out":40,"exit_mmio_read":7,"exit_mmio_write":9,"failures":0},` +
+	`"block":{"read_bytes":4096,"write_bytes":8192,"read_count":1,"rate_limiter_throttled_events":2},` +
+	`"net":{"rx_bytes_count":1500,"tx_bytes_count":600,"rx_rate_limiter_throttled":1,"tx_rate_limiter_throttled":4},` +
+	`"vsock":{"activate_fails":0,"rx_read_fails":2,"tx_write_fails":1,"rx_bytes_count":99,"conns_added":3}}`
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("write counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestVMMMetricsRead(t *testing.T) {
+	const id = "vmm-metrics-read"
+	t.Cleanup(func() { deleteVMMetrics(id) })
+	r := &vmmMetricsReader{workloadID: id, logger: slog.New(slog.NewJSONHandler(io.Discard, nil))}
+
+	// Two flushes are deltas and add up; a malformed line is skipped.
+	r.read(strings.NewReader(sampleFlush + "\nnot json\n" + sampleFlush + "\n"))
+
+	tests := []struct {
+		name string
+		c    prometheus.Counter
+		want float64
+	}{
+		{"io_out exits", vmVCPUExits.WithLabelValues(id, exitIOOut), 80},
+		{"mmio_write exits", vmVCPUExits.WithLabelValues(id, exitMMIOWrite), 18},
+		{"block reads", vmBlockBytes.WithLabelValues(id, ioDirectionRead), 8192},
+		{"block writes", vmBlockBytes.WithLabelValues(id, ioDirectionWrite), 16384},
+		{"net rx", vmNetBytes.WithLabelValues(id, netDirectionRx), 3000},
+		{"net tx", vmNetBytes.WithLabelValues(id, netDirectionTx), 1200},
+		{"vsock errors", vmVsockErrors.WithLabelValues(id), 6},
+		{"block throttled", vmRateLimited.WithLabelValues(id, deviceBlock), 4},
+		{"net throttled", vmRateLimited.WithLabelValues(id, deviceNet), 10},
+	}
+	for _, tt := range tests {
+		if got := counterValue(t, tt.c); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteVMMetrics(t *testing.T) {
+	const id = "vmm-metrics-delete"
+	var m vmmMetrics
+	m.record(id)
+	m.record("vmm-metrics-other")
+	t.Cleanup(func() { deleteVMMetrics("vmm-metrics-other") })
+
+	deleteVMMetrics(id)
+	if n := testSeries(vmNetBytes, id); n != 0 {
+		t.Errorf("%d net series left after delete", n)
+	}
+	if n := testSeries(vmVsockErrors, "vmm-metrics-other"); n != 1 {
+		t.Errorf("other workload has %d vsock series, want 1", n)
+	}
+}
+
+// testSeries counts the series of vec labelled with workloadID.
+func testSeries(vec *prometheus.CounterVec, workloadID string) int {
+	ch := make(chan prometheus.Metric, 16)
+	vec.Collect(ch)
+	close(ch)
+	n := 0
+	for metric := range ch {
+		var m dto.Metric
+		metric.Write(&m)
+		for _, l := range m.GetLabel() {
+			if l.GetName() == "workload_id" && l.GetValue() == workloadID {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,50 @@
+package firecracker
+
+import (
+	"context"
+	"strconv"
+
+	fcsdk "github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+
+	"github.com/seantiz/vulcan/internal/backend"
+)
+
+// volumeDriveIDPrefix prefixes the drive identifiers of persistent volumes,
+// which are numbered in the order they were requested.
+const volumeDriveIDPrefix = "volume"
+
+// FormatVolume creates an empty ext4 filesystem filling the file at path,
+// for use as a persistent volume.
+func FormatVolume(ctx context.Context, mkfsBin, path string) error {
+	return runMkfs(ctx, mkfsBin, path, "")
+}
+
+// guestBlockDevice returns the guest device of the drive at index in the
+// VM's drive list. Drives appear as virtio block devices in the order they
+// are attached, starting with the rootfs at /dev/vda.
+func guestBlockDevice(index int) string {
+	return "/dev/vd" + string(rune('a'+index))
+}
+
+// attachVolumes appends a drive for each of the workload's volumes to drives
+// and returns the resulting list, with the mounts telling the guest where
+// each one appears and where to mount it.
+func attachVolumes(drives []models.Drive, volumes []backend.AttachedVolume, limits *models.RateLimiter) ([]models.Drive, []VolumeMount) {
+	var mounts []VolumeMount
+	for i, v := range volumes {
+		mounts = append(mounts, VolumeMount{
+			Device:    guestBlockDevice(len(drives)),
+			MountPath: v.MountPath,
+			ReadOnly:  v.ReadOnly,
+		})
+		drives = append(drives, models.Drive{
+			DriveID:      fcsdk.String(volumeDriveIDPrefix + strconv.Itoa(i)),
+			PathOnHost:   fcsdk.String(v.Path),
+			IsRootDevice: fcsdk.Bool(false),
+			IsReadOnly:   fcsdk.Bool(v.ReadOnly),
+			RateLimiter:  limits,
+		})
+	}
+	return drives, mounts
+}
@@ -0,0 +1,83 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	fcsdk "github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+
+	"github.com/seantiz/vulcan/internal/backend"
+)
+
+func TestGuestBlockDevice(t *testing.T) {
+	for index, want := range map[int]string{0: "/dev/vda", 1: "/dev/vdb", 3: "/dev/vdd"} {
+		if got := guestBlockDevice(index); got != want {
+			t.Errorf("guestBlockDevice(%d) = %q, want %q", index, got, want)
+		}
+	}
+}
+
+func TestAttachVolumes(t *testing.T) {
+	drives := []models.Drive{
+		{DriveID: fcsdk.String(rootfsDriveID)},
+		{DriveID: fcsdk.String(depsDriveID)},
+	}
+	limits := &models.RateLimiter{}
+	drives, mounts := attachVolumes(drives, []backend.AttachedVolume{
+		{Name: "data", Path: "/volumes/data.ext4", MountPath: "/data"},
+		{Name: "models", Path: "/volumes/models.ext4", MountPath: "/models", ReadOnly: true},
+	}, limits)
+
+	if len(drives) != 4 {
+		t.Fatalf("got %d drives, want 4", len(drives))
+	}
+	for i, want := range []struct {
+		id, path string
+		readOnly bool
+	}{
+		{"volume0", "/volumes/data.ext4", false},
+		{"volume1", "/volumes/models.ext4", true},
+	} {
+		d := drives[2+i]
+		if *d.DriveID != want.id || *d.PathOnHost != want.path || *d.IsReadOnly != want.readOnly || *d.IsRootDevice || d.RateLimiter != limits {
+			t.Errorf("drive %d = %+v, want %+v", 2+i, d, want)
+		}
+	}
+
+	want := []VolumeMount{
+		{Device: "/dev/vdc", MountPath: "/data"},
+		{Device: "/dev/vdd", MountPath: "/models", ReadOnly: true},
+	}
+	if len(mounts) != len(want) || mounts[0] != want[0] || mounts[1] != want[1] {
+		t.Errorf("mounts = %+v, want %+v", mounts, want)
+	}
+
+	if drives, mounts := attachVolumes(drives[:1], nil, nil); len(drives) != 1 || mounts != nil {
+		t.Errorf("attachVolumes(nil) = %d drives, %+v", len(drives), mounts)
+	}
+}
+
+func TestFormatVolume(t *testing.T) {
+	if _, err := exec.LookPath(DefaultMkfsBin); err != nil {
+		t.Skip("mkfs.ext4 not available")
+	}
+	path := filepath.Join(t.TempDir(), "volume.ext4")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, 16<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := FormatVolume(context.Background(), DefaultMkfsBin, path); err != nil {
+		t.Fatalf("FormatVolume: %v", err)
+	}
+	out, err := exec.Command("dumpe2fs", "-h", path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("dumpe2fs: %v: %s", err, out)
+	}
+}
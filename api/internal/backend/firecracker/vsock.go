@@ -3,13 +3,39 @@ package firecracker
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Handshake errors.
+var (
+	// ErrLegacyAgent is returned by Handshake when the guest agent predates the
+	// handshake. Such an agent treats the hello as a malformed workload request
+	// and closes the connection, so the caller must redial before sending work.
+	ErrLegacyAgent = errors.New("guest agent does not support handshake")
+
+	// ErrIncompatibleAgent is returned by Handshake when the guest agent speaks
+	// a protocol version outside the range supported by the host.
+	ErrIncompatibleAgent = errors.New("incompatible guest agent")
+
+	// ErrGuestUnresponsive is returned while reading a workload's messages when
+	// a guest that promised heartbeats has sent nothing for HeartbeatMisses
+	// intervals, which indicates a hung guest kernel or agent.
+	ErrGuestUnresponsive = errors.New("guest agent unresponsive")
+)
+
+// LegacyAgent describes a guest agent that predates the handshake.
+var LegacyAgent = GuestHello{
+	AgentVersion:    "unknown",
+	ProtocolVersion: LegacyProtocolVersion,
+}
+
 // Retry defaults for vsock connection establishment.
 const (
 	dialMaxRetries  = 5
@@ -17,10 +43,54 @@ const (
 )
 
 // GuestConn wraps a connection to the guest agent inside a Firecracker microVM.
-// Each GuestConn is used by a single goroutine.
+// Each GuestConn is read by a single goroutine; streamed input and control
+// messages (Cancel, Signal) are written from other goroutines, so frame
+// writes are serialised by writeMu.
 type GuestConn struct {
 	conn   net.Conn
 	reader io.Reader // buffered reader preserving any bytes read ahead during handshake
+
+	writeMu sync.Mutex
+
+	// heartbeatTimeout bounds each read once the guest has been asked for
+	// heartbeats; zero disables the bound.
+	heartbeatTimeout time.Duration
+
+	mu            sync.Mutex
+	deadline      time.Time // overall deadline for the exchange; zero means none
+	lastHeartbeat *Heartbeat
+}
+
+// StreamIO configures chunked I/O for RunWorkloadStream.
+type StreamIO struct {
+	// Input, when non-nil, is streamed to the workload's stdin as chunk
+	// frames instead of being sent inline in GuestRequest.Input.
+	Input io.Reader
+
+	// Stdout, when non-nil, receives the workload's stdout as it arrives in
+	// chunk frames. Stderr receives stderr; nil discards it.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// LogWriter receives each streamed log line in real time, along with
+	// the stream it was written to.
+	LogWriter func(stream, line string)
+
+	// Program, when non-nil, is the prebuilt program of GuestRequest.Build,
+	// streamed to the guest as chunk frames. BuiltProgram receives the
+	// program the guest compiled, and BuildLogWriter each line of the
+	// compiler's output.
+	Program        io.Reader
+	BuiltProgram   io.Writer
+	BuildLogWriter func(string)
+
+	// OnHealth receives each health message of a service.
+	OnHealth func(HealthReport)
+
+	// OnDepsInstalled is called when the guest has installed the layer of a
+	// DepsRequest with Commit. The guest runs no workload code until it
+	// returns, and a guest that reports the install twice is failed.
+	OnDepsInstalled func()
 }
 
 // DialGuest connects to the guest agent via Firecracker's vsock UDS bridge.
@@ -54,7 +124,7 @@ func DialGuest(ctx context.Context, udsPath string, port uint32) (*GuestConn, er
 
 		// Set overall deadline from context if present.
 		if deadline, ok := ctx.Deadline(); ok {
-			if err := gc.conn.SetDeadline(deadline); err != nil {
+			if err := gc.SetDeadline(deadline); err != nil {
 				gc.conn.Close()
 				return nil, fmt.Errorf("set deadline: %w", err)
 			}
@@ -66,6 +136,12 @@ func DialGuest(ctx context.Context, udsPath string, port uint32) (*GuestConn, er
 	return nil, fmt.Errorf("dial guest after %d attempts: %w", dialMaxRetries, lastErr)
 }
 
+// NewGuestConn wraps an already established connection to a guest agent,
+// such as one end of a net.Pipe in tests.
+func NewGuestConn(conn net.Conn) *GuestConn {
+	return &GuestConn{conn: conn, reader: conn}
+}
+
 // dialVsockUDS connects to Firecracker's UDS and sends the CONNECT handshake.
 // Firecracker bridges the UDS connection to the guest's vsock listener.
 // Protocol: send "CONNECT <port>\n", receive "OK <host_port>\n".
@@ -103,37 +179,259 @@ func dialVsockUDS(ctx context.Context, udsPath string, port uint32) (*GuestConn,
 	return &GuestConn{conn: conn, reader: reader}, nil
 }
 
+// Handshake exchanges hello messages with the guest agent and returns the
+// agent's advertised capabilities. It must be the first exchange on a new
+// connection. Returns ErrLegacyAgent if the agent predates the handshake and
+// ErrIncompatibleAgent if its protocol version is not supported.
+func (gc *GuestConn) Handshake() (GuestHello, error) {
+	hello := HostMessage{Type: MsgTypeHello, Hello: &HostHello{ProtocolVersion: ProtocolVersion}}
+	if err := gc.writeMessage(&hello); err != nil {
+		return GuestHello{}, fmt.Errorf("send hello: %w", err)
+	}
+
+	var msg GuestMessage
+	if err := ReadMessage(gc.reader, &msg); err != nil {
+		return GuestHello{}, fmt.Errorf("read hello: %w", err)
+	}
+
+	switch msg.Type {
+	case MsgTypeHello:
+		if msg.Hello == nil {
+			return GuestHello{}, fmt.Errorf("received hello message with nil payload")
+		}
+	case MsgTypeResult:
+		// A pre-handshake agent decoded the hello as an empty request and
+		// answered it with an error result.
+		return GuestHello{}, ErrLegacyAgent
+	default:
+		return GuestHello{}, fmt.Errorf("unexpected message type during handshake: %q", msg.Type)
+	}
+
+	info := *msg.Hello
+	if info.ProtocolVersion < MinProtocolVersion || info.ProtocolVersion > ProtocolVersion {
+		return info, fmt.Errorf("%w: agent %s speaks protocol v%d, host supports v%d to v%d; rebuild the rootfs with a matching vulcan-guest",
+			ErrIncompatibleAgent, info.AgentVersion, info.ProtocolVersion, MinProtocolVersion, ProtocolVersion)
+	}
+	return info, nil
+}
+
 // SendWorkload sends a GuestRequest to the guest agent using length-prefixed JSON framing.
+// When the request asks for heartbeats, subsequent reads fail with
+// ErrGuestUnresponsive if the guest falls silent for HeartbeatMisses intervals.
 func (gc *GuestConn) SendWorkload(req GuestRequest) error {
-	if err := WriteMessage(gc.conn, &req); err != nil {
+	if err := gc.writeMessage(&req); err != nil {
 		return fmt.Errorf("send workload: %w", err)
 	}
+	gc.heartbeatTimeout = time.Duration(req.HeartbeatMS) * time.Millisecond * HeartbeatMisses
+	return nil
+}
+
+// Cancel asks the guest agent to terminate the running workload: its process
+// group receives SIGTERM and, after grace, SIGKILL. The agent still replies
+// with a result, so the caller keeps reading messages as usual. Requires the
+// FeatureControl feature.
+func (gc *GuestConn) Cancel(grace time.Duration) error {
+	if err := gc.writeMessage(&HostMessage{Type: MsgTypeCancel, GraceMS: int(grace.Milliseconds())}); err != nil {
+		return fmt.Errorf("send cancel: %w", err)
+	}
 	return nil
 }
 
+// Signal asks the guest agent to deliver the named signal (e.g. "SIGUSR1")
+// to the running workload's process group. Requires the FeatureControl feature.
+func (gc *GuestConn) Signal(name string) error {
+	if err := gc.writeMessage(&HostMessage{Type: MsgTypeSignal, Signal: name}); err != nil {
+		return fmt.Errorf("send signal: %w", err)
+	}
+	return nil
+}
+
+// SetDeadline sets the overall deadline for the exchange with the guest,
+// replacing any deadline derived from the dial context. It may be called
+// while another goroutine is reading, e.g. to allow a cancelled workload
+// time to report its result.
+func (gc *GuestConn) SetDeadline(t time.Time) error {
+	gc.mu.Lock()
+	gc.deadline = t
+	gc.mu.Unlock()
+	return gc.conn.SetDeadline(t)
+}
+
+// LastHeartbeat returns the most recent heartbeat received from the guest.
+func (gc *GuestConn) LastHeartbeat() (Heartbeat, bool) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.lastHeartbeat == nil {
+		return Heartbeat{}, false
+	}
+	return *gc.lastHeartbeat, true
+}
+
 // RunWorkload sends a workload request and reads back streaming log lines and the final result.
 // Each log line is passed to logWriter in real time. Returns the final GuestResponse.
-func (gc *GuestConn) RunWorkload(req GuestRequest, logWriter func(string)) (GuestResponse, error) {
+func (gc *GuestConn) RunWorkload(req GuestRequest, logWriter func(stream, line string)) (GuestResponse, error) {
 	if err := gc.SendWorkload(req); err != nil {
 		return GuestResponse{}, err
 	}
-	return gc.readMessages(logWriter)
+	return gc.readMessages(StreamIO{LogWriter: logWriter}, nil, nil)
+}
+
+// RunWorkloadStream is like RunWorkload but moves input and output over
+// chunk frames as configured by sio, so neither side has to hold them in a
+// single message. Guests that do not support streamed output reply inline in
+// GuestResponse.Output, which callers should fall back to.
+func (gc *GuestConn) RunWorkloadStream(req GuestRequest, sio StreamIO) (GuestResponse, error) {
+	req.StreamInput = sio.Input != nil
+	req.StreamOutput = sio.Stdout != nil
+	if req.StreamInput {
+		req.Input = nil
+	}
+
+	outputs := make(map[string]io.Writer)
+	if req.StreamOutput {
+		stderr := sio.Stderr
+		if stderr == nil {
+			stderr = io.Discard
+		}
+		outputs[StreamStdout] = sio.Stdout
+		outputs[StreamStderr] = stderr
+	}
+	if sio.BuiltProgram != nil {
+		outputs[StreamProgram] = sio.BuiltProgram
+	}
+
+	if err := gc.SendWorkload(req); err != nil {
+		return GuestResponse{}, err
+	}
+
+	// done aborts the input streamers once the guest has replied (it may exit
+	// without consuming all of its stdin).
+	done := make(chan struct{})
+	inputs := make(map[string]*ChunkWriter)
+	inputErrs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	streamInput := func(stream string, r io.Reader) {
+		w := NewChunkWriter(func(msgType string, data []byte) error {
+			return gc.writeMessage(&HostMessage{Type: msgType, Stream: stream, Data: data})
+		}, done)
+		inputs[stream] = w
+		wg.Go(func() {
+			_, err := io.Copy(w, r)
+			// Always terminate the stream so the guest observes EOF.
+			if closeErr := w.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+			mu.Lock()
+			inputErrs[stream] = err
+			mu.Unlock()
+		})
+	}
+	if sio.Program != nil {
+		streamInput(StreamProgram, sio.Program)
+	}
+	if sio.Input != nil {
+		streamInput(StreamInput, sio.Input)
+	}
+
+	resp, err := gc.readMessages(sio, inputs, outputs)
+	close(done)
+	wg.Wait()
+
+	if err != nil {
+		return GuestResponse{}, err
+	}
+	for stream, err := range inputErrs {
+		if err != nil && !errors.Is(err, ErrStreamAborted) {
+			return GuestResponse{}, fmt.Errorf("stream %s: %w", stream, err)
+		}
+	}
+	return resp, nil
+}
+
+// writeMessage writes a single frame to the guest, serialised with other writers.
+func (gc *GuestConn) writeMessage(v any) error {
+	gc.writeMu.Lock()
+	defer gc.writeMu.Unlock()
+	return WriteMessage(gc.conn, v)
 }
 
 // readMessages reads GuestMessage frames from the connection in a loop.
-// Log lines are delivered to logWriter; the final result message terminates the loop.
-func (gc *GuestConn) readMessages(logWriter func(string)) (GuestResponse, error) {
+// Log lines are delivered to the log writers of sio, output chunks to the
+// matching writer in outputs, and acknowledgements to the matching input
+// stream in inputs; the final result message terminates the loop.
+func (gc *GuestConn) readMessages(sio StreamIO, inputs map[string]*ChunkWriter, outputs map[string]io.Writer) (GuestResponse, error) {
+	depsInstalled := false
 	for {
+		heartbeatBound, err := gc.armReadDeadline()
+		if err != nil {
+			return GuestResponse{}, fmt.Errorf("set read deadline: %w", err)
+		}
+
 		var msg GuestMessage
 		if err := ReadMessage(gc.reader, &msg); err != nil {
+			if heartbeatBound && errors.Is(err, os.ErrDeadlineExceeded) {
+				return GuestResponse{}, gc.unresponsiveError()
+			}
 			return GuestResponse{}, fmt.Errorf("read guest message: %w", err)
 		}
 
 		switch msg.Type {
+		case MsgTypeHeartbeat:
+			if msg.Heartbeat == nil {
+				return GuestResponse{}, fmt.Errorf("received heartbeat message with nil payload")
+			}
+			gc.mu.Lock()
+			gc.lastHeartbeat = msg.Heartbeat
+			gc.mu.Unlock()
+		case MsgTypeHealth:
+			if msg.Health == nil {
+				return GuestResponse{}, fmt.Errorf("received health message with nil payload")
+			}
+			if sio.OnHealth != nil {
+				sio.OnHealth(*msg.Health)
+			}
+		case MsgTypeDepsInstalled:
+			// Only the first report precedes the workload's code.
+			if depsInstalled {
+				return GuestResponse{}, fmt.Errorf("received a second deps installed message")
+			}
+			depsInstalled = true
+			if sio.OnDepsInstalled != nil {
+				sio.OnDepsInstalled()
+			}
+			if err := gc.writeMessage(&HostMessage{Type: MsgTypeDepsCommitted}); err != nil {
+				return GuestResponse{}, fmt.Errorf("send deps committed: %w", err)
+			}
 		case MsgTypeLog:
-			if logWriter != nil {
-				logWriter(msg.Line)
+			if sio.LogWriter != nil {
+				sio.LogWriter(msg.Stream, msg.Line)
+			}
+		case MsgTypeBuildLog:
+			if sio.BuildLogWriter != nil {
+				sio.BuildLogWriter(msg.Line)
+			}
+		case MsgTypeChunk:
+			w, ok := outputs[msg.Stream]
+			if !ok {
+				return GuestResponse{}, fmt.Errorf("unexpected chunk for stream %q", msg.Stream)
+			}
+			if _, err := w.Write(msg.Data); err != nil {
+				return GuestResponse{}, fmt.Errorf("write %s chunk: %w", msg.Stream, err)
+			}
+			if err := gc.writeMessage(&HostMessage{Type: MsgTypeChunkAck, Stream: msg.Stream, Count: 1}); err != nil {
+				return GuestResponse{}, fmt.Errorf("ack %s chunk: %w", msg.Stream, err)
 			}
+		case MsgTypeChunkEnd:
+			if _, ok := outputs[msg.Stream]; !ok {
+				return GuestResponse{}, fmt.Errorf("unexpected chunk end for stream %q", msg.Stream)
+			}
+		case MsgTypeChunkAck:
+			input, ok := inputs[msg.Stream]
+			if !ok {
+				return GuestResponse{}, fmt.Errorf("unexpected chunk ack for stream %q", msg.Stream)
+			}
+			input.Ack(msg.Count)
 		case MsgTypeResult:
 			if msg.Response == nil {
 				return GuestResponse{}, fmt.Errorf("received result message with nil response")
@@ -145,6 +443,36 @@ func (gc *GuestConn) readMessages(logWriter func(string)) (GuestResponse, error)
 	}
 }
 
+// armReadDeadline bounds the next read by the heartbeat timeout, if enabled
+// and earlier than the overall deadline. It reports whether the heartbeat
+// timeout is the binding bound.
+func (gc *GuestConn) armReadDeadline() (bool, error) {
+	if gc.heartbeatTimeout <= 0 {
+		return false, nil
+	}
+
+	gc.mu.Lock()
+	deadline := gc.deadline
+	gc.mu.Unlock()
+
+	readDeadline := time.Now().Add(gc.heartbeatTimeout)
+	if !deadline.IsZero() && deadline.Before(readDeadline) {
+		return false, gc.conn.SetReadDeadline(deadline)
+	}
+	return true, gc.conn.SetReadDeadline(readDeadline)
+}
+
+// unresponsiveError describes a missed-heartbeat timeout, including the
+// last process state the guest reported.
+func (gc *GuestConn) unresponsiveError() error {
+	hb, ok := gc.LastHeartbeat()
+	if !ok {
+		return fmt.Errorf("%w: no message for %s", ErrGuestUnresponsive, gc.heartbeatTimeout)
+	}
+	return fmt.Errorf("%w: no message for %s (last heartbeat: process %s, pid %d, proc state %q)",
+		ErrGuestUnresponsive, gc.heartbeatTimeout, hb.State, hb.PID, hb.ProcState)
+}
+
 // Close closes the underlying connection.
 func (gc *GuestConn) Close() error {
 	return gc.conn.Close()
@@ -1,8 +1,11 @@
 package firecracker
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"net"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -66,7 +69,7 @@ func TestGuestConnLogStreaming(t *testing.T) {
 		ReadMessage(server, &gotReq)
 
 		for _, line := range logLines {
-			msg := GuestMessage{Type: MsgTypeLog, Line: line}
+			msg := GuestMessage{Type: MsgTypeLog, Stream: StreamStderr, Line: line}
 			WriteMessage(server, &msg)
 		}
 
@@ -80,9 +83,12 @@ func TestGuestConnLogStreaming(t *testing.T) {
 
 	var mu sync.Mutex
 	var receivedLogs []string
-	logWriter := func(line string) {
+	logWriter := func(stream, line string) {
 		mu.Lock()
 		receivedLogs = append(receivedLogs, line)
+		if stream != StreamStderr {
+			t.Errorf("log line %q has stream %q, want %q", line, stream, StreamStderr)
+		}
 		mu.Unlock()
 	}
 
@@ -107,6 +113,114 @@ func TestGuestConnLogStreaming(t *testing.T) {
 	}
 }
 
+func TestGuestConnHealthMessages(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	reports := []HealthReport{
+		{Status: HealthStarting},
+		{Status: HealthUnhealthy, Detail: "exited with code 1"},
+		{Status: HealthStarting, Restarts: 1},
+	}
+
+	// Mock guest: report the service's health, then its result once stopped.
+	go func() {
+		var gotReq GuestRequest
+		ReadMessage(server, &gotReq)
+		for _, r := range reports {
+			WriteMessage(server, &GuestMessage{Type: MsgTypeHealth, Health: &r})
+		}
+		WriteMessage(server, &GuestMessage{Type: MsgTypeResult, Response: &GuestResponse{ExitCode: 143}})
+		server.Close()
+	}()
+
+	var got []HealthReport
+	req := GuestRequest{Runtime: "node", Service: &ServiceRequest{Restart: "always"}}
+	resp, err := gc.RunWorkloadStream(req, StreamIO{OnHealth: func(r HealthReport) { got = append(got, r) }})
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+	if resp.ExitCode != 143 {
+		t.Errorf("ExitCode = %d, want 143", resp.ExitCode)
+	}
+	if !slices.Equal(got, reports) {
+		t.Errorf("health reports = %+v, want %+v", got, reports)
+	}
+}
+
+func TestGuestConnDepsCommit(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	// Mock guest: report the install and wait for the host before running.
+	committed := make(chan HostMessage, 1)
+	go func() {
+		var gotReq GuestRequest
+		ReadMessage(server, &gotReq)
+		WriteMessage(server, &GuestMessage{Type: MsgTypeDepsInstalled})
+		var msg HostMessage
+		ReadMessage(server, &msg)
+		committed <- msg
+		WriteMessage(server, &GuestMessage{Type: MsgTypeResult, Response: &GuestResponse{ExitCode: 0}})
+		server.Close()
+	}()
+
+	calls := 0
+	req := GuestRequest{Runtime: "python", Deps: &DepsRequest{Device: "/dev/vdb", Install: true, Commit: true}}
+	if _, err := gc.RunWorkloadStream(req, StreamIO{OnDepsInstalled: func() { calls++ }}); err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("OnDepsInstalled called %d times, want 1", calls)
+	}
+	if msg := <-committed; msg.Type != MsgTypeDepsCommitted {
+		t.Errorf("host replied %q, want %q", msg.Type, MsgTypeDepsCommitted)
+	}
+}
+
+func TestGuestConnRepeatedDepsInstalled(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	// A second report could come from workload code and must not be trusted.
+	go func() {
+		var gotReq GuestRequest
+		ReadMessage(server, &gotReq)
+		var msg HostMessage
+		for range 2 {
+			WriteMessage(server, &GuestMessage{Type: MsgTypeDepsInstalled})
+			ReadMessage(server, &msg)
+		}
+		server.Close()
+	}()
+
+	calls := 0
+	_, err := gc.RunWorkloadStream(GuestRequest{Runtime: "python"}, StreamIO{OnDepsInstalled: func() { calls++ }})
+	if err == nil || !strings.Contains(err.Error(), "second deps installed") {
+		t.Errorf("RunWorkloadStream error = %v, want the repeated report rejected", err)
+	}
+	if calls != 1 {
+		t.Errorf("OnDepsInstalled called %d times, want 1", calls)
+	}
+}
+
+func TestGuestConnNilHealth(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	go func() {
+		var gotReq GuestRequest
+		ReadMessage(server, &gotReq)
+		WriteMessage(server, &GuestMessage{Type: MsgTypeHealth})
+		server.Close()
+	}()
+
+	_, err := gc.RunWorkload(GuestRequest{Runtime: "node"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "nil payload") {
+		t.Errorf("error = %v, want nil payload error", err)
+	}
+}
+
 func TestGuestConnConnectionReset(t *testing.T) {
 	server, client := net.Pipe()
 	gc := &GuestConn{conn: client, reader: client}
@@ -222,3 +336,266 @@ func TestDialGuestRetries(t *testing.T) {
 	gc.Close()
 	wg.Wait()
 }
+
+func TestGuestConnRunWorkloadStream(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	input := bytes.Repeat([]byte("in"), 3*ChunkSize)
+	stdoutData := bytes.Repeat([]byte("out"), 2*ChunkSize)
+
+	// Mock guest: consume streamed input with acks, then stream stdout and
+	// stderr chunks, waiting for the host's acks after each one.
+	go func() {
+		defer server.Close()
+
+		var gotReq GuestRequest
+		if err := ReadMessage(server, &gotReq); err != nil {
+			t.Errorf("mock read request: %v", err)
+			return
+		}
+		if !gotReq.StreamInput || !gotReq.StreamOutput {
+			t.Errorf("StreamInput = %v, StreamOutput = %v, want both true", gotReq.StreamInput, gotReq.StreamOutput)
+		}
+		if len(gotReq.Input) != 0 {
+			t.Errorf("inline Input = %d bytes, want none when streamed", len(gotReq.Input))
+		}
+
+		var received []byte
+		for {
+			var msg HostMessage
+			if err := ReadMessage(server, &msg); err != nil {
+				t.Errorf("mock read input: %v", err)
+				return
+			}
+			if msg.Type == MsgTypeChunkEnd {
+				break
+			}
+			received = append(received, msg.Data...)
+			WriteMessage(server, &GuestMessage{Type: MsgTypeChunkAck, Stream: StreamInput, Count: 1})
+		}
+		if !bytes.Equal(received, input) {
+			t.Errorf("guest received %d input bytes, want %d", len(received), len(input))
+		}
+
+		send := func(stream string, data []byte) {
+			for len(data) > 0 {
+				n := min(len(data), ChunkSize)
+				WriteMessage(server, &GuestMessage{Type: MsgTypeChunk, Stream: stream, Data: data[:n]})
+				var ack HostMessage
+				if err := ReadMessage(server, &ack); err != nil || ack.Type != MsgTypeChunkAck || ack.Stream != stream {
+					t.Errorf("expected ack for %s, got %+v (err %v)", stream, ack, err)
+					return
+				}
+				data = data[n:]
+			}
+			WriteMessage(server, &GuestMessage{Type: MsgTypeChunkEnd, Stream: stream})
+		}
+		send(StreamStdout, stdoutData)
+		send(StreamStderr, []byte("warn\n"))
+
+		WriteMessage(server, &GuestMessage{Type: MsgTypeResult, Response: &GuestResponse{ExitCode: 0}})
+	}()
+
+	var stdout, stderr bytes.Buffer
+	resp, err := gc.RunWorkloadStream(GuestRequest{Runtime: "python"}, StreamIO{
+		Input:  bytes.NewReader(input),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+	if resp.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0", resp.ExitCode)
+	}
+	if !bytes.Equal(stdout.Bytes(), stdoutData) {
+		t.Errorf("stdout = %d bytes, want %d", stdout.Len(), len(stdoutData))
+	}
+	if stderr.String() != "warn\n" {
+		t.Errorf("stderr = %q, want %q", stderr.String(), "warn\n")
+	}
+}
+
+func TestGuestConnRunWorkloadStreamUnexpectedChunk(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	// Mock guest: send an output chunk the host did not ask for.
+	go func() {
+		var gotReq GuestRequest
+		ReadMessage(server, &gotReq)
+		WriteMessage(server, &GuestMessage{Type: MsgTypeChunk, Stream: StreamStdout, Data: []byte("x")})
+		server.Close()
+	}()
+
+	_, err := gc.RunWorkload(GuestRequest{Runtime: "node"}, nil)
+	if err == nil {
+		t.Fatal("expected error for unexpected chunk")
+	}
+	if !strings.Contains(err.Error(), "unexpected chunk") {
+		t.Errorf("error = %q, want to contain 'unexpected chunk'", err.Error())
+	}
+}
+
+func TestGuestConnHandshake(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	want := GuestHello{
+		AgentVersion:    "v1.2.3",
+		ProtocolVersion: ProtocolVersion,
+		Runtimes:        []string{"python"},
+		Features:        []string{FeatureChunkedIO},
+		Kernel:          "5.10.0",
+	}
+
+	go func() {
+		var msg HostMessage
+		if err := ReadMessage(server, &msg); err != nil {
+			t.Errorf("mock read hello: %v", err)
+			return
+		}
+		if msg.Type != MsgTypeHello || msg.Hello == nil || msg.Hello.ProtocolVersion != ProtocolVersion {
+			t.Errorf("host hello = %+v, want protocol v%d", msg, ProtocolVersion)
+		}
+		WriteMessage(server, &GuestMessage{Type: MsgTypeHello, Hello: &want})
+		server.Close()
+	}()
+
+	got, err := gc.Handshake()
+	if err != nil {
+		t.Fatalf("Handshake: %v", err)
+	}
+	if got.AgentVersion != want.AgentVersion || got.Kernel != want.Kernel {
+		t.Errorf("hello = %+v, want %+v", got, want)
+	}
+	if !got.HasFeature(FeatureChunkedIO) {
+		t.Error("HasFeature(chunked_io) = false, want true")
+	}
+	if !got.HasRuntime("python") || got.HasRuntime("go") {
+		t.Errorf("HasRuntime mismatch for runtimes %v", got.Runtimes)
+	}
+}
+
+func TestGuestConnHandshakeLegacyAgent(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	// Mock legacy guest: decode the hello as a request and reply with an error result.
+	go func() {
+		var req GuestRequest
+		ReadMessage(server, &req)
+		resp := GuestResponse{ExitCode: 1, Error: `unsupported runtime: ""`}
+		WriteMessage(server, &GuestMessage{Type: MsgTypeResult, Response: &resp})
+		server.Close()
+	}()
+
+	_, err := gc.Handshake()
+	if !errors.Is(err, ErrLegacyAgent) {
+		t.Fatalf("Handshake error = %v, want ErrLegacyAgent", err)
+	}
+}
+
+func TestGuestConnHandshakeIncompatible(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	go func() {
+		var msg HostMessage
+		ReadMessage(server, &msg)
+		hello := GuestHello{AgentVersion: "v9.0.0", ProtocolVersion: ProtocolVersion + 1}
+		WriteMessage(server, &GuestMessage{Type: MsgTypeHello, Hello: &hello})
+		server.Close()
+	}()
+
+	info, err := gc.Handshake()
+	if !errors.Is(err, ErrIncompatibleAgent) {
+		t.Fatalf("Handshake error = %v, want ErrIncompatibleAgent", err)
+	}
+	if !strings.Contains(err.Error(), "v9.0.0") {
+		t.Errorf("error = %q, want it to name the agent version", err.Error())
+	}
+	if info.AgentVersion != "v9.0.0" {
+		t.Errorf("AgentVersion = %q, want v9.0.0", info.AgentVersion)
+	}
+}
+
+func TestGuestConnHeartbeatTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	gc := &GuestConn{conn: client, reader: client}
+
+	// Mock guest: send one heartbeat, then hang.
+	go func() {
+		var req GuestRequest
+		ReadMessage(server, &req)
+		hb := Heartbeat{State: ProcessRunning, PID: 42, ProcState: "D"}
+		WriteMessage(server, &GuestMessage{Type: MsgTypeHeartbeat, Heartbeat: &hb})
+	}()
+
+	start := time.Now()
+	_, err := gc.RunWorkload(GuestRequest{Runtime: "python", HeartbeatMS: 20}, nil)
+	if !errors.Is(err, ErrGuestUnresponsive) {
+		t.Fatalf("RunWorkload error = %v, want ErrGuestUnresponsive", err)
+	}
+	if !strings.Contains(err.Error(), `proc state "D"`) {
+		t.Errorf("error = %q, want last heartbeat details", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("detected hang after %s, want about %s", elapsed, 20*time.Millisecond*HeartbeatMisses)
+	}
+	if hb, ok := gc.LastHeartbeat(); !ok || hb.PID != 42 {
+		t.Errorf("LastHeartbeat = %+v, %v; want pid 42", hb, ok)
+	}
+}
+
+func TestGuestConnCancelAndSignal(t *testing.T) {
+	server, client := net.Pipe()
+	gc := &GuestConn{conn: client, reader: client}
+
+	// Mock guest: after the request, expect a signal and a cancel, then reply.
+	go func() {
+		defer server.Close()
+		var req GuestRequest
+		ReadMessage(server, &req)
+
+		var sig, cancel HostMessage
+		if err := ReadMessage(server, &sig); err != nil {
+			t.Errorf("read signal: %v", err)
+			return
+		}
+		if sig.Type != MsgTypeSignal || sig.Signal != "SIGUSR1" {
+			t.Errorf("signal message = %+v", sig)
+		}
+		if err := ReadMessage(server, &cancel); err != nil {
+			t.Errorf("read cancel: %v", err)
+			return
+		}
+		if cancel.Type != MsgTypeCancel || cancel.GraceMS != 1500 {
+			t.Errorf("cancel message = %+v", cancel)
+		}
+		WriteMessage(server, &GuestMessage{
+			Type:     MsgTypeResult,
+			Response: &GuestResponse{ExitCode: 143, Error: "cancelled by host"},
+		})
+	}()
+
+	if err := gc.SendWorkload(GuestRequest{Runtime: "python"}); err != nil {
+		t.Fatalf("SendWorkload: %v", err)
+	}
+	if err := gc.Signal("SIGUSR1"); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	if err := gc.Cancel(1500 * time.Millisecond); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	resp, err := gc.readMessages(StreamIO{}, nil, nil)
+	if err != nil {
+		t.Fatalf("readMessages: %v", err)
+	}
+	if resp.Error != "cancelled by host" {
+		t.Errorf("Error = %q, want cancelled by host", resp.Error)
+	}
+}
@@ -2,6 +2,8 @@ package backend
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"sync"
 
@@ -9,6 +11,7 @@ import (
 )
 
 // autoRouting maps runtimes to their default isolation mode for auto-resolution.
+// Other runtimes go to the backend that supports them.
 var autoRouting = map[string]string{
 	model.RuntimeNode:   model.IsolationIsolate,
 	model.RuntimePython: model.IsolationMicroVM,
@@ -45,21 +48,25 @@ func (r *Registry) Register(name string, b Backend) {
 }
 
 // Resolve returns the backend to use for the given isolation and runtime.
-// If isolation is "auto", it uses the autoRouting table to pick the default.
-// Returns an error if the resolved backend is not registered.
+// If isolation is "auto", it uses the autoRouting table to pick the default,
+// or else the backend that lists runtime in its SupportedRuntimes, such as
+// the microVM backend for a runtime a rootfs manifest declares. Returns an
+// error if the resolved backend is not registered.
 func (r *Registry) Resolve(isolation, runtime string) (Backend, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	target := isolation
 	if target == model.IsolationAuto {
 		resolved, ok := autoRouting[runtime]
 		if !ok {
-			return nil, fmt.Errorf("no auto-routing rule for runtime %q", runtime)
+			if resolved, ok = r.provider(runtime); !ok {
+				return nil, fmt.Errorf("no auto-routing rule for runtime %q", runtime)
+			}
 		}
 		target = resolved
 	}
 
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
 	b, ok := r.backends[target]
 	if !ok {
 		return nil, fmt.Errorf("backend %q is not registered", target)
@@ -67,6 +74,26 @@ func (r *Registry) Resolve(isolation, runtime string) (Backend, error) {
 	return b, nil
 }
 
+// Provides reports whether a registered backend lists runtime in its
+// SupportedRuntimes.
+func (r *Registry) Provides(runtime string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.provider(runtime)
+	return ok
+}
+
+// provider returns the name of the first registered backend, by name, that
+// lists runtime in its SupportedRuntimes. The caller must hold r.mu.
+func (r *Registry) provider(runtime string) (string, bool) {
+	for _, name := range slices.Sorted(maps.Keys(r.backends)) {
+		if slices.Contains(r.backends[name].Capabilities().SupportedRuntimes, runtime) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // List returns information about all registered backends, sorted by name
 // for a stable API response.
 func (r *Registry) List() []BackendInfo {
@@ -85,3 +112,18 @@ func (r *Registry) List() []BackendInfo {
 	})
 	return infos
 }
+
+// Sweepers returns the registered backends that implement Sweeper, keyed by
+// registration name.
+func (r *Registry) Sweepers() map[string]Sweeper {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sweepers := make(map[string]Sweeper)
+	for name, b := range r.backends {
+		if s, ok := b.(Sweeper); ok {
+			sweepers[name] = s
+		}
+	}
+	return sweepers
+}
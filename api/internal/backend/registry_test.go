@@ -117,3 +117,44 @@ func TestRegistryResolveAutoTargetNotRegistered(t *testing.T) {
 		t.Error("expected error when auto-resolved backend not registered, got nil")
 	}
 }
+
+func TestRegistryResolveAutoSupportedRuntime(t *testing.T) {
+	reg := backend.NewRegistry()
+	reg.Register(model.IsolationIsolate, &stubBackend{name: "isolate", runtimes: []string{model.RuntimeNode}, isolation: model.IsolationIsolate})
+	reg.Register(model.IsolationMicroVM, &stubBackend{name: "microvm", runtimes: []string{model.RuntimePython, "ruby"}, isolation: model.IsolationMicroVM})
+
+	// A runtime without a routing rule goes to the backend supporting it.
+	b, err := reg.Resolve(model.IsolationAuto, "ruby")
+	if err != nil {
+		t.Fatalf("Resolve(auto, ruby): %v", err)
+	}
+	if b.Capabilities().Name != "microvm" {
+		t.Errorf("Resolve(auto, ruby) = %q, want microvm", b.Capabilities().Name)
+	}
+	if !reg.Provides("ruby") || reg.Provides("perl") {
+		t.Errorf("Provides(ruby), Provides(perl) = %v, %v, want true, false", reg.Provides("ruby"), reg.Provides("perl"))
+	}
+	if _, err := reg.Resolve(model.IsolationAuto, "perl"); err == nil {
+		t.Error("expected error for a runtime no backend supports, got nil")
+	}
+}
+
+// sweepingBackend is a stubBackend that also implements Sweeper.
+type sweepingBackend struct {
+	stubBackend
+}
+
+func (s *sweepingBackend) Sweep(_ context.Context, dryRun bool) (backend.SweepReport, error) {
+	return backend.SweepReport{Backend: s.name, DryRun: dryRun}, nil
+}
+
+func TestRegistrySweepers(t *testing.T) {
+	reg := backend.NewRegistry()
+	reg.Register(model.IsolationIsolate, &stubBackend{name: "isolate"})
+	reg.Register(model.IsolationMicroVM, &sweepingBackend{stubBackend{name: "microvm"}})
+
+	sweepers := reg.Sweepers()
+	if len(sweepers) != 1 || sweepers[model.IsolationMicroVM] == nil {
+		t.Fatalf("Sweepers() = %v, want only the microvm backend", sweepers)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -14,6 +15,8 @@ const (
 	envListenAddr = "VULCAN_LISTEN_ADDR"
 	envDBPath     = "VULCAN_DB_PATH"
 	envLogLevel   = "VULCAN_LOG_LEVEL"
+
+	envScratchBudgetMB = "VULCAN_SCRATCH_BUDGET_MB"
 )
 
 // Config holds application configuration loaded from environment variables.
@@ -21,6 +24,10 @@ type Config struct {
 	ListenAddr string
 	DBPath     string
 	LogLevel   slog.Level
+
+	// ScratchBudgetMB caps the combined scratch disk size of the workloads
+	// submitted to this node and not yet finished; zero means unlimited.
+	ScratchBudgetMB int
 }
 
 // Load reads configuration from environment variables with sensible defaults.
@@ -40,6 +47,11 @@ func Load() Config {
 	if v := os.Getenv(envLogLevel); v != "" {
 		cfg.LogLevel = parseLogLevel(v)
 	}
+	if v := os.Getenv(envScratchBudgetMB); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.ScratchBudgetMB = n
+		}
+	}
 
 	return cfg
 }
@@ -11,6 +11,7 @@ func TestLoadDefaults(t *testing.T) {
 	t.Setenv(envListenAddr, "")
 	t.Setenv(envDBPath, "")
 	t.Setenv(envLogLevel, "")
+	t.Setenv(envScratchBudgetMB, "")
 
 	cfg := Load()
 
@@ -23,12 +24,16 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.LogLevel != slog.LevelInfo {
 		t.Errorf("LogLevel = %v, want %v", cfg.LogLevel, slog.LevelInfo)
 	}
+	if cfg.ScratchBudgetMB != 0 {
+		t.Errorf("ScratchBudgetMB = %d, want 0 (unlimited)", cfg.ScratchBudgetMB)
+	}
 }
 
 func TestLoadFromEnv(t *testing.T) {
 	t.Setenv(envListenAddr, ":9090")
 	t.Setenv(envDBPath, "/tmp/test.db")
 	t.Setenv(envLogLevel, "debug")
+	t.Setenv(envScratchBudgetMB, "4096")
 
 	cfg := Load()
 
@@ -41,6 +46,9 @@ func TestLoadFromEnv(t *testing.T) {
 	if cfg.LogLevel != slog.LevelDebug {
 		t.Errorf("LogLevel = %v, want %v", cfg.LogLevel, slog.LevelDebug)
 	}
+	if cfg.ScratchBudgetMB != 4096 {
+		t.Errorf("ScratchBudgetMB = %d, want 4096", cfg.ScratchBudgetMB)
+	}
 }
 
 func TestParseLogLevel(t *testing.T) {
@@ -2,8 +2,10 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +18,10 @@ import (
 // DefaultTimeoutS is the default timeout in seconds when none is specified.
 const DefaultTimeoutS = 30
 
+// ErrScratchBudget is returned by Submit when a workload's scratch disk does
+// not fit in what is left of the node's scratch budget.
+var ErrScratchBudget = errors.New("scratch disk budget exceeded")
+
 // Engine orchestrates asynchronous workload execution.
 type Engine struct {
 	store    store.Store
@@ -23,6 +29,27 @@ type Engine struct {
 	logger   *slog.Logger
 	wg       sync.WaitGroup
 	broker   *LogBroker
+
+	runMu   sync.Mutex
+	running map[string]*runningWorkload // workload ID → in-flight execution
+
+	scratchMu         sync.Mutex
+	scratchBudgetMB   int // 0 means unlimited
+	scratchReservedMB int
+
+	sessMu   sync.Mutex
+	sessions map[string]*runningSession // session ID → open session
+}
+
+// runningWorkload tracks an in-flight execution so it can be cancelled or signalled.
+type runningWorkload struct {
+	cancel  context.CancelFunc
+	backend backend.Backend // nil until the backend is resolved
+
+	// Services can also be stopped, or redeployed with an update.
+	service  bool
+	stopping bool
+	update   *ServiceUpdate
 }
 
 // NewEngine creates a new execution engine.
@@ -32,6 +59,8 @@ func NewEngine(s store.Store, reg *backend.Registry, logger *slog.Logger) *Engin
 		registry: reg,
 		logger:   logger,
 		broker:   NewLogBroker(),
+		running:  make(map[string]*runningWorkload),
+		sessions: make(map[string]*runningSession),
 	}
 }
 
@@ -40,28 +69,122 @@ func (e *Engine) Broker() *LogBroker {
 	return e.broker
 }
 
+// SetScratchBudget limits the combined size of the scratch disks of
+// unfinished workloads to mb. Zero, the default, means unlimited.
+func (e *Engine) SetScratchBudget(mb int) {
+	e.scratchMu.Lock()
+	e.scratchBudgetMB = mb
+	e.scratchMu.Unlock()
+}
+
 // Submit creates a workload record and launches asynchronous execution in a
 // goroutine. The workload is stored with status "pending" before returning.
 // The goroutine operates on a copy of the workload to avoid data races with
-// the caller.
+// the caller. Returns ErrScratchBudget, without storing the workload, if its
+// scratch disk does not fit in the scratch budget.
 func (e *Engine) Submit(ctx context.Context, w *model.Workload) error {
+	diskMB := 0
+	if w.DiskLimit != nil {
+		diskMB = *w.DiskLimit
+	}
+	if err := e.reserveScratch(diskMB); err != nil {
+		return err
+	}
 	if err := e.store.CreateWorkload(ctx, w); err != nil {
+		e.releaseScratch(diskMB)
 		return fmt.Errorf("create workload: %w", err)
 	}
 
 	wCopy := *w
 	e.wg.Go(func() {
+		defer e.releaseScratch(diskMB)
 		e.execute(&wCopy)
 	})
 
 	return nil
 }
 
+// reserveScratch sets mb of the scratch budget aside for a workload until
+// releaseScratch returns it.
+func (e *Engine) reserveScratch(mb int) error {
+	if mb == 0 {
+		return nil
+	}
+	e.scratchMu.Lock()
+	defer e.scratchMu.Unlock()
+	if e.scratchBudgetMB > 0 && e.scratchReservedMB+mb > e.scratchBudgetMB {
+		return fmt.Errorf("%w: %d MB requested, %d of %d MB available",
+			ErrScratchBudget, mb, e.scratchBudgetMB-e.scratchReservedMB, e.scratchBudgetMB)
+	}
+	e.scratchReservedMB += mb
+	return nil
+}
+
+// releaseScratch returns mb reserved by reserveScratch to the budget.
+func (e *Engine) releaseScratch(mb int) {
+	e.scratchMu.Lock()
+	e.scratchReservedMB -= mb
+	e.scratchMu.Unlock()
+}
+
 // Wait blocks until all in-flight workload goroutines complete.
 func (e *Engine) Wait() {
 	e.wg.Wait()
 }
 
+// Cancel stops the in-flight execution of the workload with the given ID.
+// Backends that support it terminate the workload gracefully and still
+// collect its output. Returns false if the workload is not executing.
+func (e *Engine) Cancel(id string) bool {
+	e.runMu.Lock()
+	rw, ok := e.running[id]
+	var cancel context.CancelFunc
+	if ok {
+		cancel = rw.cancel
+		rw.update = nil // a killed service is not redeployed
+	}
+	e.runMu.Unlock()
+	if !ok {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// Signal delivers the named signal to a running workload. Returns
+// backend.ErrNotRunning if the workload is not executing and
+// backend.ErrUnsupported if its backend cannot deliver signals.
+func (e *Engine) Signal(ctx context.Context, id, signal string) error {
+	e.runMu.Lock()
+	rw, ok := e.running[id]
+	var b backend.Backend
+	if ok {
+		b = rw.backend
+	}
+	e.runMu.Unlock()
+
+	if b == nil {
+		return backend.ErrNotRunning
+	}
+	sg, ok := b.(backend.Signaler)
+	if !ok {
+		return backend.ErrUnsupported
+	}
+	return sg.Signal(ctx, id, signal)
+}
+
+// track registers an in-flight execution and returns a function that removes it.
+func (e *Engine) track(id string, rw *runningWorkload) func() {
+	e.runMu.Lock()
+	e.running[id] = rw
+	e.runMu.Unlock()
+	return func() {
+		e.runMu.Lock()
+		delete(e.running, id)
+		e.runMu.Unlock()
+	}
+}
+
 // execute runs the workload lifecycle in a goroutine: pending→running→completed/failed.
 func (e *Engine) execute(w *model.Workload) {
 	// Close the log stream when execution finishes, regardless of outcome.
@@ -70,7 +193,7 @@ func (e *Engine) execute(w *model.Workload) {
 	// Transition to running.
 	if err := e.store.UpdateWorkloadStatus(context.Background(), w.ID, model.StatusRunning); err != nil {
 		e.logger.Error("failed to transition to running", "workload_id", w.ID, "error", err)
-		e.finishFailed(w.ID, nil, fmt.Sprintf("failed to start: %v", err))
+		e.finishFailed(w.ID, nil, model.ExitReasonInfraError, fmt.Sprintf("failed to start: %v", err))
 		return
 	}
 
@@ -84,11 +207,29 @@ func (e *Engine) execute(w *model.Workload) {
 		timeoutS = *w.TimeoutS
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutS)*time.Second)
-	defer cancel()
+	// Services run until they are stopped or killed.
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if w.IsService() {
+		ctx, cancel = context.WithCancel(context.Background())
+		timeoutS = 0
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(timeoutS)*time.Second)
+	}
+
+	rw := &runningWorkload{cancel: cancel, service: w.IsService()}
+	defer e.track(w.ID, rw)()
+	defer func() {
+		// A redeployed service has replaced its context.
+		e.runMu.Lock()
+		rw.cancel()
+		e.runMu.Unlock()
+	}()
 
 	// Build the workload spec. The LogWriter dual-writes: persist to SQLite
 	// for historical viewing, then publish to LogBroker for real-time SSE.
+	// Lines keep arriving after ctx ends, while a timed-out or cancelled
+	// workload is given its grace period.
 	var seq atomic.Int32
 	spec := backend.WorkloadSpec{
 		ID:          w.ID,
@@ -97,37 +238,182 @@ func (e *Engine) execute(w *model.Workload) {
 		Code:        w.Code,
 		CodeArchive: w.CodeArchive,
 		TimeoutS:    timeoutS,
-		LogWriter: func(line string) {
+		Network:     w.Network,
+		LogWriter: func(stream, line string) {
 			currentSeq := int(seq.Add(1) - 1)
-			if err := e.store.InsertLogLine(ctx, w.ID, currentSeq, line); err != nil {
+			if err := e.store.InsertLogLine(context.Background(), w.ID, currentSeq, stream, line); err != nil {
 				e.logger.Error("failed to persist log line", "workload_id", w.ID, "seq", currentSeq, "error", err)
 			}
-			e.broker.Publish(w.ID, line)
+			e.broker.Publish(w.ID, stream, line)
 		},
 	}
+	var buildSeq atomic.Int32
+	spec.BuildLogWriter = func(line string) {
+		currentSeq := int(buildSeq.Add(1) - 1)
+		if err := e.store.InsertBuildLogLine(context.Background(), w.ID, currentSeq, line); err != nil {
+			e.logger.Error("failed to persist build log line", "workload_id", w.ID, "seq", currentSeq, "error", err)
+		}
+	}
 	if w.CPULimit != nil {
 		spec.CPULimit = *w.CPULimit
 	}
 	if w.MemLimit != nil {
 		spec.MemLimitMB = *w.MemLimit
 	}
+	if w.DiskLimit != nil {
+		spec.DiskMB = *w.DiskLimit
+	}
+	if w.GraceS != nil {
+		spec.GraceS = *w.GraceS
+	}
+	spec.Group = w.Group
+	spec.Name = w.Name
+	spec.RateLimits = w.RateLimits
+	if w.ExposePort != nil {
+		spec.ExposePort = *w.ExposePort
+		spec.OnEndpoint = func(url string) {
+			if err := e.store.SetWorkloadEndpoint(context.Background(), w.ID, url); err != nil {
+				e.logger.Error("failed to record workload endpoint", "workload_id", w.ID, "error", err)
+			}
+		}
+	}
+	if w.IsService() {
+		spec.Service = w.Service
+		spec.OnHealth = func(r backend.HealthReport) {
+			if err := e.store.RecordHealth(context.Background(), w.ID, r.Status, r.Detail, r.Restarts); err != nil {
+				e.logger.Error("failed to record service health", "workload_id", w.ID, "error", err)
+			}
+		}
+	}
+
+	// Catalog images replace the microVM backend's built-in runtime images.
+	img, isolation, err := e.resolveImage(w.ID, w.Runtime, w.Isolation, start)
+	if err != nil {
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, err.Error())
+		return
+	}
+	spec.Image = img
 
 	// Resolve backend.
-	b, err := e.registry.Resolve(w.Isolation, w.Runtime)
+	b, err := e.registry.Resolve(isolation, w.Runtime)
 	if err != nil {
-		e.finishFailed(w.ID, &start, fmt.Sprintf("resolve backend: %v", err))
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("resolve backend: %v", err))
+		return
+	}
+	if mode := w.Network.EffectiveMode(); w.Network != nil && !slices.Contains(b.Capabilities().NetworkModes, mode) {
+		// Never run a workload with weaker isolation than it asked for.
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("backend %s cannot enforce network mode %q", b.Capabilities().Name, mode))
+		return
+	}
+	if spec.ExposePort != 0 && !b.Capabilities().Ingress {
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("backend %s cannot expose ports", b.Capabilities().Name))
+		return
+	}
+	if spec.Group != "" && !b.Capabilities().NetworkGroups {
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("backend %s cannot place workloads in network groups", b.Capabilities().Name))
+		return
+	}
+	if !spec.RateLimits.IsZero() && !b.Capabilities().RateLimits {
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("backend %s cannot enforce rate limits", b.Capabilities().Name))
+		return
+	}
+	if spec.Image != nil && !b.Capabilities().Images {
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("backend %s cannot run catalog images", b.Capabilities().Name))
+		return
+	}
+	if spec.DiskMB > 0 && !b.Capabilities().ScratchDisks {
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("backend %s cannot provide scratch disks", b.Capabilities().Name))
+		return
+	}
+	if len(w.Volumes) > 0 && !b.Capabilities().Volumes {
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("backend %s cannot attach volumes", b.Capabilities().Name))
+		return
+	}
+	if spec.Service != nil && !b.Capabilities().Services {
+		e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("backend %s cannot run services", b.Capabilities().Name))
 		return
 	}
 
+	// Volumes stay locked for the workload until its sandbox has stopped.
+	if len(w.Volumes) > 0 {
+		volumes, err := e.store.AttachVolumes(context.Background(), w.ID, w.Volumes)
+		if err != nil {
+			e.finishFailed(w.ID, &start, model.ExitReasonInfraError, fmt.Sprintf("attach volumes: %v", err))
+			return
+		}
+		defer func() {
+			if err := e.store.DetachVolumes(context.Background(), w.ID); err != nil {
+				e.logger.Error("failed to detach volumes", "workload_id", w.ID, "error", err)
+			}
+		}()
+		for i, v := range volumes {
+			spec.Volumes = append(spec.Volumes, backend.AttachedVolume{
+				Name:      v.Name,
+				Path:      v.Path,
+				MountPath: w.Volumes[i].MountPath,
+				ReadOnly:  w.Volumes[i].ReadOnly,
+			})
+		}
+	}
+	e.runMu.Lock()
+	rw.backend = b
+	e.runMu.Unlock()
+
 	result, err := b.Execute(ctx, spec)
+	// An updated service is redeployed once its previous sandbox has stopped.
+	for {
+		u, next := e.takeUpdate(rw)
+		if u == nil {
+			break
+		}
+		if err != nil {
+			e.logger.Warn("service stopped with error before redeploy", "workload_id", w.ID, "error", err)
+		}
+		ctx = next
+		u.apply(&spec, w)
+		if err := e.store.RecordHealth(context.Background(), w.ID, model.HealthStarting, "updated", 0); err != nil {
+			e.logger.Error("failed to record service health", "workload_id", w.ID, "error", err)
+		}
+		result, err = b.Execute(ctx, spec)
+	}
 	durationMS := int(time.Since(start).Milliseconds())
+	// Whether the workload ran out of time is decided as the backend
+	// returns, not after the bookkeeping below.
+	deadlinePassed := errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+	// The serial console shows why a VM failed to boot or lost its agent,
+	// however the workload ended.
+	if len(result.ConsoleLines) > 0 {
+		if err := e.store.InsertConsoleLines(context.Background(), w.ID, result.ConsoleLines); err != nil {
+			e.logger.Error("failed to persist console output", "workload_id", w.ID, "error", err)
+		}
+	}
+
+	// A cancelled workload has already been marked killed; keep that status
+	// and record whatever the backend collected before it stopped.
+	cancelled := errors.Is(ctx.Err(), context.Canceled)
+	e.runMu.Lock()
+	stopped := rw.stopping
+	e.runMu.Unlock()
+	if stopped {
+		// A stopped service completes however its process exited.
+		if err != nil {
+			e.logger.Info("service stopped", "workload_id", w.ID, "error", err)
+			err = nil
+		}
+		result.Error = ""
+		cancelled = false
+	} else if err != nil && cancelled {
+		e.logger.Info("workload cancelled", "workload_id", w.ID, "error", err)
+		return
+	}
 
 	if err != nil {
 		errMsg := err.Error()
-		if ctx.Err() == context.DeadlineExceeded {
+		if deadlinePassed {
 			errMsg = fmt.Sprintf("workload timed out after %ds", timeoutS)
 		}
-		e.finishFailed(w.ID, &start, errMsg)
+		e.finishFailed(w.ID, &start, failureReason(deadlinePassed, err), errMsg)
 		return
 	}
 
@@ -139,25 +425,71 @@ func (e *Engine) execute(w *model.Workload) {
 		dur = result.DurationMS
 	}
 
+	status := model.StatusCompleted
+	if cancelled {
+		status = model.StatusKilled
+	}
+
 	completed := &model.Workload{
 		ID:         w.ID,
-		Status:     model.StatusCompleted,
+		Status:     status,
 		Output:     result.Output,
+		Stdout:     result.Stdout,
+		Stderr:     result.Stderr,
 		ExitCode:   &result.ExitCode,
 		Error:      result.Error,
+		ExitReason: resultReason(result),
+		ExitSignal: result.ExitSignal,
 		DurationMS: &dur,
+		Usage:      result.Usage,
 		StartedAt:  &start,
 		FinishedAt: &now,
 	}
+	if timedOut(result, deadlinePassed) {
+		markTimedOut(completed, timeoutS)
+	}
+	workloadExitsTotal.WithLabelValues(completed.ExitReason).Inc()
 
 	if err := e.store.UpdateWorkload(context.Background(), completed); err != nil {
 		e.logger.Error("failed to update completed workload", "workload_id", w.ID, "error", err)
 	}
 }
 
-// finishFailed marks a workload as failed with the given error message.
-// startedAt may be nil if execution never started.
-func (e *Engine) finishFailed(id string, startedAt *time.Time, errMsg string) {
+// markTimedOut records that a workload its backend ran to the end was
+// stopped for running past its timeout: it fails, keeping what it wrote
+// before and during its grace period.
+func markTimedOut(w *model.Workload, timeoutS int) {
+	w.Status = model.StatusFailed
+	w.Error = fmt.Sprintf("workload timed out after %ds", timeoutS)
+	w.ExitReason = model.ExitReasonTimeout
+	w.ExitSignal = ""
+}
+
+// resolveImage returns the catalog image that runtime names, recording its
+// use at at, along with the isolation to run it with. Built-in runtimes, and
+// runtimes a backend supports without a version, such as those declared by
+// rootfs manifests, have no image and keep isolation.
+func (e *Engine) resolveImage(id, runtime, isolation string, at time.Time) (*model.Image, string, error) {
+	name, version, ok := model.ParseImageRef(runtime)
+	if !ok || (version == "" && e.registry.Provides(runtime)) {
+		return nil, isolation, nil
+	}
+	img, err := e.store.GetImage(context.Background(), name, version)
+	if err != nil {
+		return nil, "", fmt.Errorf("resolve image %s: %w", runtime, err)
+	}
+	if err := e.store.MarkImageUsed(context.Background(), img.Name, img.Version, at.UTC()); err != nil {
+		e.logger.Error("failed to record image use", "workload_id", id, "image", img.Ref(), "error", err)
+	}
+	if isolation == model.IsolationAuto {
+		isolation = model.IsolationMicroVM
+	}
+	return img, isolation, nil
+}
+
+// finishFailed marks a workload as failed with the given exit reason and
+// error message. startedAt may be nil if execution never started.
+func (e *Engine) finishFailed(id string, startedAt *time.Time, reason, errMsg string) {
 	now := time.Now().UTC()
 	var durationMS int
 	if startedAt != nil {
@@ -168,10 +500,12 @@ func (e *Engine) finishFailed(id string, startedAt *time.Time, errMsg string) {
 		ID:         id,
 		Status:     model.StatusFailed,
 		Error:      errMsg,
+		ExitReason: reason,
 		DurationMS: &durationMS,
 		StartedAt:  startedAt,
 		FinishedAt: &now,
 	}
+	workloadExitsTotal.WithLabelValues(reason).Inc()
 
 	if err := e.store.UpdateWorkload(context.Background(), w); err != nil {
 		e.logger.Error("failed to update failed workload", "workload_id", id, "error", err)
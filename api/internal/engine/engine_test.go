@@ -3,8 +3,10 @@ package engine_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -203,10 +205,10 @@ type loggingBackend struct {
 func (lb *loggingBackend) Execute(_ context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
 	for _, line := range lb.lines {
 		if spec.LogWriter != nil {
-			spec.LogWriter(line)
+			spec.LogWriter(model.LogStreamStdout, line)
 		}
 	}
-	return backend.WorkloadResult{ExitCode: 0, Output: []byte("done"), LogLines: lb.lines}, nil
+	return backend.WorkloadResult{ExitCode: 0, Output: []byte("done"), Stdout: []byte("done"), LogLines: lb.lines}, nil
 }
 
 func (lb *loggingBackend) Capabilities() backend.BackendCapabilities {
@@ -247,6 +249,17 @@ func TestDualWriteLogPersistence(t *testing.T) {
 		if l.Line != expectedLines[i] {
 			t.Errorf("lines[%d].Line = %q, want %q", i, l.Line, expectedLines[i])
 		}
+		if l.Stream != model.LogStreamStdout {
+			t.Errorf("lines[%d].Stream = %q, want %q", i, l.Stream, model.LogStreamStdout)
+		}
+	}
+
+	got, err := s.GetWorkload(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if string(got.Stdout) != "done" || got.Stderr != nil {
+		t.Errorf("stdout, stderr = %q, %q; want the backend's", got.Stdout, got.Stderr)
 	}
 }
 
@@ -268,7 +281,7 @@ func TestDualWriteSSEUnaffected(t *testing.T) {
 	// Collect SSE lines from broker.
 	var received []string
 	for line := range ch {
-		received = append(received, line)
+		received = append(received, line.Line)
 	}
 
 	if len(received) != len(expectedLines) {
@@ -306,3 +319,637 @@ func TestSubmitConcurrent(t *testing.T) {
 		waitForStatus(t, s, id, model.StatusCompleted, 5*time.Second)
 	}
 }
+
+// gracefulBackend runs until cancelled, then reports the output collected so
+// far like a backend that stops workloads cleanly. It records signals.
+type gracefulBackend struct {
+	delayBackend
+	signals chan string
+}
+
+func (g *gracefulBackend) Execute(ctx context.Context, _ backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	<-ctx.Done()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return backend.WorkloadResult{}, ctx.Err()
+	}
+	return backend.WorkloadResult{ExitCode: 143, Output: []byte("partial"), Error: "cancelled by host"}, nil
+}
+
+func (g *gracefulBackend) Signal(_ context.Context, _, signal string) error {
+	g.signals <- signal
+	return nil
+}
+
+// waitForSignalable polls until the engine has resolved a backend for id.
+func waitForSignalable(t *testing.T, eng *engine.Engine, id string) error {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		err := eng.Signal(context.Background(), id, "SIGUSR1")
+		if !errors.Is(err, backend.ErrNotRunning) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("workload %s never became signalable", id)
+	return nil
+}
+
+func TestCancelKeepsKilledStatus(t *testing.T) {
+	b := &gracefulBackend{signals: make(chan string, 1)}
+	eng, s := newTestEngine(t, b)
+
+	w := makeAsyncWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitForStatus(t, s, w.ID, model.StatusRunning, 5*time.Second)
+	if err := waitForSignalable(t, eng, w.ID); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+
+	if err := s.UpdateWorkloadStatus(context.Background(), w.ID, model.StatusKilled); err != nil {
+		t.Fatalf("UpdateWorkloadStatus: %v", err)
+	}
+	if !eng.Cancel(w.ID) {
+		t.Fatal("Cancel returned false for running workload")
+	}
+	eng.Wait()
+
+	got, err := s.GetWorkload(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Status != model.StatusKilled {
+		t.Errorf("status = %q, want killed", got.Status)
+	}
+	if string(got.Output) != "partial" {
+		t.Errorf("output = %q, want %q", got.Output, "partial")
+	}
+	if eng.Cancel(w.ID) {
+		t.Error("Cancel returned true after execution finished")
+	}
+}
+
+func TestCancelUnknownWorkload(t *testing.T) {
+	eng, _ := newTestEngine(t, &delayBackend{})
+	if eng.Cancel("nonexistent") {
+		t.Error("Cancel returned true for unknown workload")
+	}
+}
+
+func TestSignalRunningWorkload(t *testing.T) {
+	b := &gracefulBackend{signals: make(chan string, 1)}
+	eng, _ := newTestEngine(t, b)
+
+	w := makeAsyncWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if err := waitForSignalable(t, eng, w.ID); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	if got := <-b.signals; got != "SIGUSR1" {
+		t.Errorf("signal = %q, want SIGUSR1", got)
+	}
+
+	eng.Cancel(w.ID)
+	eng.Wait()
+}
+
+func TestSignalUnsupportedBackend(t *testing.T) {
+	eng, _ := newTestEngine(t, &delayBackend{delay: 5 * time.Second})
+
+	w := makeAsyncWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if err := waitForSignalable(t, eng, w.ID); !errors.Is(err, backend.ErrUnsupported) {
+		t.Errorf("Signal error = %v, want ErrUnsupported", err)
+	}
+
+	eng.Cancel(w.ID)
+	eng.Wait()
+}
+
+func TestSubmitUnenforceableNetworkPolicy(t *testing.T) {
+	b := &delayBackend{delay: 10 * time.Millisecond, output: []byte("ran")}
+	eng, s := newTestEngine(t, b)
+
+	w := makeAsyncWorkload()
+	w.Network = &model.NetworkPolicy{Mode: model.NetworkNone}
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "cannot enforce network mode") {
+		t.Errorf("Error = %q, want network mode rejection", failed.Error)
+	}
+	if len(failed.Output) != 0 {
+		t.Errorf("Output = %q, workload should not have run", failed.Output)
+	}
+}
+
+// ingressBackend publishes an endpoint for the exposed port, then blocks
+// until released so tests can observe the running workload.
+type ingressBackend struct {
+	delayBackend
+	release chan struct{}
+}
+
+func (ib *ingressBackend) Execute(_ context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	if spec.OnEndpoint != nil {
+		spec.OnEndpoint(fmt.Sprintf("http://127.0.0.1:%d", 40000+spec.ExposePort))
+	}
+	<-ib.release
+	return backend.WorkloadResult{Output: []byte("served")}, nil
+}
+
+func (ib *ingressBackend) Capabilities() backend.BackendCapabilities {
+	caps := ib.delayBackend.Capabilities()
+	caps.Ingress = true
+	return caps
+}
+
+func TestSubmitExposePortRecordsEndpoint(t *testing.T) {
+	b := &ingressBackend{release: make(chan struct{})}
+	eng, s := newTestEngine(t, b)
+
+	port := 80
+	w := makeAsyncWorkload()
+	w.ExposePort = &port
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := s.GetWorkload(context.Background(), w.ID)
+		if err != nil {
+			t.Fatalf("GetWorkload: %v", err)
+		}
+		if got.EndpointURL != "" {
+			if got.EndpointURL != "http://127.0.0.1:40080" {
+				t.Errorf("EndpointURL = %q, want http://127.0.0.1:40080", got.EndpointURL)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("endpoint was not recorded while the workload ran")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(b.release)
+	completed := waitForStatus(t, s, w.ID, model.StatusCompleted, 5*time.Second)
+	if completed.EndpointURL != "" {
+		t.Errorf("EndpointURL = %q after completion, want cleared", completed.EndpointURL)
+	}
+	eng.Wait()
+}
+
+func TestSubmitExposePortUnsupportedBackend(t *testing.T) {
+	b := &delayBackend{delay: 10 * time.Millisecond, output: []byte("ran")}
+	eng, s := newTestEngine(t, b)
+
+	port := 8080
+	w := makeAsyncWorkload()
+	w.ExposePort = &port
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "cannot expose ports") {
+		t.Errorf("Error = %q, want expose port rejection", failed.Error)
+	}
+	if len(failed.Output) != 0 {
+		t.Errorf("Output = %q, workload should not have run", failed.Output)
+	}
+}
+
+func TestSubmitGroupUnsupportedBackend(t *testing.T) {
+	b := &delayBackend{delay: 10 * time.Millisecond, output: []byte("ran")}
+	eng, s := newTestEngine(t, b)
+
+	w := makeAsyncWorkload()
+	w.Group = "shop"
+	w.Name = "api"
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "cannot place workloads in network groups") {
+		t.Errorf("Error = %q, want network group rejection", failed.Error)
+	}
+	if failed.Group != "shop" || failed.Name != "api" {
+		t.Errorf("Group, Name = %q, %q; want shop, api", failed.Group, failed.Name)
+	}
+}
+
+func TestSubmitRateLimitsUnsupportedBackend(t *testing.T) {
+	b := &delayBackend{delay: 10 * time.Millisecond, output: []byte("ran")}
+	eng, s := newTestEngine(t, b)
+
+	w := makeAsyncWorkload()
+	w.RateLimits = &model.RateLimits{DiskBytesPerS: 1 << 20}
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "cannot enforce rate limits") {
+		t.Errorf("Error = %q, want rate limit rejection", failed.Error)
+	}
+}
+
+// imageBackend records the spec of the workload it runs and can run
+// catalog images.
+type imageBackend struct {
+	delayBackend
+	specs chan backend.WorkloadSpec
+}
+
+func (ib *imageBackend) Execute(_ context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	ib.specs <- spec
+	return backend.WorkloadResult{Output: []byte("ran")}, nil
+}
+
+func (ib *imageBackend) Capabilities() backend.BackendCapabilities {
+	caps := ib.delayBackend.Capabilities()
+	caps.Images = true
+	return caps
+}
+
+// createTestImage registers name@version in the store, created at created.
+func createTestImage(t *testing.T, s store.Store, name, version string, created time.Time) {
+	t.Helper()
+	img := &model.Image{
+		Name:       name,
+		Version:    version,
+		Digest:     "sha256:" + name + version,
+		Command:    []string{"python3"},
+		Entrypoint: "main.py",
+		Path:       "/images/" + name + version + ".ext4",
+		CreatedAt:  created,
+	}
+	if err := s.CreateImage(context.Background(), img); err != nil {
+		t.Fatalf("CreateImage: %v", err)
+	}
+}
+
+func TestSubmitCatalogImage(t *testing.T) {
+	s, err := store.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	createTestImage(t, s, "ml", "1.0", time.Now().Add(-time.Hour))
+	createTestImage(t, s, "ml", "2.0", time.Now())
+
+	// Images run on the microVM backend when isolation is auto.
+	b := &imageBackend{specs: make(chan backend.WorkloadSpec, 1)}
+	reg := backend.NewRegistry()
+	reg.Register(model.IsolationMicroVM, b)
+	eng := engine.NewEngine(s, reg, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	w := makeAsyncWorkload()
+	w.Isolation = model.IsolationAuto
+	w.Runtime = "ml"
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitForStatus(t, s, w.ID, model.StatusCompleted, 5*time.Second)
+	eng.Wait()
+
+	spec := <-b.specs
+	if spec.Image == nil || spec.Image.Ref() != "ml@2.0" || spec.Image.Path != "/images/ml2.0.ext4" {
+		t.Errorf("spec.Image = %+v, want the newest version, ml@2.0", spec.Image)
+	}
+	img, err := s.GetImage(context.Background(), "ml", "2.0")
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	if img.LastUsedAt == nil {
+		t.Error("LastUsedAt not recorded for the image a workload ran in")
+	}
+}
+
+// rubyBackend is an imageBackend on the microVM isolation whose rootfs
+// manifests declare ruby.
+type rubyBackend struct {
+	imageBackend
+}
+
+func (rb *rubyBackend) Capabilities() backend.BackendCapabilities {
+	caps := rb.imageBackend.Capabilities()
+	caps.SupportedRuntimes = []string{model.RuntimePython, "ruby"}
+	caps.SupportedIsolations = []string{model.IsolationMicroVM}
+	return caps
+}
+
+func TestSubmitManifestRuntime(t *testing.T) {
+	s, err := store.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	b := &rubyBackend{imageBackend{specs: make(chan backend.WorkloadSpec, 1)}}
+	reg := backend.NewRegistry()
+	reg.Register(model.IsolationMicroVM, b)
+	eng := engine.NewEngine(s, reg, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	// ruby is neither built in nor a catalog image; it runs on the backend
+	// that declares it.
+	w := makeAsyncWorkload()
+	w.Isolation = model.IsolationAuto
+	w.Runtime = "ruby"
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	completed := waitForStatus(t, s, w.ID, model.StatusCompleted, 5*time.Second)
+	eng.Wait()
+
+	if string(completed.Output) != "ran" {
+		t.Errorf("output = %q, want %q", completed.Output, "ran")
+	}
+	spec := <-b.specs
+	if spec.Runtime != "ruby" || spec.Image != nil {
+		t.Errorf("spec runtime %q image %+v, want ruby without a catalog image", spec.Runtime, spec.Image)
+	}
+}
+
+func TestSubmitUnknownImage(t *testing.T) {
+	eng, s := newTestEngine(t, &delayBackend{delay: 10 * time.Millisecond})
+
+	w := makeAsyncWorkload()
+	w.Runtime = "python@9.9"
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "resolve image python@9.9: image not found") {
+		t.Errorf("Error = %q, want image not found", failed.Error)
+	}
+}
+
+func TestSubmitImageUnsupportedBackend(t *testing.T) {
+	eng, s := newTestEngine(t, &delayBackend{delay: 10 * time.Millisecond, output: []byte("ran")})
+	createTestImage(t, s, "python", "3.12", time.Now())
+
+	w := makeAsyncWorkload()
+	w.Runtime = "python@3.12"
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "cannot run catalog images") {
+		t.Errorf("Error = %q, want catalog image rejection", failed.Error)
+	}
+}
+
+// bootFailBackend fails every workload as if its sandbox did not boot.
+type bootFailBackend struct {
+	delayBackend
+}
+
+func (bb *bootFailBackend) Execute(context.Context, backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	return backend.WorkloadResult{ConsoleLines: []string{"Kernel panic - not syncing"}}, errors.New("connect to guest: timed out")
+}
+
+// buildLogBackend reports a line of compiler output and one of program output.
+type buildLogBackend struct {
+	delayBackend
+}
+
+func (bb *buildLogBackend) Execute(_ context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	spec.BuildLogWriter("compiling")
+	spec.LogWriter(model.LogStreamStdout, "running")
+	return backend.WorkloadResult{}, nil
+}
+
+func TestSubmitPersistsBuildLogSeparately(t *testing.T) {
+	eng, s := newTestEngine(t, &buildLogBackend{})
+
+	w := makeAsyncWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitForStatus(t, s, w.ID, model.StatusCompleted, 5*time.Second)
+
+	build, err := s.GetBuildLogLines(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetBuildLogLines: %v", err)
+	}
+	if len(build) != 1 || build[0].Line != "compiling" {
+		t.Errorf("build log = %+v, want the compiler output", build)
+	}
+	lines, err := s.GetLogLines(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetLogLines: %v", err)
+	}
+	if len(lines) != 1 || lines[0].Line != "running" {
+		t.Errorf("log = %+v, want only the program output", lines)
+	}
+}
+
+func TestSubmitPersistsConsoleOnFailure(t *testing.T) {
+	eng, s := newTestEngine(t, &bootFailBackend{})
+
+	w := makeAsyncWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "connect to guest") {
+		t.Errorf("Error = %q, want the backend error", failed.Error)
+	}
+
+	console, err := s.GetConsoleLines(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetConsoleLines: %v", err)
+	}
+	if len(console) != 1 || console[0].Line != "Kernel panic - not syncing" {
+		t.Errorf("console = %+v, want the backend's console output", console)
+	}
+}
+
+// volumeBackend records the spec of the workload it runs, holding it until
+// release is closed, and can attach volumes.
+type volumeBackend struct {
+	delayBackend
+	specs   chan backend.WorkloadSpec
+	release chan struct{}
+}
+
+func (vb *volumeBackend) Execute(_ context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	vb.specs <- spec
+	<-vb.release
+	return backend.WorkloadResult{Output: []byte("ran")}, nil
+}
+
+func (vb *volumeBackend) Capabilities() backend.BackendCapabilities {
+	caps := vb.delayBackend.Capabilities()
+	caps.Volumes = true
+	return caps
+}
+
+func createTestVolume(t *testing.T, s store.Store, name string) {
+	t.Helper()
+	v := &model.Volume{Name: name, SizeMB: 16, Path: "/volumes/" + name + ".ext4", CreatedAt: time.Now()}
+	if err := s.CreateVolume(context.Background(), v); err != nil {
+		t.Fatalf("CreateVolume: %v", err)
+	}
+}
+
+func TestSubmitAttachesVolumes(t *testing.T) {
+	b := &volumeBackend{specs: make(chan backend.WorkloadSpec, 1), release: make(chan struct{})}
+	eng, s := newTestEngine(t, b)
+	createTestVolume(t, s, "data")
+	createTestVolume(t, s, "models")
+
+	w := makeAsyncWorkload()
+	w.Volumes = []model.VolumeMount{
+		{Name: "data", MountPath: "/data"},
+		{Name: "models", MountPath: "/models", ReadOnly: true},
+	}
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	spec := <-b.specs
+	want := []backend.AttachedVolume{
+		{Name: "data", Path: "/volumes/data.ext4", MountPath: "/data"},
+		{Name: "models", Path: "/volumes/models.ext4", MountPath: "/models", ReadOnly: true},
+	}
+	if len(spec.Volumes) != len(want) || spec.Volumes[0] != want[0] || spec.Volumes[1] != want[1] {
+		t.Errorf("spec.Volumes = %+v, want %+v", spec.Volumes, want)
+	}
+
+	// The writer's lock keeps a second writer out while the workload runs.
+	second := makeAsyncWorkload()
+	second.Volumes = []model.VolumeMount{{Name: "data", MountPath: "/data"}}
+	if err := eng.Submit(context.Background(), second); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	failed := waitForStatus(t, s, second.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "attach volumes: volume is locked by another holder: data") {
+		t.Errorf("Error = %q, want a lock conflict on data", failed.Error)
+	}
+
+	close(b.release)
+	waitForStatus(t, s, w.ID, model.StatusCompleted, 5*time.Second)
+	eng.Wait()
+
+	v, err := s.GetVolume(context.Background(), "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Attachments) != 0 {
+		t.Errorf("volume still attached after the workload finished: %+v", v.Attachments)
+	}
+}
+
+func TestSubmitVolumesUnsupportedBackend(t *testing.T) {
+	eng, s := newTestEngine(t, &delayBackend{delay: 10 * time.Millisecond})
+	createTestVolume(t, s, "data")
+
+	w := makeAsyncWorkload()
+	w.Volumes = []model.VolumeMount{{Name: "data", MountPath: "/data"}}
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "cannot attach volumes") {
+		t.Errorf("Error = %q, want volume rejection", failed.Error)
+	}
+	if v, _ := s.GetVolume(context.Background(), "data"); len(v.Attachments) != 0 {
+		t.Errorf("volume attached for a rejected workload: %+v", v.Attachments)
+	}
+}
+
+// scratchBackend is a volumeBackend that can provide scratch disks.
+type scratchBackend struct {
+	volumeBackend
+}
+
+func (sb *scratchBackend) Capabilities() backend.BackendCapabilities {
+	caps := sb.volumeBackend.Capabilities()
+	caps.ScratchDisks = true
+	return caps
+}
+
+func TestSubmitScratchBudget(t *testing.T) {
+	b := &scratchBackend{volumeBackend{specs: make(chan backend.WorkloadSpec, 2), release: make(chan struct{})}}
+	eng, s := newTestEngine(t, b)
+	eng.SetScratchBudget(1024)
+
+	disk := func(mb int) *model.Workload {
+		w := makeAsyncWorkload()
+		w.DiskLimit = &mb
+		return w
+	}
+
+	first := disk(768)
+	if err := eng.Submit(context.Background(), first); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if spec := <-b.specs; spec.DiskMB != 768 {
+		t.Errorf("spec.DiskMB = %d, want 768", spec.DiskMB)
+	}
+
+	rejected := disk(512)
+	err := eng.Submit(context.Background(), rejected)
+	if !errors.Is(err, engine.ErrScratchBudget) {
+		t.Fatalf("Submit over budget = %v, want ErrScratchBudget", err)
+	}
+	if !strings.Contains(err.Error(), "512 MB requested, 256 of 1024 MB available") {
+		t.Errorf("error = %q, want the remaining budget", err)
+	}
+	if _, err := s.GetWorkload(context.Background(), rejected.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("rejected workload was stored: %v", err)
+	}
+
+	// Workloads without a scratch disk are not limited by the budget.
+	if err := eng.Submit(context.Background(), makeAsyncWorkload()); err != nil {
+		t.Fatalf("Submit without a scratch disk: %v", err)
+	}
+	if spec := <-b.specs; spec.DiskMB != 0 {
+		t.Errorf("spec.DiskMB = %d, want 0", spec.DiskMB)
+	}
+
+	// Finished workloads return their share of the budget.
+	close(b.release)
+	eng.Wait()
+	b.specs = make(chan backend.WorkloadSpec, 1)
+	if err := eng.Submit(context.Background(), disk(1024)); err != nil {
+		t.Fatalf("Submit after the budget was released: %v", err)
+	}
+	<-b.specs
+	eng.Wait()
+}
+
+func TestSubmitScratchDiskUnsupportedBackend(t *testing.T) {
+	eng, s := newTestEngine(t, &delayBackend{delay: 10 * time.Millisecond})
+
+	w := makeAsyncWorkload()
+	mb := 64
+	w.DiskLimit = &mb
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	failed := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if !strings.Contains(failed.Error, "cannot provide scratch disks") {
+		t.Errorf("Error = %q, want scratch disk rejection", failed.Error)
+	}
+	if failed.DiskLimit == nil || *failed.DiskLimit != 64 {
+		t.Errorf("DiskLimit = %v, want 64", failed.DiskLimit)
+	}
+}
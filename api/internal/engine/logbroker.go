@@ -18,8 +18,15 @@ type LogBroker struct {
 	topics map[string]*logTopic
 }
 
+// LogEvent is a log line published to subscribers, with the stream it was
+// written to; see model.WorkloadLogStreams.
+type LogEvent struct {
+	Stream string
+	Line   string
+}
+
 type logTopic struct {
-	subs   map[int]chan string
+	subs   map[int]chan LogEvent
 	nextID int
 	closed bool
 }
@@ -34,17 +41,17 @@ func NewLogBroker() *LogBroker {
 // Subscribe returns a channel that receives log lines for the given workload
 // and an unsubscribe function. If the workload has already finished (Close was
 // called), the returned channel is immediately closed.
-func (b *LogBroker) Subscribe(workloadID string) (<-chan string, func()) {
+func (b *LogBroker) Subscribe(workloadID string) (<-chan LogEvent, func()) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	t, ok := b.topics[workloadID]
 	if !ok {
-		t = &logTopic{subs: make(map[int]chan string)}
+		t = &logTopic{subs: make(map[int]chan LogEvent)}
 		b.topics[workloadID] = t
 	}
 
-	ch := make(chan string, subscriberBufferSize)
+	ch := make(chan LogEvent, subscriberBufferSize)
 	if t.closed {
 		close(ch)
 		return ch, func() {}
@@ -61,9 +68,9 @@ func (b *LogBroker) Subscribe(workloadID string) (<-chan string, func()) {
 	}
 }
 
-// Publish sends a log line to all subscribers of the given workload.
-// Lines are dropped for subscribers whose buffers are full.
-func (b *LogBroker) Publish(workloadID string, line string) {
+// Publish sends a log line of the given stream to all subscribers of the
+// given workload. Lines are dropped for subscribers whose buffers are full.
+func (b *LogBroker) Publish(workloadID, stream, line string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -74,7 +81,7 @@ func (b *LogBroker) Publish(workloadID string, line string) {
 
 	for _, ch := range t.subs {
 		select {
-		case ch <- line:
+		case ch <- LogEvent{Stream: stream, Line: line}:
 		default:
 			// Drop line for slow subscribers to avoid blocking execution.
 		}
@@ -91,7 +98,7 @@ func (b *LogBroker) Close(workloadID string) {
 	t, ok := b.topics[workloadID]
 	if !ok {
 		// Create a closed marker so late subscribers get a closed channel.
-		b.topics[workloadID] = &logTopic{subs: make(map[int]chan string), closed: true}
+		b.topics[workloadID] = &logTopic{subs: make(map[int]chan LogEvent), closed: true}
 		return
 	}
 
@@ -13,13 +13,13 @@ func TestLogBrokerSingleSubscriber(t *testing.T) {
 
 	lines := []string{"line 1", "line 2", "line 3"}
 	for _, l := range lines {
-		b.Publish("w1", l)
+		b.Publish("w1", "", l)
 	}
 	b.Close("w1")
 
 	var got []string
 	for l := range ch {
-		got = append(got, l)
+		got = append(got, l.Line)
 	}
 
 	if len(got) != len(lines) {
@@ -39,15 +39,15 @@ func TestLogBrokerMultipleSubscribers(t *testing.T) {
 	ch2, unsub2 := b.Subscribe("w1")
 	defer unsub2()
 
-	b.Publish("w1", "hello")
+	b.Publish("w1", "", "hello")
 	b.Close("w1")
 
 	var got1, got2 []string
 	for l := range ch1 {
-		got1 = append(got1, l)
+		got1 = append(got1, l.Line)
 	}
 	for l := range ch2 {
-		got2 = append(got2, l)
+		got2 = append(got2, l.Line)
 	}
 
 	if len(got1) != 1 || got1[0] != "hello" {
@@ -74,7 +74,7 @@ func TestLogBrokerCloseClosesChannels(t *testing.T) {
 
 func TestLogBrokerLateSubscriberGetsClosed(t *testing.T) {
 	b := engine.NewLogBroker()
-	b.Publish("w1", "early")
+	b.Publish("w1", "", "early")
 	b.Close("w1")
 
 	// Subscribe after Close — should get a closed channel.
@@ -92,14 +92,14 @@ func TestLogBrokerUnsubscribeStopsDelivery(t *testing.T) {
 	ch, unsub := b.Subscribe("w1")
 	unsub()
 
-	b.Publish("w1", "after unsub")
+	b.Publish("w1", "", "after unsub")
 	b.Close("w1")
 
 	// The channel should have no messages (we unsubscribed before publish).
 	select {
 	case l, ok := <-ch:
 		if ok {
-			t.Errorf("got unexpected line %q after unsubscribe", l)
+			t.Errorf("got unexpected line %q after unsubscribe", l.Line)
 		}
 	default:
 		// No data — expected.
@@ -109,7 +109,7 @@ func TestLogBrokerUnsubscribeStopsDelivery(t *testing.T) {
 func TestLogBrokerPublishToUnknownWorkloadIsNoop(t *testing.T) {
 	b := engine.NewLogBroker()
 	// Should not panic.
-	b.Publish("nonexistent", "line")
+	b.Publish("nonexistent", "", "line")
 	b.Close("nonexistent")
 }
 
@@ -118,21 +118,21 @@ func TestLogBrokerLateSubscriberMissesEarlierLines(t *testing.T) {
 	ch1, unsub1 := b.Subscribe("w1")
 	defer unsub1()
 
-	b.Publish("w1", "line 1")
+	b.Publish("w1", "", "line 1")
 
 	// Late subscriber joins after line 1.
 	ch2, unsub2 := b.Subscribe("w1")
 	defer unsub2()
 
-	b.Publish("w1", "line 2")
+	b.Publish("w1", "", "line 2")
 	b.Close("w1")
 
 	var got1, got2 []string
 	for l := range ch1 {
-		got1 = append(got1, l)
+		got1 = append(got1, l.Line)
 	}
 	for l := range ch2 {
-		got2 = append(got2, l)
+		got2 = append(got2, l.Line)
 	}
 
 	if len(got1) != 2 {
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+var workloadExitsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "vulcan_workload_exits_total",
+		Help: "Total number of finished workloads, by how they ended.",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(workloadExitsTotal)
+
+	for _, reason := range model.ExitReasons {
+		workloadExitsTotal.WithLabelValues(reason)
+	}
+}
+
+// failureReason classifies a workload whose backend failed with err, after
+// the workload's deadline if deadlinePassed.
+func failureReason(deadlinePassed bool, err error) string {
+	switch {
+	case deadlinePassed:
+		return model.ExitReasonTimeout
+	case errors.Is(err, backend.ErrVMCrashed):
+		return model.ExitReasonVMCrashed
+	case errors.Is(err, backend.ErrAgentUnreachable):
+		return model.ExitReasonAgentUnreachable
+	}
+	return model.ExitReasonInfraError
+}
+
+// resultReason classifies a workload its backend ran to completion. Results
+// of backends that cannot tell how the workload ended are taken to be of
+// workloads that exited.
+func resultReason(result backend.WorkloadResult) string {
+	if result.ExitReason == "" {
+		return model.ExitReasonExited
+	}
+	return result.ExitReason
+}
+
+// timedOut reports whether a workload its backend ran to completion was
+// stopped for running past its deadline. Backends that tell how the workload
+// ended are believed, so one that finished just in time is not relabelled;
+// for the others, the deadline must have passed by the time they returned.
+func timedOut(result backend.WorkloadResult, deadlinePassed bool) bool {
+	if result.ExitReason != "" {
+		return result.ExitReason == model.ExitReasonTimeout
+	}
+	return deadlinePassed
+}
@@ -0,0 +1,153 @@
+package engine_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// exitBackend ends every workload with its result and error.
+type exitBackend struct {
+	delayBackend
+	result backend.WorkloadResult
+	err    error
+}
+
+func (eb *exitBackend) Execute(context.Context, backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	return eb.result, eb.err
+}
+
+// lateBackend ends every workload with its result and error only once the
+// workload's deadline has passed, as a backend does whose workload finished
+// just in time.
+type lateBackend struct {
+	exitBackend
+}
+
+func (lb *lateBackend) Execute(ctx context.Context, _ backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	<-ctx.Done()
+	return lb.result, lb.err
+}
+
+// exitsTotal returns the value of vulcan_workload_exits_total for reason.
+func exitsTotal(t *testing.T, reason string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, fam := range families {
+		if fam.GetName() != "vulcan_workload_exits_total" {
+			continue
+		}
+		for _, m := range fam.GetMetric() {
+			if labelValue(m, "reason") == reason {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	t.Fatalf("vulcan_workload_exits_total{reason=%q} not found", reason)
+	return 0
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestSubmitRecordsExitReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		backend    backend.Backend
+		wantStatus string
+		wantReason string
+		wantSignal string
+	}{
+		{
+			name:       "exited",
+			backend:    &exitBackend{result: backend.WorkloadResult{ExitCode: 2}},
+			wantStatus: model.StatusCompleted,
+			wantReason: model.ExitReasonExited,
+		},
+		{
+			name: "oom killed",
+			backend: &exitBackend{result: backend.WorkloadResult{
+				ExitCode: 137, ExitReason: model.ExitReasonOOMKilled, ExitSignal: "SIGKILL",
+			}},
+			wantStatus: model.StatusCompleted,
+			wantReason: model.ExitReasonOOMKilled,
+			wantSignal: "SIGKILL",
+		},
+		{
+			name:       "vm crashed",
+			backend:    &exitBackend{err: fmt.Errorf("run workload: %w: EOF", backend.ErrVMCrashed)},
+			wantStatus: model.StatusFailed,
+			wantReason: model.ExitReasonVMCrashed,
+		},
+		{
+			name:       "agent unreachable",
+			backend:    &exitBackend{err: fmt.Errorf("connect to guest: %w: timed out", backend.ErrAgentUnreachable)},
+			wantStatus: model.StatusFailed,
+			wantReason: model.ExitReasonAgentUnreachable,
+		},
+		{
+			name:       "infra error",
+			backend:    &exitBackend{err: fmt.Errorf("start VM: no such file")},
+			wantStatus: model.StatusFailed,
+			wantReason: model.ExitReasonInfraError,
+		},
+		{
+			name:       "timeout",
+			backend:    &delayBackend{delay: 5 * time.Second},
+			wantStatus: model.StatusFailed,
+			wantReason: model.ExitReasonTimeout,
+		},
+		{
+			name: "exited at the deadline",
+			backend: &lateBackend{exitBackend{result: backend.WorkloadResult{
+				ExitReason: model.ExitReasonExited,
+			}}},
+			wantStatus: model.StatusCompleted,
+			wantReason: model.ExitReasonExited,
+		},
+		{
+			name:       "result after the deadline without a reason",
+			backend:    &lateBackend{exitBackend{result: backend.WorkloadResult{ExitCode: 143}}},
+			wantStatus: model.StatusFailed,
+			wantReason: model.ExitReasonTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng, s := newTestEngine(t, tt.backend)
+			before := exitsTotal(t, tt.wantReason)
+
+			w := makeAsyncWorkload()
+			timeout := 1
+			w.TimeoutS = &timeout
+			if err := eng.Submit(context.Background(), w); err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+
+			got := waitForStatus(t, s, w.ID, tt.wantStatus, 5*time.Second)
+			if got.ExitReason != tt.wantReason || got.ExitSignal != tt.wantSignal {
+				t.Errorf("ExitReason, ExitSignal = %q, %q, want %q, %q",
+					got.ExitReason, got.ExitSignal, tt.wantReason, tt.wantSignal)
+			}
+			if after := exitsTotal(t, tt.wantReason); after != before+1 {
+				t.Errorf("vulcan_workload_exits_total{reason=%q} = %v, want %v", tt.wantReason, after, before+1)
+			}
+		})
+	}
+}
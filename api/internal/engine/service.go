@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// ErrNotService is returned by Stop and Update for workloads that run to
+// completion rather than as services.
+var ErrNotService = errors.New("workload is not a service")
+
+// ServiceUpdate redeploys a running service. Code or CodeArchive, if set,
+// replace the service's code, and Service, if set, replaces its restart
+// policy and health check.
+type ServiceUpdate struct {
+	Code        string
+	CodeArchive []byte
+	Service     *model.ServiceSpec
+}
+
+// Stop shuts a running service down gracefully. The workload completes,
+// keeping the exit code of its process. Returns backend.ErrNotRunning if the
+// workload is not executing and ErrNotService if it is not a service.
+func (e *Engine) Stop(id string) error {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+	rw, ok := e.running[id]
+	if !ok {
+		return backend.ErrNotRunning
+	}
+	if !rw.service {
+		return ErrNotService
+	}
+	rw.stopping = true
+	rw.cancel()
+	return nil
+}
+
+// Update redeploys a running service: its process is stopped gracefully and
+// started again in a fresh sandbox with the update applied. A new service
+// spec is stored before Update returns. Returns backend.ErrNotRunning if the
+// workload is not executing and ErrNotService if it is not a service.
+func (e *Engine) Update(ctx context.Context, id string, u ServiceUpdate) error {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+	rw, ok := e.running[id]
+	if !ok || rw.stopping {
+		return backend.ErrNotRunning
+	}
+	if !rw.service {
+		return ErrNotService
+	}
+
+	if u.Service != nil {
+		if err := e.store.UpdateWorkloadService(ctx, id, u.Service); err != nil {
+			return fmt.Errorf("store service spec: %w", err)
+		}
+	}
+
+	// Updates that arrive before the redeploy starts are merged into one.
+	if rw.update == nil {
+		rw.update = &ServiceUpdate{}
+	}
+	if u.Code != "" || u.CodeArchive != nil {
+		rw.update.Code, rw.update.CodeArchive = u.Code, u.CodeArchive
+	}
+	if u.Service != nil {
+		rw.update.Service = u.Service
+	}
+	rw.cancel()
+	return nil
+}
+
+// takeUpdate returns the update pending for a service whose execution has
+// ended, and arms a new context for its redeploy. It returns nil if the
+// service has no pending update or is being stopped.
+func (e *Engine) takeUpdate(rw *runningWorkload) (*ServiceUpdate, context.Context) {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+	u := rw.update
+	rw.update = nil
+	if u == nil || rw.stopping {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	rw.cancel = cancel
+	return u, ctx
+}
+
+// apply applies u to the spec and workload of a service being redeployed.
+func (u *ServiceUpdate) apply(spec *backend.WorkloadSpec, w *model.Workload) {
+	if u.Code != "" || u.CodeArchive != nil {
+		spec.Code, spec.CodeArchive = u.Code, u.CodeArchive
+		w.Code, w.CodeArchive = u.Code, u.CodeArchive
+	}
+	if u.Service != nil {
+		spec.Service = u.Service
+		w.Service = u.Service
+	}
+}
@@ -0,0 +1,200 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/engine"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// serviceBackend runs services until they are cancelled, reporting them
+// healthy once started and sending the spec of each run on specs.
+type serviceBackend struct {
+	delayBackend
+	specs chan backend.WorkloadSpec
+}
+
+func (sb *serviceBackend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	spec.OnHealth(backend.HealthReport{Status: model.HealthHealthy})
+	sb.specs <- spec
+	<-ctx.Done()
+	return backend.WorkloadResult{ExitCode: 143, Error: "cancelled by host"}, nil
+}
+
+func (sb *serviceBackend) Capabilities() backend.BackendCapabilities {
+	caps := sb.delayBackend.Capabilities()
+	caps.Services = true
+	return caps
+}
+
+func makeServiceWorkload() *model.Workload {
+	w := makeAsyncWorkload()
+	w.Kind = model.KindService
+	w.TimeoutS = nil
+	w.Code = "v1"
+	w.Service = &model.ServiceSpec{Restart: model.RestartPolicy{Policy: model.RestartAlways}}
+	return w
+}
+
+func TestServiceRunsWithoutTimeout(t *testing.T) {
+	b := &serviceBackend{specs: make(chan backend.WorkloadSpec, 1)}
+	eng, s := newTestEngine(t, b)
+
+	w := makeServiceWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	spec := <-b.specs
+	if spec.TimeoutS != 0 || spec.Service == nil {
+		t.Errorf("spec timeout %d service %+v, want no timeout and the service spec", spec.TimeoutS, spec.Service)
+	}
+
+	got, err := s.GetWorkload(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Status != model.StatusRunning || got.Health != model.HealthHealthy {
+		t.Errorf("status %q health %q, want running and healthy", got.Status, got.Health)
+	}
+
+	if err := eng.Stop(w.ID); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	eng.Wait()
+
+	got, err = s.GetWorkload(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Status != model.StatusCompleted || got.Error != "" {
+		t.Errorf("status %q error %q, want a clean completion", got.Status, got.Error)
+	}
+	if got.ExitCode == nil || *got.ExitCode != 143 {
+		t.Errorf("exit code = %v, want the process's 143", got.ExitCode)
+	}
+	if got.Health != "" {
+		t.Errorf("health = %q, want it cleared once finished", got.Health)
+	}
+	if err := eng.Stop(w.ID); !errors.Is(err, backend.ErrNotRunning) {
+		t.Errorf("Stop after completion = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestServiceUpdateRedeploys(t *testing.T) {
+	b := &serviceBackend{specs: make(chan backend.WorkloadSpec, 1)}
+	eng, s := newTestEngine(t, b)
+
+	w := makeServiceWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	<-b.specs
+
+	svc := &model.ServiceSpec{Restart: model.RestartPolicy{Policy: model.RestartOnFailure, MaxRestarts: 3}}
+	if err := eng.Update(context.Background(), w.ID, engine.ServiceUpdate{Code: "v2", Service: svc}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	spec := <-b.specs
+	if spec.Code != "v2" || spec.Service.Restart.Policy != model.RestartOnFailure {
+		t.Errorf("redeployed code %q policy %q, want the update applied", spec.Code, spec.Service.Restart.Policy)
+	}
+
+	got, err := s.GetWorkload(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Status != model.StatusRunning || got.Service.Restart.MaxRestarts != 3 {
+		t.Errorf("status %q service %+v, want still running with the new spec stored", got.Status, got.Service)
+	}
+	events, err := s.ListHealthEvents(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("ListHealthEvents: %v", err)
+	}
+	var details []string
+	for _, ev := range events {
+		details = append(details, ev.Status+" "+ev.Detail)
+	}
+	want := []string{"healthy ", "starting updated", "healthy "}
+	if len(details) != len(want) {
+		t.Fatalf("health events = %q, want %q", details, want)
+	}
+	for i := range want {
+		if details[i] != want[i] {
+			t.Errorf("health events = %q, want %q", details, want)
+			break
+		}
+	}
+
+	eng.Stop(w.ID)
+	eng.Wait()
+}
+
+func TestServiceKillKeepsKilledStatus(t *testing.T) {
+	b := &serviceBackend{specs: make(chan backend.WorkloadSpec, 1)}
+	eng, s := newTestEngine(t, b)
+
+	w := makeServiceWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	<-b.specs
+
+	if err := s.UpdateWorkloadStatus(context.Background(), w.ID, model.StatusKilled); err != nil {
+		t.Fatalf("UpdateWorkloadStatus: %v", err)
+	}
+	if !eng.Cancel(w.ID) {
+		t.Fatal("Cancel returned false for running service")
+	}
+	eng.Wait()
+
+	got, err := s.GetWorkload(context.Background(), w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Status != model.StatusKilled {
+		t.Errorf("status = %q, want killed", got.Status)
+	}
+}
+
+func TestStopAndUpdateRejectJobs(t *testing.T) {
+	b := &gracefulBackend{signals: make(chan string, 1)}
+	eng, _ := newTestEngine(t, b)
+
+	w := makeAsyncWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if err := waitForSignalable(t, eng, w.ID); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+
+	if err := eng.Stop(w.ID); !errors.Is(err, engine.ErrNotService) {
+		t.Errorf("Stop = %v, want ErrNotService", err)
+	}
+	if err := eng.Update(context.Background(), w.ID, engine.ServiceUpdate{Code: "x"}); !errors.Is(err, engine.ErrNotService) {
+		t.Errorf("Update = %v, want ErrNotService", err)
+	}
+	if err := eng.Update(context.Background(), "nonexistent", engine.ServiceUpdate{}); !errors.Is(err, backend.ErrNotRunning) {
+		t.Errorf("Update of unknown workload = %v, want ErrNotRunning", err)
+	}
+
+	eng.Cancel(w.ID)
+	eng.Wait()
+}
+
+func TestServiceUnsupportedBackend(t *testing.T) {
+	eng, s := newTestEngine(t, &delayBackend{})
+
+	w := makeServiceWorkload()
+	if err := eng.Submit(context.Background(), w); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	got := waitForStatus(t, s, w.ID, model.StatusFailed, 5*time.Second)
+	if got.Error != "backend delay cannot run services" {
+		t.Errorf("error = %q, want the unsupported-services error", got.Error)
+	}
+}
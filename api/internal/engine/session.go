@@ -0,0 +1,330 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// ErrSessionClosed is returned by Exec and CloseSession for sessions that
+// are not open.
+var ErrSessionClosed = errors.New("session is closed")
+
+// runningSession tracks an open session.
+type runningSession struct {
+	sess   model.Session
+	ctx    context.Context
+	cancel context.CancelFunc
+	execs  chan backend.ExecRequest
+
+	// ready is closed once the sandbox can run executions, and done once
+	// it has stopped.
+	ready chan struct{}
+	done  chan struct{}
+
+	// execMu serializes the session's executions. idle closes the session
+	// once it has been idle for its idle timeout; it is stopped while an
+	// execution runs.
+	execMu sync.Mutex
+	idle   *time.Timer
+
+	// reason is why the session is being closed; guarded by Engine.sessMu.
+	reason string
+}
+
+// StartSession stores a session and boots its sandbox in a goroutine. The
+// session is stored as starting and becomes ready once the sandbox is; it
+// is closed by CloseSession, once it has been idle for its idle timeout, or
+// once it reaches its maximum lifetime.
+func (e *Engine) StartSession(ctx context.Context, sess *model.Session) error {
+	if err := e.store.CreateSession(ctx, sess); err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+
+	sessCtx, cancel := context.WithTimeout(context.Background(), time.Duration(sess.MaxLifetimeS)*time.Second)
+	rs := &runningSession{
+		sess:   *sess,
+		ctx:    sessCtx,
+		cancel: cancel,
+		execs:  make(chan backend.ExecRequest),
+		ready:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	e.sessMu.Lock()
+	e.sessions[sess.ID] = rs
+	e.sessMu.Unlock()
+
+	e.wg.Go(func() { e.runSession(rs) })
+	return nil
+}
+
+// runSession runs a session's sandbox until the session is closed, then
+// records how it ended.
+func (e *Engine) runSession(rs *runningSession) {
+	sess := &rs.sess
+	defer func() {
+		e.sessMu.Lock()
+		delete(e.sessions, sess.ID)
+		e.sessMu.Unlock()
+		rs.cancel()
+		close(rs.done)
+	}()
+
+	err := e.bootSession(rs)
+
+	e.sessMu.Lock()
+	reason := rs.reason
+	e.sessMu.Unlock()
+	if reason == "" && errors.Is(rs.ctx.Err(), context.DeadlineExceeded) {
+		reason = model.SessionClosedLifetime
+	}
+
+	status, errMsg := model.SessionClosed, ""
+	if err != nil && reason == "" {
+		status, errMsg = model.SessionFailed, err.Error()
+		e.logger.Warn("session failed", "session_id", sess.ID, "error", err)
+	} else {
+		e.logger.Info("session closed", "session_id", sess.ID, "reason", reason)
+	}
+	if err := e.store.FinishSession(context.Background(), sess.ID, status, reason, errMsg); err != nil {
+		e.logger.Error("failed to finish session", "session_id", sess.ID, "error", err)
+	}
+}
+
+// bootSession resolves a session's backend and runs its sandbox.
+func (e *Engine) bootSession(rs *runningSession) error {
+	sess := &rs.sess
+	img, isolation, err := e.resolveImage(sess.ID, sess.Runtime, sess.Isolation, time.Now())
+	if err != nil {
+		return err
+	}
+	b, err := e.registry.Resolve(isolation, sess.Runtime)
+	if err != nil {
+		return fmt.Errorf("resolve backend: %w", err)
+	}
+	caps := b.Capabilities()
+	if !caps.Sessions {
+		return fmt.Errorf("backend %s cannot run sessions", caps.Name)
+	}
+	if mode := sess.Network.EffectiveMode(); sess.Network != nil && !slices.Contains(caps.NetworkModes, mode) {
+		return fmt.Errorf("backend %s cannot enforce network mode %q", caps.Name, mode)
+	}
+	if img != nil && !caps.Images {
+		return fmt.Errorf("backend %s cannot run catalog images", caps.Name)
+	}
+
+	spec := backend.WorkloadSpec{
+		ID:        sess.ID,
+		Runtime:   sess.Runtime,
+		Isolation: sess.Isolation,
+		Network:   sess.Network,
+		Image:     img,
+		Session: &backend.SessionSpec{
+			Interpreter: sess.Interpreter,
+			Execs:       rs.execs,
+			OnReady: func() {
+				if err := e.store.MarkSessionReady(context.Background(), sess.ID); err != nil {
+					e.logger.Error("failed to mark session ready", "session_id", sess.ID, "error", err)
+				}
+				rs.idle = time.AfterFunc(time.Duration(sess.IdleTimeoutS)*time.Second, func() {
+					e.closeSession(rs, model.SessionClosedIdle)
+				})
+				close(rs.ready)
+			},
+		},
+	}
+	if sess.CPULimit != nil {
+		spec.CPULimit = *sess.CPULimit
+	}
+	if sess.MemLimit != nil {
+		spec.MemLimitMB = *sess.MemLimit
+	}
+
+	_, err = b.Execute(rs.ctx, spec)
+	if rs.idle != nil {
+		rs.idle.Stop()
+	}
+	return err
+}
+
+// closeSession closes a session for reason, unless it is already closing.
+func (e *Engine) closeSession(rs *runningSession, reason string) {
+	e.sessMu.Lock()
+	if rs.reason == "" {
+		rs.reason = reason
+	}
+	e.sessMu.Unlock()
+	rs.cancel()
+}
+
+// CloseSession closes an open session, stopping any execution running in
+// it. Returns ErrSessionClosed if the session is not open.
+func (e *Engine) CloseSession(id string) error {
+	e.sessMu.Lock()
+	rs, ok := e.sessions[id]
+	e.sessMu.Unlock()
+	if !ok {
+		return ErrSessionClosed
+	}
+	e.closeSession(rs, model.SessionClosedByClient)
+	return nil
+}
+
+// Exec runs w, whose code and timeout are set, as an execution in
+// an open session, waiting for the session to be ready and for earlier
+// executions to finish first. The execution is stored as a workload of the
+// session, with its logs and result, and can be killed like any other.
+// Returns ErrSessionClosed if the session is not open or closes before the
+// execution starts.
+func (e *Engine) Exec(ctx context.Context, sessionID string, w *model.Workload) error {
+	e.sessMu.Lock()
+	rs, ok := e.sessions[sessionID]
+	e.sessMu.Unlock()
+	if !ok {
+		return ErrSessionClosed
+	}
+
+	select {
+	case <-rs.ready:
+	case <-rs.done:
+		return ErrSessionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	rs.execMu.Lock()
+	defer rs.execMu.Unlock()
+	if rs.ctx.Err() != nil {
+		return ErrSessionClosed
+	}
+	rs.idle.Stop()
+	defer rs.idle.Reset(time.Duration(rs.sess.IdleTimeoutS) * time.Second)
+
+	w.SessionID = sessionID
+	w.Runtime = rs.sess.Runtime
+	w.Isolation = rs.sess.Isolation
+	w.Kind = model.KindJob
+	w.Status = model.StatusPending
+	if err := e.store.CreateWorkload(ctx, w); err != nil {
+		return fmt.Errorf("create workload: %w", err)
+	}
+	e.runExec(rs, w)
+	return nil
+}
+
+// runExec runs a stored execution in a session and records its outcome.
+func (e *Engine) runExec(rs *runningSession, w *model.Workload) {
+	defer e.broker.Close(w.ID)
+	defer func() {
+		if err := e.store.RecordSessionExec(context.Background(), rs.sess.ID, time.Now().UTC()); err != nil {
+			e.logger.Error("failed to record session exec", "session_id", rs.sess.ID, "error", err)
+		}
+	}()
+
+	if err := e.store.UpdateWorkloadStatus(context.Background(), w.ID, model.StatusRunning); err != nil {
+		e.logger.Error("failed to transition to running", "workload_id", w.ID, "error", err)
+		e.finishFailed(w.ID, nil, model.ExitReasonInfraError, fmt.Sprintf("failed to start: %v", err))
+		return
+	}
+	start := time.Now()
+
+	timeoutS := DefaultTimeoutS
+	if w.TimeoutS != nil && *w.TimeoutS > 0 {
+		timeoutS = *w.TimeoutS
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutS)*time.Second)
+	defer cancel()
+	defer e.track(w.ID, &runningWorkload{cancel: cancel})()
+
+	var seq atomic.Int32
+	results := make(chan backend.ExecResult, 1)
+	x := backend.ExecRequest{
+		ID:          w.ID,
+		Ctx:         ctx,
+		Code:        w.Code,
+		CodeArchive: w.CodeArchive,
+		TimeoutS:    timeoutS,
+		LogWriter: func(stream, line string) {
+			currentSeq := int(seq.Add(1) - 1)
+			if err := e.store.InsertLogLine(context.Background(), w.ID, currentSeq, stream, line); err != nil {
+				e.logger.Error("failed to persist log line", "workload_id", w.ID, "seq", currentSeq, "error", err)
+			}
+			e.broker.Publish(w.ID, stream, line)
+		},
+		Result: results,
+	}
+	if w.GraceS != nil {
+		x.GraceS = *w.GraceS
+	}
+
+	var res backend.ExecResult
+	select {
+	case rs.execs <- x:
+		select {
+		case res = <-results:
+		case <-rs.done:
+			select {
+			case res = <-results:
+			default:
+				res.Err = ErrSessionClosed
+			}
+		}
+	case <-rs.done:
+		res.Err = ErrSessionClosed
+	}
+
+	deadlinePassed := errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+	// A killed execution has already been marked killed; keep that status.
+	cancelled := errors.Is(ctx.Err(), context.Canceled)
+	if res.Err != nil && cancelled {
+		e.logger.Info("session exec cancelled", "workload_id", w.ID, "error", res.Err)
+		return
+	}
+	if res.Err != nil {
+		errMsg := res.Err.Error()
+		if deadlinePassed {
+			errMsg = fmt.Sprintf("workload timed out after %ds", timeoutS)
+		}
+		e.finishFailed(w.ID, &start, failureReason(deadlinePassed, res.Err), errMsg)
+		return
+	}
+
+	now := time.Now().UTC()
+	dur := int(time.Since(start).Milliseconds())
+	if res.Result.DurationMS > 0 {
+		dur = res.Result.DurationMS
+	}
+	status := model.StatusCompleted
+	if cancelled {
+		status = model.StatusKilled
+	}
+	completed := &model.Workload{
+		ID:         w.ID,
+		Status:     status,
+		Output:     res.Result.Output,
+		Stdout:     res.Result.Stdout,
+		Stderr:     res.Result.Stderr,
+		ExitCode:   &res.Result.ExitCode,
+		Error:      res.Result.Error,
+		ExitReason: resultReason(res.Result),
+		ExitSignal: res.Result.ExitSignal,
+		DurationMS: &dur,
+		Usage:      res.Result.Usage,
+		StartedAt:  &start,
+		FinishedAt: &now,
+	}
+	if timedOut(res.Result, deadlinePassed) {
+		markTimedOut(completed, timeoutS)
+	}
+	workloadExitsTotal.WithLabelValues(completed.ExitReason).Inc()
+	if err := e.store.UpdateWorkload(context.Background(), completed); err != nil {
+		e.logger.Error("failed to update completed workload", "workload_id", w.ID, "error", err)
+	}
+}
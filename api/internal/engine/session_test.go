@@ -0,0 +1,210 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	"github.com/seantiz/vulcan/internal/engine"
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// sessionBackend runs sessions whose executions answer with their code and
+// the number of executions before them, logging the code. Code "block"
+// runs until its execution is cancelled.
+type sessionBackend struct {
+	delayBackend
+}
+
+func (sb *sessionBackend) Execute(ctx context.Context, spec backend.WorkloadSpec) (backend.WorkloadResult, error) {
+	spec.Session.OnReady()
+	n := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return backend.WorkloadResult{}, nil
+		case x := <-spec.Session.Execs:
+			if x.Code == "block" {
+				<-x.Ctx.Done()
+				x.Result <- backend.ExecResult{Err: x.Ctx.Err()}
+				continue
+			}
+			x.LogWriter(model.LogStreamStdout, x.Code)
+			x.Result <- backend.ExecResult{Result: backend.WorkloadResult{Output: fmt.Appendf(nil, "%s %d", x.Code, n)}}
+			n++
+		}
+	}
+}
+
+func (sb *sessionBackend) Capabilities() backend.BackendCapabilities {
+	caps := sb.delayBackend.Capabilities()
+	caps.Sessions = true
+	return caps
+}
+
+func makeSession() *model.Session {
+	sess := model.Session{
+		ID:        model.NewID(),
+		Status:    model.SessionStarting,
+		Isolation: model.IsolationIsolate,
+		Runtime:   model.RuntimeNode,
+		CreatedAt: time.Now().UTC(),
+	}.WithDefaults()
+	return &sess
+}
+
+func makeExec(code string) *model.Workload {
+	return &model.Workload{ID: model.NewID(), Code: code, CreatedAt: time.Now().UTC()}
+}
+
+// waitForSession polls the store until the session reaches status.
+func waitForSession(t *testing.T, s store.Store, id, status string) *model.Session {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		sess, err := s.GetSession(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetSession: %v", err)
+		}
+		if sess.Status == status {
+			return sess
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("session %s did not reach status %q", id, status)
+	return nil
+}
+
+func TestSessionExecs(t *testing.T) {
+	eng, s := newTestEngine(t, &sessionBackend{})
+	ctx := context.Background()
+
+	sess := makeSession()
+	if err := eng.StartSession(ctx, sess); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	for i, code := range []string{"a", "b"} {
+		w := makeExec(code)
+		if err := eng.Exec(ctx, sess.ID, w); err != nil {
+			t.Fatalf("Exec: %v", err)
+		}
+		got, err := s.GetWorkload(ctx, w.ID)
+		if err != nil {
+			t.Fatalf("GetWorkload: %v", err)
+		}
+		want := fmt.Sprintf("%s %d", code, i)
+		if got.Status != model.StatusCompleted || string(got.Output) != want || got.SessionID != sess.ID {
+			t.Errorf("exec = %q %q session %q, want completed with %q in the session", got.Status, got.Output, got.SessionID, want)
+		}
+		if got.Runtime != sess.Runtime {
+			t.Errorf("exec runtime = %q, want the session's %q", got.Runtime, sess.Runtime)
+		}
+		logs, err := s.GetLogLines(ctx, w.ID)
+		if err != nil || len(logs) != 1 || logs[0].Line != code {
+			t.Errorf("exec logs = %+v (%v), want its own line", logs, err)
+		}
+	}
+
+	got := waitForSession(t, s, sess.ID, model.SessionReady)
+	if got.Execs != 2 || got.LastExecAt == nil {
+		t.Errorf("session execs %d last exec %v, want 2 recorded", got.Execs, got.LastExecAt)
+	}
+
+	if err := eng.CloseSession(sess.ID); err != nil {
+		t.Fatalf("CloseSession: %v", err)
+	}
+	eng.Wait()
+	got = waitForSession(t, s, sess.ID, model.SessionClosed)
+	if got.CloseReason != model.SessionClosedByClient {
+		t.Errorf("close reason = %q, want %q", got.CloseReason, model.SessionClosedByClient)
+	}
+	if err := eng.Exec(ctx, sess.ID, makeExec("c")); !errors.Is(err, engine.ErrSessionClosed) {
+		t.Errorf("Exec after close = %v, want ErrSessionClosed", err)
+	}
+	if err := eng.CloseSession(sess.ID); !errors.Is(err, engine.ErrSessionClosed) {
+		t.Errorf("second CloseSession = %v, want ErrSessionClosed", err)
+	}
+}
+
+func TestSessionKillExec(t *testing.T) {
+	eng, s := newTestEngine(t, &sessionBackend{})
+	ctx := context.Background()
+
+	sess := makeSession()
+	if err := eng.StartSession(ctx, sess); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	w := makeExec("block")
+	done := make(chan error, 1)
+	go func() { done <- eng.Exec(ctx, sess.ID, w) }()
+	waitForSession(t, s, sess.ID, model.SessionReady)
+	for {
+		if got, err := s.GetWorkload(ctx, w.ID); err == nil && got.Status == model.StatusRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := s.UpdateWorkloadStatus(ctx, w.ID, model.StatusKilled); err != nil {
+		t.Fatalf("UpdateWorkloadStatus: %v", err)
+	}
+	if !eng.Cancel(w.ID) {
+		t.Fatal("Cancel returned false for a running exec")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if got, _ := s.GetWorkload(ctx, w.ID); got.Status != model.StatusKilled {
+		t.Errorf("killed exec status = %q, want killed", got.Status)
+	}
+
+	// The session outlives its killed execution.
+	next := makeExec("after")
+	if err := eng.Exec(ctx, sess.ID, next); err != nil {
+		t.Fatalf("Exec after kill: %v", err)
+	}
+	if got, _ := s.GetWorkload(ctx, next.ID); got.Status != model.StatusCompleted {
+		t.Errorf("next exec status = %q, want completed", got.Status)
+	}
+
+	eng.CloseSession(sess.ID)
+	eng.Wait()
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	eng, s := newTestEngine(t, &sessionBackend{})
+
+	sess := makeSession()
+	sess.IdleTimeoutS = 1
+	if err := eng.StartSession(context.Background(), sess); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	eng.Wait()
+
+	got := waitForSession(t, s, sess.ID, model.SessionClosed)
+	if got.CloseReason != model.SessionClosedIdle || got.FinishedAt == nil {
+		t.Errorf("session = %q %v, want closed for idleness", got.CloseReason, got.FinishedAt)
+	}
+}
+
+func TestSessionUnsupportedBackend(t *testing.T) {
+	eng, s := newTestEngine(t, &delayBackend{})
+
+	sess := makeSession()
+	if err := eng.StartSession(context.Background(), sess); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := eng.Exec(context.Background(), sess.ID, makeExec("x")); !errors.Is(err, engine.ErrSessionClosed) {
+		t.Errorf("Exec = %v, want ErrSessionClosed", err)
+	}
+	eng.Wait()
+
+	got := waitForSession(t, s, sess.ID, model.SessionFailed)
+	if got.Error != "backend delay cannot run sessions" {
+		t.Errorf("error = %q, want the unsupported-sessions error", got.Error)
+	}
+}
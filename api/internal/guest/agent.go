@@ -10,6 +10,8 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,41 +19,64 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
 )
 
-// defaultEntrypoints maps each runtime to its default entrypoint filename.
-var defaultEntrypoints = map[string]string{
-	"go":     "main.go",
-	"node":   "index.js",
-	"python": "main.py",
-}
-
-// runtimeCommands maps each runtime to the command used to execute code.
-var runtimeCommands = map[string]struct {
-	bin  string
-	args func(entrypoint string) []string
-}{
-	"go":     {bin: "go", args: func(ep string) []string { return []string{"run", ep} }},
-	"node":   {bin: "node", args: func(ep string) []string { return []string{ep} }},
-	"python": {bin: "python3", args: func(ep string) []string { return []string{ep} }},
-}
-
-// Agent handles vsock connections and executes workloads.
+// Agent handles vsock connections and executes workloads. Each connection
+// carries one request, run in its own work directory under workDir and its
+// own process group, so that requests on separate connections can run at
+// once.
 type Agent struct {
 	listener net.Listener
 	workDir  string
+
+	// runtimes are the runtimes declared by the rootfs's runtime manifest.
+	runtimes fc.RuntimeManifest
+
+	// slots holds a token for each request being executed; requests beyond
+	// its capacity wait for one to finish.
+	slots chan struct{}
+
+	// depsDir and mirrorDir are where the dependency layer and the package
+	// mirror are mounted, by mount and unmount.
+	depsDir   string
+	mirrorDir string
+	mount     func(device, target string, readOnly bool) error
+	unmount   func(target string) error
+
+	// scratchDir is where the scratch disk is mounted; its directories are
+	// bound onto tmpDir and workDir by bindMount.
+	scratchDir string
+	tmpDir     string
+	bindMount  func(source, target string) error
+
+	// interpreters holds the persistent interpreters of session executions,
+	// by runtime. interpMu also serializes the executions using them.
+	interpMu     sync.Mutex
+	interpreters map[string]*interpreter
 }
 
 // New creates a new guest agent with the given listener and work directory.
 func New(listener net.Listener, workDir string) *Agent {
 	return &Agent{
-		listener: listener,
-		workDir:  workDir,
+		listener:  listener,
+		workDir:   workDir,
+		runtimes:  loadRuntimes(runtimeManifestPath),
+		slots:     make(chan struct{}, DefaultMaxExecs),
+		depsDir:   defaultDepsDir,
+		mirrorDir: defaultMirrorDir,
+		mount:     mountExt4,
+		unmount:   unmount,
+
+		scratchDir: defaultScratchDir,
+		tmpDir:     defaultTmpDir,
+		bindMount:  bindMount,
 	}
 }
 
@@ -71,35 +96,98 @@ func (a *Agent) Serve() error {
 func (a *Agent) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
-	var req fc.GuestRequest
-	if err := fc.ReadMessage(conn, &req); err != nil {
+	req, err := a.readRequest(conn)
+	if err != nil {
 		log.Printf("read request: %v", err)
-		sendResult(conn, fc.GuestResponse{
+		newSession(conn, false, false).sendResult(fc.GuestResponse{
 			ExitCode: 1,
 			Error:    fmt.Sprintf("read request: %v", err),
 		})
 		return
 	}
 
-	resp := a.executeWorkload(conn, &req)
-	sendResult(conn, resp)
+	s := newSession(conn, req.StreamInput, req.Build != nil && req.Build.Prebuilt)
+	go s.readLoop()
+	if req.HeartbeatMS > 0 {
+		s.startHeartbeats(time.Duration(req.HeartbeatMS) * time.Millisecond)
+	}
+
+	release, err := a.acquireSlot(s)
+	if err != nil {
+		s.sendResult(fc.GuestResponse{ExitCode: 1, Error: err.Error()})
+		return
+	}
+	defer release()
+
+	resp := a.executeWorkload(s, &req)
+	s.sendResult(resp)
 }
 
-// executeWorkload runs the workload described by req, streaming log lines to conn.
-func (a *Agent) executeWorkload(conn net.Conn, req *fc.GuestRequest) fc.GuestResponse {
-	// Validate runtime.
-	rtCmd, ok := runtimeCommands[req.Runtime]
-	if !ok {
-		return fc.GuestResponse{
-			ExitCode: 1,
-			Error:    fmt.Sprintf("unsupported runtime: %q", req.Runtime),
+// readRequest reads the workload request from conn. Hosts that support the
+// handshake send a hello first, which is answered with the agent's
+// capabilities before the request is read; older hosts send the request
+// directly.
+func (a *Agent) readRequest(conn net.Conn) (fc.GuestRequest, error) {
+	var req fc.GuestRequest
+
+	frame, err := fc.ReadFrame(conn)
+	if err != nil {
+		return req, err
+	}
+
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(frame, &envelope); err != nil {
+		return req, fmt.Errorf("unmarshal message: %w", err)
+	}
+
+	if envelope.Type == fc.MsgTypeHello {
+		info := a.hello()
+		if err := fc.WriteMessage(conn, &fc.GuestMessage{Type: fc.MsgTypeHello, Hello: &info}); err != nil {
+			return req, fmt.Errorf("write hello: %w", err)
+		}
+		if frame, err = fc.ReadFrame(conn); err != nil {
+			return req, err
+		}
+	}
+
+	if err := json.Unmarshal(frame, &req); err != nil {
+		return req, fmt.Errorf("unmarshal message: %w", err)
+	}
+	return req, nil
+}
+
+// executeWorkload runs the workload described by req, streaming log lines to the host.
+func (a *Agent) executeWorkload(s *session, req *fc.GuestRequest) fc.GuestResponse {
+	// Validate runtime. Catalog images declare their own command instead.
+	var bin string
+	var args func(entrypoint string) []string
+	var rt fc.RuntimeDef
+	if len(req.Command) > 0 {
+		bin = req.Command[0]
+		args = func(ep string) []string { return append(slices.Clone(req.Command[1:]), ep) }
+	} else {
+		var ok bool
+		if rt, ok = a.runtimes.Lookup(req.Runtime); !ok {
+			return fc.GuestResponse{
+				ExitCode: 1,
+				Error:    fmt.Sprintf("unsupported runtime: %q", req.Runtime),
+			}
 		}
+		bin, args = rt.Bin, rt.Command
 	}
 
 	// Determine entrypoint.
 	entrypoint := req.Entrypoint
 	if entrypoint == "" {
-		entrypoint = defaultEntrypoints[req.Runtime]
+		entrypoint = rt.DefaultEntrypoint()
+	}
+	if entrypoint == "" {
+		return fc.GuestResponse{
+			ExitCode: 1,
+			Error:    "entrypoint is required",
+		}
 	}
 
 	// Validate entrypoint stays within work directory (path traversal guard).
@@ -110,34 +198,134 @@ func (a *Agent) executeWorkload(conn net.Conn, req *fc.GuestRequest) fc.GuestRes
 		}
 	}
 
-	// Extract code to work directory (cleans and recreates it).
-	if err := a.extractCode(req.Code, entrypoint); err != nil {
+	// Put /tmp and the work directory on the scratch disk, if any, so that
+	// the workload can write no more than its size.
+	var sc *scratch
+	if req.ScratchDevice != "" {
+		var err error
+		if sc, err = a.attachScratch(req.ScratchDevice); err != nil {
+			return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("scratch disk: %v", err)}
+		}
+		defer a.detachScratch(sc)
+	}
+
+	// Extract code to the request's work directory. Executions in a session
+	// write over the files left by the ones before them.
+	dir, err := a.newWorkDir(req)
+	if err != nil {
+		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("create work dir: %v", err)}
+	}
+	if req.Session == nil {
+		defer os.RemoveAll(dir)
+	}
+	s.workDir = dir
+	if err := extractCode(dir, req.Code, req.CodeArchive, entrypoint); err != nil {
 		return fc.GuestResponse{
 			ExitCode: 1,
 			Error:    fmt.Sprintf("extract code: %v", err),
 		}
 	}
 
-	// Build command with timeout.
+	// Mount persistent volumes; they are unmounted before the result is
+	// reported.
+	volumes, err := a.attachVolumes(req.Volumes)
+	if err != nil {
+		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("mount volumes: %v", err)}
+	}
+	defer a.detachVolumes(volumes)
+
+	// Build command with timeout. Services run until the host stops them.
 	timeout := time.Duration(req.TimeoutS) * time.Second
 	if timeout == 0 {
 		timeout = 30 * time.Second
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if req.Service != nil {
+		cancel()
+		ctx, cancel = context.WithCancel(context.Background())
+	}
 	defer cancel()
+	grace := time.Duration(req.GraceMS) * time.Millisecond
+	if grace <= 0 {
+		grace = defaultGrace
+	}
 
-	entrypointPath := filepath.Join(a.workDir, entrypoint)
-	cmd := exec.CommandContext(ctx, rtCmd.bin, rtCmd.args(entrypointPath)...)
-	cmd.Dir = a.workDir
+	// Attach the dependency layer, installing into it first on a cache miss.
+	// The install counts against the workload's timeout.
+	var depsEnv []string
+	if req.Deps != nil {
+		var err error
+		if depsEnv, err = a.attachDeps(ctx, s, req); err != nil {
+			errMsg := fmt.Sprintf("dependencies: %v", err)
+			if ctx.Err() == context.DeadlineExceeded {
+				errMsg = fmt.Sprintf("timeout after %s installing dependencies", timeout)
+			}
+			return fc.GuestResponse{ExitCode: 1, Error: errMsg}
+		}
+	}
+	depsInstalled := req.Deps != nil && req.Deps.Install
+
+	// Compiled code runs as a program, either sent by the host or compiled
+	// here and sent back before it runs, so that the workload cannot tamper
+	// with the copy the host caches.
+	built := false
+	if req.Build != nil && len(req.Command) == 0 {
+		program, resp := a.obtainProgram(ctx, s, req, entrypoint)
+		if program == "" {
+			if ctx.Err() == context.DeadlineExceeded {
+				resp.Error = fmt.Sprintf("timeout after %s compiling code", timeout)
+			}
+			return resp
+		}
+		defer os.Remove(program)
+		bin, args = program, func(string) []string { return nil }
+		built = !req.Build.Prebuilt
+	}
 
-	// Set environment.
-	cmd.Env = os.Environ()
+	entrypointPath := filepath.Join(dir, entrypoint)
+	env := os.Environ()
+	for k, v := range rt.Env {
+		env = append(env, k+"="+v)
+	}
+	env = append(env, depsEnv...)
 	for k, v := range req.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+		env = append(env, k+"="+v)
 	}
 
-	// Pipe input if provided.
-	if len(req.Input) > 0 {
+	if req.Service != nil {
+		resp := a.runService(s, req.Service, bin, args(entrypointPath), env)
+		if resp.Usage != nil && sc != nil {
+			resp.Usage.DiskUsedBytes = sc.used()
+		}
+		resp.DepsInstalled, resp.Built = depsInstalled, built
+		return resp
+	}
+	if req.Session != nil && req.Session.Interpreter {
+		return a.runInterpreted(ctx, s, req, entrypointPath, env, timeout, grace)
+	}
+
+	cmd := exec.Command(bin, args(entrypointPath)...)
+	cmd.Dir = dir
+
+	// Run the workload in its own process group so that timeouts, cancels
+	// and signals reach every process it spawned.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cg := newWorkloadCgroup(cgroupRoot)
+	defer cg.remove()
+	cg.apply(cmd.SysProcAttr)
+
+	cmd.Env = env
+
+	// Pipe input if provided. Streamed input is copied from chunk frames as
+	// they arrive rather than being buffered up front.
+	var stdinPipe io.WriteCloser
+	if s.input != nil {
+		var err error
+		stdinPipe, err = cmd.StdinPipe()
+		if err != nil {
+			return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("stdin pipe: %v", err)}
+		}
+	} else if len(req.Input) > 0 {
 		cmd.Stdin = bytes.NewReader(req.Input)
 	}
 
@@ -151,37 +339,72 @@ func (a *Agent) executeWorkload(conn net.Conn, req *fc.GuestRequest) fc.GuestRes
 		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("stderr pipe: %v", err)}
 	}
 
+	output, err := newOutputBuffer(req.StreamOutput)
+	if err != nil {
+		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("stdout buffer: %v", err)}
+	}
+	defer output.Close()
+	stderrBuf, err := newOutputBuffer(req.StreamOutput)
+	if err != nil {
+		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("stderr buffer: %v", err)}
+	}
+	defer stderrBuf.Close()
+
+	if s.proc.isCancelled() {
+		return fc.GuestResponse{ExitCode: 1, Error: cancelledMessage}
+	}
+	oomBefore := oomKills()
 	if err := cmd.Start(); err != nil {
 		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("start command: %v", err)}
 	}
-
-	// Mutex protects concurrent writes to conn from stdout/stderr goroutines.
-	var writeMu sync.Mutex
+	s.proc.started(cmd.Process.Pid)
+
+	// A workload that runs out of time is terminated as a cancelled one is,
+	// its output still streaming to the host until it has exited.
+	stopTimeout := context.AfterFunc(ctx, func() { s.proc.terminate(grace) })
+	defer stopTimeout()
+
+	if stdinPipe != nil {
+		go func() {
+			defer stdinPipe.Close()
+			if _, err := io.Copy(stdinPipe, s.input); err != nil && !errors.Is(err, fc.ErrStreamAborted) {
+				log.Printf("copy streamed input: %v", err)
+			}
+			// A workload must not run on with part of its input missing.
+			if s.input.Err() != nil {
+				s.proc.terminate(grace)
+			}
+		}()
+	}
 
 	// Stream stdout and stderr lines as log messages.
-	var output strings.Builder
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
-		streamLines(conn, &writeMu, stdoutPipe, &output)
+		streamLines(s, fc.StreamStdout, stdoutPipe, output)
 	}()
 
-	var stderrBuf strings.Builder
 	stderrDone := make(chan struct{})
 	go func() {
 		defer close(stderrDone)
-		streamLines(conn, &writeMu, stderrPipe, &stderrBuf)
+		streamLines(s, fc.StreamStderr, stderrPipe, stderrBuf)
 	}()
 
 	<-done
 	<-stderrDone
 
 	waitErr := cmd.Wait()
+	s.proc.exited()
+
+	timedOut := !s.proc.isCancelled() && ctx.Err() == context.DeadlineExceeded
+	exitReason, exitSignal := classifyExit(cmd.ProcessState, timedOut, oomBefore)
 
 	exitCode := 0
 	errMsg := ""
 	if waitErr != nil {
-		if ctx.Err() == context.DeadlineExceeded {
+		if s.proc.isCancelled() {
+			errMsg = cancelledMessage
+		} else if timedOut {
 			errMsg = fmt.Sprintf("timeout after %s", timeout)
 		} else {
 			errMsg = waitErr.Error()
@@ -191,51 +414,162 @@ func (a *Agent) executeWorkload(conn net.Conn, req *fc.GuestRequest) fc.GuestRes
 		} else {
 			exitCode = 1
 		}
+	} else if timedOut {
+		// The workload handled SIGTERM and exited cleanly, but it still ran
+		// out of time.
+		errMsg = fmt.Sprintf("timeout after %s", timeout)
 	}
-
-	combinedOutput := output.String()
-	if stderrBuf.Len() > 0 {
-		combinedOutput += stderrBuf.String()
+	if s.input != nil && s.input.Err() != nil {
+		errMsg = fmt.Sprintf("receive input: %v", s.input.Err())
+		if exitCode == 0 {
+			exitCode = 1
+		}
 	}
 
-	return fc.GuestResponse{
-		ExitCode: exitCode,
-		Output:   combinedOutput,
-		Error:    errMsg,
+	usage := cg.usage(cmd.ProcessState)
+	if usage != nil && sc != nil {
+		usage.DiskUsedBytes = sc.used()
 	}
-}
 
-// streamLines reads lines from r, sends each as a log message over conn
-// (protected by mu), and appends to output.
-func streamLines(conn net.Conn, mu *sync.Mutex, r io.Reader, output *strings.Builder) {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		output.WriteString(line + "\n")
+	return withOutput(s, req, output, stderrBuf, fc.GuestResponse{
+		ExitCode:      exitCode,
+		Error:         errMsg,
+		Usage:         usage,
+		ExitReason:    exitReason,
+		ExitSignal:    exitSignal,
+		DepsInstalled: depsInstalled,
+		Built:         built,
+	})
+}
 
-		msg := fc.GuestMessage{
-			Type: fc.MsgTypeLog,
-			Line: line,
+// withOutput completes resp with the workload's stdout and stderr: sent to
+// the host as chunk streams ahead of the result when req asks for streamed
+// output, and inline in resp otherwise.
+func withOutput(s *session, req *fc.GuestRequest, stdout, stderr *outputBuffer, resp fc.GuestResponse) fc.GuestResponse {
+	if req.StreamOutput {
+		if err := sendOutputStreams(s, stdout, stderr); err != nil {
+			log.Printf("send output streams: %v", err)
 		}
-		mu.Lock()
-		err := fc.WriteMessage(conn, &msg)
-		mu.Unlock()
+		return resp
+	}
+
+	resp.Output = stdout.String()
+	if stderr.Len() > 0 {
+		resp.Output += stderr.String()
+	}
+	return resp
+}
+
+// sendOutputStreams sends the spooled stdout and stderr to the host as chunk streams.
+func sendOutputStreams(s *session, stdout, stderr *outputBuffer) error {
+	streams := []struct {
+		name string
+		buf  *outputBuffer
+	}{
+		{fc.StreamStdout, stdout},
+		{fc.StreamStderr, stderr},
+	}
+	for _, st := range streams {
+		r, err := st.buf.reader()
 		if err != nil {
+			return fmt.Errorf("rewind %s: %w", st.name, err)
+		}
+		if err := s.sendStream(st.name, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxLogLineSize bounds a single streamed log line. Output beyond a longer
+// line is still captured but no longer streamed as log messages.
+const maxLogLineSize = 1 << 20
+
+// streamLines reads lines from r and sends each as a log message of the
+// given stream to the host, while copying the raw bytes to output. r is
+// always drained to EOF so the captured output is complete and the process
+// never blocks on a full pipe.
+func streamLines(s *session, stream string, r io.Reader, output io.Writer) {
+	streamLinesAs(s, fc.GuestMessage{Type: fc.MsgTypeLog, Stream: stream}, r, output)
+}
+
+// streamLinesAs is streamLines sending each line in a copy of msg.
+func streamLinesAs(s *session, msg fc.GuestMessage, r io.Reader, output io.Writer) {
+	tee := io.TeeReader(r, output)
+	scanner := bufio.NewScanner(tee)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLogLineSize)
+	for scanner.Scan() {
+		msg.Line = scanner.Text()
+		if err := s.writeMessage(&msg); err != nil {
 			log.Printf("write log line: %v", err)
-			return
+			break
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("scan output: %v", err)
+	}
+
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		log.Printf("drain output: %v", err)
+	}
+}
+
+// outputBuffer collects one of a workload's output streams. Output that is
+// streamed back in chunks is spooled to a temporary file so that it is never
+// held in memory as a whole; otherwise it is kept in memory.
+type outputBuffer struct {
+	mem   strings.Builder
+	spool *os.File
+}
+
+// newOutputBuffer creates an outputBuffer, backed by a spool file when spool is set.
+func newOutputBuffer(spool bool) (*outputBuffer, error) {
+	if !spool {
+		return &outputBuffer{}, nil
+	}
+	f, err := os.CreateTemp("", "vulcan-output-*")
+	if err != nil {
+		return nil, fmt.Errorf("create spool file: %w", err)
+	}
+	return &outputBuffer{spool: f}, nil
+}
+
+// Write implements io.Writer.
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	if b.spool != nil {
+		return b.spool.Write(p)
+	}
+	return b.mem.Write(p)
+}
+
+// String returns the in-memory contents.
+func (b *outputBuffer) String() string {
+	return b.mem.String()
+}
+
+// Len returns the length of the in-memory contents.
+func (b *outputBuffer) Len() int {
+	return b.mem.Len()
 }
 
-// sendResult sends the final GuestResponse wrapped in a GuestMessage.
-func sendResult(conn net.Conn, resp fc.GuestResponse) {
-	msg := fc.GuestMessage{
-		Type:     fc.MsgTypeResult,
-		Response: &resp,
+// reader returns a reader over everything written so far.
+func (b *outputBuffer) reader() (io.Reader, error) {
+	if b.spool == nil {
+		return strings.NewReader(b.mem.String()), nil
 	}
-	if err := fc.WriteMessage(conn, &msg); err != nil {
-		log.Printf("write result: %v", err)
+	if _, err := b.spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
 	}
+	return b.spool, nil
+}
+
+// Close removes the spool file, if any.
+func (b *outputBuffer) Close() error {
+	if b.spool == nil {
+		return nil
+	}
+	b.spool.Close()
+	return os.Remove(b.spool.Name())
 }
 
 // validatePath checks that joining baseDir with relPath stays within baseDir.
@@ -252,25 +586,25 @@ func validatePath(baseDir, relPath string) error {
 	return nil
 }
 
-// extractCode writes code to the work directory. If the code appears to be
-// a base64-encoded tar.gz archive, it is decoded and extracted. Otherwise,
-// the code is written as a single file.
-func (a *Agent) extractCode(code, entrypoint string) error {
-	// Clean and recreate the work directory.
-	if err := os.RemoveAll(a.workDir); err != nil {
-		return fmt.Errorf("clean work dir: %w", err)
-	}
-	if err := os.MkdirAll(a.workDir, 0o755); err != nil {
+// extractCode writes code to dir. A tar.gz archive, passed as archive or
+// base64-encoded as code, is extracted. Otherwise, the code is written as a
+// single file. Files already in dir are kept unless the code replaces them.
+func extractCode(dir, code string, archive []byte, entrypoint string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("create work dir: %w", err)
 	}
 
+	if len(archive) > 0 {
+		return extractTarGz(dir, archive)
+	}
+
 	// Check if code is a base64-encoded archive.
 	if isBase64Archive(code) {
-		return extractArchive(a.workDir, code)
+		return extractArchive(dir, code)
 	}
 
 	// Write as a single file.
-	path := filepath.Join(a.workDir, entrypoint)
+	path := filepath.Join(dir, entrypoint)
 	return os.WriteFile(path, []byte(code), 0o644)
 }
 
@@ -295,7 +629,12 @@ func extractArchive(dir, encoded string) error {
 	if err != nil {
 		return fmt.Errorf("decode base64: %w", err)
 	}
+	return extractTarGz(dir, data)
+}
 
+// extractTarGz safely extracts a tar.gz archive to dir. Each entry is
+// validated to prevent path traversal (zip-slip).
+func extractTarGz(dir string, data []byte) error {
 	gz, err := gzip.NewReader(bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("open gzip: %w", err)
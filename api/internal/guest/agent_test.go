@@ -5,22 +5,31 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
+	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+	"github.com/seantiz/vulcan/internal/model"
 )
 
 // executeOverPipe sends a GuestRequest via a pipe and reads back GuestMessages.
 func executeOverPipe(t *testing.T, workDir string, req fc.GuestRequest) ([]fc.GuestMessage, fc.GuestResponse) {
 	t.Helper()
-	server, client := net.Pipe()
+	return executeWithAgent(t, New(nil, filepath.Join(workDir, "work")), req)
+}
 
-	agent := New(nil, filepath.Join(workDir, "work"))
+// executeWithAgent is executeOverPipe for a preconfigured agent.
+func executeWithAgent(t *testing.T, agent *Agent, req fc.GuestRequest) ([]fc.GuestMessage, fc.GuestResponse) {
+	t.Helper()
+	server, client := net.Pipe()
 
 	// Send request and read responses concurrently.
 	go func() {
@@ -146,6 +155,40 @@ func TestExecuteUnsupportedRuntime(t *testing.T) {
 	}
 }
 
+func TestExecuteImageCommand(t *testing.T) {
+	req := fc.GuestRequest{
+		Runtime:    "shell@1.0",
+		Command:    []string{"sh", "-e"},
+		Entrypoint: "run.sh",
+		Code:       `echo "hello from $0"`,
+		TimeoutS:   10,
+	}
+
+	_, resp := executeOverPipe(t, t.TempDir(), req)
+
+	if resp.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0; error: %s", resp.ExitCode, resp.Error)
+	}
+	if !strings.HasPrefix(resp.Output, "hello from /") || !strings.HasSuffix(resp.Output, "/run.sh\n") {
+		t.Errorf("Output = %q, want the entrypoint path appended to the command", resp.Output)
+	}
+}
+
+func TestExecuteImageCommandWithoutEntrypoint(t *testing.T) {
+	req := fc.GuestRequest{
+		Runtime:  "shell@1.0",
+		Command:  []string{"sh"},
+		Code:     `echo hello`,
+		TimeoutS: 10,
+	}
+
+	_, resp := executeOverPipe(t, t.TempDir(), req)
+
+	if resp.ExitCode != 1 || !strings.Contains(resp.Error, "entrypoint is required") {
+		t.Errorf("resp = %+v, want a missing entrypoint error", resp)
+	}
+}
+
 func TestExecuteTimeout(t *testing.T) {
 	if _, err := findExecutable("node"); err != nil {
 		t.Skip("node not available")
@@ -168,6 +211,69 @@ func TestExecuteTimeout(t *testing.T) {
 	}
 }
 
+func TestExecuteTimeoutTerminatesGracefully(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+
+	code := "import signal, sys, time\n" +
+		"def term(*_):\n" +
+		"    time.sleep(0.2)\n" +
+		"    print('cleaned up', flush=True)\n" +
+		"    sys.exit(0)\n" +
+		"signal.signal(signal.SIGTERM, term)\n" +
+		"time.sleep(60)\n"
+
+	start := time.Now()
+	msgs, resp := executeOverPipe(t, t.TempDir(), fc.GuestRequest{
+		Runtime:  "python",
+		Code:     code,
+		TimeoutS: 1,
+		GraceMS:  5000,
+	})
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout took %s, want prompt exit on SIGTERM", elapsed)
+	}
+	if !slices.ContainsFunc(msgs, func(m fc.GuestMessage) bool { return m.Line == "cleaned up" }) {
+		t.Errorf("messages = %+v, want the SIGTERM handler's output", msgs)
+	}
+	if resp.ExitReason != model.ExitReasonTimeout {
+		t.Errorf("ExitReason = %q, want %q", resp.ExitReason, model.ExitReasonTimeout)
+	}
+	if !strings.Contains(resp.Error, "timeout") {
+		t.Errorf("Error = %q, want to indicate timeout", resp.Error)
+	}
+}
+
+func TestExecuteTimeoutKillsAfterGrace(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+
+	code := "import signal, time\n" +
+		"signal.signal(signal.SIGTERM, signal.SIG_IGN)\n" +
+		"time.sleep(60)\n"
+
+	start := time.Now()
+	_, resp := executeOverPipe(t, t.TempDir(), fc.GuestRequest{
+		Runtime:  "python",
+		Code:     code,
+		TimeoutS: 1,
+		GraceMS:  200,
+	})
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("timeout took %s, want SIGKILL after grace", elapsed)
+	}
+	if resp.ExitCode == 0 {
+		t.Error("ExitCode = 0, want non-zero for killed workload")
+	}
+	if resp.ExitReason != model.ExitReasonTimeout {
+		t.Errorf("ExitReason = %q, want %q", resp.ExitReason, model.ExitReasonTimeout)
+	}
+}
+
 func TestExecuteNonZeroExit(t *testing.T) {
 	if _, err := findExecutable("node"); err != nil {
 		t.Skip("node not available")
@@ -209,11 +315,35 @@ func TestExecuteMultiLineOutput(t *testing.T) {
 	}
 }
 
+func TestLogLinesCarryTheirStream(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+
+	req := fc.GuestRequest{
+		Runtime:  "python",
+		Code:     "import sys\nprint('out', flush=True)\nprint('err', file=sys.stderr, flush=True)",
+		TimeoutS: 10,
+	}
+	logs, resp := executeOverPipe(t, t.TempDir(), req)
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, want 0; error: %s", resp.ExitCode, resp.Error)
+	}
+
+	streams := make(map[string]string)
+	for _, msg := range logs {
+		streams[msg.Line] = msg.Stream
+	}
+	if streams["out"] != fc.StreamStdout || streams["err"] != fc.StreamStderr {
+		t.Errorf("log line streams = %v, want out on stdout and err on stderr", streams)
+	}
+}
+
 func TestExtractCodeInline(t *testing.T) {
 	workDir := t.TempDir()
 	agent := &Agent{workDir: filepath.Join(workDir, "work")}
 
-	err := agent.extractCode("console.log('test');", "index.js")
+	err := extractCode(agent.workDir, "console.log('test');", nil, "index.js")
 	if err != nil {
 		t.Fatalf("extractCode: %v", err)
 	}
@@ -253,7 +383,7 @@ func TestExtractArchiveEndToEnd(t *testing.T) {
 	workDir := t.TempDir()
 	agent := &Agent{workDir: filepath.Join(workDir, "work")}
 
-	err := agent.extractCode(encoded, "index.js")
+	err := extractCode(agent.workDir, encoded, nil, "index.js")
 	if err != nil {
 		t.Fatalf("extractCode: %v", err)
 	}
@@ -290,7 +420,7 @@ func TestExtractArchivePathTraversal(t *testing.T) {
 	workDir := t.TempDir()
 	agent := &Agent{workDir: filepath.Join(workDir, "work")}
 
-	err := agent.extractCode(encoded, "index.js")
+	err := extractCode(agent.workDir, encoded, nil, "index.js")
 	if err == nil {
 		t.Fatal("expected error for path traversal archive entry")
 	}
@@ -391,6 +521,163 @@ func TestAgentSurvivesErrors(t *testing.T) {
 	}
 }
 
+func TestExecuteStreamedIO(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+
+	server, client := net.Pipe()
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agent.handleConnection(server)
+	}()
+
+	// Input and output both span many chunks and exceed the flow-control window.
+	input := bytes.Repeat([]byte("x"), 4*fc.ChunkWindow*fc.ChunkSize)
+	code := "import sys\n" +
+		"data = sys.stdin.read()\n" +
+		"sys.stdout.write('y' * len(data))\n" +
+		"sys.stderr.write('read %d\\n' % len(data))\n"
+
+	var stdout, stderr bytes.Buffer
+	gc := fc.NewGuestConn(client)
+	resp, err := gc.RunWorkloadStream(fc.GuestRequest{
+		Runtime:  "python",
+		Code:     code,
+		TimeoutS: 30,
+	}, fc.StreamIO{
+		Input:  bytes.NewReader(input),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	client.Close()
+	<-done
+
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, want 0; error: %s", resp.ExitCode, resp.Error)
+	}
+	if resp.Output != "" {
+		t.Errorf("inline Output = %d bytes, want none when streamed", len(resp.Output))
+	}
+	if got := stdout.String(); got != strings.Repeat("y", len(input)) {
+		t.Errorf("stdout = %d bytes, want %d", len(got), len(input))
+	}
+	if want := fmt.Sprintf("read %d\n", len(input)); stderr.String() != want {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+}
+
+func TestInputWindowViolationFailsStream(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	s := newSession(server, true, false)
+	go s.readLoop()
+
+	// Nothing reads the input, so no chunk is acknowledged and the host
+	// overruns its window.
+	for i := range fc.ChunkWindow + 1 {
+		msg := fc.HostMessage{Type: fc.MsgTypeChunk, Stream: fc.StreamInput, Data: []byte{byte(i)}}
+		if err := fc.WriteMessage(client, &msg); err != nil {
+			t.Fatalf("write chunk %d: %v", i, err)
+		}
+	}
+	if err := fc.WriteMessage(client, &fc.HostMessage{Type: fc.MsgTypeChunkEnd, Stream: fc.StreamInput}); err != nil {
+		t.Fatalf("write end: %v", err)
+	}
+
+	if _, err := io.ReadAll(s.input); err == nil || !strings.Contains(err.Error(), "input stream") {
+		t.Errorf("ReadAll error = %v, want the window violation", err)
+	}
+}
+
+func TestHandshakeThenExecute(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+
+	server, client := net.Pipe()
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agent.handleConnection(server)
+	}()
+
+	gc := fc.NewGuestConn(client)
+	info, err := gc.Handshake()
+	if err != nil {
+		t.Fatalf("Handshake: %v", err)
+	}
+	if info.AgentVersion != Version {
+		t.Errorf("AgentVersion = %q, want %q", info.AgentVersion, Version)
+	}
+	if info.ProtocolVersion != fc.ProtocolVersion {
+		t.Errorf("ProtocolVersion = %d, want %d", info.ProtocolVersion, fc.ProtocolVersion)
+	}
+	if !info.HasRuntime("python") {
+		t.Errorf("Runtimes = %v, want to include python", info.Runtimes)
+	}
+	if !info.HasFeature(fc.FeatureChunkedIO) {
+		t.Errorf("Features = %v, want to include %s", info.Features, fc.FeatureChunkedIO)
+	}
+	if !info.HasFeature(fc.FeatureCommand) {
+		t.Errorf("Features = %v, want to include %s", info.Features, fc.FeatureCommand)
+	}
+	if !info.HasFeature(fc.FeatureDeps) {
+		t.Errorf("Features = %v, want to include %s", info.Features, fc.FeatureDeps)
+	}
+	if !info.HasFeature(fc.FeatureGoBuild) {
+		t.Errorf("Features = %v, want to include %s", info.Features, fc.FeatureGoBuild)
+	}
+	if !info.HasFeature(fc.FeatureVolumes) {
+		t.Errorf("Features = %v, want to include %s", info.Features, fc.FeatureVolumes)
+	}
+	if !info.HasFeature(fc.FeatureScratch) {
+		t.Errorf("Features = %v, want to include %s", info.Features, fc.FeatureScratch)
+	}
+	if !info.HasFeature(fc.FeatureService) {
+		t.Errorf("Features = %v, want to include %s", info.Features, fc.FeatureService)
+	}
+	if !info.HasFeature(fc.FeatureSession) {
+		t.Errorf("Features = %v, want to include %s", info.Features, fc.FeatureSession)
+	}
+	if !info.HasFeature(fc.FeatureMultiplex) || info.MaxExecs != DefaultMaxExecs {
+		t.Errorf("Features = %v MaxExecs = %d, want %s with %d", info.Features, info.MaxExecs, fc.FeatureMultiplex, DefaultMaxExecs)
+	}
+
+	resp, err := gc.RunWorkload(fc.GuestRequest{
+		Runtime:  "python",
+		Code:     `print("after hello")`,
+		TimeoutS: 10,
+	}, nil)
+	client.Close()
+	<-done
+
+	if err != nil {
+		t.Fatalf("RunWorkload: %v", err)
+	}
+	if !strings.Contains(resp.Output, "after hello") {
+		t.Errorf("Output = %q, want to contain 'after hello'", resp.Output)
+	}
+}
+
+func TestAvailableRuntimesOnlyListsInstalled(t *testing.T) {
+	agent := New(nil, t.TempDir())
+	for _, name := range agent.availableRuntimes() {
+		rt, _ := agent.runtimes.Lookup(name)
+		if _, err := findExecutable(rt.Bin); err != nil {
+			t.Errorf("runtime %q advertised but %s is not on PATH", name, rt.Bin)
+		}
+	}
+}
+
 func TestValidatePath(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -417,3 +704,126 @@ func TestValidatePath(t *testing.T) {
 func findExecutable(name string) (string, error) {
 	return exec.LookPath(name)
 }
+
+// runControlled executes a python workload that prints "ready" once it is
+// set up, invoking control with the host connection at that point.
+func runControlled(t *testing.T, code string, control func(gc *fc.GuestConn)) (fc.GuestResponse, []string, *fc.GuestConn) {
+	t.Helper()
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+
+	server, client := net.Pipe()
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agent.handleConnection(server)
+	}()
+
+	gc := fc.NewGuestConn(client)
+	var lines []string
+	resp, err := gc.RunWorkloadStream(fc.GuestRequest{
+		Runtime:     "python",
+		Code:        code,
+		TimeoutS:    30,
+		HeartbeatMS: 20,
+	}, fc.StreamIO{LogWriter: func(_, line string) {
+		lines = append(lines, line)
+		if line == "ready" {
+			control(gc)
+		}
+	}})
+	client.Close()
+	<-done
+
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+	return resp, lines, gc
+}
+
+func TestCancelTerminatesGracefully(t *testing.T) {
+	code := "import signal, sys, time\n" +
+		"def term(*_):\n" +
+		"    print('got SIGTERM', flush=True)\n" +
+		"    sys.exit(3)\n" +
+		"signal.signal(signal.SIGTERM, term)\n" +
+		"time.sleep(0.1)\n" +
+		"print('ready', flush=True)\n" +
+		"time.sleep(60)\n"
+
+	start := time.Now()
+	resp, lines, gc := runControlled(t, code, func(gc *fc.GuestConn) {
+		if err := gc.Cancel(5 * time.Second); err != nil {
+			t.Errorf("Cancel: %v", err)
+		}
+	})
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancel took %s, want prompt exit on SIGTERM", elapsed)
+	}
+	if resp.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", resp.ExitCode)
+	}
+	if resp.Error != cancelledMessage {
+		t.Errorf("Error = %q, want %q", resp.Error, cancelledMessage)
+	}
+	if !slices.Contains(lines, "got SIGTERM") {
+		t.Errorf("log lines = %v, want SIGTERM handler output", lines)
+	}
+	if hb, ok := gc.LastHeartbeat(); !ok || hb.PID == 0 {
+		t.Errorf("LastHeartbeat = %+v, %v; want a heartbeat with the workload pid", hb, ok)
+	}
+}
+
+func TestCancelKillsAfterGrace(t *testing.T) {
+	// The workload ignores SIGTERM and has a child that would outlive it if
+	// only the leader were killed.
+	code := "import signal, subprocess, time\n" +
+		"signal.signal(signal.SIGTERM, signal.SIG_IGN)\n" +
+		"subprocess.Popen(['sleep', '60'])\n" +
+		"print('ready', flush=True)\n" +
+		"time.sleep(60)\n"
+
+	start := time.Now()
+	resp, _, _ := runControlled(t, code, func(gc *fc.GuestConn) {
+		if err := gc.Cancel(200 * time.Millisecond); err != nil {
+			t.Errorf("Cancel: %v", err)
+		}
+	})
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("cancel took %s, want SIGKILL after grace", elapsed)
+	}
+	if resp.ExitCode == 0 {
+		t.Errorf("ExitCode = 0, want non-zero for killed workload")
+	}
+	if resp.Error != cancelledMessage {
+		t.Errorf("Error = %q, want %q", resp.Error, cancelledMessage)
+	}
+}
+
+func TestSignalReachesWorkload(t *testing.T) {
+	code := "import signal, sys, time\n" +
+		"def usr1(*_):\n" +
+		"    print('got SIGUSR1', flush=True)\n" +
+		"    sys.exit(0)\n" +
+		"signal.signal(signal.SIGUSR1, usr1)\n" +
+		"print('ready', flush=True)\n" +
+		"time.sleep(60)\n"
+
+	resp, lines, _ := runControlled(t, code, func(gc *fc.GuestConn) {
+		if err := gc.Signal("SIGUSR1"); err != nil {
+			t.Errorf("Signal: %v", err)
+		}
+	})
+
+	if resp.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0; error: %s", resp.ExitCode, resp.Error)
+	}
+	if !slices.Contains(lines, "got SIGUSR1") {
+		t.Errorf("log lines = %v, want SIGUSR1 handler output", lines)
+	}
+}
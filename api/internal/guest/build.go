@@ -0,0 +1,97 @@
+package guest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// goBin is the Go toolchain, which compiles programs whatever the go
+// runtime's manifest entry runs them with.
+const goBin = "go"
+
+// goVersion returns the version of the Go toolchain on PATH, or "" if there
+// is none. The rootfs does not change while the agent runs, so it is looked
+// up once.
+var goVersion = sync.OnceValue(func() string {
+	out, err := exec.Command(goBin, "env", "GOVERSION").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+})
+
+// toolchains returns the toolchain versions advertised in the handshake.
+func toolchains() map[string]string {
+	if v := goVersion(); v != "" {
+		return map[string]string{"go": v}
+	}
+	return nil
+}
+
+// obtainProgram returns the path of the program to run for a request with a
+// BuildRequest: the prebuilt program streamed by the host, or one compiled
+// from the entrypoint and streamed back to the host. If there is no program,
+// it returns "" and the response to send instead.
+func (a *Agent) obtainProgram(ctx context.Context, s *session, req *fc.GuestRequest, entrypoint string) (string, fc.GuestResponse) {
+	if req.Runtime != "go" {
+		return "", fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("runtime %q is not compiled", req.Runtime)}
+	}
+	f, err := os.CreateTemp("", "vulcan-program-*")
+	if err != nil {
+		return "", fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("create program file: %v", err)}
+	}
+	path := f.Name()
+
+	if req.Build.Prebuilt {
+		_, err = io.Copy(f, s.program)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(path, 0o755)
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("receive program: %v", err)}
+		}
+		return path, fc.GuestResponse{}
+	}
+
+	// The compiler replaces the file rather than writing through f.
+	f.Close()
+	cmd := exec.Command(goBin, "build", "-trimpath", "-o", path, entrypoint)
+	cmd.Dir = s.workDir
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+	if err := runLogged(ctx, s, fc.GuestMessage{Type: fc.MsgTypeBuildLog}, cmd); err != nil {
+		os.Remove(path)
+		exitCode := 1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+		return "", fc.GuestResponse{ExitCode: exitCode, Error: fmt.Sprintf("build failed: %v", err)}
+	}
+	if err := sendFile(s, fc.StreamProgram, path); err != nil {
+		os.Remove(path)
+		return "", fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("send program: %v", err)}
+	}
+	return path, fc.GuestResponse{}
+}
+
+// sendFile streams the file at path to the host.
+func sendFile(s *session, stream, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.sendStream(stream, f)
+}
@@ -0,0 +1,127 @@
+package guest
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// runStreamed executes req through a host-side GuestConn, which handles the
+// chunk streams a program is exchanged over.
+func runStreamed(t *testing.T, req fc.GuestRequest, sio fc.StreamIO) (fc.GuestResponse, error) {
+	t.Helper()
+	server, client := net.Pipe()
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agent.handleConnection(server)
+	}()
+
+	resp, err := fc.NewGuestConn(client).RunWorkloadStream(req, sio)
+	client.Close()
+	<-done
+	return resp, err
+}
+
+func TestExecuteBuildsProgram(t *testing.T) {
+	if _, err := findExecutable("go"); err != nil {
+		t.Skip("go not available")
+	}
+
+	var program, stdout bytes.Buffer
+	resp, err := runStreamed(t, fc.GuestRequest{
+		Runtime:  "go",
+		Code:     "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello from a build\")\n}\n",
+		TimeoutS: 60,
+		Build:    &fc.BuildRequest{},
+	}, fc.StreamIO{Stdout: &stdout, BuiltProgram: &program})
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+
+	if resp.ExitCode != 0 || !resp.Built {
+		t.Fatalf("resp = %+v, want a built program that ran", resp)
+	}
+	if stdout.String() != "hello from a build\n" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if !bytes.HasPrefix(program.Bytes(), []byte("\x7fELF")) {
+		t.Errorf("program starts with %q, want an ELF executable", program.Bytes()[:min(4, program.Len())])
+	}
+}
+
+func TestExecuteBuildFailure(t *testing.T) {
+	if _, err := findExecutable("go"); err != nil {
+		t.Skip("go not available")
+	}
+
+	var program bytes.Buffer
+	var buildLog, runLog []string
+	resp, err := runStreamed(t, fc.GuestRequest{
+		Runtime:  "go",
+		Code:     "package main\n\nfunc main() {\n\tundefined()\n}\n",
+		TimeoutS: 60,
+		Build:    &fc.BuildRequest{},
+	}, fc.StreamIO{
+		BuiltProgram:   &program,
+		LogWriter:      func(_, line string) { runLog = append(runLog, line) },
+		BuildLogWriter: func(line string) { buildLog = append(buildLog, line) },
+	})
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+
+	if resp.ExitCode == 0 || resp.Built || !strings.HasPrefix(resp.Error, "build failed") {
+		t.Errorf("resp = %+v, want a build failure", resp)
+	}
+	if !strings.Contains(strings.Join(buildLog, "\n"), "undefined") {
+		t.Errorf("build log = %q, want the compiler error", buildLog)
+	}
+	if len(runLog) != 0 {
+		t.Errorf("run log = %q, want compiler output kept out of it", runLog)
+	}
+	if program.Len() != 0 {
+		t.Errorf("received %d program bytes for a failed build", program.Len())
+	}
+}
+
+func TestExecutePrebuiltProgram(t *testing.T) {
+	var stdout bytes.Buffer
+	resp, err := runStreamed(t, fc.GuestRequest{
+		Runtime:  "go",
+		Code:     "package main\n\nfunc main() {}\n",
+		TimeoutS: 10,
+		Build:    &fc.BuildRequest{Prebuilt: true},
+	}, fc.StreamIO{
+		Stdout:  &stdout,
+		Program: strings.NewReader("#!/bin/sh\necho prebuilt\n"),
+	})
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+
+	if resp.ExitCode != 0 || resp.Built {
+		t.Fatalf("resp = %+v, want the prebuilt program to run", resp)
+	}
+	if stdout.String() != "prebuilt\n" {
+		t.Errorf("stdout = %q, want the prebuilt program's output", stdout.String())
+	}
+}
+
+func TestToolchains(t *testing.T) {
+	if _, err := findExecutable("go"); err != nil {
+		if toolchains() != nil {
+			t.Errorf("toolchains() = %v without go", toolchains())
+		}
+		return
+	}
+	if v := toolchains()["go"]; !strings.HasPrefix(v, "go1.") {
+		t.Errorf("go toolchain = %q, want a go1.x version", v)
+	}
+}
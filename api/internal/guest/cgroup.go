@@ -0,0 +1,170 @@
+package guest
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// cgroupRoot is where init mounts the cgroup v2 hierarchy.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupControllers are enabled for workload cgroups. cpu.stat reports CPU
+// time without a controller, but memory.peak and io.stat need theirs.
+var cgroupControllers = []string{"cpu", "memory", "io"}
+
+// cgroupSeq numbers workload cgroups, which outlive a workload whose
+// processes are still running and so cannot be named after its execution.
+var cgroupSeq atomic.Int64
+
+// enableCgroupControllers makes cgroupControllers available to workload
+// cgroups. Controllers the guest kernel lacks are skipped.
+func enableCgroupControllers() {
+	control := filepath.Join(cgroupRoot, "cgroup.subtree_control")
+	for _, c := range cgroupControllers {
+		if err := os.WriteFile(control, []byte("+"+c), 0o644); err != nil {
+			log.Printf("enable cgroup controller %s: %v", c, err)
+		}
+	}
+}
+
+// workloadCgroup is the cgroup a workload's processes run in. Its counters
+// cover every process the workload started, including those the workload
+// did not wait for, which rusage misses.
+type workloadCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// newWorkloadCgroup creates a cgroup for one workload under root. It returns
+// nil if root is not a cgroup v2 hierarchy, as when the agent runs outside a
+// microVM; the workload's usage then comes from rusage alone.
+func newWorkloadCgroup(root string) *workloadCgroup {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil
+	}
+	path := filepath.Join(root, fmt.Sprintf("workload-%d", cgroupSeq.Add(1)))
+	if err := os.Mkdir(path, 0o755); err != nil {
+		log.Printf("create workload cgroup: %v", err)
+		return nil
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		log.Printf("open workload cgroup: %v", err)
+		os.Remove(path)
+		return nil
+	}
+	return &workloadCgroup{path: path, dir: dir}
+}
+
+// apply makes the process started with attr begin in the cgroup, so that
+// nothing it forks can escape it. It does nothing on a nil cgroup.
+func (cg *workloadCgroup) apply(attr *syscall.SysProcAttr) {
+	if cg == nil {
+		return
+	}
+	attr.UseCgroupFD = true
+	attr.CgroupFD = int(cg.dir.Fd())
+}
+
+// usage returns the resources consumed by the workload whose leader exited
+// with ps. Counters the cgroup provides replace those from ps's rusage, and
+// its memory.peak adds the peak memory of the workload as a whole.
+func (cg *workloadCgroup) usage(ps *os.ProcessState) *fc.ResourceUsage {
+	u := processUsage(ps)
+	if u != nil && cg != nil {
+		readCgroupUsage(cg.path, u)
+	}
+	return u
+}
+
+// remove deletes the cgroup. A cgroup still holding processes the workload
+// left running cannot be removed and is left behind.
+func (cg *workloadCgroup) remove() {
+	if cg == nil {
+		return
+	}
+	cg.dir.Close()
+	if err := os.Remove(cg.path); err != nil {
+		log.Printf("remove workload cgroup: %v", err)
+	}
+}
+
+// readCgroupUsage sets u's counters from the cgroup at path: CPU time from
+// cpu.stat, peak memory from memory.peak (Linux 5.19 and later) and block
+// I/O from io.stat. Counters whose file is missing or unreadable are kept.
+// PeakRSSKB stays the rusage peak of the largest process, since memory.peak
+// covers the whole cgroup, page cache included.
+func readCgroupUsage(path string, u *fc.ResourceUsage) {
+	if stat, err := readKeyedFile(filepath.Join(path, "cpu.stat")); err == nil {
+		if v, ok := stat["user_usec"]; ok {
+			u.CPUUserMS = v / 1000
+		}
+		if v, ok := stat["system_usec"]; ok {
+			u.CPUSysMS = v / 1000
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(path, "memory.peak")); err == nil {
+		if v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			u.PeakMemoryKB = v >> 10
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(path, "io.stat")); err == nil {
+		u.IOReadBytes, u.IOWriteBytes = parseIOStat(string(data))
+	}
+}
+
+// readKeyedFile parses a cgroup file of "key value" lines.
+func readKeyedFile(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]int64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			values[key] = v
+		}
+	}
+	return values, sc.Err()
+}
+
+// parseIOStat sums the bytes read and written over the devices in io.stat,
+// whose lines look like "254:0 rbytes=4096 wbytes=0 rios=1 wios=0 ...".
+func parseIOStat(data string) (read, written int64) {
+	for line := range strings.Lines(data) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				read += v
+			case "wbytes":
+				written += v
+			}
+		}
+	}
+	return read, written
+}
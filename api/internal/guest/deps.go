@@ -0,0 +1,224 @@
+package guest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// Default mount points of the dependency layer and the package mirror.
+const (
+	defaultDepsDir   = "/deps"
+	defaultMirrorDir = "/mirror"
+)
+
+// depsInstaller builds the command installing a workload's dependencies from
+// the package mirror at mirrorDir into the dependency layer at depsDir.
+// Installers write only below their runtime's directory in the layer.
+type depsInstaller func(depsDir, mirrorDir, workDir string) (*exec.Cmd, error)
+
+// depsManifests lists the files each runtime's installer reads from the
+// workload's code. Installers run over a copy of only these, so that none of
+// the workload's other files can be built or run into the layer.
+var depsManifests = map[string][]string{
+	"python": {"requirements.txt"},
+	"node":   {"package.json", "package-lock.json"},
+}
+
+// depsInstallers maps each runtime with a dependency phase to its installer.
+var depsInstallers = map[string]depsInstaller{
+	"python": pythonInstaller,
+	"node":   nodeInstaller,
+}
+
+// pythonInstaller installs requirements.txt from the wheels and sdists in the
+// mirror's pip directory.
+func pythonInstaller(depsDir, mirrorDir, workDir string) (*exec.Cmd, error) {
+	return exec.Command("python3", "-m", "pip", "install",
+		"--no-index", "--find-links", filepath.Join(mirrorDir, "pip"),
+		"--target", filepath.Join(depsDir, "python"),
+		"--disable-pip-version-check", "--no-cache-dir",
+		"-r", filepath.Join(workDir, "requirements.txt")), nil
+}
+
+// nodeInstaller installs package.json, and its lock file if any, from the npm
+// cache in the mirror's npm directory.
+func nodeInstaller(depsDir, mirrorDir, workDir string) (*exec.Cmd, error) {
+	prefix := filepath.Join(depsDir, "node")
+	if err := os.MkdirAll(prefix, 0o755); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"package.json", "package-lock.json"} {
+		data, err := os.ReadFile(filepath.Join(workDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(prefix, name), data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return exec.Command("npm", "install", "--prefix", prefix,
+		"--offline", "--cache", filepath.Join(mirrorDir, "npm"),
+		"--no-audit", "--no-fund", "--omit=dev"), nil
+}
+
+// mountExt4 mounts the ext4 filesystem on device at target.
+func mountExt4(device, target string, readOnly bool) error {
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	var flags uintptr
+	if readOnly {
+		flags |= syscall.MS_RDONLY
+	}
+	if err := syscall.Mount(device, target, "ext4", flags, ""); err != nil {
+		return fmt.Errorf("mount %s on %s: %w", device, target, err)
+	}
+	return nil
+}
+
+// unmount unmounts the filesystem at target.
+func unmount(target string) error {
+	if err := syscall.Unmount(target, 0); err != nil {
+		return fmt.Errorf("unmount %s: %w", target, err)
+	}
+	return nil
+}
+
+// attachDeps mounts the request's dependency layer, first installing the
+// dependencies into it from the package mirror if asked to, and returns the
+// environment variables that make them visible to the workload. Installer
+// output is streamed to the host as log lines.
+func (a *Agent) attachDeps(ctx context.Context, s *session, req *fc.GuestRequest) ([]string, error) {
+	d := req.Deps
+	installer, ok := depsInstallers[req.Runtime]
+	if !ok {
+		return nil, fmt.Errorf("runtime %q has no dependency phase", req.Runtime)
+	}
+
+	if d.Install {
+		if err := a.installDeps(ctx, s, d, req.Runtime, installer); err != nil {
+			return nil, err
+		}
+		if d.Commit {
+			if err := handOverDeps(ctx, s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := a.mount(d.Device, a.depsDir, true); err != nil {
+		return nil, err
+	}
+	return a.depsEnv(req.Runtime, s.workDir)
+}
+
+// installDeps runs the runtime's installer into the dependency layer, which
+// is left unmounted so it can be remounted read-only.
+func (a *Agent) installDeps(ctx context.Context, s *session, d *fc.DepsRequest, runtime string, installer depsInstaller) error {
+	if err := a.mount(d.Device, a.depsDir, false); err != nil {
+		return err
+	}
+	if err := a.mount(d.MirrorDevice, a.mirrorDir, true); err != nil {
+		a.unmount(a.depsDir)
+		return err
+	}
+
+	manifestDir, err := copyManifests(s.workDir, runtime)
+	var cmd *exec.Cmd
+	if err == nil {
+		defer os.RemoveAll(manifestDir)
+		cmd, err = installer(a.depsDir, a.mirrorDir, manifestDir)
+	}
+	if err == nil {
+		cmd.Dir = manifestDir
+		err = runLogged(ctx, s, fc.GuestMessage{Type: fc.MsgTypeLog, Stream: fc.StreamSystem}, cmd)
+	}
+	if uerr := a.unmount(a.mirrorDir); err == nil {
+		err = uerr
+	}
+	if uerr := a.unmount(a.depsDir); err == nil {
+		err = uerr
+	}
+	if err != nil {
+		return fmt.Errorf("install %s dependencies: %w", runtime, err)
+	}
+	return nil
+}
+
+// copyManifests copies the runtime's dependency manifests in the work
+// directory dir to a new temporary directory, which it returns.
+func copyManifests(dir, runtime string) (string, error) {
+	tmp, err := os.MkdirTemp("", "deps-")
+	if err != nil {
+		return "", err
+	}
+	for _, name := range depsManifests[runtime] {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(tmp, name), data, 0o644)
+		}
+		if err != nil {
+			os.RemoveAll(tmp)
+			return "", fmt.Errorf("copy %s: %w", name, err)
+		}
+	}
+	return tmp, nil
+}
+
+// handOverDeps reports the installed layer to the host and waits until the
+// host has cached it, so that the cache never holds anything the workload
+// wrote.
+func handOverDeps(ctx context.Context, s *session) error {
+	if err := s.writeMessage(&fc.GuestMessage{Type: fc.MsgTypeDepsInstalled}); err != nil {
+		return fmt.Errorf("report installed dependencies: %w", err)
+	}
+	select {
+	case <-s.depsCommitted:
+		return nil
+	case <-s.done:
+		return errors.New("host closed the connection before caching dependencies")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// depsEnv returns the environment variables that expose the runtime's
+// installed dependencies. Node's ES modules ignore NODE_PATH, so the work
+// directory dir also gets a node_modules link unless the code brought its
+// own.
+func (a *Agent) depsEnv(runtime, dir string) ([]string, error) {
+	path := os.Getenv("PATH")
+	switch runtime {
+	case "python":
+		dir := filepath.Join(a.depsDir, "python")
+		return []string{
+			"PYTHONPATH=" + dir,
+			"PATH=" + filepath.Join(dir, "bin") + ":" + path,
+		}, nil
+	case "node":
+		modules := filepath.Join(a.depsDir, "node", "node_modules")
+		link := filepath.Join(dir, "node_modules")
+		if _, err := os.Lstat(link); errors.Is(err, os.ErrNotExist) {
+			if err := os.Symlink(modules, link); err != nil {
+				return nil, fmt.Errorf("link node_modules: %w", err)
+			}
+		}
+		return []string{
+			"NODE_PATH=" + modules,
+			"PATH=" + filepath.Join(modules, ".bin") + ":" + path,
+		}, nil
+	}
+	return nil, nil
+}
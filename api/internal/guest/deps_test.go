@@ -0,0 +1,292 @@
+package guest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// depsTestAgent returns an agent whose dependency layer and mirror are plain
+// directories, recording mount and unmount calls instead of making them.
+func depsTestAgent(t *testing.T) (*Agent, *[]string) {
+	t.Helper()
+	dir := t.TempDir()
+	agent := New(nil, filepath.Join(dir, "work"))
+	agent.depsDir = filepath.Join(dir, "deps")
+	agent.mirrorDir = filepath.Join(dir, "mirror")
+	var calls []string
+	agent.mount = func(device, target string, readOnly bool) error {
+		mode := "rw"
+		if readOnly {
+			mode = "ro"
+		}
+		calls = append(calls, fmt.Sprintf("mount %s %s %s", device, filepath.Base(target), mode))
+		return os.MkdirAll(target, 0o755)
+	}
+	agent.unmount = func(target string) error {
+		calls = append(calls, "unmount "+filepath.Base(target))
+		return nil
+	}
+	return agent, &calls
+}
+
+// stubInstaller replaces the installer for runtime with a shell script run
+// with DEPS, MIRROR and WORK set.
+func stubInstaller(t *testing.T, runtime, script string) {
+	t.Helper()
+	orig := depsInstallers[runtime]
+	depsInstallers[runtime] = func(depsDir, mirrorDir, workDir string) (*exec.Cmd, error) {
+		cmd := exec.Command("sh", "-c", script)
+		cmd.Env = append(os.Environ(), "DEPS="+depsDir, "MIRROR="+mirrorDir, "WORK="+workDir)
+		return cmd, nil
+	}
+	t.Cleanup(func() { depsInstallers[runtime] = orig })
+}
+
+// codeArchive builds a tar.gz archive holding files.
+func codeArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestExecuteInstallsDependencies(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent, calls := depsTestAgent(t)
+	stubInstaller(t, "python", `set -e
+echo "installing $(cat "$WORK/requirements.txt")"
+mkdir -p "$DEPS/python/greeting"
+echo 'WORD = "hello"' > "$DEPS/python/greeting/__init__.py"`)
+
+	logs, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime: "python",
+		CodeArchive: codeArchive(t, map[string]string{
+			"requirements.txt": "greeting==1.0",
+			"main.py":          "import greeting\nprint(greeting.WORD)\n",
+		}),
+		TimeoutS: 10,
+		Deps:     &fc.DepsRequest{Device: "/dev/vdb", Install: true, MirrorDevice: "/dev/vdc"},
+	})
+
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, error: %s", resp.ExitCode, resp.Error)
+	}
+	if resp.Output != "hello\n" {
+		t.Errorf("Output = %q, want hello", resp.Output)
+	}
+	if !resp.DepsInstalled {
+		t.Error("DepsInstalled = false, want true")
+	}
+	if len(logs) == 0 || logs[0].Line != "installing greeting==1.0" {
+		t.Errorf("logs = %+v, want installer output first", logs)
+	}
+	want := []string{
+		"mount /dev/vdb deps rw",
+		"mount /dev/vdc mirror ro",
+		"unmount mirror",
+		"unmount deps",
+		"mount /dev/vdb deps ro",
+	}
+	if !slices.Equal(*calls, want) {
+		t.Errorf("calls = %q, want %q", *calls, want)
+	}
+}
+
+func TestExecuteHandsOverDependenciesBeforeRunning(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent, _ := depsTestAgent(t)
+	// The installer sees the manifests but none of the workload's code.
+	stubInstaller(t, "python", `set -e
+test -f "$WORK/requirements.txt"
+test ! -e "$WORK/main.py"
+mkdir -p "$DEPS/python"`)
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agent.handleConnection(server)
+	}()
+
+	ranBeforeCommit := true
+	resp, err := fc.NewGuestConn(client).RunWorkloadStream(fc.GuestRequest{
+		Runtime: "python",
+		CodeArchive: codeArchive(t, map[string]string{
+			"requirements.txt": "greeting==1.0",
+			"main.py":          fmt.Sprintf("open(%q, 'w').close()\n", marker),
+		}),
+		TimeoutS: 10,
+		Deps:     &fc.DepsRequest{Device: "/dev/vdb", Install: true, MirrorDevice: "/dev/vdc", Commit: true},
+	}, fc.StreamIO{OnDepsInstalled: func() {
+		_, err := os.Stat(marker)
+		ranBeforeCommit = err == nil
+	}})
+	client.Close()
+	<-done
+
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, error: %s", resp.ExitCode, resp.Error)
+	}
+	if ranBeforeCommit {
+		t.Error("workload ran before the host committed the layer")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("workload did not run after the commit: %v", err)
+	}
+}
+
+func TestExecuteAttachesCachedDependencies(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent, calls := depsTestAgent(t)
+	stubInstaller(t, "python", "exit 1")
+	pkg := filepath.Join(agent.depsDir, "python", "greeting")
+	os.MkdirAll(pkg, 0o755)
+	os.WriteFile(filepath.Join(pkg, "__init__.py"), []byte(`WORD = "cached"`), 0o644)
+
+	_, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime: "python",
+		CodeArchive: codeArchive(t, map[string]string{
+			"requirements.txt": "greeting==1.0",
+			"main.py":          "import greeting\nprint(greeting.WORD)\n",
+		}),
+		TimeoutS: 10,
+		Deps:     &fc.DepsRequest{Device: "/dev/vdb"},
+	})
+
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, error: %s", resp.ExitCode, resp.Error)
+	}
+	if resp.Output != "cached\n" {
+		t.Errorf("Output = %q, want cached", resp.Output)
+	}
+	if resp.DepsInstalled {
+		t.Error("DepsInstalled = true for a cached layer")
+	}
+	if want := []string{"mount /dev/vdb deps ro"}; !slices.Equal(*calls, want) {
+		t.Errorf("calls = %q, want %q", *calls, want)
+	}
+}
+
+func TestExecuteFailedInstall(t *testing.T) {
+	agent, calls := depsTestAgent(t)
+	stubInstaller(t, "python", "echo 'no matching distribution' >&2; exit 1")
+
+	logs, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime:     "python",
+		CodeArchive: codeArchive(t, map[string]string{"requirements.txt": "missing", "main.py": "print(1)"}),
+		TimeoutS:    10,
+		Deps:        &fc.DepsRequest{Device: "/dev/vdb", Install: true, MirrorDevice: "/dev/vdc"},
+	})
+
+	if resp.ExitCode == 0 || !strings.Contains(resp.Error, "install python dependencies") {
+		t.Errorf("resp = %+v, want install failure", resp)
+	}
+	if resp.DepsInstalled {
+		t.Error("DepsInstalled = true after a failed install")
+	}
+	if len(logs) != 1 || logs[0].Line != "no matching distribution" {
+		t.Errorf("logs = %+v, want installer stderr", logs)
+	}
+	if last := (*calls)[len(*calls)-1]; last != "unmount deps" {
+		t.Errorf("last call = %q, want the layer unmounted", last)
+	}
+}
+
+func TestExecuteInstallTimeout(t *testing.T) {
+	agent, _ := depsTestAgent(t)
+	stubInstaller(t, "python", "sleep 30")
+
+	_, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime:     "python",
+		CodeArchive: codeArchive(t, map[string]string{"requirements.txt": "slow", "main.py": "print(1)"}),
+		TimeoutS:    1,
+		Deps:        &fc.DepsRequest{Device: "/dev/vdb", Install: true, MirrorDevice: "/dev/vdc"},
+	})
+
+	if !strings.Contains(resp.Error, "installing dependencies") || resp.ExitCode == 0 {
+		t.Errorf("resp = %+v, want install timeout", resp)
+	}
+}
+
+func TestNodeInstallerCopiesManifests(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	os.MkdirAll(work, 0o755)
+	os.WriteFile(filepath.Join(work, "package.json"), []byte(`{"name":"app"}`), 0o644)
+
+	cmd, err := nodeInstaller(filepath.Join(dir, "deps"), "/mirror", work)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "deps", "node", "package.json"))
+	if err != nil || string(data) != `{"name":"app"}` {
+		t.Errorf("copied package.json = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "deps", "node", "package-lock.json")); err == nil {
+		t.Error("package-lock.json created without one in the code")
+	}
+	args := strings.Join(cmd.Args, " ")
+	if !strings.Contains(args, "--offline --cache /mirror/npm") {
+		t.Errorf("args = %q, want an offline install from the mirror", args)
+	}
+}
+
+func TestDepsEnvNode(t *testing.T) {
+	agent, _ := depsTestAgent(t)
+	os.MkdirAll(agent.workDir, 0o755)
+
+	env, err := agent.depsEnv("node", agent.workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules := filepath.Join(agent.depsDir, "node", "node_modules")
+	if !slices.Contains(env, "NODE_PATH="+modules) {
+		t.Errorf("env = %q, want NODE_PATH", env)
+	}
+	if target, err := os.Readlink(filepath.Join(agent.workDir, "node_modules")); err != nil || target != modules {
+		t.Errorf("node_modules link = %q, %v", target, err)
+	}
+}
+
+func TestExtractCodeArchive(t *testing.T) {
+	agent := &Agent{workDir: filepath.Join(t.TempDir(), "work")}
+
+	archive := codeArchive(t, map[string]string{"main.py": "print(1)"})
+	if err := extractCode(agent.workDir, "", archive, "main.py"); err != nil {
+		t.Fatalf("extractCode: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(agent.workDir, "main.py"))
+	if err != nil || string(data) != "print(1)" {
+		t.Errorf("main.py = %q, %v", data, err)
+	}
+}
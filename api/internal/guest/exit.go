@@ -0,0 +1,85 @@
+package guest
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// vmstatPath holds the kernel's event counters, among them the number of
+// processes the OOM killer has killed since boot.
+const vmstatPath = "/proc/vmstat"
+
+// fatalSignals names the signals that commonly kill a workload but that the
+// host may not send, which signalsByName leaves out.
+var fatalSignals = map[string]syscall.Signal{
+	"SIGILL":  syscall.SIGILL,
+	"SIGTRAP": syscall.SIGTRAP,
+	"SIGABRT": syscall.SIGABRT,
+	"SIGBUS":  syscall.SIGBUS,
+	"SIGFPE":  syscall.SIGFPE,
+	"SIGSEGV": syscall.SIGSEGV,
+	"SIGPIPE": syscall.SIGPIPE,
+	"SIGALRM": syscall.SIGALRM,
+	"SIGXCPU": syscall.SIGXCPU,
+	"SIGXFSZ": syscall.SIGXFSZ,
+	"SIGSYS":  syscall.SIGSYS,
+}
+
+// oomKills returns how many processes the kernel's OOM killer has killed
+// since boot, or -1 if the kernel does not say.
+func oomKills() int64 {
+	f, err := os.Open(vmstatPath)
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	return parseOOMKills(f)
+}
+
+// parseOOMKills returns the oom_kill counter of /proc/vmstat content read
+// from r, or -1 if it has none.
+func parseOOMKills(r io.Reader) int64 {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		name, value, ok := strings.Cut(sc.Text(), " ")
+		if !ok || name != "oom_kill" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	return -1
+}
+
+// classifyExit returns how the workload process described by ps ended, one
+// of the model.ExitReason* constants, and the name of the signal that
+// killed it. timedOut reports that the agent killed the process for running
+// past its timeout, and oomBefore is oomKills as it was when the process
+// started. The whole VM is the workload's memory limit, so a SIGKILL while
+// the OOM killer's counter went up is taken to be the OOM killer's.
+func classifyExit(ps *os.ProcessState, timedOut bool, oomBefore int64) (reason, signal string) {
+	if timedOut {
+		return model.ExitReasonTimeout, ""
+	}
+	if ps == nil {
+		return model.ExitReasonExited, ""
+	}
+	ws, ok := ps.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return model.ExitReasonExited, ""
+	}
+	signal = signalName(ws.Signal())
+	if ws.Signal() == syscall.SIGKILL && oomBefore >= 0 && oomKills() > oomBefore {
+		return model.ExitReasonOOMKilled, signal
+	}
+	return model.ExitReasonSignaled, signal
+}
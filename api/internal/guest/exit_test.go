@@ -0,0 +1,57 @@
+package guest
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+func TestParseOOMKills(t *testing.T) {
+	tests := []struct {
+		name   string
+		vmstat string
+		want   int64
+	}{
+		{"present", "nr_free_pages 1024\noom_kill 3\npgfault 12\n", 3},
+		{"zero", "oom_kill 0\n", 0},
+		{"missing", "nr_free_pages 1024\n", -1},
+		{"malformed", "oom_kill many\n", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseOOMKills(strings.NewReader(tt.vmstat)); got != tt.want {
+				t.Errorf("parseOOMKills() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyExit(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		timedOut   bool
+		wantReason string
+		wantSignal string
+	}{
+		{"exit zero", "exit 0", false, model.ExitReasonExited, ""},
+		{"exit code", "exit 3", false, model.ExitReasonExited, ""},
+		{"signaled", "kill -SEGV $$", false, model.ExitReasonSignaled, "SIGSEGV"},
+		{"sent signal", "kill -TERM $$", false, model.ExitReasonSignaled, "SIGTERM"},
+		{"timed out", "kill -KILL $$", true, model.ExitReasonTimeout, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("sh", "-c", tt.script)
+			_ = cmd.Run()
+			// With no OOM kill counter to compare against, SIGKILL is
+			// never put down to the OOM killer.
+			reason, signal := classifyExit(cmd.ProcessState, tt.timedOut, -1)
+			if reason != tt.wantReason || signal != tt.wantSignal {
+				t.Errorf("classifyExit() = %q, %q, want %q, %q", reason, signal, tt.wantReason, tt.wantSignal)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package guest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// Health check settings used when the host leaves them unset.
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+)
+
+// maxProbeOutput bounds the output of a failed command probe quoted in its
+// error.
+const maxProbeOutput = 256
+
+// healthClient performs HTTP probes. Redirects count as healthy rather than
+// being followed, and connections are not kept between probes.
+var healthClient = &http.Client{
+	Transport: &http.Transport{DisableKeepAlives: true},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// runHealthChecks probes a service with probe every interval, starting once
+// the start period has passed, and reports its health: healthy after a
+// successful probe, and unhealthy after the configured number of
+// consecutive failures. It returns when ctx is done.
+func runHealthChecks(ctx context.Context, hc *fc.HealthCheck, probe func(context.Context) error, report func(status, detail string)) {
+	interval := durationMS(hc.IntervalMS, defaultHealthInterval)
+	timeout := durationMS(hc.TimeoutMS, defaultHealthTimeout)
+
+	timer := time.NewTimer(time.Duration(hc.StartPeriodMS) * time.Millisecond)
+	defer timer.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := probe(probeCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			failures = 0
+			report(fc.HealthHealthy, "")
+		} else if failures++; failures >= max(hc.Retries, 1) {
+			report(fc.HealthUnhealthy, err.Error())
+		}
+		timer.Reset(interval)
+	}
+}
+
+// probe runs the service's health check once.
+func (sv *service) probe(ctx context.Context) error {
+	hc := sv.req.HealthCheck
+	if hc.HTTPPort != 0 {
+		return probeHTTP(ctx, hc.HTTPPort, hc.HTTPPath)
+	}
+	return probeCommand(ctx, hc.Command, sv.workDir, sv.env)
+}
+
+// probeHTTP fetches path from port on the loopback interface and fails
+// unless the response status is below 400.
+func probeHTTP(ctx context.Context, port int, path string) error {
+	if path == "" {
+		path = "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", port, path), nil)
+	if err != nil {
+		return fmt.Errorf("health check request: %w", err)
+	}
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+// probeCommand runs command in its own process group and fails unless it
+// exits zero.
+func probeCommand(ctx context.Context, command []string, dir string, env []string) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return killGroup(cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: timed out", command[0])
+	}
+	msg := strings.TrimSpace(string(out))
+	if len(msg) > maxProbeOutput {
+		msg = msg[:maxProbeOutput] + "..."
+	}
+	if msg == "" {
+		return fmt.Errorf("%s: %v", command[0], err)
+	}
+	return fmt.Errorf("%s: %v: %s", command[0], err, msg)
+}
+
+// durationMS converts ms milliseconds to a duration, or returns def if ms is
+// not positive.
+func durationMS(ms int, def time.Duration) time.Duration {
+	if ms <= 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,119 @@
+package guest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+func TestProbeHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	ctx := context.Background()
+	if err := probeHTTP(ctx, port, "/healthz"); err != nil {
+		t.Errorf("probe /healthz: %v", err)
+	}
+	if err := probeHTTP(ctx, port, "/moved"); err != nil {
+		t.Errorf("probe /moved: %v, want redirects treated as healthy", err)
+	}
+	if err := probeHTTP(ctx, port, "/down"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("probe /down = %v, want a 503 error", err)
+	}
+
+	srv.Close()
+	if err := probeHTTP(ctx, port, "/healthz"); err == nil {
+		t.Error("probe of a closed port succeeded")
+	}
+}
+
+func TestProbeCommand(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if err := probeCommand(ctx, []string{"true"}, dir, nil); err != nil {
+		t.Errorf("probe true: %v", err)
+	}
+	err := probeCommand(ctx, []string{"sh", "-c", "echo not ready; exit 1"}, dir, nil)
+	if err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Errorf("failing probe = %v, want its output in the error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = probeCommand(ctx, []string{"sh", "-c", "sleep 30"}, dir, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("slow probe = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("slow probe took %s, want it killed at the timeout", elapsed)
+	}
+}
+
+func TestRunHealthChecks(t *testing.T) {
+	// The probe fails twice, passes, then fails from the fifth attempt on.
+	var calls int
+	probe := func(context.Context) error {
+		calls++
+		if calls <= 2 || calls >= 5 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var got []string
+	report := func(status, detail string) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, status+" "+detail)
+		if len(got) == 4 {
+			cancel()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runHealthChecks(ctx, &fc.HealthCheck{IntervalMS: 1, Retries: 2}, probe, report)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatal("health checks did not stop when cancelled")
+	}
+
+	want := []string{
+		"unhealthy connection refused",
+		"healthy ",
+		"healthy ",
+		"unhealthy connection refused",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reports = %q, want %q", got, want)
+	}
+}
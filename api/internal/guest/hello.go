@@ -0,0 +1,67 @@
+package guest
+
+import (
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// Version is the guest agent version reported in the handshake. It is set at
+// build time with -ldflags "-X github.com/seantiz/vulcan/internal/guest.Version=...".
+var Version = "dev"
+
+// agentFeatures lists the optional protocol features this agent supports.
+var agentFeatures = []string{fc.FeatureChunkedIO, fc.FeatureControl, fc.FeatureCommand, fc.FeatureDeps, fc.FeatureDepsCommit, fc.FeatureGoBuild, fc.FeatureVolumes, fc.FeatureScratch, fc.FeatureService, fc.FeatureSession, fc.FeatureMultiplex}
+
+// hello builds the agent's half of the handshake. Runtimes are those the
+// rootfs's runtime manifest declares, limited to those whose interpreter or
+// toolchain is actually present in the rootfs.
+func (a *Agent) hello() fc.GuestHello {
+	return fc.GuestHello{
+		AgentVersion:    Version,
+		ProtocolVersion: fc.ProtocolVersion,
+		Runtimes:        a.availableRuntimes(),
+		Features:        agentFeatures,
+		Kernel:          kernelRelease(),
+		Toolchains:      toolchains(),
+		MaxExecs:        cap(a.slots),
+	}
+}
+
+// availableRuntimes returns the declared runtimes whose binaries are found
+// on PATH.
+func (a *Agent) availableRuntimes() []string {
+	var runtimes []string
+	for _, rt := range a.runtimes.Runtimes {
+		if _, err := exec.LookPath(rt.Bin); err == nil {
+			runtimes = append(runtimes, rt.Name)
+		}
+	}
+	sort.Strings(runtimes)
+	return runtimes
+}
+
+// kernelRelease returns the running kernel's release and version strings.
+func kernelRelease() string {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return ""
+	}
+	return utsString(uts.Release[:]) + " " + utsString(uts.Version[:])
+}
+
+// utsString converts a NUL-terminated utsname field to a string. The
+// fields are int8 on some architectures and uint8 on others.
+func utsString[T int8 | uint8](field []T) string {
+	var b strings.Builder
+	for _, c := range field {
+		if c == 0 {
+			break
+		}
+		b.WriteByte(byte(c))
+	}
+	return b.String()
+}
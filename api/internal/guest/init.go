@@ -17,6 +17,7 @@ type mountEntry struct {
 var initMounts = []mountEntry{
 	{source: "proc", target: "/proc", fstype: "proc", flags: 0},
 	{source: "sysfs", target: "/sys", fstype: "sysfs", flags: 0},
+	{source: "cgroup2", target: cgroupRoot, fstype: "cgroup2", flags: 0},
 	{source: "devtmpfs", target: "/dev", fstype: "devtmpfs", flags: 0},
 }
 
@@ -39,6 +40,16 @@ func SetupInit() {
 		}
 	}
 
+	// Workloads run in cgroups of their own, so that their usage covers
+	// every process they start.
+	enableCgroupControllers()
+
+	// Configure networking from the kernel command line. A failure leaves the
+	// workload without connectivity but must not stop the agent from serving.
+	if err := setupNetwork(); err != nil {
+		log.Printf("network setup: %v", err)
+	}
+
 	// Set basic environment.
 	os.Setenv("HOME", "/root")
 	os.Setenv("PATH", "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/usr/local/go/bin")
@@ -0,0 +1,361 @@
+package guest
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// The persistent interpreters of sessions run a driver program that reads
+// requests, one JSON object per line naming the file to run, from file
+// descriptor 3. It runs each file in the interpreter's global scope, then
+// writes the marker given as its argument as a line of its own to stdout
+// and to stderr, so that the agent knows where the execution's output ends,
+// and finally its status, one JSON object per line, to file descriptor 4.
+
+// pythonDriver is the driver program of the python interpreter.
+const pythonDriver = `
+import json, os, sys, traceback
+marker = sys.argv[1]
+requests = os.fdopen(3, "r")
+status = os.fdopen(4, "w")
+scope = {"__name__": "__main__", "__builtins__": __builtins__}
+for line in requests:
+    path = json.loads(line)["path"]
+    code = 0
+    try:
+        sys.argv = [path]
+        with open(path) as f:
+            source = f.read()
+        exec(compile(source, path, "exec"), scope)
+    except SystemExit as e:
+        if e.code is None:
+            code = 0
+        elif isinstance(e.code, int):
+            code = e.code
+        else:
+            print(e.code, file=sys.stderr)
+            code = 1
+    except BaseException:
+        traceback.print_exc()
+        code = 1
+    sys.stdout.flush()
+    sys.stderr.flush()
+    sys.stdout.write(marker + "\n")
+    sys.stdout.flush()
+    sys.stderr.write(marker + "\n")
+    sys.stderr.flush()
+    status.write(json.dumps({"exit_code": code}) + "\n")
+    status.flush()
+`
+
+// nodeDriver is the driver program of the node interpreter. An execution
+// ends when its script returns, or when the promise it evaluates to
+// settles.
+const nodeDriver = `
+const fs = require("fs");
+const readline = require("readline");
+const vm = require("vm");
+const { createRequire } = require("module");
+const marker = process.argv[1];
+let failed = false;
+const fail = (err) => { console.error(err); failed = true; };
+process.on("uncaughtException", fail);
+process.on("unhandledRejection", fail);
+(async () => {
+  const requests = readline.createInterface({ input: fs.createReadStream(null, { fd: 3 }) });
+  for await (const line of requests) {
+    const path = JSON.parse(line).path;
+    failed = false;
+    process.exitCode = undefined;
+    try {
+      globalThis.require = createRequire(path);
+      const result = vm.runInThisContext(fs.readFileSync(path, "utf8"), { filename: path });
+      if (result && typeof result.then === "function") {
+        await result;
+      }
+    } catch (err) {
+      fail(err);
+    }
+    const code = failed ? 1 : (process.exitCode || 0);
+    process.exitCode = undefined;
+    process.stdout.write(marker + "\n");
+    process.stderr.write(marker + "\n");
+    fs.writeSync(4, JSON.stringify({ exit_code: code }) + "\n");
+  }
+})();
+`
+
+// interpreterCommands maps the runtimes that have a persistent interpreter
+// to the command that starts it with marker.
+var interpreterCommands = map[string]func(marker string) (string, []string){
+	"python": func(marker string) (string, []string) {
+		return "python3", []string{"-u", "-c", pythonDriver, marker}
+	},
+	"node": func(marker string) (string, []string) {
+		return "node", []string{"-e", nodeDriver, marker}
+	},
+}
+
+// interpreter is a running persistent interpreter.
+type interpreter struct {
+	cmd    *exec.Cmd
+	cgroup *workloadCgroup
+	marker string
+
+	requests   *os.File
+	statusFile *os.File
+	status     *bufio.Reader
+	stdout     *bufio.Reader
+	stderr     *bufio.Reader
+}
+
+// interpreterStatus is the status the driver reports for an execution.
+type interpreterStatus struct {
+	ExitCode int `json:"exit_code"`
+}
+
+// runInterpreted runs the file at path, already written to the work
+// directory, in the persistent interpreter for req's runtime, starting it
+// with env first if it is not running. An interpreter that exits, or is
+// killed because the execution timed out or was cancelled, is started
+// afresh by the next execution. grace is how long a timed-out or cancelled
+// interpreter has to exit after SIGTERM before it is killed.
+func (a *Agent) runInterpreted(ctx context.Context, s *session, req *fc.GuestRequest, path string, env []string, timeout, grace time.Duration) fc.GuestResponse {
+	start, ok := interpreterCommands[req.Runtime]
+	if !ok || len(req.Command) > 0 || req.Build != nil {
+		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("runtime %q has no persistent interpreter", req.Runtime)}
+	}
+	if s.input != nil || len(req.Input) > 0 {
+		return fc.GuestResponse{ExitCode: 1, Error: "input is not supported with a persistent interpreter"}
+	}
+
+	a.interpMu.Lock()
+	defer a.interpMu.Unlock()
+
+	in := a.interpreters[req.Runtime]
+	if in == nil {
+		bin, args := start(newMarker())
+		var err error
+		if in, err = startInterpreter(s.workDir, bin, args, env); err != nil {
+			return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("start interpreter: %v", err)}
+		}
+		if a.interpreters == nil {
+			a.interpreters = make(map[string]*interpreter)
+		}
+		a.interpreters[req.Runtime] = in
+	}
+
+	output, err := newOutputBuffer(req.StreamOutput)
+	if err != nil {
+		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("stdout buffer: %v", err)}
+	}
+	defer output.Close()
+	stderrBuf, err := newOutputBuffer(req.StreamOutput)
+	if err != nil {
+		return fc.GuestResponse{ExitCode: 1, Error: fmt.Sprintf("stderr buffer: %v", err)}
+	}
+	defer stderrBuf.Close()
+
+	if s.proc.isCancelled() {
+		return fc.GuestResponse{ExitCode: 1, Error: cancelledMessage}
+	}
+
+	// Cancels and timeouts reach the interpreter's process group as they
+	// would a workload's own process.
+	pid := in.cmd.Process.Pid
+	oomBefore := oomKills()
+	s.proc.started(pid)
+	stop := context.AfterFunc(ctx, func() { s.proc.terminate(grace) })
+	status, err := in.run(s, path, output, stderrBuf)
+	stop()
+	s.proc.exited()
+
+	resp := fc.GuestResponse{ExitCode: status.ExitCode, ExitReason: model.ExitReasonExited}
+	if err != nil {
+		// The interpreter is gone; report how it ended.
+		delete(a.interpreters, req.Runtime)
+		resp.Usage = in.cgroup.usage(in.cmd.ProcessState)
+		in.close()
+		resp.ExitCode = 1
+		if in.cmd.ProcessState != nil && in.cmd.ProcessState.ExitCode() > 0 {
+			resp.ExitCode = in.cmd.ProcessState.ExitCode()
+		}
+		timedOut := !s.proc.isCancelled() && ctx.Err() == context.DeadlineExceeded
+		resp.ExitReason, resp.ExitSignal = classifyExit(in.cmd.ProcessState, timedOut, oomBefore)
+		switch {
+		case s.proc.isCancelled():
+			resp.Error = cancelledMessage
+		case timedOut:
+			resp.Error = fmt.Sprintf("timeout after %s", timeout)
+		default:
+			resp.Error = fmt.Sprintf("interpreter exited: %v", err)
+		}
+	} else if status.ExitCode != 0 {
+		resp.Error = fmt.Sprintf("exit status %d", status.ExitCode)
+	}
+	return withOutput(s, req, output, stderrBuf, resp)
+}
+
+// startInterpreter starts a persistent interpreter running bin with args
+// in dir, in its own process group.
+func startInterpreter(dir, bin string, args, env []string) (*interpreter, error) {
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("request pipe: %w", err)
+	}
+	statR, statW, err := os.Pipe()
+	if err != nil {
+		reqR.Close()
+		reqW.Close()
+		return nil, fmt.Errorf("status pipe: %w", err)
+	}
+	// The child's ends are closed here once it has started, or failed to.
+	defer reqR.Close()
+	defer statW.Close()
+
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.ExtraFiles = []*os.File{reqR, statW}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cg := newWorkloadCgroup(cgroupRoot)
+	cg.apply(cmd.SysProcAttr)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		reqW.Close()
+		statR.Close()
+		cg.remove()
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		reqW.Close()
+		statR.Close()
+		cg.remove()
+		return nil, fmt.Errorf("stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		reqW.Close()
+		statR.Close()
+		cg.remove()
+		return nil, err
+	}
+
+	return &interpreter{
+		cmd:        cmd,
+		cgroup:     cg,
+		marker:     args[len(args)-1],
+		requests:   reqW,
+		statusFile: statR,
+		status:     bufio.NewReader(statR),
+		stdout:     bufio.NewReader(stdout),
+		stderr:     bufio.NewReader(stderr),
+	}, nil
+}
+
+// run asks the interpreter to run the file at path, relaying its output as
+// log lines and copying it to stdout and stderr until the driver's markers.
+// An error means the interpreter did not finish the execution and has been
+// reaped.
+func (in *interpreter) run(s *session, path string, stdout, stderr io.Writer) (interpreterStatus, error) {
+	var status interpreterStatus
+	line, err := json.Marshal(map[string]string{"path": path})
+	if err != nil {
+		return status, err
+	}
+	if _, err := in.requests.Write(append(line, '\n')); err != nil {
+		return status, in.wait(fmt.Errorf("send request: %w", err))
+	}
+
+	var wg sync.WaitGroup
+	var stderrDone bool
+	wg.Go(func() { stderrDone = in.relay(s, fc.StreamStderr, in.stderr, stderr) })
+	stdoutDone := in.relay(s, fc.StreamStdout, in.stdout, stdout)
+	wg.Wait()
+	if !stdoutDone || !stderrDone {
+		return status, in.wait(io.ErrUnexpectedEOF)
+	}
+
+	reply, err := in.status.ReadBytes('\n')
+	if err != nil {
+		return status, in.wait(fmt.Errorf("read status: %w", err))
+	}
+	if err := json.Unmarshal(reply, &status); err != nil {
+		return status, in.wait(fmt.Errorf("decode status: %w", err))
+	}
+	return status, nil
+}
+
+// relay streams lines from r as log messages of the given stream and copies
+// them to output until the driver's marker, reporting whether it was found.
+// Output that did not end in a newline before the marker is relayed on its
+// own.
+func (in *interpreter) relay(s *session, stream string, r *bufio.Reader, output io.Writer) bool {
+	streaming := true
+	for {
+		line, err := r.ReadString('\n')
+		text := strings.TrimSuffix(line, "\n")
+		found := strings.HasSuffix(text, in.marker)
+		if found {
+			text = strings.TrimSuffix(text, in.marker)
+			line = text
+		}
+		if line != "" {
+			output.Write([]byte(line))
+			// Once the host is gone, keep draining so that the
+			// interpreter never blocks on a full pipe.
+			if streaming {
+				if err := s.writeMessage(&fc.GuestMessage{Type: fc.MsgTypeLog, Stream: stream, Line: text}); err != nil {
+					log.Printf("write log line: %v", err)
+					streaming = false
+				}
+			}
+		}
+		if found {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// wait kills and reaps the interpreter after it failed to finish an
+// execution, returning how it exited, or cause if it exited cleanly.
+func (in *interpreter) wait(cause error) error {
+	killGroup(in.cmd.Process.Pid, syscall.SIGKILL)
+	if err := in.cmd.Wait(); err != nil {
+		return err
+	}
+	return cause
+}
+
+// close releases the interpreter's request and status pipes and its cgroup.
+func (in *interpreter) close() {
+	in.requests.Close()
+	in.statusFile.Close()
+	in.cgroup.remove()
+}
+
+// newMarker returns a marker that output is vanishingly unlikely to
+// contain by chance.
+func newMarker() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "\x1evulcan-exec-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,131 @@
+package guest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// sessionRequest returns a request running code as an execution in a
+// session, in the runtime's persistent interpreter if interpreter is set.
+func sessionRequest(runtime, code string, interpreter bool) fc.GuestRequest {
+	return fc.GuestRequest{
+		Runtime:  runtime,
+		Code:     code,
+		TimeoutS: 10,
+		Session:  &fc.SessionRequest{Interpreter: interpreter},
+	}
+}
+
+func TestSessionKeepsFiles(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	_, resp := executeWithAgent(t, agent, sessionRequest("python", `open("state.txt", "w").write("kept")`, false))
+	if resp.ExitCode != 0 {
+		t.Fatalf("first exec: exit %d error %q", resp.ExitCode, resp.Error)
+	}
+	_, resp = executeWithAgent(t, agent, sessionRequest("python", `print(open("state.txt").read())`, false))
+	if resp.ExitCode != 0 || strings.TrimSpace(resp.Output) != "kept" {
+		t.Errorf("second exec: exit %d output %q error %q, want the file left by the first", resp.ExitCode, resp.Output, resp.Error)
+	}
+
+	// Executions outside a session start from a clean work directory.
+	_, resp = executeWithAgent(t, agent, fc.GuestRequest{Runtime: "python", Code: `open("state.txt")`, TimeoutS: 10})
+	if resp.ExitCode == 0 {
+		t.Error("state.txt survived an execution outside the session")
+	}
+}
+
+func TestPythonInterpreterKeepsState(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	logs, resp := executeWithAgent(t, agent, sessionRequest("python", "import math\ncounter = 41\nprint('first')", true))
+	if resp.ExitCode != 0 || resp.Output != "first\n" {
+		t.Fatalf("first exec: exit %d output %q error %q", resp.ExitCode, resp.Output, resp.Error)
+	}
+	if len(logs) != 1 || logs[0].Line != "first" {
+		t.Errorf("first exec logs = %+v, want the one line", logs)
+	}
+
+	_, resp = executeWithAgent(t, agent, sessionRequest("python", "counter += 1\nprint(counter, math.floor(2.5), end='')", true))
+	if resp.ExitCode != 0 || resp.Output != "42 2" {
+		t.Errorf("second exec: exit %d output %q error %q, want the state of the first", resp.ExitCode, resp.Output, resp.Error)
+	}
+
+	_, resp = executeWithAgent(t, agent, sessionRequest("python", "import sys\nsys.exit(3)", true))
+	if resp.ExitCode != 3 || resp.Error != "exit status 3" {
+		t.Errorf("sys.exit(3): exit %d error %q, want 3", resp.ExitCode, resp.Error)
+	}
+	_, resp = executeWithAgent(t, agent, sessionRequest("python", "raise ValueError('bad')", true))
+	if resp.ExitCode != 1 || !strings.Contains(resp.Output, "ValueError: bad") {
+		t.Errorf("raise: exit %d output %q, want 1 with the traceback", resp.ExitCode, resp.Output)
+	}
+	_, resp = executeWithAgent(t, agent, sessionRequest("python", "print(counter)", true))
+	if strings.TrimSpace(resp.Output) != "42" {
+		t.Errorf("after errors: output %q error %q, want the interpreter kept", resp.Output, resp.Error)
+	}
+}
+
+func TestInterpreterRestartsAfterTimeout(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	executeWithAgent(t, agent, sessionRequest("python", "x = 1", true))
+	req := sessionRequest("python", "import time\ntime.sleep(30)", true)
+	req.TimeoutS = 1
+	_, resp := executeWithAgent(t, agent, req)
+	if resp.ExitCode == 0 || resp.Error != "timeout after 1s" {
+		t.Fatalf("sleep: exit %d error %q, want a timeout", resp.ExitCode, resp.Error)
+	}
+
+	_, resp = executeWithAgent(t, agent, sessionRequest("python", "print('x' in globals())", true))
+	if resp.ExitCode != 0 || strings.TrimSpace(resp.Output) != "False" {
+		t.Errorf("after timeout: exit %d output %q error %q, want a fresh interpreter", resp.ExitCode, resp.Output, resp.Error)
+	}
+}
+
+func TestNodeInterpreterKeepsState(t *testing.T) {
+	if _, err := findExecutable("node"); err != nil {
+		t.Skip("node not available")
+	}
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	_, resp := executeWithAgent(t, agent, sessionRequest("node", `var path = require("path"); var counter = 41;`, true))
+	if resp.ExitCode != 0 {
+		t.Fatalf("first exec: exit %d output %q error %q", resp.ExitCode, resp.Output, resp.Error)
+	}
+	_, resp = executeWithAgent(t, agent, sessionRequest("node",
+		`(async () => { await new Promise((r) => setTimeout(r, 10)); console.log(++counter, path.basename("/a/b")); })()`, true))
+	if resp.ExitCode != 0 || resp.Output != "42 b\n" {
+		t.Errorf("second exec: exit %d output %q error %q, want the state of the first", resp.ExitCode, resp.Output, resp.Error)
+	}
+	_, resp = executeWithAgent(t, agent, sessionRequest("node", `throw new Error("bad")`, true))
+	if resp.ExitCode != 1 || !strings.Contains(resp.Output, "bad") {
+		t.Errorf("throw: exit %d output %q, want 1 with the error", resp.ExitCode, resp.Output)
+	}
+}
+
+func TestInterpreterRejectsInput(t *testing.T) {
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	req := sessionRequest("python", "print(1)", true)
+	req.Input = []byte("data")
+	_, resp := executeWithAgent(t, agent, req)
+	if resp.ExitCode == 0 || !strings.Contains(resp.Error, "input is not supported") {
+		t.Errorf("exit %d error %q, want input rejected", resp.ExitCode, resp.Error)
+	}
+	_, resp = executeWithAgent(t, agent, sessionRequest("go", "package main", true))
+	if resp.ExitCode == 0 || !strings.Contains(resp.Error, "no persistent interpreter") {
+		t.Errorf("go: exit %d error %q, want no interpreter", resp.ExitCode, resp.Error)
+	}
+}
@@ -0,0 +1,90 @@
+package guest
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// DefaultMaxExecs is how many requests the agent executes at once unless
+// the host sets fc.BootParamMaxExecs.
+const DefaultMaxExecs = 4
+
+// sessionWorkDir is the work directory, under the agent's, shared by the
+// executions of a session.
+const sessionWorkDir = "session"
+
+// SetMaxExecs sets how many requests the agent executes at once; n <= 0
+// keeps the default. It must be called before Serve.
+func (a *Agent) SetMaxExecs(n int) {
+	if n > 0 {
+		a.slots = make(chan struct{}, n)
+	}
+}
+
+// acquireSlot waits until fewer requests than the agent's limit are being
+// executed, or the host has gone away, and returns the function that frees
+// the slot again.
+func (a *Agent) acquireSlot(s *session) (func(), error) {
+	if a.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case a.slots <- struct{}{}:
+		return func() { <-a.slots }, nil
+	case <-s.done:
+		return nil, errors.New("host went away while waiting for an execution slot")
+	}
+}
+
+// newWorkDir creates the work directory for req: a fresh one, for the
+// caller to remove, or the session's, which outlives the request.
+func (a *Agent) newWorkDir(req *fc.GuestRequest) (string, error) {
+	if err := os.MkdirAll(a.workDir, 0o755); err != nil {
+		return "", err
+	}
+	if req.Session != nil {
+		dir := filepath.Join(a.workDir, sessionWorkDir)
+		return dir, os.MkdirAll(dir, 0o755)
+	}
+	return os.MkdirTemp(a.workDir, "exec-")
+}
+
+// BootMaxExecs returns the execution limit the host set on the kernel
+// command line, or 0 if it set none.
+func BootMaxExecs() int {
+	cmdline, err := os.ReadFile(cmdlinePath)
+	if err != nil {
+		log.Printf("read %s: %v", cmdlinePath, err)
+		return 0
+	}
+	n, err := parseMaxExecs(string(cmdline))
+	if err != nil {
+		log.Printf("%v, using the default of %d", err, DefaultMaxExecs)
+		return 0
+	}
+	return n
+}
+
+// parseMaxExecs extracts fc.BootParamMaxExecs from a kernel command line,
+// returning 0 if it is absent.
+func parseMaxExecs(cmdline string) (int, error) {
+	for _, field := range strings.Fields(cmdline) {
+		v, ok := strings.CutPrefix(field, fc.BootParamMaxExecs+"=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid %s %q", fc.BootParamMaxExecs, v)
+		}
+		return n, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,87 @@
+package guest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+func TestParseMaxExecs(t *testing.T) {
+	tests := []struct {
+		cmdline string
+		want    int
+		wantErr bool
+	}{
+		{"console=ttyS0 reboot=k", 0, false},
+		{"console=ttyS0 vulcan.max_execs=8 panic=1", 8, false},
+		{"vulcan.max_execs=0", 0, true},
+		{"vulcan.max_execs=many", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMaxExecs(tt.cmdline)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseMaxExecs(%q) = %d, %v; want %d, error %v", tt.cmdline, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestConcurrentExecutionsGetOwnWorkDirs(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	// Each execution writes the same file name, waits for the others to
+	// have written theirs, and reads it back.
+	const n = 3
+	var wg sync.WaitGroup
+	outputs := make([]string, n)
+	for i := range n {
+		wg.Go(func() {
+			code := fmt.Sprintf("import os, time\nopen('state.txt', 'w').write('%d')\ntime.sleep(0.5)\nprint(open('state.txt').read(), os.getpgrp() == os.getpid())", i)
+			_, resp := executeWithAgent(t, agent, fc.GuestRequest{Runtime: "python", Code: code, TimeoutS: 10})
+			if resp.ExitCode != 0 {
+				t.Errorf("execution %d: exit %d error %q", i, resp.ExitCode, resp.Error)
+			}
+			outputs[i] = strings.TrimSpace(resp.Output)
+		})
+	}
+	wg.Wait()
+
+	for i, out := range outputs {
+		if want := fmt.Sprintf("%d True", i); out != want {
+			t.Errorf("execution %d output = %q, want %q from its own work dir and process group", i, out, want)
+		}
+	}
+}
+
+func TestMaxExecsQueuesRequests(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+	agent.SetMaxExecs(1)
+	if got := agent.hello().MaxExecs; got != 1 {
+		t.Errorf("hello MaxExecs = %d, want 1", got)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Go(func() {
+			_, resp := executeWithAgent(t, agent, fc.GuestRequest{Runtime: "python", Code: "import time\ntime.sleep(0.5)", TimeoutS: 10})
+			if resp.ExitCode != 0 {
+				t.Errorf("exit %d error %q", resp.ExitCode, resp.Error)
+			}
+		})
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("two executions took %s with a limit of one, want them run one after the other", elapsed)
+	}
+}
@@ -0,0 +1,215 @@
+package guest
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+const (
+	// cmdlinePath is where the kernel exposes its boot parameters.
+	cmdlinePath = "/proc/cmdline"
+
+	// resolvConfPath is the resolver configuration written for workloads.
+	resolvConfPath = "/etc/resolv.conf"
+)
+
+// guestNetwork is the static network configuration the host passes on the
+// kernel command line.
+type guestNetwork struct {
+	device      string
+	addr        netip.Prefix
+	gateway     netip.Addr
+	nameservers []string
+	search      []string
+	mtu         int
+	addr6       netip.Prefix
+	gateway6    netip.Addr
+}
+
+// parseNetworkParams extracts the static configuration from a kernel command
+// line. The IPv4 settings come from the ip= parameter in the kernel's nfsroot
+// format:
+//
+//	ip=<client-ip>:<server-ip>:<gw-ip>:<netmask>:<hostname>:<device>:<autoconf>:<dns0-ip>:<dns1-ip>
+//
+// and the MTU, IPv6 and DNS search settings from the host's vulcan.*
+// parameters. It
+// returns nil if the command line has no static ip= parameter, as for VMs
+// started without a network interface.
+func parseNetworkParams(cmdline string) (*guestNetwork, error) {
+	params := make(map[string]string)
+	for _, field := range strings.Fields(cmdline) {
+		if k, v, ok := strings.Cut(field, "="); ok {
+			params[k] = v
+		}
+	}
+	param := params["ip"]
+	fields := strings.Split(param, ":")
+	if param == "" || len(fields) < 4 {
+		return nil, nil
+	}
+
+	addr, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse client ip %q: %w", fields[0], err)
+	}
+	mask := net.ParseIP(fields[3]).To4()
+	if mask == nil {
+		return nil, fmt.Errorf("parse netmask %q", fields[3])
+	}
+	ones, bits := net.IPMask(mask).Size()
+	if bits == 0 {
+		return nil, fmt.Errorf("non-contiguous netmask %q", fields[3])
+	}
+
+	cfg := &guestNetwork{
+		device: fc.GuestIfName,
+		addr:   netip.PrefixFrom(addr, ones),
+	}
+	if fields[2] != "" {
+		if cfg.gateway, err = netip.ParseAddr(fields[2]); err != nil {
+			return nil, fmt.Errorf("parse gateway %q: %w", fields[2], err)
+		}
+	}
+	if len(fields) > 5 && fields[5] != "" {
+		cfg.device = fields[5]
+	}
+	for i := 7; i < len(fields) && i <= 8; i++ {
+		if fields[i] != "" {
+			cfg.nameservers = append(cfg.nameservers, fields[i])
+		}
+	}
+
+	if v, ok := params[fc.BootParamMTU]; ok {
+		if cfg.mtu, err = strconv.Atoi(v); err != nil || cfg.mtu <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", fc.BootParamMTU, v)
+		}
+	}
+	if v, ok := params[fc.BootParamIP6]; ok {
+		if cfg.addr6, err = netip.ParsePrefix(v); err != nil || !cfg.addr6.Addr().Is6() {
+			return nil, fmt.Errorf("invalid %s %q", fc.BootParamIP6, v)
+		}
+	}
+	if v, ok := params[fc.BootParamGateway6]; ok {
+		if cfg.gateway6, err = netip.ParseAddr(v); err != nil || !cfg.gateway6.Is6() {
+			return nil, fmt.Errorf("invalid %s %q", fc.BootParamGateway6, v)
+		}
+	}
+	if v, ok := params[fc.BootParamSearch]; ok {
+		for domain := range strings.SplitSeq(v, ",") {
+			if domain != "" {
+				cfg.search = append(cfg.search, domain)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// setupNetwork brings up the loopback interface and, when the host passed a
+// static configuration on the kernel command line, the VM's network
+// interface, its default route and the resolver configuration. Applying the
+// configuration is idempotent, so it is safe when the kernel has already
+// configured the interface itself.
+func setupNetwork() error {
+	if err := linkUp("lo"); err != nil {
+		return err
+	}
+
+	cmdline, err := os.ReadFile(cmdlinePath)
+	if err != nil {
+		return fmt.Errorf("read kernel command line: %w", err)
+	}
+	cfg, err := parseNetworkParams(string(cmdline))
+	if err != nil {
+		return fmt.Errorf("parse network boot parameters: %w", err)
+	}
+	if cfg == nil {
+		return nil
+	}
+
+	link, err := netlink.LinkByName(cfg.device)
+	if err != nil {
+		return fmt.Errorf("find interface %s: %w", cfg.device, err)
+	}
+	if cfg.mtu > 0 {
+		if err := netlink.LinkSetMTU(link, cfg.mtu); err != nil {
+			return fmt.Errorf("set %s MTU %d: %w", cfg.device, cfg.mtu, err)
+		}
+	}
+	if err := netlink.AddrReplace(link, netlinkAddr(cfg.addr)); err != nil {
+		return fmt.Errorf("assign %s to %s: %w", cfg.addr, cfg.device, err)
+	}
+	if cfg.addr6.IsValid() {
+		// Skip duplicate address detection: IPAM guarantees the address is
+		// unique, and DAD would leave it unusable for the first seconds.
+		addr := netlinkAddr(cfg.addr6)
+		addr.Flags = syscall.IFA_F_NODAD
+		if err := netlink.AddrReplace(link, addr); err != nil {
+			return fmt.Errorf("assign %s to %s: %w", cfg.addr6, cfg.device, err)
+		}
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("bring up %s: %w", cfg.device, err)
+	}
+	for _, gw := range []netip.Addr{cfg.gateway, cfg.gateway6} {
+		if !gw.IsValid() {
+			continue
+		}
+		route := &netlink.Route{LinkIndex: link.Attrs().Index, Gw: gw.AsSlice()}
+		if err := netlink.RouteReplace(route); err != nil {
+			return fmt.Errorf("add default route via %s: %w", gw, err)
+		}
+	}
+
+	if len(cfg.nameservers) > 0 {
+		// Replace rather than write through: images often ship resolv.conf
+		// as a symlink into a resolver daemon's runtime directory.
+		os.Remove(resolvConfPath)
+		if err := os.WriteFile(resolvConfPath, resolvConf(cfg.nameservers, cfg.search), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", resolvConfPath, err)
+		}
+	}
+	return nil
+}
+
+// netlinkAddr converts a prefix to a netlink interface address.
+func netlinkAddr(p netip.Prefix) *netlink.Addr {
+	return &netlink.Addr{IPNet: &net.IPNet{
+		IP:   p.Addr().AsSlice(),
+		Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen()),
+	}}
+}
+
+// linkUp sets the named interface up.
+func linkUp(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("find interface %s: %w", name, err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("bring up %s: %w", name, err)
+	}
+	return nil
+}
+
+// resolvConf renders a resolv.conf listing the given nameservers and search
+// domains.
+func resolvConf(nameservers, search []string) []byte {
+	var b strings.Builder
+	if len(search) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(search, " "))
+	}
+	for _, ns := range nameservers {
+		fmt.Fprintf(&b, "nameserver %s\n", ns)
+	}
+	return []byte(b.String())
+}
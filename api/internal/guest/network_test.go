@@ -0,0 +1,129 @@
+package guest
+
+import (
+	"slices"
+	"testing"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+func TestParseIPParam(t *testing.T) {
+	cmdline := "console=ttyS0 reboot=k panic=1 pci=off init=/usr/local/bin/vulcan-guest ip=10.168.0.7::10.168.0.1:255.255.255.0::eth0:off:1.1.1.1:8.8.8.8:"
+
+	cfg, err := parseNetworkParams(cmdline)
+	if err != nil {
+		t.Fatalf("parseNetworkParams: %v", err)
+	}
+	if cfg == nil {
+		t.Fatal("parseNetworkParams returned nil config")
+	}
+	if cfg.device != "eth0" {
+		t.Errorf("device = %q, want eth0", cfg.device)
+	}
+	if cfg.addr.String() != "10.168.0.7/24" {
+		t.Errorf("addr = %s, want 10.168.0.7/24", cfg.addr)
+	}
+	if cfg.gateway.String() != "10.168.0.1" {
+		t.Errorf("gateway = %s, want 10.168.0.1", cfg.gateway)
+	}
+	if !slices.Equal(cfg.nameservers, []string{"1.1.1.1", "8.8.8.8"}) {
+		t.Errorf("nameservers = %v, want [1.1.1.1 8.8.8.8]", cfg.nameservers)
+	}
+}
+
+func TestParseIPParamDefaults(t *testing.T) {
+	cfg, err := parseNetworkParams("ip=10.168.0.9::10.168.0.1:255.255.0.0::::")
+	if err != nil {
+		t.Fatalf("parseNetworkParams: %v", err)
+	}
+	if cfg.device != fc.GuestIfName {
+		t.Errorf("device = %q, want %q", cfg.device, fc.GuestIfName)
+	}
+	if cfg.addr.String() != "10.168.0.9/16" {
+		t.Errorf("addr = %s, want 10.168.0.9/16", cfg.addr)
+	}
+	if len(cfg.nameservers) != 0 {
+		t.Errorf("nameservers = %v, want none", cfg.nameservers)
+	}
+}
+
+func TestParseIPParamAbsent(t *testing.T) {
+	for _, cmdline := range []string{
+		"console=ttyS0 reboot=k panic=1",
+		"console=ttyS0 ip=off",
+		"ip=dhcp",
+	} {
+		cfg, err := parseNetworkParams(cmdline)
+		if err != nil || cfg != nil {
+			t.Errorf("parseNetworkParams(%q) = %+v, %v; want nil, nil", cmdline, cfg, err)
+		}
+	}
+}
+
+func TestParseIPParamInvalid(t *testing.T) {
+	for _, cmdline := range []string{
+		"ip=10.168.0::10.168.0.1:255.255.255.0",
+		"ip=10.168.0.7::10.168.0.1:255.0.255.0",
+		"ip=10.168.0.7::gateway:255.255.255.0",
+	} {
+		if _, err := parseNetworkParams(cmdline); err == nil {
+			t.Errorf("parseNetworkParams(%q): expected error", cmdline)
+		}
+	}
+}
+
+func TestResolvConf(t *testing.T) {
+	got := string(resolvConf([]string{"1.1.1.1", "8.8.8.8"}, nil))
+	want := "nameserver 1.1.1.1\nnameserver 8.8.8.8\n"
+	if got != want {
+		t.Errorf("resolvConf = %q, want %q", got, want)
+	}
+
+	got = string(resolvConf([]string{"10.169.0.1"}, []string{"shop.vulcan"}))
+	want = "search shop.vulcan\nnameserver 10.169.0.1\n"
+	if got != want {
+		t.Errorf("resolvConf with search = %q, want %q", got, want)
+	}
+}
+
+func TestParseNetworkParamsSearch(t *testing.T) {
+	cfg, err := parseNetworkParams("ip=10.169.0.7::10.169.0.1:255.255.255.0::eth0:off:10.169.0.1:: vulcan.search=shop.vulcan")
+	if err != nil {
+		t.Fatalf("parseNetworkParams: %v", err)
+	}
+	if !slices.Equal(cfg.search, []string{"shop.vulcan"}) {
+		t.Errorf("search = %v, want [shop.vulcan]", cfg.search)
+	}
+}
+
+func TestParseNetworkParamsDualStack(t *testing.T) {
+	cmdline := "ip=10.168.0.7::10.168.0.1:255.255.255.0::eth0:off:: vulcan.mtu=1400 vulcan.ip6=fd00:168::7/64 vulcan.gw6=fd00:168::1"
+
+	cfg, err := parseNetworkParams(cmdline)
+	if err != nil {
+		t.Fatalf("parseNetworkParams: %v", err)
+	}
+	if cfg.mtu != 1400 {
+		t.Errorf("mtu = %d, want 1400", cfg.mtu)
+	}
+	if cfg.addr6.String() != "fd00:168::7/64" {
+		t.Errorf("addr6 = %s, want fd00:168::7/64", cfg.addr6)
+	}
+	if cfg.gateway6.String() != "fd00:168::1" {
+		t.Errorf("gateway6 = %s, want fd00:168::1", cfg.gateway6)
+	}
+}
+
+func TestParseNetworkParamsInvalidExtras(t *testing.T) {
+	base := "ip=10.168.0.7::10.168.0.1:255.255.255.0::eth0:off:: "
+	for _, extra := range []string{
+		"vulcan.mtu=big",
+		"vulcan.mtu=0",
+		"vulcan.ip6=10.0.0.1/24",
+		"vulcan.gw6=fd00::/64",
+	} {
+		if _, err := parseNetworkParams(base + extra); err == nil {
+			t.Errorf("parseNetworkParams(%q): expected error", extra)
+		}
+	}
+}
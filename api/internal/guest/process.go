@@ -0,0 +1,226 @@
+package guest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// defaultGrace is used when a cancel message or request does not specify a
+// grace period.
+const defaultGrace = 5 * time.Second
+
+// cancelledMessage is the error reported for a workload cancelled by the host.
+const cancelledMessage = "cancelled by host"
+
+// errNoProcess is returned when a signal arrives while no workload process is running.
+var errNoProcess = errors.New("no running workload process")
+
+// signalsByName maps the signal names accepted in signal messages to signals.
+var signalsByName = map[string]syscall.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGINT":   syscall.SIGINT,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGKILL":  syscall.SIGKILL,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGCONT":  syscall.SIGCONT,
+	"SIGSTOP":  syscall.SIGSTOP,
+	"SIGWINCH": syscall.SIGWINCH,
+}
+
+// process tracks a request's workload process so that control messages from
+// the host act on its whole process group. The workload runs as the leader
+// of its own process group, so signals reach any children it spawned.
+type process struct {
+	mu        sync.Mutex
+	state     string
+	pid       int
+	startedAt time.Time
+	cancelled bool
+	killTimer *time.Timer
+
+	// stop is closed when the request is cancelled.
+	stop chan struct{}
+}
+
+// newProcess returns a process in the preparing state.
+func newProcess() *process {
+	return &process{state: fc.ProcessPreparing, stop: make(chan struct{})}
+}
+
+// started records that the workload process is running. If the request was
+// cancelled while it was being prepared, the process group is killed at once.
+func (p *process) started(pid int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pid = pid
+	p.startedAt = time.Now()
+	p.state = fc.ProcessRunning
+	if p.cancelled {
+		p.state = fc.ProcessTerminating
+		killGroup(pid, syscall.SIGKILL)
+	}
+}
+
+// exited records that the workload process has been reaped.
+func (p *process) exited() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state = fc.ProcessExited
+	if p.killTimer != nil {
+		p.killTimer.Stop()
+	}
+}
+
+// isCancelled reports whether the host cancelled the request.
+func (p *process) isCancelled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cancelled
+}
+
+// stopped returns a channel that is closed when the request is cancelled.
+func (p *process) stopped() <-chan struct{} {
+	return p.stop
+}
+
+// cancel terminates the process group as terminate does and records that
+// the host cancelled the request. Subsequent calls are no-ops.
+func (p *process) cancel(grace time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancelled {
+		return
+	}
+	p.cancelled = true
+	close(p.stop)
+	p.terminateLocked(grace)
+}
+
+// terminate stops the process group: SIGTERM now, then SIGKILL once grace
+// has elapsed if the process is still running. It does nothing unless the
+// process is running and not already being terminated.
+func (p *process) terminate(grace time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.terminateLocked(grace)
+}
+
+// terminateLocked is terminate with p.mu held.
+func (p *process) terminateLocked(grace time.Duration) {
+	if p.state != fc.ProcessRunning {
+		return
+	}
+
+	p.state = fc.ProcessTerminating
+	killGroup(p.pid, syscall.SIGTERM)
+	p.killTimer = time.AfterFunc(grace, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.state != fc.ProcessExited {
+			killGroup(p.pid, syscall.SIGKILL)
+		}
+	})
+}
+
+// signal delivers the named signal to the process group.
+func (p *process) signal(name string) error {
+	sig, ok := signalsByName[name]
+	if !ok {
+		return fmt.Errorf("unsupported signal %q", name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != fc.ProcessRunning && p.state != fc.ProcessTerminating {
+		return errNoProcess
+	}
+	return killGroup(p.pid, sig)
+}
+
+// heartbeat returns a snapshot of the process state.
+func (p *process) heartbeat() fc.Heartbeat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hb := fc.Heartbeat{State: p.state, PID: p.pid}
+	if p.pid != 0 {
+		hb.ElapsedMS = time.Since(p.startedAt).Milliseconds()
+		if p.state != fc.ProcessExited {
+			hb.ProcState = procState(p.pid)
+		}
+	}
+	return hb
+}
+
+// killGroup sends sig to the process group led by pid.
+func killGroup(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("signal process group %d: %w", pid, err)
+	}
+	return nil
+}
+
+// procState returns the scheduler state letter from /proc/<pid>/stat, or ""
+// if it cannot be read.
+func procState(pid int) string {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return ""
+	}
+	// The command name is parenthesised and may contain spaces, so the state
+	// is the first field after the last closing parenthesis.
+	stat := string(data)
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 || i+2 >= len(stat) {
+		return ""
+	}
+	return stat[i+2 : i+3]
+}
+
+// runLogged runs a preparatory command, such as an installer or compiler, in
+// its own process group, sending each line of its combined output to the
+// host in a copy of msg. The group is killed if ctx expires.
+func runLogged(ctx context.Context, s *session, msg fc.GuestMessage, cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	pr, pw := io.Pipe()
+	cmd.Stdout, cmd.Stderr = pw, pw
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		killGroup(cmd.Process.Pid, syscall.SIGKILL)
+	})
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		streamLinesAs(s, msg, pr, io.Discard)
+	}()
+	err := cmd.Wait()
+	pw.Close()
+	<-done
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package guest
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// runtimeManifestPath is where the agent reads the runtimes it runs.
+var runtimeManifestPath = fc.RuntimeManifestPath
+
+// loadRuntimes returns the runtimes declared by the manifest at path. A
+// rootfs without a manifest, built before runtimes were declared in the
+// image, gets fc.DefaultRuntimes, as does one whose manifest is invalid,
+// which the host sends no workloads.
+func loadRuntimes(path string) fc.RuntimeManifest {
+	m, err := fc.LoadRuntimeManifest(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fc.DefaultRuntimes
+	}
+	if err != nil {
+		log.Printf("load runtime manifest %s: %v; using built-in runtimes", path, err)
+		return fc.DefaultRuntimes
+	}
+	return m
+}
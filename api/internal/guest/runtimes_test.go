@@ -0,0 +1,78 @@
+package guest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+func TestLoadRuntimes(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	if err := os.WriteFile(valid, []byte(`{"runtimes": [{"name": "sh", "bin": "sh", "extension": ".sh"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"runtimes": [{"name": "sh"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"manifest", valid, []string{"sh"}},
+		{"missing", filepath.Join(dir, "missing.json"), fc.DefaultRuntimes.Names()},
+		{"invalid", invalid, fc.DefaultRuntimes.Names()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loadRuntimes(tt.path).Names(); !slices.Equal(got, tt.want) {
+				t.Errorf("loadRuntimes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteManifestRuntime(t *testing.T) {
+	if _, err := findExecutable("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	m, err := fc.ParseRuntimeManifest([]byte(`{"runtimes": [{
+		"name": "sh", "bin": "sh", "args": ["-e", "{entrypoint}", "from-args"],
+		"extension": ".sh", "env": {"GREETING": "hello", "TARGET": "manifest"}
+	}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+	agent.runtimes = m
+
+	if got := agent.availableRuntimes(); !slices.Equal(got, []string{"sh"}) {
+		t.Errorf("availableRuntimes = %v, want [sh]", got)
+	}
+
+	_, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime:  "sh",
+		Code:     `echo "$GREETING $TARGET $1 $(basename "$0")"`,
+		Env:      map[string]string{"TARGET": "request"},
+		TimeoutS: 10,
+	})
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, Error = %q", resp.ExitCode, resp.Error)
+	}
+	if want := "hello request from-args main.sh"; strings.TrimSpace(resp.Output) != want {
+		t.Errorf("Output = %q, want %q", resp.Output, want)
+	}
+
+	_, resp = executeWithAgent(t, agent, fc.GuestRequest{Runtime: "python", Code: "print(1)", TimeoutS: 10})
+	if !strings.Contains(resp.Error, "unsupported runtime") {
+		t.Errorf("Error = %q, want python unsupported without a manifest entry", resp.Error)
+	}
+}
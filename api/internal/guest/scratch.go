@@ -0,0 +1,97 @@
+package guest
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+)
+
+// Default mount points of the scratch disk and of the temporary directory
+// bound to it.
+const (
+	defaultScratchDir = "/scratch"
+	defaultTmpDir     = "/tmp"
+)
+
+// scratch is a mounted scratch disk.
+type scratch struct {
+	dir      string
+	targets  []string // mount points, in the order they were mounted
+	baseline int64    // bytes in use on the freshly formatted filesystem
+}
+
+// attachScratch mounts the scratch disk on device and binds its directories
+// onto /tmp and the working directory, so that what the workload writes
+// there is bounded by the disk's size. Either everything is mounted or, on
+// error, nothing remains mounted.
+func (a *Agent) attachScratch(device string) (*scratch, error) {
+	sc := &scratch{dir: a.scratchDir}
+	if err := a.mount(device, sc.dir, false); err != nil {
+		return nil, err
+	}
+	sc.targets = append(sc.targets, sc.dir)
+	sc.baseline = usedBytes(sc.dir)
+
+	for _, bind := range []struct {
+		name   string
+		target string
+		mode   os.FileMode
+	}{
+		{"tmp", a.tmpDir, 0o777 | os.ModeSticky},
+		{"work", a.workDir, 0o755},
+	} {
+		source := filepath.Join(sc.dir, bind.name)
+		err := os.Mkdir(source, bind.mode.Perm())
+		if err == nil {
+			// Mkdir applies the umask and ignores the sticky bit.
+			err = os.Chmod(source, bind.mode)
+		}
+		if err == nil {
+			err = os.MkdirAll(bind.target, 0o755)
+		}
+		if err == nil {
+			err = a.bindMount(source, bind.target)
+		}
+		if err != nil {
+			a.detachScratch(sc)
+			return nil, err
+		}
+		sc.targets = append(sc.targets, bind.target)
+	}
+	return sc, nil
+}
+
+// detachScratch unmounts the scratch disk and its bind mounts.
+func (a *Agent) detachScratch(sc *scratch) {
+	for _, target := range slices.Backward(sc.targets) {
+		if err := a.unmount(target); err != nil {
+			log.Printf("detach scratch disk: %v", err)
+		}
+	}
+}
+
+// used returns the space the workload has taken on the scratch disk.
+func (sc *scratch) used() int64 {
+	return max(usedBytes(sc.dir)-sc.baseline, 0)
+}
+
+// usedBytes returns the space in use on the filesystem holding path, or
+// zero if it cannot be determined.
+func usedBytes(path string) int64 {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0
+	}
+	return int64(st.Blocks-st.Bfree) * int64(st.Bsize)
+}
+
+// bindMount makes the directory source also visible at target.
+func bindMount(source, target string) error {
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("bind %s on %s: %w", source, target, err)
+	}
+	return nil
+}
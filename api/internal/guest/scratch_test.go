@@ -0,0 +1,123 @@
+package guest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// scratchTestAgent is depsTestAgent with the scratch disk and /tmp in plain
+// directories, also recording bind mounts instead of making them.
+func scratchTestAgent(t *testing.T) (*Agent, *[]string) {
+	t.Helper()
+	agent, calls := depsTestAgent(t)
+	dir := t.TempDir()
+	agent.scratchDir = filepath.Join(dir, "scratch")
+	agent.tmpDir = filepath.Join(dir, "tmp")
+	agent.bindMount = func(source, target string) error {
+		*calls = append(*calls, "bind "+filepath.Base(source)+" "+filepath.Base(target))
+		return nil
+	}
+	return agent, calls
+}
+
+func TestExecuteOnScratchDisk(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent, calls := scratchTestAgent(t)
+
+	_, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime:       "python",
+		Code:          "open('out.txt', 'w').write('scratch')\nprint('done')",
+		TimeoutS:      10,
+		ScratchDevice: "/dev/vdc",
+	})
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, Error = %q", resp.ExitCode, resp.Error)
+	}
+	if resp.Usage == nil || resp.Usage.DiskUsedBytes < 0 {
+		t.Errorf("Usage = %+v, want disk usage reported", resp.Usage)
+	}
+	want := []string{
+		"mount /dev/vdc scratch rw",
+		"bind tmp tmp",
+		"bind work work",
+		"unmount work",
+		"unmount tmp",
+		"unmount scratch",
+	}
+	if !slices.Equal(*calls, want) {
+		t.Errorf("calls = %q, want %q", *calls, want)
+	}
+	info, err := os.Stat(filepath.Join(agent.scratchDir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSticky == 0 || info.Mode().Perm() != 0o777 {
+		t.Errorf("scratch tmp mode = %v, want sticky and world-writable", info.Mode())
+	}
+}
+
+func TestExecuteScratchDiskFailure(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent, calls := scratchTestAgent(t)
+	bind := agent.bindMount
+	agent.bindMount = func(source, target string) error {
+		if filepath.Base(source) == "work" {
+			return errors.New("no space")
+		}
+		return bind(source, target)
+	}
+
+	_, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime:       "python",
+		Code:          "print('unreachable')",
+		TimeoutS:      10,
+		ScratchDevice: "/dev/vdc",
+	})
+	if resp.ExitCode == 0 || resp.Error != "scratch disk: no space" {
+		t.Errorf("response = %+v, want a scratch disk failure", resp)
+	}
+	want := []string{"mount /dev/vdc scratch rw", "bind tmp tmp", "unmount tmp", "unmount scratch"}
+	if !slices.Equal(*calls, want) {
+		t.Errorf("calls = %q, want %q", *calls, want)
+	}
+}
+
+func TestExecutionKeepsWorkDir(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	workDir := filepath.Join(t.TempDir(), "work")
+	agent := New(nil, workDir)
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, resp := executeWithAgent(t, agent, fc.GuestRequest{Runtime: "python", Code: "open('out.txt', 'w').write('x')", TimeoutS: 10})
+	if resp.ExitCode != 0 {
+		t.Fatalf("exit %d error %q", resp.ExitCode, resp.Error)
+	}
+	entries, _ := os.ReadDir(workDir)
+	if len(entries) != 0 {
+		t.Errorf("work dir holds %v after the execution, want it emptied", entries)
+	}
+	after, err := os.Stat(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("work dir was replaced rather than emptied")
+	}
+}
@@ -0,0 +1,242 @@
+package guest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// service keeps a service's process running: it restarts the process when
+// it exits, as far as the restart policy allows, and reports changes in its
+// health to the host.
+type service struct {
+	s       *session
+	req     *fc.ServiceRequest
+	workDir string
+	env     []string
+
+	mu       sync.Mutex
+	restarts int
+	status   string // last reported health
+	reported int    // restarts at the last report
+}
+
+// serviceExit describes how one run of a service's process ended.
+type serviceExit struct {
+	code   int
+	errMsg string
+	usage  *fc.ResourceUsage
+	uptime time.Duration
+
+	// reason and signal classify how the process ended.
+	reason string
+	signal string
+
+	// started is false if the process could not be started at all.
+	started bool
+}
+
+// runService runs bin with args as a service until the host cancels it or
+// the restart policy gives up, and returns the result of the last run of
+// its process. Services take no input and their output is only logged.
+func (a *Agent) runService(s *session, req *fc.ServiceRequest, bin string, args, env []string) fc.GuestResponse {
+	sv := &service{s: s, req: req, workDir: s.workDir, env: env}
+
+	// Without a host to report to, the service is stopped along with the VM.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-s.done:
+			s.proc.cancel(defaultGrace)
+		case <-finished:
+		}
+	}()
+
+	backoff := time.Duration(req.BackoffMS) * time.Millisecond
+	maxBackoff := time.Duration(req.MaxBackoffMS) * time.Millisecond
+	delay := backoff
+	for {
+		exit := sv.run(bin, args)
+		if s.proc.isCancelled() {
+			return exit.response(cancelledMessage)
+		}
+		if !exit.started {
+			return fc.GuestResponse{ExitCode: exit.code, Error: exit.errMsg}
+		}
+
+		sv.report(fc.HealthUnhealthy, exit.errMsg)
+		if !sv.shouldRestart(exit.code) {
+			var errMsg string
+			switch {
+			case req.MaxRestarts > 0 && sv.restarts >= req.MaxRestarts:
+				errMsg = fmt.Sprintf("%s; gave up after %d restarts", exit.errMsg, sv.restarts)
+			case exit.code != 0:
+				errMsg = exit.errMsg
+			}
+			return exit.response(errMsg)
+		}
+
+		// A process that stayed up for a while resets the backoff.
+		if exit.uptime > maxBackoff {
+			delay = backoff
+		}
+		log.Printf("service %s, restarting in %s", exit.errMsg, delay)
+		select {
+		case <-time.After(delay):
+		case <-s.proc.stopped():
+			return exit.response(cancelledMessage)
+		}
+		delay = min(delay*2, maxBackoff)
+
+		sv.mu.Lock()
+		sv.restarts++
+		sv.mu.Unlock()
+	}
+}
+
+// shouldRestart reports whether the restart policy restarts a process that
+// exited with code.
+func (sv *service) shouldRestart(code int) bool {
+	if sv.req.MaxRestarts > 0 && sv.restarts >= sv.req.MaxRestarts {
+		return false
+	}
+	switch sv.req.Restart {
+	case "always":
+		return true
+	case "on-failure":
+		return code != 0
+	}
+	return false
+}
+
+// run runs the service's process once, checking its health while it runs.
+func (sv *service) run(bin string, args []string) serviceExit {
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = sv.workDir
+	cmd.Env = sv.env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cg := newWorkloadCgroup(cgroupRoot)
+	defer cg.remove()
+	cg.apply(cmd.SysProcAttr)
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return serviceExit{code: 1, errMsg: fmt.Sprintf("stdout pipe: %v", err)}
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return serviceExit{code: 1, errMsg: fmt.Sprintf("stderr pipe: %v", err)}
+	}
+
+	if sv.s.proc.isCancelled() {
+		return serviceExit{code: 1}
+	}
+	oomBefore := oomKills()
+	if err := cmd.Start(); err != nil {
+		return serviceExit{code: 1, errMsg: fmt.Sprintf("start command: %v", err)}
+	}
+	start := time.Now()
+	sv.s.proc.started(cmd.Process.Pid)
+
+	// Without a health check the service is healthy while its process runs.
+	ctx, stopChecks := context.WithCancel(context.Background())
+	checksDone := make(chan struct{})
+	if hc := sv.req.HealthCheck; hc != nil {
+		sv.report(fc.HealthStarting, "")
+		go func() {
+			defer close(checksDone)
+			runHealthChecks(ctx, hc, sv.probe, sv.report)
+		}()
+	} else {
+		sv.report(fc.HealthHealthy, "")
+		close(checksDone)
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() { streamLines(sv.s, fc.StreamStdout, stdoutPipe, io.Discard) })
+	wg.Go(func() { streamLines(sv.s, fc.StreamStderr, stderrPipe, io.Discard) })
+	wg.Wait()
+
+	waitErr := cmd.Wait()
+	sv.s.proc.exited()
+	stopChecks()
+	<-checksDone
+
+	exit := serviceExit{
+		usage:   cg.usage(cmd.ProcessState),
+		uptime:  time.Since(start),
+		started: true,
+	}
+	if waitErr != nil {
+		exit.code = 1
+		var exitErr *exec.ExitError
+		if errors.As(waitErr, &exitErr) {
+			exit.code = exitErr.ExitCode()
+		}
+	}
+	exit.errMsg = describeExit(cmd.ProcessState)
+	exit.reason, exit.signal = classifyExit(cmd.ProcessState, false, oomBefore)
+	return exit
+}
+
+// response returns the result of a service whose last run ended with exit,
+// reported with errMsg.
+func (exit serviceExit) response(errMsg string) fc.GuestResponse {
+	return fc.GuestResponse{
+		ExitCode:   exit.code,
+		Error:      errMsg,
+		Usage:      exit.usage,
+		ExitReason: exit.reason,
+		ExitSignal: exit.signal,
+	}
+}
+
+// report sends the service's health to the host, unless neither it nor the
+// restart count has changed since the last report.
+func (sv *service) report(status, detail string) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	if status == sv.status && sv.restarts == sv.reported {
+		return
+	}
+	sv.status, sv.reported = status, sv.restarts
+
+	msg := fc.GuestMessage{
+		Type:   fc.MsgTypeHealth,
+		Health: &fc.HealthReport{Status: status, Detail: detail, Restarts: sv.restarts},
+	}
+	if err := sv.s.writeMessage(&msg); err != nil {
+		log.Printf("write health: %v", err)
+	}
+}
+
+// describeExit describes how a process ended, e.g. "exited with code 1".
+func describeExit(state *os.ProcessState) string {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return "killed by " + signalName(ws.Signal())
+	}
+	return fmt.Sprintf("exited with code %d", state.ExitCode())
+}
+
+// signalName returns the name of sig, e.g. "SIGKILL".
+func signalName(sig syscall.Signal) string {
+	for _, names := range []map[string]syscall.Signal{signalsByName, fatalSignals} {
+		for name, s := range names {
+			if s == sig {
+				return name
+			}
+		}
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
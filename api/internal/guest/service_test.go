@@ -0,0 +1,168 @@
+package guest
+
+import (
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// runServiceScript runs a shell script as a service, invoking onHealth with
+// the host connection for each health message. It returns the result, the
+// log lines and the health reports.
+func runServiceScript(t *testing.T, script string, svc *fc.ServiceRequest, onHealth func(gc *fc.GuestConn, r fc.HealthReport)) (fc.GuestResponse, []string, []fc.HealthReport) {
+	t.Helper()
+	server, client := net.Pipe()
+	agent := New(nil, filepath.Join(t.TempDir(), "work"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agent.handleConnection(server)
+	}()
+
+	gc := fc.NewGuestConn(client)
+	var mu sync.Mutex
+	var lines []string
+	var reports []fc.HealthReport
+	resp, err := gc.RunWorkloadStream(fc.GuestRequest{
+		Runtime:    "shell@1.0",
+		Command:    []string{"sh", "-e"},
+		Entrypoint: "run.sh",
+		Code:       script,
+		Service:    svc,
+	}, fc.StreamIO{
+		LogWriter: func(_, line string) {
+			mu.Lock()
+			lines = append(lines, line)
+			mu.Unlock()
+		},
+		OnHealth: func(r fc.HealthReport) {
+			mu.Lock()
+			reports = append(reports, r)
+			mu.Unlock()
+			if onHealth != nil {
+				onHealth(gc, r)
+			}
+		},
+	})
+	client.Close()
+	<-done
+
+	if err != nil {
+		t.Fatalf("RunWorkloadStream: %v", err)
+	}
+	return resp, lines, reports
+}
+
+func TestServiceRestartsOnFailure(t *testing.T) {
+	svc := &fc.ServiceRequest{Restart: "on-failure", MaxRestarts: 2, BackoffMS: 10, MaxBackoffMS: 40}
+	resp, lines, reports := runServiceScript(t, "echo run\nexit 3\n", svc, nil)
+
+	if resp.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", resp.ExitCode)
+	}
+	if want := "exited with code 3; gave up after 2 restarts"; resp.Error != want {
+		t.Errorf("Error = %q, want %q", resp.Error, want)
+	}
+	if !reflect.DeepEqual(lines, []string{"run", "run", "run"}) {
+		t.Errorf("log lines = %v, want one run per start", lines)
+	}
+
+	var want []fc.HealthReport
+	for restarts := range 3 {
+		want = append(want,
+			fc.HealthReport{Status: fc.HealthHealthy, Restarts: restarts},
+			fc.HealthReport{Status: fc.HealthUnhealthy, Detail: "exited with code 3", Restarts: restarts},
+		)
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("health reports = %+v, want %+v", reports, want)
+	}
+}
+
+func TestServiceNotRestartedAfterCleanExit(t *testing.T) {
+	svc := &fc.ServiceRequest{Restart: "on-failure", BackoffMS: 10, MaxBackoffMS: 10}
+	resp, _, reports := runServiceScript(t, "echo done\n", svc, nil)
+
+	if resp.ExitCode != 0 || resp.Error != "" {
+		t.Errorf("result = %d %q, want a clean exit", resp.ExitCode, resp.Error)
+	}
+	if len(reports) != 2 || reports[1].Status != fc.HealthUnhealthy || reports[1].Restarts != 0 {
+		t.Errorf("health reports = %+v, want healthy then unhealthy without restarts", reports)
+	}
+}
+
+func TestServiceStoppedByHost(t *testing.T) {
+	svc := &fc.ServiceRequest{Restart: "always", BackoffMS: 10, MaxBackoffMS: 10}
+	start := time.Now()
+	resp, _, reports := runServiceScript(t, "echo up\nsleep 60\n", svc, func(gc *fc.GuestConn, r fc.HealthReport) {
+		if r.Status == fc.HealthHealthy {
+			if err := gc.Cancel(5 * time.Second); err != nil {
+				t.Errorf("Cancel: %v", err)
+			}
+		}
+	})
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stop took %s, want prompt exit on SIGTERM", elapsed)
+	}
+	if resp.Error != cancelledMessage {
+		t.Errorf("Error = %q, want %q", resp.Error, cancelledMessage)
+	}
+	for _, r := range reports {
+		if r.Restarts != 0 || r.Status == fc.HealthUnhealthy {
+			t.Errorf("health reports = %+v, want no restart or failure when stopped", reports)
+			break
+		}
+	}
+}
+
+func TestServiceHealthCheck(t *testing.T) {
+	svc := &fc.ServiceRequest{
+		Restart:      "always",
+		BackoffMS:    10,
+		MaxBackoffMS: 10,
+		HealthCheck: &fc.HealthCheck{
+			Command:    []string{"test", "-f", "ready"},
+			IntervalMS: 20,
+			TimeoutMS:  1000,
+			Retries:    2,
+		},
+	}
+	script := "sleep 0.3\ntouch ready\nsleep 0.5\nrm ready\nsleep 60\n"
+	var wasHealthy atomic.Bool
+	resp, _, reports := runServiceScript(t, script, svc, func(gc *fc.GuestConn, r fc.HealthReport) {
+		switch r.Status {
+		case fc.HealthHealthy:
+			wasHealthy.Store(true)
+		case fc.HealthUnhealthy:
+			// Stop once the service has failed its check after being healthy.
+			if wasHealthy.Load() {
+				gc.Cancel(time.Second)
+			}
+		}
+	})
+
+	if resp.Error != cancelledMessage {
+		t.Errorf("Error = %q, want %q", resp.Error, cancelledMessage)
+	}
+
+	var statuses []string
+	for _, r := range reports {
+		statuses = append(statuses, r.Status)
+	}
+	want := []string{fc.HealthStarting, fc.HealthUnhealthy, fc.HealthHealthy, fc.HealthUnhealthy}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("health statuses = %v, want %v", statuses, want)
+	}
+	if detail := reports[len(reports)-1].Detail; !strings.Contains(detail, "test") {
+		t.Errorf("unhealthy detail = %q, want the failing command", detail)
+	}
+}
@@ -0,0 +1,215 @@
+package guest
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// session holds the per-connection protocol state once a request has been
+// read: serialised frame writes and the chunk streams multiplexed over conn.
+type session struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	// done is closed when the host side of the connection goes away.
+	done chan struct{}
+
+	// input is non-nil when the request's stdin is streamed as chunk frames,
+	// and program when a prebuilt program follows the request.
+	input   *fc.ChunkReader
+	program *fc.ChunkReader
+
+	// proc is the workload process targeted by cancel and signal messages.
+	proc *process
+
+	// depsCommitted is closed when the host has cached a freshly installed
+	// dependency layer.
+	depsCommitted     chan struct{}
+	depsCommittedOnce sync.Once
+
+	// workDir is the request's work directory, set once it is created.
+	workDir string
+
+	// stopHeartbeats stops the heartbeat loop, if one was started.
+	stopHeartbeats func()
+
+	mu      sync.Mutex
+	writers map[string]*fc.ChunkWriter // stream → active output writer
+}
+
+// newSession creates a session for conn. When streamInput is set, the
+// workload's stdin is read from chunk frames sent by the host, and when
+// streamProgram is set, a prebuilt program.
+func newSession(conn net.Conn, streamInput, streamProgram bool) *session {
+	s := &session{
+		conn:    conn,
+		done:    make(chan struct{}),
+		proc:    newProcess(),
+		writers: make(map[string]*fc.ChunkWriter),
+
+		depsCommitted: make(chan struct{}),
+	}
+	if streamInput {
+		s.input = s.newChunkReader(fc.StreamInput)
+	}
+	if streamProgram {
+		s.program = s.newChunkReader(fc.StreamProgram)
+	}
+	return s
+}
+
+// newChunkReader returns a reader for the chunk frames the host sends on stream.
+func (s *session) newChunkReader(stream string) *fc.ChunkReader {
+	return fc.NewChunkReader(func(n int) error {
+		return s.writeMessage(&fc.GuestMessage{Type: fc.MsgTypeChunkAck, Stream: stream, Count: n})
+	}, s.done)
+}
+
+// chunkReader returns the reader for a host→guest stream, or nil if the
+// request does not stream it.
+func (s *session) chunkReader(stream string) *fc.ChunkReader {
+	switch stream {
+	case fc.StreamInput:
+		return s.input
+	case fc.StreamProgram:
+		return s.program
+	}
+	return nil
+}
+
+// readLoop reads host→guest frames until the connection closes, routing
+// input chunks to the input reader, acknowledgements to output writers and
+// control messages to the workload process.
+func (s *session) readLoop() {
+	defer close(s.done)
+
+	for {
+		var msg fc.HostMessage
+		if err := fc.ReadMessage(s.conn, &msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case fc.MsgTypeChunk:
+			r := s.chunkReader(msg.Stream)
+			if r == nil {
+				log.Printf("unexpected chunk for stream %q", msg.Stream)
+				continue
+			}
+			// A dropped chunk would leave a hole in the stream, so the
+			// stream fails and its consumer reports the error instead.
+			if err := r.Push(msg.Data); err != nil {
+				log.Printf("push %s chunk: %v", msg.Stream, err)
+				r.Fail(fmt.Errorf("%s stream: %w", msg.Stream, err))
+			}
+		case fc.MsgTypeChunkEnd:
+			if r := s.chunkReader(msg.Stream); r != nil {
+				r.End()
+			}
+		case fc.MsgTypeChunkAck:
+			s.mu.Lock()
+			w := s.writers[msg.Stream]
+			s.mu.Unlock()
+			if w != nil {
+				w.Ack(msg.Count)
+			}
+		case fc.MsgTypeDepsCommitted:
+			s.depsCommittedOnce.Do(func() { close(s.depsCommitted) })
+		case fc.MsgTypeCancel:
+			grace := time.Duration(msg.GraceMS) * time.Millisecond
+			if grace <= 0 {
+				grace = defaultGrace
+			}
+			log.Printf("cancel requested by host, grace %s", grace)
+			s.proc.cancel(grace)
+		case fc.MsgTypeSignal:
+			if err := s.proc.signal(msg.Signal); err != nil {
+				log.Printf("signal %s: %v", msg.Signal, err)
+			}
+		default:
+			log.Printf("unknown host message type: %q", msg.Type)
+		}
+	}
+}
+
+// writeMessage writes a single frame to the host, serialised with other writers.
+func (s *session) writeMessage(v any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return fc.WriteMessage(s.conn, v)
+}
+
+// sendStream copies r to the host as chunk frames on the given stream,
+// followed by an end marker.
+func (s *session) sendStream(stream string, r io.Reader) error {
+	w := fc.NewChunkWriter(func(msgType string, data []byte) error {
+		return s.writeMessage(&fc.GuestMessage{Type: msgType, Stream: stream, Data: data})
+	}, s.done)
+
+	s.mu.Lock()
+	s.writers[stream] = w
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.writers, stream)
+		s.mu.Unlock()
+	}()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("stream %s: %w", stream, err)
+	}
+	return w.Close()
+}
+
+// startHeartbeats sends the workload process state to the host every
+// interval until the result is sent or the connection closes.
+func (s *session) startHeartbeats(interval time.Duration) {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	s.stopHeartbeats = func() {
+		close(stop)
+		<-stopped
+	}
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				hb := s.proc.heartbeat()
+				if err := s.writeMessage(&fc.GuestMessage{Type: fc.MsgTypeHeartbeat, Heartbeat: &hb}); err != nil {
+					log.Printf("write heartbeat: %v", err)
+					return
+				}
+			case <-stop:
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// sendResult sends the final GuestResponse wrapped in a GuestMessage. No
+// heartbeats follow the result.
+func (s *session) sendResult(resp fc.GuestResponse) {
+	if s.stopHeartbeats != nil {
+		s.stopHeartbeats()
+		s.stopHeartbeats = nil
+	}
+	msg := fc.GuestMessage{
+		Type:     fc.MsgTypeResult,
+		Response: &resp,
+	}
+	if err := s.writeMessage(&msg); err != nil {
+		log.Printf("write result: %v", err)
+	}
+}
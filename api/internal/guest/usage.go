@@ -0,0 +1,32 @@
+package guest
+
+import (
+	"os"
+	"syscall"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// rusageBlockSize is the unit of the block I/O counters in struct rusage.
+const rusageBlockSize = 512
+
+// processUsage returns the resources consumed by an exited workload process,
+// as its rusage reports them. The kernel accumulates the usage of every descendant the process waited
+// for into its own, so this covers the process tree rather than only the
+// leader; peak RSS is the largest of any single process in the tree.
+func processUsage(ps *os.ProcessState) *fc.ResourceUsage {
+	if ps == nil {
+		return nil
+	}
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok || ru == nil {
+		return nil
+	}
+	return &fc.ResourceUsage{
+		CPUUserMS:    ps.UserTime().Milliseconds(),
+		CPUSysMS:     ps.SystemTime().Milliseconds(),
+		PeakRSSKB:    int64(ru.Maxrss), // kilobytes on Linux
+		IOReadBytes:  int64(ru.Inblock) * rusageBlockSize,
+		IOWriteBytes: int64(ru.Oublock) * rusageBlockSize,
+	}
+}
@@ -0,0 +1,83 @@
+package guest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+func TestExecuteReportsUsage(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+
+	// Burn CPU and allocate memory in a child process, so the measurement
+	// must cover the process tree rather than only the workload's leader.
+	code := "import subprocess, sys\n" +
+		"subprocess.run([sys.executable, '-c', " +
+		"'x = bytearray(64 << 20)\\n" +
+		"n = 0\\n" +
+		"for i in range(3000000): n += i'], check=True)\n"
+
+	_, resp := executeOverPipe(t, filepath.Join(t.TempDir(), "work"), fc.GuestRequest{
+		Runtime:  "python",
+		Code:     code,
+		TimeoutS: 30,
+	})
+
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, want 0; error: %s", resp.ExitCode, resp.Error)
+	}
+	if resp.Usage == nil {
+		t.Fatal("Usage is nil")
+	}
+	if resp.Usage.CPUUserMS+resp.Usage.CPUSysMS <= 0 {
+		t.Errorf("CPU time = %d+%d ms, want > 0", resp.Usage.CPUUserMS, resp.Usage.CPUSysMS)
+	}
+	if resp.Usage.PeakRSSKB < 64<<10 {
+		t.Errorf("PeakRSSKB = %d, want at least the child's 64 MiB allocation", resp.Usage.PeakRSSKB)
+	}
+}
+
+func TestProcessUsageNil(t *testing.T) {
+	if u := processUsage(nil); u != nil {
+		t.Errorf("processUsage(nil) = %+v, want nil", u)
+	}
+}
+
+func TestReadCgroupUsage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cpu.stat":    "usage_usec 5500000\nuser_usec 4000000\nsystem_usec 1500000\nnr_periods 0\n",
+		"memory.peak": "134217728\n",
+		"io.stat":     "254:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n254:16 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := &fc.ResourceUsage{CPUUserMS: 1, CPUSysMS: 1, PeakRSSKB: 1, IOReadBytes: 1, IOWriteBytes: 1, DiskUsedBytes: 7}
+	readCgroupUsage(dir, u)
+	want := fc.ResourceUsage{CPUUserMS: 4000, CPUSysMS: 1500, PeakRSSKB: 1, IOReadBytes: 5120, IOWriteBytes: 8192, PeakMemoryKB: 128 << 10, DiskUsedBytes: 7}
+	if *u != want {
+		t.Errorf("usage = %+v, want %+v", *u, want)
+	}
+
+	// Kernels before 5.19 have no memory.peak, which leaves the peak unset.
+	os.Remove(filepath.Join(dir, "memory.peak"))
+	u = &fc.ResourceUsage{PeakRSSKB: 42}
+	readCgroupUsage(dir, u)
+	if u.PeakRSSKB != 42 || u.PeakMemoryKB != 0 || u.CPUUserMS != 4000 {
+		t.Errorf("usage without memory.peak = %+v, want no peak memory", *u)
+	}
+}
+
+func TestNewWorkloadCgroupWithoutCgroup2(t *testing.T) {
+	if cg := newWorkloadCgroup(t.TempDir()); cg != nil {
+		t.Errorf("newWorkloadCgroup outside a cgroup v2 hierarchy = %+v, want nil", cg)
+	}
+}
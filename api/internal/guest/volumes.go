@@ -0,0 +1,43 @@
+package guest
+
+import (
+	"log"
+	"slices"
+	"syscall"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+// attachVolumes mounts the request's persistent volumes. Either all are
+// mounted or, on error, none remain mounted. It returns the mount points to
+// pass to detachVolumes.
+func (a *Agent) attachVolumes(volumes []fc.VolumeMount) ([]string, error) {
+	var mounted []string
+	for _, v := range volumes {
+		if err := a.mount(v.Device, v.MountPath, v.ReadOnly); err != nil {
+			a.detachVolumes(mounted)
+			return nil, err
+		}
+		mounted = append(mounted, v.MountPath)
+	}
+	return mounted, nil
+}
+
+// detachVolumes flushes and unmounts the volumes at targets, in reverse
+// order of mounting, so that everything the workload wrote has reached the
+// host before its result is reported. A volume still held open by a leftover
+// process is detached lazily once its data has been flushed.
+func (a *Agent) detachVolumes(targets []string) {
+	if len(targets) == 0 {
+		return
+	}
+	syscall.Sync()
+	for _, target := range slices.Backward(targets) {
+		if err := a.unmount(target); err != nil {
+			log.Printf("detach volume: %v", err)
+			if err := syscall.Unmount(target, syscall.MNT_DETACH); err != nil {
+				log.Printf("lazily detach volume %s: %v", target, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package guest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+)
+
+func TestExecuteMountsVolumes(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent, calls := depsTestAgent(t)
+	dir := t.TempDir()
+	data, ref := filepath.Join(dir, "data"), filepath.Join(dir, "ref")
+
+	_, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime:  "python",
+		Code:     fmt.Sprintf("open(%q, 'w').write('kept')\n", filepath.Join(data, "out.txt")),
+		TimeoutS: 10,
+		Volumes: []fc.VolumeMount{
+			{Device: "/dev/vdb", MountPath: data},
+			{Device: "/dev/vdc", MountPath: ref, ReadOnly: true},
+		},
+	})
+	if resp.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, Error = %q", resp.ExitCode, resp.Error)
+	}
+	if got, _ := os.ReadFile(filepath.Join(data, "out.txt")); string(got) != "kept" {
+		t.Errorf("volume file = %q, want kept", got)
+	}
+	want := []string{
+		"mount /dev/vdb data rw",
+		"mount /dev/vdc ref ro",
+		"unmount ref",
+		"unmount data",
+	}
+	if !slices.Equal(*calls, want) {
+		t.Errorf("calls = %q, want %q", *calls, want)
+	}
+}
+
+func TestExecuteVolumeMountFailure(t *testing.T) {
+	if _, err := findExecutable("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	agent, calls := depsTestAgent(t)
+	mount := agent.mount
+	agent.mount = func(device, target string, readOnly bool) error {
+		if device == "/dev/vdc" {
+			return errors.New("bad superblock")
+		}
+		return mount(device, target, readOnly)
+	}
+	dir := t.TempDir()
+
+	_, resp := executeWithAgent(t, agent, fc.GuestRequest{
+		Runtime:  "python",
+		Code:     "print('unreachable')",
+		TimeoutS: 10,
+		Volumes: []fc.VolumeMount{
+			{Device: "/dev/vdb", MountPath: filepath.Join(dir, "data")},
+			{Device: "/dev/vdc", MountPath: filepath.Join(dir, "ref")},
+		},
+	})
+	if resp.ExitCode == 0 || resp.Error != "mount volumes: bad superblock" {
+		t.Errorf("response = %+v, want a mount failure", resp)
+	}
+	want := []string{"mount /dev/vdb data rw", "unmount data"}
+	if !slices.Equal(*calls, want) {
+		t.Errorf("calls = %q, want %q", *calls, want)
+	}
+}
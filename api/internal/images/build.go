@@ -0,0 +1,123 @@
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// ErrBuildsUnavailable is returned by Build when no RootfsBuilder is set.
+var ErrBuildsUnavailable = errors.New("image builds are not configured")
+
+// RootfsBuilder turns the root filesystem tree in dir into a bootable image
+// file at output, adding the guest agent. sizeMB is the image size, or 0 to
+// size it to fit the tree.
+type RootfsBuilder func(ctx context.Context, dir, output string, sizeMB int) error
+
+// BuildRequest describes a catalog image to build from a container image.
+type BuildRequest struct {
+	// Image holds the name, version, command and entrypoint to register. An
+	// empty command defaults to the container image's entrypoint followed by
+	// its cmd.
+	Image model.Image
+
+	// Source is an OCI image layout directory, or an OCI layout or docker-save
+	// tar archive, in Config.ImportDir on the host. See UnpackImage.
+	Source string
+
+	// Tag selects the image when Source holds several.
+	Tag string
+
+	// SizeMB is the size of the built image; 0 fits it to the contents.
+	SizeMB int
+}
+
+// SetBuilder enables Build, using b to produce image files.
+func (c *Catalog) SetBuilder(b RootfsBuilder) {
+	c.build = b
+}
+
+// Build unpacks a container image, builds a rootfs image from it and
+// registers the result like Register. The source must be in Config.ImportDir
+// on the host; nothing is fetched over the network. Returns ErrInvalidImage for
+// unusable sources and ErrImportDisabled without an ImportDir.
+func (c *Catalog) Build(ctx context.Context, req BuildRequest) (*model.Image, error) {
+	if c.build == nil {
+		return nil, ErrBuildsUnavailable
+	}
+	img := req.Image
+	if req.SizeMB < 0 {
+		return nil, fmt.Errorf("%w: size_mb must not be negative", ErrInvalidImage)
+	}
+	// Check all but a defaulted command before the slow unpack.
+	early := img
+	if len(early.Command) == 0 {
+		early.Command = []string{"default"}
+	}
+	if err := early.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	source, err := c.importPath(req.Source)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkNew(ctx, img); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "vulcan-build-")
+	if err != nil {
+		return nil, fmt.Errorf("create build dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg, err := UnpackImage(ctx, source, req.Tag, dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(img.Command) == 0 {
+		img.Command = append(append([]string{}, cfg.Config.Entrypoint...), cfg.Config.Cmd...)
+	}
+	if err := img.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	// Build into the catalog directory so the file can be renamed into place.
+	tmp, err := os.CreateTemp(c.cfg.Dir, uploadPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("create image file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if err := c.build(ctx, dir, tmp.Name(), req.SizeMB); err != nil {
+		return nil, fmt.Errorf("build image: %w", err)
+	}
+	digest, size, err := fileDigest(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("hash image file: %w", err)
+	}
+	c.logger.Info("image built", "image", img.Ref(), "source", req.Source, "size_bytes", size)
+	return c.add(ctx, img, tmp.Name(), digest, size)
+}
+
+// fileDigest returns the hex SHA-256 digest and size of the file at path.
+func fileDigest(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
@@ -0,0 +1,114 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// listingBuilder is a RootfsBuilder that writes the sorted file paths of the
+// tree as the image.
+func listingBuilder(_ context.Context, dir, output string, _ int) error {
+	var paths []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			paths = append(paths, rel)
+		}
+		return err
+	})
+	slices.Sort(paths)
+	return os.WriteFile(output, []byte(strings.Join(paths, "\n")), 0o644)
+}
+
+func TestBuild(t *testing.T) {
+	layout := writeOCILayout(t, layoutImage{
+		config: testImageConfig([]string{"python3"}, []string{"-u"}),
+		layers: [][]byte{layerTar(t, true, tarEntry{name: "usr/bin/python3", body: "py"})},
+	})
+	c, s := newTestCatalog(t, Config{ImportDir: filepath.Dir(layout)}, nil)
+	c.SetBuilder(listingBuilder)
+
+	req := BuildRequest{Image: model.Image{Name: "python", Version: "3.12", Entrypoint: "main.py"}, Source: layout}
+	img, err := c.Build(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if !slices.Equal(img.Command, []string{"python3", "-u"}) {
+		t.Errorf("Command = %v, want the image's entrypoint and cmd", img.Command)
+	}
+	if data, err := os.ReadFile(img.Path); err != nil || string(data) != "usr/bin/python3" {
+		t.Errorf("image file = %q, %v, want the unpacked tree", data, err)
+	}
+	if _, err := s.GetImage(context.Background(), "python", "3.12"); err != nil {
+		t.Errorf("GetImage: %v", err)
+	}
+
+	if _, err := c.Build(context.Background(), req); !errors.Is(err, store.ErrImageExists) {
+		t.Errorf("duplicate Build = %v, want ErrImageExists", err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(c.cfg.Dir, uploadPrefix+"*")); len(leftovers) != 0 {
+		t.Errorf("partial files left behind: %v", leftovers)
+	}
+}
+
+func TestBuildKeepsCommand(t *testing.T) {
+	layout := writeOCILayout(t, layoutImage{config: testImageConfig(nil, []string{"bash"}), layers: [][]byte{layerTar(t, false, tarEntry{name: "a", body: "a"})}})
+	c, _ := newTestCatalog(t, Config{ImportDir: filepath.Dir(layout)}, nil)
+	c.SetBuilder(listingBuilder)
+
+	img, err := c.Build(context.Background(), BuildRequest{Image: testImage("ml", "1.0"), Source: layout})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if !slices.Equal(img.Command, []string{"python3"}) {
+		t.Errorf("Command = %v, want the requested command", img.Command)
+	}
+}
+
+func TestBuildRejectsRequests(t *testing.T) {
+	layout := writeOCILayout(t, layoutImage{config: testImageConfig(nil, nil), layers: [][]byte{layerTar(t, false, tarEntry{name: "a", body: "a"})}})
+	buildErr := errors.New("mkfs failed")
+
+	// Test temporary directories share a parent.
+	importDir := filepath.Dir(layout)
+	outside := filepath.Join(t.TempDir(), "escape")
+	if err := os.Symlink("/", outside); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		req       BuildRequest
+		builder   RootfsBuilder
+		importDir string
+		want      error
+	}{
+		{"not configured", BuildRequest{Image: testImage("ml", "1.0"), Source: layout}, nil, importDir, ErrBuildsUnavailable},
+		{"invalid name", BuildRequest{Image: testImage("ML", "1.0"), Source: layout}, listingBuilder, importDir, ErrInvalidImage},
+		{"negative size", BuildRequest{Image: testImage("ml", "1.0"), Source: layout, SizeMB: -1}, listingBuilder, importDir, ErrInvalidImage},
+		{"no command", BuildRequest{Image: model.Image{Name: "ml", Version: "1.0", Entrypoint: "main.py"}, Source: layout}, listingBuilder, importDir, ErrInvalidImage},
+		{"bad source", BuildRequest{Image: testImage("ml", "1.0"), Source: t.TempDir()}, listingBuilder, importDir, ErrInvalidImage},
+		{"source outside import dir", BuildRequest{Image: testImage("ml", "1.0"), Source: outside + "/etc"}, listingBuilder, importDir, ErrInvalidImage},
+		{"imports disabled", BuildRequest{Image: testImage("ml", "1.0"), Source: layout}, listingBuilder, "", ErrImportDisabled},
+		{"builder fails", BuildRequest{Image: testImage("ml", "1.0"), Source: layout}, func(context.Context, string, string, int) error { return buildErr }, importDir, buildErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCatalog(t, Config{ImportDir: tt.importDir}, nil)
+			if tt.builder != nil {
+				c.SetBuilder(tt.builder)
+			}
+			if _, err := c.Build(context.Background(), tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("Build = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
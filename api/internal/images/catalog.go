@@ -0,0 +1,368 @@
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// ErrInvalidImage is returned when an image is rejected at registration.
+var ErrInvalidImage = errors.New("invalid image")
+
+// ErrImportDisabled is returned for images read from a path on the host when
+// no Config.ImportDir is set.
+var ErrImportDisabled = errors.New("reading images from host paths is disabled")
+
+// Inspector checks that the image file at path contains a usable guest agent
+// and returns the agent's version.
+type Inspector func(ctx context.Context, path string) (agentVersion string, err error)
+
+// imageExt is the extension of stored image files.
+const imageExt = ".ext4"
+
+// uploadPrefix names partial uploads in the catalog directory.
+const uploadPrefix = ".upload-"
+
+// staleUploadAge is how long an upload must have been idle before garbage
+// collection treats it as abandoned.
+const staleUploadAge = time.Hour
+
+// Catalog stores image files by digest and their metadata in the store.
+type Catalog struct {
+	cfg     Config
+	store   store.Store
+	inspect Inspector
+	build   RootfsBuilder // nil until SetBuilder
+	logger  *slog.Logger
+
+	mu sync.Mutex // serializes changes to the image directory
+}
+
+// NewCatalog creates the catalog, creating its image directory if needed.
+func NewCatalog(cfg Config, s store.Store, inspect Inspector, logger *slog.Logger) (*Catalog, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create image dir: %w", err)
+	}
+	dir, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve image dir: %w", err)
+	}
+	cfg.Dir = dir
+	if cfg.ImportDir != "" {
+		// Resolved like the paths checked against it.
+		if cfg.ImportDir, err = filepath.Abs(cfg.ImportDir); err != nil {
+			return nil, fmt.Errorf("resolve import dir: %w", err)
+		}
+		if cfg.ImportDir, err = filepath.EvalSymlinks(cfg.ImportDir); err != nil {
+			return nil, fmt.Errorf("resolve import dir: %w", err)
+		}
+	}
+	if cfg.KeepVersions < 1 {
+		cfg.KeepVersions = 1
+	}
+	return &Catalog{cfg: cfg, store: s, inspect: inspect, logger: logger}, nil
+}
+
+// Register adds the image file read from r to the catalog under img's name
+// and version, with img's command and entrypoint. The file is rejected with
+// ErrInvalidImage unless it contains a guest agent at least
+// Config.MinAgentVersion. Returns store.ErrImageExists if the version is
+// already registered.
+func (c *Catalog) Register(ctx context.Context, img model.Image, r io.Reader) (*model.Image, error) {
+	if err := img.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if err := c.checkNew(ctx, img); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(c.cfg.Dir, uploadPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("create image file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("write image file: %w", err)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%w: image file is empty", ErrInvalidImage)
+	}
+	return c.add(ctx, img, tmp.Name(), hex.EncodeToString(h.Sum(nil)), size)
+}
+
+// checkNew returns store.ErrImageExists if img's version is registered.
+func (c *Catalog) checkNew(ctx context.Context, img model.Image) error {
+	_, err := c.store.GetImage(ctx, img.Name, img.Version)
+	if err == nil {
+		return store.ErrImageExists
+	}
+	if !errors.Is(err, store.ErrImageNotFound) {
+		return err
+	}
+	return nil
+}
+
+// add inspects the image file at tmpPath, an upload in the catalog directory
+// with the given hex digest and size, and moves it into the catalog as img.
+func (c *Catalog) add(ctx context.Context, img model.Image, tmpPath, digest string, size int64) (*model.Image, error) {
+	agentVersion, err := c.inspect(ctx, tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if err := checkAgentVersion(agentVersion, c.cfg.MinAgentVersion); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	img.Digest = "sha256:" + digest
+	img.SizeBytes = size
+	img.AgentVersion = agentVersion
+	img.Path = filepath.Join(c.cfg.Dir, digest+imageExt)
+	img.CreatedAt = time.Now().UTC()
+	img.LastUsedAt = nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Versions with identical contents share one file.
+	if err := os.Rename(tmpPath, img.Path); err != nil {
+		return nil, fmt.Errorf("store image file: %w", err)
+	}
+	if err := os.Chmod(img.Path, 0o444); err != nil {
+		return nil, fmt.Errorf("store image file: %w", err)
+	}
+	if err := c.store.CreateImage(ctx, &img); err != nil {
+		c.removeUnreferenced(ctx, img.Path)
+		return nil, err
+	}
+
+	c.logger.Info("image registered", "image", img.Ref(), "digest", img.Digest, "agent_version", img.AgentVersion)
+	return &img, nil
+}
+
+// RegisterFile registers the image file at path on the host, which must be
+// in Config.ImportDir. The file is copied into the catalog.
+func (c *Catalog) RegisterFile(ctx context.Context, img model.Image, path string) (*model.Image, error) {
+	path, err := c.importPath(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	defer f.Close()
+	return c.Register(ctx, img, f)
+}
+
+// importPath resolves path, absolute or relative to Config.ImportDir, to a
+// file in ImportDir. Symlinks are followed, so none can lead out of it.
+// Returns ErrImportDisabled without an ImportDir.
+func (c *Catalog) importPath(path string) (string, error) {
+	if c.cfg.ImportDir == "" {
+		return "", ErrImportDisabled
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.cfg.ImportDir, path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if rel, err := filepath.Rel(c.cfg.ImportDir, resolved); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %s is outside the import directory", ErrInvalidImage, path)
+	}
+	return resolved, nil
+}
+
+// Delete removes an image version and, unless another version shares it,
+// its file. Returns store.ErrImageNotFound if it is not registered.
+func (c *Catalog) Delete(ctx context.Context, name, version string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	img, err := c.store.GetImage(ctx, name, version)
+	if err != nil {
+		return err
+	}
+	if err := c.store.DeleteImage(ctx, name, version); err != nil {
+		return err
+	}
+	c.removeUnreferenced(ctx, img.Path)
+	c.logger.Info("image deleted", "image", img.Ref())
+	return nil
+}
+
+// removeUnreferenced removes the image file at path unless an image still
+// refers to it. Failures are logged; the next garbage collection retries.
+func (c *Catalog) removeUnreferenced(ctx context.Context, path string) {
+	images, err := c.store.ListImages(ctx, "")
+	if err != nil {
+		c.logger.Warn("failed to check image file references", "path", path, "error", err)
+		return
+	}
+	for _, img := range images {
+		if img.Path == path {
+			return
+		}
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Warn("failed to remove image file", "path", path, "error", err)
+	}
+}
+
+// GCReport lists what a garbage collection removed, or would remove in a
+// dry run.
+type GCReport struct {
+	DryRun bool           `json:"dry_run"`
+	Images []*model.Image `json:"images"`
+
+	// Files are image files in the catalog directory that no image refers to,
+	// such as uploads interrupted by a restart.
+	Files []string `json:"files"`
+}
+
+// GC removes versions that are not among the Config.KeepVersions newest of
+// their image and have been neither registered nor used by a workload for
+// Config.UnusedFor, along with files no image refers to. In a dry run it only
+// reports them.
+func (c *Catalog) GC(ctx context.Context, dryRun bool) (*GCReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	images, err := c.store.ListImages(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{DryRun: dryRun, Images: []*model.Image{}, Files: []string{}}
+	cutoff := time.Now().Add(-c.cfg.UnusedFor)
+	kept := make(map[string]bool)      // files of versions that stay
+	collected := make(map[string]bool) // files of versions to collect
+	newer := 0                         // versions of the current image seen so far, newest first
+	for i, img := range images {
+		if i == 0 || images[i-1].Name != img.Name {
+			newer = 0
+		}
+		newer++
+		if newer <= c.cfg.KeepVersions || img.CreatedAt.After(cutoff) ||
+			(img.LastUsedAt != nil && img.LastUsedAt.After(cutoff)) {
+			kept[img.Path] = true
+			continue
+		}
+		report.Images = append(report.Images, img)
+		collected[img.Path] = true
+	}
+
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("list image dir: %w", err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(c.cfg.Dir, e.Name())
+		if e.IsDir() || kept[path] || collected[path] {
+			continue
+		}
+		if strings.HasPrefix(e.Name(), uploadPrefix) {
+			// Uploads run outside the lock; leave recent ones to finish.
+			if info, err := e.Info(); err != nil || info.ModTime().After(time.Now().Add(-staleUploadAge)) {
+				continue
+			}
+		} else if !strings.HasSuffix(e.Name(), imageExt) {
+			continue
+		}
+		report.Files = append(report.Files, path)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	for _, img := range report.Images {
+		if err := c.store.DeleteImage(ctx, img.Name, img.Version); err != nil && !errors.Is(err, store.ErrImageNotFound) {
+			return nil, err
+		}
+		c.logger.Info("image garbage collected", "image", img.Ref())
+	}
+	for path := range collected {
+		if !kept[path] {
+			report.Files = append(report.Files, path)
+		}
+	}
+	for _, path := range report.Files {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("failed to remove image file", "path", path, "error", err)
+		}
+	}
+	return report, nil
+}
+
+// checkAgentVersion returns an error if version is older than min. Any
+// version is accepted when min is empty.
+func checkAgentVersion(version, min string) error {
+	if min == "" {
+		return nil
+	}
+	want, ok := parseVersion(min)
+	if !ok {
+		return fmt.Errorf("invalid minimum agent version %q", min)
+	}
+	got, ok := parseVersion(version)
+	if !ok {
+		return fmt.Errorf("guest agent version %q cannot be compared with the minimum %s", version, min)
+	}
+	for i := range max(len(got), len(want)) {
+		var g, w int
+		if i < len(got) {
+			g = got[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if g != w {
+			if g < w {
+				return fmt.Errorf("guest agent %s is older than the minimum %s", version, min)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// parseVersion parses a version of dot-separated numbers with an optional
+// "v" prefix. Pre-release and build suffixes are ignored.
+func parseVersion(s string) ([]int, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, p := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
@@ -0,0 +1,291 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+func newTestCatalog(t *testing.T, cfg Config, inspect Inspector) (*Catalog, *store.SQLiteStore) {
+	t.Helper()
+	s, err := store.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if cfg.Dir == "" {
+		cfg.Dir = t.TempDir()
+	}
+	if inspect == nil {
+		inspect = func(context.Context, string) (string, error) { return "v1.5.0", nil }
+	}
+	c, err := NewCatalog(cfg, s, inspect, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	return c, s
+}
+
+func testImage(name, version string) model.Image {
+	return model.Image{Name: name, Version: version, Command: []string{"python3"}, Entrypoint: "main.py"}
+}
+
+func TestRegister(t *testing.T) {
+	c, s := newTestCatalog(t, Config{}, nil)
+	ctx := context.Background()
+
+	img, err := c.Register(ctx, testImage("python", "3.12"), strings.NewReader("rootfs"))
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if !strings.HasPrefix(img.Digest, "sha256:") || len(img.Digest) != len("sha256:")+64 {
+		t.Errorf("Digest = %q, want a sha256 digest", img.Digest)
+	}
+	if img.SizeBytes != 6 || img.AgentVersion != "v1.5.0" {
+		t.Errorf("image = %+v, want 6 bytes with agent v1.5.0", img)
+	}
+	if want := filepath.Join(c.cfg.Dir, strings.TrimPrefix(img.Digest, "sha256:")+imageExt); img.Path != want {
+		t.Errorf("Path = %q, want %q", img.Path, want)
+	}
+	if data, err := os.ReadFile(img.Path); err != nil || string(data) != "rootfs" {
+		t.Errorf("image file = %q, %v, want the uploaded contents", data, err)
+	}
+
+	stored, err := s.GetImage(ctx, "python", "3.12")
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	if stored.Digest != img.Digest || stored.Path != img.Path {
+		t.Errorf("stored image = %+v, want %+v", stored, img)
+	}
+
+	if _, err := c.Register(ctx, testImage("python", "3.12"), strings.NewReader("other")); !errors.Is(err, store.ErrImageExists) {
+		t.Errorf("duplicate Register = %v, want ErrImageExists", err)
+	}
+}
+
+func TestRegisterRejectsImages(t *testing.T) {
+	inspect := func(_ context.Context, path string) (string, error) {
+		data, _ := os.ReadFile(path)
+		if string(data) == "no agent" {
+			return "", errors.New("guest agent not found in image")
+		}
+		return string(data), nil
+	}
+	c, _ := newTestCatalog(t, Config{MinAgentVersion: "v1.4"}, inspect)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		img      model.Image
+		contents string
+	}{
+		{"invalid metadata", model.Image{Name: "python", Version: "3.12"}, "v1.5.0"},
+		{"empty file", testImage("python", "3.12"), ""},
+		{"no agent", testImage("python", "3.12"), "no agent"},
+		{"old agent", testImage("python", "3.12"), "v1.3.9"},
+		{"unversioned agent", testImage("python", "3.12"), "dev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Register(ctx, tt.img, strings.NewReader(tt.contents)); !errors.Is(err, ErrInvalidImage) {
+				t.Errorf("Register = %v, want ErrInvalidImage", err)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("rejected images left %d files behind", len(entries))
+	}
+}
+
+func TestRegisterFile(t *testing.T) {
+	importDir := t.TempDir()
+	c, _ := newTestCatalog(t, Config{ImportDir: importDir}, nil)
+	ctx := context.Background()
+
+	if err := os.WriteFile(filepath.Join(importDir, "python.ext4"), []byte("rootfs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("rootfs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(importDir, "escape.ext4")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.RegisterFile(ctx, testImage("python", "3.12"), filepath.Join(importDir, "python.ext4")); err != nil {
+		t.Errorf("RegisterFile by absolute path: %v", err)
+	}
+	if _, err := c.RegisterFile(ctx, testImage("python", "3.13"), "python.ext4"); err != nil {
+		t.Errorf("RegisterFile by relative path: %v", err)
+	}
+	for _, path := range []string{outside, "../secret", "escape.ext4", "missing.ext4"} {
+		if _, err := c.RegisterFile(ctx, testImage("python", "4.0"), path); !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("RegisterFile(%q) = %v, want ErrInvalidImage", path, err)
+		}
+	}
+
+	disabled, _ := newTestCatalog(t, Config{}, nil)
+	if _, err := disabled.RegisterFile(ctx, testImage("python", "3.12"), outside); !errors.Is(err, ErrImportDisabled) {
+		t.Errorf("RegisterFile without an import dir = %v, want ErrImportDisabled", err)
+	}
+}
+
+func TestDeleteSharedFile(t *testing.T) {
+	c, s := newTestCatalog(t, Config{}, nil)
+	ctx := context.Background()
+
+	a, err := c.Register(ctx, testImage("python", "3.12"), strings.NewReader("rootfs"))
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	b, err := c.Register(ctx, testImage("python", "latest"), strings.NewReader("rootfs"))
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if a.Path != b.Path {
+		t.Fatalf("identical images stored at %s and %s, want one file", a.Path, b.Path)
+	}
+
+	if err := c.Delete(ctx, "python", "3.12"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(b.Path); err != nil {
+		t.Errorf("file shared with python@latest removed: %v", err)
+	}
+	if err := c.Delete(ctx, "python", "latest"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(b.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unreferenced file still present: %v", err)
+	}
+	if err := c.Delete(ctx, "python", "latest"); !errors.Is(err, store.ErrImageNotFound) {
+		t.Errorf("second Delete = %v, want ErrImageNotFound", err)
+	}
+	if images, _ := s.ListImages(ctx, ""); len(images) != 0 {
+		t.Errorf("images = %d, want none", len(images))
+	}
+}
+
+// addImage stores an image version created at created, with its own file.
+func addImage(t *testing.T, c *Catalog, s store.Store, name, version string, created time.Time, lastUsed *time.Time) *model.Image {
+	t.Helper()
+	img := testImage(name, version)
+	img.Digest = "sha256:" + name + version
+	img.Path = filepath.Join(c.cfg.Dir, name+version+imageExt)
+	img.CreatedAt = created
+	if err := os.WriteFile(img.Path, []byte(img.Ref()), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateImage(context.Background(), &img); err != nil {
+		t.Fatalf("CreateImage: %v", err)
+	}
+	if lastUsed != nil {
+		if err := s.MarkImageUsed(context.Background(), name, version, *lastUsed); err != nil {
+			t.Fatalf("MarkImageUsed: %v", err)
+		}
+	}
+	return &img
+}
+
+func TestGC(t *testing.T) {
+	c, s := newTestCatalog(t, Config{KeepVersions: 2, UnusedFor: 24 * time.Hour}, nil)
+	ctx := context.Background()
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+
+	// python@3.9 and 3.13 are the newest two, 3.10 was used recently and
+	// ml@1.0 is the only version of its image; 3.12 and 3.11 are collected.
+	addImage(t, c, s, "python", "3.13", old.Add(4*time.Hour), nil)
+	addImage(t, c, s, "python", "3.12", old.Add(3*time.Hour), nil)
+	stale := addImage(t, c, s, "python", "3.11", old.Add(2*time.Hour), nil)
+	addImage(t, c, s, "python", "3.10", old.Add(time.Hour), &recent)
+	addImage(t, c, s, "python", "3.9", recent, nil)
+	addImage(t, c, s, "ml", "1.0", old, nil)
+
+	orphan := filepath.Join(c.cfg.Dir, "0123abcd"+imageExt)
+	os.WriteFile(orphan, nil, 0o444)
+	staleUpload := filepath.Join(c.cfg.Dir, uploadPrefix+"1")
+	os.WriteFile(staleUpload, nil, 0o644)
+	os.Chtimes(staleUpload, old, old)
+	activeUpload := filepath.Join(c.cfg.Dir, uploadPrefix+"2")
+	os.WriteFile(activeUpload, nil, 0o644)
+
+	report, err := c.GC(ctx, true)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	var refs []string
+	for _, img := range report.Images {
+		refs = append(refs, img.Ref())
+	}
+	if want := []string{"python@3.12", "python@3.11"}; !slices.Equal(refs, want) {
+		t.Errorf("collected images = %v, want %v", refs, want)
+	}
+	slices.Sort(report.Files)
+	if want := []string{staleUpload, orphan}; !slices.Equal(report.Files, want) {
+		t.Errorf("collected files = %v, want %v", report.Files, want)
+	}
+	if _, err := os.Stat(stale.Path); err != nil {
+		t.Errorf("dry run removed %s: %v", stale.Path, err)
+	}
+
+	if _, err := c.GC(ctx, false); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if _, err := s.GetImage(ctx, "python", "3.11"); !errors.Is(err, store.ErrImageNotFound) {
+		t.Errorf("python@3.11 still registered: %v", err)
+	}
+	for _, path := range []string{stale.Path, orphan, staleUpload} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s still present: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(activeUpload); err != nil {
+		t.Errorf("upload in progress removed: %v", err)
+	}
+	if images, _ := s.ListImages(ctx, ""); len(images) != 4 {
+		t.Errorf("images left = %d, want 4", len(images))
+	}
+}
+
+func TestCheckAgentVersion(t *testing.T) {
+	tests := []struct {
+		version, min string
+		ok           bool
+	}{
+		{"v1.5.0", "", true},
+		{"dev", "", true},
+		{"v1.5.0", "v1.4", true},
+		{"1.4.0", "v1.4", true},
+		{"v1.4.0-rc1", "1.4", true},
+		{"v1.10.0", "v1.9", true},
+		{"v1.3.9", "v1.4", false},
+		{"v1", "v1.0.1", false},
+		{"dev", "v1.4", false},
+		{"v1.5.0", "latest", false},
+	}
+	for _, tt := range tests {
+		err := checkAgentVersion(tt.version, tt.min)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkAgentVersion(%q, %q) = %v, want ok=%v", tt.version, tt.min, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package images
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variable names for image catalog configuration.
+const (
+	envDir             = "VULCAN_IMAGE_DIR"
+	envKeepVersions    = "VULCAN_IMAGE_KEEP_VERSIONS"
+	envUnusedFor       = "VULCAN_IMAGE_GC_UNUSED_FOR"
+	envMinAgentVersion = "VULCAN_IMAGE_MIN_AGENT_VERSION"
+	envAgentBin        = "VULCAN_IMAGE_AGENT_BIN"
+	envImportDir       = "VULCAN_IMAGE_IMPORT_DIR"
+)
+
+// Catalog defaults.
+const (
+	DefaultDir          = "images"
+	DefaultKeepVersions = 3
+	DefaultUnusedFor    = 7 * 24 * time.Hour
+)
+
+// Config holds configuration for the image catalog.
+type Config struct {
+	// Dir is where image files are stored, named by digest.
+	Dir string
+
+	// KeepVersions is the number of newest versions of each image that
+	// garbage collection never removes. At least one is always kept.
+	KeepVersions int
+
+	// UnusedFor is how long an older version must have been neither
+	// registered nor used by a workload before garbage collection removes it.
+	UnusedFor time.Duration
+
+	// MinAgentVersion, if set, is the oldest guest agent version accepted in
+	// registered images, as dot-separated numbers with an optional "v".
+	MinAgentVersion string
+
+	// AgentBin is the guest agent binary installed into images built from
+	// container images. Builds are disabled when empty.
+	AgentBin string
+
+	// ImportDir is the only directory on the host that images can be read
+	// from by path. Registering files by path is disabled when empty;
+	// uploads are always accepted.
+	ImportDir string
+}
+
+// LoadConfig reads image catalog configuration from environment variables,
+// applying defaults for values not set.
+func LoadConfig() Config {
+	cfg := Config{
+		Dir:          DefaultDir,
+		KeepVersions: DefaultKeepVersions,
+		UnusedFor:    DefaultUnusedFor,
+	}
+
+	if v := os.Getenv(envDir); v != "" {
+		cfg.Dir = v
+	}
+	if v := os.Getenv(envKeepVersions); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.KeepVersions = n
+		}
+	}
+	if v := os.Getenv(envUnusedFor); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.UnusedFor = d
+		}
+	}
+	cfg.MinAgentVersion = os.Getenv(envMinAgentVersion)
+	cfg.AgentBin = os.Getenv(envAgentBin)
+	cfg.ImportDir = os.Getenv(envImportDir)
+
+	return cfg
+}
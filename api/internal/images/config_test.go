@@ -0,0 +1,53 @@
+package images
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.Dir != DefaultDir || cfg.KeepVersions != DefaultKeepVersions ||
+		cfg.UnusedFor != DefaultUnusedFor || cfg.MinAgentVersion != "" || cfg.AgentBin != "" || cfg.ImportDir != "" {
+		t.Errorf("LoadConfig() = %+v, want defaults", cfg)
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	t.Setenv(envDir, "/var/lib/vulcan/images")
+	t.Setenv(envKeepVersions, "5")
+	t.Setenv(envUnusedFor, "48h")
+	t.Setenv(envMinAgentVersion, "v1.4")
+	t.Setenv(envAgentBin, "/opt/vulcan/vulcan-guest")
+	t.Setenv(envImportDir, "/srv/images")
+
+	cfg := LoadConfig()
+	if cfg.Dir != "/var/lib/vulcan/images" {
+		t.Errorf("Dir = %q", cfg.Dir)
+	}
+	if cfg.KeepVersions != 5 {
+		t.Errorf("KeepVersions = %d, want 5", cfg.KeepVersions)
+	}
+	if cfg.UnusedFor != 48*time.Hour {
+		t.Errorf("UnusedFor = %v, want 48h", cfg.UnusedFor)
+	}
+	if cfg.MinAgentVersion != "v1.4" {
+		t.Errorf("MinAgentVersion = %q, want v1.4", cfg.MinAgentVersion)
+	}
+	if cfg.AgentBin != "/opt/vulcan/vulcan-guest" {
+		t.Errorf("AgentBin = %q", cfg.AgentBin)
+	}
+	if cfg.ImportDir != "/srv/images" {
+		t.Errorf("ImportDir = %q", cfg.ImportDir)
+	}
+}
+
+func TestLoadConfigInvalidValues(t *testing.T) {
+	t.Setenv(envKeepVersions, "0")
+	t.Setenv(envUnusedFor, "weekly")
+
+	cfg := LoadConfig()
+	if cfg.KeepVersions != DefaultKeepVersions || cfg.UnusedFor != DefaultUnusedFor {
+		t.Errorf("LoadConfig() = %+v, want defaults for invalid values", cfg)
+	}
+}
@@ -0,0 +1,3 @@
+// Package images manages the runtime image catalog: rootfs images registered
+// under a name and version that workloads select as their runtime.
+package images
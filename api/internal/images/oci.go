@@ -0,0 +1,586 @@
+package images
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Media types of the manifests and indexes an image layout can contain.
+const (
+	mediaTypeOCIIndex        = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerList      = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest     = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifest  = "application/vnd.docker.distribution.manifest.v2+json"
+	annotationRefName        = "org.opencontainers.image.ref.name"
+	annotationContainerdName = "io.containerd.image.name"
+)
+
+// Whiteout markers in image layers. A ".wh.<name>" entry deletes <name> from
+// lower layers; an opaque marker in a directory deletes everything the lower
+// layers put there.
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// ImageConfig is the part of an OCI image configuration used to build a
+// rootfs.
+type ImageConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Config       struct {
+		Entrypoint []string `json:"Entrypoint"`
+		Cmd        []string `json:"Cmd"`
+	} `json:"config"`
+}
+
+// descriptor references a blob in an OCI image layout.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform"`
+}
+
+// ociIndex is an OCI image index or Docker manifest list.
+type ociIndex struct {
+	Manifests []descriptor `json:"manifests"`
+}
+
+// ociManifest is an OCI or Docker image manifest.
+type ociManifest struct {
+	Config descriptor   `json:"config"`
+	Layers []descriptor `json:"layers"`
+}
+
+// dockerSaveEntry is an image in the manifest.json of a docker-save archive.
+type dockerSaveEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// layoutBlob is a file in an unpacked image layout. Digest is empty when the
+// layout does not record it, as for layers of older docker-save archives.
+type layoutBlob struct {
+	path   string
+	digest string
+}
+
+// UnpackImage extracts the image in src into the empty directory dst,
+// applying its layers in order, and returns the image's configuration. src is
+// an OCI image layout directory, or a tar archive (optionally gzipped) of an
+// OCI image layout or of `docker save` output. When the source holds more
+// than one image, tag selects one by its reference name or repository tag.
+// Nothing is fetched over the network.
+func UnpackImage(ctx context.Context, src, tag, dst string) (*ImageConfig, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	layout := src
+	if !info.IsDir() {
+		tmp, err := os.MkdirTemp("", "vulcan-layout-")
+		if err != nil {
+			return nil, fmt.Errorf("create temp dir: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		if err := extractArchive(ctx, src, tmp); err != nil {
+			return nil, fmt.Errorf("%w: read %s: %v", ErrInvalidImage, filepath.Base(src), err)
+		}
+		layout = tmp
+	}
+
+	config, layers, err := resolveImage(layout, tag)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	cfg, err := readImageConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	root, err := os.OpenRoot(dst)
+	if err != nil {
+		return nil, fmt.Errorf("open rootfs dir: %w", err)
+	}
+	defer root.Close()
+	for i, layer := range layers {
+		if err := applyLayerFile(ctx, root, layer); err != nil {
+			return nil, fmt.Errorf("%w: layer %d: %v", ErrInvalidImage, i+1, err)
+		}
+	}
+	return cfg, nil
+}
+
+// resolveImage finds the configuration and layers of the image selected by
+// tag in an unpacked image layout.
+func resolveImage(layout, tag string) (layoutBlob, []layoutBlob, error) {
+	if _, err := os.Stat(filepath.Join(layout, "index.json")); err == nil {
+		return resolveOCIImage(layout, tag)
+	}
+	if _, err := os.Stat(filepath.Join(layout, "manifest.json")); err == nil {
+		return resolveDockerSaveImage(layout, tag)
+	}
+	return layoutBlob{}, nil, errors.New("not an OCI image layout or docker-save archive: no index.json or manifest.json")
+}
+
+// resolveOCIImage resolves an image through the index.json of an OCI image
+// layout, descending into a multi-platform index for the host's platform.
+func resolveOCIImage(layout, tag string) (layoutBlob, []layoutBlob, error) {
+	var index ociIndex
+	if err := readJSON(filepath.Join(layout, "index.json"), &index); err != nil {
+		return layoutBlob{}, nil, err
+	}
+
+	var desc descriptor
+	switch {
+	case tag != "":
+		i := slices.IndexFunc(index.Manifests, func(d descriptor) bool {
+			return d.Annotations[annotationRefName] == tag || d.Annotations[annotationContainerdName] == tag
+		})
+		if i < 0 {
+			return layoutBlob{}, nil, fmt.Errorf("no image tagged %q in layout", tag)
+		}
+		desc = index.Manifests[i]
+	case len(index.Manifests) == 1:
+		desc = index.Manifests[0]
+	default:
+		return layoutBlob{}, nil, fmt.Errorf("layout holds %d images; select one with a tag", len(index.Manifests))
+	}
+
+	if desc.MediaType == mediaTypeOCIIndex || desc.MediaType == mediaTypeDockerList {
+		if !digestPattern.MatchString(desc.Digest) {
+			return layoutBlob{}, nil, fmt.Errorf("unsupported index digest %q", desc.Digest)
+		}
+		var nested ociIndex
+		if err := readJSON(blobPath(layout, desc.Digest), &nested); err != nil {
+			return layoutBlob{}, nil, err
+		}
+		i := slices.IndexFunc(nested.Manifests, func(d descriptor) bool {
+			return d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == runtime.GOARCH
+		})
+		if i < 0 {
+			return layoutBlob{}, nil, fmt.Errorf("image has no linux/%s manifest", runtime.GOARCH)
+		}
+		desc = nested.Manifests[i]
+	}
+	if desc.MediaType != "" && desc.MediaType != mediaTypeOCIManifest && desc.MediaType != mediaTypeDockerManifest {
+		return layoutBlob{}, nil, fmt.Errorf("unsupported manifest media type %q", desc.MediaType)
+	}
+	if !digestPattern.MatchString(desc.Digest) {
+		return layoutBlob{}, nil, fmt.Errorf("unsupported manifest digest %q", desc.Digest)
+	}
+
+	var manifest ociManifest
+	if err := readJSON(blobPath(layout, desc.Digest), &manifest); err != nil {
+		return layoutBlob{}, nil, err
+	}
+	for _, d := range append([]descriptor{manifest.Config}, manifest.Layers...) {
+		if !digestPattern.MatchString(d.Digest) {
+			return layoutBlob{}, nil, fmt.Errorf("unsupported blob digest %q", d.Digest)
+		}
+	}
+	layers := make([]layoutBlob, len(manifest.Layers))
+	for i, d := range manifest.Layers {
+		layers[i] = layoutBlob{path: blobPath(layout, d.Digest), digest: d.Digest}
+	}
+	config := layoutBlob{path: blobPath(layout, manifest.Config.Digest), digest: manifest.Config.Digest}
+	return config, layers, nil
+}
+
+// resolveDockerSaveImage resolves an image through the manifest.json written
+// by `docker save`.
+func resolveDockerSaveImage(layout, tag string) (layoutBlob, []layoutBlob, error) {
+	var entries []dockerSaveEntry
+	if err := readJSON(filepath.Join(layout, "manifest.json"), &entries); err != nil {
+		return layoutBlob{}, nil, err
+	}
+
+	var entry dockerSaveEntry
+	switch {
+	case tag != "":
+		i := slices.IndexFunc(entries, func(e dockerSaveEntry) bool { return slices.Contains(e.RepoTags, tag) })
+		if i < 0 {
+			return layoutBlob{}, nil, fmt.Errorf("no image tagged %q in archive", tag)
+		}
+		entry = entries[i]
+	case len(entries) == 1:
+		entry = entries[0]
+	default:
+		return layoutBlob{}, nil, fmt.Errorf("archive holds %d images; select one with a tag", len(entries))
+	}
+
+	config, err := dockerSaveBlob(layout, entry.Config)
+	if err != nil {
+		return layoutBlob{}, nil, err
+	}
+	layers := make([]layoutBlob, len(entry.Layers))
+	for i, name := range entry.Layers {
+		if layers[i], err = dockerSaveBlob(layout, name); err != nil {
+			return layoutBlob{}, nil, err
+		}
+	}
+	return config, layers, nil
+}
+
+// dockerSaveBlob returns the file name refers to in a docker-save archive.
+// Archives from Docker 25 and later store blobs by digest, which is then
+// verified.
+func dockerSaveBlob(layout, name string) (layoutBlob, error) {
+	if !filepath.IsLocal(name) {
+		return layoutBlob{}, fmt.Errorf("invalid path %q in manifest.json", name)
+	}
+	blob := layoutBlob{path: filepath.Join(layout, name)}
+	if sum, ok := strings.CutPrefix(filepath.ToSlash(name), "blobs/sha256/"); ok {
+		blob.digest = "sha256:" + sum
+	}
+	return blob, nil
+}
+
+// blobPath returns where a blob is stored in an OCI image layout. digest must
+// match digestPattern.
+func blobPath(layout, digest string) string {
+	alg, hex, _ := strings.Cut(digest, ":")
+	return filepath.Join(layout, "blobs", alg, hex)
+}
+
+// readImageConfig reads and checks an image configuration blob. Images built
+// for another platform are rejected, since microVMs run the host's.
+func readImageConfig(blob layoutBlob) (*ImageConfig, error) {
+	data, err := os.ReadFile(blob.path)
+	if err != nil {
+		return nil, fmt.Errorf("read image config: %w", err)
+	}
+	if blob.digest != "" {
+		if sum := sha256.Sum256(data); "sha256:"+hex.EncodeToString(sum[:]) != blob.digest {
+			return nil, fmt.Errorf("image config does not match digest %s", blob.digest)
+		}
+	}
+	var cfg ImageConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("decode image config: %w", err)
+	}
+	if cfg.OS != "" && cfg.OS != "linux" {
+		return nil, fmt.Errorf("image is for %s, not linux", cfg.OS)
+	}
+	if cfg.Architecture != "" && cfg.Architecture != runtime.GOARCH {
+		return nil, fmt.Errorf("image is for %s, not %s", cfg.Architecture, runtime.GOARCH)
+	}
+	return &cfg, nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// extractArchive extracts the tar archive at src, which may be gzipped, into
+// dst. Only directories, files and symlinks are extracted; image layouts
+// contain nothing else.
+func extractArchive(ctx context.Context, src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := decompress(f)
+	if err != nil {
+		return err
+	}
+	root, err := os.OpenRoot(dst)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := entryPath(hdr.Name)
+		if !ok {
+			return fmt.Errorf("entry %q escapes the archive", hdr.Name)
+		}
+		if name == "." {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeSymlink:
+			if err := writeEntry(root, name, hdr, tr); err != nil {
+				return fmt.Errorf("extract %s: %w", name, err)
+			}
+		}
+	}
+}
+
+// applyLayerFile applies the layer blob to the rootfs tree in root,
+// verifying its digest when known.
+func applyLayerFile(ctx context.Context, root *os.Root, blob layoutBlob) error {
+	f, err := os.Open(blob.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	var h hash.Hash
+	if blob.digest != "" {
+		h = sha256.New()
+		r = io.TeeReader(f, h)
+	}
+	if err := applyLayer(ctx, root, r); err != nil {
+		return err
+	}
+	if h != nil {
+		// Hash any padding the tar reader left unread.
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return err
+		}
+		if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != blob.digest {
+			return fmt.Errorf("layer does not match digest %s", blob.digest)
+		}
+	}
+	return nil
+}
+
+// applyLayer applies a layer tar stream, which may be gzipped, to the rootfs
+// tree in root: whiteouts delete files from lower layers and other entries
+// replace them. Device nodes and FIFOs are skipped, since the guest mounts
+// devtmpfs at boot.
+func applyLayer(ctx context.Context, root *os.Root, r io.Reader) error {
+	lr, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	if c, ok := lr.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	// Paths this layer created, which an opaque whiteout must keep.
+	added := make(map[string]bool)
+	tr := tar.NewReader(lr)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := entryPath(hdr.Name)
+		if !ok {
+			return fmt.Errorf("entry %q escapes the image root", hdr.Name)
+		}
+		dir, base := path.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+		if dir == "" {
+			dir = "."
+		}
+
+		switch {
+		case base == opaqueWhiteout:
+			if err := clearDir(root, dir, added); err != nil {
+				return fmt.Errorf("whiteout %s: %w", dir, err)
+			}
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			target := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+			if err := root.RemoveAll(target); err != nil {
+				return fmt.Errorf("whiteout %s: %w", target, err)
+			}
+			continue
+		case name == ".":
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+			if err := writeEntry(root, name, hdr, tr); err != nil {
+				return fmt.Errorf("extract %s: %w", name, err)
+			}
+			for p := name; p != "."; p = path.Dir(p) {
+				added[p] = true
+			}
+		}
+	}
+}
+
+// decompress returns r, decompressed if it is gzipped.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		return nil, errors.New("zstd-compressed layers are not supported")
+	}
+	return br, nil
+}
+
+// entryPath cleans a tar entry name into a path relative to the archive
+// root, reporting false if it would escape it.
+func entryPath(name string) (string, bool) {
+	p := path.Clean(strings.TrimPrefix(name, "/"))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+	return p, true
+}
+
+// symlinkTarget returns the target to give the symlink at name, which the
+// layer points at linkname. Absolute targets, such as Debian's var/run ->
+// /run, and relative ones climbing above the image root are rewritten
+// relative to the link's directory: they then resolve to the same file in
+// the guest, and in root, which refuses to follow links out of the tree,
+// later entries can still be written through them.
+func symlinkTarget(name, linkname string) string {
+	dir := path.Dir(name)
+	if !path.IsAbs(linkname) {
+		if _, ok := entryPath(path.Join(dir, linkname)); ok {
+			return linkname
+		}
+		// As in the guest, ".." at the root stays there.
+		linkname = path.Join("/", dir, linkname)
+	}
+	rel, err := filepath.Rel(path.Join("/", dir), path.Clean(linkname))
+	if err != nil {
+		return linkname
+	}
+	return rel
+}
+
+// clearDir removes the entries of dir in root that were not created by the
+// current layer.
+func clearDir(root *os.Root, dir string, added map[string]bool) error {
+	d, err := root.Open(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries, err := d.ReadDir(-1)
+	d.Close()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := path.Join(dir, e.Name())
+		if added[p] {
+			continue
+		}
+		if err := root.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEntry creates the directory, file, symlink or hard link described by
+// hdr at name in root, replacing what is there, and applies its mode,
+// modification time and, when running as root, ownership.
+func writeEntry(root *os.Root, name string, hdr *tar.Header, r io.Reader) error {
+	if parent := path.Dir(name); parent != "." {
+		if err := root.MkdirAll(parent, 0o755); err != nil {
+			return err
+		}
+	}
+
+	existing, err := root.Lstat(name)
+	if err == nil && (hdr.Typeflag != tar.TypeDir || !existing.IsDir()) {
+		if err := root.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := root.Mkdir(name, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+			return err
+		}
+	case tar.TypeReg:
+		f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, r)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := root.Symlink(symlinkTarget(name, hdr.Linkname), name); err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		target, ok := entryPath(hdr.Linkname)
+		if !ok {
+			return fmt.Errorf("hard link target %q escapes the image root", hdr.Linkname)
+		}
+		// A hard link shares its target's metadata.
+		return root.Link(target, name)
+	}
+
+	if os.Geteuid() == 0 {
+		// Changing ownership clears setuid bits, so it comes first.
+		if err := root.Lchown(name, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+	mode := hdr.FileInfo().Mode()
+	if err := root.Chmod(name, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return err
+	}
+	return root.Chtimes(name, hdr.ModTime, hdr.ModTime)
+}
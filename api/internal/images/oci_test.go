@@ -0,0 +1,471 @@
+package images
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tarEntry is an entry of a test layer.
+type tarEntry struct {
+	name     string
+	body     string // file contents
+	typeflag byte   // defaults to a regular file
+	linkname string
+	mode     int64
+}
+
+// layerTar returns a tar stream of entries, gzipped if gz is set.
+func layerTar(t *testing.T, gz bool, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     e.mode,
+			Size:     int64(len(e.body)),
+			ModTime:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+			if hdr.Typeflag == tar.TypeDir {
+				hdr.Mode = 0o755
+			}
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// testImageConfig returns an image configuration for the host's platform.
+func testImageConfig(entrypoint, cmd []string) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"architecture": runtime.GOARCH,
+		"os":           "linux",
+		"config":       map[string]any{"Entrypoint": entrypoint, "Cmd": cmd},
+	})
+	return data
+}
+
+// writeBlob stores data in the OCI layout at dir and returns its digest.
+func writeBlob(t *testing.T, dir string, data []byte) string {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	path := filepath.Join(dir, "blobs", "sha256", digest)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + digest
+}
+
+// layoutImage is an image written to a test OCI layout.
+type layoutImage struct {
+	tag    string
+	config []byte
+	layers [][]byte
+}
+
+// writeManifest stores the manifest of img in the layout at dir and returns
+// its descriptor.
+func writeManifest(t *testing.T, dir string, img layoutImage) map[string]any {
+	t.Helper()
+	var layers []map[string]any
+	for _, l := range img.layers {
+		layers = append(layers, map[string]any{
+			"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+			"digest":    writeBlob(t, dir, l),
+			"size":      len(l),
+		})
+	}
+	manifest, _ := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeOCIManifest,
+		"config":        map[string]any{"mediaType": "application/vnd.oci.image.config.v1+json", "digest": writeBlob(t, dir, img.config)},
+		"layers":        layers,
+	})
+	desc := map[string]any{"mediaType": mediaTypeOCIManifest, "digest": writeBlob(t, dir, manifest), "size": len(manifest)}
+	if img.tag != "" {
+		desc["annotations"] = map[string]string{annotationRefName: img.tag}
+	}
+	return desc
+}
+
+// writeOCILayout writes an OCI image layout holding imgs to a new directory.
+func writeOCILayout(t *testing.T, imgs ...layoutImage) string {
+	t.Helper()
+	dir := t.TempDir()
+	var manifests []map[string]any
+	for _, img := range imgs {
+		manifests = append(manifests, writeManifest(t, dir, img))
+	}
+	writeJSON(t, filepath.Join(dir, "index.json"), map[string]any{"schemaVersion": 2, "manifests": manifests})
+	writeJSON(t, filepath.Join(dir, "oci-layout"), map[string]string{"imageLayoutVersion": "1.0.0"})
+	return dir
+}
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tarDir archives the directory dir into a new file, gzipped if gz is set.
+func tarDir(t *testing.T, dir string, gz bool) string {
+	t.Helper()
+	var entries []tarEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if d.IsDir() {
+			entries = append(entries, tarEntry{name: rel + "/", typeflag: tar.TypeDir})
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		entries = append(entries, tarEntry{name: rel, body: string(data)})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(out, layerTar(t, gz, entries...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// writeDockerSave writes a docker-save archive in the format used before
+// Docker 25, whose layers are not stored by digest.
+func writeDockerSave(t *testing.T, tag string, config []byte, layers ...[]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	sum := sha256.Sum256(config)
+	configName := hex.EncodeToString(sum[:]) + ".json"
+	if err := os.WriteFile(filepath.Join(dir, configName), config, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var layerNames []string
+	for i, l := range layers {
+		name := filepath.Join(fmt.Sprintf("layer%d", i), "layer.tar")
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), l, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		layerNames = append(layerNames, name)
+	}
+	writeJSON(t, filepath.Join(dir, "manifest.json"), []map[string]any{
+		{"Config": configName, "RepoTags": []string{tag}, "Layers": layerNames},
+	})
+	return tarDir(t, dir, false)
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			tree[rel] = "-> " + target
+			return err
+		case d.IsDir():
+			tree[rel] = "/"
+		default:
+			data, err := os.ReadFile(path)
+			tree[rel] = string(data)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestUnpackImageLayers(t *testing.T) {
+	base := layerTar(t, true,
+		tarEntry{name: "etc/", typeflag: tar.TypeDir},
+		tarEntry{name: "etc/os-release", body: "alpine"},
+		tarEntry{name: "etc/motd", body: "welcome"},
+		tarEntry{name: "usr/lib/python/", typeflag: tar.TypeDir},
+		tarEntry{name: "usr/lib/python/old.py", body: "old"},
+		tarEntry{name: "usr/lib/python/keep.py", body: "keep"},
+		tarEntry{name: "bin/sh", body: "shell", mode: 0o755},
+		tarEntry{name: "bin/ash", typeflag: tar.TypeLink, linkname: "bin/sh"},
+		tarEntry{name: "usr/bin/sh", typeflag: tar.TypeSymlink, linkname: "/bin/sh"},
+		tarEntry{name: "var/cache/apk/index", body: "index"},
+	)
+	top := layerTar(t, false,
+		tarEntry{name: "etc/.wh.motd"},
+		tarEntry{name: "usr/lib/python/new.py", body: "new"},
+		tarEntry{name: "usr/lib/python/.wh..wh..opq"},
+		tarEntry{name: "var/cache/apk", body: "now a file"},
+		tarEntry{name: "etc/os-release", body: "alpine 3.21"},
+	)
+	layout := writeOCILayout(t, layoutImage{config: testImageConfig([]string{"python3"}, []string{"-u"}), layers: [][]byte{base, top}})
+
+	dst := t.TempDir()
+	cfg, err := UnpackImage(context.Background(), layout, "", dst)
+	if err != nil {
+		t.Fatalf("UnpackImage: %v", err)
+	}
+	if strings.Join(cfg.Config.Entrypoint, " ") != "python3" || strings.Join(cfg.Config.Cmd, " ") != "-u" {
+		t.Errorf("config = %+v, want entrypoint python3 and cmd -u", cfg.Config)
+	}
+
+	want := map[string]string{
+		"etc":                   "/",
+		"etc/os-release":        "alpine 3.21",
+		"usr":                   "/",
+		"usr/lib":               "/",
+		"usr/lib/python":        "/",
+		"usr/lib/python/new.py": "new",
+		"usr/bin":               "/",
+		"usr/bin/sh":            "-> ../../bin/sh",
+		"bin":                   "/",
+		"bin/sh":                "shell",
+		"bin/ash":               "shell",
+		"var":                   "/",
+		"var/cache":             "/",
+		"var/cache/apk":         "now a file",
+	}
+	got := readTree(t, dst)
+	for path, contents := range want {
+		if got[path] != contents {
+			t.Errorf("%s = %q, want %q", path, got[path], contents)
+		}
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok {
+			t.Errorf("unexpected %s in rootfs", path)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "bin", "sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Errorf("bin/sh mode = %v, want 0755", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("bin/sh mtime = %v, want the layer's", info.ModTime())
+	}
+}
+
+func TestUnpackImageSources(t *testing.T) {
+	config := testImageConfig(nil, []string{"node"})
+	layer := layerTar(t, true, tarEntry{name: "app/index.js", body: "js"})
+	layout := writeOCILayout(t, layoutImage{config: config, layers: [][]byte{layer}})
+
+	tests := []struct {
+		name string
+		src  string
+		tag  string
+	}{
+		{"OCI layout directory", layout, ""},
+		{"OCI layout tarball", tarDir(t, layout, false), ""},
+		{"gzipped OCI layout tarball", tarDir(t, layout, true), ""},
+		{"docker save", writeDockerSave(t, "node:22", config, layerTar(t, false, tarEntry{name: "app/index.js", body: "js"})), "node:22"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+			cfg, err := UnpackImage(context.Background(), tt.src, tt.tag, dst)
+			if err != nil {
+				t.Fatalf("UnpackImage: %v", err)
+			}
+			if len(cfg.Config.Cmd) != 1 || cfg.Config.Cmd[0] != "node" {
+				t.Errorf("Cmd = %v, want [node]", cfg.Config.Cmd)
+			}
+			if data, err := os.ReadFile(filepath.Join(dst, "app", "index.js")); err != nil || string(data) != "js" {
+				t.Errorf("app/index.js = %q, %v, want js", data, err)
+			}
+		})
+	}
+}
+
+func TestUnpackImageSelectsTag(t *testing.T) {
+	layout := writeOCILayout(t,
+		layoutImage{tag: "3.11", config: testImageConfig(nil, nil), layers: [][]byte{layerTar(t, false, tarEntry{name: "version", body: "3.11"})}},
+		layoutImage{tag: "3.12", config: testImageConfig(nil, nil), layers: [][]byte{layerTar(t, false, tarEntry{name: "version", body: "3.12"})}},
+	)
+
+	dst := t.TempDir()
+	if _, err := UnpackImage(context.Background(), layout, "3.12", dst); err != nil {
+		t.Fatalf("UnpackImage: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "version")); string(data) != "3.12" {
+		t.Errorf("version = %q, want 3.12", data)
+	}
+
+	for _, tag := range []string{"", "3.13"} {
+		if _, err := UnpackImage(context.Background(), layout, tag, t.TempDir()); !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("UnpackImage with tag %q = %v, want ErrInvalidImage", tag, err)
+		}
+	}
+}
+
+func TestUnpackImagePlatformIndex(t *testing.T) {
+	dir := t.TempDir()
+	other := writeManifest(t, dir, layoutImage{config: testImageConfig(nil, nil), layers: [][]byte{layerTar(t, false, tarEntry{name: "arch", body: "other"})}})
+	other["platform"] = map[string]string{"os": "linux", "architecture": "s390x"}
+	host := writeManifest(t, dir, layoutImage{config: testImageConfig(nil, nil), layers: [][]byte{layerTar(t, false, tarEntry{name: "arch", body: "host"})}})
+	host["platform"] = map[string]string{"os": "linux", "architecture": runtime.GOARCH}
+	index, _ := json.Marshal(map[string]any{"schemaVersion": 2, "manifests": []any{other, host}})
+	writeJSON(t, filepath.Join(dir, "index.json"), map[string]any{
+		"schemaVersion": 2,
+		"manifests":     []any{map[string]any{"mediaType": mediaTypeOCIIndex, "digest": writeBlob(t, dir, index)}},
+	})
+
+	dst := t.TempDir()
+	if _, err := UnpackImage(context.Background(), dir, "", dst); err != nil {
+		t.Fatalf("UnpackImage: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "arch")); string(data) != "host" {
+		t.Errorf("arch = %q, want the host platform's layer", data)
+	}
+}
+
+func TestUnpackImageRejectsImages(t *testing.T) {
+	config := testImageConfig(nil, nil)
+	otherArch, _ := json.Marshal(map[string]any{"architecture": "s390x", "os": "linux"})
+
+	corrupt := writeOCILayout(t, layoutImage{config: config, layers: [][]byte{layerTar(t, false, tarEntry{name: "a", body: "a"})}})
+	blobs, _ := filepath.Glob(filepath.Join(corrupt, "blobs", "sha256", "*"))
+	for _, b := range blobs {
+		if data, _ := os.ReadFile(b); bytes.HasPrefix(data, []byte("a\x00")) {
+			os.WriteFile(b, layerTar(t, false, tarEntry{name: "a", body: "b"}), 0o644)
+		}
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"not an image", t.TempDir(), "no index.json or manifest.json"},
+		{"missing source", filepath.Join(t.TempDir(), "missing"), "no such file"},
+		{"other architecture", writeOCILayout(t, layoutImage{config: otherArch}), "s390x"},
+		{"escaping entry", writeOCILayout(t, layoutImage{config: config, layers: [][]byte{layerTar(t, false, tarEntry{name: "../../etc/passwd", body: "x"})}}), "escapes"},
+		{"escaping hard link", writeOCILayout(t, layoutImage{config: config, layers: [][]byte{layerTar(t, false, tarEntry{name: "passwd", typeflag: tar.TypeLink, linkname: "../../etc/passwd"})}}), "escapes"},
+		{"corrupt layer", corrupt, "does not match digest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UnpackImage(context.Background(), tt.src, "", t.TempDir())
+			if !errors.Is(err, ErrInvalidImage) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("UnpackImage = %v, want ErrInvalidImage containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnpackImageAbsoluteSymlinks(t *testing.T) {
+	// Debian images link var/run to /run; later layers write through it.
+	base := layerTar(t, true,
+		tarEntry{name: "run/", typeflag: tar.TypeDir},
+		tarEntry{name: "var/", typeflag: tar.TypeDir},
+		tarEntry{name: "var/run", typeflag: tar.TypeSymlink, linkname: "/run"},
+		tarEntry{name: "var/lock", typeflag: tar.TypeSymlink, linkname: "../../../run/lock"},
+		tarEntry{name: "usr/lib/os-release", body: "debian"},
+		tarEntry{name: "etc/os-release", typeflag: tar.TypeSymlink, linkname: "../usr/lib/os-release"},
+	)
+	top := layerTar(t, false,
+		tarEntry{name: "var/run/app.pid", body: "1"},
+	)
+	layout := writeOCILayout(t, layoutImage{config: testImageConfig(nil, []string{"app"}), layers: [][]byte{base, top}})
+
+	dst := t.TempDir()
+	if _, err := UnpackImage(context.Background(), layout, "", dst); err != nil {
+		t.Fatalf("UnpackImage: %v", err)
+	}
+	got := readTree(t, dst)
+	want := map[string]string{
+		"var/run":        "-> ../run",
+		"run/app.pid":    "1",
+		"var/lock":       "-> ../run/lock",
+		"etc/os-release": "-> ../usr/lib/os-release",
+	}
+	for path, contents := range want {
+		if got[path] != contents {
+			t.Errorf("%s = %q, want %q", path, got[path], contents)
+		}
+	}
+}
+
+func TestUnpackImageSymlinkCannotEscape(t *testing.T) {
+	outside := t.TempDir()
+	layer := layerTar(t, false,
+		tarEntry{name: "etc", typeflag: tar.TypeSymlink, linkname: outside},
+		tarEntry{name: "etc/passwd", body: "owned"},
+	)
+	layout := writeOCILayout(t, layoutImage{config: testImageConfig(nil, nil), layers: [][]byte{layer}})
+
+	if _, err := UnpackImage(context.Background(), layout, "", t.TempDir()); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("UnpackImage = %v, want ErrInvalidImage", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "passwd")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("layer wrote outside the rootfs: %v", err)
+	}
+}
@@ -6,3 +6,9 @@ import "github.com/oklog/ulid/v2"
 func NewID() string {
 	return ulid.Make().String()
 }
+
+// IsID reports whether s has the form of an ID generated by NewID.
+func IsID(s string) bool {
+	_, err := ulid.ParseStrict(s)
+	return err == nil
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Image is a rootfs image registered in the runtime image catalog. Workloads
+// select one with a runtime of "<name>@<version>", or "<name>" for its newest
+// version.
+type Image struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+
+	// Digest is the "sha256:<hex>" digest of the image file.
+	Digest    string `json:"digest"`
+	SizeBytes int64  `json:"size_bytes"`
+
+	// Command is the program and leading arguments that run workload code;
+	// the entrypoint's path is appended, e.g. ["python3"] or ["go", "run"].
+	Command []string `json:"command"`
+
+	// Entrypoint is the file run when a workload names none.
+	Entrypoint string `json:"entrypoint"`
+
+	// AgentVersion is the version of the guest agent found in the image.
+	AgentVersion string `json:"agent_version"`
+
+	// Path is where the image file is stored on the host.
+	Path string `json:"path"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Ref returns the image's runtime reference, "<name>@<version>".
+func (i *Image) Ref() string {
+	return i.Name + ImageRefSeparator + i.Version
+}
+
+// ImageRefSeparator separates an image name from its version in a runtime
+// reference such as "python@3.12".
+const ImageRefSeparator = "@"
+
+var (
+	imageNamePattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+	imageVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]{0,63}$`)
+)
+
+// builtinRuntimes are the runtime names that refer to a catalog image only
+// when a version is given.
+var builtinRuntimes = []string{RuntimeGo, RuntimeNode, RuntimePython, RuntimeWasm, RuntimeOCI}
+
+// ParseImageRef splits a workload runtime that refers to a catalog image into
+// the image's name and version. An empty version selects the newest one. ok
+// is false for built-in runtimes and for malformed references.
+func ParseImageRef(runtime string) (name, version string, ok bool) {
+	name, version, versioned := strings.Cut(runtime, ImageRefSeparator)
+	if !versioned && slices.Contains(builtinRuntimes, name) {
+		return "", "", false
+	}
+	if !imageNamePattern.MatchString(name) || (versioned && !imageVersionPattern.MatchString(version)) {
+		return "", "", false
+	}
+	return name, version, true
+}
+
+// Validate checks the image's name, version, command and entrypoint.
+func (i *Image) Validate() error {
+	if !imageNamePattern.MatchString(i.Name) {
+		return fmt.Errorf("invalid image name %q: use up to 64 lowercase letters, digits, '.', '_' or '-'", i.Name)
+	}
+	if !imageVersionPattern.MatchString(i.Version) {
+		return fmt.Errorf("invalid image version %q: use up to 64 letters, digits, '.', '_', '+' or '-'", i.Version)
+	}
+	if len(i.Command) == 0 || i.Command[0] == "" {
+		return errors.New("image command is required")
+	}
+	if i.Entrypoint == "" {
+		return errors.New("image entrypoint is required")
+	}
+	if path.IsAbs(i.Entrypoint) || path.Clean(i.Entrypoint) != i.Entrypoint || strings.HasPrefix(i.Entrypoint, "..") {
+		return fmt.Errorf("invalid image entrypoint %q: must be a clean relative path", i.Entrypoint)
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package model
 
 import (
+	"net/netip"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +17,17 @@ func TestNewIDFormat(t *testing.T) {
 	}
 }
 
+func TestIsID(t *testing.T) {
+	if id := NewID(); !IsID(id) {
+		t.Errorf("IsID(%q) = false, want true", id)
+	}
+	for _, s := range []string{"", "test-workload", "01ARZ3NDEKTSV4RRFFQ69G5FA", "01ARZ3NDEKTSV4RRFFQ69G5FAVX"} {
+		if IsID(s) {
+			t.Errorf("IsID(%q) = true, want false", s)
+		}
+	}
+}
+
 func TestNewIDUniqueness(t *testing.T) {
 	seen := make(map[string]bool)
 	for i := 0; i < 1000; i++ {
@@ -114,3 +127,308 @@ func TestRuntimeConstants(t *testing.T) {
 		}
 	}
 }
+
+func TestNetworkPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  NetworkPolicy
+		wantErr bool
+	}{
+		{"none", NetworkPolicy{Mode: NetworkNone}, false},
+		{"full", NetworkPolicy{Mode: NetworkFull}, false},
+		{"egress empty allowlist", NetworkPolicy{Mode: NetworkEgress}, false},
+		{"egress rules", NetworkPolicy{Mode: NetworkEgress, Allow: []EgressRule{
+			{CIDR: "203.0.113.0/24", Protocol: ProtocolTCP, Ports: []int{443}},
+			{CIDR: "2001:db8::/32"},
+		}}, false},
+		{"unknown mode", NetworkPolicy{Mode: "open"}, true},
+		{"empty mode", NetworkPolicy{}, true},
+		{"allow without egress", NetworkPolicy{Mode: NetworkFull, Allow: []EgressRule{{CIDR: "10.0.0.0/8"}}}, true},
+		{"bare address", NetworkPolicy{Mode: NetworkEgress, Allow: []EgressRule{{CIDR: "203.0.113.7"}}}, true},
+		{"bad protocol", NetworkPolicy{Mode: NetworkEgress, Allow: []EgressRule{{CIDR: "10.0.0.0/8", Protocol: "icmp"}}}, true},
+		{"port zero", NetworkPolicy{Mode: NetworkEgress, Allow: []EgressRule{{CIDR: "10.0.0.0/8", Ports: []int{0}}}}, true},
+		{"port too large", NetworkPolicy{Mode: NetworkEgress, Allow: []EgressRule{{CIDR: "10.0.0.0/8", Ports: []int{70000}}}}, true},
+		{"too many rules", NetworkPolicy{Mode: NetworkEgress, Allow: make([]EgressRule, MaxEgressRules+1)}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyAllows(t *testing.T) {
+	resolver := netip.MustParseAddr("1.1.1.1")
+	egress := func(rules ...EgressRule) *NetworkPolicy {
+		return &NetworkPolicy{Mode: NetworkEgress, Allow: rules}
+	}
+	tests := []struct {
+		name   string
+		policy *NetworkPolicy
+		want   bool
+	}{
+		{"nil", nil, true},
+		{"full", &NetworkPolicy{Mode: NetworkFull}, true},
+		{"none", &NetworkPolicy{Mode: NetworkNone}, false},
+		{"egress empty allowlist", egress(), false},
+		{"egress matching rule", egress(EgressRule{CIDR: "1.1.1.0/24", Protocol: ProtocolUDP, Ports: []int{53}}), true},
+		{"egress any protocol and port", egress(EgressRule{CIDR: "1.1.1.1/32"}), true},
+		{"egress other address", egress(EgressRule{CIDR: "8.8.8.8/32"}), false},
+		{"egress other protocol", egress(EgressRule{CIDR: "1.1.1.1/32", Protocol: ProtocolTCP}), false},
+		{"egress other port", egress(EgressRule{CIDR: "1.1.1.1/32", Ports: []int{443}}), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.Allows(resolver, ProtocolUDP, 53); got != tc.want {
+				t.Errorf("Allows(udp 1.1.1.1:53) = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyEffectiveMode(t *testing.T) {
+	var nilPolicy *NetworkPolicy
+	if got := nilPolicy.EffectiveMode(); got != NetworkFull {
+		t.Errorf("nil EffectiveMode() = %q, want %q", got, NetworkFull)
+	}
+	p := &NetworkPolicy{Mode: NetworkNone}
+	if got := p.EffectiveMode(); got != NetworkNone {
+		t.Errorf("EffectiveMode() = %q, want %q", got, NetworkNone)
+	}
+}
+
+func TestValidateGroup(t *testing.T) {
+	valid := [][2]string{
+		{"", ""},
+		{"shop", ""},
+		{"shop", "db"},
+		{"team-1", "api-2"},
+		{strings.Repeat("a", 63), "x"},
+	}
+	for _, v := range valid {
+		if err := ValidateGroup(v[0], v[1]); err != nil {
+			t.Errorf("ValidateGroup(%q, %q) = %v, want nil", v[0], v[1], err)
+		}
+	}
+
+	invalid := [][2]string{
+		{"", "db"},
+		{"Shop", ""},
+		{"-shop", ""},
+		{"shop-", ""},
+		{"shop.prod", ""},
+		{strings.Repeat("a", 64), ""},
+		{"shop", "db_1"},
+		{"shop", "DB"},
+	}
+	for _, v := range invalid {
+		if err := ValidateGroup(v[0], v[1]); err == nil {
+			t.Errorf("ValidateGroup(%q, %q) = nil, want error", v[0], v[1])
+		}
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	var unset *RateLimits
+	if !unset.IsZero() || unset.HasNetwork() {
+		t.Error("nil RateLimits should be zero without network limits")
+	}
+
+	disk := &RateLimits{DiskBytesPerS: 10 << 20, DiskIOPS: 500}
+	if err := disk.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	if disk.IsZero() || disk.HasNetwork() {
+		t.Error("disk-only limits should be non-zero without network limits")
+	}
+
+	net := &RateLimits{NetPacketsPerS: 1000}
+	if !net.HasNetwork() {
+		t.Error("HasNetwork = false with a packet limit")
+	}
+
+	if err := (&RateLimits{NetBytesPerS: -1}).Validate(); err == nil {
+		t.Error("Validate accepted a negative limit")
+	}
+}
+
+func TestValidateDiskMB(t *testing.T) {
+	for mb, valid := range map[int]bool{0: true, MinDiskMB: true, 4096: true, MinDiskMB - 1: false, -1: false} {
+		if err := ValidateDiskMB(mb); (err == nil) != valid {
+			t.Errorf("ValidateDiskMB(%d) = %v, want valid %v", mb, err, valid)
+		}
+	}
+}
+
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		runtime       string
+		name, version string
+		ok            bool
+	}{
+		{"python@3.12", "python", "3.12", true},
+		{"ml-tools@2024.06+cuda", "ml-tools", "2024.06+cuda", true},
+		{"ml-tools", "ml-tools", "", true},
+		{"python", "", "", false},
+		{"go", "", "", false},
+		{"Python@3.12", "", "", false},
+		{"python@", "", "", false},
+		{"python@../3", "", "", false},
+		{"@3.12", "", "", false},
+	}
+	for _, tt := range tests {
+		name, version, ok := ParseImageRef(tt.runtime)
+		if name != tt.name || version != tt.version || ok != tt.ok {
+			t.Errorf("ParseImageRef(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.runtime, name, version, ok, tt.name, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestImageValidate(t *testing.T) {
+	valid := Image{Name: "python", Version: "3.12", Command: []string{"python3"}, Entrypoint: "main.py"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	if got := valid.Ref(); got != "python@3.12" {
+		t.Errorf("Ref = %q, want python@3.12", got)
+	}
+
+	tests := map[string]func(*Image){
+		"name":               func(i *Image) { i.Name = "Py" },
+		"version":            func(i *Image) { i.Version = "" },
+		"command":            func(i *Image) { i.Command = nil },
+		"empty program":      func(i *Image) { i.Command = []string{""} },
+		"entrypoint":         func(i *Image) { i.Entrypoint = "" },
+		"absolute entry":     func(i *Image) { i.Entrypoint = "/etc/passwd" },
+		"escaping entry":     func(i *Image) { i.Entrypoint = "../main.py" },
+		"unclean entrypoint": func(i *Image) { i.Entrypoint = "src//main.py" },
+	}
+	for name, mutate := range tests {
+		img := valid
+		mutate(&img)
+		if err := img.Validate(); err == nil {
+			t.Errorf("%s: Validate accepted %+v", name, img)
+		}
+	}
+}
+
+func TestValidateVolumeMounts(t *testing.T) {
+	valid := [][]VolumeMount{
+		nil,
+		{{Name: "data", MountPath: "/data"}},
+		{{Name: "data", MountPath: "/mnt/data"}, {Name: "models", MountPath: "/mnt/models", ReadOnly: true}},
+		{{Name: "cache", MountPath: "/workspace"}},
+	}
+	for _, mounts := range valid {
+		if err := ValidateVolumeMounts(mounts); err != nil {
+			t.Errorf("ValidateVolumeMounts(%+v) = %v, want nil", mounts, err)
+		}
+	}
+
+	tooMany := make([]VolumeMount, MaxVolumeMounts+1)
+	for i := range tooMany {
+		tooMany[i] = VolumeMount{Name: "v" + strings.Repeat("x", i), MountPath: "/v" + strings.Repeat("x", i)}
+	}
+	invalid := map[string][]VolumeMount{
+		"bad name":        {{Name: "Data", MountPath: "/data"}},
+		"relative path":   {{Name: "data", MountPath: "data"}},
+		"unclean path":    {{Name: "data", MountPath: "/data/../etc"}},
+		"root":            {{Name: "data", MountPath: "/"}},
+		"work dir":        {{Name: "data", MountPath: "/work"}},
+		"below reserved":  {{Name: "data", MountPath: "/usr/local/data"}},
+		"duplicate name":  {{Name: "data", MountPath: "/a"}, {Name: "data", MountPath: "/b"}},
+		"same path":       {{Name: "a", MountPath: "/data"}, {Name: "b", MountPath: "/data"}},
+		"nested path":     {{Name: "a", MountPath: "/data"}, {Name: "b", MountPath: "/data/sub"}},
+		"too many mounts": tooMany,
+	}
+	for name, mounts := range invalid {
+		if err := ValidateVolumeMounts(mounts); err == nil {
+			t.Errorf("%s: ValidateVolumeMounts accepted %+v", name, mounts)
+		}
+	}
+}
+
+func TestServiceSpecValidate(t *testing.T) {
+	valid := []ServiceSpec{
+		{},
+		{Restart: RestartPolicy{Policy: RestartOnFailure, MaxRestarts: 5, BackoffMS: 500, MaxBackoffMS: 8000}},
+		{HealthCheck: &HealthCheck{Type: HealthCheckHTTP, Port: 8080, Path: "/healthz"}},
+		{HealthCheck: &HealthCheck{Type: HealthCheckCommand, Command: []string{"pg_isready"}, Retries: 5}},
+	}
+	for _, spec := range valid {
+		if err := spec.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", spec, err)
+		}
+	}
+
+	invalid := map[string]ServiceSpec{
+		"bad policy":        {Restart: RestartPolicy{Policy: "sometimes"}},
+		"negative restarts": {Restart: RestartPolicy{MaxRestarts: -1}},
+		"max below backoff": {Restart: RestartPolicy{BackoffMS: 5000, MaxBackoffMS: 1000}},
+		"bad check type":    {HealthCheck: &HealthCheck{Type: "tcp", Port: 80}},
+		"http without port": {HealthCheck: &HealthCheck{Type: HealthCheckHTTP}},
+		"relative path":     {HealthCheck: &HealthCheck{Type: HealthCheckHTTP, Port: 80, Path: "healthz"}},
+		"http with command": {HealthCheck: &HealthCheck{Type: HealthCheckHTTP, Port: 80, Command: []string{"true"}}},
+		"empty command":     {HealthCheck: &HealthCheck{Type: HealthCheckCommand}},
+		"command with port": {HealthCheck: &HealthCheck{Type: HealthCheckCommand, Command: []string{"true"}, Port: 80}},
+		"negative interval": {HealthCheck: &HealthCheck{Type: HealthCheckCommand, Command: []string{"true"}, IntervalS: -1}},
+	}
+	for name, spec := range invalid {
+		if err := spec.Validate(); err == nil {
+			t.Errorf("%s: Validate accepted %+v", name, spec)
+		}
+	}
+}
+
+func TestServiceSpecWithDefaults(t *testing.T) {
+	spec := ServiceSpec{HealthCheck: &HealthCheck{Type: HealthCheckHTTP, Port: 8080}}.WithDefaults()
+
+	wantRestart := RestartPolicy{Policy: RestartAlways, BackoffMS: DefaultRestartBackoffMS, MaxBackoffMS: DefaultRestartMaxBackoffMS}
+	if spec.Restart != wantRestart {
+		t.Errorf("Restart = %+v, want %+v", spec.Restart, wantRestart)
+	}
+	hc := spec.HealthCheck
+	if hc.Path != "/" || hc.IntervalS != DefaultHealthIntervalS || hc.TimeoutS != DefaultHealthTimeoutS || hc.Retries != DefaultHealthRetries {
+		t.Errorf("HealthCheck = %+v, want defaults", hc)
+	}
+
+	// A backoff above the default maximum raises the maximum with it.
+	spec = ServiceSpec{Restart: RestartPolicy{BackoffMS: 120000}}.WithDefaults()
+	if spec.Restart.MaxBackoffMS != 120000 {
+		t.Errorf("MaxBackoffMS = %d, want 120000", spec.Restart.MaxBackoffMS)
+	}
+}
+
+func TestSessionLifetimes(t *testing.T) {
+	s := Session{}.WithDefaults()
+	if s.IdleTimeoutS != DefaultSessionIdleTimeoutS || s.MaxLifetimeS != DefaultSessionMaxLifetimeS {
+		t.Errorf("defaults = %d/%d, want %d/%d", s.IdleTimeoutS, s.MaxLifetimeS, DefaultSessionIdleTimeoutS, DefaultSessionMaxLifetimeS)
+	}
+	// A long idle timeout raises the default lifetime with it.
+	if s := (Session{IdleTimeoutS: 7200}).WithDefaults(); s.MaxLifetimeS != 7200 {
+		t.Errorf("MaxLifetimeS = %d, want 7200", s.MaxLifetimeS)
+	}
+
+	valid := []Session{{}, {IdleTimeoutS: 60}, {IdleTimeoutS: 60, MaxLifetimeS: 60}, {MaxLifetimeS: MaxSessionLifetimeS}}
+	for _, s := range valid {
+		if err := s.ValidateLifetimes(); err != nil {
+			t.Errorf("ValidateLifetimes(%d, %d) = %v, want nil", s.IdleTimeoutS, s.MaxLifetimeS, err)
+		}
+	}
+	invalid := map[string]Session{
+		"negative idle":     {IdleTimeoutS: -1},
+		"lifetime too long": {MaxLifetimeS: MaxSessionLifetimeS + 1},
+		"idle above max":    {IdleTimeoutS: 600, MaxLifetimeS: 300},
+		"idle too long":     {IdleTimeoutS: MaxSessionLifetimeS + 1},
+		"negative lifetime": {MaxLifetimeS: -5},
+	}
+	for name, s := range invalid {
+		if err := s.ValidateLifetimes(); err == nil {
+			t.Errorf("%s: ValidateLifetimes accepted %d, %d", name, s.IdleTimeoutS, s.MaxLifetimeS)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// Network policy modes.
+const (
+	// NetworkNone gives the workload no network interface at all.
+	NetworkNone = "none"
+
+	// NetworkEgress allows outbound traffic only to the destinations listed
+	// in the policy's allowlist.
+	NetworkEgress = "egress"
+
+	// NetworkFull allows all outbound traffic. Workloads remain isolated
+	// from each other.
+	NetworkFull = "full"
+)
+
+// NetworkModes lists the valid network policy modes.
+var NetworkModes = []string{NetworkNone, NetworkEgress, NetworkFull}
+
+// Egress rule protocols. An empty protocol matches both.
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// MaxEgressRules bounds the size of a network policy's allowlist.
+const MaxEgressRules = 64
+
+// NetworkPolicy controls a workload's network access. Workloads submitted
+// without a policy get NetworkFull.
+type NetworkPolicy struct {
+	Mode  string       `json:"mode"`
+	Allow []EgressRule `json:"allow,omitempty"`
+}
+
+// EgressRule permits outbound traffic to a destination CIDR, optionally
+// restricted to a protocol and destination ports.
+type EgressRule struct {
+	CIDR     string `json:"cidr"`
+	Protocol string `json:"protocol,omitempty"`
+	Ports    []int  `json:"ports,omitempty"`
+}
+
+// EffectiveMode returns the policy's mode, treating a nil policy as NetworkFull.
+func (p *NetworkPolicy) EffectiveMode() string {
+	if p == nil {
+		return NetworkFull
+	}
+	return p.Mode
+}
+
+// Allows reports whether the policy lets the workload send protocol traffic
+// to port on addr. A nil policy allows everything.
+func (p *NetworkPolicy) Allows(addr netip.Addr, protocol string, port int) bool {
+	switch p.EffectiveMode() {
+	case NetworkFull:
+		return true
+	case NetworkEgress:
+		return slices.ContainsFunc(p.Allow, func(r EgressRule) bool {
+			return r.allows(addr, protocol, port)
+		})
+	}
+	return false
+}
+
+// Validate checks that the policy is well formed.
+func (p *NetworkPolicy) Validate() error {
+	if !slices.Contains(NetworkModes, p.Mode) {
+		return fmt.Errorf("network mode must be one of %v", NetworkModes)
+	}
+	if p.Mode != NetworkEgress {
+		if len(p.Allow) > 0 {
+			return fmt.Errorf("network allow rules require mode %q", NetworkEgress)
+		}
+		return nil
+	}
+	if len(p.Allow) > MaxEgressRules {
+		return fmt.Errorf("network allow list exceeds %d rules", MaxEgressRules)
+	}
+	for i, rule := range p.Allow {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("network allow rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r EgressRule) validate() error {
+	if _, err := netip.ParsePrefix(r.CIDR); err != nil {
+		return fmt.Errorf("invalid cidr %q: use address/prefix, e.g. 203.0.113.7/32", r.CIDR)
+	}
+	switch r.Protocol {
+	case "", ProtocolTCP, ProtocolUDP:
+	default:
+		return fmt.Errorf("protocol must be %q, %q or empty for both", ProtocolTCP, ProtocolUDP)
+	}
+	for _, port := range r.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("port %d out of range", port)
+		}
+	}
+	return nil
+}
+
+func (r EgressRule) allows(addr netip.Addr, protocol string, port int) bool {
+	prefix, err := netip.ParsePrefix(r.CIDR)
+	if err != nil || !prefix.Contains(addr.Unmap()) {
+		return false
+	}
+	if r.Protocol != "" && r.Protocol != protocol {
+		return false
+	}
+	return len(r.Ports) == 0 || slices.Contains(r.Ports, port)
+}
+
+// GroupDomain is the DNS suffix under which workloads in a network group
+// resolve each other as <name>.<group>.GroupDomain.
+const GroupDomain = "vulcan"
+
+// maxDNSLabelLen is the longest DNS label (RFC 1035).
+const maxDNSLabelLen = 63
+
+// ValidateGroup checks a workload's network group and name. Both are used as
+// DNS labels, so they must be 1-63 lowercase letters, digits or hyphens and
+// must not start or end with a hyphen. A name is only meaningful within a
+// group.
+func ValidateGroup(group, name string) error {
+	if group == "" {
+		if name != "" {
+			return fmt.Errorf("name requires a group")
+		}
+		return nil
+	}
+	if !validDNSLabel(group) {
+		return fmt.Errorf("invalid group %q: use 1-%d lowercase letters, digits or hyphens", group, maxDNSLabelLen)
+	}
+	if name != "" && !validDNSLabel(name) {
+		return fmt.Errorf("invalid name %q: use 1-%d lowercase letters, digits or hyphens", name, maxDNSLabelLen)
+	}
+	return nil
+}
+
+func validDNSLabel(s string) bool {
+	if len(s) == 0 || len(s) > maxDNSLabelLen || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
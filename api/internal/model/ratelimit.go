@@ -0,0 +1,30 @@
+package model
+
+import "errors"
+
+// RateLimits caps a workload's disk and network throughput. A zero field
+// means unlimited. Network limits apply to each direction separately.
+type RateLimits struct {
+	DiskBytesPerS  int64 `json:"disk_bytes_per_s,omitempty"`
+	DiskIOPS       int64 `json:"disk_iops,omitempty"`
+	NetBytesPerS   int64 `json:"net_bytes_per_s,omitempty"`
+	NetPacketsPerS int64 `json:"net_packets_per_s,omitempty"`
+}
+
+// Validate checks that every limit is non-negative.
+func (l *RateLimits) Validate() error {
+	if l.DiskBytesPerS < 0 || l.DiskIOPS < 0 || l.NetBytesPerS < 0 || l.NetPacketsPerS < 0 {
+		return errors.New("rate limits must not be negative")
+	}
+	return nil
+}
+
+// IsZero reports whether no limit is set. It is true for a nil RateLimits.
+func (l *RateLimits) IsZero() bool {
+	return l == nil || *l == RateLimits{}
+}
+
+// HasNetwork reports whether a network limit is set.
+func (l *RateLimits) HasNetwork() bool {
+	return l != nil && (l.NetBytesPerS > 0 || l.NetPacketsPerS > 0)
+}
@@ -0,0 +1,180 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Workload kind constants. A job runs to completion; a service is kept
+// running, and restarted when its process exits, until it is stopped.
+const (
+	KindJob     = "job"
+	KindService = "service"
+)
+
+// Restart policy constants for service workloads.
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNever     = "never"
+)
+
+// RestartPolicies lists the valid restart policies.
+var RestartPolicies = []string{RestartAlways, RestartOnFailure, RestartNever}
+
+// Health constants reported for a running service workload.
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Health check type constants.
+const (
+	HealthCheckHTTP    = "http"
+	HealthCheckCommand = "command"
+)
+
+// Defaults applied to a service's restart policy and health check.
+const (
+	DefaultRestartBackoffMS    = 1000
+	DefaultRestartMaxBackoffMS = 60000
+	DefaultHealthIntervalS     = 10
+	DefaultHealthTimeoutS      = 5
+	DefaultHealthRetries       = 3
+)
+
+// ServiceSpec configures how a service workload is kept running.
+type ServiceSpec struct {
+	Restart RestartPolicy `json:"restart"`
+
+	// HealthCheck probes the service while it runs; nil reports it healthy
+	// for as long as its process is running.
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+}
+
+// RestartPolicy decides whether a service's process is restarted when it
+// exits. Consecutive restarts are delayed by BackoffMS, doubling up to
+// MaxBackoffMS; the delay is reset once a process has stayed up for longer
+// than MaxBackoffMS.
+type RestartPolicy struct {
+	// Policy is one of RestartPolicies; empty means RestartAlways.
+	Policy string `json:"policy"`
+
+	// MaxRestarts stops restarting after that many restarts; zero means no
+	// limit.
+	MaxRestarts int `json:"max_restarts,omitempty"`
+
+	BackoffMS    int `json:"backoff_ms"`
+	MaxBackoffMS int `json:"max_backoff_ms"`
+}
+
+// HealthCheck is a probe run inside the sandbox: an HTTP GET of Path on
+// Port, healthy on a 2xx or 3xx status, or a command, healthy when it exits
+// zero. The service becomes unhealthy after Retries consecutive failures,
+// and healthy again on the next success.
+type HealthCheck struct {
+	Type string `json:"type"`
+
+	Port int    `json:"port,omitempty"`
+	Path string `json:"path,omitempty"`
+
+	Command []string `json:"command,omitempty"`
+
+	IntervalS int `json:"interval_s"`
+	TimeoutS  int `json:"timeout_s"`
+	Retries   int `json:"retries"`
+
+	// StartPeriodS delays the first probe after each start of the process.
+	StartPeriodS int `json:"start_period_s,omitempty"`
+}
+
+// HealthEvent records a change in a service workload's health.
+type HealthEvent struct {
+	ID         int64  `json:"id"`
+	WorkloadID string `json:"workload_id"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+
+	// Restarts is how many times the service's process had been restarted
+	// when the change was reported.
+	Restarts  int       `json:"restarts"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WithDefaults returns a copy of s with unset restart and health check
+// settings given their default values.
+func (s ServiceSpec) WithDefaults() ServiceSpec {
+	if s.Restart.Policy == "" {
+		s.Restart.Policy = RestartAlways
+	}
+	if s.Restart.BackoffMS == 0 {
+		s.Restart.BackoffMS = DefaultRestartBackoffMS
+	}
+	if s.Restart.MaxBackoffMS == 0 {
+		s.Restart.MaxBackoffMS = max(DefaultRestartMaxBackoffMS, s.Restart.BackoffMS)
+	}
+	if s.HealthCheck != nil {
+		hc := *s.HealthCheck
+		if hc.Type == HealthCheckHTTP && hc.Path == "" {
+			hc.Path = "/"
+		}
+		if hc.IntervalS == 0 {
+			hc.IntervalS = DefaultHealthIntervalS
+		}
+		if hc.TimeoutS == 0 {
+			hc.TimeoutS = DefaultHealthTimeoutS
+		}
+		if hc.Retries == 0 {
+			hc.Retries = DefaultHealthRetries
+		}
+		s.HealthCheck = &hc
+	}
+	return s
+}
+
+// Validate checks the restart policy and health check.
+func (s *ServiceSpec) Validate() error {
+	r := s.Restart
+	if r.Policy != "" && !slices.Contains(RestartPolicies, r.Policy) {
+		return fmt.Errorf("invalid restart policy %q: must be one of %s", r.Policy, strings.Join(RestartPolicies, ", "))
+	}
+	if r.MaxRestarts < 0 || r.BackoffMS < 0 || r.MaxBackoffMS < 0 {
+		return fmt.Errorf("max_restarts, backoff_ms and max_backoff_ms must not be negative")
+	}
+	if r.MaxBackoffMS != 0 && r.MaxBackoffMS < r.BackoffMS {
+		return fmt.Errorf("max_backoff_ms must be at least backoff_ms")
+	}
+
+	hc := s.HealthCheck
+	if hc == nil {
+		return nil
+	}
+	switch hc.Type {
+	case HealthCheckHTTP:
+		if hc.Port < 1 || hc.Port > 65535 {
+			return fmt.Errorf("health_check port must be between 1 and 65535")
+		}
+		if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+			return fmt.Errorf("health_check path %q must start with /", hc.Path)
+		}
+		if len(hc.Command) > 0 {
+			return fmt.Errorf("health_check command is only valid for command checks")
+		}
+	case HealthCheckCommand:
+		if len(hc.Command) == 0 || hc.Command[0] == "" {
+			return fmt.Errorf("health_check command is required for command checks")
+		}
+		if hc.Port != 0 || hc.Path != "" {
+			return fmt.Errorf("health_check port and path are only valid for http checks")
+		}
+	default:
+		return fmt.Errorf("invalid health_check type %q: must be %s or %s", hc.Type, HealthCheckHTTP, HealthCheckCommand)
+	}
+	if hc.IntervalS < 0 || hc.TimeoutS < 0 || hc.Retries < 0 || hc.StartPeriodS < 0 {
+		return fmt.Errorf("health_check interval_s, timeout_s, retries and start_period_s must not be negative")
+	}
+	return nil
+}
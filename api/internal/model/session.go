@@ -0,0 +1,101 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Session status constants. A session is starting while its sandbox boots,
+// ready while it accepts executions, and closed or failed once the sandbox
+// is gone.
+const (
+	SessionStarting = "starting"
+	SessionReady    = "ready"
+	SessionClosed   = "closed"
+	SessionFailed   = "failed"
+)
+
+// Reasons a session was closed.
+const (
+	SessionClosedByClient  = "deleted"
+	SessionClosedIdle      = "idle_timeout"
+	SessionClosedLifetime  = "max_lifetime"
+	SessionClosedByRestart = "server_restart"
+)
+
+// Session lifetimes, in seconds.
+const (
+	DefaultSessionIdleTimeoutS = 300
+	DefaultSessionMaxLifetimeS = 3600
+	MaxSessionLifetimeS        = 86400
+)
+
+// InterpreterRuntimes lists the runtimes whose sessions can keep a
+// persistent interpreter.
+var InterpreterRuntimes = []string{RuntimeNode, RuntimePython}
+
+// Session is a sandbox kept alive between executions, so that each one
+// sees the files, and with Interpreter the interpreter state, left by the
+// ones before it. Executions are stored as workloads with the session's ID.
+type Session struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Isolation string `json:"isolation"`
+	Runtime   string `json:"runtime"`
+
+	// Interpreter runs executions in one long-lived interpreter process
+	// instead of a new process each.
+	Interpreter bool `json:"interpreter,omitempty"`
+
+	CPULimit *int           `json:"cpu_limit,omitempty"`
+	MemLimit *int           `json:"mem_limit,omitempty"`
+	Network  *NetworkPolicy `json:"network,omitempty"`
+
+	// The session is closed once it has been idle for IdleTimeoutS, or has
+	// existed for MaxLifetimeS, whichever comes first.
+	IdleTimeoutS int `json:"idle_timeout_s"`
+	MaxLifetimeS int `json:"max_lifetime_s"`
+
+	// Execs counts the executions run in the session.
+	Execs int `json:"execs"`
+
+	// CloseReason says why a closed session was closed, and Error why a
+	// failed one failed.
+	CloseReason string `json:"close_reason,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	LastExecAt *time.Time `json:"last_exec_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// WithDefaults returns the session with unset lifetimes defaulted.
+func (s Session) WithDefaults() Session {
+	if s.IdleTimeoutS == 0 {
+		s.IdleTimeoutS = DefaultSessionIdleTimeoutS
+	}
+	if s.MaxLifetimeS == 0 {
+		s.MaxLifetimeS = max(DefaultSessionMaxLifetimeS, s.IdleTimeoutS)
+	}
+	return s
+}
+
+// ValidateLifetimes checks a session's idle timeout and maximum lifetime;
+// zero requests the default.
+func (s *Session) ValidateLifetimes() error {
+	if s.IdleTimeoutS < 0 || s.MaxLifetimeS < 0 {
+		return fmt.Errorf("idle_timeout_s and max_lifetime_s must not be negative")
+	}
+	if s.MaxLifetimeS > MaxSessionLifetimeS || s.IdleTimeoutS > MaxSessionLifetimeS {
+		return fmt.Errorf("idle_timeout_s and max_lifetime_s must be at most %d", MaxSessionLifetimeS)
+	}
+	if s.MaxLifetimeS != 0 && s.IdleTimeoutS > s.MaxLifetimeS {
+		return fmt.Errorf("idle_timeout_s must not exceed max_lifetime_s")
+	}
+	return nil
+}
+
+// IsFinished reports whether the session's sandbox is gone.
+func (s *Session) IsFinished() bool {
+	return s.Status == SessionClosed || s.Status == SessionFailed
+}
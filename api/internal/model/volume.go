@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Volume is a named ext4 filesystem that outlives the workloads it is
+// attached to. A volume is attached read-write to at most one workload at a
+// time, or read-only to any number of them. Snapshots are frozen copies of a
+// volume that can only be attached read-only; cloning a volume or snapshot
+// gives a new writable volume.
+type Volume struct {
+	Name   string `json:"name"`
+	SizeMB int    `json:"size_mb"`
+
+	// Snapshot marks a read-only copy taken with a snapshot operation.
+	Snapshot bool `json:"snapshot"`
+
+	// Source is the volume this one was snapshotted or cloned from, if any.
+	Source string `json:"source,omitempty"`
+
+	// Path is where the volume's filesystem image is stored on the host.
+	Path string `json:"path"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	// Attachments lists the workloads, and operations such as snapshots,
+	// currently holding the volume.
+	Attachments []VolumeAttachment `json:"attachments"`
+}
+
+// VolumeAttachment records a holder of a volume: a workload it is attached
+// to, or an operation reading it.
+type VolumeAttachment struct {
+	Holder     string    `json:"holder"`
+	ReadOnly   bool      `json:"read_only"`
+	AttachedAt time.Time `json:"attached_at"`
+}
+
+// VolumeMount requests that a volume be attached to a workload and mounted
+// at MountPath inside its sandbox.
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mount_path"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+// MaxVolumeMounts bounds the number of volumes attached to one workload.
+const MaxVolumeMounts = 8
+
+var volumeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// reservedMountPaths are sandbox directories a volume may not be mounted on
+// or below: system directories, and those the guest agent itself uses for
+// code and dependencies.
+var reservedMountPaths = []string{
+	"/bin", "/dev", "/etc", "/lib", "/proc", "/sbin", "/sys", "/usr",
+	"/work", "/deps", "/mirror",
+}
+
+// ValidateVolumeName checks that name is 1-64 lowercase letters, digits,
+// '.', '_' or '-', starting with a letter or digit.
+func ValidateVolumeName(name string) error {
+	if !volumeNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid volume name %q: use up to 64 lowercase letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+// ValidateVolumeMounts checks a workload's volume mounts: each names a valid
+// volume at most once and is mounted at a distinct absolute path that is
+// neither reserved nor nested in another mount.
+func ValidateVolumeMounts(mounts []VolumeMount) error {
+	if len(mounts) > MaxVolumeMounts {
+		return fmt.Errorf("too many volumes: at most %d may be attached", MaxVolumeMounts)
+	}
+	for i, m := range mounts {
+		if err := ValidateVolumeName(m.Name); err != nil {
+			return err
+		}
+		if !path.IsAbs(m.MountPath) || path.Clean(m.MountPath) != m.MountPath || m.MountPath == "/" {
+			return fmt.Errorf("volume %s: mount_path %q must be a clean absolute path other than /", m.Name, m.MountPath)
+		}
+		for _, reserved := range reservedMountPaths {
+			if pathWithin(m.MountPath, reserved) {
+				return fmt.Errorf("volume %s: mount_path %q is reserved", m.Name, m.MountPath)
+			}
+		}
+		for _, prev := range mounts[:i] {
+			if prev.Name == m.Name {
+				return fmt.Errorf("volume %s is attached more than once", m.Name)
+			}
+			if pathWithin(m.MountPath, prev.MountPath) || pathWithin(prev.MountPath, m.MountPath) {
+				return fmt.Errorf("volume %s: mount_path %q overlaps that of volume %s", m.Name, m.MountPath, prev.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// pathWithin reports whether the clean absolute path p is dir or lies below it.
+func pathWithin(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
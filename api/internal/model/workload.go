@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"slices"
+	"time"
+)
 
 // Workload status constants.
 const (
@@ -28,6 +32,76 @@ const (
 	RuntimeOCI    = "oci"
 )
 
+// Signals lists the signal names that may be sent to a running workload.
+var Signals = []string{
+	"SIGHUP", "SIGINT", "SIGQUIT", "SIGKILL", "SIGUSR1",
+	"SIGUSR2", "SIGTERM", "SIGCONT", "SIGSTOP", "SIGWINCH",
+}
+
+// ValidSignal reports whether name may be sent to a running workload.
+func ValidSignal(name string) bool {
+	return slices.Contains(Signals, name)
+}
+
+// Exit reason constants, classifying how a finished workload ended.
+const (
+	// ExitReasonExited means the workload's process exited on its own,
+	// whatever its exit code.
+	ExitReasonExited = "exited"
+	// ExitReasonTimeout means the workload was stopped for running past
+	// its timeout.
+	ExitReasonTimeout = "timeout"
+	// ExitReasonOOMKilled means the kernel killed the workload's process
+	// for running out of memory.
+	ExitReasonOOMKilled = "oom_killed"
+	// ExitReasonSignaled means a signal killed the workload's process; the
+	// workload's ExitSignal names it.
+	ExitReasonSignaled = "signaled"
+	// ExitReasonVMCrashed means the sandbox's VM stopped while the workload
+	// was running.
+	ExitReasonVMCrashed = "vm_crashed"
+	// ExitReasonAgentUnreachable means the VM kept running but its guest
+	// agent could not be reached, or stopped answering.
+	ExitReasonAgentUnreachable = "agent_unreachable"
+	// ExitReasonInfraError means the platform failed to run the workload
+	// at all, e.g. because its sandbox could not be set up.
+	ExitReasonInfraError = "infra_error"
+)
+
+// ExitReasons lists every exit reason.
+var ExitReasons = []string{
+	ExitReasonExited, ExitReasonTimeout, ExitReasonOOMKilled, ExitReasonSignaled,
+	ExitReasonVMCrashed, ExitReasonAgentUnreachable, ExitReasonInfraError,
+}
+
+// ValidExitReason reports whether reason is one of ExitReasons.
+func ValidExitReason(reason string) bool {
+	return slices.Contains(ExitReasons, reason)
+}
+
+// MinDiskMB is the smallest scratch disk a workload may request.
+const MinDiskMB = 16
+
+// ValidateDiskMB checks a requested scratch disk size; zero requests none.
+func ValidateDiskMB(mb int) error {
+	if mb != 0 && mb < MinDiskMB {
+		return fmt.Errorf("disk_mb must be at least %d", MinDiskMB)
+	}
+	return nil
+}
+
+// MaxGraceS is the longest grace period a workload may ask for between
+// being told to stop and being killed.
+const MaxGraceS = 300
+
+// ValidateGraceS checks a requested grace period.
+func ValidateGraceS(s int) error {
+	if s < 1 || s > MaxGraceS {
+		return fmt.Errorf("grace_s must be between 1 and %d", MaxGraceS)
+	}
+	return nil
+}
+
 // validTransitions maps each status to the set of statuses it may transition to.
 var validTransitions = map[string]map[string]bool{
 	StatusPending: {
@@ -51,10 +125,32 @@ func ValidTransition(from, to string) bool {
 	return targets[to]
 }
 
+// The streams of a workload's own log lines: what it wrote to stdout and
+// stderr, and what the sandbox reported about running it, such as the
+// output of installing its dependencies. Lines from sandboxes that cannot
+// tell the streams apart have no stream.
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+	LogStreamSystem = "system"
+)
+
+// WorkloadLogStreams lists the streams of a workload's own log lines.
+var WorkloadLogStreams = []string{LogStreamStdout, LogStreamStderr, LogStreamSystem}
+
+// LogStreamConsole is the log stream holding a sandbox's console output,
+// kept when the sandbox failed to boot or its agent was unreachable.
+const LogStreamConsole = "console"
+
+// LogStreamBuild is the log stream holding the output of compiling a
+// workload's code, kept apart from the output of running it.
+const LogStreamBuild = "build"
+
 // LogLine represents a single persisted log line from a workload execution.
 type LogLine struct {
 	ID         int64     `json:"id"`
 	WorkloadID string    `json:"workload_id"`
+	Stream     string    `json:"stream,omitempty"`
 	Seq        int       `json:"seq"`
 	Line       string    `json:"line"`
 	CreatedAt  time.Time `json:"created_at"`
@@ -63,6 +159,7 @@ type LogLine struct {
 // Workload represents a compute workload submitted to the platform.
 type Workload struct {
 	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
 	Status     string     `json:"status"`
 	Isolation  string     `json:"isolation"`
 	Runtime    string     `json:"runtime"`
@@ -73,14 +170,94 @@ type Workload struct {
 	Error      string     `json:"error,omitempty"`
 	CPULimit   *int       `json:"cpu_limit,omitempty"`
 	MemLimit   *int       `json:"mem_limit,omitempty"`
+	DiskLimit  *int       `json:"disk_limit,omitempty"`
 	TimeoutS   *int       `json:"timeout_s,omitempty"`
+	GraceS     *int       `json:"grace_s,omitempty"`
 	DurationMS *int       `json:"duration_ms,omitempty"`
 	CreatedAt  time.Time  `json:"created_at"`
 	StartedAt  *time.Time `json:"started_at,omitempty"`
 	FinishedAt *time.Time `json:"finished_at,omitempty"`
 
+	// Usage is the resources consumed by the workload's process tree, when
+	// reported by the backend.
+	Usage *ResourceUsage `json:"usage,omitempty"`
+
+	// Stdout and Stderr are what the workload wrote to each stream, and
+	// Output is both, stdout first; the log keeps them interleaved as they
+	// were written. Both are empty when the backend cannot tell the streams
+	// apart.
+	Stdout []byte `json:"stdout,omitempty"`
+	Stderr []byte `json:"stderr,omitempty"`
+
+	// ExitReason classifies how a finished workload ended, one of
+	// ExitReasons, and ExitSignal names the signal that killed it when the
+	// reason is ExitReasonSignaled or ExitReasonOOMKilled. Both are empty
+	// until the workload finishes.
+	ExitReason string `json:"exit_reason,omitempty"`
+	ExitSignal string `json:"exit_signal,omitempty"`
+
+	// RateLimits caps the workload's disk and network throughput; nil means
+	// unlimited.
+	RateLimits *RateLimits `json:"rate_limits,omitempty"`
+
+	// Network is the workload's network policy; nil means NetworkFull.
+	Network *NetworkPolicy `json:"network,omitempty"`
+
+	// ExposePort is the port inside the sandbox that a server listens on and
+	// that should be reachable from outside.
+	ExposePort *int `json:"expose_port,omitempty"`
+
+	// EndpointURL is where the exposed port can be reached from the host. It
+	// is set only while the workload is running.
+	EndpointURL string `json:"endpoint_url,omitempty"`
+
+	// Group places the workload on a private network shared with the other
+	// workloads of the same group, where it resolves as Name.Group.vulcan.
+	Group string `json:"group,omitempty"`
+	Name  string `json:"name,omitempty"`
+
+	// Volumes are the persistent volumes attached to the workload while it
+	// runs.
+	Volumes []VolumeMount `json:"volumes,omitempty"`
+
+	// Service configures how a workload of KindService is kept running; it
+	// is nil for jobs.
+	Service *ServiceSpec `json:"service,omitempty"`
+
+	// Health is the last health reported for a service, and Restarts the
+	// number of times its process has been restarted since it was started or
+	// last updated.
+	Health   string `json:"health,omitempty"`
+	Restarts int    `json:"restarts,omitempty"`
+
+	// SessionID is the session a workload was executed in, if any.
+	SessionID string `json:"session_id,omitempty"`
+
 	// Code and CodeArchive are transient fields passed through to the backend
 	// during execution. They are not persisted to the database.
 	Code        string `json:"-"`
 	CodeArchive []byte `json:"-"`
 }
+
+// IsService reports whether the workload is a service rather than a job.
+func (w *Workload) IsService() bool {
+	return w.Kind == KindService
+}
+
+// ResourceUsage describes the resources consumed by a workload's process tree.
+type ResourceUsage struct {
+	CPUUserMS    int64 `json:"cpu_user_ms"`
+	CPUSysMS     int64 `json:"cpu_sys_ms"`
+	PeakRSSKB    int64 `json:"peak_rss_kb"`
+	IOReadBytes  int64 `json:"io_read_bytes"`
+	IOWriteBytes int64 `json:"io_write_bytes"`
+
+	// PeakMemoryKB is the peak memory of all the workload's processes
+	// together, page cache included; PeakRSSKB covers only the largest
+	// single process. Zero where it cannot be measured.
+	PeakMemoryKB int64 `json:"peak_memory_kb,omitempty"`
+
+	// DiskUsedBytes is the space taken on the workload's scratch disk when
+	// it exited; zero without one.
+	DiskUsedBytes int64 `json:"disk_used_bytes,omitempty"`
+}
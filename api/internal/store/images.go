@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+const createImagesTable = `
+CREATE TABLE IF NOT EXISTS images (
+    name          TEXT NOT NULL,
+    version       TEXT NOT NULL,
+    digest        TEXT NOT NULL,
+    size_bytes    INTEGER NOT NULL,
+    command       TEXT NOT NULL,
+    entrypoint    TEXT NOT NULL,
+    agent_version TEXT NOT NULL,
+    path          TEXT NOT NULL,
+    created_at    DATETIME NOT NULL,
+    last_used_at  DATETIME,
+    PRIMARY KEY (name, version)
+)`
+
+// ErrImageNotFound is returned when a catalog image is not found.
+var ErrImageNotFound = errors.New("image not found")
+
+// ErrImageExists is returned when registering an image version that is
+// already in the catalog.
+var ErrImageExists = errors.New("image version already exists")
+
+// imageColumns lists the columns read by scanImage, in order.
+const imageColumns = `name, version, digest, size_bytes, command, entrypoint,
+	agent_version, path, created_at, last_used_at`
+
+// scanImage reads an image from a row selected with imageColumns.
+func scanImage(row rowScanner) (*model.Image, error) {
+	img := &model.Image{}
+	var command string
+	var lastUsed sql.NullTime
+	if err := row.Scan(
+		&img.Name, &img.Version, &img.Digest, &img.SizeBytes, &command, &img.Entrypoint,
+		&img.AgentVersion, &img.Path, &img.CreatedAt, &lastUsed,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(command), &img.Command); err != nil {
+		return nil, fmt.Errorf("decode command: %w", err)
+	}
+	if lastUsed.Valid {
+		img.LastUsedAt = &lastUsed.Time
+	}
+	return img, nil
+}
+
+// CreateImage adds an image version to the catalog. Returns ErrImageExists
+// if the version is already registered.
+func (s *SQLiteStore) CreateImage(ctx context.Context, img *model.Image) error {
+	command, err := json.Marshal(img.Command)
+	if err != nil {
+		return fmt.Errorf("encode command: %w", err)
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO images (
+			name, version, digest, size_bytes, command, entrypoint,
+			agent_version, path, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name, version) DO NOTHING`,
+		img.Name, img.Version, img.Digest, img.SizeBytes, string(command), img.Entrypoint,
+		img.AgentVersion, img.Path, img.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert image: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrImageExists
+	}
+	return nil
+}
+
+// GetImage retrieves an image version, or the newest version of the image
+// when version is empty. Returns ErrImageNotFound if there is none.
+func (s *SQLiteStore) GetImage(ctx context.Context, name, version string) (*model.Image, error) {
+	query := "SELECT " + imageColumns + " FROM images WHERE name = ? AND version = ?"
+	args := []any{name, version}
+	if version == "" {
+		query = "SELECT " + imageColumns + " FROM images WHERE name = ? ORDER BY created_at DESC, rowid DESC LIMIT 1"
+		args = args[:1]
+	}
+
+	img, err := scanImage(s.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get image: %w", err)
+	}
+	return img, nil
+}
+
+// ListImages returns the versions of the named image, or of every image when
+// name is empty, sorted by name and then newest first.
+func (s *SQLiteStore) ListImages(ctx context.Context, name string) ([]*model.Image, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+imageColumns+` FROM images WHERE ? = '' OR name = ?
+		ORDER BY name, created_at DESC, rowid DESC`,
+		name, name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list images: %w", err)
+	}
+	defer rows.Close()
+
+	images := []*model.Image{}
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan image: %w", err)
+		}
+		images = append(images, img)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate images: %w", err)
+	}
+	return images, nil
+}
+
+// DeleteImage removes an image version from the catalog. Returns
+// ErrImageNotFound if it is not registered.
+func (s *SQLiteStore) DeleteImage(ctx context.Context, name, version string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM images WHERE name = ? AND version = ?", name, version)
+	if err != nil {
+		return fmt.Errorf("delete image: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrImageNotFound
+	}
+	return nil
+}
+
+// MarkImageUsed records that a workload started from an image version.
+// Returns ErrImageNotFound if it is not registered.
+func (s *SQLiteStore) MarkImageUsed(ctx context.Context, name, version string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE images SET last_used_at = ? WHERE name = ? AND version = ?",
+		at, name, version,
+	)
+	if err != nil {
+		return fmt.Errorf("mark image used: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrImageNotFound
+	}
+	return nil
+}
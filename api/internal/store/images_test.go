@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+func makeTestImage(name, version string, created time.Time) *model.Image {
+	return &model.Image{
+		Name:         name,
+		Version:      version,
+		Digest:       "sha256:" + name + version,
+		SizeBytes:    64 << 20,
+		Command:      []string{"python3", "-u"},
+		Entrypoint:   "main.py",
+		AgentVersion: "v1.4.0",
+		Path:         "/var/lib/vulcan/images/" + name + version + ".ext4",
+		CreatedAt:    created.UTC().Truncate(time.Second),
+	}
+}
+
+func TestCreateAndGetImage(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	img := makeTestImage("python", "3.12", time.Now())
+
+	if err := s.CreateImage(ctx, img); err != nil {
+		t.Fatalf("CreateImage: %v", err)
+	}
+	got, err := s.GetImage(ctx, "python", "3.12")
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	if got.Digest != img.Digest || got.Path != img.Path || got.AgentVersion != img.AgentVersion ||
+		got.Entrypoint != img.Entrypoint || !slices.Equal(got.Command, img.Command) ||
+		!got.CreatedAt.Equal(img.CreatedAt) || got.LastUsedAt != nil {
+		t.Errorf("GetImage = %+v, want %+v", got, img)
+	}
+
+	if err := s.CreateImage(ctx, img); !errors.Is(err, ErrImageExists) {
+		t.Errorf("duplicate CreateImage = %v, want ErrImageExists", err)
+	}
+	if _, err := s.GetImage(ctx, "python", "3.13"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("GetImage(missing) = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestGetImageNewestVersion(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, img := range []*model.Image{
+		makeTestImage("ml", "1.0", now.Add(-2*time.Hour)),
+		makeTestImage("ml", "2.0", now.Add(-time.Hour)),
+		makeTestImage("ml", "1.1", now.Add(-90*time.Minute)),
+	} {
+		if err := s.CreateImage(ctx, img); err != nil {
+			t.Fatalf("CreateImage: %v", err)
+		}
+	}
+
+	got, err := s.GetImage(ctx, "ml", "")
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	if got.Version != "2.0" {
+		t.Errorf("newest version = %s, want 2.0", got.Version)
+	}
+	if _, err := s.GetImage(ctx, "other", ""); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("GetImage(unknown) = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestListAndDeleteImages(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, img := range []*model.Image{
+		makeTestImage("python", "3.11", now.Add(-time.Hour)),
+		makeTestImage("ml", "1.0", now),
+		makeTestImage("python", "3.12", now),
+	} {
+		if err := s.CreateImage(ctx, img); err != nil {
+			t.Fatalf("CreateImage: %v", err)
+		}
+	}
+
+	all, err := s.ListImages(ctx, "")
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	var refs []string
+	for _, img := range all {
+		refs = append(refs, img.Ref())
+	}
+	if want := []string{"ml@1.0", "python@3.12", "python@3.11"}; !slices.Equal(refs, want) {
+		t.Errorf("ListImages = %v, want %v", refs, want)
+	}
+
+	python, err := s.ListImages(ctx, "python")
+	if err != nil {
+		t.Fatalf("ListImages(python): %v", err)
+	}
+	if len(python) != 2 {
+		t.Errorf("ListImages(python) = %d images, want 2", len(python))
+	}
+
+	if err := s.DeleteImage(ctx, "python", "3.11"); err != nil {
+		t.Fatalf("DeleteImage: %v", err)
+	}
+	if err := s.DeleteImage(ctx, "python", "3.11"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("second DeleteImage = %v, want ErrImageNotFound", err)
+	}
+	if python, _ := s.ListImages(ctx, "python"); len(python) != 1 {
+		t.Errorf("after delete ListImages(python) = %d images, want 1", len(python))
+	}
+}
+
+func TestMarkImageUsed(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	if err := s.CreateImage(ctx, makeTestImage("ml", "1.0", time.Now())); err != nil {
+		t.Fatalf("CreateImage: %v", err)
+	}
+
+	used := time.Now().UTC().Truncate(time.Second)
+	if err := s.MarkImageUsed(ctx, "ml", "1.0", used); err != nil {
+		t.Fatalf("MarkImageUsed: %v", err)
+	}
+	got, err := s.GetImage(ctx, "ml", "1.0")
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(used) {
+		t.Errorf("LastUsedAt = %v, want %v", got.LastUsedAt, used)
+	}
+
+	if err := s.MarkImageUsed(ctx, "ml", "9.9", used); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("MarkImageUsed(missing) = %v, want ErrImageNotFound", err)
+	}
+}
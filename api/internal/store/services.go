@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// Each row of health_events is a change in a service workload's health, or
+// a restart of its process.
+const createHealthEventsTable = `
+CREATE TABLE IF NOT EXISTS health_events (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    workload_id TEXT NOT NULL REFERENCES workloads(id),
+    status      TEXT NOT NULL,
+    detail      TEXT NOT NULL DEFAULT '',
+    restarts    INTEGER NOT NULL,
+    created_at  DATETIME NOT NULL
+)`
+
+const createHealthEventsIndex = `CREATE INDEX IF NOT EXISTS idx_health_events_workload ON health_events(workload_id, id)`
+
+// RecordHealth records a service workload's health and restart count, and
+// appends them to its health history. Returns ErrNotFound if the workload
+// does not exist.
+func (s *SQLiteStore) RecordHealth(ctx context.Context, id, status, detail string, restarts int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE workloads SET health = ?, restarts = ? WHERE id = ?",
+		status, restarts, id,
+	)
+	if err != nil {
+		return fmt.Errorf("update workload health: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO health_events (workload_id, status, detail, restarts, created_at) VALUES (?, ?, ?, ?, ?)",
+		id, status, detail, restarts, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("insert health event: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListHealthEvents returns a workload's health history, oldest first.
+func (s *SQLiteStore) ListHealthEvents(ctx context.Context, id string) ([]model.HealthEvent, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, workload_id, status, detail, restarts, created_at FROM health_events WHERE workload_id = ? ORDER BY id ASC",
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query health events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []model.HealthEvent{}
+	for rows.Next() {
+		var e model.HealthEvent
+		if err := rows.Scan(&e.ID, &e.WorkloadID, &e.Status, &e.Detail, &e.Restarts, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan health event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate health events: %w", err)
+	}
+	return events, nil
+}
+
+// UpdateWorkloadService replaces a service workload's spec. Returns
+// ErrNotFound if the workload does not exist.
+func (s *SQLiteStore) UpdateWorkloadService(ctx context.Context, id string, svc *model.ServiceSpec) error {
+	service, err := jsonArg(svc)
+	if err != nil {
+		return fmt.Errorf("encode service: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE workloads SET service = ? WHERE id = ?", service, id)
+	if err != nil {
+		return fmt.Errorf("update workload service: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+func TestCreateServiceWorkload(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	job := makeTestWorkload()
+	if err := s.CreateWorkload(ctx, job); err != nil {
+		t.Fatalf("CreateWorkload(job): %v", err)
+	}
+	got, err := s.GetWorkload(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Kind != model.KindJob || got.Service != nil {
+		t.Errorf("job Kind = %q, Service = %+v, want %q and nil", got.Kind, got.Service, model.KindJob)
+	}
+
+	svc := makeTestWorkload()
+	svc.Kind = model.KindService
+	spec := model.ServiceSpec{
+		Restart:     model.RestartPolicy{Policy: model.RestartOnFailure, MaxRestarts: 3},
+		HealthCheck: &model.HealthCheck{Type: model.HealthCheckHTTP, Port: 8080, Path: "/healthz"},
+	}.WithDefaults()
+	svc.Service = &spec
+	if err := s.CreateWorkload(ctx, svc); err != nil {
+		t.Fatalf("CreateWorkload(service): %v", err)
+	}
+	got, err = s.GetWorkload(ctx, svc.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if !got.IsService() || got.Service == nil || !reflect.DeepEqual(*got.Service, spec) {
+		t.Errorf("service Kind = %q, Service = %+v, want %+v", got.Kind, got.Service, spec)
+	}
+
+	updated := model.ServiceSpec{Restart: model.RestartPolicy{Policy: model.RestartAlways}}
+	if err := s.UpdateWorkloadService(ctx, svc.ID, &updated); err != nil {
+		t.Fatalf("UpdateWorkloadService: %v", err)
+	}
+	got, err = s.GetWorkload(ctx, svc.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Service.Restart.Policy != model.RestartAlways || got.Service.HealthCheck != nil {
+		t.Errorf("updated Service = %+v, want %+v", got.Service, updated)
+	}
+	if err := s.UpdateWorkloadService(ctx, "missing", &updated); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateWorkloadService(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRecordHealth(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	w := makeTestWorkload()
+	w.Kind = model.KindService
+	w.Service = &model.ServiceSpec{}
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	if err := s.UpdateWorkloadStatus(ctx, w.ID, model.StatusRunning); err != nil {
+		t.Fatalf("UpdateWorkloadStatus: %v", err)
+	}
+
+	events, err := s.ListHealthEvents(ctx, w.ID)
+	if err != nil || len(events) != 0 {
+		t.Fatalf("ListHealthEvents before reports = %v, %v, want none", events, err)
+	}
+
+	reports := []struct {
+		status, detail string
+		restarts       int
+	}{
+		{model.HealthStarting, "", 0},
+		{model.HealthHealthy, "", 0},
+		{model.HealthUnhealthy, "exited with code 1", 0},
+		{model.HealthStarting, "", 1},
+	}
+	for _, r := range reports {
+		if err := s.RecordHealth(ctx, w.ID, r.status, r.detail, r.restarts); err != nil {
+			t.Fatalf("RecordHealth(%s): %v", r.status, err)
+		}
+	}
+
+	got, err := s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Health != model.HealthStarting || got.Restarts != 1 {
+		t.Errorf("Health = %q, Restarts = %d, want %q, 1", got.Health, got.Restarts, model.HealthStarting)
+	}
+
+	events, err = s.ListHealthEvents(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("ListHealthEvents: %v", err)
+	}
+	if len(events) != len(reports) {
+		t.Fatalf("got %d events, want %d", len(events), len(reports))
+	}
+	for i, r := range reports {
+		e := events[i]
+		if e.WorkloadID != w.ID || e.Status != r.status || e.Detail != r.detail || e.Restarts != r.restarts || e.CreatedAt.IsZero() {
+			t.Errorf("event %d = %+v, want %+v", i, e, r)
+		}
+	}
+
+	// Health is only reported while the service runs; the history is kept.
+	if err := s.UpdateWorkloadStatus(ctx, w.ID, model.StatusCompleted); err != nil {
+		t.Fatalf("UpdateWorkloadStatus: %v", err)
+	}
+	got, err = s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Health != "" || got.Restarts != 1 {
+		t.Errorf("after completion Health = %q, Restarts = %d, want empty and 1", got.Health, got.Restarts)
+	}
+
+	if err := s.RecordHealth(ctx, "missing", model.HealthHealthy, "", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RecordHealth(missing) = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+const createSessionsTable = `
+CREATE TABLE IF NOT EXISTS sessions (
+    id             TEXT PRIMARY KEY,
+    status         TEXT NOT NULL,
+    isolation      TEXT NOT NULL,
+    runtime        TEXT NOT NULL,
+    interpreter    INTEGER NOT NULL DEFAULT 0,
+    cpu_limit      INTEGER,
+    mem_limit      INTEGER,
+    network        TEXT,
+    idle_timeout_s INTEGER NOT NULL,
+    max_lifetime_s INTEGER NOT NULL,
+    execs          INTEGER NOT NULL DEFAULT 0,
+    close_reason   TEXT NOT NULL DEFAULT '',
+    error          TEXT NOT NULL DEFAULT '',
+    created_at     DATETIME NOT NULL,
+    last_exec_at   DATETIME,
+    finished_at    DATETIME
+)`
+
+// Executions in a session are workloads carrying its ID.
+const createWorkloadsSessionIndex = `CREATE INDEX IF NOT EXISTS idx_workloads_session ON workloads(session_id, created_at)`
+
+// ErrSessionNotFound is returned when a session is not found.
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionColumns lists the columns read by scanSession, in order.
+const sessionColumns = `id, status, isolation, runtime, interpreter, cpu_limit, mem_limit,
+			network, idle_timeout_s, max_lifetime_s, execs, close_reason, error,
+			created_at, last_exec_at, finished_at`
+
+// scanSession reads a session from a row selected with sessionColumns.
+func scanSession(row rowScanner) (*model.Session, error) {
+	sess := &model.Session{}
+	var network sql.NullString
+	if err := row.Scan(
+		&sess.ID, &sess.Status, &sess.Isolation, &sess.Runtime, &sess.Interpreter, &sess.CPULimit, &sess.MemLimit,
+		&network, &sess.IdleTimeoutS, &sess.MaxLifetimeS, &sess.Execs, &sess.CloseReason, &sess.Error,
+		&sess.CreatedAt, &sess.LastExecAt, &sess.FinishedAt,
+	); err != nil {
+		return nil, err
+	}
+	if network.Valid {
+		sess.Network = &model.NetworkPolicy{}
+		if err := json.Unmarshal([]byte(network.String), sess.Network); err != nil {
+			return nil, fmt.Errorf("decode network policy: %w", err)
+		}
+	}
+	return sess, nil
+}
+
+// CreateSession inserts a new session record.
+func (s *SQLiteStore) CreateSession(ctx context.Context, sess *model.Session) error {
+	network, err := jsonArg(sess.Network)
+	if err != nil {
+		return fmt.Errorf("encode network policy: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO sessions (
+			id, status, isolation, runtime, interpreter, cpu_limit, mem_limit,
+			network, idle_timeout_s, max_lifetime_s, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sess.ID, sess.Status, sess.Isolation, sess.Runtime, sess.Interpreter, sess.CPULimit, sess.MemLimit,
+		network, sess.IdleTimeoutS, sess.MaxLifetimeS, sess.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	return nil
+}
+
+// GetSession retrieves a session by ID. Returns ErrSessionNotFound if there
+// is none.
+func (s *SQLiteStore) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	sess, err := scanSession(s.db.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+	return sess, nil
+}
+
+// ListSessions returns all sessions, newest first.
+func (s *SQLiteStore) ListSessions(ctx context.Context) ([]*model.Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*model.Session{}
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		sessions = append(sessions, sess)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// MarkSessionReady moves a starting session to ready. Returns
+// ErrSessionNotFound if there is no such starting session.
+func (s *SQLiteStore) MarkSessionReady(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET status = ? WHERE id = ? AND status = ?",
+		model.SessionReady, id, model.SessionStarting,
+	)
+	if err != nil {
+		return fmt.Errorf("update session status: %w", err)
+	}
+	return sessionAffected(res)
+}
+
+// FinishSession moves an unfinished session to status, closed or failed,
+// recording why. Returns ErrSessionNotFound if there is no such unfinished
+// session.
+func (s *SQLiteStore) FinishSession(ctx context.Context, id, status, reason, errMsg string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET status = ?, close_reason = ?, error = ?, finished_at = ?
+		WHERE id = ? AND status IN (?, ?)`,
+		status, reason, errMsg, time.Now().UTC(), id, model.SessionStarting, model.SessionReady,
+	)
+	if err != nil {
+		return fmt.Errorf("finish session: %w", err)
+	}
+	return sessionAffected(res)
+}
+
+// CloseUnfinishedSessions closes every session that is still starting or
+// ready with reason. Sessions do not outlive the process that started
+// them, so this is called at startup.
+func (s *SQLiteStore) CloseUnfinishedSessions(ctx context.Context, reason string) error {
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET status = ?, close_reason = ?, finished_at = ?
+		WHERE status IN (?, ?)`,
+		model.SessionClosed, reason, time.Now().UTC(), model.SessionStarting, model.SessionReady,
+	); err != nil {
+		return fmt.Errorf("close sessions: %w", err)
+	}
+	return nil
+}
+
+// RecordSessionExec counts an execution in a session that finished at at.
+// Returns ErrSessionNotFound if the session does not exist.
+func (s *SQLiteStore) RecordSessionExec(ctx context.Context, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET execs = execs + 1, last_exec_at = ? WHERE id = ?",
+		at, id,
+	)
+	if err != nil {
+		return fmt.Errorf("record session exec: %w", err)
+	}
+	return sessionAffected(res)
+}
+
+// ListSessionExecs returns the workloads run in a session, oldest first.
+func (s *SQLiteStore) ListSessionExecs(ctx context.Context, id string) ([]*model.Workload, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+workloadColumns+` FROM workloads WHERE session_id = ? ORDER BY created_at ASC`, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list session execs: %w", err)
+	}
+	defer rows.Close()
+
+	workloads := []*model.Workload{}
+	for rows.Next() {
+		w, err := scanWorkload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan workload: %w", err)
+		}
+		workloads = append(workloads, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate workloads: %w", err)
+	}
+	return workloads, nil
+}
+
+// sessionAffected returns ErrSessionNotFound if res changed no rows.
+func sessionAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
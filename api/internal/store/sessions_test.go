@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+func makeTestSession() *model.Session {
+	sess := model.Session{
+		ID:          model.NewID(),
+		Status:      model.SessionStarting,
+		Isolation:   model.IsolationMicroVM,
+		Runtime:     model.RuntimePython,
+		Interpreter: true,
+		Network:     &model.NetworkPolicy{Mode: model.NetworkNone},
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}.WithDefaults()
+	return &sess
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	sess := makeTestSession()
+	if err := s.CreateSession(ctx, sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	got, err := s.GetSession(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.Status != model.SessionStarting || !got.Interpreter || got.Network == nil || got.IdleTimeoutS != model.DefaultSessionIdleTimeoutS {
+		t.Errorf("GetSession = %+v, want the stored session", got)
+	}
+
+	if err := s.MarkSessionReady(ctx, sess.ID); err != nil {
+		t.Fatalf("MarkSessionReady: %v", err)
+	}
+	at := time.Now().UTC().Truncate(time.Second)
+	if err := s.RecordSessionExec(ctx, sess.ID, at); err != nil {
+		t.Fatalf("RecordSessionExec: %v", err)
+	}
+	if err := s.FinishSession(ctx, sess.ID, model.SessionClosed, model.SessionClosedIdle, ""); err != nil {
+		t.Fatalf("FinishSession: %v", err)
+	}
+	got, err = s.GetSession(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.Status != model.SessionClosed || got.CloseReason != model.SessionClosedIdle || got.FinishedAt == nil {
+		t.Errorf("finished session = %+v, want closed for idleness", got)
+	}
+	if got.Execs != 1 || got.LastExecAt == nil || !got.LastExecAt.Equal(at) {
+		t.Errorf("execs %d last exec %v, want 1 at %v", got.Execs, got.LastExecAt, at)
+	}
+
+	if err := s.FinishSession(ctx, sess.ID, model.SessionFailed, "", "boom"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second FinishSession = %v, want ErrSessionNotFound", err)
+	}
+	if err := s.MarkSessionReady(ctx, sess.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("MarkSessionReady after close = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := s.GetSession(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession(missing) = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestListSessionExecs(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	sess := makeTestSession()
+	if err := s.CreateSession(ctx, sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	var ids []string
+	for i := range 2 {
+		w := makeTestWorkload()
+		w.SessionID = sess.ID
+		w.CreatedAt = w.CreatedAt.Add(time.Duration(i) * time.Second)
+		if err := s.CreateWorkload(ctx, w); err != nil {
+			t.Fatalf("CreateWorkload: %v", err)
+		}
+		ids = append(ids, w.ID)
+	}
+	if err := s.CreateWorkload(ctx, makeTestWorkload()); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+
+	execs, err := s.ListSessionExecs(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("ListSessionExecs: %v", err)
+	}
+	if len(execs) != 2 || execs[0].ID != ids[0] || execs[1].ID != ids[1] {
+		t.Fatalf("ListSessionExecs = %d workloads, want %v in order", len(execs), ids)
+	}
+	if execs[0].SessionID != sess.ID {
+		t.Errorf("SessionID = %q, want %q", execs[0].SessionID, sess.ID)
+	}
+}
+
+func TestCloseUnfinishedSessions(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	open, failed := makeTestSession(), makeTestSession()
+	for _, sess := range []*model.Session{open, failed} {
+		if err := s.CreateSession(ctx, sess); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+	}
+	if err := s.FinishSession(ctx, failed.ID, model.SessionFailed, "", "boot failed"); err != nil {
+		t.Fatalf("FinishSession: %v", err)
+	}
+
+	if err := s.CloseUnfinishedSessions(ctx, model.SessionClosedByRestart); err != nil {
+		t.Fatalf("CloseUnfinishedSessions: %v", err)
+	}
+	sessions, err := s.ListSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	for _, sess := range sessions {
+		switch sess.ID {
+		case open.ID:
+			if sess.Status != model.SessionClosed || sess.CloseReason != model.SessionClosedByRestart {
+				t.Errorf("open session = %q %q, want closed by restart", sess.Status, sess.CloseReason)
+			}
+		case failed.ID:
+			if sess.Status != model.SessionFailed || sess.Error != "boot failed" {
+				t.Errorf("failed session = %q %q, want it left failed", sess.Status, sess.Error)
+			}
+		}
+	}
+	if len(sessions) != 2 {
+		t.Errorf("ListSessions = %d sessions, want 2", len(sessions))
+	}
+}
@@ -3,8 +3,10 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/seantiz/vulcan/internal/model"
@@ -29,18 +31,97 @@ CREATE TABLE IF NOT EXISTS workloads (
     duration_ms INTEGER,
     created_at  DATETIME NOT NULL,
     started_at  DATETIME,
-    finished_at DATETIME
+    finished_at DATETIME,
+    cpu_user_ms    INTEGER,
+    cpu_sys_ms     INTEGER,
+    peak_rss_kb    INTEGER,
+    io_read_bytes  INTEGER,
+    io_write_bytes INTEGER,
+    network        TEXT,
+    expose_port    INTEGER,
+    endpoint_url   TEXT,
+    network_group  TEXT,
+    name           TEXT,
+    rate_limits    TEXT,
+    volumes        TEXT,
+    disk_limit     INTEGER,
+    disk_used_bytes INTEGER,
+    kind           TEXT NOT NULL DEFAULT 'job',
+    service        TEXT,
+    health         TEXT,
+    restarts       INTEGER NOT NULL DEFAULT 0,
+    session_id     TEXT,
+    stdout         BLOB,
+    stderr         BLOB,
+    exit_reason    TEXT,
+    exit_signal    TEXT,
+    grace_s        INTEGER,
+    peak_memory_kb INTEGER
 )`
 
+// addedWorkloadColumns lists columns added to the workloads table after its
+// initial release, so that databases created by earlier versions can be
+// upgraded in place.
+var addedWorkloadColumns = []struct {
+	name       string
+	definition string
+}{
+	{"cpu_user_ms", "INTEGER"},
+	{"cpu_sys_ms", "INTEGER"},
+	{"peak_rss_kb", "INTEGER"},
+	{"io_read_bytes", "INTEGER"},
+	{"io_write_bytes", "INTEGER"},
+	{"network", "TEXT"},
+	{"expose_port", "INTEGER"},
+	{"endpoint_url", "TEXT"},
+	{"network_group", "TEXT"},
+	{"name", "TEXT"},
+	{"rate_limits", "TEXT"},
+	{"volumes", "TEXT"},
+	{"disk_limit", "INTEGER"},
+	{"disk_used_bytes", "INTEGER"},
+	{"kind", "TEXT NOT NULL DEFAULT 'job'"},
+	{"service", "TEXT"},
+	{"health", "TEXT"},
+	{"restarts", "INTEGER NOT NULL DEFAULT 0"},
+	{"session_id", "TEXT"},
+	{"stdout", "BLOB"},
+	{"stderr", "BLOB"},
+	{"exit_reason", "TEXT"},
+	{"exit_signal", "TEXT"},
+	{"grace_s", "INTEGER"},
+	{"peak_memory_kb", "INTEGER"},
+}
+
+// workloadColumns is the column list read by scanWorkload.
+const workloadColumns = `id, status, isolation, runtime, node_id, input_hash,
+			output, exit_code, error, cpu_limit, mem_limit, timeout_s,
+			duration_ms, created_at, started_at, finished_at,
+			cpu_user_ms, cpu_sys_ms, peak_rss_kb, io_read_bytes, io_write_bytes,
+			network, expose_port, endpoint_url, network_group, name, rate_limits,
+			volumes, disk_limit, disk_used_bytes, kind, service, health, restarts,
+			session_id, stdout, stderr, exit_reason, exit_signal, grace_s,
+			peak_memory_kb`
+
 const createLogLinesTable = `
 CREATE TABLE IF NOT EXISTS log_lines (
     id          INTEGER PRIMARY KEY AUTOINCREMENT,
     workload_id TEXT NOT NULL REFERENCES workloads(id),
     seq         INTEGER NOT NULL,
     line        TEXT NOT NULL,
-    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    stream      TEXT NOT NULL DEFAULT ''
 )`
 
+// addedLogLineColumns lists columns added to the log_lines table after its
+// initial release.
+var addedLogLineColumns = []struct {
+	name       string
+	definition string
+}{
+	{"stream", "TEXT NOT NULL DEFAULT ''"},
+}
+
 const createLogLinesIndex = `CREATE INDEX IF NOT EXISTS idx_log_lines_workload ON log_lines(workload_id, seq)`
 
 // ErrNotFound is returned when a workload is not found.
@@ -81,19 +162,193 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 		return nil, fmt.Errorf("create workloads table: %w", err)
 	}
 
+	if err := addMissingColumns(db, "workloads", addedWorkloadColumns); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate workloads table: %w", err)
+	}
+
 	if _, err := db.Exec(createLogLinesTable); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("create log_lines table: %w", err)
 	}
 
+	if err := addMissingColumns(db, "log_lines", addedLogLineColumns); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate log_lines table: %w", err)
+	}
+
 	if _, err := db.Exec(createLogLinesIndex); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("create log_lines index: %w", err)
 	}
 
+	if _, err := db.Exec(createImagesTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create images table: %w", err)
+	}
+
+	if _, err := db.Exec(createVolumesTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create volumes table: %w", err)
+	}
+
+	if _, err := db.Exec(createVolumeAttachmentsTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create volume_attachments table: %w", err)
+	}
+
+	if _, err := db.Exec(createHealthEventsTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create health_events table: %w", err)
+	}
+
+	if _, err := db.Exec(createHealthEventsIndex); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create health_events index: %w", err)
+	}
+
+	if _, err := db.Exec(createSessionsTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create sessions table: %w", err)
+	}
+
+	if _, err := db.Exec(createWorkloadsSessionIndex); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create workloads session index: %w", err)
+	}
+
 	return &SQLiteStore{db: db}, nil
 }
 
+// addMissingColumns adds any of columns not yet present in table.
+func addMissingColumns(db *sql.DB, table string, columns []struct {
+	name       string
+	definition string
+}) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return fmt.Errorf("read columns: %w", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan column: %w", err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate columns: %w", err)
+	}
+
+	for _, c := range columns {
+		if existing[c.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, c.name, c.definition)); err != nil {
+			return fmt.Errorf("add column %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanWorkload reads a workload from a row selected with workloadColumns.
+func scanWorkload(row rowScanner) (*model.Workload, error) {
+	w := &model.Workload{}
+	var cpuUser, cpuSys, peakRSS, ioRead, ioWrite, diskUsed, peakMemory sql.NullInt64
+	var network, endpointURL, group, name, rateLimits, volumes, service, health, sessionID sql.NullString
+	var exitReason, exitSignal sql.NullString
+	if err := row.Scan(
+		&w.ID, &w.Status, &w.Isolation, &w.Runtime, &w.NodeID, &w.InputHash,
+		&w.Output, &w.ExitCode, &w.Error, &w.CPULimit, &w.MemLimit, &w.TimeoutS,
+		&w.DurationMS, &w.CreatedAt, &w.StartedAt, &w.FinishedAt,
+		&cpuUser, &cpuSys, &peakRSS, &ioRead, &ioWrite,
+		&network, &w.ExposePort, &endpointURL, &group, &name, &rateLimits,
+		&volumes, &w.DiskLimit, &diskUsed, &w.Kind, &service, &health, &w.Restarts,
+		&sessionID, &w.Stdout, &w.Stderr, &exitReason, &exitSignal, &w.GraceS,
+		&peakMemory,
+	); err != nil {
+		return nil, err
+	}
+	if cpuUser.Valid {
+		w.Usage = &model.ResourceUsage{
+			CPUUserMS:     cpuUser.Int64,
+			CPUSysMS:      cpuSys.Int64,
+			PeakRSSKB:     peakRSS.Int64,
+			IOReadBytes:   ioRead.Int64,
+			IOWriteBytes:  ioWrite.Int64,
+			PeakMemoryKB:  peakMemory.Int64,
+			DiskUsedBytes: diskUsed.Int64,
+		}
+	}
+	w.EndpointURL = endpointURL.String
+	w.Group = group.String
+	w.Name = name.String
+	w.Health = health.String
+	w.SessionID = sessionID.String
+	w.ExitReason = exitReason.String
+	w.ExitSignal = exitSignal.String
+	if network.Valid {
+		w.Network = &model.NetworkPolicy{}
+		if err := json.Unmarshal([]byte(network.String), w.Network); err != nil {
+			return nil, fmt.Errorf("decode network policy: %w", err)
+		}
+	}
+	if rateLimits.Valid {
+		w.RateLimits = &model.RateLimits{}
+		if err := json.Unmarshal([]byte(rateLimits.String), w.RateLimits); err != nil {
+			return nil, fmt.Errorf("decode rate limits: %w", err)
+		}
+	}
+	if volumes.Valid {
+		if err := json.Unmarshal([]byte(volumes.String), &w.Volumes); err != nil {
+			return nil, fmt.Errorf("decode volumes: %w", err)
+		}
+	}
+	if service.Valid {
+		w.Service = &model.ServiceSpec{}
+		if err := json.Unmarshal([]byte(service.String), w.Service); err != nil {
+			return nil, fmt.Errorf("decode service: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// jsonArg encodes v as a JSON column value, or NULL when v is nil.
+func jsonArg[T any](v *T) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// nullString returns s as a column value, NULL when empty.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// usageArgs returns the usage column values for u, all NULL when u is nil.
+func usageArgs(u *model.ResourceUsage) []any {
+	if u == nil {
+		return []any{nil, nil, nil, nil, nil, nil, nil}
+	}
+	return []any{u.CPUUserMS, u.CPUSysMS, u.PeakRSSKB, u.IOReadBytes, u.IOWriteBytes, u.DiskUsedBytes, u.PeakMemoryKB}
+}
+
 // Close closes the underlying database connection.
 func (s *SQLiteStore) Close() error {
 	return s.db.Close()
@@ -101,15 +356,42 @@ func (s *SQLiteStore) Close() error {
 
 // CreateWorkload inserts a new workload record.
 func (s *SQLiteStore) CreateWorkload(ctx context.Context, w *model.Workload) error {
-	_, err := s.db.ExecContext(ctx,
+	network, err := jsonArg(w.Network)
+	if err != nil {
+		return fmt.Errorf("encode network policy: %w", err)
+	}
+	rateLimits, err := jsonArg(w.RateLimits)
+	if err != nil {
+		return fmt.Errorf("encode rate limits: %w", err)
+	}
+	var volumes any
+	if len(w.Volumes) > 0 {
+		if volumes, err = jsonArg(&w.Volumes); err != nil {
+			return fmt.Errorf("encode volumes: %w", err)
+		}
+	}
+	service, err := jsonArg(w.Service)
+	if err != nil {
+		return fmt.Errorf("encode service: %w", err)
+	}
+	kind := w.Kind
+	if kind == "" {
+		kind = model.KindJob
+	}
+
+	_, err = s.db.ExecContext(ctx,
 		`INSERT INTO workloads (
 			id, status, isolation, runtime, node_id, input_hash,
 			output, exit_code, error, cpu_limit, mem_limit, timeout_s,
-			duration_ms, created_at, started_at, finished_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			duration_ms, created_at, started_at, finished_at, network,
+			expose_port, network_group, name, rate_limits, volumes, disk_limit,
+			kind, service, session_id, grace_s
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		w.ID, w.Status, w.Isolation, w.Runtime, w.NodeID, w.InputHash,
 		w.Output, w.ExitCode, w.Error, w.CPULimit, w.MemLimit, w.TimeoutS,
-		w.DurationMS, w.CreatedAt, w.StartedAt, w.FinishedAt,
+		w.DurationMS, w.CreatedAt, w.StartedAt, w.FinishedAt, network,
+		w.ExposePort, nullString(w.Group), nullString(w.Name), rateLimits, volumes, w.DiskLimit,
+		kind, service, nullString(w.SessionID), w.GraceS,
 	)
 	if err != nil {
 		return fmt.Errorf("insert workload: %w", err)
@@ -119,17 +401,9 @@ func (s *SQLiteStore) CreateWorkload(ctx context.Context, w *model.Workload) err
 
 // GetWorkload retrieves a workload by ID.
 func (s *SQLiteStore) GetWorkload(ctx context.Context, id string) (*model.Workload, error) {
-	w := &model.Workload{}
-	err := s.db.QueryRowContext(ctx,
-		`SELECT id, status, isolation, runtime, node_id, input_hash,
-			output, exit_code, error, cpu_limit, mem_limit, timeout_s,
-			duration_ms, created_at, started_at, finished_at
-		FROM workloads WHERE id = ?`, id,
-	).Scan(
-		&w.ID, &w.Status, &w.Isolation, &w.Runtime, &w.NodeID, &w.InputHash,
-		&w.Output, &w.ExitCode, &w.Error, &w.CPULimit, &w.MemLimit, &w.TimeoutS,
-		&w.DurationMS, &w.CreatedAt, &w.StartedAt, &w.FinishedAt,
-	)
+	w, err := scanWorkload(s.db.QueryRowContext(ctx,
+		`SELECT `+workloadColumns+` FROM workloads WHERE id = ?`, id,
+	))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
 	}
@@ -139,25 +413,25 @@ func (s *SQLiteStore) GetWorkload(ctx context.Context, id string) (*model.Worklo
 	return w, nil
 }
 
-// ListWorkloads returns a paginated list of workloads ordered by created_at DESC,
-// along with the total count of all workloads.
-func (s *SQLiteStore) ListWorkloads(ctx context.Context, limit, offset int) ([]*model.Workload, int, error) {
+// ListWorkloads returns a paginated list of the workloads matching filter
+// ordered by created_at DESC, along with the total count of matching workloads.
+func (s *SQLiteStore) ListWorkloads(ctx context.Context, filter WorkloadFilter, limit, offset int) ([]*model.Workload, int, error) {
 	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
 		return nil, 0, fmt.Errorf("begin read tx: %w", err)
 	}
 	defer tx.Rollback()
 
+	where, args := filter.where()
+
 	var total int
-	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM workloads").Scan(&total); err != nil {
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM workloads"+where, args...).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("count workloads: %w", err)
 	}
 
 	rows, err := tx.QueryContext(ctx,
-		`SELECT id, status, isolation, runtime, node_id, input_hash,
-			output, exit_code, error, cpu_limit, mem_limit, timeout_s,
-			duration_ms, created_at, started_at, finished_at
-		FROM workloads ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset,
+		`SELECT `+workloadColumns+` FROM workloads`+where+` ORDER BY created_at DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
 	)
 	if err != nil {
 		return nil, 0, fmt.Errorf("list workloads: %w", err)
@@ -166,12 +440,8 @@ func (s *SQLiteStore) ListWorkloads(ctx context.Context, limit, offset int) ([]*
 
 	var workloads []*model.Workload
 	for rows.Next() {
-		w := &model.Workload{}
-		if err := rows.Scan(
-			&w.ID, &w.Status, &w.Isolation, &w.Runtime, &w.NodeID, &w.InputHash,
-			&w.Output, &w.ExitCode, &w.Error, &w.CPULimit, &w.MemLimit, &w.TimeoutS,
-			&w.DurationMS, &w.CreatedAt, &w.StartedAt, &w.FinishedAt,
-		); err != nil {
+		w, err := scanWorkload(rows)
+		if err != nil {
 			return nil, 0, fmt.Errorf("scan workload: %w", err)
 		}
 		workloads = append(workloads, w)
@@ -183,6 +453,15 @@ func (s *SQLiteStore) ListWorkloads(ctx context.Context, limit, offset int) ([]*
 	return workloads, total, nil
 }
 
+// where returns the WHERE clause selecting the workloads matching f, empty
+// if f matches every workload, and its arguments.
+func (f WorkloadFilter) where() (string, []any) {
+	if f.ExitReason == "" {
+		return "", nil
+	}
+	return " WHERE exit_reason = ?", []any{f.ExitReason}
+}
+
 // UpdateWorkloadStatus updates the status of a workload after validating the
 // transition. For terminal statuses (killed, completed, failed), it also sets
 // finished_at. For running, it sets started_at. Returns ErrInvalidTransition
@@ -220,7 +499,7 @@ func (s *SQLiteStore) UpdateWorkloadStatus(ctx context.Context, id, status strin
 		)
 	case status == model.StatusKilled || status == model.StatusCompleted || status == model.StatusFailed:
 		_, err = tx.ExecContext(ctx,
-			"UPDATE workloads SET status = ?, finished_at = ? WHERE id = ?",
+			"UPDATE workloads SET status = ?, finished_at = ?, endpoint_url = NULL, health = NULL WHERE id = ?",
 			status, now, id,
 		)
 	default:
@@ -238,9 +517,12 @@ func (s *SQLiteStore) UpdateWorkloadStatus(ctx context.Context, id, status strin
 }
 
 // UpdateWorkload updates the mutable fields of a workload: status, output,
-// exit_code, error, duration_ms, started_at, and finished_at. Immutable fields
-// (id, runtime, isolation, node_id, input_hash, cpu_limit, mem_limit, timeout_s,
-// created_at) are not modified. Validates the state transition if the status has
+// stdout, stderr, exit_code, error, exit_reason, exit_signal, duration_ms, started_at, finished_at, resource usage,
+// endpoint_url and health. Immutable fields
+// (id, kind, runtime, isolation, node_id, input_hash, cpu_limit, mem_limit, disk_limit,
+// timeout_s, grace_s, network, expose_port, network_group, name, rate_limits, volumes, created_at) are not modified;
+// a service's spec and restart count are set by UpdateWorkloadService and
+// RecordHealth. Validates the state transition if the status has
 // changed. Returns ErrNotFound if the workload does not exist, or
 // ErrInvalidTransition if the status change is not allowed.
 func (s *SQLiteStore) UpdateWorkload(ctx context.Context, w *model.Workload) error {
@@ -263,13 +545,20 @@ func (s *SQLiteStore) UpdateWorkload(ctx context.Context, w *model.Workload) err
 		return fmt.Errorf("%w: cannot transition from %q to %q", ErrInvalidTransition, current, w.Status)
 	}
 
+	args := []any{w.Status, w.Output, w.Stdout, w.Stderr, w.ExitCode, w.Error,
+		nullString(w.ExitReason), nullString(w.ExitSignal), w.DurationMS, w.StartedAt, w.FinishedAt}
+	args = append(args, usageArgs(w.Usage)...)
+	args = append(args, nullString(w.EndpointURL), nullString(w.Health), w.ID)
+
 	_, err = tx.ExecContext(ctx,
 		`UPDATE workloads SET
-			status = ?, output = ?, exit_code = ?, error = ?,
-			duration_ms = ?, started_at = ?, finished_at = ?
+			status = ?, output = ?, stdout = ?, stderr = ?, exit_code = ?, error = ?,
+			exit_reason = ?, exit_signal = ?, duration_ms = ?, started_at = ?, finished_at = ?,
+			cpu_user_ms = ?, cpu_sys_ms = ?, peak_rss_kb = ?,
+			io_read_bytes = ?, io_write_bytes = ?, disk_used_bytes = ?, peak_memory_kb = ?,
+			endpoint_url = ?, health = ?
 		WHERE id = ?`,
-		w.Status, w.Output, w.ExitCode, w.Error,
-		w.DurationMS, w.StartedAt, w.FinishedAt, w.ID,
+		args...,
 	)
 	if err != nil {
 		return fmt.Errorf("update workload: %w", err)
@@ -278,11 +567,32 @@ func (s *SQLiteStore) UpdateWorkload(ctx context.Context, w *model.Workload) err
 	return tx.Commit()
 }
 
-// InsertLogLine persists a single log line for a workload.
-func (s *SQLiteStore) InsertLogLine(ctx context.Context, workloadID string, seq int, line string) error {
+// SetWorkloadEndpoint records the URL of a running workload's exposed port.
+// An empty url clears it. Returns ErrNotFound if the workload does not exist.
+func (s *SQLiteStore) SetWorkloadEndpoint(ctx context.Context, id, url string) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE workloads SET endpoint_url = ? WHERE id = ?",
+		nullString(url), id,
+	)
+	if err != nil {
+		return fmt.Errorf("set workload endpoint: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// InsertLogLine persists a single log line for a workload, written to the
+// given stream: one of model.WorkloadLogStreams, or empty if unknown.
+func (s *SQLiteStore) InsertLogLine(ctx context.Context, workloadID string, seq int, stream, line string) error {
 	_, err := s.db.ExecContext(ctx,
-		"INSERT INTO log_lines (workload_id, seq, line) VALUES (?, ?, ?)",
-		workloadID, seq, line,
+		"INSERT INTO log_lines (workload_id, stream, seq, line) VALUES (?, ?, ?, ?)",
+		workloadID, stream, seq, line,
 	)
 	if err != nil {
 		return fmt.Errorf("insert log line: %w", err)
@@ -290,11 +600,72 @@ func (s *SQLiteStore) InsertLogLine(ctx context.Context, workloadID string, seq
 	return nil
 }
 
-// GetLogLines retrieves all log lines for a workload ordered by sequence number.
+// InsertConsoleLines persists a workload's console output, replacing any
+// previously stored.
+func (s *SQLiteStore) InsertConsoleLines(ctx context.Context, workloadID string, lines []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM log_lines WHERE workload_id = ? AND stream = ?",
+		workloadID, model.LogStreamConsole,
+	); err != nil {
+		return fmt.Errorf("delete console lines: %w", err)
+	}
+	for seq, line := range lines {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO log_lines (workload_id, stream, seq, line) VALUES (?, ?, ?, ?)",
+			workloadID, model.LogStreamConsole, seq, line,
+		); err != nil {
+			return fmt.Errorf("insert console line: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// InsertBuildLogLine persists a single line of a workload's build output.
+func (s *SQLiteStore) InsertBuildLogLine(ctx context.Context, workloadID string, seq int, line string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO log_lines (workload_id, stream, seq, line) VALUES (?, ?, ?, ?)",
+		workloadID, model.LogStreamBuild, seq, line,
+	)
+	if err != nil {
+		return fmt.Errorf("insert build log line: %w", err)
+	}
+	return nil
+}
+
+// GetLogLines retrieves all of a workload's own log lines, of every stream
+// in model.WorkloadLogStreams and those without one, ordered by sequence
+// number.
 func (s *SQLiteStore) GetLogLines(ctx context.Context, workloadID string) ([]model.LogLine, error) {
+	return s.getLogLines(ctx, workloadID, append([]string{""}, model.WorkloadLogStreams...)...)
+}
+
+// GetConsoleLines retrieves a workload's console output ordered by sequence
+// number.
+func (s *SQLiteStore) GetConsoleLines(ctx context.Context, workloadID string) ([]model.LogLine, error) {
+	return s.getLogLines(ctx, workloadID, model.LogStreamConsole)
+}
+
+// GetBuildLogLines retrieves a workload's build output ordered by sequence
+// number.
+func (s *SQLiteStore) GetBuildLogLines(ctx context.Context, workloadID string) ([]model.LogLine, error) {
+	return s.getLogLines(ctx, workloadID, model.LogStreamBuild)
+}
+
+func (s *SQLiteStore) getLogLines(ctx context.Context, workloadID string, streams ...string) ([]model.LogLine, error) {
+	args := []any{workloadID}
+	for _, stream := range streams {
+		args = append(args, stream)
+	}
+	placeholders := strings.Repeat(", ?", len(streams))[2:]
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, workload_id, seq, line, created_at FROM log_lines WHERE workload_id = ? ORDER BY seq ASC",
-		workloadID,
+		"SELECT id, workload_id, stream, seq, line, created_at FROM log_lines WHERE workload_id = ? AND stream IN ("+placeholders+") ORDER BY seq ASC",
+		args...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("query log lines: %w", err)
@@ -304,7 +675,7 @@ func (s *SQLiteStore) GetLogLines(ctx context.Context, workloadID string) ([]mod
 	var lines []model.LogLine
 	for rows.Next() {
 		var l model.LogLine
-		if err := rows.Scan(&l.ID, &l.WorkloadID, &l.Seq, &l.Line, &l.CreatedAt); err != nil {
+		if err := rows.Scan(&l.ID, &l.WorkloadID, &l.Stream, &l.Seq, &l.Line, &l.CreatedAt); err != nil {
 			return nil, fmt.Errorf("scan log line: %w", err)
 		}
 		lines = append(lines, l)
@@ -386,5 +757,57 @@ func (s *SQLiteStore) GetWorkloadStats(ctx context.Context) (*WorkloadStats, err
 		stats.AvgDurationMS = avgDuration.Float64
 	}
 
+	// Resource usage, overall and per runtime.
+	overall, err := queryUsageStats(ctx, tx, false)
+	if err != nil {
+		return nil, err
+	}
+	stats.Usage = overall[""]
+	if stats.UsageByRuntime, err = queryUsageStats(ctx, tx, true); err != nil {
+		return nil, err
+	}
+
 	return stats, nil
 }
+
+// queryUsageStats aggregates the resource usage of workloads that reported
+// it, grouped by runtime when byRuntime is set and keyed by "" otherwise.
+func queryUsageStats(ctx context.Context, tx *sql.Tx, byRuntime bool) (map[string]UsageStats, error) {
+	key, group := "''", ""
+	if byRuntime {
+		key, group = "runtime", "GROUP BY runtime"
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT `+key+`, COUNT(*),
+			AVG(cpu_user_ms), AVG(cpu_sys_ms), AVG(peak_rss_kb), MAX(peak_rss_kb),
+			SUM(io_read_bytes), SUM(io_write_bytes)
+		FROM workloads WHERE cpu_user_ms IS NOT NULL `+group)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate usage: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]UsageStats)
+	for rows.Next() {
+		var name string
+		var u UsageStats
+		var avgUser, avgSys, avgRSS sql.NullFloat64
+		var maxRSS, ioRead, ioWrite sql.NullInt64
+		if err := rows.Scan(&name, &u.Count, &avgUser, &avgSys, &avgRSS, &maxRSS, &ioRead, &ioWrite); err != nil {
+			return nil, fmt.Errorf("scan usage: %w", err)
+		}
+		if u.Count == 0 {
+			continue
+		}
+		u.AvgCPUUserMS = avgUser.Float64
+		u.AvgCPUSysMS = avgSys.Float64
+		u.AvgPeakRSSKB = avgRSS.Float64
+		u.MaxPeakRSSKB = maxRSS.Int64
+		u.TotalIOReadBytes = ioRead.Int64
+		u.TotalIOWriteBytes = ioWrite.Int64
+		result[name] = u
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate usage: %w", err)
+	}
+	return result, nil
+}
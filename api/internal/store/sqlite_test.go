@@ -2,8 +2,10 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -41,6 +43,8 @@ func TestCreateAndGetWorkload(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
 	w := makeTestWorkload()
+	grace := 10
+	w.GraceS = &grace
 
 	if err := s.CreateWorkload(ctx, w); err != nil {
 		t.Fatalf("CreateWorkload: %v", err)
@@ -69,6 +73,9 @@ func TestCreateAndGetWorkload(t *testing.T) {
 	if *got.CPULimit != *w.CPULimit {
 		t.Errorf("CPULimit = %d, want %d", *got.CPULimit, *w.CPULimit)
 	}
+	if got.GraceS == nil || *got.GraceS != grace {
+		t.Errorf("GraceS = %v, want %d", got.GraceS, grace)
+	}
 }
 
 func TestGetWorkloadNotFound(t *testing.T) {
@@ -95,7 +102,7 @@ func TestListWorkloadsPagination(t *testing.T) {
 	}
 
 	// Get first page of 2.
-	workloads, total, err := s.ListWorkloads(ctx, 2, 0)
+	workloads, total, err := s.ListWorkloads(ctx, WorkloadFilter{}, 2, 0)
 	if err != nil {
 		t.Fatalf("ListWorkloads: %v", err)
 	}
@@ -107,7 +114,7 @@ func TestListWorkloadsPagination(t *testing.T) {
 	}
 
 	// Get second page of 2.
-	workloads2, total2, err := s.ListWorkloads(ctx, 2, 2)
+	workloads2, total2, err := s.ListWorkloads(ctx, WorkloadFilter{}, 2, 2)
 	if err != nil {
 		t.Fatalf("ListWorkloads page 2: %v", err)
 	}
@@ -132,7 +139,7 @@ func TestListWorkloadsOrdering(t *testing.T) {
 		}
 	}
 
-	workloads, _, err := s.ListWorkloads(ctx, 10, 0)
+	workloads, _, err := s.ListWorkloads(ctx, WorkloadFilter{}, 10, 0)
 	if err != nil {
 		t.Fatalf("ListWorkloads: %v", err)
 	}
@@ -146,11 +153,56 @@ func TestListWorkloadsOrdering(t *testing.T) {
 	}
 }
 
+func TestListWorkloadsByExitReason(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	reasons := []string{model.ExitReasonExited, model.ExitReasonOOMKilled, model.ExitReasonExited, ""}
+	for i, reason := range reasons {
+		w := makeTestWorkload()
+		if err := s.CreateWorkload(ctx, w); err != nil {
+			t.Fatalf("CreateWorkload[%d]: %v", i, err)
+		}
+		if reason == "" {
+			continue
+		}
+		w.Status = model.StatusFailed
+		w.ExitReason = reason
+		if err := s.UpdateWorkload(ctx, w); err != nil {
+			t.Fatalf("UpdateWorkload[%d]: %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		reason string
+		want   int
+	}{
+		{model.ExitReasonExited, 2},
+		{model.ExitReasonOOMKilled, 1},
+		{model.ExitReasonTimeout, 0},
+		{"", len(reasons)},
+	}
+	for _, tt := range tests {
+		workloads, total, err := s.ListWorkloads(ctx, WorkloadFilter{ExitReason: tt.reason}, 10, 0)
+		if err != nil {
+			t.Fatalf("ListWorkloads(%q): %v", tt.reason, err)
+		}
+		if total != tt.want || len(workloads) != tt.want {
+			t.Errorf("ListWorkloads(%q) = %d workloads of %d, want %d", tt.reason, len(workloads), total, tt.want)
+		}
+		for _, w := range workloads {
+			if tt.reason != "" && w.ExitReason != tt.reason {
+				t.Errorf("ListWorkloads(%q) returned workload with exit reason %q", tt.reason, w.ExitReason)
+			}
+		}
+	}
+}
+
 func TestListWorkloadsEmpty(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
 
-	workloads, total, err := s.ListWorkloads(ctx, 10, 0)
+	workloads, total, err := s.ListWorkloads(ctx, WorkloadFilter{}, 10, 0)
 	if err != nil {
 		t.Fatalf("ListWorkloads: %v", err)
 	}
@@ -319,8 +371,12 @@ func TestUpdateWorkload(t *testing.T) {
 
 	w.Status = model.StatusCompleted
 	w.Output = []byte("hello world")
+	w.Stdout = []byte("hello ")
+	w.Stderr = []byte("world")
 	w.ExitCode = &exitCode
 	w.Error = ""
+	w.ExitReason = model.ExitReasonSignaled
+	w.ExitSignal = "SIGTERM"
 	w.DurationMS = &durationMS
 	finishedAt := now.Add(time.Duration(durationMS) * time.Millisecond)
 	w.FinishedAt = &finishedAt
@@ -339,9 +395,15 @@ func TestUpdateWorkload(t *testing.T) {
 	if string(got.Output) != "hello world" {
 		t.Errorf("Output = %q, want %q", string(got.Output), "hello world")
 	}
+	if string(got.Stdout) != "hello " || string(got.Stderr) != "world" {
+		t.Errorf("Stdout, Stderr = %q, %q; want %q, %q", got.Stdout, got.Stderr, "hello ", "world")
+	}
 	if *got.ExitCode != 0 {
 		t.Errorf("ExitCode = %d, want 0", *got.ExitCode)
 	}
+	if got.ExitReason != model.ExitReasonSignaled || got.ExitSignal != "SIGTERM" {
+		t.Errorf("ExitReason, ExitSignal = %q, %q; want %q, %q", got.ExitReason, got.ExitSignal, model.ExitReasonSignaled, "SIGTERM")
+	}
 	if *got.DurationMS != 150 {
 		t.Errorf("DurationMS = %d, want 150", *got.DurationMS)
 	}
@@ -469,7 +531,7 @@ func TestInsertAndGetLogLines(t *testing.T) {
 
 	// Insert three log lines.
 	for i := 0; i < 3; i++ {
-		if err := s.InsertLogLine(ctx, w.ID, i, fmt.Sprintf("line %d", i)); err != nil {
+		if err := s.InsertLogLine(ctx, w.ID, i, "", fmt.Sprintf("line %d", i)); err != nil {
 			t.Fatalf("InsertLogLine[%d]: %v", i, err)
 		}
 	}
@@ -499,6 +561,109 @@ func TestInsertAndGetLogLines(t *testing.T) {
 	}
 }
 
+func TestConsoleLines(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	w := makeTestWorkload()
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	if err := s.InsertLogLine(ctx, w.ID, 0, "", "workload output"); err != nil {
+		t.Fatalf("InsertLogLine: %v", err)
+	}
+	if err := s.InsertConsoleLines(ctx, w.ID, []string{"stale"}); err != nil {
+		t.Fatalf("InsertConsoleLines: %v", err)
+	}
+	if err := s.InsertConsoleLines(ctx, w.ID, []string{"Linux version 5.10", "Kernel panic"}); err != nil {
+		t.Fatalf("InsertConsoleLines: %v", err)
+	}
+
+	console, err := s.GetConsoleLines(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetConsoleLines: %v", err)
+	}
+	if len(console) != 2 || console[0].Line != "Linux version 5.10" || console[1].Line != "Kernel panic" {
+		t.Fatalf("console = %+v, want the last two lines inserted", console)
+	}
+	for i, l := range console {
+		if l.Seq != i || l.Stream != model.LogStreamConsole {
+			t.Errorf("console[%d] seq, stream = %d, %q; want %d, %q", i, l.Seq, l.Stream, i, model.LogStreamConsole)
+		}
+	}
+
+	lines, err := s.GetLogLines(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetLogLines: %v", err)
+	}
+	if len(lines) != 1 || lines[0].Line != "workload output" || lines[0].Stream != "" {
+		t.Errorf("log lines = %+v, want only the workload's own line", lines)
+	}
+}
+
+func TestLogLineStreams(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	w := makeTestWorkload()
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	streams := []string{model.LogStreamStdout, model.LogStreamStderr, model.LogStreamSystem, ""}
+	for seq, stream := range streams {
+		if err := s.InsertLogLine(ctx, w.ID, seq, stream, fmt.Sprintf("line %d", seq)); err != nil {
+			t.Fatalf("InsertLogLine[%d]: %v", seq, err)
+		}
+	}
+	if err := s.InsertBuildLogLine(ctx, w.ID, 0, "compiling"); err != nil {
+		t.Fatalf("InsertBuildLogLine: %v", err)
+	}
+
+	lines, err := s.GetLogLines(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetLogLines: %v", err)
+	}
+	if len(lines) != len(streams) {
+		t.Fatalf("got %d lines, want the workload's %d", len(lines), len(streams))
+	}
+	for i, l := range lines {
+		if l.Seq != i || l.Stream != streams[i] {
+			t.Errorf("lines[%d] seq, stream = %d, %q; want %d, %q", i, l.Seq, l.Stream, i, streams[i])
+		}
+	}
+}
+
+func TestBuildLogLines(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	w := makeTestWorkload()
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	if err := s.InsertLogLine(ctx, w.ID, 0, "", "workload output"); err != nil {
+		t.Fatalf("InsertLogLine: %v", err)
+	}
+	for i, line := range []string{"# example", "./main.go:4:2: undefined: x"} {
+		if err := s.InsertBuildLogLine(ctx, w.ID, i, line); err != nil {
+			t.Fatalf("InsertBuildLogLine: %v", err)
+		}
+	}
+
+	build, err := s.GetBuildLogLines(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetBuildLogLines: %v", err)
+	}
+	if len(build) != 2 || build[1].Line != "./main.go:4:2: undefined: x" || build[1].Stream != model.LogStreamBuild {
+		t.Errorf("build lines = %+v, want both build lines", build)
+	}
+
+	lines, err := s.GetLogLines(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetLogLines: %v", err)
+	}
+	if len(lines) != 1 || lines[0].Line != "workload output" {
+		t.Errorf("log lines = %+v, want only the workload's own line", lines)
+	}
+}
+
 func TestGetLogLinesOrdering(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -509,7 +674,7 @@ func TestGetLogLinesOrdering(t *testing.T) {
 
 	// Insert lines out of order.
 	for _, seq := range []int{2, 0, 1} {
-		if err := s.InsertLogLine(ctx, w.ID, seq, fmt.Sprintf("line %d", seq)); err != nil {
+		if err := s.InsertLogLine(ctx, w.ID, seq, "", fmt.Sprintf("line %d", seq)); err != nil {
 			t.Fatalf("InsertLogLine[%d]: %v", seq, err)
 		}
 	}
@@ -562,10 +727,10 @@ func TestGetLogLinesIsolation(t *testing.T) {
 	}
 
 	// Insert lines for both workloads.
-	if err := s.InsertLogLine(ctx, w1.ID, 0, "w1 line"); err != nil {
+	if err := s.InsertLogLine(ctx, w1.ID, 0, "", "w1 line"); err != nil {
 		t.Fatalf("InsertLogLine w1: %v", err)
 	}
-	if err := s.InsertLogLine(ctx, w2.ID, 0, "w2 line"); err != nil {
+	if err := s.InsertLogLine(ctx, w2.ID, 0, "", "w2 line"); err != nil {
 		t.Fatalf("InsertLogLine w2: %v", err)
 	}
 
@@ -606,3 +771,271 @@ func TestMigrationIdempotency(t *testing.T) {
 	}
 	s1.Close()
 }
+
+func TestUpdateWorkloadUsage(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	w := makeTestWorkload()
+	diskMB := 256
+	w.DiskLimit = &diskMB
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+
+	got, err := s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Usage != nil {
+		t.Errorf("Usage = %+v before execution, want nil", got.Usage)
+	}
+	if got.DiskLimit == nil || *got.DiskLimit != diskMB {
+		t.Errorf("DiskLimit = %v, want %d", got.DiskLimit, diskMB)
+	}
+
+	usage := model.ResourceUsage{CPUUserMS: 120, CPUSysMS: 30, PeakRSSKB: 20480, IOReadBytes: 4096, IOWriteBytes: 512, PeakMemoryKB: 32768, DiskUsedBytes: 1 << 20}
+	if err := s.UpdateWorkloadStatus(ctx, w.ID, model.StatusRunning); err != nil {
+		t.Fatalf("UpdateWorkloadStatus: %v", err)
+	}
+	w.Status = model.StatusCompleted
+	w.Usage = &usage
+	if err := s.UpdateWorkload(ctx, w); err != nil {
+		t.Fatalf("UpdateWorkload: %v", err)
+	}
+
+	got, err = s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Usage == nil || *got.Usage != usage {
+		t.Errorf("Usage = %+v, want %+v", got.Usage, usage)
+	}
+
+	list, _, err := s.ListWorkloads(ctx, WorkloadFilter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("ListWorkloads: %v", err)
+	}
+	if len(list) != 1 || list[0].Usage == nil || *list[0].Usage != usage {
+		t.Errorf("listed usage = %+v, want %+v", list[0].Usage, usage)
+	}
+}
+
+func TestGetWorkloadStatsUsage(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	usages := []struct {
+		runtime string
+		usage   *model.ResourceUsage
+	}{
+		{model.RuntimeNode, &model.ResourceUsage{CPUUserMS: 100, CPUSysMS: 10, PeakRSSKB: 1000, IOReadBytes: 10, IOWriteBytes: 1}},
+		{model.RuntimeNode, &model.ResourceUsage{CPUUserMS: 300, CPUSysMS: 30, PeakRSSKB: 3000, IOReadBytes: 20, IOWriteBytes: 2}},
+		{model.RuntimePython, &model.ResourceUsage{CPUUserMS: 50, CPUSysMS: 5, PeakRSSKB: 8000, IOReadBytes: 30, IOWriteBytes: 3}},
+		{model.RuntimePython, nil}, // no usage reported
+	}
+	for _, u := range usages {
+		w := makeTestWorkload()
+		w.Runtime = u.runtime
+		if err := s.CreateWorkload(ctx, w); err != nil {
+			t.Fatalf("CreateWorkload: %v", err)
+		}
+		if err := s.UpdateWorkloadStatus(ctx, w.ID, model.StatusRunning); err != nil {
+			t.Fatalf("UpdateWorkloadStatus: %v", err)
+		}
+		w.Status = model.StatusCompleted
+		w.Usage = u.usage
+		if err := s.UpdateWorkload(ctx, w); err != nil {
+			t.Fatalf("UpdateWorkload: %v", err)
+		}
+	}
+
+	stats, err := s.GetWorkloadStats(ctx)
+	if err != nil {
+		t.Fatalf("GetWorkloadStats: %v", err)
+	}
+
+	want := UsageStats{
+		Count: 3, AvgCPUUserMS: 150, AvgCPUSysMS: 15, AvgPeakRSSKB: 4000,
+		MaxPeakRSSKB: 8000, TotalIOReadBytes: 60, TotalIOWriteBytes: 6,
+	}
+	if stats.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", stats.Usage, want)
+	}
+
+	node := stats.UsageByRuntime[model.RuntimeNode]
+	if node.Count != 2 || node.AvgCPUUserMS != 200 || node.MaxPeakRSSKB != 3000 {
+		t.Errorf("node usage = %+v, want count 2, avg user 200, max rss 3000", node)
+	}
+	if py := stats.UsageByRuntime[model.RuntimePython]; py.Count != 1 {
+		t.Errorf("python usage count = %d, want 1", py.Count)
+	}
+}
+
+func TestMigrationAddsUsageColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+
+	// Create a database with the workloads table as shipped before usage columns.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE workloads (
+		id TEXT PRIMARY KEY, status TEXT NOT NULL, isolation TEXT NOT NULL,
+		runtime TEXT NOT NULL, node_id TEXT NOT NULL, input_hash TEXT, output BLOB,
+		exit_code INTEGER, error TEXT, cpu_limit INTEGER, mem_limit INTEGER,
+		timeout_s INTEGER, duration_ms INTEGER, created_at DATETIME NOT NULL,
+		started_at DATETIME, finished_at DATETIME)`); err != nil {
+		t.Fatalf("create old table: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO workloads (id, status, isolation, runtime, node_id, input_hash, error, created_at)
+		VALUES ('old', 'completed', 'microvm', 'python', 'n', '', '', ?)`, time.Now().UTC()); err != nil {
+		t.Fatalf("insert old row: %v", err)
+	}
+	db.Close()
+
+	s, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	got, err := s.GetWorkload(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Usage != nil {
+		t.Errorf("Usage = %+v for pre-migration row, want nil", got.Usage)
+	}
+}
+
+func TestCreateWorkloadNetworkPolicy(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	w := makeTestWorkload()
+	w.Network = &model.NetworkPolicy{
+		Mode:  model.NetworkEgress,
+		Allow: []model.EgressRule{{CIDR: "203.0.113.0/24", Protocol: model.ProtocolTCP, Ports: []int{80, 443}}},
+	}
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	plain := makeTestWorkload()
+	if err := s.CreateWorkload(ctx, plain); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+
+	got, err := s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Network == nil || got.Network.Mode != model.NetworkEgress {
+		t.Fatalf("Network = %+v, want egress policy", got.Network)
+	}
+	if len(got.Network.Allow) != 1 || len(got.Network.Allow[0].Ports) != 2 {
+		t.Errorf("Allow = %+v, want one rule with two ports", got.Network.Allow)
+	}
+
+	got, err = s.GetWorkload(ctx, plain.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Network != nil {
+		t.Errorf("Network = %+v, want nil for workload without a policy", got.Network)
+	}
+}
+
+func TestCreateWorkloadRateLimits(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	w := makeTestWorkload()
+	w.RateLimits = &model.RateLimits{DiskIOPS: 500, NetBytesPerS: 1 << 20}
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+
+	got, err := s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.RateLimits == nil || *got.RateLimits != *w.RateLimits {
+		t.Errorf("RateLimits = %+v, want %+v", got.RateLimits, w.RateLimits)
+	}
+
+	plain := makeTestWorkload()
+	if err := s.CreateWorkload(ctx, plain); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	if got, err = s.GetWorkload(ctx, plain.ID); err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.RateLimits != nil {
+		t.Errorf("RateLimits = %+v, want nil for an unlimited workload", got.RateLimits)
+	}
+}
+
+func TestCreateWorkloadGroup(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	w := makeTestWorkload()
+	w.Group = "shop"
+	w.Name = "db"
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+
+	got, err := s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.Group != "shop" || got.Name != "db" {
+		t.Errorf("Group, Name = %q, %q; want shop, db", got.Group, got.Name)
+	}
+}
+
+func TestWorkloadEndpoint(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	port := 8080
+	w := makeTestWorkload()
+	w.ExposePort = &port
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatalf("CreateWorkload: %v", err)
+	}
+	if err := s.UpdateWorkloadStatus(ctx, w.ID, model.StatusRunning); err != nil {
+		t.Fatalf("UpdateWorkloadStatus: %v", err)
+	}
+	if err := s.SetWorkloadEndpoint(ctx, w.ID, "http://127.0.0.1:40000"); err != nil {
+		t.Fatalf("SetWorkloadEndpoint: %v", err)
+	}
+
+	got, err := s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.ExposePort == nil || *got.ExposePort != port {
+		t.Errorf("ExposePort = %v, want %d", got.ExposePort, port)
+	}
+	if got.EndpointURL != "http://127.0.0.1:40000" {
+		t.Errorf("EndpointURL = %q, want the recorded endpoint", got.EndpointURL)
+	}
+
+	// The endpoint is only valid while the workload runs.
+	if err := s.UpdateWorkloadStatus(ctx, w.ID, model.StatusCompleted); err != nil {
+		t.Fatalf("UpdateWorkloadStatus: %v", err)
+	}
+	got, err = s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkload: %v", err)
+	}
+	if got.EndpointURL != "" {
+		t.Errorf("EndpointURL = %q after completion, want cleared", got.EndpointURL)
+	}
+
+	if err := s.SetWorkloadEndpoint(ctx, "nonexistent", "http://x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetWorkloadEndpoint(nonexistent) = %v, want ErrNotFound", err)
+	}
+}
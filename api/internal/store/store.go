@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/seantiz/vulcan/internal/model"
 )
@@ -16,17 +17,70 @@ type WorkloadStats struct {
 	CountByStatus    map[string]int `json:"count_by_status"`
 	CountByIsolation map[string]int `json:"count_by_isolation"`
 	AvgDurationMS    float64        `json:"avg_duration_ms"`
+
+	// Usage aggregates the resource usage of all workloads that reported it;
+	// UsageByRuntime breaks it down per runtime.
+	Usage          UsageStats            `json:"usage"`
+	UsageByRuntime map[string]UsageStats `json:"usage_by_runtime"`
+}
+
+// UsageStats aggregates reported workload resource usage.
+type UsageStats struct {
+	Count             int     `json:"count"`
+	AvgCPUUserMS      float64 `json:"avg_cpu_user_ms"`
+	AvgCPUSysMS       float64 `json:"avg_cpu_sys_ms"`
+	AvgPeakRSSKB      float64 `json:"avg_peak_rss_kb"`
+	MaxPeakRSSKB      int64   `json:"max_peak_rss_kb"`
+	TotalIOReadBytes  int64   `json:"total_io_read_bytes"`
+	TotalIOWriteBytes int64   `json:"total_io_write_bytes"`
+}
+
+// WorkloadFilter selects the workloads returned by ListWorkloads. Zero
+// fields match every workload.
+type WorkloadFilter struct {
+	// ExitReason matches workloads that ended for the given reason, one of
+	// model.ExitReasons.
+	ExitReason string
 }
 
-// Store defines the persistence operations for workloads.
+// Store defines the persistence operations for workloads, catalog images,
+// volumes and sessions.
 type Store interface {
 	CreateWorkload(ctx context.Context, w *model.Workload) error
 	GetWorkload(ctx context.Context, id string) (*model.Workload, error)
-	ListWorkloads(ctx context.Context, limit, offset int) ([]*model.Workload, int, error)
+	ListWorkloads(ctx context.Context, filter WorkloadFilter, limit, offset int) ([]*model.Workload, int, error)
 	UpdateWorkloadStatus(ctx context.Context, id, status string) error
 	UpdateWorkload(ctx context.Context, w *model.Workload) error
+	SetWorkloadEndpoint(ctx context.Context, id, url string) error
+	UpdateWorkloadService(ctx context.Context, id string, svc *model.ServiceSpec) error
+	RecordHealth(ctx context.Context, id, status, detail string, restarts int) error
+	ListHealthEvents(ctx context.Context, id string) ([]model.HealthEvent, error)
 	GetWorkloadStats(ctx context.Context) (*WorkloadStats, error)
-	InsertLogLine(ctx context.Context, workloadID string, seq int, line string) error
+	InsertLogLine(ctx context.Context, workloadID string, seq int, stream, line string) error
 	GetLogLines(ctx context.Context, workloadID string) ([]model.LogLine, error)
+	InsertConsoleLines(ctx context.Context, workloadID string, lines []string) error
+	GetConsoleLines(ctx context.Context, workloadID string) ([]model.LogLine, error)
+	InsertBuildLogLine(ctx context.Context, workloadID string, seq int, line string) error
+	GetBuildLogLines(ctx context.Context, workloadID string) ([]model.LogLine, error)
+	CreateImage(ctx context.Context, img *model.Image) error
+	GetImage(ctx context.Context, name, version string) (*model.Image, error)
+	ListImages(ctx context.Context, name string) ([]*model.Image, error)
+	DeleteImage(ctx context.Context, name, version string) error
+	MarkImageUsed(ctx context.Context, name, version string, at time.Time) error
+	CreateVolume(ctx context.Context, v *model.Volume) error
+	GetVolume(ctx context.Context, name string) (*model.Volume, error)
+	ListVolumes(ctx context.Context) ([]*model.Volume, error)
+	DeleteVolume(ctx context.Context, name string) error
+	AttachVolumes(ctx context.Context, holder string, mounts []model.VolumeMount) ([]*model.Volume, error)
+	DetachVolumes(ctx context.Context, holder string) error
+	DetachAllVolumes(ctx context.Context) error
+	CreateSession(ctx context.Context, sess *model.Session) error
+	GetSession(ctx context.Context, id string) (*model.Session, error)
+	ListSessions(ctx context.Context) ([]*model.Session, error)
+	MarkSessionReady(ctx context.Context, id string) error
+	FinishSession(ctx context.Context, id, status, reason, errMsg string) error
+	CloseUnfinishedSessions(ctx context.Context, reason string) error
+	RecordSessionExec(ctx context.Context, id string, at time.Time) error
+	ListSessionExecs(ctx context.Context, id string) ([]*model.Workload, error)
 	Close() error
 }
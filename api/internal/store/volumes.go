@@ -0,0 +1,263 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+const createVolumesTable = `
+CREATE TABLE IF NOT EXISTS volumes (
+    name       TEXT PRIMARY KEY,
+    size_mb    INTEGER NOT NULL,
+    snapshot   INTEGER NOT NULL DEFAULT 0,
+    source     TEXT NOT NULL DEFAULT '',
+    path       TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+)`
+
+// Each row of volume_attachments is a lock on a volume: a read-write row
+// excludes every other row for the same volume.
+const createVolumeAttachmentsTable = `
+CREATE TABLE IF NOT EXISTS volume_attachments (
+    volume_name TEXT NOT NULL REFERENCES volumes(name),
+    holder      TEXT NOT NULL,
+    read_only   INTEGER NOT NULL,
+    attached_at DATETIME NOT NULL,
+    PRIMARY KEY (volume_name, holder)
+)`
+
+// Errors returned by volume operations.
+var (
+	// ErrVolumeNotFound is returned when a volume is not found.
+	ErrVolumeNotFound = errors.New("volume not found")
+
+	// ErrVolumeExists is returned when creating a volume whose name is taken.
+	ErrVolumeExists = errors.New("volume already exists")
+
+	// ErrVolumeLocked is returned when attaching a volume would give it a
+	// second holder alongside a writer.
+	ErrVolumeLocked = errors.New("volume is locked by another holder")
+
+	// ErrVolumeInUse is returned when deleting a volume that is attached.
+	ErrVolumeInUse = errors.New("volume is in use")
+
+	// ErrVolumeSnapshot is returned when attaching a snapshot read-write.
+	ErrVolumeSnapshot = errors.New("snapshots can only be attached read-only")
+)
+
+// volumeColumns lists the columns read by scanVolume, in order.
+const volumeColumns = `name, size_mb, snapshot, source, path, created_at`
+
+// scanVolume reads a volume, without its attachments, from a row selected
+// with volumeColumns.
+func scanVolume(row rowScanner) (*model.Volume, error) {
+	v := &model.Volume{Attachments: []model.VolumeAttachment{}}
+	if err := row.Scan(&v.Name, &v.SizeMB, &v.Snapshot, &v.Source, &v.Path, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// CreateVolume adds a volume. Returns ErrVolumeExists if the name is taken.
+func (s *SQLiteStore) CreateVolume(ctx context.Context, v *model.Volume) error {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO volumes (name, size_mb, snapshot, source, path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO NOTHING`,
+		v.Name, v.SizeMB, v.Snapshot, v.Source, v.Path, v.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert volume: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrVolumeExists
+	}
+	return nil
+}
+
+// GetVolume retrieves a volume and its attachments. Returns
+// ErrVolumeNotFound if there is none.
+func (s *SQLiteStore) GetVolume(ctx context.Context, name string) (*model.Volume, error) {
+	v, err := scanVolume(s.db.QueryRowContext(ctx, "SELECT "+volumeColumns+" FROM volumes WHERE name = ?", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVolumeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get volume: %w", err)
+	}
+	if err := s.loadAttachments(ctx, map[string]*model.Volume{v.Name: v}, "WHERE volume_name = ?", name); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ListVolumes returns all volumes and their attachments, sorted by name.
+func (s *SQLiteStore) ListVolumes(ctx context.Context) ([]*model.Volume, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+volumeColumns+" FROM volumes ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("list volumes: %w", err)
+	}
+	defer rows.Close()
+
+	volumes := []*model.Volume{}
+	byName := make(map[string]*model.Volume)
+	for rows.Next() {
+		v, err := scanVolume(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan volume: %w", err)
+		}
+		volumes = append(volumes, v)
+		byName[v.Name] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate volumes: %w", err)
+	}
+	rows.Close()
+
+	if err := s.loadAttachments(ctx, byName, ""); err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}
+
+// loadAttachments appends the attachments selected by where to the volumes
+// in byName.
+func (s *SQLiteStore) loadAttachments(ctx context.Context, byName map[string]*model.Volume, where string, args ...any) error {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT volume_name, holder, read_only, attached_at FROM volume_attachments "+where+
+			" ORDER BY attached_at, holder", args...)
+	if err != nil {
+		return fmt.Errorf("list volume attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var a model.VolumeAttachment
+		if err := rows.Scan(&name, &a.Holder, &a.ReadOnly, &a.AttachedAt); err != nil {
+			return fmt.Errorf("scan volume attachment: %w", err)
+		}
+		if v, ok := byName[name]; ok {
+			v.Attachments = append(v.Attachments, a)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate volume attachments: %w", err)
+	}
+	return nil
+}
+
+// DeleteVolume removes a volume. Returns ErrVolumeNotFound if there is none
+// and ErrVolumeInUse if it is attached.
+func (s *SQLiteStore) DeleteVolume(ctx context.Context, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var holders int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM volume_attachments WHERE volume_name = ?", name,
+	).Scan(&holders); err != nil {
+		return fmt.Errorf("count volume attachments: %w", err)
+	}
+	if holders > 0 {
+		return ErrVolumeInUse
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM volumes WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("delete volume: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrVolumeNotFound
+	}
+	return tx.Commit()
+}
+
+// AttachVolumes locks the volumes of mounts for holder and returns them, in
+// the order of mounts. Either every volume is attached or none is: returns
+// ErrVolumeNotFound if a volume does not exist, ErrVolumeSnapshot if a
+// snapshot is requested read-write, and ErrVolumeLocked if a volume
+// requested read-write has any other holder or one requested read-only has
+// a writer. Errors name the offending volume.
+func (s *SQLiteStore) AttachVolumes(ctx context.Context, holder string, mounts []model.VolumeMount) ([]*model.Volume, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	volumes := make([]*model.Volume, 0, len(mounts))
+	for _, m := range mounts {
+		v, err := scanVolume(tx.QueryRowContext(ctx, "SELECT "+volumeColumns+" FROM volumes WHERE name = ?", m.Name))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrVolumeNotFound, m.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get volume: %w", err)
+		}
+		if v.Snapshot && !m.ReadOnly {
+			return nil, fmt.Errorf("%w: %s", ErrVolumeSnapshot, m.Name)
+		}
+
+		var holders, writers int
+		if err := tx.QueryRowContext(ctx,
+			`SELECT COUNT(*), COALESCE(SUM(read_only = 0), 0) FROM volume_attachments
+			WHERE volume_name = ? AND holder != ?`,
+			m.Name, holder,
+		).Scan(&holders, &writers); err != nil {
+			return nil, fmt.Errorf("check volume attachments: %w", err)
+		}
+		if writers > 0 || (!m.ReadOnly && holders > 0) {
+			return nil, fmt.Errorf("%w: %s", ErrVolumeLocked, m.Name)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO volume_attachments (volume_name, holder, read_only, attached_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (volume_name, holder) DO UPDATE SET read_only = excluded.read_only`,
+			m.Name, holder, m.ReadOnly, now,
+		); err != nil {
+			return nil, fmt.Errorf("insert volume attachment: %w", err)
+		}
+		volumes = append(volumes, v)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit volume attachments: %w", err)
+	}
+	return volumes, nil
+}
+
+// DetachVolumes releases every volume attached for holder.
+func (s *SQLiteStore) DetachVolumes(ctx context.Context, holder string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM volume_attachments WHERE holder = ?", holder); err != nil {
+		return fmt.Errorf("detach volumes: %w", err)
+	}
+	return nil
+}
+
+// DetachAllVolumes releases every volume attachment. Attachments do not
+// survive a restart, as no sandbox outlives the process that started it.
+func (s *SQLiteStore) DetachAllVolumes(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM volume_attachments"); err != nil {
+		return fmt.Errorf("detach all volumes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+func createTestVolume(t *testing.T, s *SQLiteStore, name string, snapshot bool) {
+	t.Helper()
+	v := &model.Volume{
+		Name:      name,
+		SizeMB:    64,
+		Snapshot:  snapshot,
+		Path:      "/var/lib/vulcan/volumes/" + name + ".ext4",
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := s.CreateVolume(context.Background(), v); err != nil {
+		t.Fatalf("CreateVolume(%s): %v", name, err)
+	}
+}
+
+func TestCreateGetListDeleteVolume(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	createTestVolume(t, s, "data", false)
+	createTestVolume(t, s, "cache", false)
+
+	v := &model.Volume{Name: "data", SizeMB: 1, Path: "/elsewhere", CreatedAt: time.Now()}
+	if err := s.CreateVolume(ctx, v); !errors.Is(err, ErrVolumeExists) {
+		t.Errorf("duplicate CreateVolume = %v, want ErrVolumeExists", err)
+	}
+
+	got, err := s.GetVolume(ctx, "data")
+	if err != nil {
+		t.Fatalf("GetVolume: %v", err)
+	}
+	if got.SizeMB != 64 || got.Path != "/var/lib/vulcan/volumes/data.ext4" || got.Snapshot || got.Attachments == nil {
+		t.Errorf("GetVolume = %+v", got)
+	}
+
+	list, err := s.ListVolumes(ctx)
+	if err != nil {
+		t.Fatalf("ListVolumes: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "cache" || list[1].Name != "data" {
+		t.Errorf("ListVolumes = %+v, want cache and data", list)
+	}
+
+	if err := s.DeleteVolume(ctx, "data"); err != nil {
+		t.Fatalf("DeleteVolume: %v", err)
+	}
+	if _, err := s.GetVolume(ctx, "data"); !errors.Is(err, ErrVolumeNotFound) {
+		t.Errorf("GetVolume after delete = %v, want ErrVolumeNotFound", err)
+	}
+	if err := s.DeleteVolume(ctx, "data"); !errors.Is(err, ErrVolumeNotFound) {
+		t.Errorf("second DeleteVolume = %v, want ErrVolumeNotFound", err)
+	}
+}
+
+func TestAttachVolumesLocking(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	createTestVolume(t, s, "data", false)
+
+	rw := []model.VolumeMount{{Name: "data", MountPath: "/data"}}
+	ro := []model.VolumeMount{{Name: "data", MountPath: "/data", ReadOnly: true}}
+
+	if _, err := s.AttachVolumes(ctx, "writer", rw); err != nil {
+		t.Fatalf("attach read-write: %v", err)
+	}
+	if _, err := s.AttachVolumes(ctx, "other-writer", rw); !errors.Is(err, ErrVolumeLocked) {
+		t.Errorf("second writer = %v, want ErrVolumeLocked", err)
+	}
+	if _, err := s.AttachVolumes(ctx, "reader", ro); !errors.Is(err, ErrVolumeLocked) {
+		t.Errorf("reader alongside a writer = %v, want ErrVolumeLocked", err)
+	}
+	if err := s.DeleteVolume(ctx, "data"); !errors.Is(err, ErrVolumeInUse) {
+		t.Errorf("DeleteVolume while attached = %v, want ErrVolumeInUse", err)
+	}
+
+	if err := s.DetachVolumes(ctx, "writer"); err != nil {
+		t.Fatalf("DetachVolumes: %v", err)
+	}
+	for _, holder := range []string{"reader-1", "reader-2"} {
+		if _, err := s.AttachVolumes(ctx, holder, ro); err != nil {
+			t.Fatalf("attach read-only for %s: %v", holder, err)
+		}
+	}
+	if _, err := s.AttachVolumes(ctx, "writer", rw); !errors.Is(err, ErrVolumeLocked) {
+		t.Errorf("writer alongside readers = %v, want ErrVolumeLocked", err)
+	}
+
+	v, err := s.GetVolume(ctx, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Attachments) != 2 || !v.Attachments[0].ReadOnly {
+		t.Errorf("attachments = %+v, want two readers", v.Attachments)
+	}
+
+	if err := s.DetachAllVolumes(ctx); err != nil {
+		t.Fatalf("DetachAllVolumes: %v", err)
+	}
+	if _, err := s.AttachVolumes(ctx, "writer", rw); err != nil {
+		t.Errorf("attach after DetachAllVolumes: %v", err)
+	}
+}
+
+func TestAttachVolumesAllOrNothing(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	createTestVolume(t, s, "a", false)
+	createTestVolume(t, s, "b", false)
+	createTestVolume(t, s, "snap", true)
+
+	if _, err := s.AttachVolumes(ctx, "holder", []model.VolumeMount{{Name: "b", MountPath: "/b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.AttachVolumes(ctx, "w", []model.VolumeMount{
+		{Name: "a", MountPath: "/a"},
+		{Name: "b", MountPath: "/b"},
+	})
+	if !errors.Is(err, ErrVolumeLocked) {
+		t.Fatalf("AttachVolumes = %v, want ErrVolumeLocked", err)
+	}
+	if v, _ := s.GetVolume(ctx, "a"); len(v.Attachments) != 0 {
+		t.Errorf("volume a attached after a failed attach: %+v", v.Attachments)
+	}
+
+	if _, err := s.AttachVolumes(ctx, "w", []model.VolumeMount{{Name: "missing", MountPath: "/m"}}); !errors.Is(err, ErrVolumeNotFound) {
+		t.Errorf("missing volume = %v, want ErrVolumeNotFound", err)
+	}
+	if _, err := s.AttachVolumes(ctx, "w", []model.VolumeMount{{Name: "snap", MountPath: "/s"}}); !errors.Is(err, ErrVolumeSnapshot) {
+		t.Errorf("read-write snapshot = %v, want ErrVolumeSnapshot", err)
+	}
+
+	got, err := s.AttachVolumes(ctx, "w", []model.VolumeMount{
+		{Name: "snap", MountPath: "/s", ReadOnly: true},
+		{Name: "a", MountPath: "/a"},
+	})
+	if err != nil {
+		t.Fatalf("AttachVolumes: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "snap" || got[1].Name != "a" {
+		t.Errorf("AttachVolumes returned %+v, want snap then a", got)
+	}
+}
+
+func TestCreateWorkloadPersistsVolumes(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	w := &model.Workload{
+		ID:        model.NewID(),
+		Status:    model.StatusPending,
+		Isolation: model.IsolationMicroVM,
+		Runtime:   model.RuntimePython,
+		CreatedAt: time.Now().UTC(),
+		Volumes:   []model.VolumeMount{{Name: "data", MountPath: "/data", ReadOnly: true}},
+	}
+	if err := s.CreateWorkload(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetWorkload(ctx, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Volumes) != 1 || got.Volumes[0] != w.Volumes[0] {
+		t.Errorf("Volumes = %+v, want %+v", got.Volumes, w.Volumes)
+	}
+}
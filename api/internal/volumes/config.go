@@ -0,0 +1,52 @@
+package volumes
+
+import (
+	"os"
+	"strconv"
+)
+
+// Environment variable names for volume configuration.
+const (
+	envDir       = "VULCAN_VOLUME_DIR"
+	envMaxSizeMB = "VULCAN_VOLUME_MAX_SIZE_MB"
+)
+
+// Volume defaults.
+const (
+	DefaultDir       = "volumes"
+	DefaultSizeMB    = 1024
+	DefaultMaxSizeMB = 100 << 10
+
+	// MinSizeMB is the smallest volume that holds a usable ext4 filesystem.
+	MinSizeMB = 8
+)
+
+// Config holds configuration for persistent volumes.
+type Config struct {
+	// Dir is where volume filesystem images are stored, named by volume.
+	Dir string
+
+	// MaxSizeMB is the largest volume that may be created. Volume files are
+	// sparse, so only the space actually written is consumed.
+	MaxSizeMB int
+}
+
+// LoadConfig reads volume configuration from environment variables, applying
+// defaults for values not set.
+func LoadConfig() Config {
+	cfg := Config{
+		Dir:       DefaultDir,
+		MaxSizeMB: DefaultMaxSizeMB,
+	}
+
+	if v := os.Getenv(envDir); v != "" {
+		cfg.Dir = v
+	}
+	if v := os.Getenv(envMaxSizeMB); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= MinSizeMB {
+			cfg.MaxSizeMB = n
+		}
+	}
+
+	return cfg
+}
@@ -0,0 +1,30 @@
+package volumes
+
+import "testing"
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.Dir != DefaultDir || cfg.MaxSizeMB != DefaultMaxSizeMB {
+		t.Errorf("LoadConfig() = %+v, want defaults", cfg)
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	t.Setenv(envDir, "/var/lib/vulcan/volumes")
+	t.Setenv(envMaxSizeMB, "2048")
+
+	cfg := LoadConfig()
+	if cfg.Dir != "/var/lib/vulcan/volumes" {
+		t.Errorf("Dir = %q", cfg.Dir)
+	}
+	if cfg.MaxSizeMB != 2048 {
+		t.Errorf("MaxSizeMB = %d, want 2048", cfg.MaxSizeMB)
+	}
+}
+
+func TestLoadConfigIgnoresTinyMaxSize(t *testing.T) {
+	t.Setenv(envMaxSizeMB, "1")
+	if cfg := LoadConfig(); cfg.MaxSizeMB != DefaultMaxSizeMB {
+		t.Errorf("MaxSizeMB = %d, want the default", cfg.MaxSizeMB)
+	}
+}
@@ -0,0 +1,3 @@
+// Package volumes manages persistent volumes: named ext4 filesystems that
+// are attached to workloads as extra drives and outlive them.
+package volumes
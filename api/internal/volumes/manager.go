@@ -0,0 +1,225 @@
+package volumes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// ErrInvalidVolume is returned when a volume request is rejected.
+var ErrInvalidVolume = errors.New("invalid volume")
+
+// Formatter creates an empty ext4 filesystem filling the file at path.
+type Formatter func(ctx context.Context, path string) error
+
+// volumeExt is the extension of volume files.
+const volumeExt = ".ext4"
+
+// tempPrefix names volume files being created or copied.
+const tempPrefix = ".tmp-"
+
+// copyHolderPrefix names the attachment that keeps a volume's writers out
+// while it is being snapshotted or cloned.
+const copyHolderPrefix = "copy:"
+
+// Manager creates, copies and deletes volume files and records them in the
+// store. Attaching volumes to workloads goes through the store directly.
+type Manager struct {
+	cfg    Config
+	store  store.Store
+	format Formatter
+	logger *slog.Logger
+}
+
+// NewManager creates the volume manager, creating its directory if needed.
+// Files left behind by creations or copies interrupted by a restart are
+// removed, and every attachment is released: no sandbox outlives the process
+// that started it.
+func NewManager(ctx context.Context, cfg Config, s store.Store, format Formatter, logger *slog.Logger) (*Manager, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create volume dir: %w", err)
+	}
+	dir, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve volume dir: %w", err)
+	}
+	cfg.Dir = dir
+
+	stale, _ := filepath.Glob(filepath.Join(dir, tempPrefix+"*"))
+	for _, p := range stale {
+		os.Remove(p)
+	}
+	if err := s.DetachAllVolumes(ctx); err != nil {
+		return nil, err
+	}
+	return &Manager{cfg: cfg, store: s, format: format, logger: logger}, nil
+}
+
+// Create makes an empty volume of sizeMB, or DefaultSizeMB when zero.
+// Returns store.ErrVolumeExists if the name is taken.
+func (m *Manager) Create(ctx context.Context, name string, sizeMB int) (*model.Volume, error) {
+	if sizeMB == 0 {
+		sizeMB = DefaultSizeMB
+	}
+	if err := model.ValidateVolumeName(name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVolume, err)
+	}
+	if sizeMB < MinSizeMB || sizeMB > m.cfg.MaxSizeMB {
+		return nil, fmt.Errorf("%w: size_mb must be between %d and %d", ErrInvalidVolume, MinSizeMB, m.cfg.MaxSizeMB)
+	}
+	if err := m.checkNew(ctx, name); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(m.cfg.Dir, tempPrefix+"*"+volumeExt)
+	if err != nil {
+		return nil, fmt.Errorf("create volume file: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+	err = f.Truncate(int64(sizeMB) << 20)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("size volume file: %w", err)
+	}
+	if err := m.format(ctx, tmp); err != nil {
+		return nil, fmt.Errorf("format volume: %w", err)
+	}
+
+	v := &model.Volume{Name: name, SizeMB: sizeMB}
+	if err := m.add(ctx, v, tmp); err != nil {
+		return nil, err
+	}
+	m.logger.Info("volume created", "volume", name, "size_mb", sizeMB)
+	return v, nil
+}
+
+// Snapshot takes a read-only copy of the volume source named name. Returns
+// store.ErrVolumeLocked while source is attached read-write.
+func (m *Manager) Snapshot(ctx context.Context, source, name string) (*model.Volume, error) {
+	return m.copy(ctx, source, name, true)
+}
+
+// Clone copies the volume or snapshot source into a new writable volume
+// named name. Returns store.ErrVolumeLocked while source is attached
+// read-write.
+func (m *Manager) Clone(ctx context.Context, source, name string) (*model.Volume, error) {
+	return m.copy(ctx, source, name, false)
+}
+
+// copy copies source into a new volume, holding a read-only attachment on
+// source so that no workload writes to it meanwhile.
+func (m *Manager) copy(ctx context.Context, source, name string, snapshot bool) (*model.Volume, error) {
+	if err := model.ValidateVolumeName(name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVolume, err)
+	}
+	if err := m.checkNew(ctx, name); err != nil {
+		return nil, err
+	}
+
+	holder := copyHolderPrefix + name
+	locked, err := m.store.AttachVolumes(ctx, holder, []model.VolumeMount{{Name: source, ReadOnly: true}})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := m.store.DetachVolumes(context.WithoutCancel(ctx), holder); err != nil {
+			m.logger.Error("failed to release volume after copy", "volume", source, "error", err)
+		}
+	}()
+	src := locked[0]
+
+	f, err := os.CreateTemp(m.cfg.Dir, tempPrefix+"*"+volumeExt)
+	if err != nil {
+		return nil, fmt.Errorf("create volume file: %w", err)
+	}
+	f.Close()
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+	if err := copyFile(ctx, src.Path, tmp); err != nil {
+		return nil, fmt.Errorf("copy volume: %w", err)
+	}
+
+	v := &model.Volume{Name: name, SizeMB: src.SizeMB, Snapshot: snapshot, Source: source}
+	if err := m.add(ctx, v, tmp); err != nil {
+		return nil, err
+	}
+	m.logger.Info("volume copied", "volume", name, "source", source, "snapshot", snapshot)
+	return v, nil
+}
+
+// checkNew returns store.ErrVolumeExists if the name is taken.
+func (m *Manager) checkNew(ctx context.Context, name string) error {
+	_, err := m.store.GetVolume(ctx, name)
+	if err == nil {
+		return store.ErrVolumeExists
+	}
+	if !errors.Is(err, store.ErrVolumeNotFound) {
+		return err
+	}
+	return nil
+}
+
+// add moves the volume file at tmp into place for v and records v. Snapshot
+// files are made read-only.
+func (m *Manager) add(ctx context.Context, v *model.Volume, tmp string) error {
+	v.Path = filepath.Join(m.cfg.Dir, v.Name+volumeExt)
+	v.CreatedAt = time.Now().UTC()
+	v.Attachments = []model.VolumeAttachment{}
+
+	if v.Snapshot {
+		if err := os.Chmod(tmp, 0o444); err != nil {
+			return fmt.Errorf("store volume file: %w", err)
+		}
+	}
+	// Claim the name first so that a concurrent request for it cannot
+	// replace the file.
+	if err := m.store.CreateVolume(ctx, v); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, v.Path); err != nil {
+		if derr := m.store.DeleteVolume(context.WithoutCancel(ctx), v.Name); derr != nil {
+			m.logger.Error("failed to remove record of unstored volume", "volume", v.Name, "error", derr)
+		}
+		return fmt.Errorf("store volume file: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a volume and its file. Returns store.ErrVolumeNotFound if
+// it does not exist and store.ErrVolumeInUse if it is attached.
+func (m *Manager) Delete(ctx context.Context, name string) error {
+	v, err := m.store.GetVolume(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := m.store.DeleteVolume(ctx, name); err != nil {
+		return err
+	}
+	if err := os.Remove(v.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		m.logger.Warn("failed to remove volume file", "volume", name, "path", v.Path, "error", err)
+	}
+	m.logger.Info("volume deleted", "volume", name)
+	return nil
+}
+
+// copyFile copies the volume file src to dst, sharing blocks with src when
+// the filesystem supports it and keeping unwritten regions sparse.
+func copyFile(ctx context.Context, src, dst string) error {
+	out, err := exec.CommandContext(ctx, "cp", "--reflink=auto", "--sparse=always", src, dst).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cp %s %s: %w: %s", src, dst, err, strings.TrimSpace(string(out)))
+	}
+	return os.Chmod(dst, 0o644)
+}
@@ -0,0 +1,231 @@
+package volumes
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seantiz/vulcan/internal/model"
+	"github.com/seantiz/vulcan/internal/store"
+)
+
+// fakeFormat stands in for mkfs by writing a marker at the start of the file.
+func fakeFormat(_ context.Context, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteAt([]byte("ext4"), 0)
+	return err
+}
+
+func newTestManager(t *testing.T) (*Manager, *store.SQLiteStore) {
+	t.Helper()
+	s, err := store.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	cfg := Config{Dir: t.TempDir(), MaxSizeMB: 64}
+	m, err := NewManager(context.Background(), cfg, s, fakeFormat, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m, s
+}
+
+func TestCreateVolume(t *testing.T) {
+	m, s := newTestManager(t)
+	ctx := context.Background()
+
+	v, err := m.Create(ctx, "data", 16)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if v.Path != filepath.Join(m.cfg.Dir, "data.ext4") || v.SizeMB != 16 || v.Snapshot {
+		t.Errorf("Create = %+v", v)
+	}
+	info, err := os.Stat(v.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 16<<20 {
+		t.Errorf("volume file is %d bytes, want %d", info.Size(), 16<<20)
+	}
+	if _, err := s.GetVolume(ctx, "data"); err != nil {
+		t.Errorf("volume not recorded: %v", err)
+	}
+
+	if _, err := m.Create(ctx, "data", 16); !errors.Is(err, store.ErrVolumeExists) {
+		t.Errorf("duplicate Create = %v, want ErrVolumeExists", err)
+	}
+	entries, _ := os.ReadDir(m.cfg.Dir)
+	if len(entries) != 1 {
+		t.Errorf("volume dir holds %d files, want 1", len(entries))
+	}
+}
+
+func TestCreateVolumeInvalid(t *testing.T) {
+	m, _ := newTestManager(t)
+	for _, tc := range []struct {
+		name   string
+		sizeMB int
+	}{
+		{"Data", 16},
+		{"../data", 16},
+		{"data", MinSizeMB - 1},
+		{"data", 65},
+		{"data", 0}, // DefaultSizeMB exceeds the test maximum
+	} {
+		if _, err := m.Create(context.Background(), tc.name, tc.sizeMB); !errors.Is(err, ErrInvalidVolume) {
+			t.Errorf("Create(%q, %d) = %v, want ErrInvalidVolume", tc.name, tc.sizeMB, err)
+		}
+	}
+}
+
+func TestCreateVolumeFormatFailure(t *testing.T) {
+	m, s := newTestManager(t)
+	m.format = func(context.Context, string) error { return errors.New("mkfs failed") }
+
+	if _, err := m.Create(context.Background(), "data", 16); err == nil {
+		t.Fatal("Create succeeded despite the format failure")
+	}
+	if _, err := s.GetVolume(context.Background(), "data"); !errors.Is(err, store.ErrVolumeNotFound) {
+		t.Errorf("GetVolume = %v, want ErrVolumeNotFound", err)
+	}
+	if entries, _ := os.ReadDir(m.cfg.Dir); len(entries) != 0 {
+		t.Errorf("volume dir holds %d files after a failed create", len(entries))
+	}
+}
+
+func TestSnapshotAndClone(t *testing.T) {
+	m, s := newTestManager(t)
+	ctx := context.Background()
+	src, err := m.Create(ctx, "data", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src.Path, []byte("contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := m.Snapshot(ctx, "data", "data-snap")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if !snap.Snapshot || snap.Source != "data" || snap.SizeMB != 16 {
+		t.Errorf("Snapshot = %+v", snap)
+	}
+	if info, err := os.Stat(snap.Path); err != nil || info.Mode().Perm() != 0o444 {
+		t.Errorf("snapshot file mode = %v, %v, want read-only", info, err)
+	}
+
+	clone, err := m.Clone(ctx, "data-snap", "data-clone")
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if clone.Snapshot || clone.Source != "data-snap" {
+		t.Errorf("Clone = %+v", clone)
+	}
+	for _, p := range []string{snap.Path, clone.Path} {
+		if data, _ := os.ReadFile(p); string(data) != "contents" {
+			t.Errorf("%s = %q, want a copy of the source", p, data)
+		}
+	}
+
+	// The source is released once the copies are done.
+	v, err := s.GetVolume(ctx, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Attachments) != 0 {
+		t.Errorf("source still attached: %+v", v.Attachments)
+	}
+}
+
+func TestSnapshotLockedVolume(t *testing.T) {
+	m, s := newTestManager(t)
+	ctx := context.Background()
+	if _, err := m.Create(ctx, "data", 16); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AttachVolumes(ctx, "wl-1", []model.VolumeMount{{Name: "data", MountPath: "/data"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Snapshot(ctx, "data", "snap"); !errors.Is(err, store.ErrVolumeLocked) {
+		t.Errorf("Snapshot of a written volume = %v, want ErrVolumeLocked", err)
+	}
+	if _, err := m.Clone(ctx, "missing", "clone"); !errors.Is(err, store.ErrVolumeNotFound) {
+		t.Errorf("Clone of a missing volume = %v, want ErrVolumeNotFound", err)
+	}
+	if _, err := m.Snapshot(ctx, "data", "data"); !errors.Is(err, store.ErrVolumeExists) {
+		t.Errorf("Snapshot onto an existing name = %v, want ErrVolumeExists", err)
+	}
+}
+
+func TestDeleteVolume(t *testing.T) {
+	m, s := newTestManager(t)
+	ctx := context.Background()
+	v, err := m.Create(ctx, "data", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AttachVolumes(ctx, "wl-1", []model.VolumeMount{{Name: "data", MountPath: "/data", ReadOnly: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(ctx, "data"); !errors.Is(err, store.ErrVolumeInUse) {
+		t.Errorf("Delete while attached = %v, want ErrVolumeInUse", err)
+	}
+
+	s.DetachVolumes(ctx, "wl-1")
+	if err := m.Delete(ctx, "data"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(v.Path); !os.IsNotExist(err) {
+		t.Error("volume file not removed")
+	}
+	if err := m.Delete(ctx, "data"); !errors.Is(err, store.ErrVolumeNotFound) {
+		t.Errorf("second Delete = %v, want ErrVolumeNotFound", err)
+	}
+}
+
+func TestNewManagerRecovers(t *testing.T) {
+	s, err := store.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	dir := t.TempDir()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	m, err := NewManager(ctx, Config{Dir: dir, MaxSizeMB: 64}, s, fakeFormat, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create(ctx, "data", 16); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AttachVolumes(ctx, "wl-1", []model.VolumeMount{{Name: "data", MountPath: "/data"}}); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dir, tempPrefix+"1"+volumeExt)
+	os.WriteFile(stale, nil, 0o644)
+
+	if _, err := NewManager(ctx, Config{Dir: dir, MaxSizeMB: 64}, s, fakeFormat, logger); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("interrupted volume file not removed")
+	}
+	if v, _ := s.GetVolume(ctx, "data"); len(v.Attachments) != 0 {
+		t.Errorf("attachments survived a restart: %+v", v.Attachments)
+	}
+}
@@ -0,0 +1,277 @@
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seantiz/vulcan/internal/backend"
+	fc "github.com/seantiz/vulcan/internal/backend/firecracker"
+	"github.com/seantiz/vulcan/internal/model"
+)
+
+// envFirecrackerE2E enables tests that boot real Firecracker microVMs. They
+// need root, /dev/kvm, the firecracker binary, CNI plugins, nft and kernel
+// and rootfs images configured through the VULCAN_FC_* variables.
+const envFirecrackerE2E = "VULCAN_E2E_FIRECRACKER"
+
+// newFirecrackerBackend returns a real Firecracker backend and its config,
+// skipping the test unless envFirecrackerE2E is set.
+func newFirecrackerBackend(t *testing.T) (*fc.Backend, fc.Config) {
+	t.Helper()
+	if os.Getenv(envFirecrackerE2E) != "1" {
+		t.Skipf("set %s=1 to boot real Firecracker microVMs", envFirecrackerE2E)
+	}
+
+	cfg := fc.LoadConfig()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	b, err := fc.NewBackend(cfg, logger)
+	if err != nil {
+		t.Fatalf("NewBackend: %v", err)
+	}
+	if err := b.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		b.Shutdown(ctx)
+	})
+	return b, cfg
+}
+
+// TestMicroVMGuestReachesGateway checks that the guest agent configures eth0,
+// the default route and resolv.conf from the kernel ip= parameter: a workload
+// inside the VM connects to a listener on the host's bridge gateway.
+func TestMicroVMGuestReachesGateway(t *testing.T) {
+	b, cfg := newFirecrackerBackend(t)
+	pools, err := cfg.AddressPools()
+	if err != nil {
+		t.Fatalf("AddressPools: %v", err)
+	}
+	var gateway string
+	for _, p := range pools {
+		if p.Subnet.Addr().Is4() {
+			gateway = p.Gateway.String()
+			break
+		}
+	}
+
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			fmt.Fprintln(conn, "pong")
+			bufio.NewReader(conn).ReadString('\n')
+			conn.Close()
+		}
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	code := fmt.Sprintf(`import socket
+s = socket.create_connection((%q, %d), timeout=5)
+print(s.makefile().readline().strip())
+s.sendall(b"bye\n")
+print(open("/etc/resolv.conf").read().split()[1])
+`, gateway, port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	result, err := b.Execute(ctx, backend.WorkloadSpec{
+		ID:        model.NewID(),
+		Runtime:   model.RuntimePython,
+		Isolation: model.IsolationMicroVM,
+		Code:      code,
+		TimeoutS:  30,
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code = %d, error = %q, logs = %v", result.ExitCode, result.Error, result.LogLines)
+	}
+
+	lines := strings.Fields(string(result.Output))
+	if len(lines) != 2 || lines[0] != "pong" {
+		t.Fatalf("output = %q, want pong from the gateway listener", result.Output)
+	}
+	if len(cfg.Nameservers) > 0 && lines[1] != cfg.Nameservers[0] {
+		t.Errorf("resolv.conf nameserver = %q, want %q", lines[1], cfg.Nameservers[0])
+	}
+}
+
+// TestMicroVMExposePort checks that a port exposed by a workload is reachable
+// from the host through the endpoint the backend reports while it runs.
+func TestMicroVMExposePort(t *testing.T) {
+	b, _ := newFirecrackerBackend(t)
+
+	code := `import http.server, threading
+class H(http.server.BaseHTTPRequestHandler):
+    def do_GET(self):
+        self.send_response(200)
+        self.end_headers()
+        self.wfile.write(b"hello from " + self.path.encode())
+        threading.Thread(target=srv.shutdown).start()
+srv = http.server.HTTPServer(("0.0.0.0", 8080), H)
+srv.serve_forever()
+`
+	endpoint := make(chan string, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	type outcome struct {
+		result backend.WorkloadResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := b.Execute(ctx, backend.WorkloadSpec{
+			ID:         model.NewID(),
+			Runtime:    model.RuntimePython,
+			Isolation:  model.IsolationMicroVM,
+			Code:       code,
+			TimeoutS:   30,
+			Network:    &model.NetworkPolicy{Mode: model.NetworkEgress},
+			ExposePort: 8080,
+			OnEndpoint: func(url string) { endpoint <- url },
+		})
+		done <- outcome{result, err}
+	}()
+
+	var url string
+	select {
+	case url = <-endpoint:
+	case out := <-done:
+		t.Fatalf("workload finished before exposing its port: %+v, %v", out.result, out.err)
+	}
+
+	// The server may still be starting; retry until it answers.
+	var body []byte
+	for deadline := time.Now().Add(20 * time.Second); ; {
+		resp, err := http.Get(url + "/ping")
+		if err == nil {
+			body, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	if string(body) != "hello from /ping" {
+		t.Errorf("body = %q, want %q", body, "hello from /ping")
+	}
+
+	out := <-done
+	if out.err != nil || out.result.ExitCode != 0 {
+		t.Fatalf("Execute = %+v, %v", out.result, out.err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Errorf("endpoint %s still reachable after the workload finished", url)
+	}
+}
+
+// TestMicroVMGroupDiscovery checks that workloads in the same network group
+// reach each other by name, and that a workload outside the group cannot
+// resolve them.
+func TestMicroVMGroupDiscovery(t *testing.T) {
+	b, _ := newFirecrackerBackend(t)
+	if !b.Capabilities().NetworkGroups {
+		t.Skip("network groups are disabled")
+	}
+	group := "e2e-" + strings.ToLower(model.NewID()[20:])
+
+	server := `import socket
+l = socket.socket()
+l.setsockopt(socket.SOL_SOCKET, socket.SO_REUSEADDR, 1)
+l.bind(("0.0.0.0", 9000))
+l.listen(1)
+l.settimeout(30)
+c, _ = l.accept()
+c.sendall(b"hello from db\n")
+c.close()
+`
+	client := `import socket, time
+for _ in range(100):
+    try:
+        s = socket.create_connection(("db", 9000), timeout=2)
+        break
+    except OSError:
+        time.sleep(0.2)
+print(s.makefile().readline().strip())
+`
+	outsider := `import socket
+try:
+    socket.gethostbyname("db.` + group + `.vulcan")
+    print("resolved")
+except OSError:
+    print("unresolved")
+`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	run := func(name, code string) (backend.WorkloadResult, error) {
+		return b.Execute(ctx, backend.WorkloadSpec{
+			ID:        model.NewID(),
+			Runtime:   model.RuntimePython,
+			Isolation: model.IsolationMicroVM,
+			Code:      code,
+			TimeoutS:  45,
+			Group:     group,
+			Name:      name,
+		})
+	}
+
+	type outcome struct {
+		result backend.WorkloadResult
+		err    error
+	}
+	db := make(chan outcome, 1)
+	go func() {
+		result, err := run("db", server)
+		db <- outcome{result, err}
+	}()
+
+	result, err := run("web", client)
+	if err != nil || result.ExitCode != 0 {
+		t.Fatalf("client = %+v, %v", result, err)
+	}
+	if got := strings.TrimSpace(string(result.Output)); got != "hello from db" {
+		t.Errorf("client output = %q, want %q", got, "hello from db")
+	}
+	if out := <-db; out.err != nil || out.result.ExitCode != 0 {
+		t.Fatalf("server = %+v, %v", out.result, out.err)
+	}
+
+	result, err = b.Execute(ctx, backend.WorkloadSpec{
+		ID:        model.NewID(),
+		Runtime:   model.RuntimePython,
+		Isolation: model.IsolationMicroVM,
+		Code:      outsider,
+		TimeoutS:  30,
+	})
+	if err != nil || result.ExitCode != 0 {
+		t.Fatalf("outsider = %+v, %v", result, err)
+	}
+	if got := strings.TrimSpace(string(result.Output)); got != "unresolved" {
+		t.Errorf("outsider lookup = %q, want unresolved", got)
+	}
+}
@@ -47,7 +47,7 @@ func (s *stubBackend) Execute(ctx context.Context, spec backend.WorkloadSpec) (b
 	// Emit log lines after delay so subscribers can receive them.
 	if spec.LogWriter != nil {
 		for _, line := range s.logLines {
-			spec.LogWriter(line)
+			spec.LogWriter("", line)
 			if s.logLineDelay > 0 {
 				select {
 				case <-time.After(s.logLineDelay):
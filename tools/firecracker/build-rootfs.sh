@@ -92,10 +92,10 @@ case "${RUNTIME}" in
         sudo chroot "${MOUNT_POINT}" /sbin/apk add --no-cache go musl-dev
         ;;
     node)
-        sudo chroot "${MOUNT_POINT}" /sbin/apk add --no-cache nodejs
+        sudo chroot "${MOUNT_POINT}" /sbin/apk add --no-cache nodejs npm
         ;;
     python)
-        sudo chroot "${MOUNT_POINT}" /sbin/apk add --no-cache python3
+        sudo chroot "${MOUNT_POINT}" /sbin/apk add --no-cache python3 py3-pip
         ;;
 esac
 
@@ -109,6 +109,23 @@ echo "[INSTALL] Installing vulcan-guest agent..."
 sudo cp "${GUEST_BIN}" "${MOUNT_POINT}/usr/local/bin/vulcan-guest"
 sudo chmod +x "${MOUNT_POINT}/usr/local/bin/vulcan-guest"
 
+# Declare the runtime the guest agent runs. The host reads this manifest to
+# discover the runtime, and the agent to run its code.
+echo "[INSTALL] Writing runtime manifest..."
+sudo mkdir -p "${MOUNT_POINT}/etc/vulcan"
+case "${RUNTIME}" in
+    go)
+        RUNTIME_DEF='{"name": "go", "bin": "go", "args": ["run", "{entrypoint}"], "extension": ".go"}'
+        ;;
+    node)
+        RUNTIME_DEF='{"name": "node", "bin": "node", "entrypoint": "index.js", "extension": ".js"}'
+        ;;
+    python)
+        RUNTIME_DEF='{"name": "python", "bin": "python3", "extension": ".py"}'
+        ;;
+esac
+echo "{\"runtimes\": [${RUNTIME_DEF}]}" | sudo tee "${MOUNT_POINT}/etc/vulcan/runtimes.json" > /dev/null
+
 # Create work directory
 sudo mkdir -p "${MOUNT_POINT}/work"
 
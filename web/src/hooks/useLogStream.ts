@@ -9,6 +9,10 @@ const LOG_SSE_BASE_URL = "/api/v1/workloads";
 const MAX_RECONNECT_ATTEMPTS = 3;
 const RECONNECT_DELAY_MS = 2000;
 
+// Log lines arrive as events named after the stream they were written to;
+// lines from sandboxes that cannot tell the streams apart arrive unnamed.
+const LOG_STREAMS = ["stdout", "stderr", "system"];
+
 export type LogStreamState =
   | "connecting"
   | "connected"
@@ -53,9 +57,13 @@ export function useLogStream(
         reconnectCountRef.current = 0;
       };
 
-      es.onmessage = (event) => {
+      const appendLine = (event: MessageEvent) => {
         setLines((prev) => [...prev, event.data]);
       };
+      es.onmessage = appendLine;
+      for (const stream of LOG_STREAMS) {
+        es.addEventListener(stream, appendLine);
+      }
 
       es.addEventListener("done", () => {
         doneReceivedRef.current = true;
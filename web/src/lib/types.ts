@@ -88,6 +88,7 @@ export interface WorkloadListResponse {
 
 export interface LogHistoryLine {
   seq: number;
+  stream?: string;
   line: string;
   created_at: string;
 }